@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/tympanix/nexus-cli/internal/config"
+	"github.com/tympanix/nexus-cli/internal/deps"
+	"github.com/tympanix/nexus-cli/internal/nexusapi"
+	"github.com/tympanix/nexus-cli/internal/util"
+)
+
+// watchReads creates dir and reports the names of the files in it that are read from
+// until the returned function is called, using inotify. It observes every read by any
+// code, not only those of the checksum package.
+func watchReads(t *testing.T, dir string) func() []string {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := syscall.InotifyAddWatch(fd, dir, syscall.IN_ACCESS); err != nil {
+		syscall.Close(fd)
+		t.Fatal(err)
+	}
+	return func() []string {
+		defer syscall.Close(fd)
+		var names []string
+		buf := make([]byte, 64*1024)
+		for {
+			n, err := syscall.Read(fd, buf)
+			if errors.Is(err, syscall.EAGAIN) {
+				return names
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Each event is a syscall.InotifyEvent followed by its NUL padded name
+			for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+				nameLen := int(binary.NativeEndian.Uint32(buf[offset+12:]))
+				name := buf[offset+syscall.SizeofInotifyEvent : offset+syscall.SizeofInotifyEvent+nameLen]
+				names = append(names, strings.TrimRight(string(name), "\x00"))
+				offset += syscall.SizeofInotifyEvent + nameLen
+			}
+		}
+	}
+}
+
+// TestDepsSyncVerifiesWithoutRereading tests that a downloaded file is verified against
+// deps-lock.ini without reading it back from disk, and that a mismatch is still caught
+func TestDepsSyncVerifiesWithoutRereading(t *testing.T) {
+	mockServer := nexusapi.NewMockNexusServer()
+	defer mockServer.Close()
+
+	testChecksum := "0505007cc25ef733fb754c26db7dd8c38c5cf8f75f571f60a66548212c25b2fa"
+	mockServer.AddAsset("libs", "/docs/example-1.0.0.txt", nexusapi.Asset{}, []byte("test file content for sync"))
+
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(oldDir)
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+
+	depsIniContent := `[defaults]
+repository = libs
+checksum = sha256
+output_dir = ./local
+
+[example_txt]
+path = docs/example-${version}.txt
+version = 1.0.0
+`
+	if err := os.WriteFile("deps.ini", []byte(depsIniContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+	writeLock := func(sum string) {
+		t.Helper()
+		if err := os.WriteFile("deps-lock.ini", []byte("[example_txt]\ndocs/example-1.0.0.txt = sha256:"+sum+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cfg := &config.Config{NexusURL: mockServer.URL, Username: "test", Password: "test"}
+	runSync := func() error {
+		return depsSyncMain(cfg, nil, &DepsSyncOptions{Logger: util.NewLogger(io.Discard), QuietMode: true})
+	}
+	downloadDir := filepath.Join("local", "docs")
+
+	writeLock(testChecksum)
+	reads := watchReads(t, downloadDir)
+	if err := runSync(); err != nil {
+		t.Fatalf("deps sync failed: %v", err)
+	}
+	if names := reads(); len(names) != 0 {
+		t.Errorf("Expected the downloaded file not to be read again, read %v", names)
+	}
+
+	// Verifying the file on disk is observed as a read
+	reads = watchReads(t, downloadDir)
+	if _, err := deps.VerifyLockedFile(filepath.Join(downloadDir, "example-1.0.0.txt"), "sha256:"+testChecksum); err != nil {
+		t.Fatalf("VerifyLockedFile failed: %v", err)
+	}
+	if names := reads(); len(names) == 0 || names[0] != "example-1.0.0.txt" {
+		t.Errorf("Expected reading the file to be observed, got %v", names)
+	}
+
+	// A download that does not match deps-lock.ini fails without reading the file again
+	if err := os.RemoveAll("local"); err != nil {
+		t.Fatal(err)
+	}
+	writeLock(strings.Repeat("0", 64))
+	reads = watchReads(t, downloadDir)
+	if err := runSync(); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Errorf("Expected a checksum mismatch, got %v", err)
+	}
+	if names := reads(); len(names) != 0 {
+		t.Errorf("Expected the mismatch to be found without reading the file again, read %v", names)
+	}
+}
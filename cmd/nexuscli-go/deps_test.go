@@ -1,12 +1,22 @@
 package main
 
 import (
+	"encoding/pem"
+	"errors"
+	"io"
+	"net/http"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"testing"
 
+	"github.com/tympanix/nexus-cli/internal/config"
+	"github.com/tympanix/nexus-cli/internal/deps"
+	"github.com/tympanix/nexus-cli/internal/exitcode"
 	"github.com/tympanix/nexus-cli/internal/nexusapi"
+	"github.com/tympanix/nexus-cli/internal/operations"
+	"github.com/tympanix/nexus-cli/internal/util"
 )
 
 func TestDepsInitCommand(t *testing.T) {
@@ -45,6 +55,60 @@ func TestDepsInitCommand(t *testing.T) {
 	}
 }
 
+func TestDepsCheckCommand(t *testing.T) {
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(oldDir)
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+
+	depsIniContent := `[defaults]
+repository = libs
+checksum = sha256
+
+[example_txt]
+path = docs/example.txt
+`
+	if err := os.WriteFile("deps.ini", []byte(depsIniContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+	lockFileContent := "[example_txt]\ndocs/example.txt = sha256:" + strings.Repeat("a", 64) + ":12\n"
+	if err := os.WriteFile("deps-lock.ini", []byte(lockFileContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf strings.Builder
+	if err := depsCheckMain(util.NewLogger(&buf)); err != nil {
+		t.Fatalf("Expected consistent files to pass, got %v", err)
+	}
+	if !strings.Contains(buf.String(), "consistent (1 dependencies)") {
+		t.Errorf("Expected a confirmation, got %q", buf.String())
+	}
+
+	// Every problem in deps.ini is reported at once, with its line
+	depsIniContent += "repositry = libs\n\n[other]\npath = docs/other.txt\n"
+	if err := os.WriteFile("deps.ini", []byte(depsIniContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+	err = depsCheckMain(util.NewLogger(io.Discard))
+	if err == nil || !strings.Contains(err.Error(), "line 7: unknown key 'repositry' in [example_txt] section (did you mean 'repository'?)") {
+		t.Fatalf("Expected the typo to be reported with its line, got %v", err)
+	}
+
+	// A valid deps.ini is checked against the lock file
+	depsIniContent = strings.Replace(depsIniContent, "repositry = libs\n", "", 1)
+	if err := os.WriteFile("deps.ini", []byte(depsIniContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+	err = depsCheckMain(util.NewLogger(io.Discard))
+	if err == nil || !strings.Contains(err.Error(), "dependency other is not locked") {
+		t.Fatalf("Expected the unlocked dependency to be reported, got %v", err)
+	}
+}
+
 func TestDepsEnvCommand(t *testing.T) {
 	tmpDir := t.TempDir()
 	oldDir, err := os.Getwd()
@@ -298,6 +362,262 @@ docs/example-1.0.0.txt = sha256:` + testChecksum + `
 	}
 }
 
+func TestDepsSyncVerboseDecisions(t *testing.T) {
+	mockServer := nexusapi.NewMockNexusServer()
+	defer mockServer.Close()
+
+	testFileContent := []byte("test file content for sync")
+	testChecksum := "0505007cc25ef733fb754c26db7dd8c38c5cf8f75f571f60a66548212c25b2fa"
+	mockServer.AddAsset("libs", "/docs/example-1.0.0.txt", nexusapi.Asset{}, testFileContent)
+
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(oldDir)
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+
+	depsIniContent := `[defaults]
+repository = libs
+checksum = sha256
+output_dir = ./local
+
+[example_txt]
+path = docs/example-${version}.txt
+version = 1.0.0
+`
+	if err := os.WriteFile("deps.ini", []byte(depsIniContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+	lockFileContent := "[example_txt]\ndocs/example-1.0.0.txt = sha256:" + testChecksum + "\n"
+	if err := os.WriteFile("deps-lock.ini", []byte(lockFileContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{NexusURL: mockServer.URL, Username: "test", Password: "test"}
+	for _, expected := range []string{
+		"docs/example-1.0.0.txt: downloaded; verified against deps-lock.ini while downloading (sha256)",
+		"docs/example-1.0.0.txt: skipped, already matching; verified against deps-lock.ini while comparing (sha256)",
+	} {
+		var buf strings.Builder
+		if err := depsSyncMain(cfg, nil, &DepsSyncOptions{Logger: util.NewVerboseLogger(&buf), QuietMode: true}); err != nil {
+			t.Fatalf("deps sync failed: %v", err)
+		}
+		if !strings.Contains(buf.String(), expected) {
+			t.Errorf("Expected %q in the verbose output, got:\n%s", expected, buf.String())
+		}
+	}
+}
+
+// TestDepsSyncKeepGoing tests that a failing dependency does not stop the others with
+// --keep-going, and that the output directory of the failed one is not cleaned up
+func TestDepsSyncKeepGoing(t *testing.T) {
+	mockServer := nexusapi.NewMockNexusServer()
+	defer mockServer.Close()
+
+	mockServer.AddAsset("libs", "/docs/a-1.0.0.txt", nexusapi.Asset{}, []byte("a"))
+	mockServer.AddAsset("libs", "/docs/b-1.0.0.txt", nexusapi.Asset{}, []byte("b"))
+	checksumA := mockServer.Assets["libs:/docs/a-1.0.0.txt"].Checksum.SHA256
+	checksumB := mockServer.Assets["libs:/docs/b-1.0.0.txt"].Checksum.SHA256
+
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(oldDir)
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+
+	depsIniContent := `[defaults]
+repository = libs
+checksum = sha256
+output_dir = ./local
+
+[dep_a]
+path = docs/a-${version}.txt
+version = 1.0.0
+
+[dep_b]
+path = docs/b-${version}.txt
+version = 1.0.0
+
+[dep_missing]
+path = docs/missing-${version}.txt
+version = 1.0.0
+output_dir = ./broken
+`
+	if err := os.WriteFile("deps.ini", []byte(depsIniContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+	lockFileContent := "[dep_a]\ndocs/a-1.0.0.txt = sha256:" + checksumA +
+		"\n\n[dep_b]\ndocs/b-1.0.0.txt = sha256:" + checksumB +
+		"\n\n[dep_missing]\ndocs/missing-1.0.0.txt = sha256:" + strings.Repeat("0", 64) + "\n"
+	if err := os.WriteFile("deps-lock.ini", []byte(lockFileContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"local/stale.txt", "broken/keep.txt"} {
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, []byte("untracked"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := &config.Config{NexusURL: mockServer.URL, Username: "test", Password: "test"}
+	var buf strings.Builder
+	err = depsSyncMain(cfg, nil, &DepsSyncOptions{Logger: util.NewLogger(&buf), QuietMode: true, CleanupUntracked: true, KeepGoing: true})
+	if err == nil || !strings.Contains(err.Error(), "1 of 3 dependencies failed") {
+		t.Fatalf("Expected deps sync to report one failed dependency, got %v", err)
+	}
+	for _, want := range []string{"Dependencies synced: 2", "Dependencies failed: 1", "✗ dep_missing:"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Expected %q in the summary, got:\n%s", want, buf.String())
+		}
+	}
+
+	for _, name := range []string{"local/docs/a-1.0.0.txt", "local/docs/b-1.0.0.txt", "broken/keep.txt"} {
+		if _, err := os.Stat(name); err != nil {
+			t.Errorf("Expected %s to exist: %v", name, err)
+		}
+	}
+	if _, err := os.Stat("local/stale.txt"); !os.IsNotExist(err) {
+		t.Error("Expected the untracked file of the succeeded dependencies to be cleaned up")
+	}
+}
+
+func TestDepsSyncKeepGoingPhases(t *testing.T) {
+	mockServer := nexusapi.NewMockNexusServer()
+	defer mockServer.Close()
+
+	mockServer.AddAsset("libs", "/docs/a-1.0.0.txt", nexusapi.Asset{}, []byte("a"))
+	mockServer.AddAsset("libs", "/docs/changed-1.0.0.txt", nexusapi.Asset{}, []byte("changed"))
+	mockServer.AddAsset("libs", "/docs/unlocked-1.0.0.txt", nexusapi.Asset{}, []byte("unlocked"))
+	// Listed, but its content cannot be downloaded
+	mockServer.AddAsset("libs", "/docs/broken-1.0.0.txt", nexusapi.Asset{}, nil)
+	checksumA := mockServer.Assets["libs:/docs/a-1.0.0.txt"].Checksum.SHA256
+
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(oldDir)
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+
+	depsIniContent := `[defaults]
+repository = libs
+checksum = sha256
+
+[dep_a]
+path = docs/a-${version}.txt
+version = 1.0.0
+output_dir = ./a
+
+[dep_changed]
+path = docs/changed-${version}.txt
+version = 1.0.0
+output_dir = ./changed
+
+[dep_unlocked]
+path = docs/unlocked-${version}.txt
+version = 1.0.0
+output_dir = ./unlocked
+
+[dep_broken]
+path = docs/broken-${version}.txt
+version = 1.0.0
+output_dir = ./broken
+
+[dep_missing]
+path = docs/missing-${version}.txt
+version = 1.0.0
+output_dir = ./missing
+`
+	if err := os.WriteFile("deps.ini", []byte(depsIniContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+	lockFileContent := "[dep_a]\ndocs/a-1.0.0.txt = sha256:" + checksumA +
+		"\n\n[dep_changed]\ndocs/changed-1.0.0.txt = sha256:" + strings.Repeat("1", 64) +
+		"\n\n[dep_broken]\ndocs/broken-1.0.0.txt = sha256:" + strings.Repeat("2", 64) +
+		"\n\n[dep_missing]\ndocs/missing-1.0.0.txt = sha256:" + strings.Repeat("0", 64) + "\n"
+	if err := os.WriteFile("deps-lock.ini", []byte(lockFileContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{NexusURL: mockServer.URL, Username: "test", Password: "test"}
+	var buf strings.Builder
+	err = depsSyncMain(cfg, nil, &DepsSyncOptions{Logger: util.NewLogger(&buf), QuietMode: true, CleanupUntracked: true, KeepGoing: true})
+	if err == nil || !strings.Contains(err.Error(), "4 of 5 dependencies failed") {
+		t.Fatalf("Expected deps sync to report four failed dependencies, got %v", err)
+	}
+	for _, want := range []string{
+		"Dependencies synced: 1",
+		"Dependencies failed: 4",
+		"✗ dep_unlocked: resolve failed:",
+		"✗ dep_missing: resolve failed: locked file no longer exists upstream: docs/missing-1.0.0.txt; re-run deps lock",
+		"✗ dep_broken: download failed:",
+		"✗ dep_changed: verify failed:",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Expected %q in the summary, got:\n%s", want, buf.String())
+		}
+	}
+	if _, err := os.Stat("a/docs/a-1.0.0.txt"); err != nil {
+		t.Errorf("Expected the file of the succeeded dependency to exist: %v", err)
+	}
+}
+
+func TestDepsSyncStopsAtFailure(t *testing.T) {
+	mockServer := nexusapi.NewMockNexusServer()
+	defer mockServer.Close()
+	// Listed, but its content cannot be downloaded
+	mockServer.AddAsset("libs", "/docs/missing-1.0.0.txt", nexusapi.Asset{}, nil)
+
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(oldDir)
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+
+	depsIniContent := `[defaults]
+repository = libs
+checksum = sha256
+
+[dep_missing]
+path = docs/missing-${version}.txt
+version = 1.0.0
+output_dir = ./missing
+`
+	if err := os.WriteFile("deps.ini", []byte(depsIniContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+	lockFileContent := "[dep_missing]\ndocs/missing-1.0.0.txt = sha256:" + strings.Repeat("0", 64) + "\n"
+	if err := os.WriteFile("deps-lock.ini", []byte(lockFileContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{NexusURL: mockServer.URL, Username: "test", Password: "test"}
+	err = depsSyncMain(cfg, nil, &DepsSyncOptions{Logger: util.NewLogger(io.Discard), QuietMode: true, CleanupUntracked: true})
+	var syncErr *syncError
+	if !errors.As(err, &syncErr) {
+		t.Fatalf("Expected a sync error, got %v", err)
+	}
+	if syncErr.phase != syncPhaseDownload || syncErr.status == 0 {
+		t.Errorf("Expected a download failure with its exit status, got phase %q and status %d", syncErr.phase, syncErr.status)
+	}
+	if !strings.HasPrefix(err.Error(), "dep_missing: download failed:") {
+		t.Errorf("Expected the error to name the dependency and phase, got %v", err)
+	}
+}
+
 func TestDepsSyncRecursiveDependency(t *testing.T) {
 	t.Skip("Skipping due to known issue with recursive dependency path handling and flatten option")
 
@@ -518,7 +838,16 @@ docs/example-1.0.0.txt = sha256:` + wrongChecksum + `
 	}
 }
 
-func TestDepsSyncMissingLockEntry(t *testing.T) {
+// TestDepsSyncMultipleChecksums tests that every checksum in deps-lock.ini is verified,
+// so a mismatch in just one algorithm fails the sync
+func TestDepsSyncMultipleChecksums(t *testing.T) {
+	mockServer := nexusapi.NewMockNexusServer()
+	defer mockServer.Close()
+
+	testFileContent := []byte("test file content")
+	mockServer.AddAsset("libs", "/docs/example-1.0.0.txt", nexusapi.Asset{}, testFileContent)
+	asset := mockServer.Assets["libs:/docs/example-1.0.0.txt"]
+
 	tmpDir := t.TempDir()
 	oldDir, err := os.Getwd()
 	if err != nil {
@@ -532,7 +861,7 @@ func TestDepsSyncMissingLockEntry(t *testing.T) {
 
 	depsIniContent := `[defaults]
 repository = libs
-checksum = sha256
+checksum = sha256,sha512
 output_dir = ./local
 
 [example_txt]
@@ -543,110 +872,755 @@ version = 1.0.0
 		t.Fatal(err)
 	}
 
-	lockFileContent := `[other_dependency]
-other/file.txt = sha256:abcd1234
+	rootCmd := buildRootCommand()
+	rootCmd.SetArgs([]string{"deps", "lock", "--url", mockServer.URL})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("deps lock failed: %v", err)
+	}
+	lockFile, err := deps.ParseLockFile("deps-lock.ini")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "sha256:" + asset.Checksum.SHA256 + ":17 sha512:" + asset.Checksum.SHA512 + ":17"
+	if got := lockFile.Dependencies["example_txt"]["docs/example-1.0.0.txt"]; got != expected {
+		t.Fatalf("Expected both checksums to be locked, got %q", got)
+	}
+
+	rootCmd = buildRootCommand()
+	rootCmd.SetArgs([]string{"deps", "sync", "--url", mockServer.URL})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("deps sync failed: %v", err)
+	}
+
+	lockFileContent := `[example_txt]
+docs/example-1.0.0.txt = sha256:` + asset.Checksum.SHA256 + ` sha512:` + strings.Repeat("0", 128) + `
 `
 	if err := os.WriteFile("deps-lock.ini", []byte(lockFileContent), 0644); err != nil {
 		t.Fatal(err)
 	}
-
-	rootCmd := buildRootCommand()
-	rootCmd.SetArgs([]string{"deps", "sync"})
+	rootCmd = buildRootCommand()
+	rootCmd.SetArgs([]string{"deps", "sync", "--url", mockServer.URL})
 	err = rootCmd.Execute()
-	if err == nil {
-		t.Fatal("expected deps sync to fail with missing lock entry, but it succeeded")
+	if err == nil || !strings.Contains(err.Error(), "sha512 checksum mismatch") {
+		t.Fatalf("Expected deps sync to fail on the sha512 checksum, got %v", err)
 	}
 }
 
-func TestDepsLockCommandWithSingleFile(t *testing.T) {
+// TestDepsSyncMissingUpstream tests that locked files deleted from Nexus are reported before
+// anything is downloaded, and only warned about with --allow-missing
+func TestDepsSyncMissingUpstream(t *testing.T) {
 	mockServer := nexusapi.NewMockNexusServer()
 	defer mockServer.Close()
+	for _, name := range []string{"a", "b", "c"} {
+		mockServer.AddAsset("libs", "/docs/"+name+".txt", nexusapi.Asset{}, []byte("locked "+name))
+	}
 
-	testChecksum := "abc123def456"
-
-	mockServer.AddAsset("builds", "/test3/file1.out", nexusapi.Asset{
-		Checksum: nexusapi.Checksum{
-			SHA256: testChecksum,
-		},
-	}, nil)
-
-	tmpDir := t.TempDir()
 	oldDir, err := os.Getwd()
 	if err != nil {
 		t.Fatal(err)
 	}
 	defer os.Chdir(oldDir)
-
-	if err := os.Chdir(tmpDir); err != nil {
+	if err := os.Chdir(t.TempDir()); err != nil {
 		t.Fatal(err)
 	}
 
 	depsIniContent := `[defaults]
-repository = builds
+repository = libs
 checksum = sha256
 output_dir = ./local
 
-[example]
-path = test3/file1.out
+[docs]
+path = docs
+recursive = true
 `
 	if err := os.WriteFile("deps.ini", []byte(depsIniContent), 0644); err != nil {
 		t.Fatal(err)
 	}
-
+	cfg := &config.Config{NexusURL: mockServer.URL, Username: "test", Password: "test"}
 	rootCmd := buildRootCommand()
 	rootCmd.SetArgs([]string{"deps", "lock", "--url", mockServer.URL})
 	if err := rootCmd.Execute(); err != nil {
 		t.Fatalf("deps lock failed: %v", err)
 	}
+	if err := depsSyncMain(cfg, nil, &DepsSyncOptions{Logger: util.NewLogger(io.Discard), CleanupUntracked: true}); err != nil {
+		t.Fatalf("deps sync failed: %v", err)
+	}
 
-	if _, err := os.Stat("deps-lock.ini"); os.IsNotExist(err) {
-		t.Error("deps-lock.ini was not created")
+	// b and c are deleted from Nexus; only b is still present locally
+	delete(mockServer.Assets, "libs:/docs/b.txt")
+	delete(mockServer.Assets, "libs:/docs/c.txt")
+	for _, name := range []string{"a", "c"} {
+		if err := os.Remove(filepath.Join("local", "docs", name+".txt")); err != nil {
+			t.Fatal(err)
+		}
 	}
 
-	content, err := os.ReadFile("deps-lock.ini")
-	if err != nil {
-		t.Fatal(err)
+	err = depsSyncMain(cfg, nil, &DepsSyncOptions{Logger: util.NewLogger(io.Discard), CleanupUntracked: true})
+	var syncErr *syncError
+	if !errors.As(err, &syncErr) || syncErr.phase != syncPhaseResolve || syncErr.status != exitcode.Missing {
+		t.Fatalf("Expected a resolve failure with exit code %d, got %v", exitcode.Missing, err)
+	}
+	if !strings.Contains(err.Error(), "locked files no longer exist upstream: docs/b.txt, docs/c.txt; re-run deps lock") {
+		t.Errorf("Expected the error to name the missing files, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join("local", "docs", "a.txt")); !os.IsNotExist(err) {
+		t.Errorf("Expected nothing to be downloaded, got %v", err)
 	}
 
-	contentStr := string(content)
-	if !strings.Contains(contentStr, "[example]") {
-		t.Error("deps-lock.ini missing [example] section")
+	// With --allow-missing the rest is synced and the local copy of b is kept
+	var buf strings.Builder
+	if err := depsSyncMain(cfg, nil, &DepsSyncOptions{Logger: util.NewLogger(&buf), CleanupUntracked: true, AllowMissing: true}); err != nil {
+		t.Fatalf("Expected deps sync to succeed with --allow-missing, got %v", err)
 	}
-	if !strings.Contains(contentStr, "test3/file1.out") {
-		t.Error("deps-lock.ini missing test3/file1.out entry")
+	if !strings.Contains(buf.String(), "Warning: locked files no longer exist upstream: docs/b.txt, docs/c.txt; re-run deps lock") {
+		t.Errorf("Expected a warning naming the missing files, got:\n%s", buf.String())
 	}
-	if !strings.Contains(contentStr, testChecksum) {
-		t.Errorf("deps-lock.ini missing expected checksum %s", testChecksum)
+	for name, expected := range map[string]string{"a": "locked a", "b": "locked b"} {
+		if content, _ := os.ReadFile(filepath.Join("local", "docs", name+".txt")); string(content) != expected {
+			t.Errorf("Expected %s.txt to hold %q, got %q", name, expected, content)
+		}
 	}
 }
 
-func TestDepsSyncCleanupUntracked(t *testing.T) {
+// TestDepsSyncGroupRepository tests that the locked files of a dependency on a group
+// repository are found in its members, not reported missing upstream
+func TestDepsSyncGroupRepository(t *testing.T) {
 	mockServer := nexusapi.NewMockNexusServer()
 	defer mockServer.Close()
+	mockServer.RepositoryStatuses = []nexusapi.RepositoryStatus{
+		{Name: "libs-hosted", Format: "raw", Type: "hosted", Online: true},
+		{Name: "libs", Format: "raw", Type: "group", Online: true, Group: &nexusapi.RepositoryGroup{MemberNames: []string{"libs-hosted"}}},
+	}
+	mockServer.AddAsset("libs-hosted", "/docs/a.txt", nexusapi.Asset{}, []byte("locked a"))
 
-	testFileContent := []byte("test file content for sync")
-
-	// Add asset without explicitly setting Path to mimic real Nexus behavior
-	// which returns asset paths with leading slashes
-	mockServer.AddAsset("libs", "/docs/example-1.0.0.txt", nexusapi.Asset{}, testFileContent)
-
-	tmpDir := t.TempDir()
 	oldDir, err := os.Getwd()
 	if err != nil {
 		t.Fatal(err)
 	}
 	defer os.Chdir(oldDir)
-
-	if err := os.Chdir(tmpDir); err != nil {
+	if err := os.Chdir(t.TempDir()); err != nil {
 		t.Fatal(err)
 	}
-
 	depsIniContent := `[defaults]
 repository = libs
 checksum = sha256
 output_dir = ./local
 
-[example_txt]
+[docs]
+path = docs
+recursive = true
+`
+	if err := os.WriteFile("deps.ini", []byte(depsIniContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{NexusURL: mockServer.URL, Username: "test", Password: "test"}
+	if err := depsLockMain(cfg, nil, util.NewLogger(io.Discard), false); err != nil {
+		t.Fatalf("deps lock failed: %v", err)
+	}
+	if err := depsSyncMain(cfg, nil, &DepsSyncOptions{Logger: util.NewLogger(io.Discard), CleanupUntracked: true}); err != nil {
+		t.Fatalf("deps sync failed: %v", err)
+	}
+	if content, _ := os.ReadFile(filepath.Join("local", "docs", "a.txt")); string(content) != "locked a" {
+		t.Errorf("Expected a.txt to be synced from the group member, got %q", content)
+	}
+}
+
+// TestDepsSyncUpstreamDrift tests that deps sync compares local files against
+// deps-lock.ini rather than against Nexus, so an asset that changed in Nexus neither
+// replaces a good local copy nor ends up on disk
+func TestDepsSyncUpstreamDrift(t *testing.T) {
+	mockServer := nexusapi.NewMockNexusServer()
+	defer mockServer.Close()
+	mockServer.AddAsset("libs", "/docs/a.txt", nexusapi.Asset{}, []byte("locked a"))
+	mockServer.AddAsset("libs", "/docs/b.txt", nexusapi.Asset{}, []byte("locked b"))
+
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(oldDir)
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+
+	depsIniContent := `[defaults]
+repository = libs
+checksum = sha256
+output_dir = ./local
+
+[docs]
+path = docs
+recursive = true
+`
+	if err := os.WriteFile("deps.ini", []byte(depsIniContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{NexusURL: mockServer.URL, Username: "test", Password: "test"}
+	rootCmd := buildRootCommand()
+	rootCmd.SetArgs([]string{"deps", "lock", "--url", mockServer.URL})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("deps lock failed: %v", err)
+	}
+	if err := depsSyncMain(cfg, nil, &DepsSyncOptions{Logger: util.NewLogger(io.Discard), CleanupUntracked: true}); err != nil {
+		t.Fatalf("deps sync failed: %v", err)
+	}
+
+	// The asset changes in Nexus without the lock file being updated
+	mockServer.AddAsset("libs", "/docs/a.txt", nexusapi.Asset{}, []byte("drifted a"))
+	localA := filepath.Join("local", "docs", "a.txt")
+
+	// A local copy that matches the lock file is kept without downloading anything
+	downloads := mockServer.GetDownloadCount()
+	if err := depsSyncMain(cfg, nil, &DepsSyncOptions{Logger: util.NewLogger(io.Discard), CleanupUntracked: true}); err != nil {
+		t.Fatalf("Expected deps sync to keep the locked copy, got %v", err)
+	}
+	if got := mockServer.GetDownloadCount(); got != downloads {
+		t.Errorf("Expected no downloads for files matching deps-lock.ini, got %d", got-downloads)
+	}
+	if content, _ := os.ReadFile(localA); string(content) != "locked a" {
+		t.Errorf("Expected the locked content to be kept, got %q", content)
+	}
+
+	// A file that has to be downloaded again fails without touching the local tree
+	if err := os.WriteFile(localA, []byte("edited locally"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join("local", "docs", "b.txt")); err != nil {
+		t.Fatal(err)
+	}
+	err = depsSyncMain(cfg, nil, &DepsSyncOptions{Logger: util.NewLogger(io.Discard), CleanupUntracked: true})
+	var syncErr *syncError
+	if !errors.As(err, &syncErr) || syncErr.phase != syncPhaseVerify {
+		t.Fatalf("Expected a verify failure, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "docs/a.txt (size mismatch: expected 8 bytes, got 9)") || strings.Contains(err.Error(), "b.txt") {
+		t.Errorf("Expected the error to name only the drifted file, got %v", err)
+	}
+	if content, _ := os.ReadFile(localA); string(content) != "edited locally" {
+		t.Errorf("Expected the local file to be left untouched, got %q", content)
+	}
+	if content, _ := os.ReadFile(filepath.Join("local", "docs", "b.txt")); string(content) != "locked b" {
+		t.Errorf("Expected the unchanged file to be restored, got %q", content)
+	}
+	entries, err := os.ReadDir(filepath.Join("local", "docs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("Expected no partial downloads to be left behind, got %v", entries)
+	}
+}
+
+// TestDepsSyncDryRun tests that deps sync --dry-run plans the files that do not match
+// deps-lock.ini and the untracked files, without changing anything
+func TestDepsSyncDryRun(t *testing.T) {
+	mockServer := nexusapi.NewMockNexusServer()
+	defer mockServer.Close()
+	mockServer.AddAsset("libs", "/docs/a.txt", nexusapi.Asset{}, []byte("locked a"))
+	mockServer.AddAsset("libs", "/docs/b.txt", nexusapi.Asset{}, []byte("locked b"))
+
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(oldDir)
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+
+	depsIniContent := `[defaults]
+repository = libs
+checksum = sha256
+output_dir = ./local
+
+[docs]
+path = docs
+recursive = true
+`
+	if err := os.WriteFile("deps.ini", []byte(depsIniContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+	rootCmd := buildRootCommand()
+	rootCmd.SetArgs([]string{"deps", "lock", "--url", mockServer.URL})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("deps lock failed: %v", err)
+	}
+
+	docsDir := filepath.Join("local", "docs")
+	if err := os.MkdirAll(docsDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(docsDir, "a.txt"), []byte("locked a"), 0644)
+	os.WriteFile(filepath.Join(docsDir, "stale.txt"), []byte("stale"), 0644)
+
+	cfg := &config.Config{NexusURL: mockServer.URL, Username: "test", Password: "test"}
+	plan := operations.NewPlan("deps sync")
+	if err := depsSyncMain(cfg, nil, &DepsSyncOptions{Logger: util.NewLogger(io.Discard), QuietMode: true, CleanupUntracked: true, Plan: plan}); err != nil {
+		t.Fatalf("deps sync --dry-run failed: %v", err)
+	}
+
+	want := []operations.Action{
+		{Type: operations.ActionDownload, Source: "libs/docs/b.txt", Target: filepath.Join(docsDir, "b.txt"), Size: 8},
+		{Type: operations.ActionDeleteLocal, Target: filepath.Join("local", "docs", "stale.txt")},
+	}
+	actions := plan.Actions()
+	if len(actions) != len(want) {
+		t.Fatalf("Expected %d actions, got %v", len(want), actions)
+	}
+	for i := range want {
+		if actions[i] != want[i] {
+			t.Errorf("Expected action %d to be %+v, got %+v", i, want[i], actions[i])
+		}
+	}
+	if mockServer.GetDownloadCount() != 0 {
+		t.Error("Expected the dry run not to download anything")
+	}
+	entries, _ := os.ReadDir(docsDir)
+	if len(entries) != 2 {
+		t.Errorf("Expected the dry run to leave the output directory untouched, got %v", entries)
+	}
+}
+
+// TestDepsFileDependency tests that a dependency with file = true locks and syncs exactly
+// one asset, without its siblings
+func TestDepsFileDependency(t *testing.T) {
+	mockServer := nexusapi.NewMockNexusServer()
+	defer mockServer.Close()
+	mockServer.AddAsset("libs", "/bin/tool", nexusapi.Asset{}, []byte("tool binary"))
+	mockServer.AddAsset("libs", "/bin/tool-debug", nexusapi.Asset{}, []byte("debug binary"))
+
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(oldDir)
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+
+	depsIniContent := `[defaults]
+repository = libs
+checksum = sha256
+output_dir = ./local
+
+[tool]
+path = bin/tool
+file = true
+`
+	if err := os.WriteFile("deps.ini", []byte(depsIniContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	rootCmd := buildRootCommand()
+	rootCmd.SetArgs([]string{"deps", "lock", "--url", mockServer.URL})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("deps lock failed: %v", err)
+	}
+	lockFile, err := deps.ParseLockFile("deps-lock.ini")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if files := lockFile.Dependencies["tool"]; len(files) != 1 || files["bin/tool"] == "" {
+		t.Fatalf("Expected only bin/tool to be locked, got %v", files)
+	}
+
+	rootCmd = buildRootCommand()
+	rootCmd.SetArgs([]string{"deps", "sync", "--url", mockServer.URL})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("deps sync failed: %v", err)
+	}
+	if data, err := os.ReadFile("local/bin/tool"); err != nil || string(data) != "tool binary" {
+		t.Errorf("Expected the tool to be synced, got %q, %v", data, err)
+	}
+	if _, err := os.Stat("local/bin/tool-debug"); !os.IsNotExist(err) {
+		t.Error("Expected the sibling not to be synced")
+	}
+}
+
+func TestDepsSyncMissingLockEntry(t *testing.T) {
+	tmpDir := t.TempDir()
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(oldDir)
+
+	if err := os.Chdir(tmpDir); err != nil {
+		t.Fatal(err)
+	}
+
+	depsIniContent := `[defaults]
+repository = libs
+checksum = sha256
+output_dir = ./local
+
+[example_txt]
+path = docs/example-${version}.txt
+version = 1.0.0
+`
+	if err := os.WriteFile("deps.ini", []byte(depsIniContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	lockFileContent := `[other_dependency]
+other/file.txt = sha256:abcd1234
+`
+	if err := os.WriteFile("deps-lock.ini", []byte(lockFileContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	rootCmd := buildRootCommand()
+	rootCmd.SetArgs([]string{"deps", "sync"})
+	err = rootCmd.Execute()
+	if err == nil {
+		t.Fatal("expected deps sync to fail with missing lock entry, but it succeeded")
+	}
+}
+
+func TestDepsLockCommandWithSingleFile(t *testing.T) {
+	mockServer := nexusapi.NewMockNexusServer()
+	defer mockServer.Close()
+
+	testChecksum := "abc123def456"
+
+	mockServer.AddAsset("builds", "/test3/file1.out", nexusapi.Asset{
+		Checksum: nexusapi.Checksum{
+			SHA256: testChecksum,
+		},
+	}, nil)
+
+	tmpDir := t.TempDir()
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(oldDir)
+
+	if err := os.Chdir(tmpDir); err != nil {
+		t.Fatal(err)
+	}
+
+	depsIniContent := `[defaults]
+repository = builds
+checksum = sha256
+output_dir = ./local
+
+[example]
+path = test3/file1.out
+`
+	if err := os.WriteFile("deps.ini", []byte(depsIniContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	rootCmd := buildRootCommand()
+	rootCmd.SetArgs([]string{"deps", "lock", "--url", mockServer.URL})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("deps lock failed: %v", err)
+	}
+
+	if _, err := os.Stat("deps-lock.ini"); os.IsNotExist(err) {
+		t.Error("deps-lock.ini was not created")
+	}
+
+	content, err := os.ReadFile("deps-lock.ini")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contentStr := string(content)
+	if !strings.Contains(contentStr, "[example]") {
+		t.Error("deps-lock.ini missing [example] section")
+	}
+	if !strings.Contains(contentStr, "test3/file1.out") {
+		t.Error("deps-lock.ini missing test3/file1.out entry")
+	}
+	if !strings.Contains(contentStr, testChecksum) {
+		t.Errorf("deps-lock.ini missing expected checksum %s", testChecksum)
+	}
+}
+
+// TestDepsLockPerHostConnection tests that every dependency connects with the credentials
+// and TLS settings of its own server
+func TestDepsLockPerHostConnection(t *testing.T) {
+	internal := nexusapi.NewMockNexusTLSServer()
+	defer internal.Close()
+	internal.AddAsset("builds", "/tool/tool.bin", nexusapi.Asset{Checksum: nexusapi.Checksum{SHA256: "aaa111"}}, nil)
+	partner := nexusapi.NewMockNexusServer()
+	defer partner.Close()
+	partner.AddAsset("builds", "/sdk/sdk.zip", nexusapi.Asset{Checksum: nexusapi.Checksum{SHA256: "bbb222"}}, nil)
+
+	configDir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configDir)
+	store := &config.CredentialStore{Path: filepath.Join(configDir, "nexuscli", "credentials")}
+	if err := store.Set(partner.URL, config.Credentials{Username: "partner", Password: "partner-token"}); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("INTERNAL_TOKEN", "internal-token")
+	settingsFile := filepath.Join(configDir, "nexuscli", "config")
+	if err := os.WriteFile(settingsFile, []byte("[host "+internal.URL+"]\ntoken-env = INTERNAL_TOKEN\nheader.X-Team = platform\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(oldDir)
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	certificate := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: internal.Certificate().Raw})
+	if err := os.WriteFile("internal-ca.pem", certificate, 0644); err != nil {
+		t.Fatal(err)
+	}
+	depsIniContent := `[defaults]
+repository = builds
+checksum = sha256
+output_dir = ./local
+
+[tool]
+path = tool/tool.bin
+url = ` + internal.URL + `
+ca_cert = internal-ca.pem
+
+[sdk]
+path = sdk/sdk.zip
+url = ` + partner.URL + `
+`
+	if err := os.WriteFile("deps.ini", []byte(depsIniContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf strings.Builder
+	cfg := &config.Config{NexusURL: "http://localhost:8081", Username: "ci", Password: "ci-secret"}
+	settings, err := config.LoadSettings(settingsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := depsLockMain(cfg, settings, util.NewVerboseLogger(&buf), false); err != nil {
+		t.Fatalf("deps lock failed: %v\n%s", err, buf.String())
+	}
+	content, err := os.ReadFile("deps-lock.ini")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), "aaa111") || !strings.Contains(string(content), "bbb222") {
+		t.Errorf("Expected both dependencies to be locked, got:\n%s", content)
+	}
+
+	output := buf.String()
+	for _, expected := range []string{
+		"TLS:         CA internal-ca.pem and system CAs (file deps.ini)",
+		`Credentials: user "partner", password ******** (file ` + store.Path + ")",
+		"Credentials: token ******** (env INTERNAL_TOKEN)",
+		"Headers:     X-Team (file " + settingsFile + ")",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("Expected %q in the verbose output, got:\n%s", expected, output)
+		}
+	}
+	for _, secret := range []string{"partner-token", "ci-secret", "internal-token", "platform"} {
+		if strings.Contains(output, secret) {
+			t.Errorf("Expected the secret %q to be redacted, got:\n%s", secret, output)
+		}
+	}
+
+	// Each server only gets its own credentials, never those of cfg
+	if header := internal.GetLastHeader(); header.Get("Authorization") != "Bearer internal-token" || header.Get("X-Team") != "platform" {
+		t.Errorf("Expected the token and headers of the host section, got %v", header)
+	}
+	if username, password, ok := (&http.Request{Header: partner.GetLastHeader()}).BasicAuth(); !ok || username != "partner" || password != "partner-token" {
+		t.Errorf("Expected the stored credentials of the partner server, got %s/%s", username, password)
+	}
+}
+
+// TestDepsLockDefaultsURLCredentials checks that the [defaults] url of deps.ini gets the
+// credentials of the run from NEXUS_USER/NEXUS_PASS when NEXUS_URL is not set, while
+// another server without credentials of its own is still accessed anonymously
+func TestDepsLockDefaultsURLCredentials(t *testing.T) {
+	project := nexusapi.NewMockNexusServer()
+	defer project.Close()
+	project.AddAsset("builds", "/lib/lib.zip", nexusapi.Asset{Checksum: nexusapi.Checksum{SHA256: "aaa111"}}, nil)
+	public := nexusapi.NewMockNexusServer()
+	defer public.Close()
+	public.AddAsset("builds", "/sdk/sdk.zip", nexusapi.Asset{Checksum: nexusapi.Checksum{SHA256: "bbb222"}}, nil)
+
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("NEXUS_URL", "")
+	t.Setenv("NEXUS_USER", "ci")
+	t.Setenv("NEXUS_PASS", "ci-secret")
+
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(oldDir)
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	depsIniContent := `[defaults]
+url = ` + project.URL + `
+repository = builds
+checksum = sha256
+output_dir = ./local
+
+[lib]
+path = lib/lib.zip
+
+[sdk]
+path = sdk/sdk.zip
+url = ` + public.URL + `
+`
+	if err := os.WriteFile("deps.ini", []byte(depsIniContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf strings.Builder
+	if err := depsLockMain(config.NewConfig(), nil, util.NewVerboseLogger(&buf), false); err != nil {
+		t.Fatalf("deps lock failed: %v\n%s", err, buf.String())
+	}
+
+	output := buf.String()
+	if expected := `Credentials: user "ci", password ******** (env NEXUS_PASS)`; !strings.Contains(output, expected) {
+		t.Errorf("Expected %q in the verbose output, got:\n%s", expected, output)
+	}
+	if strings.Contains(output, "ci-secret") {
+		t.Errorf("Expected the password to be redacted, got:\n%s", output)
+	}
+	if username, password, ok := (&http.Request{Header: project.GetLastHeader()}).BasicAuth(); !ok || username != "ci" || password != "ci-secret" {
+		t.Errorf("Expected the credentials of the run for the [defaults] url, got %s/%s", username, password)
+	}
+	if header := public.GetLastHeader(); header.Get("Authorization") != "" {
+		t.Errorf("Expected no credentials for a server without its own, got %q", header.Get("Authorization"))
+	}
+}
+
+// setupFrozenLockTest writes deps.ini and an up-to-date deps-lock.ini for a single asset
+func setupFrozenLockTest(t *testing.T) *nexusapi.MockNexusServer {
+	t.Helper()
+	mockServer := nexusapi.NewMockNexusServer()
+	t.Cleanup(mockServer.Close)
+	mockServer.AddAsset("builds", "/test3/file1.out", nexusapi.Asset{
+		Checksum: nexusapi.Checksum{SHA256: "abc123def456"},
+	}, nil)
+
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(oldDir) })
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+
+	depsIniContent := `[defaults]
+repository = builds
+checksum = sha256
+output_dir = ./local
+
+[example]
+path = test3/file1.out
+`
+	if err := os.WriteFile("deps.ini", []byte(depsIniContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+	rootCmd := buildRootCommand()
+	rootCmd.SetArgs([]string{"deps", "lock", "--url", mockServer.URL, "--quiet"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("deps lock failed: %v", err)
+	}
+	return mockServer
+}
+
+func TestDepsLockFrozenInSync(t *testing.T) {
+	mockServer := setupFrozenLockTest(t)
+	before, err := os.ReadFile("deps-lock.ini")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rootCmd := buildRootCommand()
+	rootCmd.SetArgs([]string{"deps", "lock", "--frozen", "--url", mockServer.URL, "--quiet"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("Expected frozen lock to succeed for an up-to-date lock file: %v", err)
+	}
+
+	after, err := os.ReadFile("deps-lock.ini")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(after) != string(before) {
+		t.Error("Expected --frozen to leave deps-lock.ini untouched")
+	}
+}
+
+func TestDepsLockFrozenOutOfSync(t *testing.T) {
+	mockServer := setupFrozenLockTest(t)
+	mockServer.AddAsset("builds", "/test3/file1.out", nexusapi.Asset{
+		Checksum: nexusapi.Checksum{SHA256: "fff999"},
+	}, nil)
+	before, err := os.ReadFile("deps-lock.ini")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rootCmd := buildRootCommand()
+	rootCmd.SetArgs([]string{"deps", "lock", "--check", "--url", mockServer.URL, "--quiet"})
+	rootCmd.SilenceUsage = true
+
+	oldStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+	err = rootCmd.Execute()
+	w.Close()
+	os.Stdout = oldStdout
+	output, _ := io.ReadAll(r)
+
+	if err == nil || !strings.Contains(err.Error(), "out of date") {
+		t.Fatalf("Expected out of date error, got %v", err)
+	}
+	outputStr := string(output)
+	if !strings.Contains(outputStr, "- [example] test3/file1.out = sha256:abc123def456") ||
+		!strings.Contains(outputStr, "+ [example] test3/file1.out = sha256:fff999") {
+		t.Errorf("Expected diff in output, got: %s", outputStr)
+	}
+
+	after, err := os.ReadFile("deps-lock.ini")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(after) != string(before) {
+		t.Error("Expected --frozen to leave deps-lock.ini untouched")
+	}
+}
+
+func TestDepsSyncCleanupUntracked(t *testing.T) {
+	mockServer := nexusapi.NewMockNexusServer()
+	defer mockServer.Close()
+
+	testFileContent := []byte("test file content for sync")
+
+	// Add asset without explicitly setting Path to mimic real Nexus behavior
+	// which returns asset paths with leading slashes
+	mockServer.AddAsset("libs", "/docs/example-1.0.0.txt", nexusapi.Asset{}, testFileContent)
+
+	tmpDir := t.TempDir()
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(oldDir)
+
+	if err := os.Chdir(tmpDir); err != nil {
+		t.Fatal(err)
+	}
+
+	depsIniContent := `[defaults]
+repository = libs
+checksum = sha256
+output_dir = ./local
+
+[example_txt]
 path = docs/example-${version}.txt
 version = 1.0.0
 `
@@ -904,3 +1878,261 @@ docs/example-1.0.0.txt = sha256:` + testChecksum + `
 		t.Errorf("file content mismatch: expected %s, got %s", testFileContent, content)
 	}
 }
+
+func TestDependencyDownloadOptions(t *testing.T) {
+	tmpDir := t.TempDir()
+	depsIni := filepath.Join(tmpDir, "deps.ini")
+	depsIniContent := `[defaults]
+repository = libs
+checksum = sha256
+output_dir = ./local
+concurrency = 2
+
+[huge_sdk]
+path = sdk/
+recursive = true
+concurrency = 8
+
+[throttled_tool]
+path = tools/tool.bin
+checksum = sha512
+max_rate = 1M
+`
+	if err := os.WriteFile(depsIni, []byte(depsIniContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	manifest, err := deps.ParseDepsIni(depsIni)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger := util.NewLogger(io.Discard)
+
+	sdkOpts, err := newDependencyDownloadOptions(manifest.Dependencies["huge_sdk"], logger, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sdkOpts.Concurrency != 8 || sdkOpts.MaxRate != 0 || !sdkOpts.Recursive {
+		t.Errorf("unexpected options for huge_sdk: concurrency=%d max_rate=%d recursive=%v", sdkOpts.Concurrency, sdkOpts.MaxRate, sdkOpts.Recursive)
+	}
+
+	toolOpts, err := newDependencyDownloadOptions(manifest.Dependencies["throttled_tool"], logger, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if toolOpts.Concurrency != 2 || toolOpts.MaxRate != 1024*1024 {
+		t.Errorf("unexpected options for throttled_tool: concurrency=%d max_rate=%d", toolOpts.Concurrency, toolOpts.MaxRate)
+	}
+	if toolOpts.ChecksumAlgorithm != "sha512" {
+		t.Errorf("expected sha512 checksum for throttled_tool, got %s", toolOpts.ChecksumAlgorithm)
+	}
+}
+
+func TestDepsSyncOnConflictBackup(t *testing.T) {
+	mockServer := nexusapi.NewMockNexusServer()
+	defer mockServer.Close()
+
+	testFileContent := []byte("test file content for sync")
+	testChecksum := "0505007cc25ef733fb754c26db7dd8c38c5cf8f75f571f60a66548212c25b2fa"
+
+	mockServer.AddAsset("libs", "/docs/example-1.0.0.txt", nexusapi.Asset{}, testFileContent)
+
+	tmpDir := t.TempDir()
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(oldDir)
+
+	if err := os.Chdir(tmpDir); err != nil {
+		t.Fatal(err)
+	}
+
+	depsIniContent := `[defaults]
+repository = libs
+checksum = sha256
+output_dir = ./local
+
+[example_txt]
+path = docs/example-${version}.txt
+version = 1.0.0
+`
+	if err := os.WriteFile("deps.ini", []byte(depsIniContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	lockFileContent := `[example_txt]
+docs/example-1.0.0.txt = sha256:` + testChecksum + `
+`
+	if err := os.WriteFile("deps-lock.ini", []byte(lockFileContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	localFile := filepath.Join("local", "docs", "example-1.0.0.txt")
+	if err := os.MkdirAll(filepath.Dir(localFile), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(localFile, []byte("local debugging edits"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	rootCmd := buildRootCommand()
+	rootCmd.SetArgs([]string{"deps", "sync", "--url", mockServer.URL, "--on-conflict", "backup"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("deps sync failed: %v", err)
+	}
+
+	content, err := os.ReadFile(localFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != string(testFileContent) {
+		t.Errorf("expected synced content, got %s", content)
+	}
+
+	backups, _ := filepath.Glob(localFile + ".bak.*")
+	if len(backups) != 1 {
+		t.Fatalf("expected backup to survive cleanup of untracked files, got %v", backups)
+	}
+	backupContent, _ := os.ReadFile(backups[0])
+	if string(backupContent) != "local debugging edits" {
+		t.Errorf("expected backup to hold local edits, got %s", backupContent)
+	}
+}
+
+func TestDepsSyncInvalidOnConflict(t *testing.T) {
+	rootCmd := buildRootCommand()
+	rootCmd.SetArgs([]string{"deps", "sync", "--on-conflict", "merge"})
+	rootCmd.SilenceUsage = true
+	rootCmd.SilenceErrors = true
+	err := rootCmd.Execute()
+	if err == nil || !strings.Contains(err.Error(), "unsupported conflict policy 'merge'") {
+		t.Errorf("expected unsupported conflict policy error, got: %v", err)
+	}
+}
+
+func TestDepsSyncMaxTotalSize(t *testing.T) {
+	mockServer := nexusapi.NewMockNexusServer()
+	defer mockServer.Close()
+	mockServer.AddAsset("libs", "/docs/example-1.0.0.txt", nexusapi.Asset{}, []byte(strings.Repeat("x", 2000)))
+	asset := mockServer.Assets["libs:/docs/example-1.0.0.txt"]
+
+	tmpDir := t.TempDir()
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(oldDir)
+	if err := os.Chdir(tmpDir); err != nil {
+		t.Fatal(err)
+	}
+
+	depsIniContent := `[defaults]
+repository = libs
+checksum = sha256
+output_dir = ./local
+max_size = 1K
+
+[example_txt]
+path = docs/example-${version}.txt
+version = 1.0.0
+`
+	if err := os.WriteFile("deps.ini", []byte(depsIniContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll("local/docs", 0755); err != nil {
+		t.Fatal(err)
+	}
+	untrackedFile := filepath.Join("local", "docs", "untracked.txt")
+	if err := os.WriteFile(untrackedFile, []byte("untracked"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	downloadedFile := filepath.Join("local", "docs", "example-1.0.0.txt")
+
+	// The size comes from the lock file if it records sizes, and from Nexus otherwise
+	for _, locked := range []string{"sha256:" + asset.Checksum.SHA256 + ":2000", "sha256:" + asset.Checksum.SHA256} {
+		lockContent := "[example_txt]\ndocs/example-1.0.0.txt = " + locked + "\n"
+		if err := os.WriteFile("deps-lock.ini", []byte(lockContent), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		err := depsSyncMain(&config.Config{NexusURL: mockServer.URL}, nil, &DepsSyncOptions{Logger: util.NewLogger(io.Discard), QuietMode: true, CleanupUntracked: true})
+		if err == nil || !strings.Contains(err.Error(), "more than the maximum of 1.0 KiB") || !strings.Contains(err.Error(), "example_txt") {
+			t.Errorf("%s: expected the budget to be exceeded, got %v", locked, err)
+		}
+		if _, err := os.Stat(downloadedFile); err == nil {
+			t.Errorf("%s: nothing should be downloaded over budget", locked)
+		}
+		if _, err := os.Stat(untrackedFile); err != nil {
+			t.Errorf("%s: nothing should be cleaned up over budget", locked)
+		}
+	}
+
+	// --max-total-size overrides max_size in deps.ini
+	if err := depsSyncMain(&config.Config{NexusURL: mockServer.URL}, nil, &DepsSyncOptions{Logger: util.NewLogger(io.Discard), QuietMode: true, MaxTotalSize: 4096}); err != nil {
+		t.Fatalf("Expected the sync to fit in 4K, got %v", err)
+	}
+	if _, err := os.Stat(downloadedFile); err != nil {
+		t.Errorf("Expected the dependency to be downloaded: %v", err)
+	}
+}
+
+func TestDepsLatestVersionPinnedByLock(t *testing.T) {
+	mockServer := nexusapi.NewMockNexusServer()
+	defer mockServer.Close()
+	mockServer.AddAsset("libs", "/libfoo/1.9.0/libfoo.jar", nexusapi.Asset{}, []byte("libfoo 1.9.0"))
+	mockServer.AddAsset("libs", "/libfoo/1.10.0/libfoo.jar", nexusapi.Asset{}, []byte("libfoo 1.10.0"))
+	mockServer.AddAsset("libs", "/libfoo/nightly/libfoo.jar", nexusapi.Asset{}, []byte("libfoo nightly"))
+
+	oldDir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(oldDir)
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	depsIniContent := `[defaults]
+repository = libs
+checksum = sha256
+output_dir = ./local
+
+[libfoo]
+path = libfoo/${version}/
+version = {latest:semver}
+recursive = true
+`
+	if err := os.WriteFile("deps.ini", []byte(depsIniContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &config.Config{NexusURL: mockServer.URL, Username: "test", Password: "test"}
+	var buf strings.Builder
+	if err := depsLockMain(cfg, nil, util.NewLogger(&buf), false); err != nil {
+		t.Fatalf("deps lock failed: %v\n%s", err, buf.String())
+	}
+	if !strings.Contains(buf.String(), "Version:    {latest:semver} -> 1.10.0") {
+		t.Errorf("Expected the resolved version to be logged, got:\n%s", buf.String())
+	}
+	content, err := os.ReadFile("deps-lock.ini")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !regexp.MustCompile(`@version\s*= 1\.10\.0`).Match(content) || !strings.Contains(string(content), "libfoo/1.10.0/libfoo.jar") {
+		t.Errorf("Expected the version to be pinned, got:\n%s", content)
+	}
+
+	// A newer version in Nexus does not change what is synced until deps lock is run again
+	mockServer.AddAsset("libs", "/libfoo/1.11.0/libfoo.jar", nexusapi.Asset{}, []byte("libfoo 1.11.0"))
+	if err := depsSyncMain(cfg, nil, &DepsSyncOptions{Logger: util.NewLogger(io.Discard), QuietMode: true, CleanupUntracked: true}); err != nil {
+		t.Fatalf("deps sync failed: %v", err)
+	}
+	if content, err := os.ReadFile(filepath.Join("local", "libfoo", "1.10.0", "libfoo.jar")); err != nil || string(content) != "libfoo 1.10.0" {
+		t.Errorf("Expected the locked version to be synced, got %q, %v", content, err)
+	}
+	err = depsLockMain(cfg, nil, util.NewLogger(io.Discard), true)
+	if err == nil || !strings.Contains(err.Error(), "out of date") {
+		t.Errorf("Expected --frozen to report the newer version, got %v", err)
+	}
+}
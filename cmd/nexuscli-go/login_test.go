@@ -0,0 +1,61 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tympanix/nexus-cli/internal/config"
+	"github.com/tympanix/nexus-cli/internal/nexusapi"
+	"github.com/tympanix/nexus-cli/internal/util"
+)
+
+func TestLoginAndLogout(t *testing.T) {
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+
+	store := &config.CredentialStore{Path: filepath.Join(t.TempDir(), "credentials")}
+	cfg := &config.Config{NexusURL: server.URL}
+
+	// The token is piped on stdin
+	if err := loginMain(cfg, store, "ci", "", strings.NewReader("s3cret-token\n"), util.NewLogger(io.Discard)); err != nil {
+		t.Fatalf("login failed: %v", err)
+	}
+	creds, ok, err := store.Get(server.URL)
+	if err != nil || !ok || creds.Username != "ci" || creds.Password != "s3cret-token" {
+		t.Fatalf("Expected credentials to be stored, got %+v ok=%v err=%v", creds, ok, err)
+	}
+
+	var logBuf strings.Builder
+	if err := logoutMain(cfg, store, util.NewLogger(&logBuf)); err != nil {
+		t.Fatalf("logout failed: %v", err)
+	}
+	if _, ok, _ := store.Get(server.URL); ok {
+		t.Error("Expected credentials to be removed by logout")
+	}
+	if !strings.Contains(logBuf.String(), "Removed credentials for "+server.URL) {
+		t.Errorf("Unexpected logout output: %s", logBuf.String())
+	}
+}
+
+func TestLoginRejectsInvalidCredentials(t *testing.T) {
+	// A raw httptest server is used because the mock server does not check credentials
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	store := &config.CredentialStore{Path: filepath.Join(t.TempDir(), "credentials")}
+	cfg := &config.Config{NexusURL: server.URL}
+
+	err := loginMain(cfg, store, "alice", "wrong", strings.NewReader(""), util.NewLogger(io.Discard))
+	if err == nil || !strings.Contains(err.Error(), "invalid username or password") {
+		t.Fatalf("Expected login to fail with invalid credentials, got %v", err)
+	}
+	if _, ok, _ := store.Get(server.URL); ok {
+		t.Error("Expected rejected credentials not to be stored")
+	}
+}
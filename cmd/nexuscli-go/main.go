@@ -1,63 +1,143 @@
 package main
 
 import (
+	"bufio"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"net/http"
 	"os"
 	"path"
 	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
 	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/tympanix/nexus-cli/internal/archive"
 	"github.com/tympanix/nexus-cli/internal/checksum"
 	"github.com/tympanix/nexus-cli/internal/config"
 	"github.com/tympanix/nexus-cli/internal/deps"
+	"github.com/tympanix/nexus-cli/internal/exitcode"
+	"github.com/tympanix/nexus-cli/internal/metrics"
 	"github.com/tympanix/nexus-cli/internal/nexusapi"
 	"github.com/tympanix/nexus-cli/internal/operations"
 	"github.com/tympanix/nexus-cli/internal/util"
+	"golang.org/x/term"
 )
 
-var version = "dev"
+var (
+	version = "dev"
+	commit  = "none"
+	date    = "unknown"
+)
+
+// buildInfo describes the build of the binary for `version --output json`
+type buildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	Date      string `json:"date"`
+	GoVersion string `json:"go_version"`
+	Platform  string `json:"platform"`
+}
+
+func versionMain(output string) error {
+	switch output {
+	case "", "text":
+		fmt.Printf("nexuscli-go version %s\n", version)
+	case "json":
+		info := buildInfo{
+			Version:   version,
+			Commit:    commit,
+			Date:      date,
+			GoVersion: runtime.Version(),
+			Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+		}
+		data, err := json.MarshalIndent(info, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+	default:
+		return fmt.Errorf("unsupported output format '%s': must be one of: text, json", output)
+	}
+	return nil
+}
+
+// exitCodesTable returns the table of all exit codes for the help of `exit-codes`
+func exitCodesTable() string {
+	var sb strings.Builder
+	exitcode.WriteTable(&sb)
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+func exitCodesMain(output string) error {
+	switch output {
+	case "", "text":
+		return exitcode.WriteTable(os.Stdout)
+	case "json":
+		data, err := json.MarshalIndent(exitcode.Entries(), "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+	default:
+		return fmt.Errorf("unsupported output format '%s': must be one of: text, json", output)
+	}
+	return nil
+}
 
 func depsInitMain() {
 	filename := "deps.ini"
 	if _, err := os.Stat(filename); err == nil {
 		fmt.Printf("Error: %s already exists\n", filename)
-		os.Exit(1)
+		os.Exit(exitcode.Error)
 	}
 	if err := deps.CreateTemplateIni(filename); err != nil {
 		fmt.Printf("Error creating %s: %v\n", filename, err)
-		os.Exit(1)
+		os.Exit(exitcode.Error)
 	}
 	fmt.Printf("Created %s\n", filename)
 }
 
-func depsLockMain(cfg *config.Config, logger util.Logger) {
+// depsLockMain resolves all dependencies and writes deps-lock.ini. With frozen set the
+// lock file is left untouched; instead the resolution is compared against it and an
+// error is returned, after printing the differences, if a re-lock would change anything.
+func depsLockMain(cfg *config.Config, settings *config.Settings, logger util.Logger, frozen bool) error {
 	manifest, err := deps.ParseDepsIni("deps.ini")
 	if err != nil {
-		fmt.Printf("Error parsing deps.ini: %v\n", err)
-		os.Exit(1)
+		return fmt.Errorf("error parsing deps.ini: %w", err)
 	}
 
-	url := cfg.NexusURL
-	if manifest.Defaults.URL != "" {
-		url = manifest.Defaults.URL
+	var currentLock *deps.LockFile
+	if frozen {
+		currentLock, err = deps.ParseLockFile("deps-lock.ini")
+		if err != nil {
+			return fmt.Errorf("error parsing deps-lock.ini: %w", err)
+		}
 	}
 
-	client := nexusapi.NewClient(url, cfg.Username, cfg.Password)
-	resolver := deps.NewResolver(client)
-
 	lockFile := &deps.LockFile{
 		Dependencies: make(map[string]map[string]string),
+		Versions:     make(map[string]string),
 	}
+	configs, err := newDependencyConfigs(cfg, manifest, settings)
+	if err != nil {
+		return err
+	}
+	resolver := configs.resolver()
 
 	logger.Printf("=== Resolving Dependencies ===\n")
 	totalFiles := 0
 	for name, dep := range manifest.Dependencies {
-		depURL := url
-		if dep.URL != "" {
-			depURL = dep.URL
+		depCfg, err := configs.config(dep)
+		if err != nil {
+			return fmt.Errorf("error resolving %s: %w", name, err)
 		}
 		repo := dep.Repository
 		if repo == "" {
@@ -70,32 +150,96 @@ func depsLockMain(cfg *config.Config, logger util.Logger) {
 
 		logger.Printf("\n[%s]\n", name)
 		logger.Printf("  Repository: %s\n", repo)
+		if dep.LatestVersion() {
+			version, err := resolver.ResolveVersion(dep)
+			if err != nil {
+				return fmt.Errorf("error resolving %s: %w", name, err)
+			}
+			logger.Printf("  Version:    %s -> %s\n", dep.Version, version)
+			dep.Version = version
+			lockFile.Versions[name] = version
+		}
 		logger.Printf("  Path:       %s\n", dep.ExpandedPath())
 		logger.Printf("  Checksum:   %s\n", checksumAlg)
-		logger.Printf("  Server:     %s\n", depURL)
+		logger.Printf("  Server:     %s\n", depCfg.NexusURL)
+		logConnection(logger, depCfg)
 
 		files, err := resolver.ResolveDependency(dep)
 		if err != nil {
-			fmt.Printf("\nError resolving %s: %v\n", name, err)
-			os.Exit(1)
+			return fmt.Errorf("error resolving %s: %w", name, err)
 		}
 		lockFile.Dependencies[name] = files
 		totalFiles += len(files)
 		logger.Printf("  ✓ Resolved %d file(s)\n", len(files))
 	}
 
-	if err := deps.WriteLockFile("deps-lock.ini", lockFile); err != nil {
-		fmt.Printf("Error writing deps-lock.ini: %v\n", err)
-		os.Exit(1)
+	lockStatus := "deps-lock.ini"
+	if frozen {
+		diff := deps.DiffLockFiles(currentLock, lockFile)
+		if len(diff) > 0 {
+			fmt.Println("\ndeps-lock.ini is out of date:")
+			for _, line := range diff {
+				fmt.Println(line)
+			}
+			return fmt.Errorf("deps-lock.ini is out of date (%d change(s)); run 'nexuscli-go deps lock' to update it", len(diff))
+		}
+		lockStatus = "deps-lock.ini (up to date)"
+	} else if err := deps.WriteLockFile("deps-lock.ini", lockFile); err != nil {
+		return fmt.Errorf("error writing deps-lock.ini: %w", err)
 	}
 
 	logger.Printf("\n=== Summary ===\n")
 	logger.Printf("Dependencies resolved: %d\n", len(manifest.Dependencies))
 	logger.Printf("Total files: %d\n", totalFiles)
-	logger.Printf("Lock file: deps-lock.ini\n")
+	logger.Printf("Lock file: %s\n", lockStatus)
+	return nil
+}
+
+// Phases of syncing a dependency, named when it fails
+const (
+	syncPhaseResolve  = "resolve"
+	syncPhaseDownload = "download"
+	syncPhaseVerify   = "verify"
+	syncPhaseCleanup  = "cleanup"
+)
+
+// syncError is the failure of a dependency in one phase of deps sync
+type syncError struct {
+	phase  string
+	err    error
+	status int // Exit status of a failed download or of locked files missing upstream, 0 otherwise
+}
+
+func (e *syncError) Error() string {
+	return fmt.Sprintf("%s failed: %v", e.phase, e.err)
+}
+
+func (e *syncError) Unwrap() error {
+	return e.err
 }
 
-func depsSyncMain(cfg *config.Config, logger util.Logger, cleanupUntracked bool, quietMode bool) error {
+// DepsSyncOptions holds options for deps sync
+type DepsSyncOptions struct {
+	Logger           util.Logger
+	QuietMode        bool
+	CleanupUntracked bool                      // Delete the files in the output directories that no dependency tracks
+	OnConflict       operations.ConflictPolicy // How to handle locally modified files (default: overwrite)
+	KeepGoing        bool                      // Sync the remaining dependencies when one fails and report all failures at the end
+	AllowMissing     bool                      // Only warn about locked files that no longer exist in Nexus instead of failing
+	MaxTotalSize     int64                     // Fail before downloading if the dependencies are larger in total, in bytes (0 = max_size of deps.ini)
+	WaitLock         time.Duration             // Wait up to this long for another nexuscli-go process to release an output directory (0 = fail at once)
+	Plan             *operations.Plan          // If set, nothing is changed and the planned actions are added to it
+}
+
+// depsSyncMain downloads every dependency and verifies it against deps-lock.ini. By default
+// it stops at the first failing dependency. With KeepGoing the failures are collected, the
+// remaining dependencies are still synced and a summary of the failures is returned.
+// The error of each failed dependency is a *syncError naming the phase that failed.
+// With Plan set nothing is changed: the files that would be downloaded and the untracked
+// files that would be deleted are added to the plan instead. Locked files that were deleted
+// from Nexus fail their dependency with exitcode.Missing, or only warn with AllowMissing.
+func depsSyncMain(cfg *config.Config, settings *config.Settings, opts *DepsSyncOptions) error {
+	logger, plan := opts.Logger, opts.Plan
 	manifest, err := deps.ParseDepsIni("deps.ini")
 	if err != nil {
 		return fmt.Errorf("error parsing deps.ini: %w", err)
@@ -105,99 +249,111 @@ func depsSyncMain(cfg *config.Config, logger util.Logger, cleanupUntracked bool,
 	if err != nil {
 		return fmt.Errorf("error parsing deps-lock.ini: %w", err)
 	}
+	if err := deps.PinVersions(manifest, lockFile); err != nil {
+		return err
+	}
+	configs, err := newDependencyConfigs(cfg, manifest, settings)
+	if err != nil {
+		return err
+	}
 
-	trackedFilesByOutputDir := make(map[string]map[string]bool)
-
-	logger.Printf("=== Syncing Dependencies ===\n")
-	totalFilesVerified := 0
-	for name, dep := range manifest.Dependencies {
-		lockedFiles, ok := lockFile.Dependencies[name]
-		if !ok {
-			return fmt.Errorf("dependency %s not found in deps-lock.ini", name)
-		}
-
-		depURL := cfg.NexusURL
-		if dep.URL != "" {
-			depURL = dep.URL
-		} else if manifest.Defaults.URL != "" {
-			depURL = manifest.Defaults.URL
-		}
-
-		repo := dep.Repository
-		if repo == "" {
-			repo = manifest.Defaults.Repository
-		}
-		checksumAlg := dep.Checksum
-		if checksumAlg == "" {
-			checksumAlg = manifest.Defaults.Checksum
+	// The budget is checked before anything is downloaded or cleaned up
+	maxTotalSize := opts.MaxTotalSize
+	if maxTotalSize == 0 {
+		maxTotalSize = manifest.Defaults.MaxSize
+	}
+	if maxTotalSize > 0 {
+		if err := checkDependencyBudget(configs, lockFile, maxTotalSize); err != nil {
+			return err
 		}
+	}
 
-		logger.Printf("\n[%s]\n", name)
-		logger.Printf("  Repository: %s\n", repo)
-		logger.Printf("  Path:       %s\n", dep.ExpandedPath())
-		logger.Printf("  Output:     %s\n", dep.OutputDir)
-		logger.Printf("  Files:      %d\n", len(lockedFiles))
-		logger.Printf("  Checksum:   %s\n", checksumAlg)
-
-		downloadOpts := &operations.DownloadOptions{
-			Logger:            logger,
-			QuietMode:         quietMode,
-			ChecksumAlgorithm: dep.Checksum,
-			Recursive:         dep.Recursive,
+	// Every output directory is locked for the whole sync, since untracked files are only
+	// cleaned up after all dependencies are downloaded. They are locked in sorted order,
+	// so two syncs waiting for each other's directories cannot deadlock.
+	if plan == nil {
+		outputDirs := make(map[string]bool)
+		for _, dep := range manifest.Dependencies {
+			outputDirs[dep.OutputDir] = true
 		}
-		if err := downloadOpts.SetChecksumAlgorithm(dep.Checksum); err != nil {
-			return fmt.Errorf("error setting checksum algorithm: %w", err)
+		sorted := make([]string, 0, len(outputDirs))
+		for outputDir := range outputDirs {
+			sorted = append(sorted, outputDir)
 		}
-
-		src := path.Clean(path.Join(dep.Repository, dep.ExpandedPath()))
-		dest := dep.OutputDir
-
-		depCfg := &config.Config{
-			NexusURL: depURL,
-			Username: cfg.Username,
-			Password: cfg.Password,
-		}
-
-		operations.DownloadMain(src, dest, depCfg, downloadOpts)
-
-		for filePath := range lockedFiles {
-			localPath := filepath.Join(dep.OutputDir, filePath)
-			expectedChecksum := lockedFiles[filePath]
-			parts := strings.SplitN(expectedChecksum, ":", 2)
-			if len(parts) != 2 {
-				return fmt.Errorf("invalid checksum format in deps-lock.ini: %s", expectedChecksum)
-			}
-			algorithm := parts[0]
-			expected := parts[1]
-
-			actualChecksum, err := checksum.ComputeChecksum(localPath, algorithm)
+		sort.Strings(sorted)
+		for _, outputDir := range sorted {
+			lock, err := operations.LockDir(outputDir, opts.WaitLock, logger)
 			if err != nil {
-				return fmt.Errorf("error computing checksum for %s: %w", localPath, err)
+				return err
 			}
+			defer lock.Unlock()
+		}
+	}
+
+	trackedFilesByOutputDir := make(map[string]map[string]bool)
+	depsByOutputDir := make(map[string][]string)
+	// Output directories of failed dependencies are never cleaned up, since their files are not tracked
+	failedOutputDirs := make(map[string]bool)
+	var failures []string
 
-			if !strings.EqualFold(actualChecksum, expected) {
-				return fmt.Errorf("checksum mismatch for %s\n  Expected: %s\n  Got: %s", localPath, expected, actualChecksum)
+	logger.Printf("=== Syncing Dependencies ===\n")
+	totalFilesVerified := 0
+	for name, dep := range manifest.Dependencies {
+		lockedFiles, err := syncDependency(configs, lockFile, name, dep, opts)
+		if err != nil {
+			if !opts.KeepGoing {
+				return fmt.Errorf("%s: %w", name, err)
 			}
+			logger.Printf("  ✗ %v\n", err)
+			failures = append(failures, fmt.Sprintf("%s: %v", name, err))
+			failedOutputDirs[dep.OutputDir] = true
+			continue
 		}
 
 		totalFilesVerified += len(lockedFiles)
 
-		if cleanupUntracked {
+		if opts.CleanupUntracked {
 			if trackedFilesByOutputDir[dep.OutputDir] == nil {
 				trackedFilesByOutputDir[dep.OutputDir] = make(map[string]bool)
 			}
 			for filePath := range lockedFiles {
 				trackedFilesByOutputDir[dep.OutputDir][filePath] = true
 			}
+			depsByOutputDir[dep.OutputDir] = append(depsByOutputDir[dep.OutputDir], name)
 		}
 	}
 
-	if cleanupUntracked {
+	if opts.CleanupUntracked {
 		totalDeleted := 0
 		for outputDir, trackedFiles := range trackedFilesByOutputDir {
-			nDeleted := cleanupUntrackedFiles(outputDir, trackedFiles, logger)
-			if nDeleted > 0 {
-				totalDeleted += nDeleted
+			if failedOutputDirs[outputDir] {
+				logger.Printf("\nSkipping cleanup of %s: a dependency in it failed to sync\n", outputDir)
+				continue
+			}
+			if plan != nil {
+				untracked, err := untrackedFiles(outputDir, trackedFiles)
+				if err != nil && !os.IsNotExist(err) {
+					return &syncError{phase: syncPhaseCleanup, err: fmt.Errorf("%s: %w", outputDir, err)}
+				}
+				for _, relPath := range untracked {
+					plan.Add(operations.Action{Type: operations.ActionDeleteLocal, Target: filepath.Join(outputDir, relPath)})
+				}
+				continue
+			}
+			nDeleted, err := cleanupUntrackedFiles(outputDir, trackedFiles, logger)
+			totalDeleted += nDeleted
+			if err == nil {
+				continue
+			}
+			// The files of the dependencies are in place, but their output directory is not clean
+			err = &syncError{phase: syncPhaseCleanup, err: fmt.Errorf("%s: %w", outputDir, err)}
+			names := depsByOutputDir[outputDir]
+			sort.Strings(names)
+			if !opts.KeepGoing {
+				return fmt.Errorf("%s: %w", strings.Join(names, ", "), err)
+			}
+			for _, name := range names {
+				failures = append(failures, fmt.Sprintf("%s: %v", name, err))
 			}
 		}
 		if totalDeleted > 0 {
@@ -206,50 +362,490 @@ func depsSyncMain(cfg *config.Config, logger util.Logger, cleanupUntracked bool,
 	}
 
 	logger.Printf("\n=== Summary ===\n")
-	logger.Printf("Dependencies synced: %d\n", len(manifest.Dependencies))
-	logger.Printf("Total files verified: %d\n", totalFilesVerified)
+	if plan != nil {
+		logger.Printf("Dependencies checked: %d\n", len(manifest.Dependencies)-len(failures))
+		logger.Printf("Planned actions: %d\n", len(plan.Actions()))
+	} else {
+		logger.Printf("Dependencies synced: %d\n", len(manifest.Dependencies)-len(failures))
+		logger.Printf("Total files verified: %d\n", totalFilesVerified)
+	}
+	if len(failures) > 0 {
+		sort.Strings(failures)
+		logger.Printf("Dependencies failed: %d\n", len(failures))
+		for _, failure := range failures {
+			logger.Printf("  ✗ %s\n", failure)
+		}
+		return fmt.Errorf("%d of %d dependencies failed to sync", len(failures), len(manifest.Dependencies))
+	}
+	if plan != nil {
+		logger.Printf("Status: dry run, nothing was changed\n")
+		return nil
+	}
 	logger.Printf("Status: ✓ All checksums valid\n")
 	return nil
 }
 
-func cleanupUntrackedFiles(outputDir string, trackedFiles map[string]bool, logger util.Logger) int {
-	nDeleted := 0
+// syncDependency downloads a single dependency and verifies its files against the lock
+// file, returning the locked files. A failed download exits the process with the status of
+// the download, unless KeepGoing is set. With Plan set the download is a dry run that adds
+// the files that do not match the lock file to the plan, and nothing is verified.
+func syncDependency(configs *dependencyConfigs, lockFile *deps.LockFile, name string, dep *deps.Dependency, opts *DepsSyncOptions) (map[string]string, error) {
+	logger, plan := opts.Logger, opts.Plan
+	lockedFiles, ok := lockFile.Dependencies[name]
+	if !ok {
+		return nil, &syncError{phase: syncPhaseResolve, err: fmt.Errorf("dependency %s not found in deps-lock.ini", name)}
+	}
 
-	err := filepath.Walk(outputDir, func(path string, info os.FileInfo, err error) error {
+	manifest := configs.manifest
+	depCfg, err := configs.config(dep)
+	if err != nil {
+		return nil, &syncError{phase: syncPhaseResolve, err: err}
+	}
+
+	repo := dep.Repository
+	if repo == "" {
+		repo = manifest.Defaults.Repository
+	}
+	checksumAlg := dep.Checksum
+	if checksumAlg == "" {
+		checksumAlg = manifest.Defaults.Checksum
+	}
+
+	logger.Printf("\n[%s]\n", name)
+	logger.Printf("  Repository: %s\n", repo)
+	logger.Printf("  Path:       %s\n", dep.ExpandedPath())
+	logger.Printf("  Output:     %s\n", dep.OutputDir)
+	if size, ok := deps.LockedTotalSize(lockedFiles); ok {
+		logger.Printf("  Files:      %d (%s)\n", len(lockedFiles), util.FormatByteSize(size))
+	} else {
+		logger.Printf("  Files:      %d\n", len(lockedFiles))
+	}
+	logger.Printf("  Checksum:   %s\n", checksumAlg)
+	logConnection(logger, depCfg)
+
+	downloadOpts, err := newDependencyDownloadOptions(dep, logger, opts.QuietMode)
+	if err != nil {
+		return nil, &syncError{phase: syncPhaseResolve, err: err}
+	}
+	downloadOpts.OnConflict = opts.OnConflict
+	downloadOpts.Decisions = operations.NewFileDecisions()
+	// Downloaded files are verified against the checksums computed while they were written
+	downloadOpts.Digests, err = operations.NewFileDigests(lockedAlgorithms(lockedFiles)...)
+	if err != nil {
+		return nil, &syncError{phase: syncPhaseResolve, err: err}
+	}
+	// Local files are compared against deps-lock.ini rather than against Nexus, and
+	// downloads must match it before they replace anything
+	downloadOpts.Expected, err = lockedExpectedFiles(dep.OutputDir, lockedFiles)
+	if err != nil {
+		return nil, &syncError{phase: syncPhaseResolve, err: err}
+	}
+
+	// Locked files deleted from Nexus since deps lock are found in the listing of the
+	// download, which fails before downloading anything unless they are allowed
+	downloadOpts.AllowMissing = opts.AllowMissing
+	if plan != nil {
+		downloadOpts.DryRun = true
+		downloadOpts.Plan = plan
+	}
+
+	src := path.Clean(path.Join(dep.Repository, dep.ExpandedPath()))
+	dest := dep.OutputDir
+
+	status := operations.Download(src, dest, depCfg, downloadOpts)
+	missing := make(map[string]bool)
+	var missingFiles []string
+	for _, localPath := range downloadOpts.Expected.Missing() {
+		filePath := localPath
+		if rel, err := filepath.Rel(dep.OutputDir, localPath); err == nil {
+			filePath = filepath.ToSlash(rel)
+		}
+		missing[filePath] = true
+		missingFiles = append(missingFiles, filePath)
+	}
+	if status == operations.DownloadMissing {
+		return nil, &syncError{phase: syncPhaseResolve, err: &deps.MissingError{Dependency: name, Files: missingFiles}, status: exitcode.Missing}
+	}
+	if len(missingFiles) > 0 {
+		logger.Printf("  Warning: %v\n", &deps.MissingError{Dependency: name, Files: missingFiles})
+	}
+	if status != operations.DownloadSuccess {
+		// Files that no longer match deps-lock.ini were never moved into place
+		if mismatched := downloadOpts.Expected.Mismatched(); len(mismatched) > 0 {
+			files := make([]string, len(mismatched))
+			for i, m := range mismatched {
+				name := m.LocalPath
+				if rel, err := filepath.Rel(dep.OutputDir, m.LocalPath); err == nil {
+					name = filepath.ToSlash(rel)
+				}
+				files[i] = fmt.Sprintf("%s (%v)", name, m.Err)
+			}
+			return nil, &syncError{phase: syncPhaseVerify, err: fmt.Errorf("%s does not match deps-lock.ini, local files were left untouched: %s",
+				src, strings.Join(files, "; ")), status: int(status)}
+		}
+		return nil, &syncError{phase: syncPhaseDownload, err: fmt.Errorf("%s: exit status %d", src, status), status: int(status)}
+	}
+	if plan != nil {
+		return lockedFiles, nil
+	}
+
+	for filePath := range lockedFiles {
+		localPath := filepath.Join(dep.OutputDir, filePath)
+		// A local copy of a file that is gone upstream is still verified
+		if _, err := os.Stat(localPath); missing[filePath] && os.IsNotExist(err) {
+			logger.VerbosePrintf("  %s: no longer exists upstream and not present locally; not verified\n", filePath)
+			continue
+		}
+		var algorithms []string
+		source := "deps-lock.ini"
+		if digest, ok := downloadOpts.Digests.Get(localPath); ok {
+			algorithms, err = deps.VerifyLockedDigest(localPath, lockedFiles[filePath], digest.Size, digest.Checksums)
+			// Hashed once, as it was downloaded or compared with deps-lock.ini
+			if decision, _ := downloadOpts.Decisions.Get(localPath); decision == operations.DecisionDownloaded {
+				source += " while downloading"
+			} else {
+				source += " while comparing"
+			}
+		} else {
+			algorithms, err = deps.VerifyLockedFile(localPath, lockedFiles[filePath])
+		}
 		if err != nil {
-			return err
+			return nil, &syncError{phase: syncPhaseVerify, err: err}
 		}
 
-		if info.IsDir() {
-			return nil
+		// Files the download did not handle, e.g. outside the glob, are only re-verified
+		decision, ok := downloadOpts.Decisions.Get(localPath)
+		if !ok {
+			decision = "re-verified only"
 		}
+		logger.VerbosePrintf("  %s: %s; verified against %s (%s)\n", filePath, decision, source, strings.Join(algorithms, ", "))
+	}
 
-		relPath, err := filepath.Rel(outputDir, path)
+	return lockedFiles, nil
+}
+
+// lockedAlgorithms returns the checksum algorithms recorded for the files of a dependency.
+// Entries that cannot be parsed are left to the verification to report.
+func lockedAlgorithms(lockedFiles map[string]string) []string {
+	seen := make(map[string]bool)
+	var algorithms []string
+	for _, locked := range lockedFiles {
+		checksums, err := deps.ParseLockChecksums(locked)
 		if err != nil {
-			return err
+			continue
 		}
+		for _, c := range checksums {
+			if !seen[c.Algorithm] {
+				seen[c.Algorithm] = true
+				algorithms = append(algorithms, c.Algorithm)
+			}
+		}
+	}
+	sort.Strings(algorithms)
+	return algorithms
+}
 
-		relPath = filepath.ToSlash(relPath)
+// lockedExpectedFiles returns the digests deps-lock.ini records for the files of a
+// dependency in outputDir. Entries that cannot be parsed are left to the verification to report.
+func lockedExpectedFiles(outputDir string, lockedFiles map[string]string) (*operations.ExpectedFiles, error) {
+	expected := operations.NewExpectedFiles()
+	for filePath, locked := range lockedFiles {
+		checksums, err := deps.ParseLockChecksums(locked)
+		if err != nil {
+			continue
+		}
+		digest := operations.FileDigest{Size: checksums[0].Size, Checksums: make(map[string]string, len(checksums))}
+		for _, c := range checksums {
+			digest.Checksums[c.Algorithm] = c.Value
+		}
+		if err := expected.Add(filepath.Join(outputDir, filePath), digest); err != nil {
+			return nil, fmt.Errorf("invalid entry in deps-lock.ini: %w", err)
+		}
+	}
+	return expected, nil
+}
 
-		if !trackedFiles[relPath] {
-			logger.VerbosePrintf("Deleting untracked file: %s\n", relPath)
-			if err := os.Remove(path); err != nil {
-				logger.Printf("Failed to delete file %s: %v\n", relPath, err)
-			} else {
-				nDeleted++
-			}
+// dependencyURL returns the URL of the server a dependency is downloaded from
+func dependencyURL(cfg *config.Config, manifest *deps.DepsManifest, dep *deps.Dependency) string {
+	if dep.URL != "" {
+		return dep.URL
+	}
+	if manifest.Defaults.URL != "" {
+		return manifest.Defaults.URL
+	}
+	return cfg.NexusURL
+}
+
+// dependencyConfigs builds the connection settings and clients for the servers of the
+// dependencies of a manifest. The config file and the credential store are read once, and
+// each server gets one client.
+type dependencyConfigs struct {
+	cfg      *config.Config
+	manifest *deps.DepsManifest
+	settings *config.Settings
+	store    *config.CredentialStore
+	configs  map[dependencyServer]*config.Config
+	clients  map[dependencyServer]*nexusapi.Client
+}
+
+// dependencyServer identifies the connection to the server of a dependency: its URL and
+// the ca_cert of the dependency
+type dependencyServer struct {
+	url, caCert string
+}
+
+func (c *dependencyConfigs) server(dep *deps.Dependency) dependencyServer {
+	return dependencyServer{dependencyURL(c.cfg, c.manifest, dep), c.manifest.CACertPath(dep)}
+}
+
+func newDependencyConfigs(cfg *config.Config, manifest *deps.DepsManifest, settings *config.Settings) (*dependencyConfigs, error) {
+	configs := &dependencyConfigs{
+		cfg:      cfg,
+		manifest: manifest,
+		settings: settings,
+		configs:  make(map[dependencyServer]*config.Config),
+		clients:  make(map[dependencyServer]*nexusapi.Client),
+	}
+	configs.store, _ = config.DefaultCredentialStore()
+	return configs, nil
+}
+
+// config returns the connection settings for the server of dep: its credentials from the
+// credential store and TLS settings, token and headers from the config file, see
+// config.Config.ForURL, and the ca_cert of the dependency in deps.ini, which takes
+// precedence over the config file. The [defaults] url of deps.ini is the server of the
+// project, so without credentials of its own it gets the credentials of the run.
+func (c *dependencyConfigs) config(dep *deps.Dependency) (*config.Config, error) {
+	server := c.server(dep)
+	if depCfg, ok := c.configs[server]; ok {
+		return depCfg, nil
+	}
+	depCfg, err := c.cfg.ForURL(server.url, c.settings, c.store)
+	if err != nil {
+		return nil, err
+	}
+	if c.manifest.Defaults.URL != "" && config.SameServer(server.url, c.manifest.Defaults.URL) {
+		depCfg.UseCredentialsOf(c.cfg)
+	}
+	if server.caCert != "" {
+		depCfg.CACert = server.caCert
+		depCfg.SetSource(config.KeyCACert, config.FileSource(c.manifest.File))
+		if err := depCfg.LoadTLS(); err != nil {
+			return nil, err
 		}
+	}
+	c.configs[server] = depCfg
+	return depCfg, nil
+}
+
+// client returns the client for the server of dep
+func (c *dependencyConfigs) client(dep *deps.Dependency) (*nexusapi.Client, error) {
+	depCfg, err := c.config(dep)
+	if err != nil {
+		return nil, err
+	}
+	server := c.server(dep)
+	client, ok := c.clients[server]
+	if !ok {
+		client = operations.NewClient(depCfg)
+		c.clients[server] = client
+	}
+	return client, nil
+}
+
+// resolver returns a resolver connecting to the server of each dependency with its own
+// settings
+func (c *dependencyConfigs) resolver() *deps.Resolver {
+	return deps.NewResolverWithClients(c.client)
+}
 
+// logConnection prints in verbose mode where the credentials, TLS settings and headers for
+// the server of cfg came from. Neither the password, the token nor header values are printed.
+func logConnection(logger util.Logger, cfg *config.Config) {
+	switch {
+	case cfg.Token != "":
+		logger.VerbosePrintf("  Credentials: token ******** (%s)\n", cfg.Source(config.KeyToken))
+	case cfg.Username == "" && cfg.Password == "":
+		logger.VerbosePrintf("  Credentials: none, anonymous access\n")
+	default:
+		password := "not set"
+		if cfg.Password != "" {
+			password = "********"
+		}
+		logger.VerbosePrintf("  Credentials: user %q, password %s (%s)\n", cfg.Username, password, cfg.Source(config.KeyPassword))
+	}
+	if len(cfg.Headers) > 0 {
+		names := make([]string, 0, len(cfg.Headers))
+		for name := range cfg.Headers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		logger.VerbosePrintf("  Headers:     %s (%s)\n", strings.Join(names, ", "), cfg.Source(config.KeyHeaders))
+	}
+	switch {
+	case cfg.CACert != "" && cfg.Insecure:
+		logger.VerbosePrintf("  TLS:         CA %s (%s), certificate not verified (%s)\n", cfg.CACert, cfg.Source(config.KeyCACert), cfg.Source(config.KeyInsecure))
+	case cfg.CACert != "":
+		logger.VerbosePrintf("  TLS:         CA %s and system CAs (%s)\n", cfg.CACert, cfg.Source(config.KeyCACert))
+	case cfg.Insecure:
+		logger.VerbosePrintf("  TLS:         certificate not verified (%s)\n", cfg.Source(config.KeyInsecure))
+	default:
+		logger.VerbosePrintf("  TLS:         system CAs (%s)\n", config.SourceDefault)
+	}
+}
+
+// serveCredentials returns the credentials serve requires from its clients: only those
+// given by flag or environment. The built-in defaults are public and the credentials
+// stored by login belong to the upstream server, so both leave the credentials empty.
+func serveCredentials(cfg *config.Config) (username, password string) {
+	explicit := func(key string) bool {
+		source := cfg.Source(key)
+		return strings.HasPrefix(string(source), string(config.FlagSource(""))) || strings.HasPrefix(string(source), string(config.EnvSource("")))
+	}
+	if !explicit(config.KeyUsername) || !explicit(config.KeyPassword) {
+		return "", ""
+	}
+	return cfg.Username, cfg.Password
+}
+
+// dependencySize returns the total size of the locked files of a dependency, from the sizes
+// recorded in deps-lock.ini or, for lock files written before sizes were recorded, from
+// the asset metadata in Nexus
+func dependencySize(configs *dependencyConfigs, dep *deps.Dependency, lockedFiles map[string]string) (int64, error) {
+	if total, ok := deps.LockedTotalSize(lockedFiles); ok {
+		return total, nil
+	}
+
+	client, err := configs.client(dep)
+	if err != nil {
+		return 0, err
+	}
+	assets, err := client.ListAssets(dep.Repository, path.Clean(dep.ExpandedPath()), dep.Recursive)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list assets of %s: %w", dep.Name, err)
+	}
+	var total int64
+	for _, asset := range assets {
+		if _, ok := lockedFiles[strings.TrimPrefix(asset.Path, "/")]; ok {
+			total += asset.FileSize
+		}
+	}
+	return total, nil
+}
+
+// checkDependencyBudget fails if the locked files of all dependencies are larger than
+// maxTotalSize bytes in total, naming the largest dependencies
+func checkDependencyBudget(configs *dependencyConfigs, lockFile *deps.LockFile, maxTotalSize int64) error {
+	type depSize struct {
+		name string
+		size int64
+	}
+	var sizes []depSize
+	var total int64
+	for name, dep := range configs.manifest.Dependencies {
+		lockedFiles, ok := lockFile.Dependencies[name]
+		if !ok {
+			// Reported when the dependency is synced
+			continue
+		}
+		size, err := dependencySize(configs, dep, lockedFiles)
+		if err != nil {
+			return err
+		}
+		sizes = append(sizes, depSize{name, size})
+		total += size
+	}
+	if total <= maxTotalSize {
 		return nil
+	}
+
+	sort.Slice(sizes, func(i, j int) bool {
+		if sizes[i].size != sizes[j].size {
+			return sizes[i].size > sizes[j].size
+		}
+		return sizes[i].name < sizes[j].name
 	})
+	var largest []string
+	for i := 0; i < len(sizes) && i < 3; i++ {
+		largest = append(largest, fmt.Sprintf("%s %s", sizes[i].name, util.FormatByteSize(sizes[i].size)))
+	}
+	return fmt.Errorf("dependencies total %s, more than the maximum of %s (--max-total-size or max_size in deps.ini); largest: %s",
+		util.FormatByteSize(total), util.FormatByteSize(maxTotalSize), strings.Join(largest, ", "))
+}
+
+// newDependencyDownloadOptions builds the download options for a single dependency
+func newDependencyDownloadOptions(dep *deps.Dependency, logger util.Logger, quietMode bool) (*operations.DownloadOptions, error) {
+	// Files are validated on download with the first algorithm; deps sync checks the rest
+	algorithm := dep.Checksum
+	if algorithms := dep.ChecksumAlgorithms(); len(algorithms) > 0 {
+		algorithm = algorithms[0]
+	}
+	downloadOpts := &operations.DownloadOptions{
+		Logger:            logger,
+		QuietMode:         quietMode,
+		ChecksumAlgorithm: algorithm,
+		Recursive:         dep.Recursive,
+		Concurrency:       dep.Concurrency,
+		MaxRate:           dep.MaxRate,
+	}
+	if err := downloadOpts.SetChecksumAlgorithm(algorithm); err != nil {
+		return nil, fmt.Errorf("error setting checksum algorithm: %w", err)
+	}
+	return downloadOpts, nil
+}
+
+// cleanupUntrackedFiles deletes the files in outputDir that are not tracked, returning the
+// number of files deleted and an error if the directory could not be cleaned up completely
+func cleanupUntrackedFiles(outputDir string, trackedFiles map[string]bool, logger util.Logger) (int, error) {
+	nDeleted := 0
+	nFailed := 0
+
+	untracked, err := untrackedFiles(outputDir, trackedFiles)
+	for _, relPath := range untracked {
+		logger.VerbosePrintf("Deleting untracked file: %s\n", relPath)
+		if err := os.Remove(filepath.Join(outputDir, relPath)); err != nil {
+			logger.Printf("Failed to delete file %s: %v\n", relPath, err)
+			nFailed++
+		} else {
+			nDeleted++
+		}
+	}
 
 	if err != nil {
 		logger.Printf("Error walking directory: %v\n", err)
+		return nDeleted, err
 	}
 
 	cleanupEmptyDirectories(outputDir, logger)
 
-	return nDeleted
+	if nFailed > 0 {
+		return nDeleted, fmt.Errorf("%d untracked file(s) could not be deleted", nFailed)
+	}
+	return nDeleted, nil
+}
+
+// untrackedFiles returns the slash-separated paths of the files in outputDir that are not
+// tracked, relative to outputDir. Backups made by --on-conflict=backup are never untracked.
+func untrackedFiles(outputDir string, trackedFiles map[string]bool) ([]string, error) {
+	var untracked []string
+	err := filepath.Walk(outputDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		relPath, err := filepath.Rel(outputDir, path)
+		if err != nil {
+			return err
+		}
+		relPath = filepath.ToSlash(relPath)
+		if !operations.IsConflictBackup(relPath) && !trackedFiles[relPath] {
+			untracked = append(untracked, relPath)
+		}
+		return nil
+	})
+	return untracked, err
 }
 
 func cleanupEmptyDirectories(outputDir string, logger util.Logger) {
@@ -278,29 +874,599 @@ func cleanupEmptyDirectories(outputDir string, logger util.Logger) {
 	})
 }
 
+// depsCheckMain validates deps.ini and checks that deps-lock.ini is consistent with it,
+// without contacting Nexus
+func depsCheckMain(logger util.Logger) error {
+	manifest, err := deps.ParseDepsIni("deps.ini")
+	if err != nil {
+		return err
+	}
+	lockFile, err := deps.ParseLockFile("deps-lock.ini")
+	if err != nil {
+		return fmt.Errorf("error parsing deps-lock.ini: %w", err)
+	}
+	if err := deps.CheckLockFile("deps-lock.ini", manifest, lockFile); err != nil {
+		return err
+	}
+	logger.Printf("✓ deps.ini and deps-lock.ini are consistent (%d dependencies)\n", len(manifest.Dependencies))
+	return nil
+}
+
+// checkDepsFormat returns an error unless format is a format deps import and export
+// support. Only the JSON schema of deps.JSONManifest is supported so far.
+func checkDepsFormat(format string) error {
+	if strings.ToLower(format) != "json" {
+		return fmt.Errorf("unsupported format '%s': must be one of: json", format)
+	}
+	return nil
+}
+
+func depsImportMain(logger util.Logger, format, inputFile string, force bool) error {
+	if err := checkDepsFormat(format); err != nil {
+		return err
+	}
+	manifest, err := deps.ReadJSONManifest(inputFile)
+	if err != nil {
+		return err
+	}
+	names, err := deps.ImportJSON("deps.ini", manifest, force)
+	if err != nil {
+		return err
+	}
+	logger.Printf("Imported %d dependencies from %s into deps.ini: %s\n", len(names), inputFile, strings.Join(names, ", "))
+	logger.Printf("Run 'deps lock' to resolve them\n")
+	return nil
+}
+
+// depsExportMain writes the dependencies of deps.ini to outputFile, or to stdout if it is
+// empty. Settings the format cannot express are reported on stderr.
+func depsExportMain(logger util.Logger, stderr io.Writer, format, outputFile string, force bool) error {
+	if err := checkDepsFormat(format); err != nil {
+		return err
+	}
+	manifest, err := deps.ParseDepsIni("deps.ini")
+	if err != nil {
+		return err
+	}
+	exported, skipped := deps.ExportJSON(manifest)
+	if len(skipped) > 0 {
+		fmt.Fprintf(stderr, "Warning: the JSON schema has no field for these settings, which are not exported: %s\n", strings.Join(skipped, ", "))
+	}
+	if outputFile == "" {
+		return deps.WriteJSONManifest(os.Stdout, exported)
+	}
+
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if !force {
+		flags |= os.O_EXCL
+	}
+	f, err := os.OpenFile(outputFile, flags, 0644)
+	if os.IsExist(err) {
+		return fmt.Errorf("%s already exists; use --force to overwrite it", outputFile)
+	} else if err != nil {
+		return err
+	}
+	if err := deps.WriteJSONManifest(f, exported); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	logger.Printf("Exported %d dependencies to %s\n", len(exported.Dependencies), outputFile)
+	return nil
+}
+
+// pinLatestVersions sets the versions of the dependencies that select {latest} to the
+// versions locked in deps-lock.ini. deps-lock.ini is only read if there are any.
+func pinLatestVersions(manifest *deps.DepsManifest) error {
+	latest := false
+	for _, dep := range manifest.Dependencies {
+		latest = latest || dep.LatestVersion()
+	}
+	if !latest {
+		return nil
+	}
+	lockFile, err := deps.ParseLockFile("deps-lock.ini")
+	if err != nil {
+		return fmt.Errorf("error parsing deps-lock.ini: %w", err)
+	}
+	return deps.PinVersions(manifest, lockFile)
+}
+
 func depsEnvMain(logger util.Logger, outputFile string) {
 	manifest, err := deps.ParseDepsIni("deps.ini")
 	if err != nil {
 		fmt.Printf("Error parsing deps.ini: %v\n", err)
-		os.Exit(1)
+		os.Exit(exitcode.Error)
+	}
+	if err := pinLatestVersions(manifest); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitcode.Error)
 	}
 
-	if err := deps.GenerateEnvFile(outputFile, manifest); err != nil {
+	changed, err := deps.GenerateEnvFile(outputFile, manifest)
+	if err != nil {
 		fmt.Printf("Error generating %s: %v\n", outputFile, err)
-		os.Exit(1)
+		os.Exit(exitcode.Error)
+	}
+
+	if changed {
+		logger.Printf("Generated %s\n", outputFile)
+	} else {
+		logger.Printf("%s unchanged\n", outputFile)
+	}
+}
+
+func verifyTreeChecksumMain(dir string, expected string, logger util.Logger) error {
+	algorithm, expectedDigest, err := checksum.ParseTreeChecksum(expected)
+	if err != nil {
+		return err
+	}
+
+	actual, err := checksum.ComputeTreeChecksum(dir, algorithm)
+	if err != nil {
+		return fmt.Errorf("error computing tree checksum for %s: %w", dir, err)
+	}
+
+	expectedChecksum := algorithm + ":" + expectedDigest
+	if actual != expectedChecksum {
+		return fmt.Errorf("tree checksum mismatch for %s: expected %s, got %s", dir, expectedChecksum, actual)
+	}
+
+	logger.Printf("✓ Tree checksum verified: %s\n", actual)
+	return nil
+}
+
+// loginMain checks credentials against the Nexus server in cfg and stores them. Missing
+// values are read from in; the password is not echoed when in is a terminal.
+func loginMain(cfg *config.Config, store *config.CredentialStore, username, password string, in io.Reader, logger util.Logger) error {
+	reader := bufio.NewReader(in)
+	if username == "" {
+		fmt.Print("Username: ")
+		line, err := reader.ReadString('\n')
+		if err != nil && line == "" {
+			return fmt.Errorf("failed to read username: %w", err)
+		}
+		username = strings.TrimSpace(line)
+	}
+	if password == "" {
+		if f, ok := in.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+			fmt.Print("Password: ")
+			secret, err := term.ReadPassword(int(f.Fd()))
+			fmt.Println()
+			if err != nil {
+				return fmt.Errorf("failed to read password: %w", err)
+			}
+			password = string(secret)
+		} else {
+			// Allow piping a token, e.g. `echo "$TOKEN" | nexuscli-go login --username ci`
+			line, err := reader.ReadString('\n')
+			if err != nil && line == "" {
+				return fmt.Errorf("failed to read password: %w", err)
+			}
+			password = strings.TrimRight(line, "\r\n")
+		}
+	}
+	if username == "" || password == "" {
+		return fmt.Errorf("username and password are required")
+	}
+
+	// The credentials are checked on their own, not hidden behind a token of the server
+	loginCfg := *cfg
+	loginCfg.Username, loginCfg.Password, loginCfg.Token = username, password, ""
+	client := operations.NewClient(&loginCfg)
+	if _, err := client.ListRepositories(); err != nil {
+		var httpErr *nexusapi.HTTPError
+		if errors.As(err, &httpErr) && (httpErr.StatusCode == http.StatusUnauthorized || httpErr.StatusCode == http.StatusForbidden) {
+			return fmt.Errorf("login to %s failed: invalid username or password", cfg.NexusURL)
+		}
+		return fmt.Errorf("login to %s failed: %w", cfg.NexusURL, err)
+	}
+
+	if err := store.Set(cfg.NexusURL, config.Credentials{Username: username, Password: password}); err != nil {
+		return fmt.Errorf("failed to store credentials: %w", err)
+	}
+	logger.Printf("Logged in to %s as %s (credentials stored in %s)\n", cfg.NexusURL, username, store.Path)
+	return nil
+}
+
+// logoutMain removes the stored credentials for the Nexus server in cfg
+func logoutMain(cfg *config.Config, store *config.CredentialStore, logger util.Logger) error {
+	removed, err := store.Delete(cfg.NexusURL)
+	if err != nil {
+		return err
+	}
+	if !removed {
+		logger.Printf("No credentials stored for %s\n", cfg.NexusURL)
+		return nil
+	}
+	logger.Printf("Removed credentials for %s\n", cfg.NexusURL)
+	return nil
+}
+
+// loadSettings reads the config file at its default location. Without a known location
+// the settings are empty.
+func loadSettings() (*config.Settings, error) {
+	path, err := config.DefaultSettingsFile()
+	if err != nil {
+		return &config.Settings{}, nil
+	}
+	return config.LoadSettings(path)
+}
+
+// applyChecksumDefault replaces the built-in --checksum default with NEXUS_CHECKSUM or the
+// config file setting when the flag was not given
+func applyChecksumDefault(cmd *cobra.Command, settings *config.Settings, algorithm *string) config.Source {
+	if cmd.Flags().Changed("checksum") {
+		return config.FlagSource("checksum")
+	}
+	var source config.Source
+	*algorithm, source = settings.DefaultChecksumAlgorithmSource()
+	return source
+}
+
+// applyUploadBatchLimits sets the batch limits of an upload from --batch-size and
+// --max-request-bytes, or from the config file for flags that were not given
+func applyUploadBatchLimits(cmd *cobra.Command, settings *config.Settings, opts *operations.UploadOptions, maxRequestBytes string) error {
+	flags := cmd.Flags()
+	if !flags.Changed("batch-size") {
+		opts.BatchSize = settings.UploadBatchSize
+	}
+	if !flags.Changed("max-request-bytes") {
+		opts.MaxRequestBytes = settings.UploadMaxRequestBytes
+	}
+	if opts.BatchSize < 0 {
+		return fmt.Errorf("--batch-size must not be negative")
+	}
+	if flags.Changed("max-request-bytes") {
+		n, err := util.ParseByteSize(maxRequestBytes)
+		if err != nil {
+			return fmt.Errorf("--max-request-bytes: %w", err)
+		}
+		opts.MaxRequestBytes = n
+	}
+	return nil
+}
+
+// applyRepositoryAliases sets the repository aliases of cfg from the config file and
+// --repository-alias, which replaces an alias of the same name from the file
+func applyRepositoryAliases(cmd *cobra.Command, cfg *config.Config, settings *config.Settings) error {
+	for name, repository := range settings.RepositoryAliases {
+		if err := cfg.SetRepositoryAlias(name, repository); err != nil {
+			return err
+		}
+	}
+	aliases, _ := cmd.Flags().GetStringArray("repository-alias")
+	for _, alias := range aliases {
+		name, repository, ok := strings.Cut(alias, "=")
+		if !ok {
+			return fmt.Errorf("invalid --repository-alias '%s': must be in the form name=repository", alias)
+		}
+		if err := cfg.SetRepositoryAlias(name, repository); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// repositoryArgsAnnotation lists, comma-separated, the indexes of the arguments of a
+// command that are repository paths in the form repository[/path]
+const repositoryArgsAnnotation = "nexuscli_repository_args"
+
+// repositoryFlagAnnotation marks a flag whose value is a repository path. With the value
+// "route" the flag holds 'pattern=repository[/path]' routes instead.
+const repositoryFlagAnnotation = "nexuscli_repository_path"
+
+// expandRepositoryAliases replaces the repository aliases in the arguments and flags of
+// cmd marked as repository paths. args is changed in place, so the Run of cmd, which is
+// given the same slice, sees the expanded paths.
+func expandRepositoryAliases(cmd *cobra.Command, cfg *config.Config, args []string) error {
+	if indexes := cmd.Annotations[repositoryArgsAnnotation]; indexes != "" {
+		for _, index := range strings.Split(indexes, ",") {
+			i, err := strconv.Atoi(index)
+			if err != nil {
+				return fmt.Errorf("invalid repository argument index '%s' of %s", index, cmd.Name())
+			}
+			if i < len(args) {
+				args[i] = cfg.ExpandRepositoryAlias(args[i])
+			}
+		}
+	}
+	var err error
+	cmd.Flags().VisitAll(func(flag *pflag.Flag) {
+		kind, ok := flag.Annotations[repositoryFlagAnnotation]
+		if !ok || !flag.Changed || err != nil {
+			return
+		}
+		expand := cfg.ExpandRepositoryAlias
+		if len(kind) > 0 && kind[0] == "route" {
+			expand = func(route string) string {
+				pattern, dest, ok := strings.Cut(route, "=")
+				if !ok {
+					return route
+				}
+				return pattern + "=" + cfg.ExpandRepositoryAlias(strings.TrimSpace(dest))
+			}
+		}
+		if slice, ok := flag.Value.(pflag.SliceValue); ok {
+			values := slice.GetSlice()
+			for i := range values {
+				values[i] = expand(values[i])
+			}
+			err = slice.Replace(values)
+			return
+		}
+		err = flag.Value.Set(expand(flag.Value.String()))
+	})
+	return err
+}
+
+// applyHostSettings sets the TLS settings of cfg from the [host] section of its server in
+// the config file
+func applyHostSettings(cfg *config.Config, settings *config.Settings) error {
+	settings.ApplyHost(cfg)
+	return cfg.LoadTLS()
+}
+
+// verbosityFlag is the value of --verbose. It is a bool flag, so --verbose=true and
+// --verbose=false work, that counts how often it was given, so -vv is more verbose.
+type verbosityFlag int
+
+func (v *verbosityFlag) Set(s string) error {
+	on, err := strconv.ParseBool(s)
+	if err != nil {
+		return err
+	}
+	if on {
+		*v++
+	} else {
+		*v = 0
+	}
+	return nil
+}
+
+func (v *verbosityFlag) String() string {
+	return strconv.FormatBool(*v > 0)
+}
+
+func (v *verbosityFlag) Type() string {
+	return "bool"
+}
+
+// addVerboseFlag adds --verbose (-v) to flags, counting into verbosity
+func addVerboseFlag(flags *pflag.FlagSet, verbosity *verbosityFlag) {
+	flag := flags.VarPF(verbosity, "verbose", "v", "Enable verbose output; repeat (-vv) to also list every skipped file and print a breakdown of request timings")
+	flag.NoOptDefVal = "true"
+}
+
+// addMetricsFlags adds --metrics-file and --metrics-listen to cmd
+func addMetricsFlags(cmd *cobra.Command, metricsFile, metricsListen *string) {
+	cmd.Flags().StringVar(metricsFile, "metrics-file", "", "Write counters and timings of the run to this file in the Prometheus text format when it ends, e.g. for the node_exporter textfile collector")
+	cmd.Flags().StringVar(metricsListen, "metrics-listen", "", "Serve counters and timings at /metrics on this address (e.g. ':9184') while the run lasts")
+}
+
+// startMetrics records the metrics of the run of cmd if metricsFile or metricsListen is
+// set. The returned function ends the run with its outcome: it stops serving the metrics
+// and writes the metrics file.
+func startMetrics(cmd *cobra.Command, metricsFile, metricsListen string) (func(success bool) error, error) {
+	if metricsFile == "" && metricsListen == "" {
+		return func(bool) error { return nil }, nil
+	}
+	registry := metrics.New(strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name()+" "))
+	stop := func() {}
+	if metricsListen != "" {
+		var err error
+		if stop, err = registry.Listen(metricsListen); err != nil {
+			return nil, err
+		}
+	}
+	metrics.Enable(registry)
+	return func(success bool) error {
+		metrics.Enable(nil)
+		registry.Finish(success)
+		stop()
+		if metricsFile == "" {
+			return nil
+		}
+		if err := registry.WriteFile(metricsFile); err != nil {
+			return fmt.Errorf("failed to write metrics file: %w", err)
+		}
+		return nil
+	}, nil
+}
+
+// explainEntry is one resolved setting printed by --explain
+type explainEntry struct {
+	name   string
+	value  string
+	source config.Source
+}
+
+// connectionExplain lists the resolved connection settings. The password is never printed.
+func connectionExplain(cfg *config.Config) []explainEntry {
+	password := "(not set)"
+	if cfg.Password != "" {
+		password = "******** (redacted)"
+	}
+	entries := []explainEntry{
+		{"url", cfg.NexusURL, cfg.Source(config.KeyURL)},
+		{"username", cfg.Username, cfg.Source(config.KeyUsername)},
+		{"password", password, cfg.Source(config.KeyPassword)},
+		{"auth", "basic", config.SourceDefault},
+	}
+	if cfg.UnixSocket != "" {
+		entries = append(entries, explainEntry{"unix-socket", cfg.UnixSocket, cfg.Source(config.KeyUnixSocket)})
+	}
+	if cfg.CACert != "" {
+		entries = append(entries, explainEntry{"ca-cert", cfg.CACert, cfg.Source(config.KeyCACert)})
+	}
+	if cfg.Insecure {
+		entries = append(entries, explainEntry{"insecure", "true", cfg.Source(config.KeyInsecure)})
+	}
+	return append(entries, explainEntry{"request timeout", "none", config.SourceDefault})
+}
+
+// flagExplain returns the value of the flag name with the flag as its source if it was
+// given, and fallback otherwise
+func flagExplain(cmd *cobra.Command, name string, fallback config.Source) explainEntry {
+	flag := cmd.Flags().Lookup(name)
+	if flag.Changed {
+		return explainEntry{name, flag.Value.String(), config.FlagSource(name)}
+	}
+	return explainEntry{name, flag.Value.String(), fallback}
+}
+
+// compareSource returns where the --compare mode came from; without the flag it
+// follows --skip-checksum
+func compareSource(cmd *cobra.Command) config.Source {
+	if cmd.Flags().Changed("compare") {
+		return config.FlagSource("compare")
+	}
+	if cmd.Flags().Changed("skip-checksum") {
+		return config.FlagSource("skip-checksum")
+	}
+	return config.SourceDefault
+}
+
+// formatExplain returns the --compress-format entry; without the flag the format follows
+// the archive name
+func formatExplain(cmd *cobra.Command) explainEntry {
+	entry := flagExplain(cmd, "compress-format", config.SourceDefault)
+	if entry.value == "" {
+		entry.value = "from the archive name (gzip if unknown)"
+	}
+	return entry
+}
+
+// uploadExplainEntries lists the resolved settings of an upload for --explain
+func uploadExplainEntries(cmd *cobra.Command, cfg *config.Config, settings *config.Settings, opts *operations.UploadOptions, checksumAlg string, checksumSource config.Source) []explainEntry {
+	entries := append(connectionExplain(cfg),
+		explainEntry{"checksum", checksumAlg, checksumSource},
+		flagExplain(cmd, "skip-checksum", config.SourceDefault),
+		explainEntry{"compare", string(opts.EffectiveCompare()), compareSource(cmd)},
+	)
+	batchSource, requestBytesSource := config.SourceDefault, config.SourceDefault
+	if opts.BatchSize != 0 {
+		batchSource = settings.Source()
+	}
+	if opts.MaxRequestBytes != 0 {
+		requestBytesSource = settings.Source()
+	}
+	if cmd.Flags().Changed("max-request-bytes") {
+		requestBytesSource = config.FlagSource("max-request-bytes")
+	}
+	maxRequestBytes := "no limit"
+	if opts.MaxRequestBytes > 0 {
+		maxRequestBytes = fmt.Sprintf("%d bytes", opts.MaxRequestBytes)
+	}
+	entries = append(entries, flagExplain(cmd, "batch-size", batchSource), explainEntry{"max-request-bytes", maxRequestBytes, requestBytesSource})
+	if opts.Compress {
+		entries = append(entries, formatExplain(cmd))
+	}
+	return append(entries, flagExplain(cmd, "wait-for-writable", config.SourceDefault), flagExplain(cmd, "dry-run", config.SourceDefault))
+}
+
+// downloadExplainEntries lists the resolved settings of a download for --explain
+func downloadExplainEntries(cmd *cobra.Command, cfg *config.Config, opts *operations.DownloadOptions, checksumAlg string, checksumSource config.Source) []explainEntry {
+	entries := append(connectionExplain(cfg),
+		explainEntry{"checksum", checksumAlg, checksumSource},
+		flagExplain(cmd, "skip-checksum", config.SourceDefault),
+		explainEntry{"compare", string(opts.EffectiveCompare()), compareSource(cmd)},
+		flagExplain(cmd, "verify", config.SourceDefault),
+		flagExplain(cmd, "concurrency", config.SourceDefault),
+		flagExplain(cmd, "max-rate", config.SourceDefault),
+	)
+	if opts.Compress {
+		entries = append(entries, formatExplain(cmd))
+	}
+	return append(entries, flagExplain(cmd, "wait-for-available", config.SourceDefault), flagExplain(cmd, "dry-run", config.SourceDefault))
+}
+
+// printExplain prints the resolved settings of a command and where each came from
+func printExplain(w io.Writer, entries []explainEntry) {
+	fmt.Fprintln(w, "Effective configuration:")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, e := range entries {
+		value := e.value
+		if value == "" {
+			value = "(not set)"
+		}
+		fmt.Fprintf(tw, "  %s\t%s\t(%s)\n", e.name, value, e.source)
+	}
+	tw.Flush()
+}
+
+// applyContentTypes sets the Content-Types of uploaded files from the --content-type-map
+// file and then the inline --content-type mappings, so inline mappings win
+func applyContentTypes(opts *operations.UploadOptions, mapFile string, inline []string) error {
+	if mapFile != "" {
+		mapping, err := operations.LoadContentTypeMap(mapFile)
+		if err != nil {
+			return fmt.Errorf("--content-type-map: %w", err)
+		}
+		for ext, contentType := range mapping {
+			if err := opts.SetContentType(ext, contentType); err != nil {
+				return fmt.Errorf("--content-type-map: %w", err)
+			}
+		}
+	}
+	for _, s := range inline {
+		ext, contentType, err := operations.ParseContentTypeMapping(s)
+		if err == nil {
+			err = opts.SetContentType(ext, contentType)
+		}
+		if err != nil {
+			return fmt.Errorf("--content-type: %w", err)
+		}
+	}
+	return nil
+}
+
+// queueDir returns the upload queue directory given by --queue-dir, or the default one
+func queueDir(flag string) (string, error) {
+	if flag != "" {
+		return flag, nil
+	}
+	return config.DefaultQueueDir()
+}
+
+// completionTimeout bounds each Nexus request made for shell completion, so a slow or
+// unreachable server never blocks the shell on Tab
+var completionTimeout = 2 * time.Second
+
+// newCompletionClient returns a client for completion lookups. Its requests give up after
+// completionTimeout and are neither throttled nor retried, so a lookup that fails, times
+// out or is rate limited simply yields no completions.
+func newCompletionClient(cfg *config.Config) *nexusapi.Client {
+	client := operations.NewClient(cfg, nexusapi.WithoutThrottle())
+	httpClient := *client.HTTPClient // May be http.DefaultClient, which must not change
+	httpClient.Timeout = completionTimeout
+	client.HTTPClient = &httpClient
+	return client
+}
+
+// getRepositoryCompletions completes a repository name. Repository aliases are offered
+// too, even if Nexus cannot be reached.
+func getRepositoryCompletions(cfg *config.Config, toComplete string) []string {
+	var completions []string
+	for name := range cfg.RepositoryAliases {
+		if strings.HasPrefix(name, toComplete) {
+			completions = append(completions, name)
+		}
 	}
+	sort.Strings(completions)
 
-	logger.Printf("Generated %s\n", outputFile)
-}
-
-func getRepositoryCompletions(cfg *config.Config, toComplete string) []string {
-	client := nexusapi.NewClient(cfg.NexusURL, cfg.Username, cfg.Password)
+	client := newCompletionClient(cfg)
 	repos, err := client.ListRepositories()
 	if err != nil {
-		return nil
+		return completions
 	}
-	var completions []string
 	for _, repo := range repos {
+		// An alias shadows a repository of the same name, which is already offered
+		if _, ok := cfg.RepositoryAliases[repo.Name]; ok {
+			continue
+		}
 		if strings.HasPrefix(repo.Name, toComplete) {
 			completions = append(completions, repo.Name)
 		}
@@ -309,14 +1475,26 @@ func getRepositoryCompletions(cfg *config.Config, toComplete string) []string {
 }
 
 func getPathCompletions(cfg *config.Config, repository, pathPrefix string) []string {
-	client := nexusapi.NewClient(cfg.NexusURL, cfg.Username, cfg.Password)
-	paths, err := client.SearchAssetsForCompletion(repository, pathPrefix)
+	client := newCompletionClient(cfg)
+	paths, err := client.SearchAssetsForCompletion(cfg.ExpandRepositoryAlias(repository), pathPrefix)
 	if err != nil {
 		return nil
 	}
 	return paths
 }
 
+// getFolderCompletions completes an upload destination. Files are uploaded into a folder,
+// so only folders are offered.
+func getFolderCompletions(cfg *config.Config, repository, pathPrefix string) []string {
+	var completions []string
+	for _, comp := range getPathCompletions(cfg, repository, pathPrefix) {
+		if strings.HasSuffix(comp, "/") {
+			completions = append(completions, path.Join(repository, comp)+"/")
+		}
+	}
+	return completions
+}
+
 func parseRepoAndPath(arg string) (string, string) {
 	parts := strings.SplitN(arg, "/", 2)
 	if len(parts) == 2 {
@@ -329,67 +1507,175 @@ func buildRootCommand() *cobra.Command {
 	cfg := config.NewConfig()
 	var logger util.Logger
 	var quietMode bool
-	var verboseMode bool
+	var planFormat operations.PlanFormat
+	var requestMetrics *nexusapi.RequestMetrics
+	var verbosity verbosityFlag
+	var metricsFile string
+	var metricsListen string
 
 	uploadOpts := &operations.UploadOptions{}
 	var uploadCompressionFormat string
 	var uploadChecksumAlg string
+	var uploadExplain bool
+	var uploadFlattenOnConflict string
+	var uploadGlobPattern string
+	var uploadOnDeniedExt string
+	var uploadRoutes []string
+	var uploadMaxRequestBytes string
+	var uploadChunked string
+	var uploadTouch string
+	var uploadQueue bool
+	var uploadQueueDir string
+	var uploadContentTypeMap string
+	var uploadContentTypes []string
+	var uploadAttributes []string
+	var uploadCompare string
 
 	downloadOpts := &operations.DownloadOptions{
 		ChecksumAlgorithm: "sha1",
 	}
 	var downloadCompressionFormat string
 	var downloadChecksumAlg string
+	var downloadExplain bool
+	var downloadMaxRate string
+	var downloadMaxTotalSize string
+	var downloadDirMode string
+	var downloadFlattenOnConflict string
+	var downloadGlobPattern string
+	var downloadOnConflict string
+	var downloadOnNonEmpty string
+	var downloadVerify string
+	var downloadOnMissingChecksum string
+	var downloadManifestOptional bool
+	var downloadCompare string
+	var downloadSort string
+	var downloadDirection string
+	var downloadSelect string
+
+	// The config file is read once by PersistentPreRun
+	settings := &config.Settings{}
+	// Completions run without PersistentPreRun, so they read the aliases themselves
+	completionAliases := func(cmd *cobra.Command) {
+		if settings, err := loadSettings(); err == nil {
+			_ = applyRepositoryAliases(cmd, cfg, settings)
+		}
+	}
 
 	var rootCmd = &cobra.Command{
 		Use:   "nexuscli-go",
 		Short: "Nexus CLI for upload and download",
-		Long:  "Nexus CLI for upload and download\n\nExit codes:\n  0  - Success\n  1  - General error\n  66 - No files found (download only)",
+		Long:  "Nexus CLI for upload and download\n\n" + exitcode.Help("") + "\n\nRun 'nexuscli-go exit-codes' for the full table.",
 		PersistentPreRun: func(cmd *cobra.Command, args []string) {
 			cliURL, _ := cmd.Flags().GetString("url")
 			cliUsername, _ := cmd.Flags().GetString("username")
 			cliPassword, _ := cmd.Flags().GetString("password")
+			cliUnixSocket, _ := cmd.Flags().GetString("unix-socket")
 			quietMode, _ = cmd.Flags().GetBool("quiet")
-			verboseMode, _ = cmd.Flags().GetBool("verbose")
+			if verbosity > 1 && !quietMode {
+				requestMetrics = nexusapi.EnableRequestMetrics()
+			}
 			if cliURL != "" {
 				cfg.NexusURL = cliURL
+				cfg.SetSource(config.KeyURL, config.FlagSource("url"))
+			}
+			if cliUnixSocket != "" {
+				cfg.UnixSocket = cliUnixSocket
+				cfg.SetSource(config.KeyUnixSocket, config.FlagSource("unix-socket"))
 			}
 			if cliUsername != "" {
 				cfg.Username = cliUsername
+				cfg.SetSource(config.KeyUsername, config.FlagSource("username"))
 			}
 			if cliPassword != "" {
 				cfg.Password = cliPassword
+				cfg.SetSource(config.KeyPassword, config.FlagSource("password"))
+			}
+			// Stored credentials from `login` apply when none are given by flag or environment
+			if cliUsername == "" && cliPassword == "" {
+				if store, err := config.DefaultCredentialStore(); err == nil {
+					if err := cfg.UseStoredCredentials(store); err != nil {
+						fmt.Println("Error:", err)
+						os.Exit(exitcode.Error)
+					}
+				}
+			}
+			var err error
+			if settings, err = loadSettings(); err != nil {
+				fmt.Println("Error:", err)
+				os.Exit(exitcode.Error)
+			}
+			if err := applyRepositoryAliases(cmd, cfg, settings); err != nil {
+				fmt.Println("Error:", err)
+				os.Exit(exitcode.Error)
+			}
+			if err := expandRepositoryAliases(cmd, cfg, args); err != nil {
+				fmt.Println("Error:", err)
+				os.Exit(exitcode.Error)
+			}
+			if err := applyHostSettings(cfg, settings); err != nil {
+				fmt.Println("Error:", err)
+				os.Exit(exitcode.Error)
+			}
+			cliPlanOutput, _ := cmd.Flags().GetString("plan-output")
+			if planFormat, err = operations.ParsePlanFormat(cliPlanOutput); err != nil {
+				fmt.Println("Error:", err)
+				os.Exit(exitcode.Error)
+			}
+			// A JSON plan on stdout is kept parseable by sending the rest of a dry run to stderr
+			var out io.Writer = os.Stdout
+			if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun && planFormat == operations.PlanJSON {
+				out = os.Stderr
 			}
 			if quietMode {
 				logger = util.NewLogger(io.Discard)
-			} else if verboseMode {
-				logger = util.NewVerboseLogger(os.Stdout)
 			} else {
-				logger = util.NewLogger(os.Stdout)
+				logger = util.NewLoggerWithVerbosity(out, int(verbosity))
 			}
 			uploadOpts.Logger = logger
 			uploadOpts.QuietMode = quietMode
+			uploadOpts.PlanFormat = planFormat
 			downloadOpts.Logger = logger
 			downloadOpts.QuietMode = quietMode
+			downloadOpts.PlanFormat = planFormat
+		},
+		PersistentPostRun: func(cmd *cobra.Command, args []string) {
+			if requestMetrics != nil {
+				if breakdown := requestMetrics.String(); breakdown != "" {
+					logger.Println(breakdown)
+				}
+				if throttled := nexusapi.ThrottleSummary(); throttled != "" {
+					logger.Println(throttled)
+				}
+			}
 		},
 	}
 
 	rootCmd.PersistentFlags().String("url", "", "URL to Nexus server (defaults to NEXUS_URL env var or 'http://localhost:8081')")
-	rootCmd.PersistentFlags().String("username", "", "Username for Nexus authentication (defaults to NEXUS_USER env var or 'admin')")
-	rootCmd.PersistentFlags().String("password", "", "Password for Nexus authentication (defaults to NEXUS_PASS env var or 'admin')")
+	rootCmd.PersistentFlags().String("username", "", "Username for Nexus authentication (defaults to NEXUS_USER env var, credentials stored by login, or 'admin')")
+	rootCmd.PersistentFlags().String("password", "", "Password for Nexus authentication (defaults to NEXUS_PASS env var, credentials stored by login, or 'admin')")
+	rootCmd.PersistentFlags().String("unix-socket", "", "Connect to Nexus through this Unix domain socket, e.g. a local proxy; the URL still sets the host and paths (defaults to NEXUS_UNIX_SOCKET env var)")
 	rootCmd.PersistentFlags().BoolP("quiet", "q", false, "Suppress all output")
-	rootCmd.PersistentFlags().BoolP("verbose", "v", false, "Enable verbose output")
+	addVerboseFlag(rootCmd.PersistentFlags(), &verbosity)
+	rootCmd.PersistentFlags().String("plan-output", "text", "How --dry-run prints the planned actions: text or json (json is printed to stdout, other output to stderr)")
+	rootCmd.PersistentFlags().StringArray("repository-alias", nil, "Use name as a short name for a repository in any repository path, given as name=repository (repeatable; adds to the [aliases] of the config file)")
 
 	var uploadCmd = &cobra.Command{
-		Use:   "upload <src> <dest>",
-		Short: "Upload a directory to Nexus RAW",
-		Long:  "Upload a directory to Nexus RAW\n\nExit codes:\n  0 - Success\n  1 - General error",
-		Args:  cobra.ExactArgs(2),
+		Use:         "upload <src> <dest>",
+		Annotations: map[string]string{repositoryArgsAnnotation: "1"},
+		Short:       "Upload a directory to Nexus RAW",
+		Long:        "Upload a directory to Nexus RAW\n\nWith --touch repository/path, an empty marker asset is uploaded instead and no arguments are taken.\n\n" + exitcode.Help("upload"),
+		Args: func(cmd *cobra.Command, args []string) error {
+			if uploadTouch != "" {
+				return cobra.NoArgs(cmd, args)
+			}
+			return cobra.ExactArgs(2)(cmd, args)
+		},
 		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 			if len(args) == 0 {
 				return nil, cobra.ShellCompDirectiveDefault | cobra.ShellCompDirectiveFilterDirs
 			}
 			if len(args) == 1 {
+				completionAliases(cmd)
 				repo, pathPrefix := parseRepoAndPath(toComplete)
 				if !strings.Contains(toComplete, "/") {
 					completions := getRepositoryCompletions(cfg, repo)
@@ -398,21 +1684,7 @@ func buildRootCommand() *cobra.Command {
 					}
 					return completions, cobra.ShellCompDirectiveNoSpace | cobra.ShellCompDirectiveNoFileComp
 				}
-				completions := getPathCompletions(cfg, repo, pathPrefix)
-				for i := range completions {
-					completions[i] = path.Join(repo, completions[i])
-				}
-				hasDir := false
-				for _, comp := range completions {
-					if strings.HasSuffix(comp, "/") {
-						hasDir = true
-						break
-					}
-				}
-				if hasDir {
-					return completions, cobra.ShellCompDirectiveNoSpace | cobra.ShellCompDirectiveNoFileComp
-				}
-				return completions, cobra.ShellCompDirectiveNoFileComp
+				return getFolderCompletions(cfg, repo, pathPrefix), cobra.ShellCompDirectiveNoSpace | cobra.ShellCompDirectiveNoFileComp
 			}
 			return nil, cobra.ShellCompDirectiveNoFileComp
 		},
@@ -421,37 +1693,189 @@ func buildRootCommand() *cobra.Command {
 				format, err := archive.Parse(uploadCompressionFormat)
 				if err != nil {
 					fmt.Println(err)
-					os.Exit(1)
+					os.Exit(exitcode.Error)
 				}
 				uploadOpts.CompressionFormat = format
 			}
-			src := args[0]
-			dest := args[1]
+			flattenOnConflict, err := operations.ParseFlattenConflictMode(uploadFlattenOnConflict)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(exitcode.Error)
+			}
+			uploadOpts.FlattenOnConflict = flattenOnConflict
+			onDeniedExt, err := operations.ParseDeniedExtensionPolicy(uploadOnDeniedExt)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(exitcode.Error)
+			}
+			uploadOpts.OnDeniedExt = onDeniedExt
+			if err := applyContentTypes(uploadOpts, uploadContentTypeMap, uploadContentTypes); err != nil {
+				fmt.Println("Error:", err)
+				os.Exit(exitcode.Error)
+			}
+			for _, attr := range uploadAttributes {
+				if err := uploadOpts.SetAttribute(attr); err != nil {
+					fmt.Println("Error: --attr:", err)
+					os.Exit(exitcode.Error)
+				}
+			}
+			if uploadOpts.BuildnumStart < 0 {
+				fmt.Println("Error: --buildnum-start must not be negative")
+				os.Exit(exitcode.Error)
+			}
+			if err := applyUploadBatchLimits(cmd, settings, uploadOpts, uploadMaxRequestBytes); err != nil {
+				fmt.Println("Error:", err)
+				os.Exit(exitcode.Error)
+			}
+			if uploadOpts.WatchInterval > 0 && !uploadOpts.Watch {
+				fmt.Println("Error: --watch-interval requires --watch")
+				os.Exit(exitcode.Error)
+			}
+			if err := uploadOpts.SetGlobPattern(uploadGlobPattern); err != nil {
+				fmt.Println(err)
+				os.Exit(exitcode.Error)
+			}
+			for _, route := range uploadRoutes {
+				if err := uploadOpts.AddRoute(route); err != nil {
+					fmt.Println(err)
+					os.Exit(exitcode.Error)
+				}
+			}
+			if uploadOpts.Append {
+				// Appending always writes a compressed archive
+				uploadOpts.Compress = true
+			}
+			if len(uploadOpts.Routes) > 0 && uploadOpts.Compress {
+				fmt.Println("Error: --route cannot be combined with --compress")
+				os.Exit(exitcode.Error)
+			}
+			if uploadOpts.PreserveMtime && uploadOpts.Compress {
+				fmt.Println("Error: --preserve-mtime cannot be combined with --compress")
+				os.Exit(exitcode.Error)
+			}
+			if uploadOpts.Manifest && !uploadOpts.Compress {
+				fmt.Println("Error: --manifest requires --compress")
+				os.Exit(exitcode.Error)
+			}
+			if uploadChunked != "" {
+				n, err := util.ParseByteSize(uploadChunked)
+				if err != nil {
+					fmt.Println("Error: --chunked:", err)
+					os.Exit(exitcode.Error)
+				}
+				if n <= 0 {
+					fmt.Println("Error: --chunked must be a positive size")
+					os.Exit(exitcode.Error)
+				}
+				if uploadOpts.Compress {
+					fmt.Println("Error: --chunked cannot be combined with --compress")
+					os.Exit(exitcode.Error)
+				}
+				uploadOpts.ChunkSize = n
+			}
+			if uploadOpts.Offline && !uploadOpts.DryRun {
+				fmt.Println("Error: --offline requires --dry-run")
+				os.Exit(exitcode.Error)
+			}
+			if len(uploadOpts.Attributes) > 0 && (uploadOpts.Append || uploadTouch != "") {
+				fmt.Println("Error: --attr cannot be combined with --append or --touch")
+				os.Exit(exitcode.Error)
+			}
+			if uploadTouch != "" && (uploadOpts.Compress || uploadOpts.Watch || uploadOpts.PreserveMtime || uploadOpts.ChunkSize > 0 || len(uploadOpts.Routes) > 0) {
+				fmt.Println("Error: --touch cannot be combined with --compress, --append, --watch, --preserve-mtime, --chunked or --route")
+				os.Exit(exitcode.Error)
+			}
+			if uploadQueue {
+				if uploadOpts.Watch || uploadTouch != "" || len(uploadOpts.Routes) > 0 || uploadOpts.Append || uploadOpts.Flatten || uploadOpts.PreserveMtime {
+					fmt.Println("Error: --queue cannot be combined with --watch, --touch, --route, --append, --flatten or --preserve-mtime")
+					os.Exit(exitcode.Error)
+				}
+				dir, err := queueDir(uploadQueueDir)
+				if err != nil {
+					fmt.Println("Error:", err)
+					os.Exit(exitcode.Error)
+				}
+				uploadOpts.Queue = dir
+			}
+			checksumSource := applyChecksumDefault(cmd, settings, &uploadChecksumAlg)
 			if !uploadOpts.SkipChecksum && uploadChecksumAlg != "" {
 				if err := uploadOpts.SetChecksumAlgorithm(uploadChecksumAlg); err != nil {
 					fmt.Println(err)
-					os.Exit(1)
+					os.Exit(exitcode.Error)
 				}
 			}
-			operations.UploadMain(src, dest, cfg, uploadOpts)
+			compare, err := operations.ParseCompareMode(uploadCompare)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(exitcode.Error)
+			}
+			if compare == operations.CompareChecksum && uploadOpts.SkipChecksum {
+				fmt.Println("Error: --compare checksum cannot be combined with --skip-checksum")
+				os.Exit(exitcode.Error)
+			}
+			uploadOpts.Compare = compare
+			if uploadExplain && !quietMode {
+				printExplain(cmd.OutOrStdout(), uploadExplainEntries(cmd, cfg, settings, uploadOpts, uploadChecksumAlg, checksumSource))
+			}
+			if uploadTouch != "" {
+				operations.TouchMain(uploadTouch, cfg, uploadOpts)
+				return
+			}
+			operations.UploadMain(args[0], args[1], cfg, uploadOpts)
 		},
 	}
 	uploadCmd.Flags().BoolVarP(&uploadOpts.Compress, "compress", "z", false, "Create and upload files as a compressed archive")
+	uploadCmd.Flags().BoolVar(&uploadOpts.Append, "append", false, "Merge the files into the existing archive in dest instead of replacing it (implies --compress)")
+	uploadCmd.Flags().BoolVar(&uploadOpts.Manifest, "manifest", false, "With --compress, also upload <archive>"+operations.ArchiveManifestSuffix+" listing each file in the archive with its checksum")
 	uploadCmd.Flags().StringVar(&uploadCompressionFormat, "compress-format", "", "Compression format to use: gzip (default), zstd, or zip")
-	uploadCmd.Flags().StringVarP(&uploadOpts.GlobPattern, "glob", "g", "", "Glob pattern(s) to filter files (e.g., '**/*.go', '**/*.go,**/*.md', '**/*.go,!**/*_test.go')")
-	uploadCmd.Flags().StringVar(&uploadOpts.KeyFromFile, "key-from", "", "Path to file to compute hash from for {key} template in dest")
-	uploadCmd.Flags().StringVarP(&uploadChecksumAlg, "checksum", "c", "sha1", "Checksum algorithm to use for validation (sha1, sha256, sha512, md5)")
+	uploadCmd.Flags().StringVarP(&uploadGlobPattern, "glob", "g", "", "Glob pattern(s) to filter files (e.g., '**/*.go', '**/*.go,**/*.md', '**/*.go,!**/*_test.go')")
+	uploadCmd.Flags().BoolVar(&uploadOpts.GlobDebug, "glob-debug", false, "Log which glob pattern included or excluded each file")
+	uploadCmd.Flags().StringVar(&uploadOpts.KeyFromFile, "key-from", "", "Path to file or directory to compute hash from for {key} template in dest")
+	uploadCmd.Flags().IntVar(&uploadOpts.BuildnumStart, "buildnum-start", 1, "Build number to use for {buildnum} in dest when no numbered folder exists yet")
+	uploadCmd.Flags().StringVarP(&uploadChecksumAlg, "checksum", "c", "sha1", "Checksum algorithm to use for validation (sha1, sha256, sha512, md5); defaults to NEXUS_CHECKSUM or the config file")
 	uploadCmd.Flags().BoolVarP(&uploadOpts.SkipChecksum, "skip-checksum", "s", false, "Skip checksum validation and upload files based on file existence")
-	uploadCmd.Flags().BoolVar(&uploadOpts.Force, "force", false, "Force upload all files regardless of existence or checksum match")
+	uploadCmd.Flags().StringVar(&uploadCompare, "compare", "", "How to decide an existing asset is up to date: existence, size or checksum (default: checksum, or existence with --skip-checksum); checksum only hashes files whose size matches")
+	uploadCmd.Flags().BoolVar(&uploadOpts.Force, "force", false, "Force upload all files regardless of existence or checksum match, even below a path that is an existing file")
+	uploadCmd.Flags().BoolVar(&uploadOpts.PreserveMtime, "preserve-mtime", false, "Upload a "+operations.MetadataManifestName+" manifest recording the modification time and mode of each file")
+	uploadCmd.Flags().BoolVar(&uploadOpts.SkipWriteCheck, "skip-write-check", false, "Upload without first checking that the repository is online and accepts uploads")
+	uploadCmd.Flags().BoolVar(&uploadOpts.DedupeCheck, "dedupe-check", false, "Search the repository by SHA-256 for the content of each file to upload, to skip files already at their path and log duplicates at other paths")
+	uploadCmd.Flags().StringVar(&uploadOpts.DedupeReport, "dedupe-report", "", "Write a JSON report of the files whose content already exists at other paths in the repository to this file (implies --dedupe-check)")
+	uploadCmd.Flags().DurationVar(&uploadOpts.WaitForWritable, "wait-for-writable", 0, "Wait up to this long for Nexus to leave read-only mode before uploading (e.g. 15m)")
 	uploadCmd.Flags().BoolVarP(&uploadOpts.DryRun, "dry-run", "n", false, "Perform a dry-run without actually uploading files")
+	uploadCmd.Flags().BoolVar(&uploadExplain, "explain", false, "Print the effective configuration and where each value came from (flag, env, file or default) before running; useful with --dry-run")
+	uploadCmd.Flags().BoolVar(&uploadOpts.Offline, "offline", false, "With --dry-run, do not contact Nexus and list all files as candidates without checking what already exists")
+	uploadCmd.Flags().BoolVar(&uploadOpts.Strict, "strict", false, "Fail the upload if any file cannot be read instead of skipping it with a warning")
+	uploadCmd.Flags().BoolVarP(&uploadOpts.Flatten, "flatten", "f", false, "Upload all files directly into the destination without preserving local subdirectories")
+	uploadCmd.Flags().StringVar(&uploadFlattenOnConflict, "flatten-on-conflict", "error", "How to handle files that flatten onto the same path: error, rename (alias: suffix) or skip (alias: --flatten-collision)")
+	uploadCmd.Flags().StringVar(&uploadFlattenOnConflict, "flatten-collision", "error", "Alias for --flatten-on-conflict")
+	uploadCmd.Flags().MarkHidden("flatten-collision")
+	uploadCmd.Flags().StringSliceVar(&uploadOpts.AllowExtensions, "allow-ext", nil, "Only upload files with these extensions (repeatable or comma-separated, e.g. .jar,.pom)")
+	uploadCmd.Flags().StringSliceVar(&uploadOpts.DenyExtensions, "deny-ext", nil, "Never upload files with these extensions (repeatable or comma-separated, e.g. .pem,.key,.env)")
+	uploadCmd.Flags().StringVar(&uploadOnDeniedExt, "on-denied-ext", "abort", "What to do with files rejected by --allow-ext/--deny-ext: abort or skip")
+	uploadCmd.Flags().StringArrayVar(&uploadContentTypes, "content-type", nil, "Send files with an extension as this Content-Type, as 'ext=type' (repeatable, e.g. svg=image/svg+xml); overrides --content-type-map")
+	uploadCmd.Flags().StringArrayVar(&uploadAttributes, "attr", nil, "Store an attribute with every uploaded file, as 'key=value' (repeatable); shown by stat")
+	uploadCmd.Flags().StringVar(&uploadContentTypeMap, "content-type-map", "", "File mapping extensions to Content-Types, one 'ext=type' per line or a JSON object; unmapped files are detected by Nexus")
+	uploadCmd.Flags().StringArrayVar(&uploadRoutes, "route", nil, "Upload files matching a pattern to another destination, as 'pattern=repository[/folder]' (repeatable, first match wins, unmatched files go to dest)")
+	uploadCmd.Flags().SetAnnotation("route", repositoryFlagAnnotation, []string{"route"})
+	uploadCmd.Flags().IntVar(&uploadOpts.BatchSize, "batch-size", 0, "Maximum number of files per upload request (0 = no limit, default from the config file)")
+	uploadCmd.Flags().StringVar(&uploadMaxRequestBytes, "max-request-bytes", "", "Maximum file content per upload request, e.g. 512M; larger files are sent alone (default from the config file)")
+	uploadCmd.Flags().StringVar(&uploadChunked, "chunked", "", "Upload files larger than this size in resumable parts of this size, e.g. 1G")
+	uploadCmd.Flags().BoolVar(&uploadQueue, "queue", false, "If Nexus cannot be reached, record the upload in the local queue instead of failing; upload it later with 'queue flush'")
+	uploadCmd.Flags().StringVar(&uploadQueueDir, "queue-dir", "", "Directory of the upload queue (default $XDG_CONFIG_HOME/nexuscli/queue)")
+	uploadCmd.Flags().StringVar(&uploadTouch, "touch", "", "Upload an empty marker asset to repository/path instead of a directory, e.g. my-repo/builds/42/BUILD_SUCCESS")
+	uploadCmd.Flags().SetAnnotation("touch", repositoryFlagAnnotation, nil)
+	uploadCmd.Flags().BoolVar(&uploadOpts.Watch, "watch", false, "Keep running and re-upload changed files whenever the source directory changes")
+	uploadCmd.Flags().DurationVar(&uploadOpts.WatchInterval, "watch-interval", 0, "Poll the source directory at this interval instead of using filesystem notifications (e.g. 2s, for NFS)")
 
 	var downloadCmd = &cobra.Command{
-		Use:   "download <src> <dest>",
-		Short: "Download a folder from Nexus RAW",
-		Long:  "Download a folder from Nexus RAW\n\nExit codes:\n  0  - Success\n  1  - General error\n  66 - No files found",
-		Args:  cobra.ExactArgs(2),
+		Use:         "download <src> <dest>",
+		Annotations: map[string]string{repositoryArgsAnnotation: "0"},
+		Short:       "Download a folder from Nexus RAW",
+		Long:        "Download a folder from Nexus RAW\n\nWhen <src> is a single file and <dest> is not an existing directory or a path ending in /, the file is written to <dest> itself.\n\n<src> may contain glob patterns, e.g. 'repo/builds/2024-*/logs', to download every matching folder (with --recursive) or file under its own name.\n\nWith --to-archive, <dest> is omitted and the files are written into a single local archive instead.\n\n" + exitcode.Help("download"),
+		Args:        cobra.RangeArgs(1, 2),
 		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 			if len(args) == 0 {
+				completionAliases(cmd)
 				repo, pathPrefix := parseRepoAndPath(toComplete)
 				if !strings.Contains(toComplete, "/") {
 					completions := getRepositoryCompletions(cfg, repo)
@@ -486,37 +1910,534 @@ func buildRootCommand() *cobra.Command {
 				format, err := archive.Parse(downloadCompressionFormat)
 				if err != nil {
 					fmt.Println(err)
-					os.Exit(1)
+					os.Exit(exitcode.Error)
 				}
 				downloadOpts.CompressionFormat = format
 			}
+			flattenOnConflict, err := operations.ParseFlattenConflictMode(downloadFlattenOnConflict)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(exitcode.Error)
+			}
+			downloadOpts.FlattenOnConflict = flattenOnConflict
+			if err := downloadOpts.SetGlobPattern(downloadGlobPattern); err != nil {
+				fmt.Println(err)
+				os.Exit(exitcode.Error)
+			}
+			onConflict, err := operations.ParseConflictPolicy(downloadOnConflict)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(exitcode.Error)
+			}
+			downloadOpts.OnConflict = onConflict
+			onNonEmpty, err := operations.ParseNonEmptyPolicy(downloadOnNonEmpty)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(exitcode.Error)
+			}
+			downloadOpts.OnNonEmpty = onNonEmpty
+			verify, err := operations.ParseVerifyLevel(downloadVerify)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(exitcode.Error)
+			}
+			downloadOpts.Verify = verify
+			onMissingChecksum, err := operations.ParseMissingChecksumPolicy(downloadOnMissingChecksum)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(exitcode.Error)
+			}
+			if downloadManifestOptional {
+				if cmd.Flags().Changed("on-missing-checksum") {
+					fmt.Println("Error: --manifest-optional cannot be combined with --on-missing-checksum")
+					os.Exit(exitcode.Error)
+				}
+				if downloadOpts.SkipChecksum || verify == operations.VerifyNone {
+					fmt.Println("Error: --manifest-optional falls back to the checksums reported by Nexus and cannot be combined with --skip-checksum or --verify none")
+					os.Exit(exitcode.Error)
+				}
+				onMissingChecksum = operations.MissingChecksumNexus
+			}
+			downloadOpts.OnMissingChecksum = onMissingChecksum
+			compare, err := operations.ParseCompareMode(downloadCompare)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(exitcode.Error)
+			}
+			if compare == operations.CompareChecksum && downloadOpts.SkipChecksum {
+				fmt.Println("Error: --compare checksum cannot be combined with --skip-checksum")
+				os.Exit(exitcode.Error)
+			}
+			downloadOpts.Compare = compare
+			sortKey, err := operations.ParseSortKey(downloadSort)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(exitcode.Error)
+			}
+			downloadOpts.Sort = sortKey
+			direction, err := operations.ParseSortDirection(downloadDirection)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(exitcode.Error)
+			}
+			downloadOpts.Direction = direction
+			selectMode, err := util.ParseSelectMode(downloadSelect)
+			if err != nil {
+				fmt.Println("Error:", err)
+				os.Exit(exitcode.Error)
+			}
+			downloadOpts.Select = selectMode
+			if downloadOpts.Depth < 0 {
+				fmt.Println("Error: --depth must not be negative")
+				os.Exit(exitcode.Error)
+			}
+			if downloadOpts.Limit < 0 {
+				fmt.Println("Error: --limit must not be negative")
+				os.Exit(exitcode.Error)
+			}
+			if downloadOpts.MinFiles < 0 {
+				fmt.Println("Error: --min-files must not be negative")
+				os.Exit(exitcode.Error)
+			}
+			if downloadOpts.MinFiles > 0 && downloadOpts.Compress {
+				fmt.Println("Error: --min-files cannot be combined with --compress")
+				os.Exit(exitcode.Error)
+			}
+			if downloadMaxTotalSize != "" {
+				n, err := util.ParseByteSize(downloadMaxTotalSize)
+				if err != nil {
+					fmt.Println("Error: --max-total-size:", err)
+					os.Exit(exitcode.Error)
+				}
+				if downloadOpts.Compress {
+					fmt.Println("Error: --max-total-size cannot be combined with --compress")
+					os.Exit(exitcode.Error)
+				}
+				downloadOpts.MaxTotalSize = n
+			}
+			if downloadOpts.DirMode, err = util.ParseDirMode(downloadDirMode); err != nil {
+				fmt.Println("Error: --dir-mode:", err)
+				os.Exit(exitcode.Error)
+			}
+			if downloadOpts.PreserveMtime && downloadOpts.Compress {
+				fmt.Println("Error: --preserve-mtime cannot be combined with --compress")
+				os.Exit(exitcode.Error)
+			}
+			if downloadOpts.ChecksumFile != "" && downloadOpts.Compress {
+				fmt.Println("Error: --checksum-from-file cannot be combined with --compress")
+				os.Exit(exitcode.Error)
+			}
+			if downloadOpts.ChecksumManifest != "" {
+				if downloadOpts.ChecksumFile != "" {
+					fmt.Println("Error: --checksum-manifest cannot be combined with --checksum-from-file")
+					os.Exit(exitcode.Error)
+				}
+				if downloadOpts.Compress {
+					fmt.Println("Error: --checksum-manifest cannot be combined with --compress")
+					os.Exit(exitcode.Error)
+				}
+				if _, _, ok := util.ParseRepositoryPath(downloadOpts.ChecksumManifest); !ok {
+					fmt.Println("Error: --checksum-manifest must be given as repository/path")
+					os.Exit(exitcode.Error)
+				}
+			}
+			if downloadManifestOptional && downloadOpts.ChecksumFile == "" && downloadOpts.ChecksumManifest == "" {
+				fmt.Println("Error: --manifest-optional requires --checksum-manifest or --checksum-from-file")
+				os.Exit(exitcode.Error)
+			}
+			if downloadOpts.Chunked && (downloadOpts.Compress || downloadOpts.ToArchive != "") {
+				fmt.Println("Error: --chunked cannot be combined with --compress or --to-archive")
+				os.Exit(exitcode.Error)
+			}
+			if downloadOpts.Resume && (downloadOpts.Compress || downloadOpts.ToArchive != "") {
+				fmt.Println("Error: --resume cannot be combined with --compress or --to-archive")
+				os.Exit(exitcode.Error)
+			}
+			if downloadOpts.VerifySignature != (downloadOpts.PublicKeyFile != "") {
+				fmt.Println("Error: --verify-signature and --pubkey must be given together")
+				os.Exit(exitcode.Error)
+			}
+			if downloadOpts.VerifySignature && (downloadOpts.Compress || downloadOpts.ToArchive != "") {
+				fmt.Println("Error: --verify-signature cannot be combined with --compress or --to-archive")
+				os.Exit(exitcode.Error)
+			}
+			if downloadOpts.ToArchive != "" {
+				if len(args) != 1 {
+					fmt.Println("Error: --to-archive replaces the <dest> argument")
+					os.Exit(exitcode.Error)
+				}
+				if downloadOpts.Compress || downloadOpts.DeleteExtra || downloadOpts.PreserveMtime || downloadOpts.TreeChecksum {
+					fmt.Println("Error: --to-archive cannot be combined with --compress, --delete, --preserve-mtime or --tree-checksum")
+					os.Exit(exitcode.Error)
+				}
+			} else if len(args) != 2 {
+				fmt.Println("Error: download requires <src> and <dest> arguments")
+				os.Exit(exitcode.Error)
+			}
 			src := args[0]
-			dest := args[1]
+			dest := ""
+			if len(args) == 2 {
+				dest = args[1]
+			}
+			downloadOpts.FileDestination = true
+			checksumSource := applyChecksumDefault(cmd, settings, &downloadChecksumAlg)
 			if err := downloadOpts.SetChecksumAlgorithm(downloadChecksumAlg); err != nil {
 				fmt.Println(err)
-				os.Exit(1)
+				os.Exit(exitcode.Error)
+			}
+			if downloadMaxRate != "" {
+				maxRate, err := util.ParseByteSize(downloadMaxRate)
+				if err != nil {
+					fmt.Println(err)
+					os.Exit(exitcode.Error)
+				}
+				downloadOpts.MaxRate = maxRate
+			}
+			if downloadExplain && !quietMode {
+				printExplain(cmd.OutOrStdout(), downloadExplainEntries(cmd, cfg, downloadOpts, downloadChecksumAlg, checksumSource))
+			}
+			finishMetrics, err := startMetrics(cmd, metricsFile, metricsListen)
+			if err != nil {
+				fmt.Println("Error:", err)
+				os.Exit(exitcode.Error)
+			}
+			status := operations.Download(src, dest, cfg, downloadOpts)
+			if err := finishMetrics(status == operations.DownloadSuccess); err != nil {
+				fmt.Println("Error:", err)
+				os.Exit(exitcode.Error)
+			}
+			if status != operations.DownloadSuccess {
+				os.Exit(int(status))
 			}
-			operations.DownloadMain(src, dest, cfg, downloadOpts)
 		},
 	}
-	downloadCmd.Flags().StringVarP(&downloadChecksumAlg, "checksum", "c", "sha1", "Checksum algorithm to use for validation (sha1, sha256, sha512, md5)")
+	downloadCmd.Flags().StringVarP(&downloadChecksumAlg, "checksum", "c", "sha1", "Checksum algorithm to use for validation (sha1, sha256, sha512, md5); defaults to NEXUS_CHECKSUM or the config file")
 	downloadCmd.Flags().BoolVarP(&downloadOpts.SkipChecksum, "skip-checksum", "s", false, "Skip checksum validation and download files based on file existence")
+	downloadCmd.Flags().StringVar(&downloadCompare, "compare", "", "How to decide an existing local file is up to date: existence, size or checksum (default: checksum, or existence with --skip-checksum)")
+	downloadCmd.Flags().StringVar(&downloadVerify, "verify", "checksum", "How to verify downloaded files: checksum, size (compare file size only, no hashing) or none")
+	downloadCmd.Flags().StringVar(&downloadOpts.ChecksumFile, "checksum-from-file", "", "Validate downloaded files against a sha256sum-style checksums file, given as a local path or repository/path")
+	downloadCmd.Flags().StringVar(&downloadOpts.ChecksumManifest, "checksum-manifest", "", "Fetch a sha256sum-style manifest from Nexus (repository/path) first and validate downloaded files against it")
+	downloadCmd.Flags().SetAnnotation("checksum-manifest", repositoryFlagAnnotation, nil)
+	downloadCmd.Flags().StringVar(&downloadOnMissingChecksum, "on-missing-checksum", "error", "What to do with downloaded files not listed in --checksum-from-file or --checksum-manifest: error, warn or nexus")
+	downloadCmd.Flags().BoolVar(&downloadManifestOptional, "manifest-optional", false, "Verify files not listed in the checksum manifest against the checksums reported by Nexus instead of failing them (same as --on-missing-checksum nexus)")
+	downloadCmd.Flags().BoolVar(&downloadOpts.VerifySignature, "verify-signature", false, "Verify every downloaded file against its detached OpenPGP signature (<file>.asc) in Nexus; fails on a missing or invalid signature")
+	downloadCmd.Flags().StringVar(&downloadOpts.PublicKeyFile, "pubkey", "", "OpenPGP public key file (armored or binary) to verify signatures with --verify-signature")
 	downloadCmd.Flags().BoolVarP(&downloadOpts.Flatten, "flatten", "f", false, "Download files without preserving the base path specified in the source argument")
+	downloadCmd.Flags().StringVar(&downloadFlattenOnConflict, "flatten-on-conflict", "error", "How to handle files that flatten onto the same path: error, rename (alias: suffix) or skip (alias: --flatten-collision)")
+	downloadCmd.Flags().StringVar(&downloadFlattenOnConflict, "flatten-collision", "error", "Alias for --flatten-on-conflict")
+	downloadCmd.Flags().MarkHidden("flatten-collision")
 	downloadCmd.Flags().BoolVar(&downloadOpts.DeleteExtra, "delete", false, "Remove local files from the destination folder that are not present in Nexus")
 	downloadCmd.Flags().BoolVarP(&downloadOpts.Compress, "compress", "z", false, "Download and extract a compressed archive")
 	downloadCmd.Flags().StringVar(&downloadCompressionFormat, "compress-format", "", "Compression format to use: gzip (default), zstd, or zip")
-	downloadCmd.Flags().StringVarP(&downloadOpts.GlobPattern, "glob", "g", "", "Glob pattern(s) to filter files (e.g., '**/*.go', '**/*.go,**/*.md', '**/*.go,!**/*_test.go')")
-	downloadCmd.Flags().StringVar(&downloadOpts.KeyFromFile, "key-from", "", "Path to file to compute hash from for {key} template in src")
+	downloadCmd.Flags().StringVar(&downloadOpts.ToArchive, "to-archive", "", "Stream the files into this local archive (.tar.gz, .tar.zst or .zip) instead of writing them to <dest>")
+	downloadCmd.Flags().StringVarP(&downloadGlobPattern, "glob", "g", "", "Glob pattern(s) to filter files (e.g., '**/*.go', '**/*.go,**/*.md', '**/*.go,!**/*_test.go')")
+	downloadCmd.Flags().BoolVar(&downloadOpts.GlobDebug, "glob-debug", false, "Log which glob pattern included or excluded each file")
+	downloadCmd.Flags().StringVar(&downloadOpts.KeyFromFile, "key-from", "", "Path to file or directory to compute hash from for {key} template in src")
 	downloadCmd.Flags().BoolVar(&downloadOpts.Force, "force", false, "Force download all files regardless of existence or checksum match")
 	downloadCmd.Flags().BoolVarP(&downloadOpts.DryRun, "dry-run", "n", false, "Perform a dry-run without actually downloading files")
+	downloadCmd.Flags().BoolVar(&downloadExplain, "explain", false, "Print the effective configuration and where each value came from (flag, env, file or default) before running; useful with --dry-run")
 	downloadCmd.Flags().BoolVarP(&downloadOpts.Recursive, "recursive", "r", false, "Download folder recursively (default: false for single file download)")
+	downloadCmd.Flags().IntVar(&downloadOpts.Depth, "depth", 0, "Only download files up to this many levels below the source folder (0 = unlimited); --depth 1 downloads a flat folder without --recursive")
+	downloadCmd.Flags().IntVar(&downloadOpts.Limit, "limit", 0, "Only download the first N files that pass the filters, in --sort-server order (0 = unlimited); --delete is ignored with a limit")
+	downloadCmd.Flags().IntVar(&downloadOpts.MinFiles, "min-files", 0, "Fail with exit code 65 if fewer than N files pass the filters, counted before --limit (0 = no minimum; no files at all still exits 66)")
+	downloadCmd.Flags().BoolVar(&downloadOpts.PreserveMtime, "preserve-mtime", false, "Restore modification times and modes recorded by upload --preserve-mtime")
+	downloadCmd.Flags().BoolVar(&downloadOpts.NoSpaceCheck, "no-space-check", false, "Skip checking that the destination filesystem has room for the files to download")
+	downloadCmd.Flags().BoolVar(&downloadOpts.NoPathCheck, "no-path-check", false, "Skip checking that every local path is within the path and file name length limits of the system")
+	downloadCmd.Flags().BoolVar(&downloadOpts.LongPaths, "long-paths", false, "On Windows, write through \\\\?\\ paths so local paths may exceed 260 characters (no effect elsewhere)")
+	downloadCmd.Flags().IntVar(&downloadOpts.Concurrency, "concurrency", 0, "Maximum number of parallel file downloads (0 = unlimited)")
+	downloadCmd.Flags().StringVar(&downloadMaxTotalSize, "max-total-size", "", "Fail before downloading or deleting anything if the matched files are larger than this in total (e.g., '2G')")
+	downloadCmd.Flags().StringVar(&downloadMaxRate, "max-rate", "", "Maximum combined download rate (e.g., '512K', '10M')")
+	downloadCmd.Flags().StringVar(&downloadOnConflict, "on-conflict", "overwrite", "How to handle local files that differ from Nexus: overwrite, backup, skip, or fail")
+	downloadCmd.Flags().StringVar(&downloadOnNonEmpty, "on-nonempty", "merge", "What to do if the destination folder is not empty: fail, merge, or clean (remove its content first, after confirmation)")
+	downloadCmd.Flags().BoolVarP(&downloadOpts.Yes, "yes", "y", false, "Clean the destination for --on-nonempty clean without asking for confirmation")
+	downloadCmd.Flags().StringVar(&downloadSort, "sort-server", "", "Order in which assets are listed and downloaded: name, version, group or repository (sorted by Nexus), modified or size (sorted locally)")
+	downloadCmd.Flags().StringVar(&downloadDirection, "direction", "asc", "Sort direction for --sort-server: asc or desc")
+	downloadCmd.Flags().StringVar(&downloadSelect, "select", "semver", "How {latest} in the source path picks a folder or file name: semver, lexical or mtime")
+	downloadCmd.Flags().BoolVar(&downloadOpts.CheckOnline, "repository-online-check", false, "Check repository status before listing and skip offline members of a group repository")
+	downloadCmd.Flags().DurationVar(&downloadOpts.WaitForAvailable, "wait-for-available", 0, "Wait up to this long for Nexus to be available before downloading (e.g. 5m)")
+	downloadCmd.Flags().DurationVar(&downloadOpts.WaitLock, "wait-lock", 0, "Wait up to this long for another nexuscli-go process operating on the destination to finish (e.g. 10m), instead of failing at once")
+	downloadCmd.Flags().BoolVar(&downloadOpts.Chunked, "chunked", false, "Reassemble files uploaded with --chunked from their parts, resuming from parts already downloaded")
+	downloadCmd.Flags().BoolVar(&downloadOpts.Resume, "resume", false, "Keep the partial file of a failed download and resume it on the next run, fetching only the blocks that fail verification again")
+	addMetricsFlags(downloadCmd, &metricsFile, &metricsListen)
+	downloadCmd.Flags().StringVar(&downloadDirMode, "dir-mode", "", "Octal mode of the directories created for downloaded files, e.g. 2775 (default: 777 less the umask); new directories keep the setgid bit of their parent")
+	downloadCmd.Flags().StringVar(&downloadOpts.CacheDir, "cache-dir", "", "Shared cache directory; assets with a matching checksum are copied from it instead of downloaded")
+	downloadCmd.Flags().BoolVar(&downloadOpts.TreeChecksum, "tree-checksum", false, "Print a root checksum over the whole destination tree after download")
+
+	var mirrorOpts = &operations.MirrorOptions{}
+	var mirrorGlobPattern string
+	var mirrorSrcUsername, mirrorSrcPassword string
+	var mirrorDstUsername, mirrorDstPassword string
+	var mirrorCmd = &cobra.Command{
+		Use:   "mirror <src-url>/<repo>[/<path>] <dst-url>/<repo>[/<path>]",
+		Short: "Mirror assets from one Nexus repository to another",
+		Long:  "Copy missing or changed assets from a source repository to a destination repository, possibly on another Nexus instance.\nContent is streamed between the servers without touching the local disk.\n\nCredentials for each side default to the global --username and --password.",
+		Args:  cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			mirrorOpts.Logger = logger
+			mirrorOpts.QuietMode = quietMode
+			mirrorOpts.PlanFormat = planFormat
+			if err := mirrorOpts.SetGlobPattern(mirrorGlobPattern); err != nil {
+				fmt.Println(err)
+				os.Exit(exitcode.Error)
+			}
+			if mirrorSrcUsername == "" {
+				mirrorSrcUsername = cfg.Username
+			}
+			if mirrorSrcPassword == "" {
+				mirrorSrcPassword = cfg.Password
+			}
+			if mirrorDstUsername == "" {
+				mirrorDstUsername = cfg.Username
+			}
+			if mirrorDstPassword == "" {
+				mirrorDstPassword = cfg.Password
+			}
+			src, err := operations.ParseMirrorEndpoint(args[0], mirrorSrcUsername, mirrorSrcPassword, cfg, settings)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(exitcode.Error)
+			}
+			dst, err := operations.ParseMirrorEndpoint(args[1], mirrorDstUsername, mirrorDstPassword, cfg, settings)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(exitcode.Error)
+			}
+			finishMetrics, err := startMetrics(cmd, metricsFile, metricsListen)
+			if err != nil {
+				fmt.Println("Error:", err)
+				os.Exit(exitcode.Error)
+			}
+			ok := operations.Mirror(src, dst, mirrorOpts)
+			if err := finishMetrics(ok); err != nil {
+				fmt.Println("Error:", err)
+				os.Exit(exitcode.Error)
+			}
+			if !ok {
+				os.Exit(exitcode.Error)
+			}
+		},
+	}
+	mirrorCmd.Flags().BoolVar(&mirrorOpts.Delete, "delete", false, "Delete assets from the destination that are not present in the source")
+	mirrorCmd.Flags().BoolVarP(&mirrorOpts.DryRun, "dry-run", "n", false, "Show what would be copied or deleted without changing the destination")
+	mirrorCmd.Flags().StringVarP(&mirrorGlobPattern, "glob", "g", "", "Glob pattern(s) to filter assets (e.g., '**/*.bin', '**/*.bin,!**/debug/**')")
+	mirrorCmd.Flags().IntVar(&mirrorOpts.Concurrency, "concurrency", 0, "Maximum number of parallel copies (0 = unlimited)")
+	addMetricsFlags(mirrorCmd, &metricsFile, &metricsListen)
+	mirrorCmd.Flags().StringVar(&mirrorSrcUsername, "src-username", "", "Username for the source Nexus (defaults to --username)")
+	mirrorCmd.Flags().StringVar(&mirrorSrcPassword, "src-password", "", "Password for the source Nexus (defaults to --password)")
+	mirrorCmd.Flags().StringVar(&mirrorDstUsername, "dst-username", "", "Username for the destination Nexus (defaults to --username)")
+	mirrorCmd.Flags().StringVar(&mirrorDstPassword, "dst-password", "", "Password for the destination Nexus (defaults to --password)")
+
+	var moveOpts = &operations.MoveOptions{}
+	var moveCmd = &cobra.Command{
+		Use:         "mv <repository>/<src> <repository>/<dest>",
+		Annotations: map[string]string{repositoryArgsAnnotation: "0,1"},
+		Short:       "Move or rename a folder within a repository",
+		Long:        "Move or rename a file or folder within a repository, e.g. builds/2024.1 to releases/2024.1.\nEach asset is streamed to its new path and the original is deleted only once the copy has been verified,\nso an interrupted move leaves both copies behind and can be run again.",
+		Args:        cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			moveOpts.Logger = logger
+			moveOpts.QuietMode = quietMode
+			moveOpts.PlanFormat = planFormat
+			operations.MoveMain(args[0], args[1], cfg, moveOpts)
+		},
+	}
+	moveCmd.Flags().BoolVarP(&moveOpts.DryRun, "dry-run", "n", false, "Show what would be moved without changing the repository")
+	moveCmd.Flags().IntVar(&moveOpts.Concurrency, "concurrency", 0, "Maximum number of parallel copies (0 = unlimited)")
+
+	var copyOpts = &operations.CopyOptions{}
+	var copyCmd = &cobra.Command{
+		Use:         "copy <repository>/<src> <repository>/<dest>",
+		Annotations: map[string]string{repositoryArgsAnnotation: "0,1"},
+		Short:       "Copy a file or folder to another path or repository",
+		Long:        "Copy a file or folder to another path, in the same or another RAW repository on the same server.\nNexus has no server-side copy, so each asset is streamed from its download into an upload without touching the local disk.",
+		Args:        cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			copyOpts.Logger = logger
+			copyOpts.QuietMode = quietMode
+			copyOpts.PlanFormat = planFormat
+			operations.CopyMain(args[0], args[1], cfg, copyOpts)
+		},
+	}
+	copyCmd.Flags().BoolVarP(&copyOpts.DryRun, "dry-run", "n", false, "Show what would be copied without changing the repository")
+	copyCmd.Flags().IntVar(&copyOpts.Concurrency, "concurrency", 0, "Maximum number of parallel copies (0 = unlimited)")
+
+	var pruneOpts = &operations.PruneOptions{}
+	var pruneGlobPattern string
+	var pruneOlderThan string
+	var pruneCmd = &cobra.Command{
+		Use:         "prune <repository>/<path>",
+		Annotations: map[string]string{repositoryArgsAnnotation: "0"},
+		Short:       "Delete old builds from a repository path",
+		Long:        "Delete the files and subdirectories directly below a repository path that are older than --older-than, keeping at least the newest --keep-last of them.\nA subdirectory, e.g. one nightly build, is kept or deleted as a whole based on its most recently modified asset.\n\nThe assets to delete are listed and confirmed before anything is deleted, unless --yes is given.",
+		Args:        cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			pruneOpts.Logger = logger
+			pruneOpts.QuietMode = quietMode
+			pruneOpts.PlanFormat = planFormat
+			if err := pruneOpts.SetGlobPattern(pruneGlobPattern); err != nil {
+				fmt.Println(err)
+				os.Exit(exitcode.Error)
+			}
+			if pruneOlderThan != "" {
+				age, err := util.ParseAge(pruneOlderThan)
+				if err != nil {
+					fmt.Printf("Error: invalid --older-than: %v\n", err)
+					os.Exit(exitcode.Error)
+				}
+				pruneOpts.OlderThan = age
+			}
+			if pruneOpts.KeepLast < 0 {
+				fmt.Println("Error: --keep-last must not be negative")
+				os.Exit(exitcode.Error)
+			}
+			if pruneOpts.OlderThan == 0 && pruneOpts.KeepLast == 0 {
+				fmt.Println("Error: at least one of --older-than or --keep-last is required")
+				os.Exit(exitcode.Error)
+			}
+			operations.PruneMain(args[0], cfg, pruneOpts, os.Stdin)
+		},
+	}
+	pruneCmd.Flags().StringVar(&pruneOlderThan, "older-than", "", "Only delete entries last modified longer ago than this (e.g., '30d', '2w', '12h')")
+	pruneCmd.Flags().IntVar(&pruneOpts.KeepLast, "keep-last", 0, "Always keep the newest N files or subdirectories directly below the path")
+	pruneCmd.Flags().StringVarP(&pruneGlobPattern, "glob", "g", "", "Glob pattern(s) to select the assets considered (e.g., '**/*.bin', '**/*,!**/*.sig')")
+	pruneCmd.Flags().BoolVarP(&pruneOpts.Yes, "yes", "y", false, "Delete without asking for confirmation")
+	pruneCmd.Flags().BoolVarP(&pruneOpts.DryRun, "dry-run", "n", false, "Show what would be deleted without deleting anything")
+	pruneCmd.Flags().StringVar(&pruneOpts.ReportFile, "report", "", "Write a JSON report of the deleted assets to this file")
+
+	var treeOpts = &operations.TreeOptions{}
+	var treeCmd = &cobra.Command{
+		Use:         "tree <repository>[/<path>]",
+		Annotations: map[string]string{repositoryArgsAnnotation: "0"},
+		Short:       "Show the assets in a repository as a tree",
+		Long:        "List assets recursively and print them as an indented tree grouped by directory, with the number and total size of the files in each directory",
+		Args:        cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if treeOpts.Depth < 0 {
+				fmt.Println("Error: --depth must not be negative")
+				os.Exit(exitcode.Error)
+			}
+			treeOpts.Logger = logger
+			// Box-drawing characters are only used on a terminal
+			treeOpts.ASCII = treeOpts.ASCII || !util.IsATTY()
+			operations.TreeMain(args[0], cfg, treeOpts)
+		},
+	}
+	treeCmd.Flags().IntVarP(&treeOpts.Depth, "depth", "L", 0, "Maximum number of directory levels to show (0 = unlimited)")
+	treeCmd.Flags().BoolVar(&treeOpts.ASCII, "ascii", false, "Draw the tree with ASCII characters (default when output is not a terminal)")
+
+	var statOpts = &operations.StatOptions{}
+	var statCmd = &cobra.Command{
+		Use:         "stat <repository>/<path>",
+		Annotations: map[string]string{repositoryArgsAnnotation: "0"},
+		Short:       "Show the details and attributes of an asset",
+		Long:        "Show the size, content type, checksums and upload details of an asset, and the attributes stored for it with upload --attr",
+		Args:        cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			statOpts.Logger = logger
+			operations.StatMain(args[0], cfg, statOpts)
+		},
+	}
+
+	var serveOpts = &operations.ServeOptions{}
+	var serveCmd = &cobra.Command{
+		Use:   "serve <dir>",
+		Short: "Serve a downloaded directory as a read-only Nexus mirror",
+		Long:  "Serve a local directory over HTTP with the download and search endpoints of Nexus, so download and deps sync work against it with --url, e.g. on an air-gapped network. Every subdirectory of <dir> is served as a repository of the same name, or <dir> itself as the repository given with --repository.\n\nThe mirror is read-only: only GET and HEAD requests are answered. Clients authenticate with basic auth using the credentials given with --username and --password (or NEXUS_USER and NEXUS_PASS); the default credentials and those stored by login are never used, so serve refuses to start without them unless --no-auth is given. It listens on 127.0.0.1:8080 unless --addr says otherwise.",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			serveOpts.Logger = logger
+			serveOpts.Username, serveOpts.Password = serveCredentials(cfg)
+			operations.ServeMain(args[0], serveOpts)
+		},
+	}
+	serveCmd.Flags().StringVar(&serveOpts.Addr, "addr", "127.0.0.1:8080", "Address to listen on, e.g. ':8080' for all interfaces")
+	serveCmd.Flags().StringVar(&serveOpts.Repository, "repository", "", "Serve <dir> as this single repository instead of one repository per subdirectory")
+	serveCmd.Flags().BoolVar(&serveOpts.NoAuth, "no-auth", false, "Serve without requiring basic auth")
+
+	var repoCmd = &cobra.Command{
+		Use:   "repo",
+		Short: "Repository commands",
+		Long:  "Inspect the repositories of the Nexus server",
+	}
+
+	var repoListOpts = &operations.RepoListOptions{}
+	var repoListCmd = &cobra.Command{
+		Use:   "list",
+		Short: "List repositories",
+		Long:  "List the repositories of the Nexus server by name\n\nWith --long, also show the format, the type (hosted, proxy or group), whether the repository is online and its URL.",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			repoListOpts.Logger = logger
+			operations.RepoListMain(cfg, repoListOpts)
+		},
+	}
+	repoListCmd.Flags().BoolVarP(&repoListOpts.Long, "long", "l", false, "Show format, type, status and URL of each repository")
+	repoListCmd.Flags().StringVarP(&repoListOpts.Output, "output", "o", "text", "Output format: text or json (json always includes all details)")
+	repoCmd.AddCommand(repoListCmd)
+
+	var verifyTreeChecksum string
+	var verifyCmd = &cobra.Command{
+		Use:   "verify <dir>",
+		Short: "Verify a local directory against a tree checksum",
+		Long:  "Recompute the root checksum over all files in a local directory and compare it to an expected value. A checksum mismatch is a general error.\n\n" + exitcode.Help("verify"),
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return verifyTreeChecksumMain(args[0], verifyTreeChecksum, logger)
+		},
+	}
+	verifyCmd.Flags().StringVar(&verifyTreeChecksum, "tree-checksum", "", "Expected tree checksum in the form '<algorithm>:<hex>'")
+	verifyCmd.MarkFlagRequired("tree-checksum")
 
+	var versionOutput string
 	var versionCmd = &cobra.Command{
 		Use:   "version",
 		Short: "Print the version number",
 		Long:  "Print the version number of nexuscli-go",
-		Run: func(cmd *cobra.Command, args []string) {
-			fmt.Printf("nexuscli-go version %s\n", version)
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return versionMain(versionOutput)
+		},
+	}
+	versionCmd.Flags().StringVarP(&versionOutput, "output", "o", "text", "Output format: text or json (json includes commit, build date and Go version)")
+
+	var exitCodesOutput string
+	var exitCodesCmd = &cobra.Command{
+		Use:   "exit-codes",
+		Short: "List the exit codes",
+		Long:  "List the exit codes of nexuscli-go, the commands that can exit with each and what it means. The codes are stable, so scripts can rely on them. Also shown by 'nexuscli-go help exit-codes'.\n\n" + exitCodesTable(),
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return exitCodesMain(exitCodesOutput)
+		},
+	}
+	exitCodesCmd.Flags().StringVarP(&exitCodesOutput, "output", "o", "text", "Output format: text or json")
+
+	var loginCmd = &cobra.Command{
+		Use:   "login",
+		Short: "Store credentials for a Nexus server",
+		Long:  "Check credentials against the Nexus server given by --url (or NEXUS_URL) and store them for later commands.\nCredentials are kept per server URL in $XDG_CONFIG_HOME/nexuscli/credentials, readable only by you.\nThey are used when no --username/--password flags or NEXUS_USER/NEXUS_PASS variables are given.\n\nMissing values are prompted for; a password or token can also be piped on stdin.",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, err := config.DefaultCredentialStore()
+			if err != nil {
+				return err
+			}
+			username, _ := cmd.Flags().GetString("username")
+			password, _ := cmd.Flags().GetString("password")
+			return loginMain(cfg, store, username, password, os.Stdin, logger)
+		},
+	}
+
+	var logoutCmd = &cobra.Command{
+		Use:   "logout",
+		Short: "Remove stored credentials for a Nexus server",
+		Long:  "Remove the credentials stored by login for the Nexus server given by --url (or NEXUS_URL)",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			store, err := config.DefaultCredentialStore()
+			if err != nil {
+				return err
+			}
+			return logoutMain(cfg, store, logger)
 		},
 	}
 
@@ -535,25 +2456,86 @@ func buildRootCommand() *cobra.Command {
 		},
 	}
 
+	var depsLockFrozen bool
 	var depsLockCmd = &cobra.Command{
 		Use:   "lock",
 		Short: "Resolve and update deps-lock.ini from deps.ini",
-		Long:  "Resolve dependencies from Nexus and write checksums to deps-lock.ini",
-		Run: func(cmd *cobra.Command, args []string) {
-			depsLockMain(cfg, logger)
+		Long:  "Resolve dependencies from Nexus and write checksums to deps-lock.ini\n\nWith --frozen, deps-lock.ini is not modified; the command fails and prints the differences if it is out of date.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return depsLockMain(cfg, settings, logger, depsLockFrozen)
 		},
 	}
+	depsLockCmd.Flags().BoolVar(&depsLockFrozen, "frozen", false, "Check that deps-lock.ini is up to date without modifying it (alias: --check)")
+	depsLockCmd.Flags().BoolVar(&depsLockFrozen, "check", false, "Alias for --frozen")
+	depsLockCmd.Flags().MarkHidden("check")
 
 	var depsSyncNoCleanup bool
+	var depsSyncOnConflict string
+	var depsSyncKeepGoing bool
+	var depsSyncAllowMissing bool
+	var depsSyncMaxTotalSize string
+	var depsSyncDryRun bool
+	var depsSyncWaitLock time.Duration
 	var depsSyncCmd = &cobra.Command{
 		Use:   "sync",
 		Short: "Download dependencies and verify against deps-lock.ini",
-		Long:  "Download dependencies from Nexus and verify checksums atomically (fails if out of sync)",
+		Long:  "Download dependencies from Nexus and verify checksums atomically (fails if out of sync)\n\nWithout --keep-going, a failed download exits with the exit code of the download.\n\n" + exitcode.Help("deps sync"),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return depsSyncMain(cfg, logger, !depsSyncNoCleanup, quietMode)
+			onConflict, err := operations.ParseConflictPolicy(depsSyncOnConflict)
+			if err != nil {
+				return err
+			}
+			syncOpts := &DepsSyncOptions{
+				Logger:           logger,
+				QuietMode:        quietMode,
+				CleanupUntracked: !depsSyncNoCleanup,
+				OnConflict:       onConflict,
+				KeepGoing:        depsSyncKeepGoing,
+				AllowMissing:     depsSyncAllowMissing,
+				WaitLock:         depsSyncWaitLock,
+			}
+			if depsSyncMaxTotalSize != "" {
+				if syncOpts.MaxTotalSize, err = util.ParseByteSize(depsSyncMaxTotalSize); err != nil {
+					return fmt.Errorf("--max-total-size: %w", err)
+				}
+			}
+			if !depsSyncDryRun {
+				finishMetrics, err := startMetrics(cmd, metricsFile, metricsListen)
+				if err != nil {
+					return err
+				}
+				err = depsSyncMain(cfg, settings, syncOpts)
+				if metricsErr := finishMetrics(err == nil); err == nil {
+					err = metricsErr
+				}
+				return err
+			}
+			syncOpts.Plan = operations.NewPlan("deps sync")
+			if err := depsSyncMain(cfg, settings, syncOpts); err != nil {
+				return err
+			}
+			syncOpts.Plan.Print(logger, planFormat)
+			return nil
 		},
 	}
 	depsSyncCmd.Flags().BoolVar(&depsSyncNoCleanup, "no-cleanup", false, "Skip cleanup of untracked files from output directory")
+	depsSyncCmd.Flags().StringVar(&depsSyncOnConflict, "on-conflict", "overwrite", "How to handle locally modified files: overwrite, backup, skip, or fail")
+	depsSyncCmd.Flags().StringVar(&depsSyncMaxTotalSize, "max-total-size", "", "Fail before downloading or cleaning up anything if the dependencies are larger than this in total (e.g., '2G'); overrides max_size in deps.ini")
+	depsSyncCmd.Flags().BoolVarP(&depsSyncDryRun, "dry-run", "n", false, "Show the files that would be downloaded or deleted without changing anything")
+	depsSyncCmd.Flags().BoolVar(&depsSyncKeepGoing, "keep-going", false, "Continue with the remaining dependencies when one fails and report all failures at the end")
+	depsSyncCmd.Flags().BoolVar(&depsSyncAllowMissing, "allow-missing", false, "Only warn about files in deps-lock.ini that no longer exist in Nexus, e.g. for an emergency build, instead of failing")
+	depsSyncCmd.Flags().DurationVar(&depsSyncWaitLock, "wait-lock", 0, "Wait up to this long for another nexuscli-go process operating on an output directory to finish (e.g. 10m), instead of failing at once")
+	addMetricsFlags(depsSyncCmd, &metricsFile, &metricsListen)
+
+	var depsCheckCmd = &cobra.Command{
+		Use:   "check",
+		Short: "Validate deps.ini and check deps-lock.ini against it",
+		Long:  "Validate deps.ini and check that deps-lock.ini is consistent with it, reporting every problem at once.\n\nNexus is not contacted, so the command is suited to pre-commit hooks. Use 'deps lock --frozen' to also check that the locked checksums are current.",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return depsCheckMain(logger)
+		},
+	}
 
 	var depsEnvOutput string
 	var depsEnvCmd = &cobra.Command{
@@ -566,15 +2548,85 @@ func buildRootCommand() *cobra.Command {
 	}
 	depsEnvCmd.Flags().StringVarP(&depsEnvOutput, "output", "o", "deps.env", "Output file path for environment variables")
 
+	var depsImportFormat string
+	var depsImportForce bool
+	var depsImportCmd = &cobra.Command{
+		Use:   "import <file>",
+		Short: "Add dependencies from a JSON file to deps.ini",
+		Long:  "Add the dependencies of a JSON file, e.g. converted from another tool's lock file, to deps.ini as sections.\n\nThe file must follow the documented JSON schema; every problem in it is reported at once. deps.ini is created if it does not exist, and its comments and other sections are kept. A dependency that already has a section is an error unless --force is given.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return depsImportMain(logger, depsImportFormat, args[0], depsImportForce)
+		},
+	}
+	depsImportCmd.Flags().StringVar(&depsImportFormat, "format", "json", "Format of the file to import (json)")
+	depsImportCmd.Flags().BoolVar(&depsImportForce, "force", false, "Replace the sections of dependencies that are already in deps.ini")
+
+	var depsExportFormat string
+	var depsExportOutput string
+	var depsExportForce bool
+	var depsExportCmd = &cobra.Command{
+		Use:   "export",
+		Short: "Write the dependencies of deps.ini as JSON",
+		Long:  "Write the dependencies of deps.ini in the JSON schema read by 'deps import', with the [defaults] applied to each dependency.\n\nSettings the schema has no field for (dest, ca_cert, concurrency, max_rate) are not exported and are listed in a warning.",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return depsExportMain(logger, cmd.ErrOrStderr(), depsExportFormat, depsExportOutput, depsExportForce)
+		},
+	}
+	depsExportCmd.Flags().StringVar(&depsExportFormat, "format", "json", "Format to export (json)")
+	depsExportCmd.Flags().StringVarP(&depsExportOutput, "output", "o", "", "Write to this file instead of stdout")
+	depsExportCmd.Flags().BoolVar(&depsExportForce, "force", false, "Overwrite the output file if it exists")
+
+	var queueDirFlag string
+	var queueCmd = &cobra.Command{
+		Use:   "queue",
+		Short: "Manage uploads queued while Nexus was unreachable",
+		Long:  "Manage uploads recorded by upload --queue while Nexus could not be reached.",
+	}
+	queueCmd.PersistentFlags().StringVar(&queueDirFlag, "queue-dir", "", "Directory of the upload queue (default $XDG_CONFIG_HOME/nexuscli/queue)")
+
+	var queueFlushCmd = &cobra.Command{
+		Use:   "flush",
+		Short: "Upload the queued uploads",
+		Long:  "Replay the queued uploads in the order they were queued.\n\nAn upload whose source files changed since it was queued is skipped with a warning and kept in the queue.\nAn upload is removed from the queue only after it succeeded and the uploaded files match the queued checksums.",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			dir, err := queueDir(queueDirFlag)
+			if err != nil {
+				fmt.Println("Error:", err)
+				os.Exit(exitcode.Error)
+			}
+			operations.FlushQueueMain(dir, cfg, &operations.UploadOptions{Logger: logger, QuietMode: quietMode})
+		},
+	}
+	queueCmd.AddCommand(queueFlushCmd)
+
 	depsCmd.AddCommand(depsInitCmd)
 	depsCmd.AddCommand(depsLockCmd)
 	depsCmd.AddCommand(depsSyncCmd)
+	depsCmd.AddCommand(depsCheckCmd)
 	depsCmd.AddCommand(depsEnvCmd)
+	depsCmd.AddCommand(depsImportCmd)
+	depsCmd.AddCommand(depsExportCmd)
 
 	rootCmd.AddCommand(uploadCmd)
 	rootCmd.AddCommand(downloadCmd)
+	rootCmd.AddCommand(mirrorCmd)
+	rootCmd.AddCommand(moveCmd)
+	rootCmd.AddCommand(copyCmd)
+	rootCmd.AddCommand(pruneCmd)
+	rootCmd.AddCommand(treeCmd)
+	rootCmd.AddCommand(statCmd)
+	rootCmd.AddCommand(repoCmd)
+	rootCmd.AddCommand(verifyCmd)
+	rootCmd.AddCommand(serveCmd)
 	rootCmd.AddCommand(versionCmd)
+	rootCmd.AddCommand(exitCodesCmd)
+	rootCmd.AddCommand(loginCmd)
+	rootCmd.AddCommand(logoutCmd)
 	rootCmd.AddCommand(depsCmd)
+	rootCmd.AddCommand(queueCmd)
 
 	return rootCmd
 }
@@ -584,6 +2636,11 @@ func main() {
 
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Println(err)
-		os.Exit(1)
+		// A failed download keeps its exit status, e.g. when a dependency has no files
+		var syncErr *syncError
+		if errors.As(err, &syncErr) && syncErr.status != exitcode.Success {
+			os.Exit(syncErr.status)
+		}
+		os.Exit(exitcode.Error)
 	}
 }
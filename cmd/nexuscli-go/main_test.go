@@ -2,14 +2,24 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/tympanix/nexus-cli/internal/config"
+	"github.com/tympanix/nexus-cli/internal/exitcode"
 	"github.com/tympanix/nexus-cli/internal/nexusapi"
 )
 
@@ -211,6 +221,53 @@ func TestVersionCommand(t *testing.T) {
 	}
 }
 
+func TestVersionCommandJSON(t *testing.T) {
+	// Build the binary with custom version and build metadata
+	buildCmd := exec.Command("go", "build", "-ldflags", "-X main.version=test-version -X main.commit=abc1234 -X main.date=2025-01-02T03:04:05Z", "-o", "nexuscli-go-test-version-json")
+	buildCmd.Dir = "."
+	if err := buildCmd.Run(); err != nil {
+		t.Fatalf("Failed to build binary: %v", err)
+	}
+	defer os.Remove("./nexuscli-go-test-version-json")
+
+	cmd := exec.Command("./nexuscli-go-test-version-json", "version", "--output", "json")
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stdout
+
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("Command failed: %v, output: %s", err, stdout.String())
+	}
+
+	var info buildInfo
+	if err := json.Unmarshal(stdout.Bytes(), &info); err != nil {
+		t.Fatalf("Failed to parse JSON output: %v, output: %s", err, stdout.String())
+	}
+	if info.Version != "test-version" {
+		t.Errorf("Expected version 'test-version', got '%s'", info.Version)
+	}
+	if info.Commit != "abc1234" {
+		t.Errorf("Expected commit 'abc1234', got '%s'", info.Commit)
+	}
+	if info.Date != "2025-01-02T03:04:05Z" {
+		t.Errorf("Expected date '2025-01-02T03:04:05Z', got '%s'", info.Date)
+	}
+	if !strings.HasPrefix(info.GoVersion, "go") {
+		t.Errorf("Expected Go version, got '%s'", info.GoVersion)
+	}
+}
+
+func TestVersionCommandInvalidOutput(t *testing.T) {
+	rootCmd := buildRootCommand()
+	rootCmd.SetArgs([]string{"version", "--output", "yaml"})
+	rootCmd.SilenceUsage = true
+	rootCmd.SilenceErrors = true
+	err := rootCmd.Execute()
+	if err == nil || !strings.Contains(err.Error(), "unsupported output format 'yaml'") {
+		t.Errorf("Expected unsupported output format error, got: %v", err)
+	}
+}
+
 func TestKeyFromFlagExists(t *testing.T) {
 	buildCmd := exec.Command("go", "build", "-o", "nexuscli-go-test-keyfrom")
 	buildCmd.Dir = "."
@@ -601,6 +658,56 @@ func TestCompletionBehavior(t *testing.T) {
 	}
 }
 
+func TestFolderCompletions(t *testing.T) {
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+	server.AddAsset("my-repo", "/files/test.txt", nexusapi.Asset{}, nil)
+	server.AddAsset("my-repo", "/files/nested/data.bin", nexusapi.Asset{}, nil)
+	server.AddAsset("my-repo", "/readme.txt", nexusapi.Asset{}, nil)
+
+	cfg := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+
+	tests := []struct {
+		pathPrefix string
+		expected   []string
+	}{
+		{"", []string{"my-repo/files/"}},
+		{"files/", []string{"my-repo/files/nested/"}},
+		{"files/test", nil},
+	}
+	for _, tt := range tests {
+		completions := getFolderCompletions(cfg, "my-repo", tt.pathPrefix)
+		if strings.Join(completions, ",") != strings.Join(tt.expected, ",") {
+			t.Errorf("Expected completions %v for %q, got %v", tt.expected, tt.pathPrefix, completions)
+		}
+	}
+}
+
+func TestCompletionSlowServer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(10 * time.Second):
+		}
+	}))
+	defer server.Close()
+
+	defer func(timeout time.Duration) { completionTimeout = timeout }(completionTimeout)
+	completionTimeout = 100 * time.Millisecond
+	cfg := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+
+	start := time.Now()
+	if completions := getRepositoryCompletions(cfg, "my"); len(completions) != 0 {
+		t.Errorf("Expected no repository completions, got %v", completions)
+	}
+	if completions := getPathCompletions(cfg, "myrepo", "ar"); len(completions) != 0 {
+		t.Errorf("Expected no path completions, got %v", completions)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected completion to give up promptly, took %s", elapsed)
+	}
+}
+
 func TestShellCompletionIntegration(t *testing.T) {
 	server := nexusapi.NewMockNexusServer()
 	defer server.Close()
@@ -724,3 +831,319 @@ func TestShellCompletionIntegration(t *testing.T) {
 		})
 	}
 }
+
+func TestExplainShowsSourcesAndRedactsPassword(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("NEXUS_URL", "http://env-nexus:8081")
+	t.Setenv("NEXUS_USER", "env-user")
+	t.Setenv("NEXUS_PASS", "env-s3cret")
+	t.Setenv("NEXUS_CHECKSUM", "sha256")
+
+	srcDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(srcDir, "file.txt"), []byte("content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	rootCmd := buildRootCommand()
+	rootCmd.SetOut(&out)
+	rootCmd.SetArgs([]string{"upload", "--dry-run", "--offline", "--explain", "--url", "http://flag-nexus:8081", "--batch-size", "10", srcDir, "raw/dist"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("upload failed: %v", err)
+	}
+	output := out.String()
+
+	for _, want := range []string{
+		`url\s+http://flag-nexus:8081\s+\(flag --url\)`,
+		`username\s+env-user\s+\(env NEXUS_USER\)`,
+		`password\s+\*+ \(redacted\)\s+\(env NEXUS_PASS\)`,
+		`checksum\s+sha256\s+\(env NEXUS_CHECKSUM\)`,
+		`batch-size\s+10\s+\(flag --batch-size\)`,
+		`max-request-bytes\s+no limit\s+\(default\)`,
+	} {
+		if !regexp.MustCompile(want).MatchString(output) {
+			t.Errorf("Expected explain output to match %q, got:\n%s", want, output)
+		}
+	}
+	if strings.Contains(output, "env-s3cret") {
+		t.Errorf("Expected the password to be redacted, got:\n%s", output)
+	}
+	if strings.Contains(output, "env-nexus") {
+		t.Errorf("Expected --url to take precedence over NEXUS_URL, got:\n%s", output)
+	}
+}
+
+// setupAliasConfig writes a config file defining the alias prod for company-prod-raw
+func setupAliasConfig(t *testing.T) {
+	t.Helper()
+	configHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+	if err := os.MkdirAll(filepath.Join(configHome, "nexuscli"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(configHome, "nexuscli", "config"), []byte("[aliases]\nprod = company-prod-raw\nstage = company-stage-raw\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRepositoryAliasUpload(t *testing.T) {
+	setupAliasConfig(t)
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+
+	srcDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(srcDir, "app.bin"), []byte("binary"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// --repository-alias replaces the alias of the config file; a name that is no alias
+	// is a literal repository name
+	for dest, expected := range map[string]string{
+		"prod/dist":    "company-prod-raw",
+		"stage/dist":   "company-stage-next",
+		"literal/dist": "literal",
+	} {
+		server.UploadedFiles = make([]nexusapi.UploadedFile, 0)
+		rootCmd := buildRootCommand()
+		rootCmd.SetArgs([]string{"--url", server.URL, "--quiet", "--repository-alias", "stage=company-stage-next", "upload", "--skip-write-check", srcDir, dest})
+		if err := rootCmd.Execute(); err != nil {
+			t.Fatalf("upload to %s failed: %v", dest, err)
+		}
+		uploaded := server.GetUploadedFiles()
+		if len(uploaded) != 1 || uploaded[0].Repository != expected || uploaded[0].Path != "/dist/app.bin" {
+			t.Errorf("Expected %s to upload dist/app.bin to %s, got %+v", dest, expected, uploaded)
+		}
+	}
+}
+
+func TestRepositoryAliasDownload(t *testing.T) {
+	setupAliasConfig(t)
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+	server.AddAsset("company-prod-raw", "/dist/app.bin", nexusapi.Asset{}, []byte("binary"))
+
+	destDir := t.TempDir()
+	rootCmd := buildRootCommand()
+	rootCmd.SetArgs([]string{"--url", server.URL, "--quiet", "download", "--recursive", "prod/dist", destDir})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("download failed: %v", err)
+	}
+	content, err := os.ReadFile(filepath.Join(destDir, "dist", "app.bin"))
+	if err != nil || string(content) != "binary" {
+		t.Errorf("Expected prod/dist to download from company-prod-raw, got %q, %v", content, err)
+	}
+}
+
+func TestRepositoryAliasArgsAndFlags(t *testing.T) {
+	setupAliasConfig(t)
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+	server.AddAsset("company-prod-raw", "/dist/app.bin", nexusapi.Asset{}, []byte("binary"))
+
+	srcDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(srcDir, "notes.txt"), []byte("notes"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"copy", "prod/dist/app.bin", "stage/dist/app.bin"}, "company-stage-raw:/dist/app.bin"},
+		{[]string{"upload", "--skip-write-check", "--touch", "stage/builds/DONE"}, "company-stage-raw:/builds/DONE"},
+		{[]string{"upload", "--skip-write-check", "--route", "*.txt=stage/docs", srcDir, "literal/dist"}, "company-stage-raw:/docs/notes.txt"},
+	} {
+		server.UploadedFiles = make([]nexusapi.UploadedFile, 0)
+		rootCmd := buildRootCommand()
+		rootCmd.SetArgs(append([]string{"--url", server.URL, "--quiet"}, tc.args...))
+		if err := rootCmd.Execute(); err != nil {
+			t.Fatalf("%v failed: %v", tc.args, err)
+		}
+		uploaded := server.GetUploadedFiles()
+		if len(uploaded) != 1 || uploaded[0].Repository+":"+uploaded[0].Path != tc.expected {
+			t.Errorf("Expected %v to upload %s, got %+v", tc.args, tc.expected, uploaded)
+		}
+	}
+}
+
+func TestRepositoryAliasCompletion(t *testing.T) {
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+	server.AddRepository(nexusapi.Repository{Name: "company-prod-raw", Format: "raw", Type: "hosted"})
+	server.AddRepository(nexusapi.Repository{Name: "prod", Format: "raw", Type: "hosted"})
+	server.AddRepository(nexusapi.Repository{Name: "production", Format: "raw", Type: "hosted"})
+	server.AddAsset("company-prod-raw", "/releases/app.bin", nexusapi.Asset{}, nil)
+
+	cfg := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+	if err := cfg.SetRepositoryAlias("prod", "company-prod-raw"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The alias shadows the repository of the same name, which is offered only once
+	if completions := getRepositoryCompletions(cfg, "pro"); strings.Join(completions, ",") != "prod,production" {
+		t.Errorf("Expected the alias and the other repository, got %v", completions)
+	}
+	if completions := getFolderCompletions(cfg, "prod", "rel"); strings.Join(completions, ",") != "prod/releases/" {
+		t.Errorf("Expected folders of the aliased repository under the alias, got %v", completions)
+	}
+
+	// Aliases are offered when Nexus cannot be reached
+	cfg.NexusURL = "http://127.0.0.1:1"
+	if completions := getRepositoryCompletions(cfg, "p"); strings.Join(completions, ",") != "prod" {
+		t.Errorf("Expected the alias without Nexus, got %v", completions)
+	}
+}
+
+func TestDownloadMetricsFile(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+	server.AddAsset("builds", "/dist/app.bin", nexusapi.Asset{}, []byte("binary"))
+	server.AddAsset("builds", "/dist/app.txt", nexusapi.Asset{}, []byte("text"))
+
+	destDir := t.TempDir()
+	metricsFile := filepath.Join(t.TempDir(), "nexuscli.prom")
+	args := []string{"--url", server.URL, "--quiet", "download", "--recursive", "builds/dist", destDir, "--metrics-file", metricsFile}
+	for run, expected := range []string{
+		`nexuscli_files_total{command="download",outcome="succeeded"} 2`,
+		`nexuscli_files_total{command="download",outcome="skipped"} 2`,
+	} {
+		rootCmd := buildRootCommand()
+		rootCmd.SetArgs(args)
+		if err := rootCmd.Execute(); err != nil {
+			t.Fatalf("download failed: %v", err)
+		}
+		content, err := os.ReadFile(metricsFile)
+		if err != nil {
+			t.Fatalf("Expected the metrics file to be written: %v", err)
+		}
+		for _, line := range []string{expected, `nexuscli_success{command="download"} 1`, `nexuscli_phase_duration_seconds{command="download",phase="transfer"}`} {
+			if !strings.Contains(string(content), line) {
+				t.Errorf("Run %d: expected %q in the metrics file, got:\n%s", run+1, line, content)
+			}
+		}
+	}
+}
+
+func TestExitCodeRegistry(t *testing.T) {
+	buildCmd := exec.Command("go", "build", "-o", "nexuscli-go-test-registry")
+	buildCmd.Dir = "."
+	if err := buildCmd.Run(); err != nil {
+		t.Fatalf("Failed to build binary: %v", err)
+	}
+	defer os.Remove("./nexuscli-go-test-registry")
+
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+	server.AddAsset("raw", "/builds/app.bin", nexusapi.Asset{}, []byte("binary"))
+	server.AddAsset("raw", "/empty/.keep", nexusapi.Asset{}, nil)
+	home := t.TempDir()
+	run := func(args ...string) (int, string) {
+		cmd := exec.Command("./nexuscli-go-test-registry", append([]string{"--url", server.URL, "--quiet"}, args...)...)
+		cmd.Env = append(os.Environ(), "HOME="+home, "NEXUS_USER=test", "NEXUS_PASS=test")
+		output, err := cmd.CombinedOutput()
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return exitErr.ExitCode(), string(output)
+		} else if err != nil {
+			t.Fatalf("Failed to run %v: %v", args, err)
+		}
+		return 0, string(output)
+	}
+
+	// Representative outcomes of each code, which must be registered for the command
+	for _, tc := range []struct {
+		command  string
+		args     []string
+		expected int
+	}{
+		{"download", []string{"download", "--recursive", "raw/builds", t.TempDir()}, exitcode.Success},
+		{"download", []string{"download", "invalid-format", t.TempDir()}, exitcode.Error},
+		{"download", []string{"download", "--recursive", "--min-files", "3", "raw/builds", t.TempDir()}, exitcode.TooFewFiles},
+		{"download", []string{"download", "--recursive", "raw/missing", t.TempDir()}, exitcode.NoFiles},
+		{"upload", []string{"upload", "--skip-write-check", filepath.Join(home, "missing"), "raw/dest"}, exitcode.Error},
+		{"verify", []string{"verify", "--tree-checksum", "sha256:00", t.TempDir()}, exitcode.Error},
+	} {
+		code, output := run(tc.args...)
+		if code != tc.expected {
+			t.Errorf("%v: expected exit code %d, got %d: %s", tc.args, tc.expected, code, output)
+			continue
+		}
+		entry, ok := exitcode.Lookup(code)
+		if !ok {
+			t.Errorf("%v: exit code %d is not registered", tc.args, code)
+			continue
+		}
+		if len(entry.Commands) > 0 && !slices.Contains(entry.Commands, tc.command) {
+			t.Errorf("%v: exit code %d is not registered for %s: %v", tc.args, code, tc.command, entry.Commands)
+		}
+		if !strings.Contains(exitcode.Help(tc.command), entry.Description) {
+			t.Errorf("Expected the help of %s to list exit code %d", tc.command, code)
+		}
+	}
+
+	// The table lists the registry
+	code, output := run("exit-codes", "--output", "json")
+	var entries []exitcode.Entry
+	if err := json.Unmarshal([]byte(output), &entries); code != 0 || err != nil {
+		t.Fatalf("exit-codes --output json failed with %d: %v: %s", code, err, output)
+	}
+	if !reflect.DeepEqual(entries, exitcode.Entries()) {
+		t.Errorf("Expected the registry, got %+v", entries)
+	}
+	if code, output := run("help", "exit-codes"); code != 0 || !strings.Contains(output, "66    no-files") {
+		t.Errorf("Expected help exit-codes to print the table, got %d: %s", code, output)
+	}
+}
+
+func TestServeCredentials(t *testing.T) {
+	t.Setenv("NEXUS_USER", "")
+	t.Setenv("NEXUS_PASS", "")
+	cfg := config.NewConfig()
+	if username, password := serveCredentials(cfg); username != "" || password != "" {
+		t.Errorf("Expected the default credentials not to be used, got %q/%q", username, password)
+	}
+
+	cfg.Username, cfg.Password = "mirror", "secret"
+	cfg.SetSource(config.KeyUsername, config.FlagSource("username"))
+	cfg.SetSource(config.KeyPassword, config.FileSource("credentials"))
+	if username, _ := serveCredentials(cfg); username != "" {
+		t.Errorf("Expected stored credentials not to be used, got %q", username)
+	}
+
+	t.Setenv("NEXUS_USER", "env-user")
+	t.Setenv("NEXUS_PASS", "env-pass")
+	if username, password := serveCredentials(config.NewConfig()); username != "env-user" || password != "env-pass" {
+		t.Errorf("Expected the credentials from the environment, got %q/%q", username, password)
+	}
+}
+
+func TestVerboseFlagValues(t *testing.T) {
+	for _, tc := range []struct {
+		args     []string
+		expected verbosityFlag
+	}{
+		{nil, 0},
+		{[]string{"-v"}, 1},
+		{[]string{"--verbose"}, 1},
+		{[]string{"--verbose=true"}, 1},
+		{[]string{"--verbose=false"}, 0},
+		{[]string{"-vv"}, 2},
+		{[]string{"-v", "--verbose"}, 2},
+		{[]string{"-vv", "--verbose=false"}, 0},
+	} {
+		var verbosity verbosityFlag
+		flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+		addVerboseFlag(flags, &verbosity)
+		if err := flags.Parse(tc.args); err != nil {
+			t.Fatalf("%v: %v", tc.args, err)
+		}
+		if verbosity != tc.expected {
+			t.Errorf("%v: expected verbosity %d, got %d", tc.args, tc.expected, verbosity)
+		}
+	}
+	var verbosity verbosityFlag
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	addVerboseFlag(flags, &verbosity)
+	if err := flags.Parse([]string{"--verbose=maybe"}); err == nil {
+		t.Error("Expected --verbose=maybe to be rejected")
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tympanix/nexus-cli/internal/checksum"
+	"github.com/tympanix/nexus-cli/internal/nexusapi"
+)
+
+func TestDownloadTreeChecksumVerify(t *testing.T) {
+	mockServer := nexusapi.NewMockNexusServer()
+	defer mockServer.Close()
+
+	mockServer.AddAsset("libs", "/env/bin/tool", nexusapi.Asset{}, []byte("tool binary"))
+	mockServer.AddAsset("libs", "/env/config/app.yml", nexusapi.Asset{}, []byte("key: value"))
+	mockServer.AddAsset("libs", "/env/README.md", nexusapi.Asset{}, []byte("# env"))
+
+	// Download the same tree twice into separate directories
+	var treeChecksums []string
+	var destDirs []string
+	for i := 0; i < 2; i++ {
+		destDir := t.TempDir()
+		rootCmd := buildRootCommand()
+		rootCmd.SetArgs([]string{"download", "--url", mockServer.URL, "-q", "-r", "--checksum", "sha256", "--tree-checksum", "libs/env", destDir})
+		if err := rootCmd.Execute(); err != nil {
+			t.Fatalf("download failed: %v", err)
+		}
+
+		treeChecksum, err := checksum.ComputeTreeChecksum(destDir, "sha256")
+		if err != nil {
+			t.Fatal(err)
+		}
+		treeChecksums = append(treeChecksums, treeChecksum)
+		destDirs = append(destDirs, destDir)
+	}
+
+	if treeChecksums[0] != treeChecksums[1] {
+		t.Fatalf("tree checksum differs between downloads: %s != %s", treeChecksums[0], treeChecksums[1])
+	}
+
+	rootCmd := buildRootCommand()
+	rootCmd.SetArgs([]string{"verify", "-q", "--tree-checksum", treeChecksums[0], destDirs[1]})
+	if err := rootCmd.Execute(); err != nil {
+		t.Errorf("verify failed for matching tree: %v", err)
+	}
+
+	// Tamper with one file and verify again
+	if err := os.WriteFile(filepath.Join(destDirs[1], "env", "config", "app.yml"), []byte("key: other"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	rootCmd = buildRootCommand()
+	rootCmd.SetArgs([]string{"verify", "-q", "--tree-checksum", treeChecksums[0], destDirs[1]})
+	rootCmd.SilenceUsage = true
+	rootCmd.SilenceErrors = true
+	err := rootCmd.Execute()
+	if err == nil {
+		t.Fatal("verify should have failed for modified tree")
+	}
+	if !strings.Contains(err.Error(), "tree checksum mismatch") {
+		t.Errorf("expected mismatch error, got: %v", err)
+	}
+}
+
+func TestVerifyInvalidTreeChecksum(t *testing.T) {
+	rootCmd := buildRootCommand()
+	rootCmd.SetArgs([]string{"verify", "-q", "--tree-checksum", "not-a-checksum", t.TempDir()})
+	rootCmd.SilenceUsage = true
+	rootCmd.SilenceErrors = true
+	err := rootCmd.Execute()
+	if err == nil {
+		t.Fatal("verify should have failed for invalid tree checksum")
+	}
+	if !strings.Contains(err.Error(), "expected format '<algorithm>:<hex>'") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"archive/tar"
 	"archive/zip"
 	"compress/gzip"
+	"encoding/hex"
 	"fmt"
+	"hash"
 	"io"
 	"os"
 	"path/filepath"
@@ -41,6 +43,72 @@ func CollectFilesWithGlob(src string, globPattern string) ([]string, error) {
 	})
 }
 
+// UnreadableFile is a file or directory that was skipped because it could not be read
+type UnreadableFile struct {
+	Path string
+	Err  error
+}
+
+// CollectReadableFilesWithGlob works like CollectFilesWithGlob but skips files and directories
+// that cannot be read (e.g. permission denied) instead of failing. Files excluded by the glob
+// pattern are never opened. The skipped paths are returned separately so callers can warn about them.
+func CollectReadableFilesWithGlob(src string, globPattern string) ([]string, []UnreadableFile, error) {
+	readable, unreadable, _, err := CollectReadableFilesCountingExcluded(src, globPattern)
+	return readable, unreadable, err
+}
+
+// CollectReadableFilesCountingExcluded works like CollectReadableFilesWithGlob and also
+// returns the number of files the glob pattern excluded
+func CollectReadableFilesCountingExcluded(src string, globPattern string) ([]string, []UnreadableFile, int, error) {
+	var allFiles []string
+	var unreadable []UnreadableFile
+
+	err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if path == src {
+				return err
+			}
+			unreadable = append(unreadable, UnreadableFile{Path: path, Err: err})
+			if info != nil && info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.IsDir() {
+			allFiles = append(allFiles, path)
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	filtered, err := util.FilterWithGlob(allFiles, globPattern, func(path string) string {
+		relPath, err := filepath.Rel(src, path)
+		if err != nil {
+			return path
+		}
+		return relPath
+	})
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	var readable []string
+	for _, path := range filtered {
+		f, err := os.Open(path)
+		if err != nil {
+			unreadable = append(unreadable, UnreadableFile{Path: path, Err: err})
+			continue
+		}
+		f.Close()
+		readable = append(readable, path)
+	}
+
+	return readable, unreadable, len(allFiles) - len(filtered), nil
+}
+
 // CreateTarGz creates a tar.gz archive containing all files from srcDir.
 // The archive is written to the provided writer on-the-fly.
 // Files are stored in the archive with paths relative to srcDir.
@@ -52,9 +120,18 @@ func CreateTarGz(srcDir string, writer io.Writer) error {
 // The archive is written to the provided writer on-the-fly.
 // Files are stored in the archive with paths relative to srcDir.
 func CreateTarGzWithGlob(srcDir string, writer io.Writer, globPattern string) error {
+	files, err := collectArchiveFiles(srcDir, globPattern)
+	if err != nil {
+		return err
+	}
+	return createTarGzFromFiles(srcDir, files, writer, nil)
+}
+
+// createTarGzFromFiles creates a tar.gz archive containing the given files from srcDir
+func createTarGzFromFiles(srcDir string, files []string, writer io.Writer, sums *entryChecksums) error {
 	gzipWriter := gzip.NewWriter(writer)
 
-	if err := createTarArchive(srcDir, gzipWriter, globPattern); err != nil {
+	if err := writeTarArchive(srcDir, gzipWriter, files, sums); err != nil {
 		gzipWriter.Close()
 		return err
 	}
@@ -69,13 +146,19 @@ func CreateTarGzWithGlob(srcDir string, writer io.Writer, globPattern string) er
 // ExtractTarGz extracts a tar.gz archive from the provided reader to destDir.
 // Files are extracted on-the-fly as they are read from the archive.
 func ExtractTarGz(reader io.Reader, destDir string) error {
+	_, err := extractTarGz(reader, destDir, 0)
+	return err
+}
+
+// extractTarGz extracts a tar.gz archive and returns the number of content bytes written
+func extractTarGz(reader io.Reader, destDir string, dirMode os.FileMode) (int64, error) {
 	gzipReader, err := gzip.NewReader(reader)
 	if err != nil {
-		return fmt.Errorf("failed to create gzip reader: %w", err)
+		return 0, fmt.Errorf("failed to create gzip reader: %w", err)
 	}
 	defer gzipReader.Close()
 
-	return extractTar(gzipReader, destDir)
+	return extractTar(gzipReader, destDir, dirMode)
 }
 
 // CreateTarZst creates a tar.zst archive containing all files from srcDir.
@@ -89,12 +172,21 @@ func CreateTarZst(srcDir string, writer io.Writer) error {
 // The archive is written to the provided writer on-the-fly.
 // Files are stored in the archive with paths relative to srcDir.
 func CreateTarZstWithGlob(srcDir string, writer io.Writer, globPattern string) error {
+	files, err := collectArchiveFiles(srcDir, globPattern)
+	if err != nil {
+		return err
+	}
+	return createTarZstFromFiles(srcDir, files, writer, nil)
+}
+
+// createTarZstFromFiles creates a tar.zst archive containing the given files from srcDir
+func createTarZstFromFiles(srcDir string, files []string, writer io.Writer, sums *entryChecksums) error {
 	zstdWriter, err := zstd.NewWriter(writer)
 	if err != nil {
 		return fmt.Errorf("failed to create zstd writer: %w", err)
 	}
 
-	if err := createTarArchive(srcDir, zstdWriter, globPattern); err != nil {
+	if err := writeTarArchive(srcDir, zstdWriter, files, sums); err != nil {
 		zstdWriter.Close()
 		return err
 	}
@@ -109,18 +201,27 @@ func CreateTarZstWithGlob(srcDir string, writer io.Writer, globPattern string) e
 // ExtractTarZst extracts a tar.zst archive from the provided reader to destDir.
 // Files are extracted on-the-fly as they are read from the archive.
 func ExtractTarZst(reader io.Reader, destDir string) error {
+	_, err := extractTarZst(reader, destDir, 0)
+	return err
+}
+
+// extractTarZst extracts a tar.zst archive and returns the number of content bytes written
+func extractTarZst(reader io.Reader, destDir string, dirMode os.FileMode) (int64, error) {
 	zstdReader, err := zstd.NewReader(reader)
 	if err != nil {
-		return fmt.Errorf("failed to create zstd reader: %w", err)
+		return 0, fmt.Errorf("failed to create zstd reader: %w", err)
 	}
 	defer zstdReader.Close()
 
-	return extractTar(zstdReader, destDir)
+	return extractTar(zstdReader, destDir, dirMode)
 }
 
 // extractTar is a helper function that extracts tar content from any decompressed reader.
-func extractTar(reader io.Reader, destDir string) error {
+// It returns the number of content bytes written to extracted files. Directories are
+// created with dirMode, see util.MkdirAll.
+func extractTar(reader io.Reader, destDir string, dirMode os.FileMode) (int64, error) {
 	tarReader := tar.NewReader(reader)
+	var written int64
 
 	for {
 		header, err := tarReader.Next()
@@ -128,7 +229,7 @@ func extractTar(reader io.Reader, destDir string) error {
 			break
 		}
 		if err != nil {
-			return fmt.Errorf("failed to read tar header: %w", err)
+			return written, fmt.Errorf("failed to read tar header: %w", err)
 		}
 
 		// Construct target path
@@ -136,50 +237,90 @@ func extractTar(reader io.Reader, destDir string) error {
 
 		// Security check: ensure path doesn't escape destDir
 		if !strings.HasPrefix(filepath.Clean(targetPath), filepath.Clean(destDir)) {
-			return fmt.Errorf("illegal file path in archive: %s", header.Name)
+			return written, fmt.Errorf("illegal file path in archive: %s", header.Name)
 		}
 
 		// Create directories as needed
-		if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
-			return fmt.Errorf("failed to create directory for %s: %w", targetPath, err)
+		if err := util.MkdirAll(filepath.Dir(targetPath), dirMode); err != nil {
+			return written, fmt.Errorf("failed to create directory for %s: %w", targetPath, err)
 		}
 
 		// Extract file
 		if header.Typeflag == tar.TypeReg {
 			outFile, err := os.Create(targetPath)
 			if err != nil {
-				return fmt.Errorf("failed to create file %s: %w", targetPath, err)
+				return written, fmt.Errorf("failed to create file %s: %w", targetPath, err)
 			}
 
-			if _, err := io.Copy(outFile, tarReader); err != nil {
+			n, err := io.Copy(outFile, tarReader)
+			written += n
+			if err != nil {
 				outFile.Close()
-				return fmt.Errorf("failed to extract file %s: %w", targetPath, err)
+				return written, fmt.Errorf("failed to extract file %s: %w", targetPath, err)
 			}
 			outFile.Close()
 
-			// Restore file mode
+			// Restore file mode and modification time
 			if err := os.Chmod(targetPath, os.FileMode(header.Mode)); err != nil {
-				return fmt.Errorf("failed to set permissions on %s: %w", targetPath, err)
+				return written, fmt.Errorf("failed to set permissions on %s: %w", targetPath, err)
+			}
+			if err := os.Chtimes(targetPath, header.ModTime, header.ModTime); err != nil {
+				return written, fmt.Errorf("failed to set modification time on %s: %w", targetPath, err)
 			}
 		}
 	}
 
+	return written, nil
+}
+
+// entryChecksums collects the checksum of each file as it is written to an archive, keyed
+// by its path in the archive
+type entryChecksums struct {
+	newHash func() hash.Hash
+	sums    map[string]string
+}
+
+// add writes the content of the entry name with write. Unless c is nil, the content is
+// hashed on its way into the archive, so the file is read only once.
+func (c *entryChecksums) add(name string, reader io.Reader, write func(io.Reader) error) error {
+	if c == nil {
+		return write(reader)
+	}
+	h := c.newHash()
+	if err := write(io.TeeReader(reader, h)); err != nil {
+		return err
+	}
+	c.sums[name] = hex.EncodeToString(h.Sum(nil))
 	return nil
 }
 
 // createTarArchive is a helper function that creates a tar archive from files.
 // It writes to any io.Writer (which may be a compression writer).
 func createTarArchive(srcDir string, writer io.Writer, globPattern string) error {
-	tarWriter := tar.NewWriter(writer)
-	defer tarWriter.Close()
+	files, err := collectArchiveFiles(srcDir, globPattern)
+	if err != nil {
+		return err
+	}
+	return writeTarArchive(srcDir, writer, files, nil)
+}
 
-	files, err := CollectFilesWithGlob(srcDir, globPattern)
+// collectArchiveFiles collects the files to archive from srcDir.
+// Unreadable files are skipped; callers report them before creating the archive.
+func collectArchiveFiles(srcDir string, globPattern string) ([]string, error) {
+	files, _, err := CollectReadableFilesWithGlob(srcDir, globPattern)
 	if err != nil {
-		return fmt.Errorf("failed to collect files: %w", err)
+		return nil, fmt.Errorf("failed to collect files: %w", err)
 	}
+	return files, nil
+}
+
+// writeTarArchive writes the given files to a tar archive with paths relative to srcDir
+func writeTarArchive(srcDir string, writer io.Writer, files []string, sums *entryChecksums) error {
+	tarWriter := tar.NewWriter(writer)
+	defer tarWriter.Close()
 
 	for _, filePath := range files {
-		if err := addFileToTar(tarWriter, srcDir, filePath); err != nil {
+		if err := addFileToTar(tarWriter, srcDir, filePath, sums); err != nil {
 			return err
 		}
 	}
@@ -188,7 +329,7 @@ func createTarArchive(srcDir string, writer io.Writer, globPattern string) error
 }
 
 // addFileToTar adds a single file to a tar archive
-func addFileToTar(tarWriter *tar.Writer, srcDir string, filePath string) error {
+func addFileToTar(tarWriter *tar.Writer, srcDir string, filePath string, sums *entryChecksums) error {
 	info, err := os.Stat(filePath)
 	if err != nil {
 		return fmt.Errorf("failed to stat file %s: %w", filePath, err)
@@ -200,25 +341,34 @@ func addFileToTar(tarWriter *tar.Writer, srcDir string, filePath string) error {
 	}
 	relPath = filepath.ToSlash(relPath)
 
-	header := &tar.Header{
-		Name:    relPath,
-		Size:    info.Size(),
-		Mode:    int64(info.Mode()),
-		ModTime: info.ModTime(),
+	file, err := os.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to open file %s: %w", filePath, err)
 	}
+	defer file.Close()
 
+	return sums.add(relPath, file, func(reader io.Reader) error {
+		return writeTarEntry(tarWriter, &tar.Header{
+			Name:    relPath,
+			Size:    info.Size(),
+			Mode:    int64(info.Mode()),
+			ModTime: info.ModTime(),
+		}, reader)
+	})
+}
+
+// writeTarEntry writes a header and exactly header.Size bytes read from reader
+func writeTarEntry(tarWriter *tar.Writer, header *tar.Header, reader io.Reader) error {
 	if err := tarWriter.WriteHeader(header); err != nil {
-		return fmt.Errorf("failed to write tar header for %s: %w", relPath, err)
+		return fmt.Errorf("failed to write tar header for %s: %w", header.Name, err)
 	}
 
-	file, err := os.Open(filePath)
+	n, err := io.Copy(tarWriter, reader)
 	if err != nil {
-		return fmt.Errorf("failed to open file %s: %w", filePath, err)
+		return fmt.Errorf("failed to write file %s to archive: %w", header.Name, err)
 	}
-	defer file.Close()
-
-	if _, err := io.Copy(tarWriter, file); err != nil {
-		return fmt.Errorf("failed to write file %s to archive: %w", relPath, err)
+	if n != header.Size {
+		return fmt.Errorf("failed to write file %s to archive: got %d bytes, expected %d", header.Name, n, header.Size)
 	}
 
 	return nil
@@ -235,16 +385,20 @@ func CreateZip(srcDir string, writer io.Writer) error {
 // The archive is written to the provided writer on-the-fly.
 // Files are stored in the archive with paths relative to srcDir.
 func CreateZipWithGlob(srcDir string, writer io.Writer, globPattern string) error {
-	zipWriter := zip.NewWriter(writer)
-	defer zipWriter.Close()
-
-	files, err := CollectFilesWithGlob(srcDir, globPattern)
+	files, err := collectArchiveFiles(srcDir, globPattern)
 	if err != nil {
-		return fmt.Errorf("failed to collect files: %w", err)
+		return err
 	}
+	return createZipFromFiles(srcDir, files, writer, nil)
+}
+
+// createZipFromFiles creates a zip archive containing the given files from srcDir
+func createZipFromFiles(srcDir string, files []string, writer io.Writer, sums *entryChecksums) error {
+	zipWriter := zip.NewWriter(writer)
+	defer zipWriter.Close()
 
 	for _, filePath := range files {
-		if err := addFileToZip(zipWriter, srcDir, filePath); err != nil {
+		if err := addFileToZip(zipWriter, srcDir, filePath, sums); err != nil {
 			return err
 		}
 	}
@@ -253,7 +407,7 @@ func CreateZipWithGlob(srcDir string, writer io.Writer, globPattern string) erro
 }
 
 // addFileToZip adds a single file to a zip archive
-func addFileToZip(zipWriter *zip.Writer, srcDir string, filePath string) error {
+func addFileToZip(zipWriter *zip.Writer, srcDir string, filePath string, sums *entryChecksums) error {
 	info, err := os.Stat(filePath)
 	if err != nil {
 		return fmt.Errorf("failed to stat file %s: %w", filePath, err)
@@ -272,19 +426,26 @@ func addFileToZip(zipWriter *zip.Writer, srcDir string, filePath string) error {
 	header.Name = relPath
 	header.Method = zip.Deflate
 
-	headerWriter, err := zipWriter.CreateHeader(header)
-	if err != nil {
-		return fmt.Errorf("failed to create header for %s: %w", relPath, err)
-	}
-
 	file, err := os.Open(filePath)
 	if err != nil {
 		return fmt.Errorf("failed to open file %s: %w", filePath, err)
 	}
 	defer file.Close()
 
-	if _, err := io.Copy(headerWriter, file); err != nil {
-		return fmt.Errorf("failed to write file %s to archive: %w", relPath, err)
+	return sums.add(relPath, file, func(reader io.Reader) error {
+		return writeZipEntry(zipWriter, header, reader)
+	})
+}
+
+// writeZipEntry writes a header and the content read from reader
+func writeZipEntry(zipWriter *zip.Writer, header *zip.FileHeader, reader io.Reader) error {
+	headerWriter, err := zipWriter.CreateHeader(header)
+	if err != nil {
+		return fmt.Errorf("failed to create header for %s: %w", header.Name, err)
+	}
+
+	if _, err := io.Copy(headerWriter, reader); err != nil {
+		return fmt.Errorf("failed to write file %s to archive: %w", header.Name, err)
 	}
 
 	return nil
@@ -293,60 +454,74 @@ func addFileToZip(zipWriter *zip.Writer, srcDir string, filePath string) error {
 // ExtractZip extracts a zip archive from the provided reader to destDir.
 // Files are extracted on-the-fly as they are read from the archive.
 func ExtractZip(reader io.Reader, destDir string) error {
+	_, err := extractZip(reader, destDir, 0)
+	return err
+}
+
+// extractZip extracts a zip archive and returns the number of content bytes written
+func extractZip(reader io.Reader, destDir string, dirMode os.FileMode) (int64, error) {
 	data, err := io.ReadAll(reader)
 	if err != nil {
-		return fmt.Errorf("failed to read zip data: %w", err)
+		return 0, fmt.Errorf("failed to read zip data: %w", err)
 	}
 
 	zipReader, err := zip.NewReader(strings.NewReader(string(data)), int64(len(data)))
 	if err != nil {
-		return fmt.Errorf("failed to create zip reader: %w", err)
+		return 0, fmt.Errorf("failed to create zip reader: %w", err)
 	}
 
+	var written int64
 	for _, file := range zipReader.File {
-		if err := extractZipFile(file, destDir); err != nil {
-			return err
+		n, err := extractZipFile(file, destDir, dirMode)
+		written += n
+		if err != nil {
+			return written, err
 		}
 	}
 
-	return nil
+	return written, nil
 }
 
-// extractZipFile extracts a single file from a zip archive
-func extractZipFile(file *zip.File, destDir string) error {
+// extractZipFile extracts a single file from a zip archive, creating directories with dirMode
+func extractZipFile(file *zip.File, destDir string, dirMode os.FileMode) (int64, error) {
 	targetPath := filepath.Join(destDir, file.Name)
 
 	if !strings.HasPrefix(filepath.Clean(targetPath), filepath.Clean(destDir)) {
-		return fmt.Errorf("illegal file path in archive: %s", file.Name)
+		return 0, fmt.Errorf("illegal file path in archive: %s", file.Name)
 	}
 
 	if file.FileInfo().IsDir() {
-		return os.MkdirAll(targetPath, file.Mode())
+		return 0, util.MkdirAll(targetPath, dirMode)
 	}
 
-	if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
-		return fmt.Errorf("failed to create directory for %s: %w", targetPath, err)
+	if err := util.MkdirAll(filepath.Dir(targetPath), dirMode); err != nil {
+		return 0, fmt.Errorf("failed to create directory for %s: %w", targetPath, err)
 	}
 
 	fileReader, err := file.Open()
 	if err != nil {
-		return fmt.Errorf("failed to open file %s in archive: %w", file.Name, err)
+		return 0, fmt.Errorf("failed to open file %s in archive: %w", file.Name, err)
 	}
 	defer fileReader.Close()
 
 	outFile, err := os.Create(targetPath)
 	if err != nil {
-		return fmt.Errorf("failed to create file %s: %w", targetPath, err)
+		return 0, fmt.Errorf("failed to create file %s: %w", targetPath, err)
 	}
 	defer outFile.Close()
 
-	if _, err := io.Copy(outFile, fileReader); err != nil {
-		return fmt.Errorf("failed to extract file %s: %w", targetPath, err)
+	written, err := io.Copy(outFile, fileReader)
+	if err != nil {
+		return written, fmt.Errorf("failed to extract file %s: %w", targetPath, err)
 	}
 
 	if err := os.Chmod(targetPath, file.Mode()); err != nil {
-		return fmt.Errorf("failed to set permissions on %s: %w", targetPath, err)
+		return written, fmt.Errorf("failed to set permissions on %s: %w", targetPath, err)
+	}
+	outFile.Close()
+	if err := os.Chtimes(targetPath, file.Modified, file.Modified); err != nil {
+		return written, fmt.Errorf("failed to set modification time on %s: %w", targetPath, err)
 	}
 
-	return nil
+	return written, nil
 }
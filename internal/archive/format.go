@@ -2,7 +2,9 @@ package archive
 
 import (
 	"fmt"
+	"hash"
 	"io"
+	"os"
 	"strings"
 )
 
@@ -53,17 +55,55 @@ func (f Format) CreateArchiveWithGlob(srcDir string, writer io.Writer, globPatte
 	}
 }
 
+// CreateArchiveFromFiles creates a compressed archive based on the format containing exactly
+// the given files. Files are stored with paths relative to srcDir.
+func (f Format) CreateArchiveFromFiles(srcDir string, files []string, writer io.Writer) error {
+	return f.createArchiveFromFiles(srcDir, files, writer, nil)
+}
+
+// CreateArchiveFromFilesWithChecksums works like CreateArchiveFromFiles and also returns the
+// hex checksum of each file, keyed by its path in the archive. The checksums are computed
+// with newHash while the files are written, without reading them a second time.
+func (f Format) CreateArchiveFromFilesWithChecksums(srcDir string, files []string, writer io.Writer, newHash func() hash.Hash) (map[string]string, error) {
+	sums := &entryChecksums{newHash: newHash, sums: make(map[string]string, len(files))}
+	if err := f.createArchiveFromFiles(srcDir, files, writer, sums); err != nil {
+		return nil, err
+	}
+	return sums.sums, nil
+}
+
+func (f Format) createArchiveFromFiles(srcDir string, files []string, writer io.Writer, sums *entryChecksums) error {
+	switch f {
+	case FormatGzip:
+		return createTarGzFromFiles(srcDir, files, writer, sums)
+	case FormatZstd:
+		return createTarZstFromFiles(srcDir, files, writer, sums)
+	case FormatZip:
+		return createZipFromFiles(srcDir, files, writer, sums)
+	default:
+		return fmt.Errorf("unsupported compression format: %s", f)
+	}
+}
+
 // ExtractArchive extracts a compressed archive based on the format
 func (f Format) ExtractArchive(reader io.Reader, destDir string) error {
+	_, err := f.ExtractArchiveWithSize(reader, destDir, 0)
+	return err
+}
+
+// ExtractArchiveWithSize extracts a compressed archive based on the format and returns
+// the total number of content bytes written to the extracted files. Directories are
+// created with dirMode, see util.MkdirAll.
+func (f Format) ExtractArchiveWithSize(reader io.Reader, destDir string, dirMode os.FileMode) (int64, error) {
 	switch f {
 	case FormatGzip:
-		return ExtractTarGz(reader, destDir)
+		return extractTarGz(reader, destDir, dirMode)
 	case FormatZstd:
-		return ExtractTarZst(reader, destDir)
+		return extractTarZst(reader, destDir, dirMode)
 	case FormatZip:
-		return ExtractZip(reader, destDir)
+		return extractZip(reader, destDir, dirMode)
 	default:
-		return fmt.Errorf("unsupported compression format: %s", f)
+		return 0, fmt.Errorf("unsupported compression format: %s", f)
 	}
 }
 
@@ -1,6 +1,11 @@
 package archive
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -85,3 +90,33 @@ func TestDetectCompressionFromFilename(t *testing.T) {
 		})
 	}
 }
+
+func TestCreateArchiveFromFilesWithChecksums(t *testing.T) {
+	srcDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(srcDir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := []string{filepath.Join(srcDir, "a.txt"), filepath.Join(srcDir, "sub", "b.txt")}
+	for _, file := range files {
+		if err := os.WriteFile(file, []byte("content of "+filepath.Base(file)), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, format := range []Format{FormatGzip, FormatZstd, FormatZip} {
+		var buf bytes.Buffer
+		sums, err := format.CreateArchiveFromFilesWithChecksums(srcDir, files, &buf, sha256.New)
+		if err != nil {
+			t.Fatalf("%s: CreateArchiveFromFilesWithChecksums failed: %v", format, err)
+		}
+		for name, content := range map[string]string{"a.txt": "content of a.txt", "sub/b.txt": "content of b.txt"} {
+			sum := sha256.Sum256([]byte(content))
+			if sums[name] != hex.EncodeToString(sum[:]) {
+				t.Errorf("%s: unexpected checksum for %s: %q", format, name, sums[name])
+			}
+		}
+		if err := format.ExtractArchive(&buf, t.TempDir()); err != nil {
+			t.Errorf("%s: archive does not extract: %v", format, err)
+		}
+	}
+}
@@ -583,7 +583,7 @@ func TestAddFileToTarHelper(t *testing.T) {
 	var buf bytes.Buffer
 	tw := newTestTarWriter(&buf)
 
-	if err := addFileToTar(tw, testDir, testFile); err != nil {
+	if err := addFileToTar(tw, testDir, testFile, nil); err != nil {
 		t.Fatalf("addFileToTar failed: %v", err)
 	}
 
@@ -610,7 +610,7 @@ func TestAddFileToZipHelper(t *testing.T) {
 	var buf bytes.Buffer
 	zw := newTestZipWriter(&buf)
 
-	if err := addFileToZip(zw, testDir, testFile); err != nil {
+	if err := addFileToZip(zw, testDir, testFile, nil); err != nil {
 		t.Fatalf("addFileToZip failed: %v", err)
 	}
 
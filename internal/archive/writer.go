@@ -0,0 +1,72 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Writer writes an archive entry by entry from readers, so content that is not on disk,
+// such as a download in progress, is archived as it arrives
+type Writer struct {
+	tarWriter  *tar.Writer
+	zipWriter  *zip.Writer
+	compressor io.WriteCloser // Closed after the tar writer; nil for zip
+}
+
+// NewWriter starts an archive in format f on writer
+func (f Format) NewWriter(writer io.Writer) (*Writer, error) {
+	switch f {
+	case FormatGzip:
+		gzipWriter := gzip.NewWriter(writer)
+		return &Writer{tarWriter: tar.NewWriter(gzipWriter), compressor: gzipWriter}, nil
+	case FormatZstd:
+		zstdWriter, err := zstd.NewWriter(writer)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create zstd writer: %w", err)
+		}
+		return &Writer{tarWriter: tar.NewWriter(zstdWriter), compressor: zstdWriter}, nil
+	case FormatZip:
+		return &Writer{zipWriter: zip.NewWriter(writer)}, nil
+	default:
+		return nil, fmt.Errorf("unsupported compression format: %s", f)
+	}
+}
+
+// Add writes a file named name to the archive with the content read from reader. The size
+// is recorded in tar headers before the content is read, so reading any other number of
+// bytes from reader is an error.
+func (w *Writer) Add(name string, size int64, mode os.FileMode, modTime time.Time, reader io.Reader) error {
+	if w.zipWriter != nil {
+		header := &zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modTime}
+		header.SetMode(mode)
+		return writeZipEntry(w.zipWriter, header, reader)
+	}
+	return writeTarEntry(w.tarWriter, &tar.Header{
+		Name:     name,
+		Typeflag: tar.TypeReg,
+		Size:     size,
+		Mode:     int64(mode),
+		ModTime:  modTime,
+	}, reader)
+}
+
+// Close finishes the archive. It does not close the underlying writer.
+func (w *Writer) Close() error {
+	if w.zipWriter != nil {
+		return w.zipWriter.Close()
+	}
+	if err := w.tarWriter.Close(); err != nil {
+		return fmt.Errorf("failed to close tar writer: %w", err)
+	}
+	if err := w.compressor.Close(); err != nil {
+		return fmt.Errorf("failed to close compressor: %w", err)
+	}
+	return nil
+}
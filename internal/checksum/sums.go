@@ -0,0 +1,96 @@
+package checksum
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// SumsEntry is the digest listed for one file in a checksums file
+type SumsEntry struct {
+	Algorithm string // Inferred from the digest length: md5, sha1, sha256 or sha512
+	Digest    string // Lowercase hex
+}
+
+// ParseSumsFile parses a checksums file in the format written by sha256sum and its
+// siblings: one "<hex digest>  <file name>" line per file, with "*" before the name for
+// files hashed in binary mode. Names containing a backslash or newline are escaped the
+// way GNU coreutils does it, by a leading "\" on the line. Blank lines and lines starting
+// with "#" are ignored. Names are returned as clean slash-separated paths without a
+// leading "./", and the algorithm of each entry is inferred from its digest length.
+func ParseSumsFile(r io.Reader) (map[string]SumsEntry, error) {
+	entries := make(map[string]SumsEntry)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		escaped := strings.HasPrefix(line, "\\")
+		if escaped {
+			line = line[1:]
+		}
+		digest, name, ok := strings.Cut(line, " ")
+		if !ok || (!strings.HasPrefix(name, " ") && !strings.HasPrefix(name, "*")) {
+			return nil, fmt.Errorf("line %d: expected '<digest>  <file name>'", lineNum)
+		}
+		name = name[1:]
+		if escaped {
+			name = unescapeSumsName(name)
+		}
+		if name == "" {
+			return nil, fmt.Errorf("line %d: missing file name", lineNum)
+		}
+
+		algorithm := ""
+		for alg, length := range digestLengths {
+			if len(digest) == length {
+				algorithm = alg
+			}
+		}
+		if algorithm == "" {
+			return nil, fmt.Errorf("line %d: digest %q does not have the length of an md5, sha1, sha256 or sha512 digest", lineNum, digest)
+		}
+		normalized, err := NormalizeChecksum(digest, algorithm)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNum, err)
+		}
+
+		name = strings.TrimPrefix(path.Clean("/"+name), "/")
+		if previous, exists := entries[name]; exists && previous.Digest != normalized {
+			return nil, fmt.Errorf("line %d: %s is listed twice with different digests", lineNum, name)
+		}
+		entries[name] = SumsEntry{Algorithm: algorithm, Digest: normalized}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// unescapeSumsName reverses the escaping of "\\" and "\n" in file names of escaped lines
+func unescapeSumsName(name string) string {
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		if name[i] == '\\' && i+1 < len(name) {
+			switch name[i+1] {
+			case '\\':
+				b.WriteByte('\\')
+				i++
+				continue
+			case 'n':
+				b.WriteByte('\n')
+				i++
+				continue
+			}
+		}
+		b.WriteByte(name[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,53 @@
+package checksum
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseSumsFile(t *testing.T) {
+	sha256Hello := "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+	sha1Hello := "aaf4c61ddcc5e8a2dabede0f3b482cd9aea9434d"
+	input := "# release 1.0\n" +
+		sha256Hello + "  app.tar.gz\n" +
+		strings.ToUpper(sha256Hello) + " *bin/tool.exe\r\n" +
+		"\n" +
+		sha1Hello + "  ./docs/readme.txt\n" +
+		"\\" + sha256Hello + "  dir\\\\name\\nwith newline\n"
+
+	entries, err := ParseSumsFile(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParseSumsFile failed: %v", err)
+	}
+
+	expected := map[string]SumsEntry{
+		"app.tar.gz":              {Algorithm: "sha256", Digest: sha256Hello},
+		"bin/tool.exe":            {Algorithm: "sha256", Digest: sha256Hello},
+		"docs/readme.txt":         {Algorithm: "sha1", Digest: sha1Hello},
+		"dir\\name\nwith newline": {Algorithm: "sha256", Digest: sha256Hello},
+	}
+	if len(entries) != len(expected) {
+		t.Errorf("Expected %d entries, got %d: %v", len(expected), len(entries), entries)
+	}
+	for name, entry := range expected {
+		if entries[name] != entry {
+			t.Errorf("Entry %q = %+v, want %+v", name, entries[name], entry)
+		}
+	}
+}
+
+func TestParseSumsFileErrors(t *testing.T) {
+	sha256Hello := "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+	tests := map[string]string{
+		"missing name":        sha256Hello + "\n",
+		"single space":        sha256Hello + " app.tar.gz\n",
+		"odd digest length":   "abc123  app.tar.gz\n",
+		"not hexadecimal":     strings.Repeat("z", 64) + "  app.tar.gz\n",
+		"conflicting entries": sha256Hello + "  a\n" + strings.Repeat("0", 64) + "  ./a\n",
+	}
+	for name, input := range tests {
+		if _, err := ParseSumsFile(strings.NewReader(input)); err == nil {
+			t.Errorf("%s: expected an error for %q", name, input)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package checksum
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ComputeTreeChecksum computes a single root checksum over every regular file below root.
+//
+// The aggregation is defined as follows:
+//  1. Collect all regular files below root (directories and symlinks are skipped)
+//     and express their paths relative to root using forward slashes.
+//  2. Sort the relative paths by byte order.
+//  3. For each path, compute the file checksum with ComputeChecksum as lowercase hex
+//     and write the line "<file-checksum>  <relative-path>\n" into a fresh hash of
+//     the same algorithm.
+//  4. The result is "<algorithm>:<lowercase hex of that hash>".
+//
+// The line format matches the output of tools like sha256sum, so the same value can be
+// reproduced with `find . -type f | cut -c3- | LC_ALL=C sort | xargs sha256sum | sha256sum`.
+func ComputeTreeChecksum(root string, algorithm string) (string, error) {
+	alg := strings.ToLower(algorithm)
+	h, err := NewHasher(alg)
+	if err != nil {
+		return "", err
+	}
+
+	var paths []string
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		relPath, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		paths = append(paths, filepath.ToSlash(relPath))
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	sort.Strings(paths)
+
+	for _, relPath := range paths {
+		if strings.ContainsAny(relPath, "\n\r") {
+			return "", fmt.Errorf("cannot compute tree checksum: file name contains a line break: %q", relPath)
+		}
+		fileChecksum, err := ComputeChecksum(filepath.Join(root, filepath.FromSlash(relPath)), alg)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "%s  %s\n", fileChecksum, relPath)
+	}
+
+	return fmt.Sprintf("%s:%x", alg, h.Sum(nil)), nil
+}
+
+// ComputeDirectoryKey computes a cache key over the files below root, for use as {key}.
+// include is called with each path relative to root (forward slashes) and leaves out
+// the entries for which it returns false; nil includes everything.
+//
+// The key is the lowercase hex hash of these lines, in byte order of the relative paths:
+//   - "<file-checksum>  <relative-path>\n" for a regular file, as in ComputeTreeChecksum
+//   - "link <quoted-target>  <relative-path>\n" for a symbolic link. Links are not followed:
+//     the key changes when a link is retargeted, not when the file it points to changes.
+//
+// Directories contribute nothing, so empty directories do not affect the key, and other
+// file types such as sockets are left out. Names and contents are hashed but not
+// timestamps or permissions, so touching a file keeps the key while renaming it changes it.
+func ComputeDirectoryKey(root string, algorithm string, include func(relPath string) (bool, error)) (string, error) {
+	alg := strings.ToLower(algorithm)
+	h, err := NewHasher(alg)
+	if err != nil {
+		return "", err
+	}
+
+	entries := make(map[string]fs.FileMode)
+	var paths []string
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		relPath, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		relPath = filepath.ToSlash(relPath)
+		if include != nil {
+			ok, err := include(relPath)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return nil
+			}
+		}
+		entries[relPath] = d.Type()
+		paths = append(paths, relPath)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	sort.Strings(paths)
+
+	for _, relPath := range paths {
+		if strings.ContainsAny(relPath, "\n\r") {
+			return "", fmt.Errorf("cannot compute key: file name contains a line break: %q", relPath)
+		}
+		path := filepath.Join(root, filepath.FromSlash(relPath))
+		switch mode := entries[relPath]; {
+		case mode&fs.ModeSymlink != 0:
+			target, err := os.Readlink(path)
+			if err != nil {
+				return "", err
+			}
+			fmt.Fprintf(h, "link %q  %s\n", filepath.ToSlash(target), relPath)
+		case mode.IsRegular():
+			fileChecksum, err := ComputeChecksum(path, alg)
+			if err != nil {
+				return "", err
+			}
+			fmt.Fprintf(h, "%s  %s\n", fileChecksum, relPath)
+		}
+	}
+
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// ParseTreeChecksum splits a tree checksum of the form "<algorithm>:<hex>" into its parts
+func ParseTreeChecksum(value string) (algorithm string, digest string, err error) {
+	algorithm, digest, ok := strings.Cut(strings.TrimSpace(value), ":")
+	if !ok || digest == "" {
+		return "", "", fmt.Errorf("invalid tree checksum '%s': expected format '<algorithm>:<hex>'", value)
+	}
+	if _, err := NewHasher(algorithm); err != nil {
+		return "", "", err
+	}
+	return strings.ToLower(algorithm), strings.ToLower(digest), nil
+}
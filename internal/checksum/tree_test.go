@@ -0,0 +1,302 @@
+package checksum
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeTreeFiles(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestComputeTreeChecksumStable(t *testing.T) {
+	files := map[string]string{
+		"b.txt":           "bravo",
+		"a.txt":           "alpha",
+		"sub/c.txt":       "charlie",
+		"sub/deep/d.txt":  "delta",
+		"sub-sibling.txt": "echo",
+	}
+
+	dir1 := t.TempDir()
+	dir2 := t.TempDir()
+	writeTreeFiles(t, dir1, files)
+	writeTreeFiles(t, dir2, files)
+
+	first, err := ComputeTreeChecksum(dir1, "sha256")
+	if err != nil {
+		t.Fatalf("ComputeTreeChecksum failed: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		again, err := ComputeTreeChecksum(dir1, "sha256")
+		if err != nil {
+			t.Fatalf("ComputeTreeChecksum failed: %v", err)
+		}
+		if again != first {
+			t.Errorf("Tree checksum not stable across runs: %s != %s", again, first)
+		}
+	}
+
+	other, err := ComputeTreeChecksum(dir2, "sha256")
+	if err != nil {
+		t.Fatalf("ComputeTreeChecksum failed: %v", err)
+	}
+	if other != first {
+		t.Errorf("Identical trees in different locations produced different checksums: %s != %s", other, first)
+	}
+}
+
+func TestComputeTreeChecksumAlgorithm(t *testing.T) {
+	dir := t.TempDir()
+	writeTreeFiles(t, dir, map[string]string{
+		"z.txt":     "zulu",
+		"a/b.txt":   "bravo",
+		"a.txt":     "alpha",
+		"a-b/c.txt": "charlie",
+	})
+
+	// Expected value computed by hand following the documented algorithm
+	var manifest strings.Builder
+	for _, entry := range []struct{ path, content string }{
+		{"a-b/c.txt", "charlie"},
+		{"a.txt", "alpha"},
+		{"a/b.txt", "bravo"},
+		{"z.txt", "zulu"},
+	} {
+		fmt.Fprintf(&manifest, "%x  %s\n", sha256.Sum256([]byte(entry.content)), entry.path)
+	}
+	expected := fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(manifest.String())))
+
+	actual, err := ComputeTreeChecksum(dir, "SHA256")
+	if err != nil {
+		t.Fatalf("ComputeTreeChecksum failed: %v", err)
+	}
+	if actual != expected {
+		t.Errorf("Expected %s, got %s", expected, actual)
+	}
+}
+
+func TestComputeTreeChecksumDetectsChanges(t *testing.T) {
+	files := map[string]string{
+		"a.txt":     "alpha",
+		"sub/b.txt": "bravo",
+	}
+
+	tests := []struct {
+		name   string
+		modify func(t *testing.T, dir string)
+	}{
+		{
+			name: "content changed",
+			modify: func(t *testing.T, dir string) {
+				writeTreeFiles(t, dir, map[string]string{"a.txt": "ALPHA"})
+			},
+		},
+		{
+			name: "file renamed",
+			modify: func(t *testing.T, dir string) {
+				if err := os.Rename(filepath.Join(dir, "a.txt"), filepath.Join(dir, "renamed.txt")); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+		{
+			name: "file added",
+			modify: func(t *testing.T, dir string) {
+				writeTreeFiles(t, dir, map[string]string{"sub/extra.txt": ""})
+			},
+		},
+		{
+			name: "file removed",
+			modify: func(t *testing.T, dir string) {
+				if err := os.Remove(filepath.Join(dir, "sub", "b.txt")); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeTreeFiles(t, dir, files)
+			before, err := ComputeTreeChecksum(dir, "sha1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.modify(t, dir)
+			after, err := ComputeTreeChecksum(dir, "sha1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if before == after {
+				t.Errorf("Expected tree checksum to change, got %s both times", before)
+			}
+		})
+	}
+}
+
+func TestParseTreeChecksum(t *testing.T) {
+	tests := []struct {
+		value      string
+		wantAlg    string
+		wantDigest string
+		wantErr    bool
+	}{
+		{value: "sha256:ABCDEF", wantAlg: "sha256", wantDigest: "abcdef"},
+		{value: " md5:0123 ", wantAlg: "md5", wantDigest: "0123"},
+		{value: "abcdef", wantErr: true},
+		{value: "sha256:", wantErr: true},
+		{value: "crc32:abcdef", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			alg, digest, err := ParseTreeChecksum(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected error for %q, got nil", tt.value)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error for %q: %v", tt.value, err)
+			}
+			if alg != tt.wantAlg || digest != tt.wantDigest {
+				t.Errorf("Expected %s:%s, got %s:%s", tt.wantAlg, tt.wantDigest, alg, digest)
+			}
+		})
+	}
+}
+
+func TestComputeDirectoryKey(t *testing.T) {
+	root := t.TempDir()
+	writeTreeFiles(t, root, map[string]string{
+		"main.go":       "package main",
+		"lib/util.go":   "package lib",
+		"lib/notes.txt": "notes",
+	})
+
+	key := func() string {
+		t.Helper()
+		k, err := ComputeDirectoryKey(root, "sha256", nil)
+		if err != nil {
+			t.Fatalf("ComputeDirectoryKey failed: %v", err)
+		}
+		return k
+	}
+	original := key()
+	if len(original) != 64 {
+		t.Fatalf("Expected a sha256 hex key, got %q", original)
+	}
+
+	// Touching a file keeps the key
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(root, "main.go"), later, later); err != nil {
+		t.Fatal(err)
+	}
+	if k := key(); k != original {
+		t.Errorf("Expected touching a file to keep the key, got %s instead of %s", k, original)
+	}
+
+	// Empty directories do not count
+	if err := os.MkdirAll(filepath.Join(root, "empty", "nested"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if k := key(); k != original {
+		t.Errorf("Expected an empty directory to keep the key, got %s instead of %s", k, original)
+	}
+
+	// Renaming a file changes the key, and renaming it back restores it
+	if err := os.Rename(filepath.Join(root, "lib", "notes.txt"), filepath.Join(root, "lib", "readme.txt")); err != nil {
+		t.Fatal(err)
+	}
+	renamed := key()
+	if renamed == original {
+		t.Error("Expected renaming a file to change the key")
+	}
+	if err := os.Rename(filepath.Join(root, "lib", "readme.txt"), filepath.Join(root, "lib", "notes.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if k := key(); k != original {
+		t.Errorf("Expected the original key after renaming back, got %s", k)
+	}
+
+	// Changing content changes the key
+	writeTreeFiles(t, root, map[string]string{"lib/util.go": "package lib // changed"})
+	if k := key(); k == original {
+		t.Error("Expected changed content to change the key")
+	}
+}
+
+func TestComputeDirectoryKeyInclude(t *testing.T) {
+	root := t.TempDir()
+	writeTreeFiles(t, root, map[string]string{"a.go": "a", "b.txt": "b"})
+	onlyGo := func(relPath string) (bool, error) { return strings.HasSuffix(relPath, ".go"), nil }
+
+	before, err := ComputeDirectoryKey(root, "sha256", onlyGo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeTreeFiles(t, root, map[string]string{"b.txt": "changed", "c.txt": "new"})
+	after, err := ComputeDirectoryKey(root, "sha256", onlyGo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if before != after {
+		t.Error("Expected excluded files not to affect the key")
+	}
+}
+
+func TestComputeDirectoryKeySymlinks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks require privileges on Windows")
+	}
+	root := t.TempDir()
+	outside := t.TempDir()
+	writeTreeFiles(t, root, map[string]string{"a.txt": "alpha", "b.txt": "bravo"})
+	writeTreeFiles(t, outside, map[string]string{"shared.txt": "shared"})
+	if err := os.Symlink(filepath.Join(outside, "shared.txt"), filepath.Join(root, "shared")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("a.txt", filepath.Join(root, "current")); err != nil {
+		t.Fatal(err)
+	}
+
+	linked, err := ComputeDirectoryKey(root, "sha256", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Links are not followed: the content behind a link does not affect the key
+	writeTreeFiles(t, outside, map[string]string{"shared.txt": "changed"})
+	if k, _ := ComputeDirectoryKey(root, "sha256", nil); k != linked {
+		t.Error("Expected the key to ignore the content a symlink points to")
+	}
+
+	// Retargeting a link changes the key
+	if err := os.Remove(filepath.Join(root, "current")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("b.txt", filepath.Join(root, "current")); err != nil {
+		t.Fatal(err)
+	}
+	if k, _ := ComputeDirectoryKey(root, "sha256", nil); k == linked {
+		t.Error("Expected retargeting a symlink to change the key")
+	}
+}
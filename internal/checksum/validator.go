@@ -5,6 +5,9 @@ import (
 	"crypto/sha1"
 	"crypto/sha256"
 	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"hash"
 	"io"
@@ -14,6 +17,17 @@ import (
 	"github.com/tympanix/nexus-cli/internal/nexusapi"
 )
 
+// ErrMalformedChecksum is returned when a checksum is not a valid hex digest for its algorithm
+var ErrMalformedChecksum = errors.New("malformed checksum")
+
+// digestLengths holds the length of the hex encoded digest for each supported algorithm
+var digestLengths = map[string]int{
+	"md5":    32,
+	"sha1":   40,
+	"sha256": 64,
+	"sha512": 128,
+}
+
 // Validator interface for checksum validation
 type Validator interface {
 	Validate(filePath string, expected nexusapi.Checksum) (bool, error)
@@ -40,13 +54,17 @@ func (v *validator) ValidateWithProgress(filePath string, expected nexusapi.Chec
 	if expectedChecksum == "" {
 		return false, fmt.Errorf("no %s checksum available for validation", v.algorithm)
 	}
+	// Reject a malformed server value up front instead of reporting a mismatch
+	if _, err := NormalizeChecksum(expectedChecksum, v.algorithm); err != nil {
+		return false, fmt.Errorf("server provided %w", err)
+	}
 
 	actualChecksum, err := v.computeChecksumWithProgress(filePath, progress)
 	if err != nil {
 		return false, err
 	}
 
-	return strings.EqualFold(actualChecksum, expectedChecksum), nil
+	return Matches(expectedChecksum, actualChecksum, v.algorithm)
 }
 
 func (v *validator) computeChecksum(filePath string) (string, error) {
@@ -101,27 +119,87 @@ func NewValidator(algorithm string) (Validator, error) {
 	}
 }
 
-// ComputeChecksum computes the checksum of a file using the specified algorithm
-func ComputeChecksum(filePath string, algorithm string) (string, error) {
-	return ComputeChecksumWithProgress(filePath, algorithm, io.Discard)
+// fallbackAlgorithms are tried, strongest first, when a checksum set has no value for the
+// preferred algorithm
+var fallbackAlgorithms = []string{"sha512", "sha256", "sha1", "md5"}
+
+// AvailableAlgorithm returns preferred if the checksum set has a value for it, and otherwise
+// the strongest algorithm that it has a value for. It returns "" for an empty set.
+func AvailableAlgorithm(c nexusapi.Checksum, preferred string) string {
+	if ExtractChecksum(c, preferred) != "" {
+		return strings.ToLower(preferred)
+	}
+	for _, algorithm := range fallbackAlgorithms {
+		if ExtractChecksum(c, algorithm) != "" {
+			return algorithm
+		}
+	}
+	return ""
 }
 
-// ComputeChecksumWithProgress computes the checksum of a file using the specified algorithm with progress tracking
-func ComputeChecksumWithProgress(filePath string, algorithm string, progress io.Writer) (string, error) {
-	var h hash.Hash
+// ExtractChecksum returns the checksum for the specified algorithm from a Nexus checksum set.
+// It returns an empty string if the algorithm is unsupported or the value is missing.
+func ExtractChecksum(c nexusapi.Checksum, algorithm string) string {
 	switch strings.ToLower(algorithm) {
 	case "sha1":
-		h = sha1.New()
+		return c.SHA1
 	case "sha256":
-		h = sha256.New()
+		return c.SHA256
 	case "sha512":
-		h = sha512.New()
+		return c.SHA512
 	case "md5":
-		h = md5.New()
+		return c.MD5
 	default:
+		return ""
+	}
+}
+
+// NormalizeChecksum trims surrounding whitespace from a hex digest and lowercases it.
+// It returns an error wrapping ErrMalformedChecksum if the value is not hex or does not
+// have the length of a digest for the algorithm.
+func NormalizeChecksum(value string, algorithm string) (string, error) {
+	alg := strings.ToLower(algorithm)
+	length, ok := digestLengths[alg]
+	if !ok {
 		return "", fmt.Errorf("unsupported checksum algorithm '%s'", algorithm)
 	}
 
+	normalized := strings.ToLower(strings.TrimSpace(value))
+	if len(normalized) != length {
+		return "", fmt.Errorf("%w: %s value %q has %d characters, expected %d", ErrMalformedChecksum, alg, value, len(normalized), length)
+	}
+	if _, err := hex.DecodeString(normalized); err != nil {
+		return "", fmt.Errorf("%w: %s value %q is not hexadecimal", ErrMalformedChecksum, alg, value)
+	}
+	return normalized, nil
+}
+
+// Matches reports whether the expected and actual checksums are the same digest.
+// Both values are normalized first and compared in constant time.
+func Matches(expected string, actual string, algorithm string) (bool, error) {
+	normalizedExpected, err := NormalizeChecksum(expected, algorithm)
+	if err != nil {
+		return false, err
+	}
+	normalizedActual, err := NormalizeChecksum(actual, algorithm)
+	if err != nil {
+		return false, err
+	}
+	return subtle.ConstantTimeCompare([]byte(normalizedExpected), []byte(normalizedActual)) == 1, nil
+}
+
+// ComputeChecksum computes the checksum of a file using the specified algorithm
+func ComputeChecksum(filePath string, algorithm string) (string, error) {
+	return ComputeChecksumWithProgress(filePath, algorithm, io.Discard)
+}
+
+// ComputeChecksumWithProgress computes the checksum of a file using the specified algorithm with progress tracking
+func ComputeChecksumWithProgress(filePath string, algorithm string, progress io.Writer) (string, error) {
+	h, err := NewHasher(algorithm)
+	if err != nil {
+		return "", err
+	}
+
 	file, err := os.Open(filePath)
 	if err != nil {
 		return "", err
@@ -134,3 +212,20 @@ func ComputeChecksumWithProgress(filePath string, algorithm string, progress io.
 	}
 	return fmt.Sprintf("%x", h.Sum(nil)), nil
 }
+
+// NewHasher returns a fresh hash for the specified algorithm. It is used to compute a
+// checksum while data is streamed, e.g. while a download is written to disk.
+func NewHasher(algorithm string) (hash.Hash, error) {
+	switch strings.ToLower(algorithm) {
+	case "sha1":
+		return sha1.New(), nil
+	case "sha256":
+		return sha256.New(), nil
+	case "sha512":
+		return sha512.New(), nil
+	case "md5":
+		return md5.New(), nil
+	default:
+		return nil, fmt.Errorf("unsupported checksum algorithm '%s'", algorithm)
+	}
+}
@@ -1,8 +1,11 @@
 package checksum
 
 import (
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/tympanix/nexus-cli/internal/nexusapi"
@@ -111,11 +114,47 @@ func TestChecksumValidatorValidate(t *testing.T) {
 			name:      "invalid sha1",
 			algorithm: "sha1",
 			checksums: nexusapi.Checksum{
-				SHA1: "wrongchecksum",
+				SHA1: "0000000000000000000000000000000000000000",
 			},
 			wantValid: false,
 			wantErr:   false,
 		},
+		{
+			name:      "uppercase sha1",
+			algorithm: "sha1",
+			checksums: nexusapi.Checksum{
+				SHA1: "D38A2973B20670764496E490A7F638302EB96602",
+			},
+			wantValid: true,
+			wantErr:   false,
+		},
+		{
+			name:      "sha1 with surrounding whitespace",
+			algorithm: "sha1",
+			checksums: nexusapi.Checksum{
+				SHA1: " d38a2973b20670764496e490a7f638302eb96602\n",
+			},
+			wantValid: true,
+			wantErr:   false,
+		},
+		{
+			name:      "wrong length sha1",
+			algorithm: "sha1",
+			checksums: nexusapi.Checksum{
+				SHA1: "wrongchecksum",
+			},
+			wantValid: false,
+			wantErr:   true,
+		},
+		{
+			name:      "non-hex sha1",
+			algorithm: "sha1",
+			checksums: nexusapi.Checksum{
+				SHA1: "z38a2973b20670764496e490a7f638302eb96602",
+			},
+			wantValid: false,
+			wantErr:   true,
+		},
 		{
 			name:      "valid sha256",
 			algorithm: "sha256",
@@ -167,6 +206,56 @@ func TestChecksumValidatorValidate(t *testing.T) {
 	}
 }
 
+func TestNormalizeChecksum(t *testing.T) {
+	tests := []struct {
+		value     string
+		algorithm string
+		want      string
+		wantErr   bool
+	}{
+		{value: "1786A2D74A141E8CA2D371A0B519EBC3", algorithm: "md5", want: "1786a2d74a141e8ca2d371a0b519ebc3"},
+		{value: "\t1786a2d74a141e8ca2d371a0b519ebc3 ", algorithm: "MD5", want: "1786a2d74a141e8ca2d371a0b519ebc3"},
+		{value: "1786a2d74a141e8ca2d371a0b519ebc3", algorithm: "sha1", wantErr: true},
+		{value: "", algorithm: "sha256", wantErr: true},
+		{value: "1786a2d74a141e8ca2d371a0b519ebc3", algorithm: "crc32", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := NormalizeChecksum(tt.value, tt.algorithm)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("NormalizeChecksum(%q, %q) expected error", tt.value, tt.algorithm)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("NormalizeChecksum(%q, %q) unexpected error: %v", tt.value, tt.algorithm, err)
+		}
+		if got != tt.want {
+			t.Errorf("NormalizeChecksum(%q, %q) = %q, want %q", tt.value, tt.algorithm, got, tt.want)
+		}
+	}
+}
+
+func TestChecksumValidatorValidateMalformedServerChecksum(t *testing.T) {
+	testFile := filepath.Join(t.TempDir(), "test.txt")
+	if err := os.WriteFile(testFile, []byte("content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	validator, err := NewValidator("sha256")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = validator.Validate(testFile, nexusapi.Checksum{SHA256: "abc123"})
+	if !errors.Is(err, ErrMalformedChecksum) {
+		t.Fatalf("Expected ErrMalformedChecksum, got: %v", err)
+	}
+	if !strings.Contains(err.Error(), "server provided") || !strings.Contains(err.Error(), "expected 64") {
+		t.Errorf("Expected error to describe the malformed server value, got: %v", err)
+	}
+}
+
 func TestChecksumValidatorValidateNonExistentFile(t *testing.T) {
 	validator, err := NewValidator("sha1")
 	if err != nil {
@@ -267,3 +356,36 @@ func (bc *bytesCounter) Write(p []byte) (n int, err error) {
 	bc.bytesWritten += int64(len(p))
 	return len(p), nil
 }
+
+func TestNewHasher(t *testing.T) {
+	h, err := NewHasher("SHA256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Write([]byte("hello world"))
+	if got := fmt.Sprintf("%x", h.Sum(nil)); got != "b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9" {
+		t.Errorf("Unexpected sha256 digest: %s", got)
+	}
+	if _, err := NewHasher("crc32"); err == nil {
+		t.Error("Expected error for unsupported algorithm")
+	}
+}
+
+func TestAvailableAlgorithm(t *testing.T) {
+	tests := []struct {
+		checksum  nexusapi.Checksum
+		preferred string
+		want      string
+	}{
+		{nexusapi.Checksum{SHA1: "a", SHA256: "b"}, "sha1", "sha1"},
+		{nexusapi.Checksum{SHA1: "a", SHA256: "b"}, "SHA256", "sha256"},
+		{nexusapi.Checksum{SHA256: "b"}, "sha1", "sha256"},
+		{nexusapi.Checksum{MD5: "c", SHA1: "a"}, "sha512", "sha1"},
+		{nexusapi.Checksum{}, "sha1", ""},
+	}
+	for _, tt := range tests {
+		if got := AvailableAlgorithm(tt.checksum, tt.preferred); got != tt.want {
+			t.Errorf("AvailableAlgorithm(%+v, %q) = %q, want %q", tt.checksum, tt.preferred, got, tt.want)
+		}
+	}
+}
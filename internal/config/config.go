@@ -1,28 +1,133 @@
 package config
 
 import (
+	"crypto/tls"
+	"fmt"
 	"os"
+	"strings"
 )
 
 // Config holds the configuration for connecting to Nexus
 type Config struct {
-	NexusURL string
-	Username string
-	Password string
+	NexusURL   string
+	Username   string
+	Password   string
+	UnixSocket string // Connect through this Unix domain socket instead of the host of NexusURL
+	CACert     string // PEM file with CA certificates to trust in addition to the system ones
+	Insecure   bool   // Skip verification of the server certificate
+	Token      string // Bearer token sent instead of Username and Password
+
+	// Headers are sent with every request to the server, from its [host] section
+	Headers map[string]string
+
+	// RepositoryAliases maps short names to repository names, from the [aliases] section
+	// of the config file and --repository-alias
+	RepositoryAliases map[string]string
+
+	sources map[string]Source
+	tls     *tls.Config // Built from CACert and Insecure by LoadTLS
+}
+
+// Keys of the Config values whose Source is tracked, named after their command line flags
+const (
+	KeyURL        = "url"
+	KeyUsername   = "username"
+	KeyPassword   = "password"
+	KeyUnixSocket = "unix-socket"
+	KeyCACert     = "ca-cert"
+	KeyInsecure   = "insecure"
+	KeyToken      = "token"
+	KeyHeaders    = "headers"
+)
+
+// Source describes where a configuration value came from, e.g. "flag --url",
+// "env NEXUS_URL", "file /home/ci/.config/nexuscli/credentials" or "default"
+type Source string
+
+// SourceDefault is the Source of a built-in default
+const SourceDefault Source = "default"
+
+// FlagSource is the Source of a value given by the command line flag --name
+func FlagSource(name string) Source {
+	return Source("flag --" + name)
+}
+
+// EnvSource is the Source of a value read from the environment variable name
+func EnvSource(name string) Source {
+	return Source("env " + name)
+}
+
+// FileSource is the Source of a value read from the file at path
+func FileSource(path string) Source {
+	return Source("file " + path)
 }
 
 // NewConfig creates a new Config with values from environment variables or defaults
 func NewConfig() *Config {
-	return &Config{
-		NexusURL: getenv("NEXUS_URL", "http://localhost:8081"),
-		Username: getenv("NEXUS_USER", "admin"),
-		Password: getenv("NEXUS_PASS", "admin"),
+	c := &Config{}
+	c.NexusURL = c.getenv(KeyURL, "NEXUS_URL", "http://localhost:8081")
+	c.Username = c.getenv(KeyUsername, "NEXUS_USER", "admin")
+	c.Password = c.getenv(KeyPassword, "NEXUS_PASS", "admin")
+	c.UnixSocket = c.getenv(KeyUnixSocket, "NEXUS_UNIX_SOCKET", "")
+	return c
+}
+
+// SetSource records where the value for key came from
+func (c *Config) SetSource(key string, source Source) {
+	if c.sources == nil {
+		c.sources = make(map[string]Source)
+	}
+	c.sources[key] = source
+}
+
+// Source returns where the value for key came from. Values set without SetSource, e.g.
+// in a Config literal, are reported as defaults.
+func (c *Config) Source(key string) Source {
+	if source, ok := c.sources[key]; ok {
+		return source
 	}
+	return SourceDefault
 }
 
-func getenv(key, fallback string) string {
-	if v := os.Getenv(key); v != "" {
+// getenv returns the environment variable name, or fallback if it is not set, and
+// records which one was used for key
+func (c *Config) getenv(key, name, fallback string) string {
+	if v := os.Getenv(name); v != "" {
+		c.SetSource(key, EnvSource(name))
 		return v
 	}
+	c.SetSource(key, SourceDefault)
 	return fallback
 }
+
+// SetRepositoryAlias makes name an alias of repository, replacing an earlier alias of
+// the same name
+func (c *Config) SetRepositoryAlias(name, repository string) error {
+	name, repository = strings.TrimSpace(name), strings.Trim(strings.TrimSpace(repository), "/")
+	if name == "" || strings.Contains(name, "/") {
+		return fmt.Errorf("invalid repository alias '%s': must be a name without '/'", name)
+	}
+	if repository == "" || strings.Contains(repository, "/") {
+		return fmt.Errorf("invalid repository for alias '%s': must be a repository name without '/'", name)
+	}
+	if c.RepositoryAliases == nil {
+		c.RepositoryAliases = make(map[string]string)
+	}
+	c.RepositoryAliases[name] = repository
+	return nil
+}
+
+// ExpandRepositoryAlias replaces an alias in the repository part of target, in the form
+// repository[/path], with the repository it stands for. A name that is not an alias is
+// returned unchanged, as a literal repository name.
+func (c *Config) ExpandRepositoryAlias(target string) string {
+	name, rest, hasPath := strings.Cut(target, "/")
+	repository, ok := c.RepositoryAliases[name]
+	if !ok {
+		return target
+	}
+	if hasPath {
+		return repository + "/" + rest
+	}
+	return repository
+}
@@ -0,0 +1,158 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/go-ini/ini"
+)
+
+// Credentials authenticate against a Nexus server. A Nexus user token is stored the
+// same way, with its name code as username and its pass code as password.
+type Credentials struct {
+	Username string
+	Password string
+}
+
+// CredentialStore keeps credentials for several Nexus servers in an INI file with one
+// section per server URL. The file is only readable by its owner.
+type CredentialStore struct {
+	Path string
+}
+
+// DefaultCredentialStore returns the store at $XDG_CONFIG_HOME/nexuscli/credentials,
+// or the platform's user configuration directory if XDG_CONFIG_HOME is not set
+func DefaultCredentialStore() (*CredentialStore, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return nil, fmt.Errorf("cannot locate credentials file: %w", err)
+	}
+	return &CredentialStore{Path: filepath.Join(dir, "nexuscli", "credentials")}, nil
+}
+
+// normalizeURL returns the key under which credentials for a Nexus URL are stored, so
+// that e.g. "HTTPS://nexus.example.com/" and "https://nexus.example.com" share an entry
+func normalizeURL(nexusURL string) string {
+	u, err := url.Parse(strings.TrimSpace(nexusURL))
+	if err != nil || u.Host == "" {
+		return strings.TrimRight(strings.TrimSpace(nexusURL), "/")
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	u.User = nil
+	u.Path = strings.TrimRight(u.Path, "/")
+	u.RawQuery, u.Fragment = "", ""
+	return u.String()
+}
+
+// load reads the store. A missing file is an empty store. A file that other users can
+// access is refused, since its credentials may already have leaked.
+func (s *CredentialStore) load() (*ini.File, error) {
+	info, err := os.Stat(s.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return ini.Empty(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0077 != 0 {
+		return nil, fmt.Errorf("credentials file %s is accessible by other users (mode %04o); run 'chmod 600 %s'", s.Path, info.Mode().Perm(), s.Path)
+	}
+	file, err := ini.Load(s.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read credentials file %s: %w", s.Path, err)
+	}
+	return file, nil
+}
+
+// save writes the store with owner-only permissions. It is written under a temporary
+// name and renamed, so an interrupted write never leaves a truncated store behind.
+func (s *CredentialStore) save(file *ini.File) error {
+	dir := filepath.Dir(s.Path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, ".credentials-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := file.WriteTo(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.Path)
+}
+
+// Get returns the credentials stored for nexusURL
+func (s *CredentialStore) Get(nexusURL string) (Credentials, bool, error) {
+	file, err := s.load()
+	if err != nil {
+		return Credentials{}, false, err
+	}
+	section, err := file.GetSection(normalizeURL(nexusURL))
+	if err != nil {
+		return Credentials{}, false, nil
+	}
+	return Credentials{
+		Username: section.Key("username").String(),
+		Password: section.Key("password").String(),
+	}, true, nil
+}
+
+// Set stores credentials for nexusURL, replacing any previous entry
+func (s *CredentialStore) Set(nexusURL string, creds Credentials) error {
+	file, err := s.load()
+	if err != nil {
+		return err
+	}
+	key := normalizeURL(nexusURL)
+	file.DeleteSection(key)
+	section, err := file.NewSection(key)
+	if err != nil {
+		return err
+	}
+	section.Key("username").SetValue(creds.Username)
+	section.Key("password").SetValue(creds.Password)
+	return s.save(file)
+}
+
+// Delete removes the credentials for nexusURL and reports whether there were any
+func (s *CredentialStore) Delete(nexusURL string) (bool, error) {
+	file, err := s.load()
+	if err != nil {
+		return false, err
+	}
+	key := normalizeURL(nexusURL)
+	if _, err := file.GetSection(key); err != nil {
+		return false, nil
+	}
+	file.DeleteSection(key)
+	return true, s.save(file)
+}
+
+// UseStoredCredentials fills in Username and Password from the store for NexusURL unless
+// credentials are given through NEXUS_USER or NEXUS_PASS. Command line flags are applied
+// by the caller and take precedence over both.
+func (c *Config) UseStoredCredentials(store *CredentialStore) error {
+	if os.Getenv("NEXUS_USER") != "" || os.Getenv("NEXUS_PASS") != "" {
+		return nil
+	}
+	creds, ok, err := store.Get(c.NexusURL)
+	if err != nil || !ok {
+		return err
+	}
+	c.Username = creds.Username
+	c.Password = creds.Password
+	c.SetSource(KeyUsername, FileSource(store.Path))
+	c.SetSource(KeyPassword, FileSource(store.Path))
+	return nil
+}
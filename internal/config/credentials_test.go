@@ -0,0 +1,89 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCredentialStore(t *testing.T) {
+	store := &CredentialStore{Path: filepath.Join(t.TempDir(), "nexuscli", "credentials")}
+
+	if _, ok, err := store.Get("https://nexus.example.com"); err != nil || ok {
+		t.Fatalf("Expected empty store without a file, got ok=%v err=%v", ok, err)
+	}
+
+	password := `p#ss;word = "quoted"`
+	if err := store.Set("HTTPS://Nexus.Example.com/", Credentials{Username: "alice", Password: password}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Set("http://localhost:8081", Credentials{Username: "admin", Password: "admin123"}); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(store.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("Expected credentials file mode 0600, got %04o", perm)
+	}
+
+	creds, ok, err := store.Get("https://nexus.example.com")
+	if err != nil || !ok || creds.Username != "alice" || creds.Password != password {
+		t.Errorf("Expected alice's credentials for the normalized URL, got %+v ok=%v err=%v", creds, ok, err)
+	}
+	if creds, _, _ := store.Get("http://localhost:8081/"); creds.Username != "admin" {
+		t.Errorf("Expected credentials of a second server to be kept, got %+v", creds)
+	}
+
+	if removed, err := store.Delete("https://nexus.example.com"); err != nil || !removed {
+		t.Errorf("Expected credentials to be removed, got removed=%v err=%v", removed, err)
+	}
+	if _, ok, _ := store.Get("https://nexus.example.com"); ok {
+		t.Error("Expected credentials to be gone after Delete")
+	}
+	if removed, _ := store.Delete("https://nexus.example.com"); removed {
+		t.Error("Expected second Delete to report nothing removed")
+	}
+}
+
+func TestCredentialStoreRefusesReadableFile(t *testing.T) {
+	store := &CredentialStore{Path: filepath.Join(t.TempDir(), "credentials")}
+	if err := os.WriteFile(store.Path, []byte("[http://localhost:8081]\nusername = admin\npassword = secret\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err := store.Get("http://localhost:8081")
+	if err == nil || !strings.Contains(err.Error(), "accessible by other users") {
+		t.Errorf("Expected world-readable credentials file to be refused, got %v", err)
+	}
+}
+
+func TestUseStoredCredentials(t *testing.T) {
+	store := &CredentialStore{Path: filepath.Join(t.TempDir(), "credentials")}
+	if err := store.Set("http://localhost:8081", Credentials{Username: "stored", Password: "token"}); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("NEXUS_USER", "")
+	t.Setenv("NEXUS_PASS", "")
+
+	cfg := &Config{NexusURL: "http://localhost:8081", Username: "admin", Password: "admin"}
+	if err := cfg.UseStoredCredentials(store); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Username != "stored" || cfg.Password != "token" {
+		t.Errorf("Expected stored credentials to replace the defaults, got %s/%s", cfg.Username, cfg.Password)
+	}
+
+	// Credentials from the environment take precedence over the store
+	t.Setenv("NEXUS_USER", "env-user")
+	cfg = &Config{NexusURL: "http://localhost:8081", Username: "env-user", Password: "admin"}
+	if err := cfg.UseStoredCredentials(store); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Username != "env-user" {
+		t.Errorf("Expected environment credentials to be kept, got %s", cfg.Username)
+	}
+}
@@ -0,0 +1,137 @@
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// HostSettings are the connection settings of one Nexus server, from its [host <url>]
+// section in the config file
+type HostSettings struct {
+	CACert   string            // PEM file with CA certificates to trust in addition to the system ones
+	Insecure bool              // Skip verification of the server certificate
+	Token    string            // Bearer token sent instead of a username and password
+	TokenEnv string            // Environment variable to read the token from instead
+	Headers  map[string]string // Sent with every request to the server
+}
+
+// LoadTLS builds the TLS settings of the client from CACert and Insecure. It must be
+// called again after either of them changes.
+func (c *Config) LoadTLS() error {
+	c.tls = nil
+	if c.CACert == "" && !c.Insecure {
+		return nil
+	}
+	tlsConfig := &tls.Config{InsecureSkipVerify: c.Insecure}
+	if c.CACert != "" {
+		data, err := os.ReadFile(c.CACert)
+		if err != nil {
+			return fmt.Errorf("failed to read CA certificate for %s: %w", c.NexusURL, err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(data) {
+			return fmt.Errorf("no PEM certificates found in %s", c.CACert)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	c.tls = tlsConfig
+	return nil
+}
+
+// TLSConfig returns the TLS settings loaded by LoadTLS, or nil for the defaults
+func (c *Config) TLSConfig() *tls.Config {
+	return c.tls
+}
+
+// ForURL returns the connection settings for the Nexus server at nexusURL, e.g. of a
+// dependency in deps.ini, with its TLS settings loaded. The server of c keeps the
+// settings of c. Another server gets only the credentials stored for it in store and the
+// TLS settings, token and headers of its section in settings: neither the credentials
+// nor the Unix socket of c are sent to it, so a server without credentials of its own is
+// accessed anonymously unless UseCredentialsOf is called. Either store or settings may
+// be nil.
+func (c *Config) ForURL(nexusURL string, settings *Settings, store *CredentialStore) (*Config, error) {
+	if SameServer(nexusURL, c.NexusURL) {
+		host := *c
+		host.sources = make(map[string]Source, len(c.sources))
+		for key, source := range c.sources {
+			host.sources[key] = source
+		}
+		return &host, nil
+	}
+
+	host := &Config{
+		NexusURL:          nexusURL,
+		RepositoryAliases: c.RepositoryAliases,
+	}
+	if store != nil {
+		creds, ok, err := store.Get(nexusURL)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			host.Username = creds.Username
+			host.Password = creds.Password
+			host.SetSource(KeyUsername, FileSource(store.Path))
+			host.SetSource(KeyPassword, FileSource(store.Path))
+		}
+	}
+	if settings != nil {
+		settings.ApplyHost(host)
+	}
+	return host, host.LoadTLS()
+}
+
+// UseCredentialsOf makes c send the username and password of from, keeping their sources,
+// unless c has credentials or a token of its own. It is used for a server the user chose
+// to send the credentials of the run to, such as the [defaults] url of deps.ini.
+func (c *Config) UseCredentialsOf(from *Config) {
+	if c.Username != "" || c.Token != "" {
+		return
+	}
+	c.Username, c.Password = from.Username, from.Password
+	c.SetSource(KeyUsername, from.Source(KeyUsername))
+	c.SetSource(KeyPassword, from.Source(KeyPassword))
+}
+
+// SameServer reports whether two URLs point to the same Nexus server, ignoring the case
+// of the scheme and host and a trailing slash
+func SameServer(a, b string) bool {
+	return normalizeURL(a) == normalizeURL(b)
+}
+
+// ServerURL returns the URL of the Nexus server that rawURL points into: the longest of
+// nexusURL and the servers with a [host] section in settings that rawURL starts with, so
+// a server under a context path such as https://example.com/nexus is recognized. For any
+// other rawURL it is its scheme and host. settings may be nil.
+func ServerURL(rawURL, nexusURL string, settings *Settings) string {
+	target := normalizeURL(rawURL)
+	candidates := []string{nexusURL}
+	if settings != nil {
+		for hostURL := range settings.Hosts {
+			candidates = append(candidates, hostURL)
+		}
+	}
+	server := ""
+	for _, candidate := range candidates {
+		candidate = normalizeURL(candidate)
+		if candidate != "" && len(candidate) > len(server) && (target == candidate || strings.HasPrefix(target, candidate+"/")) {
+			server = candidate
+		}
+	}
+	if server != "" {
+		return server
+	}
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return ""
+	}
+	return u.Scheme + "://" + u.Host
+}
@@ -0,0 +1,110 @@
+package config
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestForURL(t *testing.T) {
+	store := &CredentialStore{Path: filepath.Join(t.TempDir(), "credentials")}
+	if err := store.Set("https://partner.example.com", Credentials{Username: "partner", Password: "token"}); err != nil {
+		t.Fatal(err)
+	}
+	caCert := filepath.Join(t.TempDir(), "ca.pem")
+	settings := &Settings{Hosts: map[string]HostSettings{"https://internal.example.com": {Insecure: true}}}
+
+	cfg := &Config{NexusURL: "https://nexus.example.com", Username: "ci", Password: "secret", UnixSocket: "/run/nexus.sock", CACert: caCert}
+	cfg.SetSource(KeyUsername, FlagSource("username"))
+
+	// The server of cfg keeps its settings
+	same, err := cfg.ForURL("HTTPS://nexus.example.com/", settings, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if same.Username != "ci" || same.UnixSocket != "/run/nexus.sock" || same.CACert != caCert || same.Source(KeyUsername) != FlagSource("username") {
+		t.Errorf("Expected the settings of cfg for its own server, got %+v", same)
+	}
+
+	partner, err := cfg.ForURL("https://partner.example.com", settings, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if partner.Username != "partner" || partner.Password != "token" || partner.Source(KeyPassword) != FileSource(store.Path) {
+		t.Errorf("Expected the stored credentials of the partner server, got %s/%s from %s", partner.Username, partner.Password, partner.Source(KeyPassword))
+	}
+	if partner.UnixSocket != "" || partner.CACert != "" || partner.TLSConfig() != nil {
+		t.Errorf("Expected no Unix socket or TLS settings for another server, got %+v", partner)
+	}
+
+	internal, err := cfg.ForURL("https://internal.example.com", settings, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if internal.Username != "" || internal.Password != "" || internal.Source(KeyPassword) != SourceDefault {
+		t.Errorf("Expected no credentials for a server without stored ones, got %s/%s from %s", internal.Username, internal.Password, internal.Source(KeyPassword))
+	}
+	if !internal.Insecure || internal.TLSConfig() == nil || !internal.TLSConfig().InsecureSkipVerify {
+		t.Errorf("Expected the TLS settings of the host section, got %+v", internal)
+	}
+
+	// UseCredentialsOf fills in the credentials of cfg, but never replaces stored ones
+	internal.UseCredentialsOf(cfg)
+	if internal.Username != "ci" || internal.Password != "secret" || internal.Source(KeyUsername) != FlagSource("username") {
+		t.Errorf("Expected the credentials of cfg, got %s/%s from %s", internal.Username, internal.Password, internal.Source(KeyUsername))
+	}
+	partner.UseCredentialsOf(cfg)
+	if partner.Username != "partner" || partner.Password != "token" {
+		t.Errorf("Expected the stored credentials to be kept, got %s/%s", partner.Username, partner.Password)
+	}
+}
+
+func TestLoadTLS(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	cfg := &Config{NexusURL: server.URL}
+	if err := cfg.LoadTLS(); err != nil || cfg.TLSConfig() != nil {
+		t.Fatalf("Expected the default TLS settings, got %v, %v", cfg.TLSConfig(), err)
+	}
+
+	cfg.CACert = filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(cfg.CACert, []byte("not a certificate"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.LoadTLS(); err == nil {
+		t.Error("Expected an error for a file without certificates")
+	}
+
+	certificate := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(cfg.CACert, certificate, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.LoadTLS(); err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: cfg.TLSConfig()}}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Expected the server certificate to be trusted, got %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestServerURL(t *testing.T) {
+	settings := &Settings{Hosts: map[string]HostSettings{"https://example.com/nexus": {}, "https://example.com/nexus/inner": {}}}
+	for _, tc := range []struct{ rawURL, expected string }{
+		{"https://example.com/nexus/raw/fw", "https://example.com/nexus"},
+		{"https://example.com/nexus/inner/raw", "https://example.com/nexus/inner"},
+		{"https://example.com/nexusraw/fw", "https://example.com"},
+		{"HTTP://LOCALHOST:8081/raw", "http://localhost:8081"},
+		{"https://other.example.com/ctx/raw", "https://other.example.com"},
+	} {
+		if got := ServerURL(tc.rawURL, "http://localhost:8081/", settings); got != tc.expected {
+			t.Errorf("ServerURL(%q) = %q, expected %q", tc.rawURL, got, tc.expected)
+		}
+	}
+}
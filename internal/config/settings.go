@@ -0,0 +1,199 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-ini/ini"
+	"github.com/tympanix/nexus-cli/internal/util"
+)
+
+// Settings are site defaults read from the config file. Command line flags take
+// precedence over them.
+type Settings struct {
+	UploadBatchSize       int                     // Maximum number of files per upload request (0 = no limit)
+	UploadMaxRequestBytes int64                   // Maximum file content per upload request (0 = no limit)
+	ChecksumAlgorithm     string                  // Default checksum algorithm for upload and download ("" = sha1)
+	RepositoryAliases     map[string]string       // Short names for repositories, from the [aliases] section
+	Hosts                 map[string]HostSettings // Connection settings of Nexus servers by URL, from [host <url>] sections
+
+	path string // The file the settings were read from, if it exists
+}
+
+// DefaultChecksumAlgorithm returns the checksum algorithm used when --checksum is not
+// given: NEXUS_CHECKSUM if it is set, then the config file, then sha1
+func (s *Settings) DefaultChecksumAlgorithm() string {
+	algorithm, _ := s.DefaultChecksumAlgorithmSource()
+	return algorithm
+}
+
+// DefaultChecksumAlgorithmSource returns DefaultChecksumAlgorithm and where it came from
+func (s *Settings) DefaultChecksumAlgorithmSource() (string, Source) {
+	if algorithm := os.Getenv("NEXUS_CHECKSUM"); algorithm != "" {
+		return algorithm, EnvSource("NEXUS_CHECKSUM")
+	}
+	if s.ChecksumAlgorithm != "" {
+		return s.ChecksumAlgorithm, s.Source()
+	}
+	return "sha1", SourceDefault
+}
+
+// Source returns the Source of the values read from the config file
+func (s *Settings) Source() Source {
+	if s.path == "" {
+		return SourceDefault
+	}
+	return FileSource(s.path)
+}
+
+// ApplyHost sets the TLS settings, token and headers of c from the section of its server,
+// if there is one
+func (s *Settings) ApplyHost(c *Config) {
+	host, ok := s.Hosts[normalizeURL(c.NexusURL)]
+	if !ok {
+		return
+	}
+	if host.Token != "" {
+		c.Token = host.Token
+		c.SetSource(KeyToken, s.Source())
+	}
+	if token := os.Getenv(host.TokenEnv); host.TokenEnv != "" && token != "" {
+		c.Token = token
+		c.SetSource(KeyToken, EnvSource(host.TokenEnv))
+	}
+	if len(host.Headers) > 0 {
+		c.Headers = host.Headers
+		c.SetSource(KeyHeaders, s.Source())
+	}
+	if host.CACert != "" {
+		c.CACert = host.CACert
+		c.SetSource(KeyCACert, s.Source())
+	}
+	if host.Insecure {
+		c.Insecure = true
+		c.SetSource(KeyInsecure, s.Source())
+	}
+}
+
+// DefaultSettingsFile returns the path of the config file, $XDG_CONFIG_HOME/nexuscli/config
+// or the same file in the platform's user configuration directory
+func DefaultSettingsFile() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("cannot locate config file: %w", err)
+	}
+	return filepath.Join(dir, "nexuscli", "config"), nil
+}
+
+// DefaultQueueDir returns the directory of uploads queued while Nexus was unreachable,
+// $XDG_CONFIG_HOME/nexuscli/queue or the same directory in the platform's user
+// configuration directory
+func DefaultQueueDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("cannot locate upload queue: %w", err)
+	}
+	return filepath.Join(dir, "nexuscli", "queue"), nil
+}
+
+// LoadSettings reads the INI config file at path. A missing file gives empty settings.
+//
+//	[upload]
+//	batch-size = 100
+//	max-request-bytes = 512M
+//
+//	[checksum]
+//	algorithm = sha256
+//
+//	[aliases]
+//	prod = company-prod-raw
+//
+//	[host https://nexus.partner.example.com]
+//	ca-cert = partner-ca.pem
+//	token-env = PARTNER_NEXUS_TOKEN
+//	header.X-Team = platform
+//
+// A relative ca-cert is relative to the directory of the config file. The token of a
+// host is given by token, or read from the environment variable named by token-env.
+func LoadSettings(path string) (*Settings, error) {
+	settings := &Settings{}
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return settings, nil
+	}
+	settings.path = path
+	file, err := ini.Load(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file %s: %w", path, err)
+	}
+
+	upload := file.Section("upload")
+	if key := upload.Key("batch-size"); key.String() != "" {
+		n, err := key.Int()
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid batch-size '%s' in %s: must be a non-negative number", key.String(), path)
+		}
+		settings.UploadBatchSize = n
+	}
+	if key := upload.Key("max-request-bytes"); key.String() != "" {
+		n, err := util.ParseByteSize(key.String())
+		if err != nil {
+			return nil, fmt.Errorf("invalid max-request-bytes in %s: %w", path, err)
+		}
+		settings.UploadMaxRequestBytes = n
+	}
+	settings.ChecksumAlgorithm = file.Section("checksum").Key("algorithm").String()
+
+	aliases := &Config{}
+	for _, key := range file.Section("aliases").Keys() {
+		if err := aliases.SetRepositoryAlias(key.Name(), key.String()); err != nil {
+			return nil, fmt.Errorf("%w in %s", err, path)
+		}
+	}
+	settings.RepositoryAliases = aliases.RepositoryAliases
+
+	for _, section := range file.Sections() {
+		name, ok := strings.CutPrefix(section.Name(), "host ")
+		if !ok {
+			continue
+		}
+		nexusURL := strings.TrimSpace(name)
+		var host HostSettings
+		if caCert := section.Key("ca-cert").String(); caCert != "" {
+			if !filepath.IsAbs(caCert) {
+				caCert = filepath.Join(filepath.Dir(path), caCert)
+			}
+			host.CACert = caCert
+		}
+		if key := section.Key("insecure"); key.String() != "" {
+			if host.Insecure, err = key.Bool(); err != nil {
+				return nil, fmt.Errorf("invalid insecure '%s' for %s in %s: must be true or false", key.String(), nexusURL, path)
+			}
+		}
+		host.Token = section.Key("token").String()
+		host.TokenEnv = section.Key("token-env").String()
+		if host.Token != "" && host.TokenEnv != "" {
+			return nil, fmt.Errorf("both token and token-env are set for %s in %s", nexusURL, path)
+		}
+		for _, key := range section.Keys() {
+			header, ok := strings.CutPrefix(key.Name(), "header.")
+			if !ok {
+				continue
+			}
+			if header == "" {
+				return nil, fmt.Errorf("invalid header key '%s' for %s in %s: must be header.<name>", key.Name(), nexusURL, path)
+			}
+			if host.Headers == nil {
+				host.Headers = make(map[string]string)
+			}
+			host.Headers[header] = key.String()
+		}
+		if settings.Hosts == nil {
+			settings.Hosts = make(map[string]HostSettings)
+		}
+		settings.Hosts[normalizeURL(nexusURL)] = host
+	}
+	return settings, nil
+}
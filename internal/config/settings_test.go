@@ -0,0 +1,90 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadSettings(t *testing.T) {
+	dir := t.TempDir()
+
+	settings, err := LoadSettings(filepath.Join(dir, "missing"))
+	if err != nil || !reflect.DeepEqual(*settings, Settings{}) {
+		t.Fatalf("Expected empty settings without a file, got %+v, %v", settings, err)
+	}
+
+	path := filepath.Join(dir, "config")
+	if err := os.WriteFile(path, []byte("[upload]\nbatch-size = 50\nmax-request-bytes = 1.5M\n\n[aliases]\nprod = company-prod-raw/\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	settings, err = LoadSettings(path)
+	if err != nil {
+		t.Fatalf("LoadSettings failed: %v", err)
+	}
+	if settings.UploadBatchSize != 50 || settings.UploadMaxRequestBytes != 1572864 {
+		t.Errorf("Unexpected settings: %+v", settings)
+	}
+	if expected := map[string]string{"prod": "company-prod-raw"}; !reflect.DeepEqual(settings.RepositoryAliases, expected) {
+		t.Errorf("Expected aliases %v, got %v", expected, settings.RepositoryAliases)
+	}
+
+	if err := os.WriteFile(path, []byte("[host https://Partner.example.com/]\nca-cert = certs/partner.pem\n\n[host https://internal.example.com]\ninsecure = true\ntoken-env = INTERNAL_TOKEN\nheader.X-Team = platform\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	settings, err = LoadSettings(path)
+	if err != nil {
+		t.Fatalf("LoadSettings failed: %v", err)
+	}
+	expectedHosts := map[string]HostSettings{
+		"https://partner.example.com":  {CACert: filepath.Join(dir, "certs", "partner.pem")},
+		"https://internal.example.com": {Insecure: true, TokenEnv: "INTERNAL_TOKEN", Headers: map[string]string{"X-Team": "platform"}},
+	}
+	if !reflect.DeepEqual(settings.Hosts, expectedHosts) {
+		t.Errorf("Expected hosts %v, got %v", expectedHosts, settings.Hosts)
+	}
+	cfg := &Config{NexusURL: "https://partner.example.com"}
+	settings.ApplyHost(cfg)
+	if cfg.CACert != filepath.Join(dir, "certs", "partner.pem") || cfg.Source(KeyCACert) != FileSource(path) {
+		t.Errorf("Expected the CA certificate of the host section, got %s from %s", cfg.CACert, cfg.Source(KeyCACert))
+	}
+	t.Setenv("INTERNAL_TOKEN", "s3cret")
+	cfg = &Config{NexusURL: "https://internal.example.com"}
+	settings.ApplyHost(cfg)
+	if cfg.Token != "s3cret" || cfg.Source(KeyToken) != EnvSource("INTERNAL_TOKEN") || cfg.Headers["X-Team"] != "platform" {
+		t.Errorf("Expected the token and headers of the host section, got %q from %s and %v", cfg.Token, cfg.Source(KeyToken), cfg.Headers)
+	}
+
+	for _, content := range []string{"[upload]\nbatch-size = -1\n", "[upload]\nbatch-size = many\n", "[upload]\nmax-request-bytes = 10X\n", "[aliases]\nprod = company/prod\n", "[aliases]\nprod =\n", "[host https://nexus.example.com]\ninsecure = maybe\n", "[host https://nexus.example.com]\ntoken = a\ntoken-env = B\n", "[host https://nexus.example.com]\nheader. = x\n"} {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadSettings(path); err == nil {
+			t.Errorf("Expected an error for %q", content)
+		}
+	}
+}
+
+func TestDefaultChecksumAlgorithm(t *testing.T) {
+	t.Setenv("NEXUS_CHECKSUM", "")
+	path := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(path, []byte("[checksum]\nalgorithm = sha256\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	settings, err := LoadSettings(path)
+	if err != nil {
+		t.Fatalf("LoadSettings failed: %v", err)
+	}
+	if got := settings.DefaultChecksumAlgorithm(); got != "sha256" {
+		t.Errorf("Expected the config file algorithm, got %q", got)
+	}
+	if got := (&Settings{}).DefaultChecksumAlgorithm(); got != "sha1" {
+		t.Errorf("Expected sha1 without a setting, got %q", got)
+	}
+
+	t.Setenv("NEXUS_CHECKSUM", "sha512")
+	if got := settings.DefaultChecksumAlgorithm(); got != "sha512" {
+		t.Errorf("Expected NEXUS_CHECKSUM to take precedence, got %q", got)
+	}
+}
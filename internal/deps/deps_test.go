@@ -1,9 +1,14 @@
 package deps
 
 import (
+	"errors"
 	"os"
+	"path/filepath"
+	"regexp"
 	"strings"
 	"testing"
+
+	"github.com/tympanix/nexus-cli/internal/checksum"
 )
 
 func TestParseDepsIni(t *testing.T) {
@@ -103,6 +108,7 @@ output_dir = ./local
 path = docs/example-${version}.txt
 version = 1.0.0
 url = http://nexus-custom.example.com:8082
+ca_cert = certs/custom-ca.pem
 
 [libfoo_tar]
 path = thirdparty/libfoo-${version}.tar.gz
@@ -131,6 +137,13 @@ version = 1.2.3
 	if exampleTxt.URL != "http://nexus-custom.example.com:8082" {
 		t.Errorf("Expected custom URL for example_txt, got '%s'", exampleTxt.URL)
 	}
+	if exampleTxt.CACert != "certs/custom-ca.pem" {
+		t.Errorf("Expected the CA certificate of example_txt, got '%s'", exampleTxt.CACert)
+	}
+	// A relative ca_cert is relative to deps.ini, not to the current directory
+	if expected := filepath.Join(filepath.Dir(tmpfile.Name()), "certs", "custom-ca.pem"); manifest.CACertPath(exampleTxt) != expected {
+		t.Errorf("Expected the CA certificate path %s, got %s", expected, manifest.CACertPath(exampleTxt))
+	}
 
 	libfooTar := manifest.Dependencies["libfoo_tar"]
 	if libfooTar == nil {
@@ -139,6 +152,9 @@ version = 1.2.3
 	if libfooTar.URL != "http://nexus-default.example.com:8081" {
 		t.Errorf("Expected libfoo_tar to inherit default URL, got '%s'", libfooTar.URL)
 	}
+	if libfooTar.CACert != "" {
+		t.Errorf("Expected no CA certificate for libfoo_tar, got '%s'", libfooTar.CACert)
+	}
 }
 
 func TestExpandedPath(t *testing.T) {
@@ -246,7 +262,8 @@ func TestLockFileRoundTrip(t *testing.T) {
 				"docs/example-1.0.0.txt": "sha256:f6a4e3c9b12",
 			},
 			"libfoo_tar": {
-				"thirdparty/libfoo-1.2.3.tar.gz": "sha512:a4c9d2e8abf",
+				"thirdparty/libfoo-1.2.3.tar.gz": "sha512:a4c9d2e8abf:1048576",
+				"thirdparty/libfoo-1.2.3.sig":    "sha512:5e0c2b7f1aa:256",
 			},
 		},
 	}
@@ -274,6 +291,162 @@ func TestLockFileRoundTrip(t *testing.T) {
 	if parsed.Dependencies["example_txt"]["docs/example-1.0.0.txt"] != "sha256:f6a4e3c9b12" {
 		t.Error("Checksum mismatch for example_txt")
 	}
+
+	// Sizes are kept, and a separate size entry is written as part of the checksum
+	files := parsed.Dependencies["libfoo_tar"]
+	if files["thirdparty/libfoo-1.2.3.tar.gz"] != "sha512:a4c9d2e8abf:1048576" {
+		t.Errorf("Expected the size to be kept, got %q", files["thirdparty/libfoo-1.2.3.tar.gz"])
+	}
+	if files["thirdparty/libfoo-1.2.3.sig"] != "sha512:5e0c2b7f1aa:256" {
+		t.Errorf("Expected the size entry in canonical form, got %q", files["thirdparty/libfoo-1.2.3.sig"])
+	}
+	if size, ok := LockedSize(files["thirdparty/libfoo-1.2.3.tar.gz"]); !ok || size != 1048576 {
+		t.Errorf("Expected a locked size of 1048576, got %d, %v", size, ok)
+	}
+	if _, ok := LockedSize(parsed.Dependencies["example_txt"]["docs/example-1.0.0.txt"]); ok {
+		t.Error("Expected no locked size for a two-field entry")
+	}
+}
+
+func TestLockFileMultipleChecksums(t *testing.T) {
+	lockFile := &LockFile{
+		Dependencies: map[string]map[string]string{
+			"libfoo_tar": {
+				"libfoo.tar.gz": "sha256:aaa,SHA512:bbb",
+				"legacy.txt":    "sha1:ccc",
+			},
+		},
+	}
+
+	filename := filepath.Join(t.TempDir(), "deps-lock.ini")
+	if err := WriteLockFile(filename, lockFile); err != nil {
+		t.Fatalf("WriteLockFile failed: %v", err)
+	}
+	parsed, err := ParseLockFile(filename)
+	if err != nil {
+		t.Fatalf("ParseLockFile failed: %v", err)
+	}
+
+	files := parsed.Dependencies["libfoo_tar"]
+	if files["libfoo.tar.gz"] != "sha256:aaa sha512:bbb" {
+		t.Errorf("Expected both checksums in canonical form, got %q", files["libfoo.tar.gz"])
+	}
+	if files["legacy.txt"] != "sha1:ccc" {
+		t.Errorf("Expected a single checksum to be kept, got %q", files["legacy.txt"])
+	}
+	if diff := DiffLockFiles(parsed, parsed); len(diff) != 0 {
+		t.Errorf("Expected no differences, got %v", diff)
+	}
+}
+
+func TestParseLockChecksums(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{value: "sha256:aaa", want: "sha256:aaa"},
+		{value: "sha256: aaa ", want: "sha256:aaa"},
+		{value: "sha256:aaa sha512:bbb", want: "sha256:aaa sha512:bbb"},
+		{value: "sha256:aaa, sha512:bbb", want: "sha256:aaa sha512:bbb"},
+		{value: "aaa", wantErr: true},
+		{value: "sha256:", wantErr: true},
+		{value: "", wantErr: true},
+		{value: "sha256:aaa sha256:bbb", wantErr: true},
+		{value: "sha256:aaa:1024", want: "sha256:aaa:1024"},
+		{value: "sha256: aaa:1024", want: "sha256:aaa:1024"},
+		{value: "sha256:aaa:1024 sha512:bbb", want: "sha256:aaa:1024 sha512:bbb:1024"},
+		{value: "sha256:aaa:0", want: "sha256:aaa:0"},
+		{value: "size:1024", wantErr: true},
+		{value: "sha256:aaa:big", wantErr: true},
+		{value: "sha256:aaa:-1", wantErr: true},
+		{value: "sha256::1024", wantErr: true},
+		{value: "sha256:aaa:1024 sha512:bbb:2048", wantErr: true},
+		{value: "sha256:aaa:", wantErr: true},
+		{value: "sha256:aaa size:1024", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		checksums, err := ParseLockChecksums(tt.value)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("Expected an error for %q", tt.value)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unexpected error for %q: %v", tt.value, err)
+			continue
+		}
+		if got := FormatLockChecksums(checksums); got != tt.want {
+			t.Errorf("Expected %q for %q, got %q", tt.want, tt.value, got)
+		}
+	}
+}
+
+func TestVerifyLockedFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "file.txt")
+	if err := os.WriteFile(filename, []byte("test file content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sha256sum, _ := checksum.ComputeChecksum(filename, "sha256")
+	sha512sum, _ := checksum.ComputeChecksum(filename, "sha512")
+
+	algorithms, err := VerifyLockedFile(filename, "sha256:"+sha256sum+" sha512:"+sha512sum)
+	if err != nil {
+		t.Fatalf("Expected both checksums to verify, got %v", err)
+	}
+	if strings.Join(algorithms, ",") != "sha256,sha512" {
+		t.Errorf("Expected sha256 and sha512 to be verified, got %v", algorithms)
+	}
+
+	// A mismatch in just one algorithm fails the file
+	_, err = VerifyLockedFile(filename, "sha256:"+sha256sum+" sha512:"+strings.Repeat("0", 128))
+	if err == nil || !strings.Contains(err.Error(), "sha512 checksum mismatch") {
+		t.Errorf("Expected a sha512 mismatch, got %v", err)
+	}
+
+	// A recorded size is verified first
+	if algorithms, err := VerifyLockedFile(filename, "sha256:"+sha256sum+":17"); err != nil || strings.Join(algorithms, ",") != "size,sha256" {
+		t.Errorf("Expected size and sha256 to be verified, got %v, %v", algorithms, err)
+	}
+	// A truncated file fails on its size even if the checksum would match
+	if _, err := VerifyLockedFile(filename, "sha256:"+sha256sum+":18"); err == nil || !strings.Contains(err.Error(), "size mismatch") {
+		t.Errorf("Expected a size mismatch, got %v", err)
+	}
+	if size, ok := LockedSize("sha256:" + sha256sum + ":17"); !ok || size != 17 {
+		t.Errorf("Expected a locked size of 17, got %d, %v", size, ok)
+	}
+	if _, ok := LockedSize("sha256:" + sha256sum); ok {
+		t.Error("Expected no locked size without a size entry")
+	}
+}
+
+// TestVerifyLockedDigest tests verifying against checksums computed during a download. The
+// file does not exist, so any attempt to read it would fail the verification.
+func TestVerifyLockedDigest(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "missing.txt")
+	sha256sum := strings.Repeat("a", 64)
+	sha512sum := strings.Repeat("b", 128)
+	checksums := map[string]string{"sha256": sha256sum, "sha512": sha512sum}
+
+	algorithms, err := VerifyLockedDigest(filename, "sha256:"+sha256sum+":17 sha512:"+sha512sum+":17", 17, checksums)
+	if err != nil {
+		t.Fatalf("Expected the digest to verify without reading the file, got %v", err)
+	}
+	if strings.Join(algorithms, ",") != "size,sha256,sha512" {
+		t.Errorf("Expected size, sha256 and sha512 to be verified, got %v", algorithms)
+	}
+
+	if _, err := VerifyLockedDigest(filename, "sha256:"+strings.Repeat("0", 64), 17, checksums); err == nil || !strings.Contains(err.Error(), "sha256 checksum mismatch") {
+		t.Errorf("Expected a sha256 mismatch, got %v", err)
+	}
+	if _, err := VerifyLockedDigest(filename, "sha256:"+sha256sum+":18", 17, checksums); err == nil || !strings.Contains(err.Error(), "size mismatch") {
+		t.Errorf("Expected a size mismatch, got %v", err)
+	}
+	if _, err := VerifyLockedDigest(filename, "md5:"+strings.Repeat("0", 32), 17, checksums); err == nil || !strings.Contains(err.Error(), "no md5 checksum") {
+		t.Errorf("Expected an error for an algorithm that was not computed, got %v", err)
+	}
 }
 
 func TestLockFileDeterministicOutput(t *testing.T) {
@@ -322,6 +495,95 @@ func TestLockFileDeterministicOutput(t *testing.T) {
 	}
 }
 
+func TestDiffLockFiles(t *testing.T) {
+	current := &LockFile{
+		Dependencies: map[string]map[string]string{
+			"alpha": {"a.txt": "sha256:aaa", "b.txt": "sha256:bbb"},
+			"gone":  {"old.txt": "sha256:old"},
+		},
+	}
+
+	if diff := DiffLockFiles(current, current); len(diff) != 0 {
+		t.Errorf("Expected no differences for identical lock files, got %v", diff)
+	}
+
+	resolved := &LockFile{
+		Dependencies: map[string]map[string]string{
+			"alpha": {"a.txt": "sha256:aaa", "b.txt": "sha256:changed", "c.txt": "sha256:ccc"},
+			"beta":  {"new.txt": "sha256:new"},
+		},
+	}
+	expected := []string{
+		"- [alpha] b.txt = sha256:bbb",
+		"+ [alpha] b.txt = sha256:changed",
+		"+ [alpha] c.txt = sha256:ccc",
+		"+ [beta] new.txt = sha256:new",
+		"- [gone] old.txt = sha256:old",
+	}
+	diff := DiffLockFiles(current, resolved)
+	if strings.Join(diff, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Unexpected diff:\n%s\nexpected:\n%s", strings.Join(diff, "\n"), strings.Join(expected, "\n"))
+	}
+
+	// A lock file from before sizes were recorded is still up to date, but a changed size is not
+	sized := &LockFile{
+		Dependencies: map[string]map[string]string{
+			"alpha": {"a.txt": "sha256:aaa:10", "b.txt": "sha256:bbb:20"},
+			"gone":  {"old.txt": "sha256:old:30"},
+		},
+	}
+	if diff := DiffLockFiles(current, sized); len(diff) != 0 {
+		t.Errorf("Expected new sizes not to be differences, got %v", diff)
+	}
+	resized := &LockFile{
+		Dependencies: map[string]map[string]string{
+			"alpha": {"a.txt": "sha256:aaa:10", "b.txt": "sha256:bbb:21"},
+			"gone":  {"old.txt": "sha256:old:30"},
+		},
+	}
+	if diff := DiffLockFiles(sized, resized); len(diff) != 2 {
+		t.Errorf("Expected a changed size to be a difference, got %v", diff)
+	}
+}
+
+func TestVerifyLockFile(t *testing.T) {
+	const digest = "b873ee26f3d17e038e023b4a4a9c9e3379ecc018171760b986abdbc011e17746"
+	tests := []struct {
+		name    string
+		locked  string
+		actual  string
+		wantErr string
+	}{
+		{name: "exact match", locked: "sha256:" + digest, actual: digest},
+		{name: "uppercase lock value", locked: "SHA256:" + strings.ToUpper(digest), actual: digest},
+		{name: "whitespace around lock value", locked: "sha256: " + digest + " ", actual: digest},
+		{name: "mismatch", locked: "sha256:" + strings.Repeat("0", 64), actual: digest, wantErr: "checksum mismatch"},
+		{name: "wrong length lock value", locked: "sha256:abc123", actual: digest, wantErr: "malformed checksum"},
+		{name: "algorithm mismatch", locked: "sha1:" + digest, actual: digest, wantErr: "algorithm mismatch"},
+		{name: "one of several algorithms", locked: "sha1:" + strings.Repeat("0", 40) + " sha256:" + digest, actual: digest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lockFile := &LockFile{
+				Dependencies: map[string]map[string]string{
+					"dep": {"file.bin": tt.locked},
+				},
+			}
+			err := VerifyLockFile(lockFile, "dep", "file.bin", "sha256", tt.actual)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
 func TestValidateOutputDir(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -640,3 +902,540 @@ repositry = libs
 		t.Errorf("Expected error about unknown key 'repositry', got: %v", err)
 	}
 }
+
+func TestParseDepsIniReportsAllProblems(t *testing.T) {
+	content := `stray = value
+
+[defaults]
+repository = libs
+checksum = sha265
+outptu_dir = ./local
+
+[example_txt]
+path = docs/example.txt
+repositry = libs
+concurrency = 0
+
+[no_path]
+version = 1.0.0
+recursive = maybe
+`
+	filename := filepath.Join(t.TempDir(), "deps.ini")
+	if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := ParseDepsIni(filename)
+	var manifestErr *ManifestError
+	if !errors.As(err, &manifestErr) {
+		t.Fatalf("Expected a ManifestError, got %v", err)
+	}
+	expected := []Problem{
+		{Line: 1, Section: "DEFAULT", Key: "stray", Message: "key 'stray' is not in a section"},
+		{Line: 6, Section: "defaults", Key: "outptu_dir", Message: "unknown key 'outptu_dir' in [defaults] section (did you mean 'output_dir'?)"},
+		{Line: 5, Section: "defaults", Key: "checksum", Message: "invalid [defaults] section: unsupported checksum algorithm 'sha265'"},
+		{Line: 10, Section: "example_txt", Key: "repositry", Message: "unknown key 'repositry' in [example_txt] section (did you mean 'repository'?)"},
+		{Line: 11, Section: "example_txt", Key: "concurrency", Message: "invalid [example_txt] section: concurrency must be a positive integer, got '0'"},
+		{Line: 15, Section: "no_path", Key: "recursive", Message: "recursive must be true or false, got 'maybe'"},
+		{Line: 13, Section: "no_path", Key: "", Message: "dependency no_path is missing required 'path' field"},
+	}
+	if len(manifestErr.Problems) != len(expected) {
+		t.Fatalf("Expected %d problems, got %d:\n%v", len(expected), len(manifestErr.Problems), err)
+	}
+	for i, want := range expected {
+		if manifestErr.Problems[i] != want {
+			t.Errorf("Problem %d: expected %+v, got %+v", i, want, manifestErr.Problems[i])
+		}
+	}
+	if !strings.HasPrefix(err.Error(), "7 problems in "+filename+":\n  line 1: key 'stray' is not in a section") {
+		t.Errorf("Expected every problem to be listed with its line, got:\n%v", err)
+	}
+}
+
+func TestParseDepsIniFileDependency(t *testing.T) {
+	content := `[defaults]
+repository = libs
+
+[tool]
+path = bin/tool
+file = true
+
+[both]
+path = bin/both
+file = true
+recursive = true
+`
+	filename := filepath.Join(t.TempDir(), "deps.ini")
+	if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := ParseDepsIni(filename)
+	if err == nil || !strings.Contains(err.Error(), "line 10: dependency both cannot set both file and recursive") {
+		t.Fatalf("Expected file and recursive to conflict, got %v", err)
+	}
+
+	content = content[:strings.Index(content, "[both]")]
+	if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := ParseDepsIni(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !manifest.Dependencies["tool"].File {
+		t.Fatal("Expected tool to be a file dependency")
+	}
+
+	// The mode survives writing deps.ini back
+	if err := WriteDepsIni(filename, manifest); err != nil {
+		t.Fatal(err)
+	}
+	if manifest, err = ParseDepsIni(filename); err != nil || !manifest.Dependencies["tool"].File {
+		t.Errorf("Expected file = true to be written, got %v", err)
+	}
+}
+
+func TestCheckLockFile(t *testing.T) {
+	manifest := &DepsManifest{Dependencies: map[string]*Dependency{
+		"app":     {Name: "app", Checksum: "sha256,sha512"},
+		"tool":    {Name: "tool", Checksum: "sha256"},
+		"missing": {Name: "missing", Checksum: "sha256"},
+	}}
+	content := `[app]
+app.jar = sha256:` + strings.Repeat("a", 64) + `
+
+[tool]
+tool.bin = sha256:` + strings.Repeat("b", 64) + `
+
+[removed]
+old.bin = sha256:` + strings.Repeat("c", 64) + `
+`
+	filename := filepath.Join(t.TempDir(), "deps-lock.ini")
+	if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	lockFile, err := ParseLockFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = CheckLockFile(filename, manifest, lockFile)
+	var manifestErr *ManifestError
+	if !errors.As(err, &manifestErr) {
+		t.Fatalf("Expected a ManifestError, got %v", err)
+	}
+	expected := []string{
+		"line 2: app.jar in [app] has no sha512 checksum; run 'deps lock'",
+		"dependency missing is not locked; run 'deps lock'",
+		"line 7: [removed] is locked but not a dependency in deps.ini; run 'deps lock'",
+	}
+	if len(manifestErr.Problems) != len(expected) {
+		t.Fatalf("Expected %d problems, got:\n%v", len(expected), err)
+	}
+	for i, want := range expected {
+		if got := manifestErr.Problems[i].String(); got != want {
+			t.Errorf("Problem %d: expected %q, got %q", i, want, got)
+		}
+	}
+
+	delete(manifest.Dependencies, "missing")
+	manifest.Dependencies["app"].Checksum = "sha256"
+	delete(lockFile.Dependencies, "removed")
+	if err := CheckLockFile(filename, manifest, lockFile); err != nil {
+		t.Errorf("Expected a consistent lock file, got %v", err)
+	}
+}
+
+func TestParseDepsIniWithConcurrencyAndMaxRate(t *testing.T) {
+	content := `[defaults]
+repository = libs
+output_dir = ./local
+concurrency = 4
+max_rate = 10M
+max_size = 2G
+
+[huge_sdk]
+path = sdk/
+recursive = true
+concurrency = 16
+max_rate = 0
+
+[throttled_tool]
+path = tools/tool.bin
+max_rate = 512K
+
+[plain_lib]
+path = libs/lib.tar.gz
+`
+	tmpfile, err := os.CreateTemp("", "deps-*.ini")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(tmpfile.Name())
+
+	if _, err := tmpfile.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	tmpfile.Close()
+
+	manifest, err := ParseDepsIni(tmpfile.Name())
+	if err != nil {
+		t.Fatalf("ParseDepsIni failed: %v", err)
+	}
+
+	tests := []struct {
+		name            string
+		wantConcurrency int
+		wantMaxRate     int64
+	}{
+		{name: "huge_sdk", wantConcurrency: 16, wantMaxRate: 0},
+		{name: "throttled_tool", wantConcurrency: 4, wantMaxRate: 512 * 1024},
+		{name: "plain_lib", wantConcurrency: 4, wantMaxRate: 10 * 1024 * 1024},
+	}
+
+	for _, tt := range tests {
+		dep := manifest.Dependencies[tt.name]
+		if dep == nil {
+			t.Fatalf("%s dependency not found", tt.name)
+		}
+		if dep.Concurrency != tt.wantConcurrency {
+			t.Errorf("%s: expected concurrency %d, got %d", tt.name, tt.wantConcurrency, dep.Concurrency)
+		}
+		if dep.MaxRate != tt.wantMaxRate {
+			t.Errorf("%s: expected max_rate %d, got %d", tt.name, tt.wantMaxRate, dep.MaxRate)
+		}
+	}
+	if manifest.Defaults.MaxSize != 2*1024*1024*1024 {
+		t.Errorf("Expected max_size of 2G, got %d", manifest.Defaults.MaxSize)
+	}
+}
+
+func TestParseDepsIniWithInvalidConcurrencyAndMaxRate(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     string
+		errContains string
+	}{
+		{
+			name: "zero concurrency",
+			content: `[defaults]
+repository = libs
+
+[example]
+path = docs/example.txt
+concurrency = 0
+`,
+			errContains: "invalid [example] section: concurrency must be a positive integer",
+		},
+		{
+			name: "non-numeric concurrency in defaults",
+			content: `[defaults]
+repository = libs
+concurrency = many
+
+[example]
+path = docs/example.txt
+`,
+			errContains: "invalid [defaults] section: concurrency must be a positive integer",
+		},
+		{
+			name: "invalid max_rate",
+			content: `[defaults]
+repository = libs
+
+[example]
+path = docs/example.txt
+max_rate = fast
+`,
+			errContains: "invalid [example] section: invalid max_rate",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpfile, err := os.CreateTemp("", "deps-*.ini")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.Remove(tmpfile.Name())
+
+			if _, err := tmpfile.Write([]byte(tt.content)); err != nil {
+				t.Fatal(err)
+			}
+			tmpfile.Close()
+
+			_, err = ParseDepsIni(tmpfile.Name())
+			if err == nil {
+				t.Fatal("ParseDepsIni should have failed")
+			}
+			if !strings.Contains(err.Error(), tt.errContains) {
+				t.Errorf("Expected error containing %q, got: %v", tt.errContains, err)
+			}
+		})
+	}
+}
+
+func TestReadJSONManifestReportsAllProblems(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		content  string
+		expected []string
+	}{
+		{
+			name: "invalid dependencies",
+			content: `{
+  "schema_version": 1,
+  "dependencies": [
+    {"name": "libfoo", "repository": "thirdparty", "path": "libfoo/1.0/", "output": "/"},
+    {"name": "libfoo", "path": "libfoo/2.0/", "checksum": "crc32"},
+    {"repository": "thirdparty"},
+    {"name": "defaults", "path": "x", "file": true, "recursive": true}
+  ]
+}`,
+			expected: []string{
+				`dependencies[0] (libfoo): invalid "output": output cannot be '/' (root directory) for safety reasons`,
+				`dependencies[1] (libfoo): name "libfoo" is already used by dependencies[0]`,
+				`dependencies[1] (libfoo): invalid "checksum": unsupported checksum algorithm 'crc32'`,
+				`dependencies[2]: missing required "name"`,
+				`dependencies[2]: missing required "path"`,
+				`dependencies[3] (defaults): name "defaults" cannot be used as a deps.ini section`,
+				`dependencies[3] (defaults): cannot set both "file" and "recursive"`,
+			},
+		},
+		{
+			name:     "newer schema",
+			content:  `{"schema_version": 2, "dependencies": [{"name": "a", "path": "a/"}]}`,
+			expected: []string{"schema_version 2 is newer than this nexuscli-go supports (1)"},
+		},
+		{
+			name:     "missing schema version",
+			content:  `{"dependencies": [{"name": "a", "path": "a/"}]}`,
+			expected: []string{`missing "schema_version": set it to 1`},
+		},
+		{
+			name:     "unknown field",
+			content:  `{"schema_version": 1, "dependencies": [{"name": "a", "path": "a/", "outptu": "./deps"}]}`,
+			expected: []string{`unknown field "outptu" (did you mean "output"?)`},
+		},
+		{
+			name:     "wrong type",
+			content:  "{\n  \"schema_version\": 1,\n  \"dependencies\": [{\"name\": \"a\", \"path\": \"a/\", \"recursive\": \"yes\"}]\n}",
+			expected: []string{"line 3: dependencies[0].recursive must be a bool, got a JSON string"},
+		},
+		{
+			name:     "syntax error",
+			content:  "{\n  \"schema_version\": 1,\n  \"dependencies\": [}\n}",
+			expected: []string{"line 3: invalid JSON: invalid character '}' looking for beginning of value"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "artifacts.json")
+			if err := os.WriteFile(path, []byte(tc.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			_, err := ReadJSONManifest(path)
+			var manifestErr *ManifestError
+			if !errors.As(err, &manifestErr) {
+				t.Fatalf("Expected a ManifestError, got %v", err)
+			}
+			var got []string
+			for _, p := range manifestErr.Problems {
+				got = append(got, p.String())
+			}
+			if strings.Join(got, "\n") != strings.Join(tc.expected, "\n") {
+				t.Errorf("Expected problems:\n%s\ngot:\n%s", strings.Join(tc.expected, "\n"), strings.Join(got, "\n"))
+			}
+		})
+	}
+}
+
+func TestImportExportJSON(t *testing.T) {
+	dir := t.TempDir()
+	depsFile := filepath.Join(dir, "deps.ini")
+	if err := os.WriteFile(depsFile, []byte("# Shared settings\n[defaults]\nrepository = libs\noutput_dir = ./deps\n\n[existing]\npath = existing/\nconcurrency = 4\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	jsonFile := filepath.Join(dir, "artifacts.json")
+	if err := os.WriteFile(jsonFile, []byte(`{
+  "schema_version": 1,
+  "dependencies": [
+    {"name": "libfoo", "repository": "thirdparty", "path": "libfoo/${version}/", "version": "1.2.3", "output": "./vendor", "checksum": "sha512", "recursive": true},
+    {"name": "existing", "path": "replaced/", "file": true}
+  ]
+}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	imported, err := ReadJSONManifest(jsonFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ImportJSON(depsFile, imported, false); err == nil || !strings.Contains(err.Error(), "already has section(s) [existing]; use --force") {
+		t.Fatalf("Expected an existing section not to be replaced without force, got %v", err)
+	}
+	manifest, err := ParseDepsIni(depsFile)
+	if err != nil || len(manifest.Dependencies) != 1 {
+		t.Fatalf("Expected deps.ini to be left as it was, got %v, %v", manifest, err)
+	}
+
+	names, err := ImportJSON(depsFile, imported, true)
+	if err != nil || strings.Join(names, ",") != "libfoo,existing" {
+		t.Fatalf("Expected both dependencies to be imported, got %v, %v", names, err)
+	}
+	content, _ := os.ReadFile(depsFile)
+	if !strings.Contains(string(content), "# Shared settings") {
+		t.Errorf("Expected the comments of deps.ini to be kept, got:\n%s", content)
+	}
+	manifest, err = ParseDepsIni(depsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	libfoo := manifest.Dependencies["libfoo"]
+	if libfoo == nil || libfoo.Repository != "thirdparty" || libfoo.ExpandedPath() != "libfoo/1.2.3/" || libfoo.OutputDir != "./vendor" || libfoo.Checksum != "sha512" || !libfoo.Recursive {
+		t.Errorf("Unexpected libfoo: %+v", libfoo)
+	}
+	existing := manifest.Dependencies["existing"]
+	if existing == nil || existing.Path != "replaced/" || !existing.File || existing.Concurrency != 0 || existing.Repository != "libs" {
+		t.Errorf("Expected the existing section to be replaced, got %+v", existing)
+	}
+
+	exported, skipped := ExportJSON(manifest)
+	if len(skipped) != 0 {
+		t.Errorf("Expected every setting to be exported, got %v", skipped)
+	}
+	var b strings.Builder
+	if err := WriteJSONManifest(&b, exported); err != nil {
+		t.Fatal(err)
+	}
+	roundTrip := filepath.Join(dir, "exported.json")
+	if err := os.WriteFile(roundTrip, []byte(b.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+	reread, err := ReadJSONManifest(roundTrip)
+	if err != nil {
+		t.Fatalf("Expected the export to be valid input for import, got %v", err)
+	}
+	expected := []JSONDependency{
+		{Name: "existing", Repository: "libs", Path: "replaced/", Output: "./deps", Checksum: "sha256", File: true},
+		{Name: "libfoo", Repository: "thirdparty", Path: "libfoo/${version}/", Version: "1.2.3", Output: "./vendor", Checksum: "sha512", Recursive: true},
+	}
+	if reread.SchemaVersion != JSONSchemaVersion || len(reread.Dependencies) != 2 || reread.Dependencies[0] != expected[0] || reread.Dependencies[1] != expected[1] {
+		t.Errorf("Expected %+v, got %+v", expected, reread)
+	}
+
+	manifest.Dependencies["libfoo"].MaxRate = 1024
+	if _, skipped := ExportJSON(manifest); strings.Join(skipped, ",") != "libfoo: max_rate" {
+		t.Errorf("Expected max_rate to be reported as not exported, got %v", skipped)
+	}
+}
+
+func TestParseDepsIniLatestVersion(t *testing.T) {
+	content := `[defaults]
+repository = libs
+
+[libfoo]
+path = libfoo/${version}/
+version = {latest:semver}
+recursive = true
+
+[typo]
+path = libbar/${version}/libbar.jar
+version = {latest:newest}
+
+[nopath]
+path = libbaz/libbaz.jar
+version = {latest}
+
+[inpath]
+path = libqux/{latest}/libqux.jar
+`
+	filename := filepath.Join(t.TempDir(), "deps.ini")
+	if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := ParseDepsIni(filename)
+	var manifestErr *ManifestError
+	if !errors.As(err, &manifestErr) {
+		t.Fatalf("Expected a ManifestError, got %v", err)
+	}
+	expected := []string{
+		"invalid selection 'newest'",
+		"version {latest} needs ${version} in the path",
+		"path of inpath cannot contain {latest}",
+	}
+	if len(manifestErr.Problems) != len(expected) {
+		t.Fatalf("Expected %d problems, got:\n%v", len(expected), err)
+	}
+	for _, want := range expected {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected a problem %q, got:\n%v", want, err)
+		}
+	}
+
+	content = content[:strings.Index(content, "[typo]")]
+	if err := os.WriteFile(filename, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := ParseDepsIni(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !manifest.Dependencies["libfoo"].LatestVersion() {
+		t.Error("Expected libfoo to select the latest version")
+	}
+}
+
+func TestLockFileVersions(t *testing.T) {
+	lockFile := &LockFile{
+		Dependencies: map[string]map[string]string{
+			"libfoo": {"libfoo/1.10.0/libfoo.jar": "sha256:" + strings.Repeat("a", 64)},
+		},
+		Versions: map[string]string{"libfoo": "1.10.0"},
+	}
+	filename := filepath.Join(t.TempDir(), "deps-lock.ini")
+	if err := WriteLockFile(filename, lockFile); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !regexp.MustCompile(`^\[libfoo\]\n@version\s*= 1\.10\.0\n`).Match(content) {
+		t.Errorf("Expected the version before the files, got:\n%s", content)
+	}
+	parsed, err := ParseLockFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Versions["libfoo"] != "1.10.0" || len(parsed.Dependencies["libfoo"]) != 1 {
+		t.Fatalf("Expected the version to be read apart from the files, got %+v", parsed)
+	}
+
+	manifest := &DepsManifest{Dependencies: map[string]*Dependency{
+		"libfoo": {Name: "libfoo", Path: "libfoo/${version}/", Version: "{latest:semver}", Checksum: "sha256", OutputDir: "deps"},
+	}}
+	if err := CheckLockFile(filename, manifest, parsed); err != nil {
+		t.Errorf("Expected a consistent lock file, got %v", err)
+	}
+	if err := PinVersions(manifest, parsed); err != nil {
+		t.Fatal(err)
+	}
+	if path := manifest.Dependencies["libfoo"].ExpandedPath(); path != "libfoo/1.10.0/" {
+		t.Errorf("Expected the locked version to be pinned, got %s", path)
+	}
+
+	manifest.Dependencies["libfoo"].Version = "{latest}"
+	resolved := &LockFile{Dependencies: parsed.Dependencies, Versions: map[string]string{"libfoo": "1.11.0"}}
+	diff := DiffLockFiles(parsed, resolved)
+	if len(diff) != 2 || diff[0] != "- [libfoo] @version = 1.10.0" || diff[1] != "+ [libfoo] @version = 1.11.0" {
+		t.Errorf("Expected the changed version in the diff, got %v", diff)
+	}
+
+	delete(parsed.Versions, "libfoo")
+	if err := CheckLockFile(filename, manifest, parsed); err == nil || !strings.Contains(err.Error(), "no version is locked") {
+		t.Errorf("Expected a missing version to be reported, got %v", err)
+	}
+	if err := PinVersions(manifest, parsed); err == nil {
+		t.Error("Expected PinVersions to fail without a locked version")
+	}
+}
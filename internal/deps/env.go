@@ -1,29 +1,48 @@
 package deps
 
 import (
+	"bytes"
 	"fmt"
 	"os"
+	"sort"
 )
 
-func GenerateEnvFile(filename string, manifest *DepsManifest) error {
-	file, err := os.Create(filename)
-	if err != nil {
-		return fmt.Errorf("failed to create %s: %w", filename, err)
+// GenerateEnvFile writes the name, version and local path of every dependency to
+// filename as shell variables, sorted by dependency name. The file is only written if its
+// content changes, so its modification time does not retrigger build rules; the returned
+// bool reports whether it was written.
+func GenerateEnvFile(filename string, manifest *DepsManifest) (bool, error) {
+	content := formatEnvFile(manifest)
+	if existing, err := os.ReadFile(filename); err == nil && bytes.Equal(existing, content) {
+		return false, nil
 	}
-	defer file.Close()
+	if err := os.WriteFile(filename, content, 0644); err != nil {
+		return false, fmt.Errorf("failed to create %s: %w", filename, err)
+	}
+	return true, nil
+}
 
-	for name, dep := range manifest.Dependencies {
+// formatEnvFile returns the content GenerateEnvFile writes for manifest
+func formatEnvFile(manifest *DepsManifest) []byte {
+	names := make([]string, 0, len(manifest.Dependencies))
+	for name := range manifest.Dependencies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	for _, name := range names {
+		dep := manifest.Dependencies[name]
 		export := &EnvExport{
 			Name:    name,
 			Version: dep.Version,
 			Path:    dep.LocalPath(),
 		}
 
-		fmt.Fprintf(file, "%s=\"%s\"\n", export.EnvName(), export.Name)
-		fmt.Fprintf(file, "%s=\"%s\"\n", export.EnvVersion(), export.Version)
-		fmt.Fprintf(file, "%s=\"%s\"\n", export.EnvPath(), export.Path)
-		fmt.Fprintf(file, "\n")
+		fmt.Fprintf(&buf, "%s=\"%s\"\n", export.EnvName(), export.Name)
+		fmt.Fprintf(&buf, "%s=\"%s\"\n", export.EnvVersion(), export.Version)
+		fmt.Fprintf(&buf, "%s=\"%s\"\n", export.EnvPath(), export.Path)
+		fmt.Fprintf(&buf, "\n")
 	}
-
-	return nil
+	return buf.Bytes()
 }
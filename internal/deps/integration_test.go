@@ -2,7 +2,10 @@ package deps
 
 import (
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/tympanix/nexus-cli/internal/nexusapi"
 )
@@ -33,6 +36,12 @@ func TestResolverWithMockServer(t *testing.T) {
 			SHA256: "ef125678",
 		},
 	}, nil)
+	// Written by upload --preserve-mtime; describes the other files and is not locked
+	mockServer.AddAsset("libs", "/docs/2025-10-15/.nexus-meta.json", nexusapi.Asset{
+		Checksum: nexusapi.Checksum{
+			SHA256: "0e7a1f22",
+		},
+	}, nil)
 
 	client := nexusapi.NewClient(mockServer.URL, "admin", "admin")
 	resolver := NewResolver(client)
@@ -61,6 +70,35 @@ func TestResolverWithMockServer(t *testing.T) {
 		}
 	})
 
+	t.Run("resolve multiple checksums", func(t *testing.T) {
+		mockServer.AddAsset("libs", "/tools/tool-2.0.bin", nexusapi.Asset{
+			Checksum: nexusapi.Checksum{
+				SHA256: "1234abcd",
+				SHA512: "5678ef90",
+			},
+		}, nil)
+		dep := &Dependency{
+			Name:       "tool",
+			Repository: "libs",
+			Path:       "/tools/tool-${version}.bin",
+			Version:    "2.0",
+			Checksum:   "sha256, sha512",
+		}
+
+		files, err := resolver.ResolveDependency(dep)
+		if err != nil {
+			t.Fatalf("ResolveDependency failed: %v", err)
+		}
+		if files["tools/tool-2.0.bin"] != "sha256:1234abcd sha512:5678ef90" {
+			t.Errorf("Expected both checksums, got '%s'", files["tools/tool-2.0.bin"])
+		}
+
+		dep.Checksum = "sha256,sha1"
+		if _, err := resolver.ResolveDependency(dep); err == nil {
+			t.Error("Expected an error when one of the checksums is not available")
+		}
+	})
+
 	t.Run("resolve recursive folder", func(t *testing.T) {
 		dep := &Dependency{
 			Name:       "docs_folder",
@@ -87,6 +125,43 @@ func TestResolverWithMockServer(t *testing.T) {
 			t.Error("guide.pdf checksum mismatch")
 		}
 	})
+
+	t.Run("resolve exact file", func(t *testing.T) {
+		dep := &Dependency{
+			Name:       "readme",
+			Repository: "libs",
+			Path:       "/docs/${version}/readme.md",
+			Version:    "2025-10-15",
+			Checksum:   "sha256",
+			File:       true,
+		}
+
+		files, err := resolver.ResolveDependency(dep)
+		if err != nil {
+			t.Fatalf("ResolveDependency failed: %v", err)
+		}
+		if len(files) != 1 || files["docs/2025-10-15/readme.md"] != "sha256:abcd1234" {
+			t.Errorf("Expected the single file to be locked, got %v", files)
+		}
+	})
+
+	t.Run("single asset errors", func(t *testing.T) {
+		for _, file := range []bool{true, false} {
+			dep := &Dependency{Name: "single", Repository: "libs", Checksum: "sha256", File: file}
+
+			dep.Path = "/docs/missing.txt"
+			_, err := resolver.ResolveDependency(dep)
+			if err == nil || !strings.Contains(err.Error(), "asset docs/missing.txt not found in repository libs") {
+				t.Errorf("Expected a missing asset error with file = %v, got %v", file, err)
+			}
+
+			dep.Path = "/docs/2025-10-15"
+			_, err = resolver.ResolveDependency(dep)
+			if err == nil || !strings.Contains(err.Error(), "is a folder; set recursive = true") {
+				t.Errorf("Expected a folder error with file = %v, got %v", file, err)
+			}
+		}
+	})
 }
 
 func TestCreateTemplateIni(t *testing.T) {
@@ -146,7 +221,7 @@ func TestGenerateEnvFile(t *testing.T) {
 	defer os.Remove(tmpfile.Name())
 	tmpfile.Close()
 
-	if err := GenerateEnvFile(tmpfile.Name(), manifest); err != nil {
+	if _, err := GenerateEnvFile(tmpfile.Name(), manifest); err != nil {
 		t.Fatalf("GenerateEnvFile failed: %v", err)
 	}
 
@@ -161,6 +236,69 @@ func TestGenerateEnvFile(t *testing.T) {
 	}
 }
 
+// TestGenerateEnvFileGolden tests that dependencies are written sorted by name, so the
+// file is the same on every run
+func TestGenerateEnvFileGolden(t *testing.T) {
+	manifest := &DepsManifest{
+		Dependencies: map[string]*Dependency{
+			"zeta":     {Name: "zeta", Path: "docs/zeta.txt", Version: "1.0.0", OutputDir: "./local"},
+			"alpha":    {Name: "alpha", Path: "alpha-${version}.zip", Version: "2.1.0", OutputDir: "./local"},
+			"beta-lib": {Name: "beta-lib", Path: "beta/", Version: "0.9", OutputDir: "./local", Dest: "vendor/beta", Recursive: true},
+		},
+	}
+	golden, err := os.ReadFile(filepath.Join("testdata", "deps.env.golden"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 10; i++ {
+		filename := filepath.Join(t.TempDir(), "deps.env")
+		if _, err := GenerateEnvFile(filename, manifest); err != nil {
+			t.Fatalf("GenerateEnvFile failed: %v", err)
+		}
+		content, err := os.ReadFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(content) != string(golden) {
+			t.Fatalf("Expected:\n%s\nGot:\n%s", golden, content)
+		}
+	}
+}
+
+// TestGenerateEnvFileUnchanged tests that regenerating an up-to-date file leaves it and
+// its modification time untouched
+func TestGenerateEnvFileUnchanged(t *testing.T) {
+	manifest := &DepsManifest{
+		Dependencies: map[string]*Dependency{
+			"example_txt": {Name: "example_txt", Path: "example.txt", Version: "1.0.0", OutputDir: "./local"},
+		},
+	}
+	filename := filepath.Join(t.TempDir(), "deps.env")
+	if changed, err := GenerateEnvFile(filename, manifest); err != nil || !changed {
+		t.Fatalf("Expected a new file to be written, got %v, %v", changed, err)
+	}
+	past := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(filename, past, past); err != nil {
+		t.Fatal(err)
+	}
+
+	if changed, err := GenerateEnvFile(filename, manifest); err != nil || changed {
+		t.Fatalf("Expected an up-to-date file not to be written, got %v, %v", changed, err)
+	}
+	if info, err := os.Stat(filename); err != nil || !info.ModTime().Equal(past) {
+		t.Errorf("Expected the modification time to stay %s, got %v", past, info.ModTime())
+	}
+
+	manifest.Dependencies["example_txt"].Version = "1.0.1"
+	if changed, err := GenerateEnvFile(filename, manifest); err != nil || !changed {
+		t.Fatalf("Expected a changed file to be written, got %v, %v", changed, err)
+	}
+	if content, _ := os.ReadFile(filename); !strings.Contains(string(content), `DEPS_EXAMPLE_TXT_VERSION="1.0.1"`) {
+		t.Errorf("Expected the new version in the file, got:\n%s", content)
+	}
+}
+
 func TestResolverWithPerDependencyURL(t *testing.T) {
 	mockServer1 := nexusapi.NewMockNexusServer()
 	defer mockServer1.Close()
@@ -233,3 +371,45 @@ func TestResolverWithPerDependencyURL(t *testing.T) {
 		}
 	})
 }
+
+func TestResolverLatestVersion(t *testing.T) {
+	mockServer := nexusapi.NewMockNexusServer()
+	defer mockServer.Close()
+	for _, assetPath := range []string{
+		"/libfoo/1.9.0/libfoo.jar",
+		"/libfoo/1.10.0/libfoo.jar",
+		"/libfoo/v1.10.1-rc.1/libfoo.jar",
+		"/libfoo/nightly/libfoo.jar",
+		"/tools/tool-0.9.bin",
+		"/tools/tool-0.10.bin",
+		"/snapshots/2024-01-15/app.zip",
+		"/snapshots/2024-02-01/app.zip",
+	} {
+		mockServer.AddAsset("libs", assetPath, nexusapi.Asset{Checksum: nexusapi.Checksum{SHA256: "abcd1234"}}, nil)
+	}
+	resolver := NewResolver(nexusapi.NewClient(mockServer.URL, "admin", "admin"))
+
+	for _, tc := range []struct {
+		path     string
+		version  string
+		expected string
+	}{
+		{"libfoo/${version}/", "{latest}", "v1.10.1-rc.1"},
+		{"/tools/tool-${version}.bin", "{latest:semver}", "0.10"},
+		{"snapshots/${version}/app.zip", "{latest:lexical}", "2024-02-01"},
+	} {
+		dep := &Dependency{Name: "dep", Repository: "libs", Path: tc.path, Version: tc.version, Checksum: "sha256"}
+		version, err := resolver.ResolveVersion(dep)
+		if err != nil {
+			t.Errorf("ResolveVersion(%s) failed: %v", tc.path, err)
+		} else if version != tc.expected {
+			t.Errorf("ResolveVersion(%s) = %s, expected %s", tc.path, version, tc.expected)
+		}
+	}
+
+	dep := &Dependency{Name: "snapshots", Repository: "libs", Path: "snapshots/${version}/app.zip", Version: "{latest}", Checksum: "sha256"}
+	_, err := resolver.ResolveVersion(dep)
+	if err == nil || !strings.Contains(err.Error(), "2024-01-15, 2024-02-01") || !strings.Contains(err.Error(), "{latest:lexical}") {
+		t.Errorf("Expected an error listing the names that are not versions, got %v", err)
+	}
+}
@@ -0,0 +1,308 @@
+package deps
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/go-ini/ini"
+	"github.com/tympanix/nexus-cli/internal/checksum"
+)
+
+// JSONSchemaVersion is the version of the JSON schema that deps import reads and deps
+// export writes. It is raised whenever a field changes meaning or is removed.
+const JSONSchemaVersion = 1
+
+// JSONManifest is the JSON form of the dependencies of deps.ini, for migrating from and to
+// other tools:
+//
+//	{
+//	  "schema_version": 1,
+//	  "dependencies": [
+//	    {"name": "libfoo", "repository": "thirdparty", "path": "libfoo/${version}/", "version": "1.2.3",
+//	     "output": "./deps/libfoo", "checksum": "sha256", "recursive": true}
+//	  ]
+//	}
+type JSONManifest struct {
+	SchemaVersion int              `json:"schema_version"`
+	Dependencies  []JSONDependency `json:"dependencies"`
+}
+
+// JSONDependency is one dependency of a JSONManifest. Name and path are required; the
+// other fields are left to the [defaults] of deps.ini if empty.
+type JSONDependency struct {
+	Name       string `json:"name"`
+	Repository string `json:"repository,omitempty"`
+	Path       string `json:"path"`
+	Version    string `json:"version,omitempty"`
+	Output     string `json:"output,omitempty"` // output_dir in deps.ini
+	Checksum   string `json:"checksum,omitempty"`
+	URL        string `json:"url,omitempty"`
+	Recursive  bool   `json:"recursive,omitempty"`
+	File       bool   `json:"file,omitempty"`
+}
+
+// jsonManifestKeys and jsonDependencyKeys are the fields of the schema, to suggest the
+// field an unknown one is most likely a typo of
+var (
+	jsonManifestKeys   = map[string]bool{"schema_version": true, "dependencies": true}
+	jsonDependencyKeys = map[string]bool{"name": true, "repository": true, "path": true, "version": true, "output": true, "checksum": true, "url": true, "recursive": true, "file": true}
+)
+
+// ReadJSONManifest reads and validates a JSON manifest. Every problem is reported at
+// once in a *ManifestError, naming the dependency by its index and name.
+func ReadJSONManifest(filename string) (*JSONManifest, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", filename, err)
+	}
+	problems := &ManifestError{File: filename}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	var manifest JSONManifest
+	if err := decoder.Decode(&manifest); err != nil {
+		problems.Problems = append(problems.Problems, jsonProblem(data, err))
+		return nil, problems
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		problems.add("", "", "unexpected data after the JSON document")
+	}
+
+	switch {
+	case manifest.SchemaVersion == 0:
+		problems.add("", "", "missing \"schema_version\": set it to %d", JSONSchemaVersion)
+	case manifest.SchemaVersion > JSONSchemaVersion:
+		problems.add("", "", "schema_version %d is newer than this nexuscli-go supports (%d)", manifest.SchemaVersion, JSONSchemaVersion)
+	case manifest.SchemaVersion < 0:
+		problems.add("", "", "invalid schema_version %d: must be %d", manifest.SchemaVersion, JSONSchemaVersion)
+	}
+	if len(manifest.Dependencies) == 0 {
+		problems.add("", "", "no dependencies in \"dependencies\"")
+	}
+
+	seen := make(map[string]int)
+	for i, dep := range manifest.Dependencies {
+		where := fmt.Sprintf("dependencies[%d]", i)
+		if dep.Name != "" {
+			where += fmt.Sprintf(" (%s)", dep.Name)
+		}
+		switch {
+		case strings.TrimSpace(dep.Name) == "":
+			problems.add("", "", "%s: missing required \"name\"", where)
+		case dep.Name == "defaults" || dep.Name == ini.DefaultSection || dep.Name != strings.TrimSpace(dep.Name) || strings.ContainsAny(dep.Name, "[]\n"):
+			problems.add("", "", "%s: name %q cannot be used as a deps.ini section", where, dep.Name)
+		default:
+			if first, ok := seen[dep.Name]; ok {
+				problems.add("", "", "%s: name %q is already used by dependencies[%d]", where, dep.Name, first)
+			}
+			seen[dep.Name] = i
+		}
+		if dep.Path == "" {
+			problems.add("", "", "%s: missing required \"path\"", where)
+		}
+		if dep.Output != "" {
+			if err := validateOutputDir(dep.Output); err != nil {
+				problems.add("", "", "%s: invalid \"output\": %v", where, strings.Replace(err.Error(), "output_dir", "output", 1))
+			}
+		}
+		if dep.Checksum != "" {
+			if err := checkChecksumAlgorithms(dep.Checksum); err != nil {
+				problems.add("", "", "%s: invalid \"checksum\": %v", where, err)
+			}
+		}
+		if dep.File && dep.Recursive {
+			problems.add("", "", "%s: cannot set both \"file\" and \"recursive\"", where)
+		}
+	}
+
+	if len(problems.Problems) > 0 {
+		return nil, problems
+	}
+	return &manifest, nil
+}
+
+// jsonProblem describes an error decoding the JSON document data, with the line it is on
+// and the schema field an unknown field is most likely a typo of
+func jsonProblem(data []byte, err error) Problem {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		return Problem{Line: lineAt(data, syntaxErr.Offset), Message: fmt.Sprintf("invalid JSON: %v", syntaxErr)}
+	case errors.As(err, &typeErr):
+		return Problem{Line: lineAt(data, typeErr.Offset), Message: fmt.Sprintf("%s must be a %s, got a JSON %s", jsonIndex.ReplaceAllString(typeErr.Field, "[$1]"), typeErr.Type, typeErr.Value)}
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return Problem{Message: "invalid JSON: unexpected end of file"}
+	}
+	// Unknown fields are only reported as text, e.g. json: unknown field "outptu"
+	if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		field = strings.Trim(field, `"`)
+		valid := make(map[string]bool)
+		for _, keys := range []map[string]bool{jsonManifestKeys, jsonDependencyKeys} {
+			for key := range keys {
+				valid[key] = true
+			}
+		}
+		if suggestion := closestKey(field, valid); suggestion != "" {
+			return Problem{Message: fmt.Sprintf("unknown field %q (did you mean %q?)", field, suggestion)}
+		}
+		return Problem{Message: fmt.Sprintf("unknown field %q", field)}
+	}
+	return Problem{Message: err.Error()}
+}
+
+// jsonIndex matches an array index in the field path of a decoding error, e.g. the ".0" of
+// "dependencies.0.recursive"
+var jsonIndex = regexp.MustCompile(`\.(\d+)`)
+
+// lineAt returns the line of the byte at offset in data
+func lineAt(data []byte, offset int64) int {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	return bytes.Count(data[:offset], []byte("\n")) + 1
+}
+
+// checkChecksumAlgorithms returns an error if value lists no or an unsupported algorithm
+func checkChecksumAlgorithms(value string) error {
+	algorithms := (&Dependency{Checksum: value}).ChecksumAlgorithms()
+	if len(algorithms) == 0 {
+		return errors.New("checksum cannot be empty")
+	}
+	for _, algorithm := range algorithms {
+		if _, err := checksum.NewHasher(algorithm); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ImportJSON adds the dependencies of manifest to the deps.ini at filename as sections,
+// creating the file if it does not exist, and returns their names. Comments and other
+// sections of an existing deps.ini are kept. A dependency that already has a section is
+// an error, unless force is set, in which case its section is replaced.
+func ImportJSON(filename string, manifest *JSONManifest, force bool) ([]string, error) {
+	cfg := ini.Empty()
+	defaults := Defaults{}
+	if _, err := os.Stat(filename); err == nil {
+		existing, err := ParseDepsIni(filename)
+		if err != nil {
+			return nil, err
+		}
+		defaults = existing.Defaults
+		if cfg, err = ini.Load(filename); err != nil {
+			return nil, fmt.Errorf("failed to open %s: %w", filename, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	problems := &ManifestError{File: filename}
+	var clobbered []string
+	for _, dep := range manifest.Dependencies {
+		if dep.Repository == "" && defaults.Repository == "" {
+			problems.add("", "", "dependency %s has no \"repository\" and %s sets no default repository", dep.Name, filename)
+		}
+		if cfg.HasSection(dep.Name) && !force {
+			clobbered = append(clobbered, "["+dep.Name+"]")
+		}
+	}
+	if len(clobbered) > 0 {
+		problems.add("", "", "already has section(s) %s; use --force to replace them", strings.Join(clobbered, ", "))
+	}
+	if len(problems.Problems) > 0 {
+		return nil, problems
+	}
+
+	names := make([]string, 0, len(manifest.Dependencies))
+	for _, dep := range manifest.Dependencies {
+		cfg.DeleteSection(dep.Name)
+		section, err := cfg.NewSection(dep.Name)
+		if err != nil {
+			return nil, err
+		}
+		for _, kv := range [][2]string{
+			{"url", dep.URL},
+			{"repository", dep.Repository},
+			{"path", dep.Path},
+			{"version", dep.Version},
+			{"checksum", dep.Checksum},
+			{"output_dir", dep.Output},
+		} {
+			if kv[1] != "" {
+				section.NewKey(kv[0], kv[1])
+			}
+		}
+		if dep.Recursive {
+			section.NewKey("recursive", "true")
+		}
+		if dep.File {
+			section.NewKey("file", "true")
+		}
+		names = append(names, dep.Name)
+	}
+
+	if err := cfg.SaveTo(filename); err != nil {
+		return nil, fmt.Errorf("failed to write %s: %w", filename, err)
+	}
+	return names, nil
+}
+
+// ExportJSON returns the dependencies of manifest as a JSON manifest, sorted by name, with
+// the [defaults] of deps.ini applied. Settings the schema has no field for are not
+// exported; they are returned as "dependency: key" so they can be reported.
+func ExportJSON(manifest *DepsManifest) (*JSONManifest, []string) {
+	names := make([]string, 0, len(manifest.Dependencies))
+	for name := range manifest.Dependencies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	exported := &JSONManifest{SchemaVersion: JSONSchemaVersion, Dependencies: make([]JSONDependency, 0, len(names))}
+	var skipped []string
+	for _, name := range names {
+		dep := manifest.Dependencies[name]
+		exported.Dependencies = append(exported.Dependencies, JSONDependency{
+			Name:       name,
+			Repository: dep.Repository,
+			Path:       dep.Path,
+			Version:    dep.Version,
+			Output:     dep.OutputDir,
+			Checksum:   dep.Checksum,
+			URL:        dep.URL,
+			Recursive:  dep.Recursive,
+			File:       dep.File,
+		})
+		for _, kv := range []struct {
+			key string
+			set bool
+		}{
+			{"dest", dep.Dest != ""},
+			{"ca_cert", dep.CACert != ""},
+			{"concurrency", dep.Concurrency > 0},
+			{"max_rate", dep.MaxRate > 0},
+		} {
+			if kv.set {
+				skipped = append(skipped, name+": "+kv.key)
+			}
+		}
+	}
+	return exported, skipped
+}
+
+// WriteJSONManifest writes manifest to w as indented JSON
+func WriteJSONManifest(w io.Writer, manifest *JSONManifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
@@ -2,12 +2,25 @@ package deps
 
 import (
 	"fmt"
+	"os"
 	"sort"
+	"strconv"
 	"strings"
+	"unicode"
 
 	"github.com/go-ini/ini"
+	"github.com/tympanix/nexus-cli/internal/checksum"
 )
 
+// LockSizeKey names the size of a file among the verified algorithms of VerifyLockedFile.
+// Sizes are recorded in each entry of a locked checksum, e.g. "sha256:9f86…:1048576".
+// Lock files written before sizes were recorded have none.
+const LockSizeKey = "size"
+
+// LockVersionKey is the key of the version resolved for a {latest} selector, in the
+// section of its dependency
+const LockVersionKey = "@version"
+
 func ParseLockFile(filename string) (*LockFile, error) {
 	cfg, err := ini.Load(filename)
 	if err != nil {
@@ -16,6 +29,7 @@ func ParseLockFile(filename string) (*LockFile, error) {
 
 	lockFile := &LockFile{
 		Dependencies: make(map[string]map[string]string),
+		Versions:     make(map[string]string),
 	}
 
 	for _, section := range cfg.Sections() {
@@ -26,6 +40,13 @@ func ParseLockFile(filename string) (*LockFile, error) {
 
 		lockFile.Dependencies[sectionName] = make(map[string]string)
 		for _, key := range section.Keys() {
+			if key.Name() == LockVersionKey {
+				lockFile.Versions[sectionName] = key.String()
+				continue
+			}
+			if _, err := ParseLockChecksums(key.String()); err != nil {
+				return nil, fmt.Errorf("invalid entry for %s in [%s] of %s: %w", key.Name(), sectionName, filename, err)
+			}
 			lockFile.Dependencies[sectionName][key.Name()] = key.String()
 		}
 	}
@@ -33,6 +54,10 @@ func ParseLockFile(filename string) (*LockFile, error) {
 	return lockFile, nil
 }
 
+// WriteLockFile writes lockFile sorted by dependency and file path, with the resolved
+// version of a dependency before its files. Locked checksums are
+// written in their canonical form, "algorithm:hex:size" entries separated by single
+// spaces, or "algorithm:hex" if the size is not known.
 func WriteLockFile(filename string, lockFile *LockFile) error {
 	cfg := ini.Empty()
 
@@ -45,6 +70,9 @@ func WriteLockFile(filename string, lockFile *LockFile) error {
 	for _, depName := range depNames {
 		files := lockFile.Dependencies[depName]
 		section, _ := cfg.NewSection(depName)
+		if version, ok := lockFile.Versions[depName]; ok {
+			section.NewKey(LockVersionKey, version)
+		}
 
 		var filePaths []string
 		for filePath := range files {
@@ -53,8 +81,11 @@ func WriteLockFile(filename string, lockFile *LockFile) error {
 		sort.Strings(filePaths)
 
 		for _, filePath := range filePaths {
-			checksum := files[filePath]
-			section.NewKey(filePath, checksum)
+			checksums, err := ParseLockChecksums(files[filePath])
+			if err != nil {
+				return fmt.Errorf("invalid checksum for %s in [%s]: %w", filePath, depName, err)
+			}
+			section.NewKey(filePath, FormatLockChecksums(checksums))
 		}
 	}
 
@@ -65,6 +96,115 @@ func WriteLockFile(filename string, lockFile *LockFile) error {
 	return nil
 }
 
+// ParseLockChecksums parses a locked checksum into its entries. Entries are separated by
+// spaces or commas and have the form "algorithm:hex:size" or, in lock files written before
+// sizes were recorded, "algorithm:hex"; a single "algorithm:hex" entry is the format of
+// older lock files. Whitespace after the first colon of an entry is allowed. All entries
+// get the same size, or -1 if none is recorded.
+func ParseLockChecksums(s string) ([]LockChecksum, error) {
+	var checksums []LockChecksum
+	seen := make(map[string]bool)
+	fields := strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	})
+	for _, field := range fields {
+		// The hex part of an entry written as "algorithm: hex"
+		if n := len(checksums); n > 0 && checksums[n-1].Value == "" {
+			checksums[n-1].Value = field
+			continue
+		}
+		alg, value, ok := strings.Cut(field, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid checksum format in lock file: %s", s)
+		}
+		alg = strings.ToLower(alg)
+		if alg == "" || alg == LockSizeKey {
+			return nil, fmt.Errorf("invalid checksum format in lock file: %s", s)
+		}
+		if seen[alg] {
+			return nil, fmt.Errorf("duplicate %s checksum in lock file: %s", alg, s)
+		}
+		seen[alg] = true
+		checksums = append(checksums, LockChecksum{Algorithm: alg, Value: value, Size: -1})
+	}
+
+	if len(checksums) == 0 {
+		return nil, fmt.Errorf("invalid checksum format in lock file: %s", s)
+	}
+	size := int64(-1)
+	for i, c := range checksums {
+		if c.Value == "" {
+			return nil, fmt.Errorf("invalid checksum format in lock file: %s", s)
+		}
+		value, sizeValue, ok := strings.Cut(c.Value, ":")
+		if !ok {
+			continue
+		}
+		n, err := strconv.ParseInt(sizeValue, 10, 64)
+		if err != nil || n < 0 || value == "" {
+			return nil, fmt.Errorf("invalid size in lock file: %s", s)
+		}
+		if size >= 0 && n != size {
+			return nil, fmt.Errorf("conflicting sizes in lock file: %s", s)
+		}
+		checksums[i].Value, size = value, n
+	}
+	for i := range checksums {
+		checksums[i].Size = size
+	}
+	return checksums, nil
+}
+
+// LockedSize returns the size recorded in a locked checksum, and false if it has none
+func LockedSize(locked string) (int64, bool) {
+	checksums, err := ParseLockChecksums(locked)
+	if err != nil || checksums[0].Size < 0 {
+		return 0, false
+	}
+	return checksums[0].Size, true
+}
+
+// LockedTotalSize returns the total size of the locked files, and false if the size of
+// any of them is not recorded
+func LockedTotalSize(lockedFiles map[string]string) (int64, bool) {
+	var total int64
+	for _, locked := range lockedFiles {
+		size, ok := LockedSize(locked)
+		if !ok {
+			return 0, false
+		}
+		total += size
+	}
+	return total, true
+}
+
+// canonicalLockChecksums returns a locked checksum in the form written by WriteLockFile,
+// optionally without its size
+func canonicalLockChecksums(locked string, withSize bool) string {
+	checksums, err := ParseLockChecksums(locked)
+	if err != nil {
+		return locked
+	}
+	if !withSize {
+		for i := range checksums {
+			checksums[i].Size = -1
+		}
+	}
+	return FormatLockChecksums(checksums)
+}
+
+// FormatLockChecksums formats checksums as a locked checksum, in the order given
+func FormatLockChecksums(checksums []LockChecksum) string {
+	entries := make([]string, len(checksums))
+	for i, c := range checksums {
+		entries[i] = c.Algorithm + ":" + c.Value
+		if c.Size >= 0 {
+			entries[i] += ":" + strconv.FormatInt(c.Size, 10)
+		}
+	}
+	return strings.Join(entries, " ")
+}
+
 func VerifyLockFile(lockFile *LockFile, depName string, filePath string, algorithm string, actualChecksum string) error {
 	if lockFile.Dependencies[depName] == nil {
 		return fmt.Errorf("dependency %s not found in lock file", depName)
@@ -75,21 +215,243 @@ func VerifyLockFile(lockFile *LockFile, depName string, filePath string, algorit
 		return fmt.Errorf("file %s not found in lock file for dependency %s", filePath, depName)
 	}
 
-	parts := strings.SplitN(expectedChecksumStr, ":", 2)
-	if len(parts) != 2 {
-		return fmt.Errorf("invalid checksum format in lock file: %s", expectedChecksumStr)
+	checksums, err := ParseLockChecksums(expectedChecksumStr)
+	if err != nil {
+		return err
+	}
+
+	var algorithms []string
+	for _, c := range checksums {
+		if !strings.EqualFold(c.Algorithm, algorithm) {
+			algorithms = append(algorithms, c.Algorithm)
+			continue
+		}
+		match, err := checksum.Matches(c.Value, actualChecksum, algorithm)
+		if err != nil {
+			return fmt.Errorf("invalid checksum for %s in lock file: %w", filePath, err)
+		}
+		if !match {
+			return fmt.Errorf("checksum mismatch for %s: expected %s, got %s", filePath, c.Value, actualChecksum)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("checksum algorithm mismatch: expected %s, got %s", strings.Join(algorithms, ", "), algorithm)
+}
+
+// VerifyLockedFile checks the file at localPath against every checksum of locked and
+// returns the algorithms that were verified. It fails on the first mismatch, so a file
+// matching only some of its locked checksums is rejected. A recorded size is checked
+// first, so a truncated file is rejected without hashing it.
+func VerifyLockedFile(localPath string, locked string) ([]string, error) {
+	return verifyLocked(localPath, locked, func() (int64, error) {
+		info, err := os.Stat(localPath)
+		if err != nil {
+			return 0, err
+		}
+		return info.Size(), nil
+	}, func(algorithm string) (string, error) {
+		actual, err := checksum.ComputeChecksum(localPath, algorithm)
+		if err != nil {
+			return "", fmt.Errorf("error computing checksum for %s: %w", localPath, err)
+		}
+		return actual, nil
+	})
+}
+
+// VerifyLockedDigest checks the file at localPath like VerifyLockedFile, but against its
+// size and checksums computed while it was downloaded, so the file is not read again.
+// checksums is keyed by lower case algorithm and must hold every algorithm of locked.
+func VerifyLockedDigest(localPath string, locked string, size int64, checksums map[string]string) ([]string, error) {
+	return verifyLocked(localPath, locked, func() (int64, error) {
+		return size, nil
+	}, func(algorithm string) (string, error) {
+		actual, ok := checksums[strings.ToLower(algorithm)]
+		if !ok {
+			return "", fmt.Errorf("no %s checksum was computed for %s", algorithm, localPath)
+		}
+		return actual, nil
+	})
+}
+
+// verifyLocked checks a file against locked, getting its size and checksums from the
+// given functions
+func verifyLocked(localPath string, locked string, fileSize func() (int64, error), sum func(algorithm string) (string, error)) ([]string, error) {
+	checksums, err := ParseLockChecksums(locked)
+	if err != nil {
+		return nil, err
+	}
+
+	algorithms := make([]string, 0, len(checksums)+1)
+	if size := checksums[0].Size; size >= 0 {
+		actual, err := fileSize()
+		if err != nil {
+			return nil, err
+		}
+		if actual != size {
+			return nil, fmt.Errorf("size mismatch for %s\n  Expected: %d bytes\n  Got: %d bytes", localPath, size, actual)
+		}
+		algorithms = append(algorithms, LockSizeKey)
+	}
+	for _, c := range checksums {
+		actual, err := sum(c.Algorithm)
+		if err != nil {
+			return nil, err
+		}
+		match, err := checksum.Matches(c.Value, actual, c.Algorithm)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s checksum for %s in lock file: %w", c.Algorithm, localPath, err)
+		}
+		if !match {
+			return nil, fmt.Errorf("%s checksum mismatch for %s\n  Expected: %s\n  Got: %s", c.Algorithm, localPath, c.Value, actual)
+		}
+		algorithms = append(algorithms, c.Algorithm)
+	}
+	return algorithms, nil
+}
+
+// CheckLockFile checks without contacting Nexus that lockFile, read from filename, is
+// consistent with manifest: every dependency is locked, with a version if it selects
+// {latest}, every locked section is a dependency, and every locked file has a checksum
+// for each algorithm of its dependency.
+// The problems found are returned in a *ManifestError.
+func CheckLockFile(filename string, manifest *DepsManifest, lockFile *LockFile) error {
+	problems := &ManifestError{File: filename, lines: indexIniLines(filename)}
+
+	names := make([]string, 0, len(manifest.Dependencies))
+	for name := range manifest.Dependencies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		lockedFiles, ok := lockFile.Dependencies[name]
+		if !ok {
+			problems.add(name, "", "dependency %s is not locked; run 'deps lock'", name)
+			continue
+		}
+		if _, ok := lockFile.Versions[name]; !ok && manifest.Dependencies[name].LatestVersion() {
+			problems.add(name, "", "dependency %s selects version %s but no version is locked; run 'deps lock'", name, manifest.Dependencies[name].Version)
+		}
+		filePaths := make([]string, 0, len(lockedFiles))
+		for filePath := range lockedFiles {
+			filePaths = append(filePaths, filePath)
+		}
+		sort.Strings(filePaths)
+		for _, filePath := range filePaths {
+			checksums, err := ParseLockChecksums(lockedFiles[filePath])
+			if err != nil {
+				problems.add(name, filePath, "invalid entry for %s in [%s]: %v", filePath, name, err)
+				continue
+			}
+			locked := make(map[string]bool, len(checksums))
+			for _, c := range checksums {
+				locked[c.Algorithm] = true
+			}
+			for _, algorithm := range manifest.Dependencies[name].ChecksumAlgorithms() {
+				if !locked[algorithm] {
+					problems.add(name, filePath, "%s in [%s] has no %s checksum; run 'deps lock'", filePath, name, algorithm)
+				}
+			}
+		}
+	}
+
+	locked := make([]string, 0, len(lockFile.Dependencies))
+	for name := range lockFile.Dependencies {
+		if _, ok := manifest.Dependencies[name]; !ok {
+			locked = append(locked, name)
+		}
+	}
+	sort.Strings(locked)
+	for _, name := range locked {
+		problems.add(name, "", "[%s] is locked but not a dependency in deps.ini; run 'deps lock'", name)
 	}
 
-	expectedAlgorithm := parts[0]
-	expectedChecksum := parts[1]
+	if len(problems.Problems) > 0 {
+		return problems
+	}
+	return nil
+}
 
-	if !strings.EqualFold(expectedAlgorithm, algorithm) {
-		return fmt.Errorf("checksum algorithm mismatch: expected %s, got %s", expectedAlgorithm, algorithm)
+// DiffLockFiles compares two lock files and returns the differences as sorted diff
+// lines: "- [dep] path = checksum" for entries only in current and "+ [dep] path = checksum"
+// for entries only in resolved. A changed checksum or resolved version shows up as a
+// removal followed by an addition.
+// An empty result means the lock files are identical. Entries are compared in their
+// canonical form, and a size that current does not record yet is not a difference, so
+// lock files from before sizes were recorded stay up to date.
+func DiffLockFiles(current, resolved *LockFile) []string {
+	depNames := make(map[string]bool)
+	for depName := range current.Dependencies {
+		depNames[depName] = true
+	}
+	for depName := range resolved.Dependencies {
+		depNames[depName] = true
 	}
+	sortedDeps := make([]string, 0, len(depNames))
+	for depName := range depNames {
+		sortedDeps = append(sortedDeps, depName)
+	}
+	sort.Strings(sortedDeps)
 
-	if !strings.EqualFold(expectedChecksum, actualChecksum) {
-		return fmt.Errorf("checksum mismatch for %s: expected %s, got %s", filePath, expectedChecksum, actualChecksum)
+	var diff []string
+	for _, depName := range sortedDeps {
+		oldVersion, inOld := current.Versions[depName]
+		newVersion, inNew := resolved.Versions[depName]
+		if inOld && (!inNew || oldVersion != newVersion) {
+			diff = append(diff, fmt.Sprintf("- [%s] %s = %s", depName, LockVersionKey, oldVersion))
+		}
+		if inNew && (!inOld || oldVersion != newVersion) {
+			diff = append(diff, fmt.Sprintf("+ [%s] %s = %s", depName, LockVersionKey, newVersion))
+		}
+
+		oldFiles := current.Dependencies[depName]
+		newFiles := resolved.Dependencies[depName]
+
+		filePaths := make(map[string]bool)
+		for filePath := range oldFiles {
+			filePaths[filePath] = true
+		}
+		for filePath := range newFiles {
+			filePaths[filePath] = true
+		}
+		sortedPaths := make([]string, 0, len(filePaths))
+		for filePath := range filePaths {
+			sortedPaths = append(sortedPaths, filePath)
+		}
+		sort.Strings(sortedPaths)
+
+		for _, filePath := range sortedPaths {
+			oldChecksum, inOld := oldFiles[filePath]
+			newChecksum, inNew := newFiles[filePath]
+			if inOld && inNew {
+				_, sized := LockedSize(oldChecksum)
+				if canonicalLockChecksums(oldChecksum, sized) == canonicalLockChecksums(newChecksum, sized) {
+					continue
+				}
+			}
+			if inOld {
+				diff = append(diff, fmt.Sprintf("- [%s] %s = %s", depName, filePath, oldChecksum))
+			}
+			if inNew {
+				diff = append(diff, fmt.Sprintf("+ [%s] %s = %s", depName, filePath, newChecksum))
+			}
+		}
 	}
+	return diff
+}
 
+// PinVersions sets the version of every dependency of manifest that selects {latest} to
+// the version locked in lockFile, so its paths name the files that were locked
+func PinVersions(manifest *DepsManifest, lockFile *LockFile) error {
+	for name, dep := range manifest.Dependencies {
+		if !dep.LatestVersion() {
+			continue
+		}
+		version, ok := lockFile.Versions[name]
+		if !ok {
+			return fmt.Errorf("dependency %s selects version %s but deps-lock.ini locks no version; run 'deps lock'", name, dep.Version)
+		}
+		dep.Version = version
+	}
 	return nil
 }
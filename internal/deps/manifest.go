@@ -0,0 +1,185 @@
+package deps
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/go-ini/ini"
+	"github.com/tympanix/nexus-cli/internal/checksum"
+)
+
+// Problem is a single problem found in deps.ini or deps-lock.ini
+type Problem struct {
+	Line    int    // Line in the file, 0 if not known
+	Section string // Section the problem is in, empty if it concerns the whole file
+	Key     string // Key the problem is about, empty if it concerns the whole section
+	Message string
+}
+
+func (p Problem) String() string {
+	if p.Line > 0 {
+		return fmt.Sprintf("line %d: %s", p.Line, p.Message)
+	}
+	return p.Message
+}
+
+// ManifestError lists every problem found in a file
+type ManifestError struct {
+	File     string
+	Problems []Problem
+	lines    iniLines
+}
+
+func (e *ManifestError) Error() string {
+	if len(e.Problems) == 1 {
+		return fmt.Sprintf("%s: %s", e.File, e.Problems[0])
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d problems in %s:", len(e.Problems), e.File)
+	for _, p := range e.Problems {
+		fmt.Fprintf(&b, "\n  %s", p)
+	}
+	return b.String()
+}
+
+// add records a problem with the key of a section, or with the section if key is empty
+func (e *ManifestError) add(section, key string, format string, args ...any) {
+	e.Problems = append(e.Problems, Problem{
+		Line:    e.lines.line(section, key),
+		Section: section,
+		Key:     key,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// addErr records err, if any, as a problem with the key of a section
+func (e *ManifestError) addErr(section, key string, err error) {
+	if err != nil {
+		e.add(section, key, "invalid [%s] section: %v", section, err)
+	}
+}
+
+// checkKeys records a problem for every key of section that is not in valid, suggesting
+// the valid key it is most likely a typo of
+func (e *ManifestError) checkKeys(section *ini.Section, valid map[string]bool) {
+	for _, key := range section.KeyStrings() {
+		if valid[key] {
+			continue
+		}
+		if suggestion := closestKey(key, valid); suggestion != "" {
+			e.add(section.Name(), key, "unknown key '%s' in [%s] section (did you mean '%s'?)", key, section.Name(), suggestion)
+		} else {
+			e.add(section.Name(), key, "unknown key '%s' in [%s] section", key, section.Name())
+		}
+	}
+}
+
+// checkChecksum records a problem if a checksum algorithm of section is not supported
+func (e *ManifestError) checkChecksum(section *ini.Section) {
+	algorithms := (&Dependency{Checksum: section.Key("checksum").String()}).ChecksumAlgorithms()
+	if len(algorithms) == 0 {
+		e.add(section.Name(), "checksum", "invalid [%s] section: checksum cannot be empty", section.Name())
+	}
+	for _, algorithm := range algorithms {
+		if _, err := checksum.NewHasher(algorithm); err != nil {
+			e.add(section.Name(), "checksum", "invalid [%s] section: %v", section.Name(), err)
+		}
+	}
+}
+
+// closestKey returns the key of valid within two edits of key, or "" if there is none
+func closestKey(key string, valid map[string]bool) string {
+	best, bestDistance := "", 3
+	candidates := make([]string, 0, len(valid))
+	for candidate := range valid {
+		candidates = append(candidates, candidate)
+	}
+	sort.Strings(candidates)
+	for _, candidate := range candidates {
+		if d := editDistance(strings.ToLower(key), candidate); d < bestDistance {
+			best, bestDistance = candidate, d
+		}
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+// iniLines holds the line numbers of the sections and keys of an INI file, which the INI
+// library does not expose
+type iniLines struct {
+	sections map[string]int
+	keys     map[string]map[string]int
+}
+
+// indexIniLines finds the line of every section and key in filename. Line numbers are
+// only used in messages, so a file that cannot be read yields an empty index.
+func indexIniLines(filename string) iniLines {
+	lines := iniLines{sections: make(map[string]int), keys: make(map[string]map[string]int)}
+	f, err := os.Open(filename)
+	if err != nil {
+		return lines
+	}
+	defer f.Close()
+
+	section := ini.DefaultSection
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			if _, ok := lines.sections[section]; !ok {
+				lines.sections[section] = n
+			}
+			continue
+		}
+		i := strings.IndexAny(line, "=:")
+		if i < 0 {
+			continue
+		}
+		key := strings.TrimSpace(line[:i])
+		if lines.keys[section] == nil {
+			lines.keys[section] = make(map[string]int)
+		}
+		if _, ok := lines.keys[section][key]; !ok {
+			lines.keys[section][key] = n
+		}
+	}
+	return lines
+}
+
+// line returns the line of key in section, or of the section itself if key is empty or
+// not found, and 0 if neither is known
+func (l iniLines) line(section, key string) int {
+	if key != "" {
+		if n, ok := l.keys[section][key]; ok {
+			return n
+		}
+	}
+	return l.sections[section]
+}
@@ -3,8 +3,11 @@ package deps
 import (
 	"fmt"
 	"path/filepath"
+	"strconv"
+	"strings"
 
 	"github.com/go-ini/ini"
+	"github.com/tympanix/nexus-cli/internal/util"
 )
 
 func validateOutputDir(dir string) error {
@@ -25,11 +28,66 @@ func validateOutputDir(dir string) error {
 	return nil
 }
 
+func parseConcurrency(value string) (int, error) {
+	concurrency, err := strconv.Atoi(value)
+	if err != nil || concurrency < 1 {
+		return 0, fmt.Errorf("concurrency must be a positive integer, got '%s'", value)
+	}
+	return concurrency, nil
+}
+
+func parseMaxRate(value string) (int64, error) {
+	maxRate, err := util.ParseByteSize(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid max_rate: %w", err)
+	}
+	return maxRate, nil
+}
+
+func parseMaxSize(value string) (int64, error) {
+	maxSize, err := util.ParseByteSize(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid max_size: %w", err)
+	}
+	return maxSize, nil
+}
+
+// validDefaultKeys are the keys of the [defaults] section of deps.ini
+var validDefaultKeys = map[string]bool{
+	"repository":  true,
+	"checksum":    true,
+	"output_dir":  true,
+	"url":         true,
+	"ca_cert":     true,
+	"concurrency": true,
+	"max_rate":    true,
+	"max_size":    true,
+}
+
+// validDependencyKeys are the keys of a dependency section of deps.ini
+var validDependencyKeys = map[string]bool{
+	"repository":  true,
+	"path":        true,
+	"version":     true,
+	"checksum":    true,
+	"output_dir":  true,
+	"dest":        true,
+	"recursive":   true,
+	"file":        true,
+	"url":         true,
+	"ca_cert":     true,
+	"concurrency": true,
+	"max_rate":    true,
+}
+
+// ParseDepsIni reads and validates deps.ini. Every problem in the file is reported at
+// once in a *ManifestError, rather than stopping at the first one.
 func ParseDepsIni(filename string) (*DepsManifest, error) {
 	cfg, err := ini.Load(filename)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open %s: %w", filename, err)
 	}
+	problems := &ManifestError{File: filename, lines: indexIniLines(filename)}
 
 	manifest := &DepsManifest{
 		Defaults: Defaults{
@@ -39,29 +97,24 @@ func ParseDepsIni(filename string) (*DepsManifest, error) {
 			URL:        "",
 		},
 		Dependencies: make(map[string]*Dependency),
+		File:         filename,
 	}
 
-	validDefaultKeys := map[string]bool{
-		"repository": true,
-		"checksum":   true,
-		"output_dir": true,
-		"url":        true,
+	// Keys before the first section would silently be ignored
+	for _, key := range cfg.Section(ini.DefaultSection).KeyStrings() {
+		problems.add(ini.DefaultSection, key, "key '%s' is not in a section", key)
 	}
 
 	if cfg.HasSection("defaults") {
 		defaultsSection := cfg.Section("defaults")
-
-		for _, key := range defaultsSection.KeyStrings() {
-			if !validDefaultKeys[key] {
-				return nil, fmt.Errorf("unknown key '%s' in [defaults] section", key)
-			}
-		}
+		problems.checkKeys(defaultsSection, validDefaultKeys)
 
 		if defaultsSection.HasKey("repository") {
 			manifest.Defaults.Repository = defaultsSection.Key("repository").String()
 		}
 		if defaultsSection.HasKey("checksum") {
 			manifest.Defaults.Checksum = defaultsSection.Key("checksum").String()
+			problems.checkChecksum(defaultsSection)
 		}
 		if defaultsSection.HasKey("output_dir") {
 			manifest.Defaults.OutputDir = defaultsSection.Key("output_dir").String()
@@ -69,37 +122,39 @@ func ParseDepsIni(filename string) (*DepsManifest, error) {
 		if defaultsSection.HasKey("url") {
 			manifest.Defaults.URL = defaultsSection.Key("url").String()
 		}
-	}
-
-	validDependencyKeys := map[string]bool{
-		"repository": true,
-		"path":       true,
-		"version":    true,
-		"checksum":   true,
-		"output_dir": true,
-		"dest":       true,
-		"recursive":  true,
-		"url":        true,
+		if defaultsSection.HasKey("ca_cert") {
+			manifest.Defaults.CACert = defaultsSection.Key("ca_cert").String()
+		}
+		if defaultsSection.HasKey("concurrency") {
+			manifest.Defaults.Concurrency, err = parseConcurrency(defaultsSection.Key("concurrency").String())
+			problems.addErr("defaults", "concurrency", err)
+		}
+		if defaultsSection.HasKey("max_rate") {
+			manifest.Defaults.MaxRate, err = parseMaxRate(defaultsSection.Key("max_rate").String())
+			problems.addErr("defaults", "max_rate", err)
+		}
+		if defaultsSection.HasKey("max_size") {
+			manifest.Defaults.MaxSize, err = parseMaxSize(defaultsSection.Key("max_size").String())
+			problems.addErr("defaults", "max_size", err)
+		}
 	}
 
 	for _, section := range cfg.Sections() {
 		sectionName := section.Name()
-		if sectionName == "DEFAULT" || sectionName == "defaults" {
+		if sectionName == ini.DefaultSection || sectionName == "defaults" {
 			continue
 		}
-
-		for _, key := range section.KeyStrings() {
-			if !validDependencyKeys[key] {
-				return nil, fmt.Errorf("unknown key '%s' in [%s] section", key, sectionName)
-			}
-		}
+		problems.checkKeys(section, validDependencyKeys)
 
 		dep := &Dependency{
-			Name:       sectionName,
-			Repository: manifest.Defaults.Repository,
-			Checksum:   manifest.Defaults.Checksum,
-			OutputDir:  manifest.Defaults.OutputDir,
-			URL:        manifest.Defaults.URL,
+			Name:        sectionName,
+			Repository:  manifest.Defaults.Repository,
+			Checksum:    manifest.Defaults.Checksum,
+			OutputDir:   manifest.Defaults.OutputDir,
+			URL:         manifest.Defaults.URL,
+			CACert:      manifest.Defaults.CACert,
+			Concurrency: manifest.Defaults.Concurrency,
+			MaxRate:     manifest.Defaults.MaxRate,
 		}
 
 		if section.HasKey("repository") {
@@ -113,6 +168,7 @@ func ParseDepsIni(filename string) (*DepsManifest, error) {
 		}
 		if section.HasKey("checksum") {
 			dep.Checksum = section.Key("checksum").String()
+			problems.checkChecksum(section)
 		}
 		if section.HasKey("output_dir") {
 			dep.OutputDir = section.Key("output_dir").String()
@@ -121,38 +177,77 @@ func ParseDepsIni(filename string) (*DepsManifest, error) {
 			dep.Dest = section.Key("dest").String()
 		}
 		if section.HasKey("recursive") {
-			dep.Recursive, _ = section.Key("recursive").Bool()
+			dep.Recursive, err = section.Key("recursive").Bool()
+			if err != nil {
+				problems.add(sectionName, "recursive", "recursive must be true or false, got '%s'", section.Key("recursive").String())
+			}
+		}
+		if section.HasKey("file") {
+			dep.File, err = section.Key("file").Bool()
+			if err != nil {
+				problems.add(sectionName, "file", "file must be true or false, got '%s'", section.Key("file").String())
+			}
+		}
+		if dep.File && dep.Recursive {
+			problems.add(sectionName, "file", "dependency %s cannot set both file and recursive", sectionName)
 		}
 		if section.HasKey("url") {
 			dep.URL = section.Key("url").String()
 		}
+		if section.HasKey("ca_cert") {
+			dep.CACert = section.Key("ca_cert").String()
+		}
+		if section.HasKey("concurrency") {
+			dep.Concurrency, err = parseConcurrency(section.Key("concurrency").String())
+			problems.addErr(sectionName, "concurrency", err)
+		}
+		if section.HasKey("max_rate") {
+			dep.MaxRate, err = parseMaxRate(section.Key("max_rate").String())
+			problems.addErr(sectionName, "max_rate", err)
+		}
 
-		manifest.Dependencies[sectionName] = dep
-	}
-
-	for name, dep := range manifest.Dependencies {
 		if dep.Path == "" {
-			return nil, fmt.Errorf("dependency %s is missing required 'path' field", name)
+			problems.add(sectionName, "", "dependency %s is missing required 'path' field", sectionName)
+		}
+		if util.HasLatest(dep.Path) {
+			problems.add(sectionName, "path", "path of %s cannot contain {latest}; set version = {latest} and use ${version} in the path", sectionName)
+		}
+		if dep.LatestVersion() {
+			if err := validateLatestVersion(dep); err != nil {
+				problems.add(sectionName, "version", "dependency %s: %v", sectionName, err)
+			}
 		}
 		if dep.Repository == "" {
-			return nil, fmt.Errorf("dependency %s is missing 'repository' (not set in defaults or dependency)", name)
+			problems.add(sectionName, "", "dependency %s is missing 'repository' (not set in defaults or dependency)", sectionName)
 		}
 		if err := validateOutputDir(dep.OutputDir); err != nil {
-			return nil, fmt.Errorf("dependency %s has invalid output_dir: %w", name, err)
+			key := ""
+			if section.HasKey("output_dir") {
+				key = "output_dir"
+			}
+			problems.add(sectionName, key, "dependency %s has invalid output_dir: %v", sectionName, err)
 		}
+
+		manifest.Dependencies[sectionName] = dep
 	}
 
+	if len(problems.Problems) > 0 {
+		return nil, problems
+	}
 	return manifest, nil
 }
 
 func WriteDepsIni(filename string, manifest *DepsManifest) error {
 	cfg := ini.Empty()
 
-	if manifest.Defaults.Repository != "" || manifest.Defaults.Checksum != "" || manifest.Defaults.OutputDir != "" || manifest.Defaults.URL != "" {
+	if manifest.Defaults.Repository != "" || manifest.Defaults.Checksum != "" || manifest.Defaults.OutputDir != "" || manifest.Defaults.URL != "" || manifest.Defaults.CACert != "" {
 		defaultsSection, _ := cfg.NewSection("defaults")
 		if manifest.Defaults.URL != "" {
 			defaultsSection.NewKey("url", manifest.Defaults.URL)
 		}
+		if manifest.Defaults.CACert != "" {
+			defaultsSection.NewKey("ca_cert", manifest.Defaults.CACert)
+		}
 		if manifest.Defaults.Repository != "" {
 			defaultsSection.NewKey("repository", manifest.Defaults.Repository)
 		}
@@ -162,6 +257,15 @@ func WriteDepsIni(filename string, manifest *DepsManifest) error {
 		if manifest.Defaults.OutputDir != "" {
 			defaultsSection.NewKey("output_dir", manifest.Defaults.OutputDir)
 		}
+		if manifest.Defaults.Concurrency > 0 {
+			defaultsSection.NewKey("concurrency", strconv.Itoa(manifest.Defaults.Concurrency))
+		}
+		if manifest.Defaults.MaxRate > 0 {
+			defaultsSection.NewKey("max_rate", strconv.FormatInt(manifest.Defaults.MaxRate, 10))
+		}
+		if manifest.Defaults.MaxSize > 0 {
+			defaultsSection.NewKey("max_size", strconv.FormatInt(manifest.Defaults.MaxSize, 10))
+		}
 	}
 
 	for name, dep := range manifest.Dependencies {
@@ -173,6 +277,9 @@ func WriteDepsIni(filename string, manifest *DepsManifest) error {
 		if dep.URL != manifest.Defaults.URL && dep.URL != "" {
 			depSection.NewKey("url", dep.URL)
 		}
+		if dep.CACert != manifest.Defaults.CACert && dep.CACert != "" {
+			depSection.NewKey("ca_cert", dep.CACert)
+		}
 		if dep.Repository != manifest.Defaults.Repository && dep.Repository != "" {
 			depSection.NewKey("repository", dep.Repository)
 		}
@@ -185,9 +292,18 @@ func WriteDepsIni(filename string, manifest *DepsManifest) error {
 		if dep.Dest != "" {
 			depSection.NewKey("dest", dep.Dest)
 		}
+		if dep.File {
+			depSection.NewKey("file", "true")
+		}
 		if dep.Recursive {
 			depSection.NewKey("recursive", "true")
 		}
+		if dep.Concurrency != manifest.Defaults.Concurrency && dep.Concurrency > 0 {
+			depSection.NewKey("concurrency", strconv.Itoa(dep.Concurrency))
+		}
+		if dep.MaxRate != manifest.Defaults.MaxRate && dep.MaxRate > 0 {
+			depSection.NewKey("max_rate", strconv.FormatInt(dep.MaxRate, 10))
+		}
 	}
 
 	if err := cfg.SaveTo(filename); err != nil {
@@ -196,3 +312,18 @@ func WriteDepsIni(filename string, manifest *DepsManifest) error {
 
 	return nil
 }
+
+// validateLatestVersion checks that the {latest} selector of dep is the whole version, names
+// a valid mode and has a ${version} in the path to select
+func validateLatestVersion(dep *Dependency) error {
+	if err := util.ValidateLatest(dep.Version); err != nil {
+		return err
+	}
+	if !strings.HasPrefix(dep.Version, "{latest") || strings.Count(dep.Version, "}") != 1 || !strings.HasSuffix(dep.Version, "}") {
+		return fmt.Errorf("version '%s' must be {latest} or {latest:semver|lexical|mtime}", dep.Version)
+	}
+	if !strings.Contains(dep.Path, "${version}") {
+		return fmt.Errorf("version %s needs ${version} in the path", dep.Version)
+	}
+	return nil
+}
@@ -1,50 +1,117 @@
 package deps
 
 import (
+	"errors"
 	"fmt"
 	"path"
 	"strings"
+	"time"
 
 	"github.com/tympanix/nexus-cli/internal/nexusapi"
+	"github.com/tympanix/nexus-cli/internal/util"
 )
 
-type ClientFactory func(url, username, password string) *nexusapi.Client
+// ClientFactory returns the client for the Nexus server of dep, connected with the
+// settings of that server
+type ClientFactory func(dep *Dependency) (*nexusapi.Client, error)
 
 type Resolver struct {
 	clientFactory ClientFactory
-	username      string
-	password      string
-	defaultURL    string
 }
 
+// NewResolver resolves dependencies with client. Dependencies on another server get a
+// plain client of their own, without the credentials or transport of client; use
+// NewResolverWithClients to connect to them with settings of their own.
 func NewResolver(client *nexusapi.Client) *Resolver {
-	return &Resolver{
-		clientFactory: nexusapi.NewClient,
-		username:      client.Username,
-		password:      client.Password,
-		defaultURL:    client.BaseURL,
+	return NewResolverWithClients(func(dep *Dependency) (*nexusapi.Client, error) {
+		if dep.URL == "" || dep.URL == client.BaseURL {
+			return client, nil
+		}
+		return nexusapi.NewClient(dep.URL, "", ""), nil
+	})
+}
+
+// NewResolverWithClients resolves each dependency with the client clients returns for it
+func NewResolverWithClients(clients ClientFactory) *Resolver {
+	return &Resolver{clientFactory: clients}
+}
+
+// ResolveVersion returns the version the {latest} selector of dep picks among the names
+// found in Nexus where ${version} stands in its path, e.g. the folders of libfoo/ for
+// libfoo/${version}/. The selector defaults to semver.
+func (r *Resolver) ResolveVersion(dep *Dependency) (string, error) {
+	segments := strings.Split(strings.Trim(dep.Path, "/"), "/")
+	var pattern string
+	for i, segment := range segments {
+		if strings.Contains(segment, "${version}") {
+			pattern = strings.Join(append(segments[:i:i], expandVariables(segment, dep.Version)), "/")
+			break
+		}
+	}
+	if pattern == "" {
+		return "", fmt.Errorf("path %s of dependency %s has no ${version} for %s to select", dep.Path, dep.Name, dep.Version)
+	}
+
+	client, err := r.clientFactory(dep)
+	if err != nil {
+		return "", err
+	}
+	_, selections, err := util.ResolveLatest(pattern, util.SelectSemver, func(parent string) ([]util.LatestAsset, error) {
+		assets, err := client.ListAssets(dep.Repository, parent, true)
+		if err != nil {
+			return nil, fmt.Errorf("failed to search assets for %s: %w", dep.Name, err)
+		}
+		latestAssets := make([]util.LatestAsset, len(assets))
+		for i, asset := range assets {
+			modified, _ := time.Parse(time.RFC3339, asset.LastModified)
+			latestAssets[i] = util.LatestAsset{Path: asset.Path, Modified: modified}
+		}
+		return latestAssets, nil
+	})
+	if errors.Is(err, util.ErrNoSemver) {
+		return "", fmt.Errorf("cannot resolve version of %s: %w; use {latest:lexical} or {latest:mtime}", dep.Name, err)
+	}
+	if err != nil {
+		return "", fmt.Errorf("cannot resolve version of %s: %w", dep.Name, err)
 	}
+	return selections[0].Value, nil
 }
 
 func (r *Resolver) ResolveDependency(dep *Dependency) (map[string]string, error) {
 	files := make(map[string]string)
 
-	url := dep.URL
-	if url == "" {
-		url = r.defaultURL
+	client, err := r.clientFactory(dep)
+	if err != nil {
+		return nil, err
 	}
 
-	client := r.clientFactory(url, r.username, r.password)
-
 	expandedPath := dep.ExpandedPath()
 
 	pathPrefix := path.Clean(expandedPath)
-	assets, err := client.ListAssets(dep.Repository, pathPrefix, dep.Recursive)
+	var assets []nexusapi.Asset
+	if dep.File {
+		var asset *nexusapi.Asset
+		asset, err = client.GetAssetByPath(dep.Repository, pathPrefix)
+		if errors.Is(err, nexusapi.ErrAssetNotFound) {
+			return nil, r.notFound(client, dep, pathPrefix)
+		}
+		if asset != nil {
+			assets = []nexusapi.Asset{*asset}
+		}
+	} else {
+		// Attribute sidecars and metadata manifests describe the other files and are not
+		// locked themselves
+		assets, err = client.ListAssets(dep.Repository, pathPrefix, dep.Recursive)
+		assets = nexusapi.WithoutMetadataManifests(nexusapi.WithoutAttributeSidecars(assets))
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to search assets for %s: %w", dep.Name, err)
 	}
 
 	if len(assets) == 0 {
+		if !dep.Recursive {
+			return nil, r.notFound(client, dep, pathPrefix)
+		}
 		return nil, fmt.Errorf("no assets found for dependency %s at path %s", dep.Name, expandedPath)
 	}
 
@@ -52,17 +119,55 @@ func (r *Resolver) ResolveDependency(dep *Dependency) (map[string]string, error)
 		return nil, fmt.Errorf("expected one asset for dependency %s at path %s, but found %d", dep.Name, expandedPath, len(assets))
 	}
 
+	algorithms := dep.ChecksumAlgorithms()
+	if len(algorithms) == 0 {
+		return nil, fmt.Errorf("no checksum algorithm set for dependency %s", dep.Name)
+	}
 	for _, asset := range assets {
-		checksum := r.getChecksumForAlgorithm(asset.Checksum, dep.Checksum)
-		if checksum == "" {
-			return nil, fmt.Errorf("no %s checksum available for asset %s", dep.Checksum, asset.Path)
+		// Older Nexus versions do not report the size of assets
+		size := asset.FileSize
+		if size <= 0 {
+			size = -1
+		}
+		var checksums []LockChecksum
+		for _, alg := range algorithms {
+			checksum := r.getChecksumForAlgorithm(asset.Checksum, alg)
+			if checksum == "" {
+				return nil, fmt.Errorf("no %s checksum available for asset %s", alg, asset.Path)
+			}
+			checksums = append(checksums, LockChecksum{Algorithm: alg, Value: checksum, Size: size})
 		}
 		normalizedPath := strings.TrimPrefix(asset.Path, "/")
-		files[normalizedPath] = fmt.Sprintf("%s:%s", dep.Checksum, checksum)
+		files[normalizedPath] = FormatLockChecksums(checksums)
 	}
 	return files, nil
 }
 
+// MissingError reports the files of a dependency in deps-lock.ini that were deleted from
+// Nexus after the lock file was written
+type MissingError struct {
+	Dependency string
+	Files      []string // Locked paths, sorted
+}
+
+func (e *MissingError) Error() string {
+	if len(e.Files) == 1 {
+		return fmt.Sprintf("locked file no longer exists upstream: %s; re-run deps lock", e.Files[0])
+	}
+	return fmt.Sprintf("locked files no longer exist upstream: %s; re-run deps lock", strings.Join(e.Files, ", "))
+}
+
+// notFound returns the error for a single asset of dep that does not exist at assetPath,
+// telling a missing asset from a folder
+func (r *Resolver) notFound(client *nexusapi.Client, dep *Dependency, assetPath string) error {
+	assets, err := client.ListAssets(dep.Repository, assetPath, true)
+	assetPath = strings.TrimPrefix(assetPath, "/")
+	if err == nil && len(assets) > 0 {
+		return fmt.Errorf("path %s of dependency %s is a folder; set recursive = true", assetPath, dep.Name)
+	}
+	return fmt.Errorf("asset %s not found in repository %s for dependency %s", assetPath, dep.Repository, dep.Name)
+}
+
 func (r *Resolver) getChecksumForAlgorithm(checksum nexusapi.Checksum, algorithm string) string {
 	switch strings.ToLower(algorithm) {
 	case "sha1":
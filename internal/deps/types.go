@@ -4,25 +4,41 @@ import (
 	"fmt"
 	"path/filepath"
 	"strings"
+
+	"github.com/tympanix/nexus-cli/internal/util"
 )
 
 type Defaults struct {
-	Repository string
-	Checksum   string
-	OutputDir  string
-	URL        string
+	Repository  string
+	Checksum    string
+	OutputDir   string
+	URL         string
+	CACert      string // PEM file with CA certificates to trust for the server
+	Concurrency int
+	MaxRate     int64
+	MaxSize     int64 // Maximum total size of all dependencies in bytes (0 = unlimited); not inherited by dependencies
 }
 
 type Dependency struct {
-	Name       string
-	Repository string
-	Path       string
-	Version    string
-	Checksum   string
-	OutputDir  string
-	Dest       string
-	Recursive  bool
-	URL        string
+	Name        string
+	Repository  string
+	Path        string
+	Version     string
+	Checksum    string
+	OutputDir   string
+	Dest        string
+	Recursive   bool
+	File        bool // The path is one exact asset, looked up by its path rather than listed
+	URL         string
+	CACert      string // PEM file with CA certificates to trust for the server, in addition to the system ones
+	Concurrency int    // Maximum number of parallel file downloads (0 = unlimited)
+	MaxRate     int64  // Maximum download rate in bytes per second (0 = unlimited)
+}
+
+// LatestVersion reports whether the version of d is a {latest} selector, e.g.
+// {latest:semver}, resolved by deps lock and pinned in deps-lock.ini
+func (d *Dependency) LatestVersion() bool {
+	return util.HasLatest(d.Version)
 }
 
 func (d *Dependency) ExpandedPath() string {
@@ -37,6 +53,18 @@ func (d *Dependency) LocalPath() string {
 	return filepath.Join(d.OutputDir, expanded)
 }
 
+// ChecksumAlgorithms returns the algorithms of the checksum setting, which may list
+// several separated by commas, e.g. "sha256,sha512". The first one is used for downloads.
+func (d *Dependency) ChecksumAlgorithms() []string {
+	var algorithms []string
+	for _, alg := range strings.Split(d.Checksum, ",") {
+		if alg = strings.ToLower(strings.TrimSpace(alg)); alg != "" {
+			algorithms = append(algorithms, alg)
+		}
+	}
+	return algorithms
+}
+
 func (d *Dependency) NexusPath() string {
 	return d.ExpandedPath()
 }
@@ -44,10 +72,32 @@ func (d *Dependency) NexusPath() string {
 type DepsManifest struct {
 	Defaults     Defaults
 	Dependencies map[string]*Dependency
+	File         string // The deps.ini the manifest was parsed from, if any
 }
 
+// CACertPath returns the ca_cert of dep. A relative path is relative to the directory of
+// the manifest file, not to the current directory.
+func (m *DepsManifest) CACertPath(dep *Dependency) string {
+	if dep.CACert == "" || filepath.IsAbs(dep.CACert) || m.File == "" {
+		return dep.CACert
+	}
+	return filepath.Join(filepath.Dir(m.File), dep.CACert)
+}
+
+// LockFile maps each dependency to its files and their locked checksums. A locked
+// checksum is a list of "algorithm:hex" entries separated by spaces or commas.
+// Versions holds the version resolved for each dependency whose version is a {latest}
+// selector.
 type LockFile struct {
 	Dependencies map[string]map[string]string
+	Versions     map[string]string
+}
+
+// LockChecksum is one "algorithm:hex:size" entry of a locked checksum
+type LockChecksum struct {
+	Algorithm string
+	Value     string
+	Size      int64 // Size of the file in bytes, -1 if it is not recorded
 }
 
 type EnvExport struct {
@@ -0,0 +1,106 @@
+// Package exitcode is the registry of the exit codes of nexuscli-go. The codes are a
+// contract for scripts: a code keeps its meaning across releases and is never reused, so
+// new outcomes get new codes. Every command exits through these constants.
+package exitcode
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// The exit codes. They are untyped so they can be passed to os.Exit and converted to the
+// status types of the operations.
+const (
+	Success     = 0
+	Error       = 1
+	TooFewFiles = 65 // Some, but fewer than --min-files, files matched
+	NoFiles     = 66
+	Missing     = 67 // Files locked in deps-lock.ini were deleted from Nexus
+)
+
+// Entry describes one exit code
+type Entry struct {
+	Code        int      `json:"code"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Commands    []string `json:"commands,omitempty"` // Commands that can exit with the code, all if empty
+}
+
+// registry holds every exit code in ascending order
+var registry = []Entry{
+	{Code: Success, Name: "success", Description: "Success"},
+	{Code: Error, Name: "error", Description: "General error"},
+	{Code: TooFewFiles, Name: "too-few-files", Description: "Fewer files than --min-files found", Commands: []string{"download", "deps sync"}},
+	{Code: NoFiles, Name: "no-files", Description: "No files found", Commands: []string{"download", "deps sync"}},
+	{Code: Missing, Name: "missing-upstream", Description: "Locked files no longer exist upstream", Commands: []string{"deps sync"}},
+}
+
+// Entries returns all exit codes in ascending order
+func Entries() []Entry {
+	entries := make([]Entry, len(registry))
+	copy(entries, registry)
+	return entries
+}
+
+// Lookup returns the entry of code
+func Lookup(code int) (Entry, bool) {
+	for _, entry := range registry {
+		if entry.Code == code {
+			return entry, true
+		}
+	}
+	return Entry{}, false
+}
+
+// appliesTo reports whether a command can exit with the code of e
+func (e Entry) appliesTo(command string) bool {
+	if len(e.Commands) == 0 {
+		return true
+	}
+	for _, c := range e.Commands {
+		if c == command {
+			return true
+		}
+	}
+	return false
+}
+
+// Help returns the "Exit codes:" section of the help of command, e.g. "download" or
+// "deps sync". For the root command, command is empty and every code is listed along with
+// the commands it is limited to.
+func Help(command string) string {
+	var entries []Entry
+	width := 0
+	for _, entry := range registry {
+		if command == "" || entry.appliesTo(command) {
+			entries = append(entries, entry)
+			width = max(width, len(strconv.Itoa(entry.Code)))
+		}
+	}
+	var sb strings.Builder
+	sb.WriteString("Exit codes:")
+	for _, entry := range entries {
+		fmt.Fprintf(&sb, "\n  %-*d - %s", width, entry.Code, entry.Description)
+		if command == "" && len(entry.Commands) > 0 {
+			fmt.Fprintf(&sb, " (%s only)", strings.Join(entry.Commands, ", "))
+		}
+	}
+	return sb.String()
+}
+
+// WriteTable writes all exit codes as a table with the commands of each
+func WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "CODE\tNAME\tCOMMANDS\tDESCRIPTION")
+	for _, entry := range registry {
+		commands := "all"
+		if len(entry.Commands) > 0 {
+			commands = strings.Join(entry.Commands, ", ")
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", entry.Code, entry.Name, commands, entry.Description)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,39 @@
+package exitcode
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	names := make(map[string]bool)
+	for i, entry := range Entries() {
+		if i > 0 && entry.Code <= registry[i-1].Code {
+			t.Errorf("Expected the codes in ascending order, got %d after %d", entry.Code, registry[i-1].Code)
+		}
+		if names[entry.Name] {
+			t.Errorf("Duplicate name %s", entry.Name)
+		}
+		names[entry.Name] = true
+		if found, ok := Lookup(entry.Code); !ok || found.Name != entry.Name {
+			t.Errorf("Lookup(%d) = %+v, %v", entry.Code, found, ok)
+		}
+	}
+	if _, ok := Lookup(2); ok {
+		t.Error("Expected exit code 2 not to be registered")
+	}
+}
+
+func TestHelp(t *testing.T) {
+	expected := "Exit codes:\n  0 - Success\n  1 - General error"
+	if help := Help("upload"); help != expected {
+		t.Errorf("Help(upload) = %q, expected %q", help, expected)
+	}
+	expected = "Exit codes:\n  0  - Success\n  1  - General error\n  65 - Fewer files than --min-files found\n  66 - No files found"
+	if help := Help("download"); help != expected {
+		t.Errorf("Help(download) = %q, expected %q", help, expected)
+	}
+	if help := Help(""); !strings.Contains(help, "  66 - No files found (download, deps sync only)") {
+		t.Errorf("Expected the root help to name the commands of a code, got %q", help)
+	}
+}
@@ -0,0 +1,265 @@
+// Package metrics records counters and gauges of a run, e.g. a nightly mirror or deps
+// sync, and writes them in the Prometheus text exposition format, to a file for the
+// node_exporter textfile collector or on a /metrics endpoint while the run lasts.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Type is the Prometheus type of a metric
+type Type string
+
+const (
+	Counter Type = "counter"
+	Gauge   Type = "gauge"
+)
+
+// Metric describes one metric. Every series also has the label "command", the command
+// of the run, e.g. "download" or "deps sync".
+type Metric struct {
+	Name   string
+	Help   string
+	Type   Type
+	Labels []string // Names of the labels besides "command"
+}
+
+// The metrics of a run. Add new metrics here and to All.
+var (
+	TransferredBytes = Metric{"nexuscli_transferred_bytes_total", "Bytes of file content uploaded, downloaded or copied.", Counter, nil}
+	Files            = Metric{"nexuscli_files_total", "Files processed, by outcome.", Counter, []string{"outcome"}}
+	Retries          = Metric{"nexuscli_retries_total", "Requests retried after a failure, by kind of request.", Counter, []string{"request"}}
+	Throttled        = Metric{"nexuscli_throttled_total", "Requests Nexus rate limited with 429 Too Many Requests, by kind of request.", Counter, []string{"request"}}
+	PhaseDuration    = Metric{"nexuscli_phase_duration_seconds", "Time spent in each phase of the run.", Gauge, []string{"phase"}}
+	Duration         = Metric{"nexuscli_duration_seconds", "Time from the start of the run until it ended, or until now while it runs.", Gauge, nil}
+	Success          = Metric{"nexuscli_success", "1 if the run succeeded, 0 if it failed; not set while it runs.", Gauge, nil}
+	EndTime          = Metric{"nexuscli_end_time_seconds", "Unix time the run ended.", Gauge, nil}
+)
+
+// All lists the metrics in the order they are written
+var All = []Metric{TransferredBytes, Files, Retries, Throttled, PhaseDuration, Duration, Success, EndTime}
+
+// Values of the outcome label of Files
+const (
+	OutcomeSucceeded = "succeeded"
+	OutcomeFailed    = "failed"
+	OutcomeSkipped   = "skipped" // Up to date or identical, so not transferred
+)
+
+// Values of the request label of Retries and Throttled
+const (
+	RequestSearch   = "search" // A page of a search, e.g. retried after a transient error
+	RequestUpload   = "upload"
+	RequestDownload = "download"
+	RequestAPI      = "api" // Any other REST API request
+)
+
+// Values of the phase label of PhaseDuration
+const (
+	PhaseList     = "list"     // Listing assets in Nexus and files on disk
+	PhaseTransfer = "transfer" // Uploading, downloading or copying files
+	PhaseDelete   = "delete"   // Deleting extra assets or files
+)
+
+// Registry holds the values of the metrics of one run. It is safe for concurrent use,
+// and all its methods do nothing on a nil Registry, so call sites need not check
+// whether metrics are enabled.
+type Registry struct {
+	mu      sync.Mutex
+	command string
+	start   time.Time
+	values  map[string]map[string]float64 // By metric name, then by label values joined with "\xff"
+}
+
+// New returns an empty registry for a run of command that starts now. The outcomes of
+// Files start at zero, so every outcome is reported even if it never happened.
+func New(command string) *Registry {
+	r := &Registry{command: command, start: time.Now(), values: make(map[string]map[string]float64)}
+	r.Add(TransferredBytes, 0)
+	for _, outcome := range []string{OutcomeSucceeded, OutcomeFailed, OutcomeSkipped} {
+		r.Add(Files, 0, outcome)
+	}
+	return r
+}
+
+var (
+	activeMu sync.Mutex
+	active   *Registry
+)
+
+// Enable makes r the registry that Active returns
+func Enable(r *Registry) {
+	activeMu.Lock()
+	defer activeMu.Unlock()
+	active = r
+}
+
+// Active returns the registry of the current run, or nil if metrics are not enabled
+func Active() *Registry {
+	activeMu.Lock()
+	defer activeMu.Unlock()
+	return active
+}
+
+// Add adds value to the series of m with the given label values
+func (r *Registry) Add(m Metric, value float64, labels ...string) {
+	r.update(m, labels, func(v float64) float64 { return v + value })
+}
+
+// Set sets the series of m with the given label values to value
+func (r *Registry) Set(m Metric, value float64, labels ...string) {
+	r.update(m, labels, func(float64) float64 { return value })
+}
+
+func (r *Registry) update(m Metric, labels []string, f func(float64) float64) {
+	if r == nil {
+		return
+	}
+	if len(labels) != len(m.Labels) {
+		panic(fmt.Sprintf("metric %s takes %d label(s), got %d", m.Name, len(m.Labels), len(labels)))
+	}
+	key := strings.Join(labels, "\xff")
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	series, ok := r.values[m.Name]
+	if !ok {
+		series = make(map[string]float64)
+		r.values[m.Name] = series
+	}
+	series[key] = f(series[key])
+}
+
+// Phase starts timing phase and returns the function that stops it. The time is added
+// to PhaseDuration, so a phase may run several times, e.g. once per dependency.
+func (r *Registry) Phase(phase string) func() {
+	if r == nil {
+		return func() {}
+	}
+	start := time.Now()
+	return func() {
+		r.Add(PhaseDuration, time.Since(start).Seconds(), phase)
+	}
+}
+
+// Finish records the outcome of the run, its duration and end time
+func (r *Registry) Finish(success bool) {
+	if r == nil {
+		return
+	}
+	now := time.Now()
+	r.Set(Duration, now.Sub(r.start).Seconds())
+	r.Set(Success, boolValue(success))
+	r.Set(EndTime, float64(now.UnixNano())/1e9)
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// Write writes the metrics in the Prometheus text exposition format. Metrics without a
+// value are left out. Before Finish the duration is the time the run has taken so far.
+func (r *Registry) Write(w io.Writer) error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, finished := r.values[Duration.Name]; !finished {
+		defer delete(r.values, Duration.Name)
+		r.values[Duration.Name] = map[string]float64{"": time.Since(r.start).Seconds()}
+	}
+
+	var b strings.Builder
+	for _, m := range All {
+		series, ok := r.values[m.Name]
+		if !ok {
+			continue
+		}
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", m.Name, m.Help, m.Name, m.Type)
+		keys := make([]string, 0, len(series))
+		for key := range series {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			names := append([]string{"command"}, m.Labels...)
+			values := []string{r.command}
+			if len(m.Labels) > 0 {
+				values = append(values, strings.Split(key, "\xff")...)
+			}
+			pairs := make([]string, len(names))
+			for i, name := range names {
+				pairs[i] = fmt.Sprintf("%s=\"%s\"", name, escapeLabelValue(values[i]))
+			}
+			fmt.Fprintf(&b, "%s{%s} %s\n", m.Name, strings.Join(pairs, ","), strconv.FormatFloat(series[key], 'g', -1, 64))
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// escapeLabelValue escapes a backslash, double quote and newline in a label value
+func escapeLabelValue(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+// WriteFile writes the metrics to path. It is written under a temporary name and renamed,
+// so a collector reading the file never sees it half written.
+func (r *Registry) WriteFile(path string) error {
+	if r == nil {
+		return nil
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := r.Write(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// ServeHTTP serves the metrics as they are now
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	r.Write(w)
+}
+
+// Listen serves the metrics of r at /metrics on addr, e.g. ":9100", until the returned
+// function is called
+func (r *Registry) Listen(addr string) (func(), error) {
+	if r == nil {
+		return func() {}, nil
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for metrics on %s: %w", addr, err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", r)
+	server := &http.Server{Handler: mux}
+	go server.Serve(listener)
+	return func() { server.Close() }, nil
+}
@@ -0,0 +1,244 @@
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// sample is one parsed sample line of the text exposition format
+type sample struct {
+	name   string
+	labels map[string]string
+	value  float64
+}
+
+var (
+	sampleLine = regexp.MustCompile(`^([a-zA-Z_:][a-zA-Z0-9_:]*)\{(.*)\} (\S+)$`)
+	labelPair  = regexp.MustCompile(`^([a-zA-Z_][a-zA-Z0-9_]*)="((?:[^"\\]|\\.)*)"(?:,|$)`)
+)
+
+// parseExposition parses the Prometheus text exposition format as written by Write. It
+// fails on any line that is not a HELP or TYPE comment or a well-formed sample, and on a
+// sample of a metric without a TYPE line before it.
+func parseExposition(t *testing.T, r io.Reader) (map[string]Type, []sample) {
+	t.Helper()
+	types := make(map[string]Type)
+	var samples []sample
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if rest, ok := strings.CutPrefix(line, "# HELP "); ok {
+			if name, help, _ := strings.Cut(rest, " "); name == "" || help == "" {
+				t.Fatalf("Malformed HELP line %q", line)
+			}
+			continue
+		}
+		if rest, ok := strings.CutPrefix(line, "# TYPE "); ok {
+			name, typ, _ := strings.Cut(rest, " ")
+			if _, ok := types[name]; ok || (Type(typ) != Counter && Type(typ) != Gauge) {
+				t.Fatalf("Malformed or repeated TYPE line %q", line)
+			}
+			types[name] = Type(typ)
+			continue
+		}
+		match := sampleLine.FindStringSubmatch(line)
+		if match == nil {
+			t.Fatalf("Malformed sample line %q", line)
+		}
+		if _, ok := types[match[1]]; !ok {
+			t.Fatalf("Sample of %s before its TYPE line", match[1])
+		}
+		s := sample{name: match[1], labels: make(map[string]string)}
+		for rest := match[2]; rest != ""; {
+			pair := labelPair.FindStringSubmatch(rest)
+			if pair == nil {
+				t.Fatalf("Malformed labels in %q", line)
+			}
+			value, err := strconv.Unquote(`"` + pair[2] + `"`)
+			if err != nil {
+				t.Fatalf("Malformed label value in %q: %v", line, err)
+			}
+			s.labels[pair[1]] = value
+			rest = rest[len(pair[0]):]
+		}
+		var err error
+		if s.value, err = strconv.ParseFloat(match[3], 64); err != nil {
+			t.Fatalf("Malformed value in %q: %v", line, err)
+		}
+		samples = append(samples, s)
+	}
+	return types, samples
+}
+
+func find(samples []sample, m Metric, labels ...string) (float64, bool) {
+	for _, s := range samples {
+		if s.name != m.Name {
+			continue
+		}
+		matches := true
+		for i, name := range m.Labels {
+			matches = matches && s.labels[name] == labels[i]
+		}
+		if matches {
+			return s.value, true
+		}
+	}
+	return 0, false
+}
+
+// TestDefinitions checks that every metric has a valid, unique name, a help text, and the
+// suffix its type calls for
+func TestDefinitions(t *testing.T) {
+	names := make(map[string]bool)
+	for _, m := range All {
+		if !regexp.MustCompile(`^nexuscli_[a-z_]+$`).MatchString(m.Name) || names[m.Name] {
+			t.Errorf("Invalid or repeated metric name %q", m.Name)
+		}
+		names[m.Name] = true
+		if m.Help == "" {
+			t.Errorf("Metric %s has no help text", m.Name)
+		}
+		if (m.Type == Counter) != strings.HasSuffix(m.Name, "_total") {
+			t.Errorf("Metric %s: only counters end in _total", m.Name)
+		}
+		for _, label := range m.Labels {
+			if label == "command" {
+				t.Errorf("Metric %s repeats the command label", m.Name)
+			}
+		}
+	}
+}
+
+func TestRegistryWrite(t *testing.T) {
+	r := New(`deps "sync"`)
+	r.Add(Files, 2, OutcomeSucceeded)
+	r.Add(Files, 1, OutcomeFailed)
+	r.Add(TransferredBytes, 1536)
+	r.Add(Retries, 1, RequestSearch)
+	r.Add(Throttled, 2, RequestDownload)
+	r.Add(PhaseDuration, 1.5, PhaseList)
+	r.Add(PhaseDuration, 0.5, PhaseList)
+
+	var running strings.Builder
+	if err := r.Write(&running); err != nil {
+		t.Fatal(err)
+	}
+	_, samples := parseExposition(t, strings.NewReader(running.String()))
+	if _, ok := find(samples, Duration); !ok {
+		t.Error("Expected the duration so far while the run lasts")
+	}
+	if _, ok := find(samples, Success); ok {
+		t.Error("Expected no outcome while the run lasts")
+	}
+
+	r.Finish(false)
+	var b strings.Builder
+	if err := r.Write(&b); err != nil {
+		t.Fatal(err)
+	}
+	types, samples := parseExposition(t, strings.NewReader(b.String()))
+	for _, m := range All {
+		if types[m.Name] != m.Type {
+			t.Errorf("Expected %s to be a %s, got %q", m.Name, m.Type, types[m.Name])
+		}
+	}
+	for _, s := range samples {
+		if s.labels["command"] != `deps "sync"` {
+			t.Errorf("Expected the command label on %s, got %v", s.name, s.labels)
+		}
+	}
+	for _, want := range []struct {
+		metric Metric
+		labels []string
+		value  float64
+	}{
+		{Files, []string{OutcomeSucceeded}, 2},
+		{Files, []string{OutcomeFailed}, 1},
+		{Files, []string{OutcomeSkipped}, 0},
+		{TransferredBytes, nil, 1536},
+		{Retries, []string{RequestSearch}, 1},
+		{Throttled, []string{RequestDownload}, 2},
+		{PhaseDuration, []string{PhaseList}, 2},
+		{Success, nil, 0},
+	} {
+		if got, ok := find(samples, want.metric, want.labels...); !ok || got != want.value {
+			t.Errorf("Expected %s%v = %v, got %v (found: %v)", want.metric.Name, want.labels, want.value, got, ok)
+		}
+	}
+	if end, _ := find(samples, EndTime); end <= 0 {
+		t.Errorf("Expected the end time, got %v", end)
+	}
+}
+
+func TestNilRegistry(t *testing.T) {
+	var r *Registry
+	r.Add(Files, 1, OutcomeSucceeded)
+	r.Phase(PhaseList)()
+	r.Finish(true)
+	if err := r.WriteFile(filepath.Join(t.TempDir(), "metrics.prom")); err != nil {
+		t.Errorf("Expected a nil registry to do nothing, got %v", err)
+	}
+	var b strings.Builder
+	if err := r.Write(&b); err != nil || b.Len() != 0 {
+		t.Errorf("Expected a nil registry to write nothing, got %q, %v", b.String(), err)
+	}
+	recorder := httptest.NewRecorder()
+	r.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	if recorder.Code != 200 || recorder.Body.Len() != 0 {
+		t.Errorf("Expected a nil registry to serve no metrics, got %d %q", recorder.Code, recorder.Body.String())
+	}
+	stop, err := r.Listen("127.0.0.1:0")
+	if err != nil {
+		t.Errorf("Expected a nil registry not to listen, got %v", err)
+	}
+	stop()
+}
+
+func TestRegistryWriteFile(t *testing.T) {
+	r := New("mirror")
+	r.Add(Files, 3, OutcomeSucceeded)
+	r.Finish(true)
+
+	path := filepath.Join(t.TempDir(), "nexuscli.prom")
+	if err := r.WriteFile(path); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	_, samples := parseExposition(t, f)
+	if got, _ := find(samples, Files, OutcomeSucceeded); got != 3 {
+		t.Errorf("Expected 3 succeeded files in the file, got %v", got)
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+		t.Errorf("Expected no temporary file to be left behind, got %v", entries)
+	}
+}
+
+func TestRegistryServeHTTP(t *testing.T) {
+	r := New("download")
+	r.Add(Files, 1, OutcomeSkipped)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Errorf("Unexpected content type %q", rec.Header().Get("Content-Type"))
+	}
+	_, samples := parseExposition(t, rec.Body)
+	if got, _ := find(samples, Files, OutcomeSkipped); got != 1 {
+		t.Errorf("Expected 1 skipped file, got %v", got)
+	}
+
+	if _, err := r.Listen("256.0.0.1:http"); err == nil {
+		t.Error("Expected an error for an address that cannot be listened on")
+	}
+}
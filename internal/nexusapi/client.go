@@ -1,16 +1,27 @@
 package nexusapi
 
 import (
+	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"mime/multipart"
+	"net"
 	"net/http"
+	"net/textproto"
 	"net/url"
 	"os"
 	pathpkg "path"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tympanix/nexus-cli/internal/metrics"
 )
 
 // Client represents a Nexus API client
@@ -19,15 +30,137 @@ type Client struct {
 	Username   string
 	Password   string
 	HTTPClient *http.Client
+	Stats      APIStats // Size of the JSON responses received, compressed and decoded
+
+	unthrottled bool        // Send every request once, without pacing or retrying 429s
+	token       string      // Bearer token sent instead of the username and password
+	headers     http.Header // Sent with every request
+
+	groupsMu sync.Mutex
+	groups   map[string][]string // Members found by GetGroupMembers, nil for a repository that is not a group
+
+	noServerCopy atomic.Bool // The server answered that it cannot copy assets itself
+}
+
+// ClientOption configures a Client created by NewClient
+type ClientOption func(*clientOptions)
+
+type clientOptions struct {
+	dial        func(ctx context.Context, network, addr string) (net.Conn, error)
+	tls         *tls.Config
+	unthrottled bool
+	token       string
+	headers     http.Header
+}
+
+// WithDialer makes the client open its connections with dial instead of connecting to the
+// host of the URL. The URL still sets the Host header, TLS server name and paths.
+func WithDialer(dial func(ctx context.Context, network, addr string) (net.Conn, error)) ClientOption {
+	return func(o *clientOptions) {
+		o.dial = dial
+	}
+}
+
+// WithUnixSocket sends all requests over the Unix domain socket at socketPath, e.g. a
+// local proxy tunnelling to Nexus. An empty path leaves the client unchanged.
+func WithUnixSocket(socketPath string) ClientOption {
+	if socketPath == "" {
+		return func(*clientOptions) {}
+	}
+	return WithDialer(func(ctx context.Context, _, _ string) (net.Conn, error) {
+		var dialer net.Dialer
+		return dialer.DialContext(ctx, "unix", socketPath)
+	})
+}
+
+// WithTLSConfig makes the client connect with tlsConfig, e.g. to trust a private CA. A nil
+// config leaves the client unchanged.
+func WithTLSConfig(tlsConfig *tls.Config) ClientOption {
+	return func(o *clientOptions) {
+		o.tls = tlsConfig
+	}
+}
+
+// WithoutThrottle makes the client send every request once: it is neither paced by the
+// throttle of its host nor retried after a 429, e.g. for lookups that must answer quickly
+func WithoutThrottle() ClientOption {
+	return func(o *clientOptions) {
+		o.unthrottled = true
+	}
+}
+
+// WithToken makes the client authenticate with the bearer token instead of the username
+// and password. An empty token leaves the client unchanged.
+func WithToken(token string) ClientOption {
+	return func(o *clientOptions) {
+		if token != "" {
+			o.token = token
+		}
+	}
+}
+
+// WithHeaders makes the client send headers with every request, e.g. for a proxy in
+// front of Nexus
+func WithHeaders(headers map[string]string) ClientOption {
+	return func(o *clientOptions) {
+		for name, value := range headers {
+			if o.headers == nil {
+				o.headers = make(http.Header)
+			}
+			o.headers.Set(name, value)
+		}
+	}
 }
 
-// NewClient creates a new Nexus API client
-func NewClient(baseURL, username, password string) *Client {
+// NewClient creates a new Nexus API client. Its requests are timed if
+// EnableRequestMetrics was called before.
+func NewClient(baseURL, username, password string, opts ...ClientOption) *Client {
+	var options clientOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	var transport http.RoundTripper = http.DefaultTransport
+	if options.dial != nil || options.tls != nil {
+		customTransport := http.DefaultTransport.(*http.Transport).Clone()
+		if options.dial != nil {
+			customTransport.DialContext = options.dial
+		}
+		if options.tls != nil {
+			customTransport.TLSClientConfig = options.tls
+		}
+		transport = customTransport
+	}
+	if metrics := enabledRequestMetrics(); metrics != nil {
+		transport = &metricsTransport{base: transport, metrics: metrics}
+	}
+	httpClient := http.DefaultClient
+	if transport != http.DefaultTransport {
+		httpClient = &http.Client{Transport: transport}
+	}
 	return &Client{
-		BaseURL:    baseURL,
-		Username:   username,
-		Password:   password,
-		HTTPClient: http.DefaultClient,
+		BaseURL:     baseURL,
+		Username:    username,
+		Password:    password,
+		HTTPClient:  httpClient,
+		unthrottled: options.unthrottled,
+		token:       options.token,
+		headers:     options.headers,
+	}
+}
+
+// authenticate adds the headers and credentials of the client to req: the bearer token if
+// there is one, or else the username and password. A client without any of them sends no
+// Authorization header.
+func (c *Client) authenticate(req *http.Request) {
+	for name, values := range c.headers {
+		req.Header[name] = values
+	}
+	switch {
+	case c.token != "":
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	case c.Username != "" || c.Password != "":
+		req.SetBasicAuth(c.Username, c.Password)
 	}
 }
 
@@ -68,49 +201,300 @@ type SearchResponse struct {
 type Repository struct {
 	Name   string `json:"name"`
 	Format string `json:"format"`
-	Type   string `json:"type"`
+	Type   string `json:"type"` // hosted, proxy or group
 	URL    string `json:"url"`
+	Online *bool  `json:"online,omitempty"` // nil if the server does not report it
+}
+
+// ReadOnly reports why Nexus rejects uploads to the repository based on its type and
+// online state, or "" if it may accept them. The write policy of a hosted repository is
+// only known from its settings, see CheckWritable.
+func (r *Repository) ReadOnly() string {
+	switch {
+	case r.Online != nil && !*r.Online:
+		return "offline"
+	case r.Type == "proxy" || r.Type == "group":
+		return fmt.Sprintf("read-only (%s repository)", r.Type)
+	}
+	return ""
+}
+
+// restURL returns the URL of a REST API endpoint of the server, below the context path of
+// BaseURL such as https://example.com/nexus. Each of names is escaped and appended to
+// endpoint as a path segment.
+func (c *Client) restURL(endpoint string, names ...string) (*url.URL, error) {
+	restURL, err := url.Parse(c.BaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Nexus URL: %w", err)
+	}
+	restURL.RawPath = strings.TrimRight(restURL.EscapedPath(), "/") + endpoint
+	restURL.Path = strings.TrimRight(restURL.Path, "/") + endpoint
+	for _, name := range names {
+		restURL.Path += "/" + name
+		restURL.RawPath += "/" + url.PathEscape(name)
+	}
+	return restURL, nil
 }
 
 // ListRepositories lists all repositories in Nexus
 func (c *Client) ListRepositories() ([]Repository, error) {
-	baseURL, err := url.Parse(c.BaseURL)
+	baseURL, err := c.restURL("/service/rest/v1/repositories")
 	if err != nil {
-		return nil, fmt.Errorf("invalid Nexus URL: %w", err)
+		return nil, err
 	}
-	baseURL.Path = "/service/rest/v1/repositories"
 
-	req, err := http.NewRequest("GET", baseURL.String(), nil)
+	req, err := c.newAPIRequest("GET", baseURL.String())
 	if err != nil {
 		return nil, err
 	}
-	req.SetBasicAuth(c.Username, c.Password)
-	resp, err := c.HTTPClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("failed to list repositories: %d", resp.StatusCode)
+		return nil, newHTTPError("list repositories", resp)
 	}
 	var repositories []Repository
-	if err := json.NewDecoder(resp.Body).Decode(&repositories); err != nil {
+	if err := c.decodeJSON(resp, &repositories); err != nil {
 		return nil, err
 	}
 	return repositories, nil
 }
 
+// GetRepository returns the repository with the given name, or nil if it does not exist
+func (c *Client) GetRepository(name string) (*Repository, error) {
+	baseURL, err := c.restURL("/service/rest/v1/repositories", name)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := c.newAPIRequest("GET", baseURL.String())
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == 404 {
+		return nil, nil
+	}
+	if resp.StatusCode != 200 {
+		return nil, newHTTPError(fmt.Sprintf("get repository '%s'", name), resp)
+	}
+	var repository Repository
+	if err := c.decodeJSON(resp, &repository); err != nil {
+		return nil, err
+	}
+	return &repository, nil
+}
+
+// RepositoryGroup holds the members of a group repository in resolution order
+type RepositoryGroup struct {
+	MemberNames []string `json:"memberNames"`
+}
+
+// RepositoryStorage holds the storage settings of a hosted repository
+type RepositoryStorage struct {
+	WritePolicy string `json:"writePolicy"` // ALLOW, ALLOW_ONCE or DENY
+}
+
+// RepositoryStatus describes whether a repository is online and, for group
+// repositories, which repositories it is composed of
+type RepositoryStatus struct {
+	Name    string             `json:"name"`
+	Format  string             `json:"format"`
+	Type    string             `json:"type"`
+	Online  bool               `json:"online"`
+	Group   *RepositoryGroup   `json:"group,omitempty"`
+	Storage *RepositoryStorage `json:"storage,omitempty"`
+}
+
+// ListRepositoryStatuses returns the settings of all repositories the user may read
+func (c *Client) ListRepositoryStatuses() ([]RepositoryStatus, error) {
+	baseURL, err := c.restURL("/service/rest/v1/repositorySettings")
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := c.newAPIRequest("GET", baseURL.String())
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, newHTTPError("get repository status", resp)
+	}
+	var statuses []RepositoryStatus
+	if err := c.decodeJSON(resp, &statuses); err != nil {
+		return nil, err
+	}
+	return statuses, nil
+}
+
+// GetRepositoryStatus returns the online status of a repository and its group members, if any
+func (c *Client) GetRepositoryStatus(name string) (*RepositoryStatus, error) {
+	statuses, err := c.ListRepositoryStatuses()
+	if err != nil {
+		return nil, err
+	}
+	if status := findStatus(statuses, name); status != nil {
+		return status, nil
+	}
+	return nil, fmt.Errorf("repository '%s' not found", name)
+}
+
+// GetGroupMembers returns the members of a group repository in resolution order, or nil
+// if the repository is not a group or does not exist. The type comes from the repository
+// metadata; the members come from the repository settings, which need more privileges.
+// The answer is kept for the lifetime of the client, so listing a repository many times
+// checks its type once.
+func (c *Client) GetGroupMembers(name string) ([]string, error) {
+	c.groupsMu.Lock()
+	members, ok := c.groups[name]
+	c.groupsMu.Unlock()
+	if ok {
+		return members, nil
+	}
+
+	repository, err := c.GetRepository(name)
+	if err != nil {
+		return nil, err
+	}
+	if repository != nil && repository.Type == "group" {
+		status, err := c.GetRepositoryStatus(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read members of group '%s': %w", name, err)
+		}
+		if status.Group != nil {
+			members = status.Group.MemberNames
+		}
+	}
+
+	c.groupsMu.Lock()
+	defer c.groupsMu.Unlock()
+	if c.groups == nil {
+		c.groups = make(map[string][]string)
+	}
+	c.groups[name] = members
+	return members, nil
+}
+
+// CheckWritable reports whether Nexus will accept uploads to a repository. It returns a
+// *NotWritableError if the repository is offline, denies writes or is a proxy or group
+// repository, naming a member that accepts uploads for a group, and any other error if the repository settings cannot be read. Reading the
+// settings needs more privileges than reading the repository itself, so without them a
+// proxy or group repository is still recognized from its type.
+func (c *Client) CheckWritable(name string) error {
+	statuses, err := c.ListRepositoryStatuses()
+	var status *RepositoryStatus
+	if err == nil {
+		status = findStatus(statuses, name)
+		if status == nil {
+			err = fmt.Errorf("repository '%s' not found", name)
+		}
+	}
+	if err != nil {
+		if repository, repoErr := c.GetRepository(name); repoErr == nil && repository != nil {
+			if reason := repository.ReadOnly(); reason != "" {
+				return &NotWritableError{Repository: name, Reason: reason}
+			}
+		}
+		return err
+	}
+	reason := status.readOnly()
+	if reason == "" {
+		return nil
+	}
+	notWritable := &NotWritableError{Repository: name, Reason: reason}
+	if status.Group != nil {
+		for _, member := range status.Group.MemberNames {
+			if memberStatus := findStatus(statuses, member); memberStatus != nil && memberStatus.readOnly() == "" {
+				notWritable.WritableMember = member
+				break
+			}
+		}
+	}
+	return notWritable
+}
+
+// readOnly reports why Nexus rejects uploads to the repository, or "" if it accepts them
+func (s *RepositoryStatus) readOnly() string {
+	switch {
+	case !s.Online:
+		return "offline"
+	case s.Type == "proxy" || s.Type == "group":
+		return fmt.Sprintf("read-only (%s repository)", s.Type)
+	case s.Storage != nil && strings.EqualFold(s.Storage.WritePolicy, "DENY"):
+		return "read-only (write policy DENY)"
+	}
+	return ""
+}
+
+// findStatus returns the status of the repository called name, or nil
+func findStatus(statuses []RepositoryStatus, name string) *RepositoryStatus {
+	for i := range statuses {
+		if statuses[i].Name == name {
+			return &statuses[i]
+		}
+	}
+	return nil
+}
+
+// StatusAvailable reports whether the server is available to serve read requests
+func (c *Client) StatusAvailable() (bool, error) {
+	return c.status("/service/rest/v1/status", "check status")
+}
+
+// StatusWritable reports whether the server is available to serve read and write
+// requests. It is false while Nexus is in read-only mode, e.g. during blob store maintenance.
+func (c *Client) StatusWritable() (bool, error) {
+	return c.status("/service/rest/v1/status/writable", "check writable status")
+}
+
+// status requests a status endpoint, which answers 200 if the server is in the state
+// checked for and 503 if it is not
+func (c *Client) status(endpoint, op string) (bool, error) {
+	baseURL, err := c.restURL(endpoint)
+	if err != nil {
+		return false, err
+	}
+
+	req, err := c.newAPIRequest("GET", baseURL.String())
+	if err != nil {
+		return false, err
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusServiceUnavailable:
+		return false, nil
+	default:
+		return false, newHTTPError(op, resp)
+	}
+}
+
 // SearchAssetsForCompletion searches for assets matching a prefix for autocompletion
 // Returns a list of unique path segments (directories and files) at the next level after pathPrefix
 func (c *Client) SearchAssetsForCompletion(repository, pathPrefix string) ([]string, error) {
 	if repository == "" {
 		return nil, nil
 	}
-	baseURL, err := url.Parse(c.BaseURL)
+	baseURL, err := c.restURL("/service/rest/v1/search/assets")
 	if err != nil {
-		return nil, fmt.Errorf("invalid Nexus URL: %w", err)
+		return nil, err
 	}
-	baseURL.Path = "/service/rest/v1/search/assets"
 	query := baseURL.Query()
 	query.Set("repository", repository)
 	query.Set("format", "raw")
@@ -121,12 +505,11 @@ func (c *Client) SearchAssetsForCompletion(repository, pathPrefix string) ([]str
 	}
 	baseURL.RawQuery = query.Encode()
 
-	req, err := http.NewRequest("GET", baseURL.String(), nil)
+	req, err := c.newAPIRequest("GET", baseURL.String())
 	if err != nil {
 		return nil, err
 	}
-	req.SetBasicAuth(c.Username, c.Password)
-	resp, err := c.HTTPClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -135,7 +518,7 @@ func (c *Client) SearchAssetsForCompletion(repository, pathPrefix string) ([]str
 		return nil, nil
 	}
 	var sr SearchResponse
-	if err := json.NewDecoder(resp.Body).Decode(&sr); err != nil {
+	if err := c.decodeJSON(resp, &sr); err != nil {
 		return nil, err
 	}
 
@@ -196,23 +579,101 @@ func (c *Client) SearchAssetsForCompletion(repository, pathPrefix string) ([]str
 	return paths, nil
 }
 
+// listPageAttempts is how often a page of an asset listing is requested before giving up
+const listPageAttempts = 4
+
+// ListRetryBackoff is the delay before the first retry of a failed page; it doubles with every retry
+var ListRetryBackoff = 500 * time.Millisecond
+
+// SearchSort selects the order in which the search API returns assets. Nexus sorts by
+// "group", "name", "version" or "repository" and Direction is "asc" or "desc".
+type SearchSort struct {
+	Key       string
+	Direction string
+}
+
+// DefaultSearchSort is the order in which ListAssets returns assets
+var DefaultSearchSort = SearchSort{Key: "name", Direction: "asc"}
+
 // ListAssets lists all assets in a repository path
 // When recursive is true, searches for path/* (all files under the path)
 // When recursive is false, searches for the exact path (single file)
+// A page that fails with a transient error is retried with the last continuation token,
+// so a long listing resumes where it stopped instead of starting over. A group repository
+// is listed member by member, see ListGroupAssets; if its members cannot be read, the
+// group itself is searched.
 func (c *Client) ListAssets(repository, path string, recursive bool) ([]Asset, error) {
+	return c.ListAssetsSorted(repository, path, recursive, DefaultSearchSort)
+}
+
+// ListAssetsSorted lists all assets in a repository path like ListAssets, in the given order
+func (c *Client) ListAssetsSorted(repository, path string, recursive bool, order SearchSort) ([]Asset, error) {
+	return c.ListAssetsFiltered(repository, path, recursive, order, nil)
+}
+
+// ListFilter reports whether a listing keeps an asset. It is applied to each page of
+// search results as it arrives, so the assets it drops are never collected.
+type ListFilter func(asset Asset) bool
+
+// ListAssetsFiltered lists the assets in a repository path like ListAssetsSorted, keeping
+// only those keep accepts. A nil keep keeps all assets.
+func (c *Client) ListAssetsFiltered(repository, path string, recursive bool, order SearchSort, keep ListFilter) ([]Asset, error) {
+	if members, err := c.GetGroupMembers(repository); err == nil && len(members) > 0 {
+		return c.ListGroupAssets(members, path, recursive, order, keep, nil)
+	}
+	return c.listRepositoryAssets(repository, path, recursive, order, keep)
+}
+
+// ListGroupAssets lists the assets below path in members, the members of a group
+// repository, like ListAssetsSorted. Nexus reports the content of a group under its
+// members, so searching the group itself finds nothing. Members are visited in group order
+// and the first member providing a path wins, the same way Nexus resolves requests
+// against a group. The Repository of each asset is the member that provides it. Only the
+// assets keep accepts are listed, or all if keep is nil. shadowed, if not nil, is called
+// for each asset ignored because an earlier member provides its path.
+func (c *Client) ListGroupAssets(members []string, path string, recursive bool, order SearchSort, keep ListFilter, shadowed func(asset Asset, provider string)) ([]Asset, error) {
+	providedBy := make(map[string]string)
+	var assets []Asset
+	for _, member := range members {
+		memberAssets, err := c.ListAssetsFiltered(member, path, recursive, order, keep)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list assets of group member '%s': %w", member, err)
+		}
+		for _, asset := range memberAssets {
+			if provider, ok := providedBy[asset.Path]; ok {
+				if shadowed != nil {
+					shadowed(asset, provider)
+				}
+				continue
+			}
+			providedBy[asset.Path] = member
+			assets = append(assets, asset)
+		}
+	}
+	sort.SliceStable(assets, func(i, j int) bool {
+		if order.Direction == "desc" {
+			return assets[i].Path > assets[j].Path
+		}
+		return assets[i].Path < assets[j].Path
+	})
+	return assets, nil
+}
+
+// listRepositoryAssets searches the assets below path in a single repository, keeping those
+// keep accepts
+func (c *Client) listRepositoryAssets(repository, path string, recursive bool, order SearchSort, keep ListFilter) ([]Asset, error) {
 	var assets []Asset
 	continuationToken := ""
-	for {
-		baseURL, err := url.Parse(c.BaseURL)
+	for page := 1; ; page++ {
+		baseURL, err := c.restURL("/service/rest/v1/search/assets")
 		if err != nil {
-			return nil, fmt.Errorf("invalid Nexus URL: %w", err)
+			return nil, err
 		}
-		baseURL.Path = "/service/rest/v1/search/assets"
 		query := baseURL.Query()
 		query.Set("repository", repository)
 		query.Set("format", "raw")
-		query.Set("direction", "asc")
-		query.Set("sort", "name")
+		query.Set("direction", order.Direction)
+		query.Set("sort", order.Key)
 		// Ensure path starts with / as required by Nexus API
 		searchPath := pathpkg.Join("/", path)
 		if recursive {
@@ -226,21 +687,18 @@ func (c *Client) ListAssets(repository, path string, recursive bool) ([]Asset, e
 		}
 		baseURL.RawQuery = query.Encode()
 
-		req, _ := http.NewRequest("GET", baseURL.String(), nil)
-		req.SetBasicAuth(c.Username, c.Password)
-		resp, err := c.HTTPClient.Do(req)
+		sr, err := c.searchAssetsPageWithRetry(baseURL.String())
 		if err != nil {
+			if page > 1 {
+				return nil, fmt.Errorf("listing stopped at page %d after retrieving %d asset(s): %w", page, len(assets), err)
+			}
 			return nil, err
 		}
-		defer resp.Body.Close()
-		if resp.StatusCode != 200 {
-			return nil, fmt.Errorf("Failed to list assets: %d", resp.StatusCode)
-		}
-		var sr SearchResponse
-		if err := json.NewDecoder(resp.Body).Decode(&sr); err != nil {
-			return nil, err
+		for _, asset := range sr.Items {
+			if keep == nil || keep(asset) {
+				assets = append(assets, asset)
+			}
 		}
-		assets = append(assets, sr.Items...)
 		if sr.ContinuationToken == "" {
 			break
 		}
@@ -249,24 +707,201 @@ func (c *Client) ListAssets(repository, path string, recursive bool) ([]Asset, e
 	return assets, nil
 }
 
+// searchAssetsPageWithRetry requests a single page of search results, retrying transient
+// failures with exponential backoff
+func (c *Client) searchAssetsPageWithRetry(pageURL string) (*SearchResponse, error) {
+	backoff := ListRetryBackoff
+	for attempt := 1; ; attempt++ {
+		sr, err := c.searchAssetsPage(pageURL)
+		if err == nil || attempt == listPageAttempts || !isRetryable(err) {
+			return sr, err
+		}
+		metrics.Active().Add(metrics.Retries, 1, metrics.RequestSearch)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// searchAssetsPage requests a single page of search results
+func (c *Client) searchAssetsPage(pageURL string) (*SearchResponse, error) {
+	req, err := c.newAPIRequest("GET", pageURL)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, newHTTPError("list assets", resp)
+	}
+	var sr SearchResponse
+	if err := c.decodeJSON(resp, &sr); err != nil {
+		return nil, err
+	}
+	return &sr, nil
+}
+
+// UploadRawAsset streams content to remotePath in a RAW repository without buffering it.
+// remotePath is slash separated; its directory becomes the raw.directory form field.
+func (c *Client) UploadRawAsset(repository, remotePath string, content io.Reader) error {
+	remotePath = strings.TrimPrefix(pathpkg.Clean("/"+remotePath), "/")
+	directory, filename := pathpkg.Split(remotePath)
+
+	form := NewFormStream(func(writer *multipart.Writer) error {
+		part, err := writer.CreateFormFile("raw.asset1", filename)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(part, content); err != nil {
+			return err
+		}
+		_ = writer.WriteField("raw.asset1.filename", filename)
+		if directory != "" {
+			_ = writer.WriteField("raw.directory", strings.TrimSuffix(directory, "/"))
+		}
+		return nil
+	})
+
+	err := c.UploadComponent(repository, form, form.ContentType())
+	if formErr := form.Close(); formErr != nil {
+		return formErr
+	}
+	return err
+}
+
+// UploadRawAssets uploads small files held in memory to a RAW repository in a single
+// request. contents is keyed by the slash separated path of each file below directory.
+func (c *Client) UploadRawAssets(repository, directory string, contents map[string][]byte) error {
+	relPaths := make([]string, 0, len(contents))
+	for relPath := range contents {
+		relPaths = append(relPaths, relPath)
+	}
+	sort.Strings(relPaths)
+
+	form := NewFormStream(func(writer *multipart.Writer) error {
+		for i, relPath := range relPaths {
+			field := fmt.Sprintf("raw.asset%d", i+1)
+			part, err := writer.CreateFormFile(field, pathpkg.Base(relPath))
+			if err != nil {
+				return err
+			}
+			if _, err := part.Write(contents[relPath]); err != nil {
+				return err
+			}
+			_ = writer.WriteField(field+".filename", relPath)
+		}
+		if directory != "" {
+			_ = writer.WriteField("raw.directory", directory)
+		}
+		return nil
+	})
+
+	err := c.UploadComponent(repository, form, form.ContentType())
+	if formErr := form.Close(); formErr != nil {
+		return formErr
+	}
+	return err
+}
+
+// CopyAsset copies asset to targetPath in a repository of the same server. The copy is
+// first attempted on the server with CopyAssetOnServer, so the content does not pass
+// through the client. Where the server offers no such copy, the content is streamed from
+// the download into an upload to a RAW repository, without buffering it, and the
+// transferred bytes are also written to progress if it is not nil. onServer reports
+// whether the server made the copy.
+func (c *Client) CopyAsset(asset Asset, repository, targetPath string, progress io.Writer) (onServer bool, err error) {
+	if err := c.CopyAssetOnServer(asset, repository, targetPath); !errors.Is(err, ErrServerCopyUnavailable) {
+		return err == nil, err
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		var writer io.Writer = pw
+		if progress != nil {
+			writer = io.MultiWriter(pw, progress)
+		}
+		pw.CloseWithError(c.DownloadAsset(asset.DownloadURL, writer))
+	}()
+	err = c.UploadRawAsset(repository, targetPath, pr)
+	pr.CloseWithError(err)
+	return false, err
+}
+
+// ErrServerCopyUnavailable is returned by CopyAssetOnServer when the server cannot copy
+// assets itself
+var ErrServerCopyUnavailable = errors.New("server-side copy is not available")
+
+// CopyAssetOnServer asks the server to copy asset to targetPath in repository with POST
+// /service/rest/v1/assets/{id}/copy, without the content passing through the client.
+// Current Nexus releases, OSS and Pro, do not offer this endpoint: the Pro staging API only
+// moves whole components between repositories, keeping their path. A server that answers
+// 404, 405 or 501 is remembered as having no server-side copy, so later copies by the
+// same client do not ask again and ErrServerCopyUnavailable is returned.
+func (c *Client) CopyAssetOnServer(asset Asset, repository, targetPath string) error {
+	if c.noServerCopy.Load() {
+		return ErrServerCopyUnavailable
+	}
+	baseURL, err := c.restURL("/service/rest/v1/assets", asset.ID, "copy")
+	if err != nil {
+		return err
+	}
+
+	target := map[string]string{"repository": repository, "path": strings.TrimLeft(targetPath, "/")}
+	status, err := c.sendJSON("POST", baseURL.String(), target, fmt.Sprintf("copy asset %s on the server", asset.ID),
+		http.StatusOK, http.StatusNoContent, http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusNotImplemented)
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK && status != http.StatusNoContent {
+		c.noServerCopy.Store(true)
+		return ErrServerCopyUnavailable
+	}
+	return nil
+}
+
+// DeleteAsset deletes an asset by its ID
+func (c *Client) DeleteAsset(id string) error {
+	baseURL, err := c.restURL("/service/rest/v1/assets", id)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("DELETE", baseURL.String(), nil)
+	if err != nil {
+		return err
+	}
+	c.authenticate(req)
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 204 {
+		return newHTTPError(fmt.Sprintf("delete asset %s", id), resp)
+	}
+	return nil
+}
+
 // UploadComponent uploads a component to a Nexus repository
 func (c *Client) UploadComponent(repository string, body io.Reader, contentType string) error {
-	baseURL, err := url.Parse(c.BaseURL)
+	baseURL, err := c.restURL("/service/rest/v1/components")
 	if err != nil {
-		return fmt.Errorf("invalid Nexus URL: %w", err)
+		return err
 	}
-	baseURL.Path = "/service/rest/v1/components"
 	query := baseURL.Query()
 	query.Set("repository", repository)
 	baseURL.RawQuery = query.Encode()
 
-	req, err := http.NewRequest("POST", baseURL.String(), body)
+	sent := &countingReader{r: body}
+	req, err := http.NewRequest("POST", baseURL.String(), sent)
 	if err != nil {
 		return err
 	}
-	req.SetBasicAuth(c.Username, c.Password)
+	c.authenticate(req)
 	req.Header.Set("Content-Type", contentType)
-	resp, err := c.HTTPClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return err
 	}
@@ -274,27 +909,98 @@ func (c *Client) UploadComponent(repository string, body io.Reader, contentType
 	if resp.StatusCode == 204 {
 		return nil
 	}
-	respBody, _ := io.ReadAll(resp.Body)
+	httpErr := newHTTPError(fmt.Sprintf("upload to repository '%s'", repository), resp)
+	if isRequestTooLarge(httpErr) {
+		// The error page of a proxy is HTML that means nothing on a terminal
+		return &RequestTooLargeError{Repository: repository, Sent: sent.n.Load(), HTTPError: httpErr}
+	}
 	if resp.StatusCode == 404 {
-		return fmt.Errorf("repository '%s' not found (status %d)", repository, resp.StatusCode)
+		return fmt.Errorf("repository '%s' not found: %w", repository, httpErr)
+	}
+	return httpErr
+}
+
+// EscapePath percent-encodes each segment of a slash-separated asset path.
+// Segments that are already valid percent-encoded strings are decoded first,
+// so a path is never encoded twice.
+func EscapePath(p string) string {
+	segments := strings.Split(p, "/")
+	for i, segment := range segments {
+		if decoded, err := url.PathUnescape(segment); err == nil {
+			segment = decoded
+		}
+		segments[i] = url.PathEscape(segment)
 	}
-	return fmt.Errorf("upload failed with status %d: %s", resp.StatusCode, string(respBody))
+	return strings.Join(segments, "/")
+}
+
+// normalizeDownloadURL re-encodes the path of a download URL exactly once.
+// Nexus may return download URLs with raw characters (spaces, '%') in the path,
+// which must be escaped before building the request.
+func normalizeDownloadURL(rawURL string) string {
+	schemeEnd := strings.Index(rawURL, "://")
+	if schemeEnd < 0 {
+		return rawURL
+	}
+	hostStart := schemeEnd + len("://")
+	pathStart := strings.Index(rawURL[hostStart:], "/")
+	if pathStart < 0 {
+		return rawURL
+	}
+	pathStart += hostStart
+
+	assetPath := rawURL[pathStart:]
+	rawQuery := ""
+	if idx := strings.Index(assetPath, "?"); idx >= 0 {
+		rawQuery = assetPath[idx:]
+		assetPath = assetPath[:idx]
+	}
+	return rawURL[:pathStart] + EscapePath(assetPath) + rawQuery
 }
 
 // DownloadAsset downloads an asset from a Nexus repository
 func (c *Client) DownloadAsset(downloadURL string, writer io.Writer) error {
-	req, err := http.NewRequest("GET", downloadURL, nil)
+	return c.DownloadAssetRange(downloadURL, 0, -1, writer)
+}
+
+// DownloadAssetRange downloads length bytes of an asset starting at offset, or the rest of
+// the asset from offset if length is negative. A server that ignores the Range header and
+// sends the whole asset is handled by skipping the bytes before offset. Asking for the
+// rest of an asset from its end writes nothing.
+func (c *Client) DownloadAssetRange(downloadURL string, offset, length int64, writer io.Writer) error {
+	req, err := http.NewRequest("GET", normalizeDownloadURL(downloadURL), nil)
 	if err != nil {
 		return err
 	}
-	req.SetBasicAuth(c.Username, c.Password)
-	resp, err := c.HTTPClient.Do(req)
+	c.authenticate(req)
+	// Artifacts are stored as is; never let the transport decompress e.g. a .tar.gz on the way
+	req.Header.Set("Accept-Encoding", "identity")
+	switch {
+	case length >= 0:
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+length-1))
+	case offset > 0:
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	resp, err := c.do(req)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
-	if resp.StatusCode != 200 {
-		return fmt.Errorf("failed to download asset: %d", resp.StatusCode)
+	switch {
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && length < 0:
+		return nil
+	case resp.StatusCode == http.StatusOK && offset > 0:
+		if _, err := io.CopyN(io.Discard, resp.Body, offset); err != nil {
+			return fmt.Errorf("download asset: asset is shorter than %d bytes: %w", offset, err)
+		}
+	case resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent:
+		return newHTTPError("download asset", resp)
+	}
+	if length >= 0 {
+		if _, err := io.CopyN(writer, resp.Body, length); err != nil {
+			return fmt.Errorf("download asset: expected %d bytes at offset %d: %w", length, offset, err)
+		}
+		return nil
 	}
 	_, err = io.Copy(writer, resp.Body)
 	return err
@@ -305,45 +1011,111 @@ func GetFormDataContentType(writer *multipart.Writer) string {
 	return writer.FormDataContentType()
 }
 
+// FormStream is a multipart form that is written while it is read, so an upload is sent
+// to Nexus as it is produced and never held in memory. Memory use stays at the size of
+// the pipe and copy buffers regardless of the size of the uploaded files.
+type FormStream struct {
+	pr          *io.PipeReader
+	contentType string
+	done        chan struct{}
+	err         error
+	closeOnce   sync.Once
+}
+
+// NewFormStream starts writing a multipart form with build. The form is only terminated
+// if build succeeds; if it fails, reading the stream fails with its error instead, so a
+// request that carries the stream is aborted rather than completed with missing parts.
+func NewFormStream(build func(writer *multipart.Writer) error) *FormStream {
+	pr, pw := io.Pipe()
+	writer := multipart.NewWriter(pw)
+	s := &FormStream{pr: pr, contentType: writer.FormDataContentType(), done: make(chan struct{})}
+	go func() {
+		defer close(s.done)
+		err := build(writer)
+		if err == nil {
+			err = writer.Close()
+		}
+		s.err = err
+		pw.CloseWithError(err)
+	}()
+	return s
+}
+
+// ContentType returns the Content-Type of the form, including its boundary
+func (s *FormStream) ContentType() string {
+	return s.contentType
+}
+
+func (s *FormStream) Read(p []byte) (int, error) {
+	return s.pr.Read(p)
+}
+
+// Close stops writing the form, waits for the writer to return and reports the error
+// build failed with. A write failing only because the stream was closed before it was
+// fully read, e.g. when Nexus rejected the request early, is not reported.
+func (s *FormStream) Close() error {
+	s.closeOnce.Do(func() { s.pr.Close() })
+	<-s.done
+	if errors.Is(s.err, io.ErrClosedPipe) {
+		return nil
+	}
+	return s.err
+}
+
 // FileUpload represents a file to be uploaded
 type FileUpload struct {
 	FilePath     string // Absolute path to the file
-	RelativePath string // Relative path to use in Nexus (with forward slashes)
+	RelativePath string // Path below the directory of the form (with forward slashes), which may include subdirectories
+	ContentType  string // Content-Type of the form part; "" sends application/octet-stream and leaves detection to Nexus
 }
 
 // FileProcessCallback is called before processing each file during upload
-// idx is the 0-based index of the file being processed, total is the total number of files
+// idx is the 0-based index of the file being processed, total is the total number of files,
+// or -1 if it is not known yet
 type FileProcessCallback func(idx, total int)
 
 // BuildRawUploadForm builds a multipart form for uploading files to a Nexus RAW repository
 // It writes the form data to the provided writer and returns any error encountered
+// The components API takes a single raw.directory per request, which is subdir; each file
+// carries the rest of its path in raw.assetN.filename, so one form uploads the files of any
+// number of subdirectories and a tree of mixed depth needs no request per directory
 // If onFileStart is provided, it will be called before processing each file with the index and total count
 // If onFileComplete is provided, it will be called after processing each file with the index and total count
 func BuildRawUploadForm(writer *multipart.Writer, files []FileUpload, subdir string, progressWriter io.Writer, onFileStart, onFileComplete FileProcessCallback) error {
-	for idx, file := range files {
-		// Notify callback that we're starting to process this file
-		if onFileStart != nil {
-			onFileStart(idx, len(files))
+	idx := 0
+	next := func() (FileUpload, error) {
+		if idx == len(files) {
+			return FileUpload{}, io.EOF
 		}
+		idx++
+		return files[idx-1], nil
+	}
+	return buildRawUploadForm(writer, next, len(files), subdir, progressWriter, onFileStart, onFileComplete)
+}
 
-		f, err := os.Open(file.FilePath)
-		if err != nil {
-			return err
-		}
-		defer f.Close()
+// BuildRawUploadFormStream is like BuildRawUploadForm for files that are still being found
+// while the form is written. It writes the files returned by next until next returns
+// io.EOF; any other error aborts the form. The callbacks are given a total of -1.
+func BuildRawUploadFormStream(writer *multipart.Writer, next func() (FileUpload, error), subdir string, progressWriter io.Writer, onFileStart, onFileComplete FileProcessCallback) error {
+	return buildRawUploadForm(writer, next, -1, subdir, progressWriter, onFileStart, onFileComplete)
+}
 
-		// Create form file with Nexus RAW format: raw.asset1, raw.asset2, etc.
-		part, err := writer.CreateFormFile(fmt.Sprintf("raw.asset%d", idx+1), filepath.Base(file.FilePath))
+func buildRawUploadForm(writer *multipart.Writer, next func() (FileUpload, error), total int, subdir string, progressWriter io.Writer, onFileStart, onFileComplete FileProcessCallback) error {
+	for idx := 0; ; idx++ {
+		file, err := next()
+		if err == io.EOF {
+			break
+		}
 		if err != nil {
 			return err
 		}
 
-		// Copy file content to form, optionally through progress writer
-		var reader io.Reader = f
-		if progressWriter != nil {
-			reader = io.TeeReader(f, progressWriter)
+		// Notify callback that we're starting to process this file
+		if onFileStart != nil {
+			onFileStart(idx, total)
 		}
-		if _, err := io.Copy(part, reader); err != nil {
+
+		if err := writeFormFile(writer, fmt.Sprintf("raw.asset%d", idx+1), file.FilePath, file.ContentType, progressWriter); err != nil {
 			return err
 		}
 
@@ -352,7 +1124,7 @@ func BuildRawUploadForm(writer *multipart.Writer, files []FileUpload, subdir str
 
 		// Notify callback that we've completed processing this file
 		if onFileComplete != nil {
-			onFileComplete(idx, len(files))
+			onFileComplete(idx, total)
 		}
 	}
 
@@ -364,64 +1136,58 @@ func BuildRawUploadForm(writer *multipart.Writer, files []FileUpload, subdir str
 	return nil
 }
 
-// BuildAptUploadForm builds a multipart form for uploading a .deb file to a Nexus APT repository
-// It writes the form data to the provided writer and returns any error encountered
-// The debFile parameter should contain the path to a single .deb file
-// If progressWriter is provided, progress will be tracked during the upload
-func BuildAptUploadForm(writer *multipart.Writer, debFile string, progressWriter io.Writer) error {
-	f, err := os.Open(debFile)
+// writeFormFile copies the file at path into a new form file part with the given
+// Content-Type, or application/octet-stream if it is "". Each file is opened only while it
+// is copied, so a form with many files does not hold them all open.
+func writeFormFile(writer *multipart.Writer, field, path, contentType string, progressWriter io.Writer) error {
+	f, err := os.Open(path)
 	if err != nil {
 		return err
 	}
 	defer f.Close()
 
-	part, err := writer.CreateFormFile("apt.asset", filepath.Base(debFile))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", multipart.FileContentDisposition(field, filepath.Base(path)))
+	header.Set("Content-Type", contentType)
+	part, err := writer.CreatePart(header)
 	if err != nil {
 		return err
 	}
 
+	// Copy file content to form, optionally through progress writer
 	var reader io.Reader = f
 	if progressWriter != nil {
 		reader = io.TeeReader(f, progressWriter)
 	}
 	if _, err := io.Copy(part, reader); err != nil {
-		return err
+		return fmt.Errorf("failed to read %s: %w", path, err)
 	}
-
 	return nil
 }
 
+// BuildAptUploadForm builds a multipart form for uploading a .deb file to a Nexus APT repository
+// It writes the form data to the provided writer and returns any error encountered
+// The debFile parameter should contain the path to a single .deb file
+// If progressWriter is provided, progress will be tracked during the upload
+func BuildAptUploadForm(writer *multipart.Writer, debFile string, progressWriter io.Writer) error {
+	return writeFormFile(writer, "apt.asset", debFile, "", progressWriter)
+}
+
 // BuildYumUploadForm builds a multipart form for uploading an .rpm file to a Nexus YUM repository
 // It writes the form data to the provided writer and returns any error encountered
 // The rpmFile parameter should contain the path to a single .rpm file
 // If progressWriter is provided, progress will be tracked during the upload
 func BuildYumUploadForm(writer *multipart.Writer, rpmFile string, progressWriter io.Writer) error {
-	f, err := os.Open(rpmFile)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-
-	filename := filepath.Base(rpmFile)
-
-	if err := writer.WriteField("yum.asset.filename", filename); err != nil {
+	if _, err := os.Stat(rpmFile); err != nil {
 		return err
 	}
-
-	part, err := writer.CreateFormFile("yum.asset", filename)
-	if err != nil {
-		return err
-	}
-
-	var reader io.Reader = f
-	if progressWriter != nil {
-		reader = io.TeeReader(f, progressWriter)
-	}
-	if _, err := io.Copy(part, reader); err != nil {
+	if err := writer.WriteField("yum.asset.filename", filepath.Base(rpmFile)); err != nil {
 		return err
 	}
-
-	return nil
+	return writeFormFile(writer, "yum.asset", rpmFile, "", progressWriter)
 }
 
 // SearchAssets searches for assets in a repository with optional path prefix
@@ -430,11 +1196,10 @@ func (c *Client) SearchAssets(repository, pathPrefix string) ([]Asset, error) {
 	continuationToken := ""
 
 	for {
-		baseURL, err := url.Parse(c.BaseURL)
+		baseURL, err := c.restURL("/service/rest/v1/search/assets")
 		if err != nil {
-			return nil, fmt.Errorf("invalid Nexus URL: %w", err)
+			return nil, err
 		}
-		baseURL.Path = "/service/rest/v1/search/assets"
 		query := baseURL.Query()
 		query.Set("repository", repository)
 		if pathPrefix != "" {
@@ -447,21 +1212,20 @@ func (c *Client) SearchAssets(repository, pathPrefix string) ([]Asset, error) {
 		}
 		baseURL.RawQuery = query.Encode()
 
-		req, err := http.NewRequest("GET", baseURL.String(), nil)
+		req, err := c.newAPIRequest("GET", baseURL.String())
 		if err != nil {
 			return nil, err
 		}
-		req.SetBasicAuth(c.Username, c.Password)
-		resp, err := c.HTTPClient.Do(req)
+		resp, err := c.do(req)
 		if err != nil {
 			return nil, err
 		}
 		defer resp.Body.Close()
 		if resp.StatusCode != 200 {
-			return nil, fmt.Errorf("failed to search assets: status %d", resp.StatusCode)
+			return nil, newHTTPError("search assets", resp)
 		}
 		var sr SearchResponse
-		if err := json.NewDecoder(resp.Body).Decode(&sr); err != nil {
+		if err := c.decodeJSON(resp, &sr); err != nil {
 			return nil, err
 		}
 		assets = append(assets, sr.Items...)
@@ -474,13 +1238,55 @@ func (c *Client) SearchAssets(repository, pathPrefix string) ([]Asset, error) {
 	return assets, nil
 }
 
-// GetAssetByPath gets a single asset by its exact path in a repository
+// SearchAssetsBySHA256 returns the assets of a repository whose content has the given
+// SHA-256 checksum, wherever they are stored
+func (c *Client) SearchAssetsBySHA256(repository, sha256 string) ([]Asset, error) {
+	var assets []Asset
+	continuationToken := ""
+	for {
+		baseURL, err := c.restURL("/service/rest/v1/search/assets")
+		if err != nil {
+			return nil, err
+		}
+		query := baseURL.Query()
+		query.Set("repository", repository)
+		query.Set("sha256", strings.ToLower(sha256))
+		if continuationToken != "" {
+			query.Set("continuationToken", continuationToken)
+		}
+		baseURL.RawQuery = query.Encode()
+
+		sr, err := c.searchAssetsPageWithRetry(baseURL.String())
+		if err != nil {
+			return nil, err
+		}
+		assets = append(assets, sr.Items...)
+		if sr.ContinuationToken == "" {
+			break
+		}
+		continuationToken = sr.ContinuationToken
+	}
+	return assets, nil
+}
+
+// GetAssetByPath gets a single asset by its exact path in a repository. It returns an
+// error wrapping ErrAssetNotFound if there is none. In a group repository the asset of the
+// first member providing the path is returned.
 func (c *Client) GetAssetByPath(repository, path string) (*Asset, error) {
-	baseURL, err := url.Parse(c.BaseURL)
+	if members, err := c.GetGroupMembers(repository); err == nil && len(members) > 0 {
+		for _, member := range members {
+			asset, err := c.GetAssetByPath(member, path)
+			if !errors.Is(err, ErrAssetNotFound) {
+				return asset, err
+			}
+		}
+		return nil, fmt.Errorf("%w: %s", ErrAssetNotFound, path)
+	}
+
+	baseURL, err := c.restURL("/service/rest/v1/search/assets")
 	if err != nil {
-		return nil, fmt.Errorf("invalid Nexus URL: %w", err)
+		return nil, err
 	}
-	baseURL.Path = "/service/rest/v1/search/assets"
 	query := baseURL.Query()
 	query.Set("repository", repository)
 	// Ensure path starts with / as required by Nexus API
@@ -491,33 +1297,28 @@ func (c *Client) GetAssetByPath(repository, path string) (*Asset, error) {
 	query.Set("name", searchPath)
 	baseURL.RawQuery = query.Encode()
 
-	req, err := http.NewRequest("GET", baseURL.String(), nil)
+	req, err := c.newAPIRequest("GET", baseURL.String())
 	if err != nil {
 		return nil, err
 	}
-	req.SetBasicAuth(c.Username, c.Password)
-	resp, err := c.HTTPClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("failed to get asset: status %d", resp.StatusCode)
+		return nil, newHTTPError("get asset", resp)
 	}
 	var sr SearchResponse
-	if err := json.NewDecoder(resp.Body).Decode(&sr); err != nil {
+	if err := c.decodeJSON(resp, &sr); err != nil {
 		return nil, err
 	}
 
-	if len(sr.Items) == 0 {
-		return nil, fmt.Errorf("asset not found: %s", path)
-	}
-
 	for _, asset := range sr.Items {
-		if asset.Path == path {
+		if strings.TrimPrefix(asset.Path, "/") == strings.TrimPrefix(path, "/") {
 			return &asset, nil
 		}
 	}
 
-	return nil, fmt.Errorf("asset not found: %s", path)
+	return nil, fmt.Errorf("%w: %s", ErrAssetNotFound, path)
 }
@@ -1,11 +1,21 @@
 package nexusapi
 
 import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
 	"mime/multipart"
+	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
+	"time"
 )
 
 // TestNewClient tests creating a new Nexus API client
@@ -26,6 +36,59 @@ func TestNewClient(t *testing.T) {
 	}
 }
 
+func TestRestURL(t *testing.T) {
+	tests := []struct {
+		baseURL  string
+		endpoint string
+		names    []string
+		want     string
+	}{
+		{"http://localhost:8081", "/service/rest/v1/repositories", nil, "http://localhost:8081/service/rest/v1/repositories"},
+		{"https://example.com/nexus/", "/service/rest/v1/status", nil, "https://example.com/nexus/service/rest/v1/status"},
+		{"https://example.com/nexus", "/service/rest/v1/assets", []string{"a/b+c", "copy"}, "https://example.com/nexus/service/rest/v1/assets/a%2Fb+c/copy"},
+		{"https://example.com/my%20nexus", "/service/rest/v1/tags", []string{"v1 rc"}, "https://example.com/my%20nexus/service/rest/v1/tags/v1%20rc"},
+	}
+	for _, tt := range tests {
+		client := NewClient(tt.baseURL, "", "")
+		got, err := client.restURL(tt.endpoint, tt.names...)
+		if err != nil {
+			t.Fatalf("restURL(%q) failed: %v", tt.baseURL, err)
+		}
+		if got.String() != tt.want {
+			t.Errorf("restURL(%q, %q, %v) = %s, want %s", tt.baseURL, tt.endpoint, tt.names, got, tt.want)
+		}
+	}
+}
+
+func TestClientAuthentication(t *testing.T) {
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Clone()
+		w.Write([]byte("[]"))
+	}))
+	defer server.Close()
+
+	for _, tc := range []struct {
+		name          string
+		client        *Client
+		authorization string
+	}{
+		{"basic", NewClient(server.URL, "admin", "secret"), "Basic YWRtaW46c2VjcmV0"},
+		{"anonymous", NewClient(server.URL, "", ""), ""},
+		{"token", NewClient(server.URL, "admin", "secret", WithToken("t0ken"), WithHeaders(map[string]string{"x-team": "platform"})), "Bearer t0ken"},
+	} {
+		if _, err := tc.client.ListRepositories(); err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if got := header.Get("Authorization"); got != tc.authorization {
+			t.Errorf("%s: expected Authorization %q, got %q", tc.name, tc.authorization, got)
+		}
+	}
+	if header.Get("X-Team") != "platform" {
+		t.Errorf("Expected the headers of the client, got %v", header)
+	}
+}
+
 // TestListAssets tests listing assets from Nexus
 func TestListAssets(t *testing.T) {
 	server := NewMockNexusServer()
@@ -51,6 +114,315 @@ func TestListAssets(t *testing.T) {
 	}
 }
 
+func TestListAssetsSorted(t *testing.T) {
+	server := NewMockNexusServer()
+	defer server.Close()
+	server.AddAsset("test-repo", "/builds/1.0/app.bin", Asset{}, nil)
+
+	client := NewClient(server.URL, "testuser", "testpass")
+	if _, err := client.ListAssets("test-repo", "builds", true); err != nil {
+		t.Fatalf("ListAssets failed: %v", err)
+	}
+	if got := server.LastListQuery; got.Get("sort") != "name" || got.Get("direction") != "asc" {
+		t.Errorf("Expected default sort=name&direction=asc, got %v", got)
+	}
+
+	if _, err := client.ListAssetsSorted("test-repo", "builds", true, SearchSort{Key: "version", Direction: "desc"}); err != nil {
+		t.Fatalf("ListAssetsSorted failed: %v", err)
+	}
+	if got := server.LastListQuery; got.Get("sort") != "version" || got.Get("direction") != "desc" {
+		t.Errorf("Expected sort=version&direction=desc, got %v", got)
+	}
+}
+
+// TestGetRepositoryStatus tests reading the online status and group members of a repository
+func TestGetRepositoryStatus(t *testing.T) {
+	server := NewMockNexusServer()
+	defer server.Close()
+
+	server.RepositoryStatuses = []RepositoryStatus{
+		{Name: "raw-hosted", Format: "raw", Type: "hosted", Online: true},
+		{Name: "raw-proxy", Format: "raw", Type: "proxy", Online: false},
+		{Name: "raw-group", Format: "raw", Type: "group", Online: true, Group: &RepositoryGroup{MemberNames: []string{"raw-hosted", "raw-proxy"}}},
+	}
+
+	client := NewClient(server.URL, "testuser", "testpass")
+
+	status, err := client.GetRepositoryStatus("raw-proxy")
+	if err != nil {
+		t.Fatalf("GetRepositoryStatus failed: %v", err)
+	}
+	if status.Online || status.Type != "proxy" {
+		t.Errorf("Expected offline proxy repository, got %+v", status)
+	}
+
+	status, err = client.GetRepositoryStatus("raw-group")
+	if err != nil {
+		t.Fatalf("GetRepositoryStatus failed: %v", err)
+	}
+	if status.Group == nil || strings.Join(status.Group.MemberNames, ",") != "raw-hosted,raw-proxy" {
+		t.Errorf("Expected group members raw-hosted,raw-proxy, got %+v", status.Group)
+	}
+
+	if _, err := client.GetRepositoryStatus("missing"); err == nil {
+		t.Error("Expected error for unknown repository")
+	}
+}
+
+// TestCheckWritable tests which repositories are reported as rejecting uploads
+func TestCheckWritable(t *testing.T) {
+	server := NewMockNexusServer()
+	defer server.Close()
+
+	server.RepositoryStatuses = []RepositoryStatus{
+		{Name: "raw-hosted", Format: "raw", Type: "hosted", Online: true, Storage: &RepositoryStorage{WritePolicy: "ALLOW"}},
+		{Name: "raw-releases", Format: "raw", Type: "hosted", Online: true, Storage: &RepositoryStorage{WritePolicy: "DENY"}},
+		{Name: "raw-offline", Format: "raw", Type: "hosted", Online: false, Storage: &RepositoryStorage{WritePolicy: "ALLOW"}},
+		{Name: "raw-proxy", Format: "raw", Type: "proxy", Online: true},
+	}
+	client := NewClient(server.URL, "testuser", "testpass")
+
+	if err := client.CheckWritable("raw-hosted"); err != nil {
+		t.Errorf("Expected raw-hosted to be writable, got %v", err)
+	}
+	for name, reason := range map[string]string{
+		"raw-releases": "read-only (write policy DENY)",
+		"raw-offline":  "offline",
+		"raw-proxy":    "read-only (proxy repository)",
+	} {
+		err := client.CheckWritable(name)
+		var notWritable *NotWritableError
+		if !errors.As(err, &notWritable) || notWritable.Reason != reason {
+			t.Errorf("Expected %s to be %s, got %v", name, reason, err)
+		}
+	}
+
+	// Unknown repositories are an error, but not a NotWritableError
+	err := client.CheckWritable("missing")
+	var notWritable *NotWritableError
+	if err == nil || errors.As(err, &notWritable) {
+		t.Errorf("Expected a lookup error for an unknown repository, got %v", err)
+	}
+
+	// A group names its first member that accepts uploads
+	server.RepositoryStatuses = append(server.RepositoryStatuses, RepositoryStatus{
+		Name: "raw-all", Format: "raw", Type: "group", Online: true,
+		Group: &RepositoryGroup{MemberNames: []string{"raw-proxy", "raw-releases", "raw-hosted"}},
+	})
+	err = client.CheckWritable("raw-all")
+	if !errors.As(err, &notWritable) || notWritable.WritableMember != "raw-hosted" {
+		t.Fatalf("Expected raw-all to be read-only with writable member raw-hosted, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "upload to its member 'raw-hosted' instead") {
+		t.Errorf("Expected the error to name the writable member, got: %v", err)
+	}
+
+	// Without settings, a group repository is still recognized from the repository list
+	server.Repositories = []Repository{{Name: "raw-group", Format: "raw", Type: "group"}}
+	err = client.CheckWritable("raw-group")
+	if !errors.As(err, &notWritable) || notWritable.Reason != "read-only (group repository)" {
+		t.Errorf("Expected raw-group to be read-only, got %v", err)
+	}
+}
+
+// TestGetGroupMembers tests that the members of a group are returned in resolution order,
+// and nothing for other repositories
+func TestGetGroupMembers(t *testing.T) {
+	server := NewMockNexusServer()
+	defer server.Close()
+
+	server.RepositoryStatuses = []RepositoryStatus{
+		{Name: "raw-hosted", Format: "raw", Type: "hosted", Online: true},
+		{Name: "raw-proxy", Format: "raw", Type: "proxy", Online: true},
+		{Name: "raw-group", Format: "raw", Type: "group", Online: true, Group: &RepositoryGroup{MemberNames: []string{"raw-proxy", "raw-hosted"}}},
+	}
+	server.SetRepositoryNotFound("missing")
+	client := NewClient(server.URL, "testuser", "testpass")
+
+	members, err := client.GetGroupMembers("raw-group")
+	if err != nil || strings.Join(members, ",") != "raw-proxy,raw-hosted" {
+		t.Errorf("Expected members raw-proxy,raw-hosted, got %v, %v", members, err)
+	}
+	for _, name := range []string{"raw-hosted", "missing"} {
+		if members, err := client.GetGroupMembers(name); err != nil || members != nil {
+			t.Errorf("Expected no members for %s, got %v, %v", name, members, err)
+		}
+	}
+
+	// The members are read once per client
+	requests := server.GetRequestCount()
+	for _, name := range []string{"raw-group", "raw-hosted", "missing"} {
+		client.GetGroupMembers(name)
+	}
+	if got := server.GetRequestCount(); got != requests {
+		t.Errorf("Expected the members to be cached, got %d more request(s)", got-requests)
+	}
+}
+
+func TestListAssetsFiltered(t *testing.T) {
+	server := NewMockNexusServer()
+	defer server.Close()
+	server.AddAsset("test-repo", "/builds/app.bin", Asset{}, nil)
+	server.AddAsset("test-repo", "/builds/1.0/app.bin", Asset{}, nil)
+	server.AddAsset("test-repo", "/builds/1.0/debug/app.pdb", Asset{}, nil)
+
+	// The filter sees every listed asset and only the accepted ones are returned
+	var seen []string
+	client := NewClient(server.URL, "testuser", "testpass")
+	assets, err := client.ListAssetsFiltered("test-repo", "builds", true, DefaultSearchSort, func(asset Asset) bool {
+		seen = append(seen, asset.Path)
+		return !strings.Contains(asset.Path, "/debug/")
+	})
+	if err != nil {
+		t.Fatalf("ListAssetsFiltered failed: %v", err)
+	}
+	if len(seen) != 3 || len(assets) != 2 {
+		t.Errorf("Expected 3 assets to be filtered down to 2, saw %v and kept %+v", seen, assets)
+	}
+}
+
+func TestListAssetsOfGroup(t *testing.T) {
+	server := NewMockNexusServer()
+	defer server.Close()
+
+	server.RepositoryStatuses = []RepositoryStatus{
+		{Name: "raw-hosted", Format: "raw", Type: "hosted", Online: true},
+		{Name: "raw-proxy", Format: "raw", Type: "proxy", Online: true},
+		{Name: "raw-group", Format: "raw", Type: "group", Online: true, Group: &RepositoryGroup{MemberNames: []string{"raw-hosted", "raw-proxy"}}},
+	}
+	server.AddAsset("raw-hosted", "/libs/shared.jar", Asset{}, []byte("hosted"))
+	server.AddAsset("raw-proxy", "/libs/shared.jar", Asset{}, []byte("proxy"))
+	server.AddAsset("raw-proxy", "/libs/other.jar", Asset{}, []byte("other"))
+	client := NewClient(server.URL, "testuser", "testpass")
+
+	assets, err := client.ListAssets("raw-group", "libs", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var listed []string
+	for _, asset := range assets {
+		listed = append(listed, asset.Repository+":"+asset.Path)
+	}
+	if strings.Join(listed, ",") != "raw-proxy:/libs/other.jar,raw-hosted:/libs/shared.jar" {
+		t.Errorf("Expected the assets of the group members, first member first, got %v", listed)
+	}
+
+	asset, err := client.GetAssetByPath("raw-group", "libs/shared.jar")
+	if err != nil || asset.Repository != "raw-hosted" {
+		t.Errorf("Expected the asset of the first member, got %+v, %v", asset, err)
+	}
+	if _, err := client.GetAssetByPath("raw-group", "libs/missing.jar"); !errors.Is(err, ErrAssetNotFound) {
+		t.Errorf("Expected ErrAssetNotFound, got %v", err)
+	}
+}
+
+// TestListRepositoriesDetails tests that the repository list includes type, URL and online state
+func TestStatus(t *testing.T) {
+	server := NewMockNexusServer()
+	defer server.Close()
+	server.Unavailable = 1
+	server.ReadOnly = 1
+
+	client := NewClient(server.URL, "testuser", "testpass")
+	checks := []struct {
+		check func() (bool, error)
+		want  bool
+	}{
+		{client.StatusAvailable, false},
+		{client.StatusAvailable, true},
+		{client.StatusWritable, false}, // Available, but still read-only
+		{client.StatusWritable, true},
+	}
+	for i, c := range checks {
+		got, err := c.check()
+		if err != nil {
+			t.Fatalf("Check %d failed: %v", i, err)
+		}
+		if got != c.want {
+			t.Errorf("Check %d: expected %v, got %v", i, c.want, got)
+		}
+	}
+
+	if _, err := NewClient("://invalid", "", "").StatusWritable(); err == nil {
+		t.Error("Expected an error for an invalid URL")
+	}
+}
+
+func TestRequestMetrics(t *testing.T) {
+	server := NewMockNexusServer()
+	defer server.Close()
+	server.AddAsset("test-repo", "/dir/file.txt", Asset{}, []byte("content"))
+
+	if client := NewClient(server.URL, "testuser", "testpass"); client.HTTPClient != http.DefaultClient {
+		t.Error("Expected requests not to be timed unless enabled")
+	}
+
+	metrics := newRequestMetrics()
+	client := NewClient(server.URL, "testuser", "testpass")
+	// The type of the repository is checked once, before the requests that are timed
+	if _, err := client.GetGroupMembers("test-repo"); err != nil {
+		t.Fatal(err)
+	}
+	client.HTTPClient = &http.Client{Transport: &metricsTransport{base: http.DefaultTransport.(*http.Transport).Clone(), metrics: metrics}}
+
+	assets, err := client.ListAssets("test-repo", "dir", true)
+	if err != nil || len(assets) != 1 {
+		t.Fatalf("ListAssets failed: %v, %v", assets, err)
+	}
+	if err := client.DownloadAsset(assets[0].DownloadURL, io.Discard); err != nil {
+		t.Fatalf("DownloadAsset failed: %v", err)
+	}
+	if err := client.UploadRawAsset("test-repo", "dir/other.txt", strings.NewReader("other")); err != nil {
+		t.Fatalf("UploadRawAsset failed: %v", err)
+	}
+
+	snapshot := metrics.Snapshot()
+	for _, kind := range []string{RequestKindSearch, RequestKindDownload, RequestKindUpload} {
+		if snapshot[kind].Requests != 1 {
+			t.Errorf("Expected 1 %s request, got %+v", kind, snapshot[kind])
+		}
+		if snapshot[kind].TTFB <= 0 {
+			t.Errorf("Expected the time to first byte of the %s request to be recorded", kind)
+		}
+	}
+	if snapshot[RequestKindSearch].NewConnections != 1 {
+		t.Errorf("Expected the first request to open a connection, got %+v", snapshot[RequestKindSearch])
+	}
+	if breakdown := metrics.String(); !strings.HasPrefix(breakdown, "Requests download: 1") || strings.Count(breakdown, "\n") != 2 {
+		t.Errorf("Unexpected breakdown:\n%s", breakdown)
+	}
+}
+
+func TestListRepositoriesDetails(t *testing.T) {
+	server := NewMockNexusServer()
+	defer server.Close()
+
+	server.Repositories = []Repository{
+		{Name: "raw-hosted", Format: "raw", Type: "hosted", URL: server.URL + "/repository/raw-hosted"},
+		{Name: "raw-group", Format: "raw", Type: "group", URL: server.URL + "/repository/raw-group"},
+	}
+	server.RepositoryStatuses = []RepositoryStatus{{Name: "raw-hosted", Format: "raw", Type: "hosted", Online: false}}
+	client := NewClient(server.URL, "testuser", "testpass")
+
+	repositories, err := client.ListRepositories()
+	if err != nil {
+		t.Fatalf("ListRepositories failed: %v", err)
+	}
+	if len(repositories) != 2 {
+		t.Fatalf("Expected 2 repositories, got %d", len(repositories))
+	}
+	hosted, group := repositories[0], repositories[1]
+	if hosted.Type != "hosted" || hosted.URL != server.URL+"/repository/raw-hosted" || hosted.Online == nil || *hosted.Online {
+		t.Errorf("Expected an offline hosted repository with its URL, got %+v", hosted)
+	}
+	if group.Online != nil {
+		t.Errorf("Expected no online state for raw-group, got %v", *group.Online)
+	}
+	if hosted.ReadOnly() != "offline" || group.ReadOnly() != "read-only (group repository)" {
+		t.Errorf("Unexpected read-only reasons %q and %q", hosted.ReadOnly(), group.ReadOnly())
+	}
+}
+
 // TestListAssetsWithPagination tests listing assets with continuation tokens
 func TestListAssetsWithPagination(t *testing.T) {
 	server := NewMockNexusServer()
@@ -79,6 +451,60 @@ func TestListAssetsWithPagination(t *testing.T) {
 	}
 }
 
+// TestListAssetsRetriesFailedPage tests that a page failing mid-pagination is retried with its continuation token
+func TestListAssetsRetriesFailedPage(t *testing.T) {
+	oldBackoff := ListRetryBackoff
+	ListRetryBackoff = time.Millisecond
+	defer func() { ListRetryBackoff = oldBackoff }()
+
+	server := NewMockNexusServer()
+	defer server.Close()
+	server.AddAssetForPage("repo", "/path/*", Asset{ID: "asset1", Path: "/path/file1.txt"}, 1)
+	server.AddAssetForPage("repo", "/path/*", Asset{ID: "asset2", Path: "/path/file2.txt"}, 2)
+	server.SetContinuationToken("repo", "/path/*", "token123")
+	server.FailListPage("token123", 2)
+
+	client := NewClient(server.URL, "user", "pass")
+	assets, err := client.ListAssets("repo", "path", true)
+	if err != nil {
+		t.Fatalf("Expected listing to complete after retries: %v", err)
+	}
+	if len(assets) != 2 {
+		t.Errorf("Expected 2 assets, got %d", len(assets))
+	}
+	// The first page is requested once; only the failed second page is repeated
+	if got := server.GetRequestCount(); got != 5 {
+		t.Errorf("Expected 5 requests (repository type + 1 + 2 failures + 1), got %d", got)
+	}
+}
+
+// TestListAssetsGivesUpAfterRetries tests that a persistently failing page reports the assets already retrieved
+func TestListAssetsGivesUpAfterRetries(t *testing.T) {
+	oldBackoff := ListRetryBackoff
+	ListRetryBackoff = time.Millisecond
+	defer func() { ListRetryBackoff = oldBackoff }()
+
+	server := NewMockNexusServer()
+	defer server.Close()
+	server.AddAssetForPage("repo", "/path/*", Asset{ID: "asset1", Path: "/path/file1.txt"}, 1)
+	server.AddAssetForPage("repo", "/path/*", Asset{ID: "asset2", Path: "/path/file2.txt"}, 2)
+	server.SetContinuationToken("repo", "/path/*", "token123")
+	server.FailListPage("token123", listPageAttempts)
+
+	client := NewClient(server.URL, "user", "pass")
+	_, err := client.ListAssets("repo", "path", true)
+	if err == nil {
+		t.Fatal("Expected listing to fail")
+	}
+	if !strings.Contains(err.Error(), "page 2 after retrieving 1 asset(s)") {
+		t.Errorf("Expected error to report progress, got: %v", err)
+	}
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expected wrapped 503 *HTTPError, got %v", err)
+	}
+}
+
 // TestUploadComponent tests uploading a component
 func TestUploadComponent(t *testing.T) {
 	server := NewMockNexusServer()
@@ -116,6 +542,118 @@ func TestUploadComponentError(t *testing.T) {
 	}
 }
 
+// TestHTTPErrorDetails tests that failed requests report the URL, status, request id and body
+func TestHTTPErrorDetails(t *testing.T) {
+	// A raw httptest server is used because the error response needs custom headers
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-Id", "req-42")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("invalid query " + strings.Repeat("x", 1000)))
+	}))
+	defer server.Close()
+
+	client := NewClient(strings.Replace(server.URL, "http://", "http://admin:secret@", 1), "admin", "secret")
+	_, err := client.ListAssets("repo", "path", true)
+
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) {
+		t.Fatalf("Expected *HTTPError, got %T: %v", err, err)
+	}
+	if httpErr.Op != "list assets" || httpErr.Method != "GET" || httpErr.StatusCode != http.StatusBadRequest || httpErr.RequestID != "req-42" {
+		t.Errorf("Unexpected error fields: %+v", httpErr)
+	}
+	if strings.Contains(httpErr.URL, "secret") || !strings.Contains(httpErr.URL, "/service/rest/v1/search/assets?") || !strings.Contains(httpErr.URL, "repository=repo") {
+		t.Errorf("Expected full request URL without credentials, got %s", httpErr.URL)
+	}
+	if !strings.HasPrefix(httpErr.Body, "invalid query") || len(httpErr.Body) != 503 {
+		t.Errorf("Expected body truncated to 500 bytes, got %d bytes", len(httpErr.Body))
+	}
+	if strings.Contains(err.Error(), "secret") || !strings.Contains(err.Error(), "status 400 (request id req-42)") {
+		t.Errorf("Unexpected error message: %v", err)
+	}
+}
+
+// TestListAssetsGzip tests that search responses are requested and decoded as gzip
+func TestListAssetsGzip(t *testing.T) {
+	server := NewMockNexusServer()
+	defer server.Close()
+	for i := 0; i < 50; i++ {
+		server.AddAsset("test-repo", fmt.Sprintf("/builds/%02d/app.bin", i), Asset{}, []byte("app"))
+	}
+
+	client := NewClient(server.URL, "testuser", "testpass")
+	assets, err := client.ListAssets("test-repo", "builds", true)
+	if err != nil {
+		t.Fatalf("ListAssets failed: %v", err)
+	}
+	if len(assets) != 50 {
+		t.Fatalf("Expected 50 assets, got %d", len(assets))
+	}
+	wire, decoded := client.Stats.WireBytes(), client.Stats.DecodedBytes()
+	if wire == 0 || wire >= decoded {
+		t.Errorf("Expected a compressed response smaller than the decoded JSON, got %d wire and %d decoded bytes", wire, decoded)
+	}
+}
+
+// TestDownloadAssetIsNotDecompressed tests that gzip artifacts are downloaded byte for byte
+func TestDownloadAssetIsNotDecompressed(t *testing.T) {
+	var artifact bytes.Buffer
+	gz := gzip.NewWriter(&artifact)
+	gz.Write([]byte("release notes"))
+	gz.Close()
+
+	// A raw httptest server is used to mimic a proxy that marks .gz files as gzip encoded
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			w.Header().Set("Content-Encoding", "gzip")
+		}
+		w.Write(artifact.Bytes())
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "testuser", "testpass")
+	var got bytes.Buffer
+	if err := client.DownloadAsset(server.URL+"/repository/test-repo/notes.txt.gz", &got); err != nil {
+		t.Fatalf("DownloadAsset failed: %v", err)
+	}
+	if !bytes.Equal(got.Bytes(), artifact.Bytes()) {
+		t.Errorf("Expected the gzip artifact unchanged, got %q", got.Bytes())
+	}
+	if client.Stats.WireBytes() != 0 {
+		t.Errorf("Expected asset downloads not to count towards API stats")
+	}
+}
+
+// TestUploadComponentTooLarge tests that a request rejected by a proxy for its size is
+// reported without the HTML error page
+func TestUploadComponentTooLarge(t *testing.T) {
+	server := NewMockNexusServer()
+	defer server.Close()
+	server.MaxRequestBytes = 100
+
+	client := NewClient(server.URL, "testuser", "testpass")
+	err := client.UploadComponent("raw-repo", strings.NewReader(strings.Repeat("x", 500)), "multipart/form-data")
+
+	var tooLarge *RequestTooLargeError
+	if !errors.As(err, &tooLarge) {
+		t.Fatalf("Expected a *RequestTooLargeError, got %v", err)
+	}
+	if tooLarge.Sent <= 0 {
+		t.Errorf("Expected the sent bytes to be counted, got %d", tooLarge.Sent)
+	}
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected wrapped *HTTPError with status 413, got %v", err)
+	}
+	msg := err.Error()
+	if !strings.Contains(msg, "request too large (server limit ~") || !strings.Contains(msg, "--chunked") {
+		t.Errorf("Expected a hint to use smaller requests, got: %s", msg)
+	}
+	if strings.Contains(msg, "<html>") {
+		t.Errorf("Expected the HTML error page to be left out, got: %s", msg)
+	}
+}
+
 // TestUploadComponentRepositoryNotFound tests uploading to a non-existent repository
 func TestUploadComponentRepositoryNotFound(t *testing.T) {
 	server := NewMockNexusServer()
@@ -136,11 +674,135 @@ func TestUploadComponentRepositoryNotFound(t *testing.T) {
 		t.Errorf("Expected error to contain status code 404, got: %v", err)
 	}
 
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != 404 {
+		t.Errorf("Expected wrapped *HTTPError with status 404, got %v", err)
+	}
 	if !strings.Contains(err.Error(), "not found") {
 		t.Errorf("Expected error message to mention 'not found', got: %v", err)
 	}
 }
 
+func TestUploadRawAsset(t *testing.T) {
+	server := NewMockNexusServer()
+	defer server.Close()
+
+	client := NewClient(server.URL, "testuser", "testpass")
+	if err := client.UploadRawAsset("raw-mirror", "firmware/v1/fw.bin", strings.NewReader("firmware")); err != nil {
+		t.Fatalf("UploadRawAsset failed: %v", err)
+	}
+
+	uploaded := server.GetUploadedFiles()
+	if len(uploaded) != 1 {
+		t.Fatalf("Expected 1 uploaded file, got %d", len(uploaded))
+	}
+	if uploaded[0].Repository != "raw-mirror" || uploaded[0].Path != "/firmware/v1/fw.bin" || string(uploaded[0].Content) != "firmware" {
+		t.Errorf("Unexpected upload: %+v", uploaded[0])
+	}
+}
+
+func TestCopyAsset(t *testing.T) {
+	server := NewMockNexusServer()
+	defer server.Close()
+
+	server.AddAsset("builds", "/app/1.0/app.bin", Asset{}, []byte("app binary"))
+	client := NewClient(server.URL, "testuser", "testpass")
+
+	var progress bytes.Buffer
+	onServer, err := client.CopyAsset(server.Assets["builds:/app/1.0/app.bin"], "releases", "app/app.bin", &progress)
+	if err != nil {
+		t.Fatalf("CopyAsset failed: %v", err)
+	}
+	if onServer {
+		t.Error("Expected the copy to fall back to streaming without a server-side copy")
+	}
+
+	uploaded := server.GetUploadedFiles()
+	if len(uploaded) != 1 || uploaded[0].Repository != "releases" || uploaded[0].Path != "/app/app.bin" || string(uploaded[0].Content) != "app binary" {
+		t.Errorf("Unexpected uploads: %+v", uploaded)
+	}
+	if progress.String() != "app binary" {
+		t.Errorf("Expected the content to be reported as progress, got %q", progress.String())
+	}
+
+	// A failed download fails the copy
+	if _, err := client.CopyAsset(Asset{DownloadURL: server.URL + "/repository/builds/missing.bin"}, "releases", "missing.bin", nil); err == nil {
+		t.Error("Expected an error when the source cannot be downloaded")
+	}
+}
+
+func TestCopyAssetOnServer(t *testing.T) {
+	server := NewMockNexusServer()
+	defer server.Close()
+	server.ServerCopy = true
+
+	server.AddAsset("builds", "/app/1.0/app.bin", Asset{}, []byte("app binary"))
+	client := NewClient(server.URL, "testuser", "testpass")
+
+	var progress bytes.Buffer
+	onServer, err := client.CopyAsset(server.Assets["builds:/app/1.0/app.bin"], "releases", "app/app.bin", &progress)
+	if err != nil {
+		t.Fatalf("CopyAsset failed: %v", err)
+	}
+	if !onServer || server.ServerCopies != 1 {
+		t.Errorf("Expected the server to copy the asset, got onServer=%v and %d server copies", onServer, server.ServerCopies)
+	}
+	if len(server.GetUploadedFiles()) != 0 || server.GetDownloadCount() != 0 || progress.Len() != 0 {
+		t.Error("Expected the content not to pass through the client")
+	}
+	copied, ok := server.Assets["releases:/app/app.bin"]
+	if !ok || copied.Checksum.SHA256 != server.Assets["builds:/app/1.0/app.bin"].Checksum.SHA256 {
+		t.Errorf("Expected the copy with the same content, got %+v", copied)
+	}
+}
+
+// TestCopyAssetServerUnavailable checks that a server without a server-side copy is asked
+// only once before the client streams every copy
+func TestCopyAssetServerUnavailable(t *testing.T) {
+	server := NewMockNexusServer()
+	defer server.Close()
+	server.StoreUploads = true
+
+	server.AddAsset("builds", "/a.bin", Asset{}, []byte("a"))
+	server.AddAsset("builds", "/b.bin", Asset{}, []byte("b"))
+	client := NewClient(server.URL, "testuser", "testpass")
+
+	if err := client.CopyAssetOnServer(server.Assets["builds:/a.bin"], "releases", "a.bin"); !errors.Is(err, ErrServerCopyUnavailable) {
+		t.Fatalf("Expected ErrServerCopyUnavailable, got %v", err)
+	}
+	requests := server.GetRequestCount()
+	for _, name := range []string{"a.bin", "b.bin"} {
+		if onServer, err := client.CopyAsset(server.Assets["builds:/"+name], "releases", name, nil); err != nil || onServer {
+			t.Fatalf("Expected %s to be streamed, got onServer=%v, err=%v", name, onServer, err)
+		}
+	}
+	// Each streamed copy is one download and one upload, without asking the server again
+	if got := server.GetRequestCount() - requests; got != 4 {
+		t.Errorf("Expected 4 requests for two streamed copies, got %d", got)
+	}
+	if uploaded := server.GetUploadedFiles(); len(uploaded) != 2 {
+		t.Errorf("Expected both assets to be uploaded, got %+v", uploaded)
+	}
+}
+
+func TestDeleteAsset(t *testing.T) {
+	server := NewMockNexusServer()
+	defer server.Close()
+
+	server.AddAsset("repo", "/path/file.txt", Asset{ID: "cmVwbzpmaWxl"}, []byte("content"))
+	client := NewClient(server.URL, "testuser", "testpass")
+
+	if err := client.DeleteAsset("cmVwbzpmaWxl"); err != nil {
+		t.Fatalf("DeleteAsset failed: %v", err)
+	}
+	if deleted := server.GetDeletedAssets(); len(deleted) != 1 || deleted[0] != "cmVwbzpmaWxl" {
+		t.Errorf("Expected asset to be deleted, got %v", deleted)
+	}
+	if err := client.DeleteAsset("cmVwbzpmaWxl"); err == nil {
+		t.Error("Expected error when deleting a missing asset")
+	}
+}
+
 // TestDownloadAsset tests downloading an asset
 func TestDownloadAsset(t *testing.T) {
 	testContent := "downloaded content"
@@ -165,6 +827,72 @@ func TestDownloadAsset(t *testing.T) {
 	}
 }
 
+// TestDownloadAssetSpecialCharacters tests that asset paths with spaces, plus and percent signs are encoded exactly once
+func TestDownloadAssetSpecialCharacters(t *testing.T) {
+	server := NewMockNexusServer()
+	defer server.Close()
+
+	client := NewClient(server.URL, "testuser", "testpass")
+
+	tests := []struct {
+		name        string
+		downloadURL string
+	}{
+		{
+			name:        "raw spaces, parentheses and plus",
+			downloadURL: server.URL + "/repository/test-repo/dir/my file (v2)+final.txt",
+		},
+		{
+			name:        "already encoded spaces and parentheses",
+			downloadURL: server.URL + "/repository/test-repo/dir/my%20file%20%28v2%29+final.txt",
+		},
+		{
+			name:        "raw percent sign",
+			downloadURL: server.URL + "/repository/test-repo/dir/100%done.bin",
+		},
+		{
+			name:        "already encoded percent sign",
+			downloadURL: server.URL + "/repository/test-repo/dir/100%25done.bin",
+		},
+	}
+
+	server.SetAssetContent("/repository/test-repo/dir/my file (v2)+final.txt", []byte("spaces"))
+	server.SetAssetContent("/repository/test-repo/dir/100%done.bin", []byte("percent"))
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf strings.Builder
+			if err := client.DownloadAsset(tt.downloadURL, &buf); err != nil {
+				t.Fatalf("DownloadAsset failed: %v", err)
+			}
+			if buf.Len() == 0 {
+				t.Error("Expected content, got empty body")
+			}
+		})
+	}
+}
+
+// TestEscapePath tests per-segment path escaping
+func TestEscapePath(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"/dir/file.txt", "/dir/file.txt"},
+		{"/dir/my file (v2)+final.txt", "/dir/my%20file%20%28v2%29+final.txt"},
+		{"/dir/my%20file%20%28v2%29+final.txt", "/dir/my%20file%20%28v2%29+final.txt"},
+		{"/dir/100%done.bin", "/dir/100%25done.bin"},
+		{"/dir/100%25done.bin", "/dir/100%25done.bin"},
+		{"/a?b/c#d", "/a%3Fb/c%23d"},
+	}
+
+	for _, tt := range tests {
+		if got := EscapePath(tt.input); got != tt.expected {
+			t.Errorf("EscapePath(%q) = %q, want %q", tt.input, got, tt.expected)
+		}
+	}
+}
+
 // TestDownloadAssetError tests download error handling
 func TestDownloadAssetError(t *testing.T) {
 	server := NewMockNexusServer()
@@ -259,6 +987,152 @@ func TestBuildRawUploadForm(t *testing.T) {
 	}
 }
 
+// TestFormStreamUploadsLargeFileInConstantMemory uploads a large file through a streamed
+// form and checks that memory allocated during the upload stays far below its size
+func TestFormStreamUploadsLargeFileInConstantMemory(t *testing.T) {
+	const size = 128 << 20
+
+	// A sparse file reads as zeros without taking up disk space
+	path := filepath.Join(t.TempDir(), "large.bin")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	if err := f.Truncate(size); err != nil {
+		t.Fatalf("Failed to size file: %v", err)
+	}
+	f.Close()
+
+	var received int64
+	var filename string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reader, err := r.MultipartReader()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if part.FormName() == "raw.asset1" {
+				received, err = io.Copy(io.Discard, part)
+			} else if part.FormName() == "raw.asset1.filename" {
+				var value []byte
+				value, err = io.ReadAll(part)
+				filename = string(value)
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, "user", "pass")
+	files := []FileUpload{{FilePath: path, RelativePath: "large.bin"}}
+
+	runtime.GC()
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+
+	form := NewFormStream(func(writer *multipart.Writer) error {
+		return BuildRawUploadForm(writer, files, "", nil, nil, nil)
+	})
+	err = client.UploadComponent("raw-repo", form, form.ContentType())
+	if formErr := form.Close(); formErr != nil {
+		t.Fatalf("Writing the form failed: %v", formErr)
+	}
+	if err != nil {
+		t.Fatalf("UploadComponent failed: %v", err)
+	}
+
+	runtime.ReadMemStats(&after)
+	if received != size || filename != "large.bin" {
+		t.Fatalf("Expected %d bytes of large.bin, server received %d bytes of '%s'", size, received, filename)
+	}
+	// The client and the test server share the process, so this covers both sides
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 16<<20 {
+		t.Errorf("Uploading %d bytes allocated %d bytes; the form is being buffered", size, allocated)
+	}
+}
+
+// TestFormStreamBuildErrorAbortsUpload checks that a form whose file cannot be read is
+// never sent as a complete request, and that the read error is reported
+func TestFormStreamBuildErrorAbortsUpload(t *testing.T) {
+	complete := make(chan bool, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := io.Copy(io.Discard, r.Body)
+		complete <- err == nil
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	readErr := errors.New("disk read failed")
+	form := NewFormStream(func(writer *multipart.Writer) error {
+		part, err := writer.CreateFormFile("raw.asset1", "file.bin")
+		if err != nil {
+			return err
+		}
+		if _, err := part.Write(bytes.Repeat([]byte("x"), 64<<10)); err != nil {
+			return err
+		}
+		return readErr
+	})
+
+	client := NewClient(server.URL, "user", "pass")
+	err := client.UploadComponent("raw-repo", form, form.ContentType())
+	if err == nil {
+		t.Fatal("Expected the upload to fail")
+	}
+	if formErr := form.Close(); !errors.Is(formErr, readErr) {
+		t.Errorf("Expected form error %v, got %v", readErr, formErr)
+	}
+
+	select {
+	case ok := <-complete:
+		if ok {
+			t.Error("Server received a complete request body")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Server did not see the aborted request")
+	}
+}
+
+// TestFormStreamContentType checks that the Content-Type carries the boundary used in the body
+func TestFormStreamContentType(t *testing.T) {
+	form := NewFormStream(func(writer *multipart.Writer) error {
+		return writer.WriteField("raw.directory", "dir")
+	})
+	body, err := io.ReadAll(form)
+	if err != nil {
+		t.Fatalf("Failed to read form: %v", err)
+	}
+	if err := form.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	_, params, err := mime.ParseMediaType(form.ContentType())
+	if err != nil {
+		t.Fatalf("Invalid Content-Type '%s': %v", form.ContentType(), err)
+	}
+	reader := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+	part, err := reader.NextPart()
+	if err != nil || part.FormName() != "raw.directory" {
+		t.Fatalf("Expected raw.directory part, got %v (%v)", part, err)
+	}
+	if _, err := reader.NextPart(); err != io.EOF {
+		t.Errorf("Expected form to be terminated, got %v", err)
+	}
+}
+
 // TestBuildAptUploadForm tests building multipart form for APT (Debian) package upload
 func TestBuildAptUploadForm(t *testing.T) {
 	// Create a test .deb file
@@ -529,3 +1403,39 @@ func TestSearchAssetsForCompletionWithoutLeadingSlash(t *testing.T) {
 
 	// Test passes if no error occurred - the function normalizes paths correctly
 }
+
+func TestGetAssetByPathNotFound(t *testing.T) {
+	server := NewMockNexusServer()
+	defer server.Close()
+	server.AddAsset("repo", "/docs/readme.txt", Asset{}, nil)
+
+	client := NewClient(server.URL, "testuser", "testpass")
+	if _, err := client.GetAssetByPath("repo", "docs/readme.txt"); err != nil {
+		t.Errorf("Expected the asset to be found without a leading slash: %v", err)
+	}
+	if _, err := client.GetAssetByPath("repo", "/docs"); !errors.Is(err, ErrAssetNotFound) {
+		t.Errorf("Expected ErrAssetNotFound for a folder, got %v", err)
+	}
+}
+
+func TestWithoutAttributeSidecars(t *testing.T) {
+	assets := []Asset{{Path: "/dist/app.bin"}, {Path: "/dist/app.bin.meta.json"}, {Path: "/dist/orphan.meta.json"}}
+	var paths []string
+	for _, asset := range WithoutAttributeSidecars(assets) {
+		paths = append(paths, asset.Path)
+	}
+	if strings.Join(paths, ",") != "/dist/app.bin,/dist/orphan.meta.json" {
+		t.Errorf("Expected only the sidecar of a listed asset to be left out, got %v", paths)
+	}
+}
+
+func TestWithoutMetadataManifests(t *testing.T) {
+	assets := []Asset{{Path: "/dist/.nexus-meta.json"}, {Path: "/dist/app.bin"}, {Path: "/dist/sub/.nexus-meta.json"}, {Path: "/dist/app.nexus-meta.json"}}
+	var paths []string
+	for _, asset := range WithoutMetadataManifests(assets) {
+		paths = append(paths, asset.Path)
+	}
+	if strings.Join(paths, ",") != "/dist/app.bin,/dist/app.nexus-meta.json" {
+		t.Errorf("Expected the metadata manifests to be left out, got %v", paths)
+	}
+}
@@ -0,0 +1,81 @@
+package nexusapi
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+// APIStats counts the bytes of JSON responses received from the REST API, as transferred
+// and after decompression. Asset downloads are not included.
+type APIStats struct {
+	wire    atomic.Int64
+	decoded atomic.Int64
+}
+
+// WireBytes returns the number of response bytes received, compressed or not
+func (s *APIStats) WireBytes() int64 {
+	return s.wire.Load()
+}
+
+// DecodedBytes returns the number of JSON bytes after decompression
+func (s *APIStats) DecodedBytes() int64 {
+	return s.decoded.Load()
+}
+
+// newAPIRequest creates an authenticated request to the REST API. The JSON responses of
+// the search and repository endpoints get large and compress well, so gzip is requested
+// explicitly and decoded by decodeJSON.
+func (c *Client) newAPIRequest(method, url string) (*http.Request, error) {
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	c.authenticate(req)
+	req.Header.Set("Accept-Encoding", "gzip")
+	return req, nil
+}
+
+// decodeJSON decodes the body of a REST API response into v, decompressing it if the
+// server gzip encoded it
+func (c *Client) decodeJSON(resp *http.Response, v interface{}) error {
+	wire := &countingReader{r: resp.Body}
+	body, err := decodedBody(resp, wire)
+	if err != nil {
+		return err
+	}
+	decoded := &countingReader{r: body}
+	err = json.NewDecoder(decoded).Decode(v)
+	c.Stats.wire.Add(wire.n.Load())
+	c.Stats.decoded.Add(decoded.n.Load())
+	return err
+}
+
+// decodedBody returns a reader for r, the body of resp, that undoes its Content-Encoding
+func decodedBody(resp *http.Response, r io.Reader) (io.Reader, error) {
+	if !strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		return r, nil
+	}
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress response: %w", err)
+	}
+	return gz, nil
+}
+
+// countingReader counts the bytes read through it. The count may be read while another
+// goroutine reads, e.g. the transport sending a request body.
+type countingReader struct {
+	r io.Reader
+	n atomic.Int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n.Add(int64(n))
+	return n, err
+}
@@ -0,0 +1,142 @@
+package nexusapi
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/tympanix/nexus-cli/internal/util"
+)
+
+// ErrAssetNotFound is returned by GetAssetByPath when no asset has the given path
+var ErrAssetNotFound = errors.New("asset not found")
+
+// maxErrorBodySize is how much of an error response body is kept in an HTTPError
+const maxErrorBodySize = 500
+
+// requestIDHeaders are response headers that servers and proxies commonly use to
+// correlate a request with their logs, in order of preference
+var requestIDHeaders = []string{"X-Request-Id", "X-Correlation-Id", "X-Amzn-Trace-Id", "Request-Id"}
+
+// HTTPError is returned when Nexus answers a request with an unexpected status.
+// It carries enough detail to find the request in the server logs.
+type HTTPError struct {
+	Op         string // What the client was doing, e.g. "list assets"
+	Method     string
+	URL        string // Request URL with any credentials removed
+	StatusCode int
+	Body       string // Start of the response body, at most maxErrorBodySize bytes
+	RequestID  string // Correlation ID from the response headers, if the server sent one
+}
+
+func (e *HTTPError) Error() string {
+	msg := fmt.Sprintf("failed to %s: %s %s: status %d", e.Op, e.Method, e.URL, e.StatusCode)
+	if e.RequestID != "" {
+		msg += fmt.Sprintf(" (request id %s)", e.RequestID)
+	}
+	if e.Body != "" {
+		msg += ": " + e.Body
+	}
+	return msg
+}
+
+// newHTTPError builds an HTTPError from a failed response. It consumes the start of the body.
+func newHTTPError(op string, resp *http.Response) *HTTPError {
+	err := &HTTPError{
+		Op:         op,
+		StatusCode: resp.StatusCode,
+	}
+	if req := resp.Request; req != nil {
+		err.Method = req.Method
+		redacted := *req.URL
+		redacted.User = nil
+		err.URL = redacted.String()
+	}
+	for _, header := range requestIDHeaders {
+		if id := resp.Header.Get(header); id != "" {
+			err.RequestID = id
+			break
+		}
+	}
+
+	var reader io.Reader = resp.Body
+	if decoded, err := decodedBody(resp, resp.Body); err == nil {
+		reader = decoded
+	}
+	body, _ := io.ReadAll(io.LimitReader(reader, maxErrorBodySize+1))
+	truncated := len(body) > maxErrorBodySize
+	if truncated {
+		body = body[:maxErrorBodySize]
+	}
+	// Truncation may cut a multi-byte character in half
+	err.Body = strings.TrimSpace(strings.ToValidUTF8(string(body), ""))
+	if truncated {
+		err.Body += "..."
+	}
+	return err
+}
+
+// NotWritableError is returned by CheckWritable for a repository that rejects uploads
+type NotWritableError struct {
+	Repository string
+	Reason     string // Why uploads are rejected, e.g. "offline" or "read-only (write policy DENY)"
+	// WritableMember is the first member of a group repository that accepts uploads, if any
+	WritableMember string
+}
+
+func (e *NotWritableError) Error() string {
+	if e.WritableMember != "" {
+		return fmt.Sprintf("repository '%s' is %s; uploads will be rejected, upload to its member '%s' instead", e.Repository, e.Reason, e.WritableMember)
+	}
+	return fmt.Sprintf("repository '%s' is %s; uploads will be rejected", e.Repository, e.Reason)
+}
+
+// RequestTooLargeError is returned by UploadComponent when Nexus, or a proxy in front of
+// it, rejects an upload request for its size
+type RequestTooLargeError struct {
+	Repository string
+	Sent       int64 // Request bytes sent before the rejection, roughly the server limit
+	HTTPError  *HTTPError
+}
+
+func (e *RequestTooLargeError) Error() string {
+	limit := "unknown"
+	if e.Sent > 0 {
+		limit = "~" + util.FormatByteSize(e.Sent)
+	}
+	return fmt.Sprintf("upload to repository '%s' failed: request too large (server limit %s); retry with smaller batches or --chunked", e.Repository, limit)
+}
+
+func (e *RequestTooLargeError) Unwrap() error {
+	return e.HTTPError
+}
+
+// tooLargeMarkers are phrases of the error pages that servers and proxies such as nginx
+// send for an oversized request, sometimes under another status than 413
+var tooLargeMarkers = []string{"request entity too large", "payload too large", "content too large", "client intended to send too large body"}
+
+// isRequestTooLarge reports whether a request was rejected for its size
+func isRequestTooLarge(err *HTTPError) bool {
+	if err.StatusCode == http.StatusRequestEntityTooLarge {
+		return true
+	}
+	body := strings.ToLower(err.Body)
+	for _, marker := range tooLargeMarkers {
+		if strings.Contains(body, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// isRetryable reports whether a failed request may succeed when repeated. Server errors,
+// rate limiting and transport errors are retryable; other client errors are not.
+func isRetryable(err error) bool {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode >= 500 || httpErr.StatusCode == http.StatusTooManyRequests
+	}
+	return true
+}
@@ -0,0 +1,231 @@
+package nexusapi
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Request kinds that RequestMetrics reports separately
+const (
+	RequestKindSearch   = "search"
+	RequestKindUpload   = "upload"
+	RequestKindDownload = "download"
+	RequestKindAPI      = "api" // Any other REST API request, e.g. repositories or status
+)
+
+// RequestMetrics aggregates connection and request timings of every request made by
+// clients created while it is enabled, to tell slow TLS handshakes from search latency or
+// low throughput. It is safe for concurrent use.
+type RequestMetrics struct {
+	mu    sync.Mutex
+	kinds map[string]*RequestTimings
+}
+
+// RequestTimings are the summed timings of the requests of one kind. DNS, connect and TLS
+// only count for requests that opened a new connection; TTFB runs from sending the
+// request to the first response byte and transfer from there until the body is closed.
+type RequestTimings struct {
+	Requests       int           `json:"requests"`
+	NewConnections int           `json:"new_connections"`
+	DNS            time.Duration `json:"dns_ns"`
+	Connect        time.Duration `json:"connect_ns"`
+	TLS            time.Duration `json:"tls_ns"`
+	TTFB           time.Duration `json:"ttfb_ns"`
+	Transfer       time.Duration `json:"transfer_ns"`
+	Throttled      int           `json:"throttled"` // Responses with 429 Too Many Requests
+}
+
+var (
+	metricsMu      sync.Mutex
+	defaultMetrics *RequestMetrics
+)
+
+// EnableRequestMetrics makes clients created from now on record their request timings
+// and returns the collector. Clients created before, and all clients while it is not
+// enabled, use the plain http.DefaultClient and pay nothing for it.
+func EnableRequestMetrics() *RequestMetrics {
+	metricsMu.Lock()
+	defer metricsMu.Unlock()
+	if defaultMetrics == nil {
+		defaultMetrics = newRequestMetrics()
+	}
+	return defaultMetrics
+}
+
+func newRequestMetrics() *RequestMetrics {
+	return &RequestMetrics{kinds: make(map[string]*RequestTimings)}
+}
+
+// enabledRequestMetrics returns the collector of EnableRequestMetrics, or nil
+func enabledRequestMetrics() *RequestMetrics {
+	metricsMu.Lock()
+	defer metricsMu.Unlock()
+	return defaultMetrics
+}
+
+// Snapshot returns a copy of the timings by request kind
+func (m *RequestMetrics) Snapshot() map[string]RequestTimings {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	snapshot := make(map[string]RequestTimings, len(m.kinds))
+	for kind, timings := range m.kinds {
+		snapshot[kind] = *timings
+	}
+	return snapshot
+}
+
+// String formats the timings as one line per request kind with the average of each phase,
+// or "" if no request was made
+func (m *RequestMetrics) String() string {
+	snapshot := m.Snapshot()
+	kinds := make([]string, 0, len(snapshot))
+	for kind := range snapshot {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+
+	var b strings.Builder
+	for _, kind := range kinds {
+		t := snapshot[kind]
+		fmt.Fprintf(&b, "Requests %s: %d, new connections: %d", kind, t.Requests, t.NewConnections)
+		if t.NewConnections > 0 {
+			n := time.Duration(t.NewConnections)
+			fmt.Fprintf(&b, ", avg dns: %s, avg connect: %s, avg tls: %s", roundTiming(t.DNS/n), roundTiming(t.Connect/n), roundTiming(t.TLS/n))
+		}
+		n := time.Duration(t.Requests)
+		fmt.Fprintf(&b, ", avg ttfb: %s, avg transfer: %s, total transfer: %s", roundTiming(t.TTFB/n), roundTiming(t.Transfer/n), roundTiming(t.Transfer))
+		if t.Throttled > 0 {
+			fmt.Fprintf(&b, ", throttled: %d", t.Throttled)
+		}
+		b.WriteString("\n")
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func roundTiming(d time.Duration) time.Duration {
+	if d >= time.Second {
+		return d.Round(time.Millisecond)
+	}
+	return d.Round(10 * time.Microsecond)
+}
+
+func (m *RequestMetrics) add(kind string, t RequestTimings) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	total, ok := m.kinds[kind]
+	if !ok {
+		total = &RequestTimings{}
+		m.kinds[kind] = total
+	}
+	total.Requests += t.Requests
+	total.NewConnections += t.NewConnections
+	total.DNS += t.DNS
+	total.Connect += t.Connect
+	total.TLS += t.TLS
+	total.TTFB += t.TTFB
+	total.Transfer += t.Transfer
+	total.Throttled += t.Throttled
+}
+
+// requestKind classifies a request by its endpoint
+func requestKind(req *http.Request) string {
+	switch {
+	case strings.Contains(req.URL.Path, "/service/rest/v1/search/"):
+		return RequestKindSearch
+	case strings.Contains(req.URL.Path, "/service/rest/v1/components"):
+		return RequestKindUpload
+	case strings.Contains(req.URL.Path, "/service/rest/"):
+		return RequestKindAPI
+	}
+	return RequestKindDownload
+}
+
+// metricsTransport records the timings of each request with httptrace
+type metricsTransport struct {
+	base    http.RoundTripper
+	metrics *RequestMetrics
+}
+
+func (t *metricsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	rt := &requestTrace{}
+	trace := &httptrace.ClientTrace{
+		DNSStart:          func(httptrace.DNSStartInfo) { rt.mark(&rt.dnsStart) },
+		DNSDone:           func(httptrace.DNSDoneInfo) { rt.since(&rt.dnsStart, &rt.timings.DNS) },
+		ConnectStart:      func(string, string) { rt.mark(&rt.connectStart) },
+		ConnectDone:       func(string, string, error) { rt.since(&rt.connectStart, &rt.timings.Connect) },
+		TLSHandshakeStart: func() { rt.mark(&rt.tlsStart) },
+		TLSHandshakeDone:  func(tls.ConnectionState, error) { rt.since(&rt.tlsStart, &rt.timings.TLS) },
+		GotConn: func(info httptrace.GotConnInfo) {
+			if !info.Reused {
+				rt.mu.Lock()
+				rt.timings.NewConnections = 1
+				rt.mu.Unlock()
+			}
+		},
+		GotFirstResponseByte: func() { rt.mark(&rt.firstByte) },
+	}
+
+	rt.start = time.Now()
+	resp, err := t.base.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+	finish := func() { t.metrics.add(requestKind(req), rt.finish()) }
+	if err != nil {
+		finish()
+		return nil, err
+	}
+	resp.Body = &metricsBody{ReadCloser: resp.Body, finish: finish}
+	return resp, nil
+}
+
+// requestTrace collects the timings of one request. The httptrace hooks may run on other
+// goroutines than the request.
+type requestTrace struct {
+	mu                                                 sync.Mutex
+	timings                                            RequestTimings
+	start, dnsStart, connectStart, tlsStart, firstByte time.Time
+}
+
+func (r *requestTrace) mark(t *time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	*t = time.Now()
+}
+
+func (r *requestTrace) since(start *time.Time, total *time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	*total += time.Since(*start)
+}
+
+// finish ends the request and returns its timings
+func (r *requestTrace) finish() RequestTimings {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.timings.Requests = 1
+	if r.firstByte.IsZero() {
+		r.timings.TTFB = time.Since(r.start)
+	} else {
+		r.timings.TTFB = r.firstByte.Sub(r.start)
+		r.timings.Transfer = time.Since(r.firstByte)
+	}
+	return r.timings
+}
+
+// metricsBody records the timings of its request once it is closed
+type metricsBody struct {
+	io.ReadCloser
+	once   sync.Once
+	finish func()
+}
+
+func (b *metricsBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.finish)
+	return err
+}
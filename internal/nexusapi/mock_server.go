@@ -1,6 +1,8 @@
 package nexusapi
 
 import (
+	"bytes"
+	"compress/gzip"
 	"crypto/md5"
 	"crypto/sha1"
 	"crypto/sha256"
@@ -10,10 +12,13 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	pathpkg "path"
 	"regexp"
 	"sort"
 	"strings"
 	"sync"
+	"time"
 )
 
 // MockNexusServer provides a high-level mock Nexus server for testing
@@ -30,58 +35,222 @@ type MockNexusServer struct {
 	ContinuationTokens map[string]string
 	// Repositories stores the repositories that will be returned by ListRepositories
 	Repositories []Repository
+	// RepositoryStatuses stores the repository settings returned by GetRepositoryStatus.
+	// Listing assets of an offline repository, or of a group with an offline member, fails.
+	RepositoryStatuses []RepositoryStatus
+	// StoreUploads adds uploaded files to Assets, so they can be listed and downloaded again
+	StoreUploads bool
 
 	// Captured data from requests
 	UploadedFiles  []UploadedFile
+	UploadRequests int      // Number of multipart upload requests received
+	StatusRequests int      // Number of repository settings listings received
+	DeletedAssets  []string // IDs of assets removed through DeleteAsset
 	RequestCount   int
+	DownloadCount  int
 	LastUploadRepo string
 	LastListRepo   string
 	LastListPath   string
+	LastListQuery  url.Values  // Query parameters of the last asset listing request
+	LastHeader     http.Header // Headers of the last request, e.g. to check its credentials
 
 	// Error configuration
 	RepositoryNotFoundList map[string]bool
+	// ListFailures maps a continuation token ("" for the first page) to the number of
+	// times an asset listing request for that page fails before succeeding
+	ListFailures map[string]int
+	// Unavailable is the number of status checks answered with 503 before the server is
+	// available. ReadOnly does the same for the writable status, which also waits for
+	// the server to be available.
+	Unavailable int
+	ReadOnly    int
+	// RateLimited is the number of requests, of any kind, answered with 429 Too Many
+	// Requests, like a rate limit in front of Nexus. RetryAfter is sent as the Retry-After
+	// header of those responses if set. ThrottledRequests counts the 429 responses sent.
+	RateLimited       int
+	RetryAfter        string
+	ThrottledRequests int
+	// MaxRequestBytes rejects upload requests with a larger body with the 413 error page
+	// of nginx, like a proxy with client_max_body_size in front of Nexus
+	MaxRequestBytes int64
+	RejectedUploads int // Number of upload requests rejected by MaxRequestBytes
+	// Tagging enables the tagging API of Nexus Repository Pro, which answers 404 otherwise.
+	// Tags stores the tags by name and TagAssociations the components associated with
+	// each tag, as "repository:name".
+	Tagging         bool
+	Tags            map[string]Tag
+	TagAssociations map[string][]string
+	// ServerCopy enables copying assets on the server through POST
+	// /service/rest/v1/assets/{id}/copy, which answers 404 otherwise. ServerCopies counts
+	// the assets copied that way.
+	ServerCopy   bool
+	ServerCopies int
+	// NoRanges answers ranged downloads with the whole asset, like a proxy that drops the
+	// Range header. DownloadRanges records the Range header of every ranged download.
+	NoRanges       bool
+	DownloadRanges []string
 }
 
+// nginxTooLargePage is the error page nginx sends for a body above client_max_body_size
+const nginxTooLargePage = `<html>
+<head><title>413 Request Entity Too Large</title></head>
+<body>
+<center><h1>413 Request Entity Too Large</h1></center>
+<hr><center>nginx/1.25.3</center>
+</body>
+</html>
+`
+
 // UploadedFile represents a file that was uploaded to the mock server
 type UploadedFile struct {
-	Filename   string
-	Content    []byte
-	Repository string
+	Filename    string
+	Path        string // Remote path assembled from raw.directory and raw.assetN.filename
+	Directory   string // raw.directory of the request, "" if it was not sent
+	FormName    string // raw.assetN.filename of the file, the path below Directory
+	Content     []byte
+	ContentType string // Content-Type of the form part
+	Repository  string
+	Request     int // Number of the upload request that carried the file, counting from 1
 }
 
 // NewMockNexusServer creates a new mock Nexus server
 func NewMockNexusServer() *MockNexusServer {
+	return newMockNexusServer(httptest.NewServer)
+}
+
+// NewMockNexusTLSServer is like NewMockNexusServer, but serves HTTPS with a self-signed
+// certificate, see Certificate
+func NewMockNexusTLSServer() *MockNexusServer {
+	return newMockNexusServer(httptest.NewTLSServer)
+}
+
+func newMockNexusServer(start func(http.Handler) *httptest.Server) *MockNexusServer {
 	mock := &MockNexusServer{
 		Assets:                 make(map[string]Asset),
 		AssetContent:           make(map[string][]byte),
 		ContinuationTokens:     make(map[string]string),
 		UploadedFiles:          make([]UploadedFile, 0),
 		RepositoryNotFoundList: make(map[string]bool),
+		ListFailures:           make(map[string]int),
 		Repositories:           make([]Repository, 0),
+		Tags:                   make(map[string]Tag),
+		TagAssociations:        make(map[string][]string),
 	}
 
-	mock.Server = httptest.NewServer(http.HandlerFunc(mock.handler))
+	mock.Server = start(http.HandlerFunc(mock.handler))
 	return mock
 }
 
+// gzipResponseWriter gzip encodes a response if it is successful. Error responses are
+// sent uncompressed.
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	gz          *gzip.Writer
+	wroteHeader bool
+}
+
+func (w *gzipResponseWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	if status == http.StatusOK {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Header().Del("Content-Length")
+		w.gz = gzip.NewWriter(w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *gzipResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.gz != nil {
+		return w.gz.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *gzipResponseWriter) close() {
+	if w.gz != nil {
+		w.gz.Close()
+	}
+}
+
 // handler is the main HTTP handler for the mock server
 func (m *MockNexusServer) handler(w http.ResponseWriter, r *http.Request) {
 	m.mu.Lock()
 	m.RequestCount++
+	m.LastHeader = r.Header.Clone()
+	if m.RateLimited > 0 {
+		m.RateLimited--
+		m.ThrottledRequests++
+		retryAfter := m.RetryAfter
+		m.mu.Unlock()
+		if retryAfter != "" {
+			w.Header().Set("Retry-After", retryAfter)
+		}
+		http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+		return
+	}
 	m.mu.Unlock()
 
+	// Like Nexus, compress successful REST API responses for clients that accept gzip
+	if r.Method == "GET" && strings.Contains(r.URL.Path, "/service/rest/") && strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+		gw := &gzipResponseWriter{ResponseWriter: w}
+		defer gw.close()
+		w = gw
+	}
+
 	// Handle upload requests
 	if r.Method == "POST" && strings.Contains(r.URL.Path, "/service/rest/v1/components") {
 		m.handleUpload(w, r)
 		return
 	}
 
+	// Handle tagging requests
+	if strings.Contains(r.URL.Path, "/service/rest/v1/tags") {
+		m.handleTags(w, r)
+		return
+	}
+
+	// Handle server-side copy requests
+	if r.Method == "POST" && strings.Contains(r.URL.Path, "/service/rest/v1/assets/") && strings.HasSuffix(r.URL.Path, "/copy") {
+		m.handleCopyAsset(w, r)
+		return
+	}
+
+	// Handle asset deletion requests
+	if r.Method == "DELETE" && strings.Contains(r.URL.Path, "/service/rest/v1/assets/") {
+		m.handleDeleteAsset(w, r)
+		return
+	}
+
+	// Handle server status requests
+	if r.Method == "GET" && (strings.HasSuffix(r.URL.Path, "/service/rest/v1/status") || strings.HasSuffix(r.URL.Path, "/service/rest/v1/status/writable")) {
+		m.handleStatus(w, r)
+		return
+	}
+
+	// Handle single repository requests
+	if r.Method == "GET" && strings.Contains(r.URL.Path, "/service/rest/v1/repositories/") {
+		m.handleGetRepository(w, r)
+		return
+	}
+
 	// Handle repository listing requests
 	if r.Method == "GET" && strings.Contains(r.URL.Path, "/service/rest/v1/repositories") {
 		m.handleListRepositories(w, r)
 		return
 	}
 
+	// Handle repository status requests
+	if r.Method == "GET" && strings.Contains(r.URL.Path, "/service/rest/v1/repositorySettings") {
+		m.handleRepositorySettings(w, r)
+		return
+	}
+
 	// Handle asset listing requests
 	if r.Method == "GET" && strings.Contains(r.URL.Path, "/service/rest/v1/search/assets") {
 		m.handleListAssets(w, r)
@@ -97,6 +266,25 @@ func (m *MockNexusServer) handler(w http.ResponseWriter, r *http.Request) {
 	http.NotFound(w, r)
 }
 
+// handleStatus answers status checks, counting down Unavailable and ReadOnly
+func (m *MockNexusServer) handleStatus(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	available := m.Unavailable == 0
+	if !available {
+		m.Unavailable--
+	}
+	if strings.HasSuffix(r.URL.Path, "/writable") && available && m.ReadOnly > 0 {
+		m.ReadOnly--
+		available = false
+	}
+	if !available {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
 // handleUpload handles file upload requests
 func (m *MockNexusServer) handleUpload(w http.ResponseWriter, r *http.Request) {
 	repository := r.URL.Query().Get("repository")
@@ -113,6 +301,37 @@ func (m *MockNexusServer) handleUpload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Reject uploads to repositories that do not accept them, the way Nexus does
+	m.mu.RLock()
+	rejected := false
+	for _, status := range m.RepositoryStatuses {
+		if status.Name == repository {
+			rejected = !status.Online || (status.Storage != nil && status.Storage.WritePolicy == "DENY")
+		}
+	}
+	m.mu.RUnlock()
+	if rejected {
+		http.Error(w, "Repository does not allow updating assets: "+repository, http.StatusBadRequest)
+		return
+	}
+
+	m.mu.RLock()
+	maxRequestBytes := m.MaxRequestBytes
+	m.mu.RUnlock()
+	if maxRequestBytes > 0 {
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxRequestBytes+1))
+		if err == nil && int64(len(body)) > maxRequestBytes {
+			m.mu.Lock()
+			m.RejectedUploads++
+			m.mu.Unlock()
+			w.Header().Set("Content-Type", "text/html")
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			w.Write([]byte(nginxTooLargePage))
+			return
+		}
+		r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), r.Body))
+	}
+
 	// Parse multipart form (ignore errors for non-multipart content)
 	err := r.ParseMultipartForm(32 << 20)
 	if err != nil {
@@ -121,6 +340,11 @@ func (m *MockNexusServer) handleUpload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	m.mu.Lock()
+	m.UploadRequests++
+	request := m.UploadRequests
+	m.mu.Unlock()
+
 	// Capture uploaded files
 	for key := range r.MultipartForm.File {
 		if strings.HasPrefix(key, "raw.asset") || strings.HasPrefix(key, "apt.asset") || strings.HasPrefix(key, "yum.asset") {
@@ -134,38 +358,280 @@ func (m *MockNexusServer) handleUpload(w http.ResponseWriter, r *http.Request) {
 				continue
 			}
 
+			formName := r.FormValue(key + ".filename")
+			directory := r.FormValue("raw.directory")
+			remotePath := formName
+			if remotePath != "" {
+				remotePath = pathpkg.Join("/", directory, remotePath)
+			}
+
 			m.mu.Lock()
 			m.UploadedFiles = append(m.UploadedFiles, UploadedFile{
-				Filename:   header.Filename,
-				Content:    content,
-				Repository: repository,
+				Filename:    header.Filename,
+				Path:        remotePath,
+				Directory:   directory,
+				FormName:    formName,
+				Content:     content,
+				ContentType: header.Header.Get("Content-Type"),
+				Repository:  repository,
+				Request:     request,
 			})
+			store := m.StoreUploads
 			m.mu.Unlock()
+			if store && remotePath != "" {
+				m.AddAsset(repository, remotePath, Asset{}, content)
+			}
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleTags handles the tagging API: listing, getting, creating and updating tags, and
+// associating a tag with the component of a repository that has the given name
+func (m *MockNexusServer) handleTags(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.Tagging {
+		http.NotFound(w, r)
+		return
+	}
+
+	rest := strings.Trim(r.URL.Path[strings.Index(r.URL.Path, "/service/rest/v1/tags")+len("/service/rest/v1/tags"):], "/")
+	switch {
+	case r.Method == "GET" && rest == "":
+		tags := make([]Tag, 0, len(m.Tags))
+		for _, tag := range m.Tags {
+			tags = append(tags, tag)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"items": tags})
+	case r.Method == "POST" && rest == "":
+		var tag Tag
+		if err := json.NewDecoder(r.Body).Decode(&tag); err != nil || tag.Name == "" {
+			http.Error(w, "invalid tag", http.StatusBadRequest)
+			return
+		}
+		if _, exists := m.Tags[tag.Name]; exists {
+			http.Error(w, "tag already exists", http.StatusBadRequest)
+			return
+		}
+		m.Tags[tag.Name] = tag
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(tag)
+	case strings.HasPrefix(rest, "associate/") && r.Method == "POST":
+		name := strings.TrimPrefix(rest, "associate/")
+		if _, exists := m.Tags[name]; !exists {
+			http.NotFound(w, r)
+			return
+		}
+		repository := r.URL.Query().Get("repository")
+		component := strings.TrimPrefix(r.URL.Query().Get("name"), "/")
+		if _, exists := m.Assets[repository+":/"+component]; !exists {
+			http.Error(w, "no components found", http.StatusNotFound)
+			return
+		}
+		m.TagAssociations[name] = append(m.TagAssociations[name], repository+":"+component)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"status": 200, "message": "Association successful"})
+	case r.Method == "GET":
+		tag, exists := m.Tags[rest]
+		if !exists {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(tag)
+	case r.Method == "PUT":
+		tag, exists := m.Tags[rest]
+		if !exists {
+			http.NotFound(w, r)
+			return
+		}
+		var update Tag
+		if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+			http.Error(w, "invalid tag", http.StatusBadRequest)
+			return
+		}
+		tag.Attributes = update.Attributes
+		m.Tags[rest] = tag
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(tag)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// handleDeleteAsset handles asset deletion requests
+func (m *MockNexusServer) handleDeleteAsset(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Path[strings.Index(r.URL.Path, "/service/rest/v1/assets/")+len("/service/rest/v1/assets/"):]
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for key, asset := range m.Assets {
+		if asset.ID == id {
+			delete(m.Assets, key)
+			m.DeletedAssets = append(m.DeletedAssets, id)
+			w.WriteHeader(http.StatusNoContent)
+			return
 		}
 	}
+	http.NotFound(w, r)
+}
 
+// handleCopyAsset copies an asset and its content to the repository and path of the
+// JSON body, if ServerCopy is set
+func (m *MockNexusServer) handleCopyAsset(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimSuffix(r.URL.Path[strings.Index(r.URL.Path, "/service/rest/v1/assets/")+len("/service/rest/v1/assets/"):], "/copy")
+	var target struct {
+		Repository string `json:"repository"`
+		Path       string `json:"path"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&target); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	m.mu.Lock()
+	if !m.ServerCopy {
+		m.mu.Unlock()
+		http.NotFound(w, r)
+		return
+	}
+	var content []byte
+	found := false
+	for _, asset := range m.Assets {
+		if asset.ID == id {
+			content, found = m.AssetContent[asset.DownloadURL], true
+			break
+		}
+	}
+	if found {
+		m.ServerCopies++
+	}
+	m.mu.Unlock()
+	if !found {
+		http.NotFound(w, r)
+		return
+	}
+	m.AddAsset(target.Repository, target.Path, Asset{}, content)
 	w.WriteHeader(http.StatusNoContent)
 }
 
 // handleListRepositories handles repository listing requests
 func (m *MockNexusServer) handleListRepositories(w http.ResponseWriter, r *http.Request) {
 	m.mu.RLock()
-	repos := m.Repositories
+	repos := make([]Repository, len(m.Repositories))
+	for i, repo := range m.Repositories {
+		repos[i] = m.withOnline(repo)
+	}
 	m.mu.RUnlock()
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(repos)
 }
 
+// withOnline sets the online state of repo from RepositoryStatuses unless it is set
+// already. Callers must hold m.mu.
+func (m *MockNexusServer) withOnline(repo Repository) Repository {
+	if repo.Online != nil {
+		return repo
+	}
+	for _, status := range m.RepositoryStatuses {
+		if status.Name == repo.Name {
+			online := status.Online
+			repo.Online = &online
+		}
+	}
+	return repo
+}
+
+// handleGetRepository handles requests for a single repository. Repositories marked with
+// SetRepositoryNotFound do not exist; any other repository not in Repositories is taken
+// from RepositoryStatuses, or reported as a hosted raw repository, like the other handlers
+// treat unknown repositories.
+func (m *MockNexusServer) handleGetRepository(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Path[strings.Index(r.URL.Path, "/service/rest/v1/repositories/")+len("/service/rest/v1/repositories/"):]
+
+	m.mu.RLock()
+	notFound := m.RepositoryNotFoundList[name]
+	repository := Repository{Name: name, Format: "raw", Type: "hosted"}
+	for _, status := range m.RepositoryStatuses {
+		if status.Name == name {
+			repository = Repository{Name: name, Format: status.Format, Type: status.Type}
+		}
+	}
+	for _, repo := range m.Repositories {
+		if repo.Name == name {
+			repository = repo
+		}
+	}
+	repository = m.withOnline(repository)
+	m.mu.RUnlock()
+
+	if notFound {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(repository)
+}
+
+// handleRepositorySettings handles repository status requests
+func (m *MockNexusServer) handleRepositorySettings(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	m.StatusRequests++
+	statuses := m.RepositoryStatuses
+	m.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(statuses)
+}
+
+// resolveListRepositories returns the repositories whose assets are listed for repository.
+// Like Nexus, which reports the content of a group under its members, searching a group
+// finds nothing. It returns false if the repository or a group member is offline.
+func (m *MockNexusServer) resolveListRepositories(repository string) (map[string]bool, bool) {
+	statuses := make(map[string]RepositoryStatus, len(m.RepositoryStatuses))
+	for _, status := range m.RepositoryStatuses {
+		statuses[status.Name] = status
+	}
+
+	status, known := statuses[repository]
+	if !known {
+		return map[string]bool{repository: true}, true
+	}
+	if !status.Online {
+		return nil, false
+	}
+	if status.Group == nil {
+		return map[string]bool{repository: true}, true
+	}
+	for _, member := range status.Group.MemberNames {
+		if memberStatus, ok := statuses[member]; ok && !memberStatus.Online {
+			return nil, false
+		}
+	}
+	return map[string]bool{}, true
+}
+
 // handleListAssets handles asset listing requests
 func (m *MockNexusServer) handleListAssets(w http.ResponseWriter, r *http.Request) {
 	repository := r.URL.Query().Get("repository")
 	query := r.URL.Query().Get("q")
 	name := r.URL.Query().Get("name")
+	sha256 := r.URL.Query().Get("sha256")
 	continuationToken := r.URL.Query().Get("continuationToken")
 
 	m.mu.Lock()
+	if m.ListFailures[continuationToken] > 0 {
+		m.ListFailures[continuationToken]--
+		m.mu.Unlock()
+		http.Error(w, "temporarily unavailable", http.StatusServiceUnavailable)
+		return
+	}
 	m.LastListRepo = repository
+	m.LastListQuery = r.URL.Query()
 	// Extract path from query (format: /path/*)
 	if len(query) > 2 && strings.HasPrefix(query, "/") && strings.HasSuffix(query, "/*") {
 		m.LastListPath = query[1 : len(query)-2]
@@ -174,6 +640,12 @@ func (m *MockNexusServer) handleListAssets(w http.ResponseWriter, r *http.Reques
 
 	// Filter assets based on repository and query parameters
 	m.mu.RLock()
+	repositories, online := m.resolveListRepositories(repository)
+	if !online {
+		m.mu.RUnlock()
+		http.Error(w, "repository is offline", http.StatusBadGateway)
+		return
+	}
 	var filteredAssets []Asset
 
 	// Collect keys first to ensure consistent ordering
@@ -190,7 +662,7 @@ func (m *MockNexusServer) handleListAssets(w http.ResponseWriter, r *http.Reques
 		asset := m.Assets[key]
 		// Check if asset belongs to the requested repository
 		parts := strings.SplitN(key, ":", 2)
-		if len(parts) != 2 || parts[0] != repository {
+		if len(parts) != 2 || !repositories[parts[0]] {
 			continue
 		}
 
@@ -208,6 +680,10 @@ func (m *MockNexusServer) handleListAssets(w http.ResponseWriter, r *http.Reques
 			matched = matchGlobPattern(query, assetPath)
 		}
 
+		if sha256 != "" && !strings.EqualFold(asset.Checksum.SHA256, sha256) {
+			matched = false
+		}
+
 		if matched {
 			filteredAssets = append(filteredAssets, asset)
 		}
@@ -217,6 +693,8 @@ func (m *MockNexusServer) handleListAssets(w http.ResponseWriter, r *http.Reques
 	pageKey := repository
 	if name != "" {
 		pageKey = repository + ":name=" + name
+	} else if sha256 != "" {
+		pageKey = repository + ":sha256=" + sha256
 	} else if query != "" {
 		pageKey = repository + ":" + query
 	}
@@ -278,7 +756,19 @@ func (m *MockNexusServer) handleDownloadAsset(w http.ResponseWriter, r *http.Req
 		return
 	}
 
+	m.mu.Lock()
+	m.DownloadCount++
+	noRanges := m.NoRanges
+	if rangeHeader := r.Header.Get("Range"); rangeHeader != "" {
+		m.DownloadRanges = append(m.DownloadRanges, rangeHeader)
+	}
+	m.mu.Unlock()
+
 	w.Header().Set("Content-Type", "application/octet-stream")
+	if !noRanges && r.Header.Get("Range") != "" {
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+		return
+	}
 	w.WriteHeader(http.StatusOK)
 	w.Write(content)
 }
@@ -343,7 +833,7 @@ func (m *MockNexusServer) AddAsset(repository, path string, asset Asset, content
 		asset.Repository = repository
 	}
 	if asset.DownloadURL == "" {
-		asset.DownloadURL = m.Server.URL + "/repository/" + repository + normalizedPath
+		asset.DownloadURL = m.Server.URL + "/repository/" + repository + EscapePath(normalizedPath)
 	}
 	if asset.ID == "" {
 		// Generate a default ID from repository and path
@@ -421,6 +911,30 @@ func (m *MockNexusServer) AddAssetForPage(repository, query string, asset Asset,
 	m.AddAsset(repository, path, asset, nil)
 }
 
+// FailListPage makes the next times asset listing requests for the page with the given
+// continuation token ("" for the first page) fail with 503 Service Unavailable
+func (m *MockNexusServer) FailListPage(continuationToken string, times int) {
+	m.mu.Lock()
+	m.ListFailures[continuationToken] = times
+	m.mu.Unlock()
+}
+
+// RateLimit answers the next times requests with 429 Too Many Requests and the given
+// Retry-After header, or none if retryAfter is empty
+func (m *MockNexusServer) RateLimit(times int, retryAfter string) {
+	m.mu.Lock()
+	m.RateLimited = times
+	m.RetryAfter = retryAfter
+	m.mu.Unlock()
+}
+
+// GetThrottledRequests returns the number of requests answered with 429 Too Many Requests
+func (m *MockNexusServer) GetThrottledRequests() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.ThrottledRequests
+}
+
 // Reset clears all stored data in the mock server
 func (m *MockNexusServer) Reset() {
 	m.mu.Lock()
@@ -430,11 +944,22 @@ func (m *MockNexusServer) Reset() {
 	m.AssetContent = make(map[string][]byte)
 	m.ContinuationTokens = make(map[string]string)
 	m.UploadedFiles = make([]UploadedFile, 0)
+	m.DeletedAssets = nil
 	m.RepositoryNotFoundList = make(map[string]bool)
+	m.ListFailures = make(map[string]int)
+	m.RateLimited = 0
+	m.RetryAfter = ""
+	m.ThrottledRequests = 0
+	m.Tags = make(map[string]Tag)
+	m.TagAssociations = make(map[string][]string)
+	m.ServerCopies = 0
+	m.StatusRequests = 0
 	m.RequestCount = 0
+	m.DownloadCount = 0
 	m.LastUploadRepo = ""
 	m.LastListRepo = ""
 	m.LastListPath = ""
+	m.LastListQuery = nil
 }
 
 // GetUploadedFiles returns the list of uploaded files
@@ -444,6 +969,13 @@ func (m *MockNexusServer) GetUploadedFiles() []UploadedFile {
 	return append([]UploadedFile{}, m.UploadedFiles...)
 }
 
+// GetDeletedAssets returns the IDs of assets deleted through the API
+func (m *MockNexusServer) GetDeletedAssets() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]string{}, m.DeletedAssets...)
+}
+
 // GetRequestCount returns the number of requests received
 func (m *MockNexusServer) GetRequestCount() int {
 	m.mu.RLock()
@@ -451,6 +983,27 @@ func (m *MockNexusServer) GetRequestCount() int {
 	return m.RequestCount
 }
 
+// GetLastHeader returns the headers of the last request received
+func (m *MockNexusServer) GetLastHeader() http.Header {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.LastHeader.Clone()
+}
+
+// GetDownloadCount returns the number of asset downloads served
+func (m *MockNexusServer) GetDownloadCount() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.DownloadCount
+}
+
+// GetDownloadRanges returns the Range headers of the ranged downloads received
+func (m *MockNexusServer) GetDownloadRanges() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]string(nil), m.DownloadRanges...)
+}
+
 // SetRepositoryNotFound marks a repository as not found for error testing
 func (m *MockNexusServer) SetRepositoryNotFound(repository string) {
 	m.mu.Lock()
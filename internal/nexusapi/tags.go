@@ -0,0 +1,200 @@
+package nexusapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// AttributeSidecarSuffix is appended to the path of an uploaded file for the sidecar that
+// holds its attributes on servers without tagging
+const AttributeSidecarSuffix = ".meta.json"
+
+// WithoutAttributeSidecars returns assets without the attribute sidecars of other listed
+// assets, which describe those assets and are not files of their own. A file named like a
+// sidecar whose asset is not listed is kept.
+func WithoutAttributeSidecars(assets []Asset) []Asset {
+	listed := make(map[string]bool, len(assets))
+	for _, asset := range assets {
+		listed[strings.TrimPrefix(asset.Path, "/")] = true
+	}
+	files := assets[:0:0]
+	for _, asset := range assets {
+		assetPath := strings.TrimPrefix(asset.Path, "/")
+		if described, ok := strings.CutSuffix(assetPath, AttributeSidecarSuffix); ok && listed[described] {
+			continue
+		}
+		files = append(files, asset)
+	}
+	return files
+}
+
+// MetadataManifestName is the name of the manifest that upload --preserve-mtime writes into
+// each destination folder, recording the modification times and modes of its files
+const MetadataManifestName = ".nexus-meta.json"
+
+// IsMetadataManifest reports whether asset is a metadata manifest
+func IsMetadataManifest(asset Asset) bool {
+	return path.Base(asset.Path) == MetadataManifestName
+}
+
+// WithoutMetadataManifests returns assets without the metadata manifests, which describe
+// the other files of their folder and are not files of their own
+func WithoutMetadataManifests(assets []Asset) []Asset {
+	files := assets[:0:0]
+	for _, asset := range assets {
+		if !IsMetadataManifest(asset) {
+			files = append(files, asset)
+		}
+	}
+	return files
+}
+
+// Tag is a named set of attributes that Nexus Repository Pro associates with components.
+// Nexus accepts any JSON as attribute values; the values set by this client are strings.
+type Tag struct {
+	Name       string                 `json:"name"`
+	Attributes map[string]interface{} `json:"attributes"`
+}
+
+// TaggingSupported reports whether the server has the tagging API of Nexus Repository Pro.
+// Nexus Repository OSS answers 404 for the endpoint.
+func (c *Client) TaggingSupported() (bool, error) {
+	baseURL, err := c.restURL("/service/rest/v1/tags")
+	if err != nil {
+		return false, err
+	}
+
+	req, err := c.newAPIRequest("GET", baseURL.String())
+	if err != nil {
+		return false, err
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, newHTTPError("check tagging support", resp)
+	}
+}
+
+// GetTag returns the tag with the given name, or nil if it does not exist
+func (c *Client) GetTag(name string) (*Tag, error) {
+	tagURL, err := c.tagURL(name)
+	if err != nil {
+		return nil, err
+	}
+	req, err := c.newAPIRequest("GET", tagURL.String())
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, newHTTPError(fmt.Sprintf("get tag '%s'", name), resp)
+	}
+	var tag Tag
+	if err := c.decodeJSON(resp, &tag); err != nil {
+		return nil, err
+	}
+	return &tag, nil
+}
+
+// SetTag replaces the attributes of the tag with the given name, creating the tag if it
+// does not exist yet
+func (c *Client) SetTag(name string, attributes map[string]string) error {
+	tagURL, err := c.tagURL(name)
+	if err != nil {
+		return err
+	}
+	status, err := c.sendJSON("PUT", tagURL.String(), map[string]interface{}{"attributes": attributes}, fmt.Sprintf("update tag '%s'", name), http.StatusOK, http.StatusNotFound)
+	if err != nil || status == http.StatusOK {
+		return err
+	}
+
+	tagURL, err = c.restURL("/service/rest/v1/tags")
+	if err != nil {
+		return err
+	}
+	_, err = c.sendJSON("POST", tagURL.String(), map[string]interface{}{"name": name, "attributes": attributes}, fmt.Sprintf("create tag '%s'", name), http.StatusOK)
+	return err
+}
+
+// AssociateTag associates the tag with the component named componentName in repository.
+// For a RAW repository the component name is the asset path without its leading slash.
+func (c *Client) AssociateTag(name, repository, componentName string) error {
+	baseURL, err := c.restURL("/service/rest/v1/tags/associate", name)
+	if err != nil {
+		return err
+	}
+	query := baseURL.Query()
+	query.Set("repository", repository)
+	query.Set("name", componentName)
+	baseURL.RawQuery = query.Encode()
+
+	req, err := http.NewRequest("POST", baseURL.String(), nil)
+	if err != nil {
+		return err
+	}
+	c.authenticate(req)
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return newHTTPError(fmt.Sprintf("associate tag '%s' with %s/%s", name, repository, componentName), resp)
+	}
+	return nil
+}
+
+// tagURL returns the URL of the tag with the given name
+func (c *Client) tagURL(name string) (*url.URL, error) {
+	baseURL, err := c.restURL("/service/rest/v1/tags", name)
+	if err != nil {
+		return nil, err
+	}
+	return baseURL, nil
+}
+
+// sendJSON sends v as the JSON body of a request and returns the response status if it is
+// one of expected
+func (c *Client) sendJSON(method, rawURL string, v interface{}, op string, expected ...int) (int, error) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequest(method, rawURL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	c.authenticate(req)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	for _, status := range expected {
+		if resp.StatusCode == status {
+			return status, nil
+		}
+	}
+	return 0, newHTTPError(op, resp)
+}
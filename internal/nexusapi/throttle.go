@@ -0,0 +1,240 @@
+package nexusapi
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tympanix/nexus-cli/internal/metrics"
+)
+
+// ThrottleAttempts is how often a request that Nexus answers with 429 Too Many Requests is
+// sent before the 429 is returned to the caller. Requests whose body cannot be sent again,
+// such as streamed uploads, are sent once.
+var ThrottleAttempts = 5
+
+// ThrottleMinDelay is the gap between the starts of two requests after the first 429; every
+// further 429 doubles it, up to ThrottleMaxDelay
+var (
+	ThrottleMinDelay = 250 * time.Millisecond
+	ThrottleMaxDelay = 10 * time.Second
+)
+
+// MaxRetryAfter is the longest Retry-After a rate limited request waits for; the 429 of a
+// server asking to wait longer is returned to the caller instead
+var MaxRetryAfter = 5 * time.Minute
+
+// Throttle paces the requests of the run to one server once it starts rate limiting them.
+// Until the first 429 it costs nothing. After it, requests start at least a delay apart
+// and at most limit of them wait for a response at once; each 429 doubles the delay and
+// halves the limit, and both stay in place for the rest of the run rather than probing
+// the server at full speed again. A request holds its slot only until the response
+// headers arrive, and requests streaming a body that cannot be sent again take no slot,
+// so a download piped into an upload never waits for itself. The 429s of requests that
+// ran in parallel within one delay count as a single slowdown. It is safe for concurrent
+// use.
+type Throttle struct {
+	mu       sync.Mutex
+	cond     *sync.Cond
+	delay    time.Duration
+	next     time.Time // Earliest start of the next request
+	limit    int       // Maximum number of requests in flight, 0 if unlimited
+	inFlight int
+	events   int       // Number of 429 responses
+	slowed   time.Time // Last time the delay and limit were raised
+}
+
+// ThrottleStats describes how much the requests of the run were throttled
+type ThrottleStats struct {
+	Events int           `json:"events"` // Number of 429 Too Many Requests responses
+	Delay  time.Duration `json:"delay_ns"`
+	Limit  int           `json:"limit"` // Maximum number of requests in flight, 0 if unlimited
+}
+
+func newThrottle() *Throttle {
+	t := &Throttle{}
+	t.cond = sync.NewCond(&t.mu)
+	return t
+}
+
+var (
+	throttlesMu sync.Mutex
+	throttles   = make(map[string]*Throttle) // By host
+)
+
+// throttleFor returns the throttle shared by the requests of the run to host
+func throttleFor(host string) *Throttle {
+	throttlesMu.Lock()
+	defer throttlesMu.Unlock()
+	throttle, ok := throttles[host]
+	if !ok {
+		throttle = newThrottle()
+		throttles[host] = throttle
+	}
+	return throttle
+}
+
+// ThrottleSummary describes the throttling of every server that rate limited a request
+// of the run for the -vv output, or returns "" if none did
+func ThrottleSummary() string {
+	throttlesMu.Lock()
+	hosts := make([]string, 0, len(throttles))
+	for host := range throttles {
+		hosts = append(hosts, host)
+	}
+	throttlesMu.Unlock()
+	sort.Strings(hosts)
+	var lines []string
+	for _, host := range hosts {
+		if s := throttleFor(host).String(); s != "" {
+			lines = append(lines, s+" ("+host+")")
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Stats returns how much the requests were throttled so far
+func (t *Throttle) Stats() ThrottleStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return ThrottleStats{Events: t.events, Delay: t.delay, Limit: t.limit}
+}
+
+// String describes the throttling for the -vv output, or returns "" if Nexus never rate
+// limited a request
+func (t *Throttle) String() string {
+	stats := t.Stats()
+	if stats.Events == 0 {
+		return ""
+	}
+	return fmt.Sprintf("Throttled by Nexus (429 Too Many Requests): %d time(s); requests slowed to one every %s, at most %d at once", stats.Events, stats.Delay, stats.Limit)
+}
+
+// acquire waits until a request may start, and returns the function to call once its
+// response headers arrived. A request that is not limited only waits for the delay and
+// takes no slot: its body is streamed from another request, such as a download piped into
+// an upload, which could otherwise wait for the slot the upload holds.
+func (t *Throttle) acquire(limited bool) func() {
+	t.mu.Lock()
+	for limited && t.limit > 0 && t.inFlight >= t.limit {
+		t.cond.Wait()
+	}
+	if limited {
+		t.inFlight++
+	}
+	wait := time.Until(t.next)
+	if t.delay > 0 {
+		start := time.Now().Add(max(wait, 0))
+		t.next = start.Add(t.delay)
+	}
+	t.mu.Unlock()
+
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+	if !limited {
+		return func() {}
+	}
+	return func() {
+		t.mu.Lock()
+		t.inFlight--
+		t.cond.Broadcast()
+		t.mu.Unlock()
+	}
+}
+
+// throttled slows down the requests after a 429 and returns how long to wait before
+// sending the rate limited request again. A Retry-After beyond MaxRetryAfter is not
+// waited for, so it only holds back further requests by the delay.
+func (t *Throttle) throttled(retryAfter time.Duration) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.events++
+	if t.slowed.IsZero() || time.Since(t.slowed) >= t.delay {
+		t.slowed = time.Now()
+		t.delay = min(max(ThrottleMinDelay, 2*t.delay), ThrottleMaxDelay)
+		if t.limit == 0 {
+			t.limit = max(1, t.inFlight/2)
+		} else {
+			t.limit = max(1, t.limit/2)
+		}
+	}
+	wait := t.delay
+	if retryAfter <= MaxRetryAfter {
+		wait = max(retryAfter, wait)
+	}
+	if next := time.Now().Add(wait); next.After(t.next) {
+		t.next = next
+	}
+	return wait
+}
+
+// parseRetryAfter returns the delay the Retry-After header asks for, given in seconds or
+// as an HTTP date, or 0 if it is missing or invalid
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return max(time.Duration(seconds)*time.Second, 0)
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0)
+	}
+	return 0
+}
+
+// do sends req like HTTPClient.Do, paced by the throttle of its host. A 429 response slows
+// down all further requests to the host and the request is sent again after the delay the
+// server asks for, as long as its body can be replayed. Clients created WithoutThrottle
+// send the request once.
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	if c.unthrottled {
+		return c.HTTPClient.Do(req)
+	}
+	throttle := throttleFor(req.URL.Host)
+	replayable := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+	for attempt := 1; ; attempt++ {
+		release := throttle.acquire(replayable)
+		resp, err := c.HTTPClient.Do(req)
+		release()
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusTooManyRequests {
+			return resp, nil
+		}
+
+		kind := requestKind(req)
+		metrics.Active().Add(metrics.Throttled, 1, kind)
+		if requestMetrics := enabledRequestMetrics(); requestMetrics != nil {
+			requestMetrics.add(kind, RequestTimings{Throttled: 1})
+		}
+		retryAfter := parseRetryAfter(resp.Header.Get("Retry-After"))
+		wait := throttle.throttled(retryAfter)
+		if attempt == ThrottleAttempts || !replayable || retryAfter > MaxRetryAfter {
+			return resp, nil
+		}
+		metrics.Active().Add(metrics.Retries, 1, kind)
+		io.Copy(io.Discard, io.LimitReader(resp.Body, maxErrorBodySize))
+		resp.Body.Close()
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(wait):
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package nexusapi
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tympanix/nexus-cli/internal/metrics"
+)
+
+// useThrottle shortens the delays of the test and returns the throttle of server
+func useThrottle(t *testing.T, server *MockNexusServer) *Throttle {
+	t.Helper()
+	minDelay := ThrottleMinDelay
+	ThrottleMinDelay = time.Millisecond
+	t.Cleanup(func() { ThrottleMinDelay = minDelay })
+	serverURL, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return throttleFor(serverURL.Host)
+}
+
+func TestThrottleBurst(t *testing.T) {
+	server := NewMockNexusServer()
+	defer server.Close()
+	throttle := useThrottle(t, server)
+	server.AddAsset("test-repo", "/dir/file.txt", Asset{}, []byte("content"))
+
+	// A burst of 429s is waited out instead of failing the listing
+	registry := metrics.New("test")
+	metrics.Enable(registry)
+	defer metrics.Enable(nil)
+	server.RateLimit(3, "0")
+	client := NewClient(server.URL, "testuser", "testpass")
+	assets, err := client.ListAssets("test-repo", "dir", true)
+	if err != nil || len(assets) != 1 {
+		t.Fatalf("Expected the listing to succeed after the burst, got %v, %v", assets, err)
+	}
+	if got := server.GetThrottledRequests(); got != 3 {
+		t.Errorf("Expected 3 rate limited requests, got %d", got)
+	}
+	var written strings.Builder
+	registry.Write(&written)
+	for _, series := range []string{`nexuscli_throttled_total{command="test",request="api"} 3`, `nexuscli_retries_total{command="test",request="api"} 3`} {
+		if !strings.Contains(written.String(), series) {
+			t.Errorf("Expected %s in the metrics, got:\n%s", series, written.String())
+		}
+	}
+
+	// The requests stay slowed down for the rest of the run
+	stats := throttle.Stats()
+	if stats.Events != 3 || stats.Delay != 4*time.Millisecond || stats.Limit != 1 {
+		t.Errorf("Expected 3 events, a delay of 4ms and 1 request at once, got %+v", stats)
+	}
+	if err := client.DownloadAsset(assets[0].DownloadURL, &strings.Builder{}); err != nil {
+		t.Errorf("Expected the download to succeed, got %v", err)
+	}
+	if s := throttle.String(); !strings.Contains(s, "Throttled by Nexus (429 Too Many Requests): 3 time(s)") {
+		t.Errorf("Unexpected summary %q", s)
+	}
+
+	// A download streamed into an upload runs two requests at once, even at a limit of 1
+	reader, writer := io.Pipe()
+	downloaded := make(chan error, 1)
+	go func() {
+		err := client.DownloadAsset(assets[0].DownloadURL, writer)
+		writer.CloseWithError(err)
+		downloaded <- err
+	}()
+	uploaded := make(chan error, 1)
+	go func() { uploaded <- client.UploadRawAsset("test-repo", "copy/file.txt", reader) }()
+	for _, result := range []chan error{downloaded, uploaded} {
+		select {
+		case err := <-result:
+			if err != nil {
+				t.Errorf("Expected the streamed copy to succeed, got %v", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Expected the streamed copy not to wait for its own download")
+		}
+	}
+
+	// Other servers are not slowed down
+	other := NewMockNexusServer()
+	defer other.Close()
+	if stats := useThrottle(t, other).Stats(); stats.Events != 0 || stats.Limit != 0 {
+		t.Errorf("Expected another server not to be throttled, got %+v", stats)
+	}
+	if summary := ThrottleSummary(); !strings.Contains(summary, strings.TrimPrefix(server.URL, "http://")) {
+		t.Errorf("Expected the summary to name the throttled server, got %q", summary)
+	}
+}
+
+func TestWithoutThrottle(t *testing.T) {
+	server := NewMockNexusServer()
+	defer server.Close()
+	throttle := useThrottle(t, server)
+	server.AddRepository(Repository{Name: "test-repo", Format: "raw", Type: "hosted"})
+
+	// Lookups that must answer quickly are neither retried nor paced
+	server.RateLimit(1, "60")
+	client := NewClient(server.URL, "testuser", "testpass", WithoutThrottle())
+	start := time.Now()
+	var httpErr *HTTPError
+	if _, err := client.GetRepository("test-repo"); !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("Expected the 429 to be returned at once, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected the request not to wait for Retry-After, took %s", elapsed)
+	}
+	if stats := throttle.Stats(); stats.Events != 0 {
+		t.Errorf("Expected the 429 not to slow down other requests, got %+v", stats)
+	}
+}
+
+func TestThrottleGivesUp(t *testing.T) {
+	server := NewMockNexusServer()
+	defer server.Close()
+	throttle := useThrottle(t, server)
+	server.AddRepository(Repository{Name: "test-repo", Format: "raw", Type: "hosted"})
+	client := NewClient(server.URL, "testuser", "testpass")
+
+	// The 429 is returned once the attempts are used up
+	server.RateLimit(ThrottleAttempts, "")
+	_, err := client.GetRepository("test-repo")
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("Expected a 429 error after %d attempts, got %v", ThrottleAttempts, err)
+	}
+
+	// A server asking to wait longer than MaxRetryAfter is not waited for
+	server.RateLimit(1, "3600")
+	start := time.Now()
+	if _, err := client.GetRepository("test-repo"); !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("Expected a 429 error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected the request not to wait, took %s", elapsed)
+	}
+
+	// A streamed upload cannot be sent again, so its 429 is returned at once
+	server.RateLimit(1, "")
+	if err := client.UploadRawAsset("test-repo", "dir/file.txt", strings.NewReader("content")); err == nil || !strings.Contains(err.Error(), "429") {
+		t.Errorf("Expected the upload to fail with 429, got %v", err)
+	}
+	if got := throttle.Stats().Events; got != ThrottleAttempts+2 {
+		t.Errorf("Expected every 429 to be counted, got %d", got)
+	}
+}
+
+func TestThrottleLimit(t *testing.T) {
+	throttle := newThrottle()
+	throttle.limit = 1
+	release := throttle.acquire(true)
+
+	acquired := make(chan struct{})
+	go func() {
+		throttle.acquire(true)()
+		close(acquired)
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("Expected the second request to wait for the first")
+	case <-time.After(20 * time.Millisecond):
+	}
+	release()
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("Expected the second request to start once the first ended")
+	}
+	if throttle.inFlight != 0 {
+		t.Errorf("Expected no request in flight, got %d", throttle.inFlight)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	if got := parseRetryAfter("120"); got != 2*time.Minute {
+		t.Errorf("Expected 2m, got %s", got)
+	}
+	date := time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+	if got := parseRetryAfter(date); got < 59*time.Minute || got > time.Hour {
+		t.Errorf("Expected about an hour for %s, got %s", date, got)
+	}
+	for _, value := range []string{"", "soon", "-5"} {
+		if got := parseRetryAfter(value); got != 0 {
+			t.Errorf("Expected 0 for %q, got %s", value, got)
+		}
+	}
+}
+
+func TestThrottleParallelBurst(t *testing.T) {
+	// Eight requests in flight are rate limited at once: one slowdown, not eight
+	throttle := newThrottle()
+	throttle.inFlight = 8
+	for i := 0; i < 8; i++ {
+		throttle.throttled(0)
+	}
+	if stats := throttle.Stats(); stats.Events != 8 || stats.Limit != 4 || stats.Delay != ThrottleMinDelay {
+		t.Errorf("Expected 8 events to halve the requests in flight once, got %+v", stats)
+	}
+}
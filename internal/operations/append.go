@@ -0,0 +1,141 @@
+package operations
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/tympanix/nexus-cli/internal/archive"
+	"github.com/tympanix/nexus-cli/internal/config"
+	"github.com/tympanix/nexus-cli/internal/nexusapi"
+	"github.com/tympanix/nexus-cli/internal/util"
+)
+
+// appendToArchive merges filePaths into the archive at repository/subdir/archiveName. The
+// existing archive is downloaded and extracted to a temporary directory, the new files are
+// copied over it and the result is archived again and uploaded in its place. A new file
+// replaces an entry with the same path. If the archive does not exist yet it is created
+// with just the new files.
+//
+// Entries are written in path order with the modification time and mode they had in the
+// old archive or on disk, so merging the same files twice gives the same archive.
+func appendToArchive(src string, filePaths []string, repository, subdir, archiveName string, config *config.Config, opts *UploadOptions) error {
+	client := NewClient(config)
+	archivePath := path.Join(subdir, archiveName)
+
+	var existing *nexusapi.Asset
+	var err error
+	if !opts.offline() {
+		existing, err = findAsset(client, repository, archivePath)
+	}
+	if err != nil && !opts.continueOffline(err) {
+		return fmt.Errorf("failed to look up %s: %w", path.Join(repository, archivePath), err)
+	}
+
+	if opts.DryRun {
+		for _, filePath := range filePaths {
+			opts.Logger.VerbosePrintf("Would add: %s\n", planLocalPath(src, filePath))
+		}
+		plan := NewPlan("upload")
+		plan.Add(Action{Type: ActionUpload, Source: src, Target: path.Join(repository, archivePath)})
+		plan.Print(opts.Logger, opts.PlanFormat)
+		if opts.Offline {
+			opts.Logger.Printf("Dry-run mode: Would append %d files from %s to %s (Nexus was not contacted to check whether it exists)\n", len(filePaths), src, archiveName)
+		} else if existing == nil {
+			opts.Logger.Printf("Dry-run mode: Would create %s with %d files from %s\n", archiveName, len(filePaths), src)
+		} else {
+			opts.Logger.Printf("Dry-run mode: Would append %d files from %s to %s\n", len(filePaths), src, archiveName)
+		}
+		return nil
+	}
+
+	workDir, err := os.MkdirTemp("", "nexus-append-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	if existing != nil {
+		opts.Logger.VerbosePrintf("Downloading existing archive %s\n", archivePath)
+		if err := extractRemoteArchive(client, *existing, opts.CompressionFormat, workDir); err != nil {
+			return fmt.Errorf("failed to read existing archive %s: %w", archiveName, err)
+		}
+	} else {
+		opts.Logger.VerbosePrintf("Archive %s does not exist yet; creating it\n", archivePath)
+	}
+
+	replaced := 0
+	for _, filePath := range filePaths {
+		relPath, err := filepath.Rel(src, filePath)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(workDir, relPath)
+		if info, err := os.Stat(target); err == nil {
+			if info.IsDir() {
+				return fmt.Errorf("cannot append %s: the archive contains a directory with that name", filepath.ToSlash(relPath))
+			}
+			opts.Logger.VerbosePrintf("Replacing %s\n", filepath.ToSlash(relPath))
+			replaced++
+		}
+		if err := overlayFile(filePath, target); err != nil {
+			return fmt.Errorf("failed to add %s: %w", filepath.ToSlash(relPath), err)
+		}
+	}
+
+	merged, _, err := archive.CollectReadableFilesWithGlob(workDir, "")
+	if err != nil {
+		return err
+	}
+
+	summary := fmt.Sprintf("Appended %d files from %s to %s (%d replaced, %d files in archive)", len(filePaths), src, archiveName, replaced, len(merged))
+	return uploadArchive(client, workDir, merged, repository, subdir, archiveName, summary, opts)
+}
+
+// extractRemoteArchive downloads an archive asset and extracts it into destDir while it is read
+func extractRemoteArchive(client *nexusapi.Client, asset nexusapi.Asset, format archive.Format, destDir string) error {
+	pr, pw := io.Pipe()
+	errChan := make(chan error, 1)
+
+	go func() {
+		err := format.ExtractArchive(pr, destDir)
+		if err != nil {
+			pr.CloseWithError(err)
+		} else {
+			// Consume any trailing bytes so the download completes
+			_, _ = io.Copy(io.Discard, pr)
+		}
+		errChan <- err
+	}()
+
+	err := client.DownloadAsset(asset.DownloadURL, pw)
+	pw.CloseWithError(err)
+	if extractErr := <-errChan; extractErr != nil {
+		return extractErr
+	}
+	return err
+}
+
+// overlayFile copies src to dst, replacing dst if it exists, and keeps the mode and
+// modification time of src
+func overlayFile(src, dst string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if err := util.MkdirAll(filepath.Dir(dst), 0); err != nil {
+		return err
+	}
+	if err := os.Remove(dst); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := copyFile(src, dst); err != nil {
+		return err
+	}
+	if err := os.Chmod(dst, info.Mode().Perm()); err != nil {
+		return err
+	}
+	return os.Chtimes(dst, info.ModTime(), info.ModTime())
+}
@@ -0,0 +1,94 @@
+package operations
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tympanix/nexus-cli/internal/archive"
+	"github.com/tympanix/nexus-cli/internal/config"
+	"github.com/tympanix/nexus-cli/internal/nexusapi"
+	"github.com/tympanix/nexus-cli/internal/util"
+)
+
+// TestAppendToArchiveRoundTrip creates an archive with --append, appends to it and
+// downloads the merged archive again
+func TestAppendToArchiveRoundTrip(t *testing.T) {
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+	server.StoreUploads = true
+	config := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+	opts := &UploadOptions{Logger: util.NewLogger(io.Discard), QuietMode: true, Compress: true, Append: true, CompressionFormat: archive.FormatGzip}
+
+	first := t.TempDir()
+	oldTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	writeFileWithMetadata(t, filepath.Join(first, "keep.txt"), "keep", 0644, oldTime)
+	writeFileWithMetadata(t, filepath.Join(first, "conf", "app.json"), "old", 0644, oldTime)
+	if err := uploadFilesCompressedWithArchiveName(first, "raw", "builds", "bundle.tar.gz", config, opts); err != nil {
+		t.Fatalf("Creating the archive failed: %v", err)
+	}
+
+	second := t.TempDir()
+	newTime := time.Date(2023, 4, 5, 6, 7, 8, 0, time.UTC)
+	writeFileWithMetadata(t, filepath.Join(second, "conf", "app.json"), "new", 0600, newTime)
+	writeFileWithMetadata(t, filepath.Join(second, "added.txt"), "added", 0644, newTime)
+	if err := uploadFilesCompressedWithArchiveName(second, "raw", "builds", "bundle.tar.gz", config, opts); err != nil {
+		t.Fatalf("Appending to the archive failed: %v", err)
+	}
+
+	uploaded := findUpload(server, "/builds/bundle.tar.gz")
+	if uploaded == nil {
+		t.Fatal("Expected the merged archive to be uploaded")
+	}
+	destDir := t.TempDir()
+	if err := archive.FormatGzip.ExtractArchive(bytes.NewReader(uploaded.Content), destDir); err != nil {
+		t.Fatalf("Failed to extract merged archive: %v", err)
+	}
+	expected := map[string]string{"keep.txt": "keep", "conf/app.json": "new", "added.txt": "added"}
+	for name, content := range expected {
+		data, err := os.ReadFile(filepath.Join(destDir, name))
+		if err != nil {
+			t.Errorf("Expected %s in the merged archive: %v", name, err)
+			continue
+		}
+		if string(data) != content {
+			t.Errorf("Expected %s to contain %q, got %q", name, content, data)
+		}
+	}
+	checkFileMetadata(t, filepath.Join(destDir, "keep.txt"), 0644, oldTime)
+	checkFileMetadata(t, filepath.Join(destDir, "conf", "app.json"), 0600, newTime)
+
+	// Appending the same files again must give the same archive
+	if err := uploadFilesCompressedWithArchiveName(second, "raw", "builds", "bundle.tar.gz", config, opts); err != nil {
+		t.Fatalf("Appending again failed: %v", err)
+	}
+	if again := findUpload(server, "/builds/bundle.tar.gz"); !bytes.Equal(again.Content, uploaded.Content) {
+		t.Error("Expected appending the same files twice to produce an identical archive")
+	}
+}
+
+func TestAppendToArchiveDryRun(t *testing.T) {
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+	config := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+
+	src := t.TempDir()
+	if err := os.WriteFile(filepath.Join(src, "a.txt"), []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	opts := &UploadOptions{Logger: util.NewLogger(&buf), Compress: true, Append: true, DryRun: true, CompressionFormat: archive.FormatGzip}
+	if err := uploadFilesCompressedWithArchiveName(src, "raw", "", "bundle.tar.gz", config, opts); err != nil {
+		t.Fatalf("Dry-run failed: %v", err)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte("Would create bundle.tar.gz")) {
+		t.Errorf("Expected dry-run to report that the archive would be created, got: %s", buf.String())
+	}
+	if len(server.GetUploadedFiles()) != 0 {
+		t.Error("Expected no uploads in dry-run mode")
+	}
+}
@@ -0,0 +1,60 @@
+package operations
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"path"
+
+	"github.com/tympanix/nexus-cli/internal/checksum"
+	"github.com/tympanix/nexus-cli/internal/nexusapi"
+)
+
+// ArchiveManifestSuffix is appended to the archive name to name the manifest that
+// --manifest uploads next to a compressed archive
+const ArchiveManifestSuffix = ".manifest.json"
+
+// ArchiveManifest lists the files inside an uploaded archive with their checksums
+type ArchiveManifest struct {
+	Archive   string            `json:"archive"`   // Name of the archive the manifest describes
+	Algorithm string            `json:"algorithm"` // Checksum algorithm of the entries in Files
+	Files     map[string]string `json:"files"`     // Hex checksum by path inside the archive
+}
+
+// manifestAlgorithm returns the checksum algorithm for the archive manifest. It follows
+// --checksum and falls back to sha256 when checksums are skipped.
+func manifestAlgorithm(opts *UploadOptions) string {
+	if opts.ChecksumAlgorithm != "" {
+		return opts.ChecksumAlgorithm
+	}
+	return "sha256"
+}
+
+// manifestHash returns a constructor for the hash of the archive manifest
+func manifestHash(opts *UploadOptions) (func() hash.Hash, error) {
+	algorithm := manifestAlgorithm(opts)
+	if _, err := checksum.NewHasher(algorithm); err != nil {
+		return nil, err
+	}
+	return func() hash.Hash {
+		h, _ := checksum.NewHasher(algorithm)
+		return h
+	}, nil
+}
+
+// uploadArchiveManifest uploads the manifest of archiveName, listing the checksums
+// collected while the archive was written, to repository/subdir
+func uploadArchiveManifest(client *nexusapi.Client, repository, subdir, archiveName string, sums map[string]string, opts *UploadOptions) error {
+	manifest := ArchiveManifest{Archive: archiveName, Algorithm: manifestAlgorithm(opts), Files: sums}
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	manifestPath := path.Join(subdir, archiveName+ArchiveManifestSuffix)
+	if err := client.UploadRawAsset(repository, manifestPath, bytes.NewReader(data)); err != nil {
+		return fmt.Errorf("failed to upload %s: %w", manifestPath, err)
+	}
+	opts.Logger.VerbosePrintf("Uploaded %s with checksums of %d file(s)\n", manifestPath, len(sums))
+	return nil
+}
@@ -0,0 +1,84 @@
+package operations
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tympanix/nexus-cli/internal/archive"
+	"github.com/tympanix/nexus-cli/internal/config"
+	"github.com/tympanix/nexus-cli/internal/nexusapi"
+	"github.com/tympanix/nexus-cli/internal/util"
+)
+
+func TestUploadCompressedManifest(t *testing.T) {
+	src := t.TempDir()
+	files := map[string]string{
+		"app.bin":         "app binary",
+		"docs/readme.txt": "readme",
+		"docs/empty.txt":  "",
+	}
+	for relPath, content := range files {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(src, relPath)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(src, relPath), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, format := range []archive.Format{archive.FormatGzip, archive.FormatZip} {
+		t.Run(string(format), func(t *testing.T) {
+			server := nexusapi.NewMockNexusServer()
+			defer server.Close()
+			config := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+
+			archiveName := "bundle" + format.Extension()
+			opts := &UploadOptions{Logger: util.NewLogger(io.Discard), QuietMode: true, Compress: true, CompressionFormat: format, Manifest: true}
+			opts.SetChecksumAlgorithm("sha256")
+			if err := uploadFilesWithArchiveName(src, "raw", "builds", archiveName, config, opts); err != nil {
+				t.Fatalf("Upload failed: %v", err)
+			}
+
+			uploaded := findUpload(server, "/builds/"+archiveName+ArchiveManifestSuffix)
+			if uploaded == nil {
+				t.Fatal("Expected the manifest to be uploaded")
+			}
+			var manifest ArchiveManifest
+			if err := json.Unmarshal(uploaded.Content, &manifest); err != nil {
+				t.Fatalf("Uploaded manifest is invalid: %v", err)
+			}
+			if manifest.Archive != archiveName || manifest.Algorithm != "sha256" || len(manifest.Files) != len(files) {
+				t.Fatalf("Unexpected manifest: %+v", manifest)
+			}
+			for relPath, content := range files {
+				sum := sha256.Sum256([]byte(content))
+				if got := manifest.Files[relPath]; got != hex.EncodeToString(sum[:]) {
+					t.Errorf("Expected checksum of %s to match the source file, got %q", relPath, got)
+				}
+			}
+		})
+	}
+}
+
+func TestUploadCompressedWithoutManifest(t *testing.T) {
+	src := t.TempDir()
+	if err := os.WriteFile(filepath.Join(src, "app.bin"), []byte("app"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+	config := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+
+	opts := &UploadOptions{Logger: util.NewLogger(io.Discard), QuietMode: true, Compress: true, CompressionFormat: archive.FormatGzip}
+	if err := uploadFilesWithArchiveName(src, "raw", "builds", "bundle.tar.gz", config, opts); err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	if uploaded := server.GetUploadedFiles(); len(uploaded) != 1 {
+		t.Errorf("Expected only the archive to be uploaded, got %d files", len(uploaded))
+	}
+}
@@ -0,0 +1,180 @@
+package operations
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/tympanix/nexus-cli/internal/nexusapi"
+)
+
+// AttributeSidecarSuffix is appended to the path of an uploaded file for the sidecar that
+// holds its attributes on servers without tagging
+const AttributeSidecarSuffix = nexusapi.AttributeSidecarSuffix
+
+// AttributeSidecar is the content of an attribute sidecar
+type AttributeSidecar struct {
+	Attributes map[string]string `json:"attributes"`
+}
+
+// SetAttribute adds an attribute given as "key=value" with --attr. A later value for the
+// same key replaces an earlier one.
+func (opts *UploadOptions) SetAttribute(s string) error {
+	key, value, ok := strings.Cut(s, "=")
+	key = strings.TrimSpace(key)
+	if !ok || key == "" {
+		return fmt.Errorf("invalid attribute '%s': must be in the form key=value, e.g. commit=4f2a9c1", s)
+	}
+	if opts.Attributes == nil {
+		opts.Attributes = make(map[string]string)
+	}
+	opts.Attributes[key] = value
+	return nil
+}
+
+// attributeTagName returns the name of the tag holding the attributes of the asset at
+// assetPath. Tag names are global to the server, so the name is derived from both the
+// repository and the path.
+func attributeTagName(repository, assetPath string) string {
+	sum := sha256.Sum256([]byte(repository + ":" + strings.TrimPrefix(assetPath, "/")))
+	return "nexuscli-" + hex.EncodeToString(sum[:10])
+}
+
+// storeAttributes attaches opts.Attributes to the uploaded assets at relPaths below
+// subdir. The upload forms of the components API take no attributes, so on servers with
+// tagging (Nexus Repository Pro) they become a tag associated with the component of each
+// asset, and on other servers a sidecar next to each asset, all uploaded in one request.
+// listed holds the assets below subdir before the upload, by relative path; attributes
+// that are already stored for them are left as they are.
+func storeAttributes(client *nexusapi.Client, repository, subdir string, relPaths []string, listed map[string]nexusapi.Asset, opts *UploadOptions) error {
+	if len(opts.Attributes) == 0 || len(relPaths) == 0 {
+		return nil
+	}
+	tagging, err := client.TaggingSupported()
+	if err != nil {
+		return err
+	}
+
+	stored, storage := 0, "tags"
+	if tagging {
+		stored, err = storeAttributeTags(client, repository, subdir, relPaths, listed, opts)
+	} else {
+		stored, err = storeAttributeSidecars(client, repository, subdir, relPaths, listed, opts)
+		storage = "sidecars"
+	}
+	if err != nil {
+		return err
+	}
+	opts.Logger.VerbosePrintf("Stored %d attribute(s) of %d file(s) as %s, %d already up to date\n", len(opts.Attributes), stored, storage, len(relPaths)-stored)
+	return nil
+}
+
+// storeAttributeTags sets the tag of each asset and associates it with its component. An
+// asset that existed before the upload and whose tag has the attributes already keeps it.
+// It returns the number of tags set.
+func storeAttributeTags(client *nexusapi.Client, repository, subdir string, relPaths []string, listed map[string]nexusapi.Asset, opts *UploadOptions) (int, error) {
+	stored := 0
+	for _, relPath := range relPaths {
+		assetPath := path.Join(subdir, relPath)
+		name := attributeTagName(repository, assetPath)
+		if _, existed := listed[relPath]; existed {
+			tag, err := client.GetTag(name)
+			if err != nil {
+				return stored, err
+			}
+			if tag != nil && sameAttributes(tag.Attributes, opts.Attributes) {
+				continue
+			}
+		}
+		if err := client.SetTag(name, opts.Attributes); err != nil {
+			return stored, err
+		}
+		if err := client.AssociateTag(name, repository, assetPath); err != nil {
+			return stored, err
+		}
+		stored++
+	}
+	return stored, nil
+}
+
+// storeAttributeSidecars uploads the sidecars of the assets in a single request, leaving out
+// listed sidecars with the same content. It returns the number of sidecars uploaded.
+func storeAttributeSidecars(client *nexusapi.Client, repository, subdir string, relPaths []string, listed map[string]nexusapi.Asset, opts *UploadOptions) (int, error) {
+	sidecar, err := json.MarshalIndent(AttributeSidecar{Attributes: opts.Attributes}, "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	sum := sha1.Sum(sidecar)
+	sidecars := make(map[string][]byte, len(relPaths))
+	for _, relPath := range relPaths {
+		sidecarPath := relPath + AttributeSidecarSuffix
+		if asset, ok := listed[sidecarPath]; ok && strings.EqualFold(asset.Checksum.SHA1, hex.EncodeToString(sum[:])) {
+			continue
+		}
+		sidecars[sidecarPath] = sidecar
+	}
+	if len(sidecars) == 0 {
+		return 0, nil
+	}
+	if err := client.UploadRawAssets(repository, subdir, sidecars); err != nil {
+		return 0, fmt.Errorf("failed to upload attributes: %w", err)
+	}
+	return len(sidecars), nil
+}
+
+// sameAttributes reports whether the attributes of a tag are those to store
+func sameAttributes(tagAttributes map[string]interface{}, attributes map[string]string) bool {
+	if len(tagAttributes) != len(attributes) {
+		return false
+	}
+	for key, value := range attributes {
+		if tagValue, ok := tagAttributes[key].(string); !ok || tagValue != value {
+			return false
+		}
+	}
+	return true
+}
+
+// fetchAttributes returns the attributes stored for the asset at assetPath and where
+// they were found, e.g. "tag nexuscli-…" or "sidecar repo/file.meta.json". It returns
+// nil attributes if none were stored.
+func fetchAttributes(client *nexusapi.Client, repository, assetPath string) (map[string]interface{}, string, error) {
+	tagging, err := client.TaggingSupported()
+	if err != nil {
+		return nil, "", err
+	}
+	if tagging {
+		name := attributeTagName(repository, assetPath)
+		tag, err := client.GetTag(name)
+		if err != nil {
+			return nil, "", err
+		}
+		if tag != nil {
+			return tag.Attributes, "tag " + name, nil
+		}
+	}
+
+	// Sidecars are also looked up on servers with tagging, for files uploaded before it
+	// was available
+	sidecarPath := strings.TrimPrefix(assetPath, "/") + AttributeSidecarSuffix
+	asset, err := findAsset(client, repository, sidecarPath)
+	if err != nil || asset == nil {
+		return nil, "", err
+	}
+	var buf bytes.Buffer
+	if err := client.DownloadAsset(asset.DownloadURL, &buf); err != nil {
+		return nil, "", err
+	}
+	var content struct {
+		Attributes map[string]interface{} `json:"attributes"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &content); err != nil {
+		return nil, "", fmt.Errorf("invalid attribute sidecar %s: %w", sidecarPath, err)
+	}
+	return content.Attributes, "sidecar " + repository + "/" + sidecarPath, nil
+}
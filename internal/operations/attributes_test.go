@@ -0,0 +1,159 @@
+package operations
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tympanix/nexus-cli/internal/config"
+	"github.com/tympanix/nexus-cli/internal/nexusapi"
+	"github.com/tympanix/nexus-cli/internal/util"
+)
+
+// uploadWithAttributes uploads two files to raw/dist with attributes commit=4f2a9c1 and
+// channel=stable. Without force, files that are already uploaded are skipped.
+func uploadWithAttributes(t *testing.T, server *nexusapi.MockNexusServer, force bool) *config.Config {
+	t.Helper()
+	cfg := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+	opts := &UploadOptions{Logger: util.NewLogger(io.Discard), QuietMode: true, Force: force}
+	if err := opts.SetChecksumAlgorithm("sha1"); err != nil {
+		t.Fatal(err)
+	}
+	for _, attr := range []string{"commit=4f2a9c1", "channel=beta", "channel=stable"} {
+		if err := opts.SetAttribute(attr); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	srcDir := t.TempDir()
+	for _, name := range []string{"app.bin", "notes.txt"} {
+		if err := os.WriteFile(filepath.Join(srcDir, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := uploadFiles(srcDir, "raw", "dist", cfg, opts); err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	return cfg
+}
+
+func TestAttributesStoredAsTags(t *testing.T) {
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+	server.StoreUploads = true
+	server.Tagging = true
+
+	cfg := uploadWithAttributes(t, server, true)
+
+	if paths := uploadedPaths(server); len(paths) != 2 {
+		t.Errorf("Expected no sidecars with tagging, got %v", paths)
+	}
+	if len(server.Tags) != 2 {
+		t.Errorf("Expected a tag per file, got %v", server.Tags)
+	}
+	name := attributeTagName("raw", "dist/app.bin")
+	if associated := server.TagAssociations[name]; len(associated) != 1 || associated[0] != "raw:dist/app.bin" {
+		t.Errorf("Expected %s to be associated with dist/app.bin, got %v", name, associated)
+	}
+
+	stat, err := statAsset("raw/dist/app.bin", cfg)
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	expected := map[string]interface{}{"commit": "4f2a9c1", "channel": "stable"}
+	if !reflect.DeepEqual(stat.Attributes, expected) || stat.AttributeSource != "tag "+name {
+		t.Errorf("Unexpected attributes %v from %q", stat.Attributes, stat.AttributeSource)
+	}
+
+	// Unchanged files keep their tags
+	uploadWithAttributes(t, server, false)
+	if associated := server.TagAssociations[name]; len(associated) != 1 {
+		t.Errorf("Expected the unchanged tag not to be associated again, got %v", associated)
+	}
+}
+
+func TestAttributesStoredAsSidecars(t *testing.T) {
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+	server.StoreUploads = true
+
+	cfg := uploadWithAttributes(t, server, true)
+
+	paths := uploadedPaths(server)
+	expectedPaths := []string{"/dist/app.bin", "/dist/app.bin.meta.json", "/dist/notes.txt", "/dist/notes.txt.meta.json"}
+	if !reflect.DeepEqual(paths, expectedPaths) {
+		t.Errorf("Expected a sidecar per file, got %v", paths)
+	}
+
+	stat, err := statAsset("raw/dist/notes.txt", cfg)
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	expected := map[string]interface{}{"commit": "4f2a9c1", "channel": "stable"}
+	if !reflect.DeepEqual(stat.Attributes, expected) || stat.AttributeSource != "sidecar raw/dist/notes.txt.meta.json" {
+		t.Errorf("Unexpected attributes %v from %q", stat.Attributes, stat.AttributeSource)
+	}
+
+	var out strings.Builder
+	printStat(&out, stat)
+	for _, want := range []string{"Path:", "raw/dist/notes.txt", "Attributes (sidecar raw/dist/notes.txt.meta.json):", "channel  stable", "commit   4f2a9c1"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected %q in stat output:\n%s", want, out.String())
+		}
+	}
+
+	// The sidecars go up in one request, and unchanged ones are not uploaded again
+	requests := make(map[int]bool)
+	for _, file := range server.GetUploadedFiles() {
+		if strings.HasSuffix(file.Path, AttributeSidecarSuffix) {
+			requests[file.Request] = true
+		}
+	}
+	if len(requests) != 1 {
+		t.Errorf("Expected the sidecars in one request, got requests %v", requests)
+	}
+	server.UploadedFiles = nil
+	uploadWithAttributes(t, server, false)
+	if paths := uploadedPaths(server); len(paths) != 0 {
+		t.Errorf("Expected nothing to be uploaded again, got %v", paths)
+	}
+
+	// A download leaves the sidecars out, so an upload of it adds no sidecars of sidecars
+	destDir := t.TempDir()
+	opts := &DownloadOptions{ChecksumAlgorithm: "sha1", Logger: util.NewLogger(io.Discard), QuietMode: true, Recursive: true}
+	if status := downloadFolder("raw/dist", destDir, cfg, opts); status != DownloadSuccess {
+		t.Fatalf("Download failed: %v", status)
+	}
+	entries, _ := os.ReadDir(filepath.Join(destDir, "dist"))
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if !reflect.DeepEqual(names, []string{"app.bin", "notes.txt"}) {
+		t.Errorf("Expected only the files to be downloaded, got %v", names)
+	}
+}
+
+func TestStatWithoutAttributes(t *testing.T) {
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+	server.AddAsset("raw", "/dist/app.bin", nexusapi.Asset{}, []byte("binary"))
+	cfg := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+
+	stat, err := statAsset("raw/dist/app.bin", cfg)
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if stat.Attributes != nil || stat.Asset.FileSize != 6 {
+		t.Errorf("Unexpected stat %+v", stat)
+	}
+	if _, err := statAsset("raw/dist/missing.bin", cfg); err == nil {
+		t.Error("Expected an error for a missing asset")
+	}
+	if err := (&UploadOptions{}).SetAttribute("=value"); err == nil {
+		t.Error("Expected an error for an attribute without a key")
+	}
+}
@@ -0,0 +1,33 @@
+package operations
+
+// splitUploadBatches groups files into upload requests of at most maxFiles files and
+// maxBytes bytes of file content. A file is added to the current request unless that
+// would exceed either limit, in which case a new request is started. A file larger than
+// maxBytes is sent in a request of its own. A limit of 0 means no limit. The returned
+// batches hold indexes into sizes, in order.
+func splitUploadBatches(sizes []int64, maxFiles int, maxBytes int64) [][]int {
+	var batches [][]int
+	var current []int
+	var currentBytes int64
+
+	for idx, size := range sizes {
+		if startsNewBatch(len(current), currentBytes, size, maxFiles, maxBytes) {
+			batches = append(batches, current)
+			current, currentBytes = nil, 0
+		}
+		current = append(current, idx)
+		currentBytes += size
+	}
+	if len(current) > 0 {
+		batches = append(batches, current)
+	}
+	return batches
+}
+
+// startsNewBatch reports whether a file of size bytes goes into a new batch after a batch of
+// files files and bytes bytes
+func startsNewBatch(files int, bytes, size int64, maxFiles int, maxBytes int64) bool {
+	full := maxFiles > 0 && files >= maxFiles
+	tooLarge := maxBytes > 0 && bytes+size > maxBytes
+	return files > 0 && (full || tooLarge)
+}
@@ -0,0 +1,218 @@
+package operations
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/tympanix/nexus-cli/internal/config"
+	"github.com/tympanix/nexus-cli/internal/nexusapi"
+	"github.com/tympanix/nexus-cli/internal/util"
+)
+
+func TestSplitUploadBatches(t *testing.T) {
+	tests := []struct {
+		name     string
+		sizes    []int64
+		maxFiles int
+		maxBytes int64
+		expected [][]int
+	}{
+		{"no limits", []int64{10, 20, 30}, 0, 0, [][]int{{0, 1, 2}}},
+		{"file count", []int64{1, 1, 1, 1, 1}, 2, 0, [][]int{{0, 1}, {2, 3}, {4}}},
+		{"byte limit", []int64{40, 40, 40, 10}, 0, 100, [][]int{{0, 1}, {2, 3}}},
+		{"exact byte limit", []int64{50, 50, 1}, 0, 100, [][]int{{0, 1}, {2}}},
+		{"oversized file alone", []int64{10, 500, 10, 10}, 0, 100, [][]int{{0}, {1}, {2, 3}}},
+		{"both limits", []int64{10, 10, 10, 90, 10}, 2, 100, [][]int{{0, 1}, {2, 3}, {4}}},
+		{"empty", nil, 2, 100, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := splitUploadBatches(tt.sizes, tt.maxFiles, tt.maxBytes)
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("splitUploadBatches(%v, %d, %d) = %v, want %v", tt.sizes, tt.maxFiles, tt.maxBytes, got, tt.expected)
+			}
+		})
+	}
+}
+
+// TestUploadSplitsBatches uploads files of crafted sizes and checks which request carried each one
+func TestUploadSplitsBatches(t *testing.T) {
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+	config := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+
+	srcDir := t.TempDir()
+	sizes := map[string]int{"a.bin": 300, "b.bin": 300, "c.bin": 300, "d.bin": 5000, "e.bin": 100, "f.bin": 100, "g.bin": 100}
+	for name, size := range sizes {
+		if err := os.WriteFile(filepath.Join(srcDir, name), []byte(strings.Repeat("x", size)), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	opts := &UploadOptions{Logger: util.NewLogger(io.Discard), QuietMode: true, Force: true, BatchSize: 2, MaxRequestBytes: 1000}
+	if err := uploadFiles(srcDir, "raw", "batched", config, opts); err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+
+	// Files are walked in name order: a+b hit the file limit, c is followed by the
+	// oversized d which goes alone, then e+f and g
+	expected := map[string]int{"a.bin": 1, "b.bin": 1, "c.bin": 2, "d.bin": 3, "e.bin": 4, "f.bin": 4, "g.bin": 5}
+	got := make(map[string]int)
+	for _, file := range server.GetUploadedFiles() {
+		got[filepath.Base(file.Path)] = file.Request
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Unexpected requests per file:\n got: %v\nwant: %v", got, expected)
+	}
+	if server.UploadRequests != 5 {
+		t.Errorf("Expected 5 upload requests, got %d", server.UploadRequests)
+	}
+}
+
+func TestUploadWithoutBatchLimitsUsesOneRequest(t *testing.T) {
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+	config := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+
+	srcDir := t.TempDir()
+	for i := 0; i < 5; i++ {
+		if err := os.WriteFile(filepath.Join(srcDir, fmt.Sprintf("file%d.txt", i)), []byte("content"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	opts := &UploadOptions{Logger: util.NewLogger(io.Discard), QuietMode: true, Force: true}
+	if err := uploadFiles(srcDir, "raw", "", config, opts); err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	if server.UploadRequests != 1 || len(server.GetUploadedFiles()) != 5 {
+		t.Errorf("Expected 5 files in 1 request, got %d files in %d requests", len(server.GetUploadedFiles()), server.UploadRequests)
+	}
+}
+
+// TestUploadMixedDepthTree checks that files of different subdirectories share a request:
+// raw.directory is the destination folder and each file carries the rest of its path
+func TestUploadMixedDepthTree(t *testing.T) {
+	srcDir := t.TempDir()
+	relPaths := []string{"a.txt", "docs/b.txt", "docs/api/v1/c.txt", "lib/d.txt"}
+	for _, relPath := range relPaths {
+		filePath := filepath.Join(srcDir, filepath.FromSlash(relPath))
+		if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filePath, []byte(relPath), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tc := range []struct {
+		name     string
+		opts     UploadOptions
+		requests int
+		subdir   string
+	}{
+		{"streamed", UploadOptions{}, 1, "site/2.0"},
+		{"collected", UploadOptions{Strict: true}, 1, "site/2.0"},
+		{"batched", UploadOptions{BatchSize: 3}, 2, "site/2.0"},
+		{"repository root", UploadOptions{}, 1, ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			server := nexusapi.NewMockNexusServer()
+			defer server.Close()
+			config := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+
+			opts := tc.opts
+			opts.Logger, opts.QuietMode, opts.Force = util.NewLogger(io.Discard), true, true
+			if err := uploadFiles(srcDir, "raw", tc.subdir, config, &opts); err != nil {
+				t.Fatalf("Upload failed: %v", err)
+			}
+			if server.UploadRequests != tc.requests {
+				t.Errorf("Expected %d upload requests, got %d", tc.requests, server.UploadRequests)
+			}
+			var formNames []string
+			for _, file := range server.GetUploadedFiles() {
+				if file.Directory != tc.subdir {
+					t.Errorf("Expected raw.directory %q for %s, got %q", tc.subdir, file.FormName, file.Directory)
+				}
+				if expected := "/" + path.Join(tc.subdir, file.FormName); file.Path != expected {
+					t.Errorf("Expected %s to be uploaded to %s, got %s", file.FormName, expected, file.Path)
+				}
+				formNames = append(formNames, file.FormName)
+			}
+			sort.Strings(formNames)
+			if !reflect.DeepEqual(formNames, []string{"a.txt", "docs/api/v1/c.txt", "docs/b.txt", "lib/d.txt"}) {
+				t.Errorf("Expected each file to carry its path below the directory, got %v", formNames)
+			}
+		})
+	}
+}
+
+// writeBatchFiles writes n files of size bytes each to a new directory
+func writeBatchFiles(t *testing.T, n, size int) string {
+	t.Helper()
+	srcDir := t.TempDir()
+	for i := 0; i < n; i++ {
+		if err := os.WriteFile(filepath.Join(srcDir, fmt.Sprintf("file%d.bin", i)), []byte(strings.Repeat("x", size)), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return srcDir
+}
+
+// TestUploadRetriesRejectedBatch tests that a batch rejected with 413 is sent again as two
+// smaller requests
+func TestUploadRetriesRejectedBatch(t *testing.T) {
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+	server.StoreUploads = true
+	// Room for two files with their multipart headers, but not four
+	server.MaxRequestBytes = 3000
+	config := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+
+	srcDir := writeBatchFiles(t, 4, 1000)
+	opts := &UploadOptions{Logger: util.NewLogger(io.Discard), QuietMode: true, BatchSize: 4}
+	if err := uploadFiles(srcDir, "raw", "retried", config, opts); err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	if server.RejectedUploads != 1 || server.UploadRequests != 2 {
+		t.Errorf("Expected 1 rejected and 2 accepted requests, got %d and %d", server.RejectedUploads, server.UploadRequests)
+	}
+	if uploaded := server.GetUploadedFiles(); len(uploaded) != 4 {
+		t.Errorf("Expected all 4 files to be uploaded, got %d", len(uploaded))
+	}
+	// The bytes of the rejected request are not counted as transferred
+	if transferred := opts.meter.Reading().TransferredBytes; transferred != 4000 {
+		t.Errorf("Expected 4000 bytes counted as transferred, got %d", transferred)
+	}
+}
+
+// TestUploadTooLargeWithoutBatching tests that without batch limits a 413 fails the upload
+// with a hint instead of being retried
+func TestUploadTooLargeWithoutBatching(t *testing.T) {
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+	server.MaxRequestBytes = 3000
+	config := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+
+	srcDir := writeBatchFiles(t, 4, 1000)
+	opts := &UploadOptions{Logger: util.NewLogger(io.Discard), QuietMode: true}
+	err := uploadFiles(srcDir, "raw", "", config, opts)
+	var tooLarge *nexusapi.RequestTooLargeError
+	if !errors.As(err, &tooLarge) {
+		t.Fatalf("Expected a *RequestTooLargeError, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "retry with smaller batches or --chunked") {
+		t.Errorf("Expected a hint to use smaller requests, got: %v", err)
+	}
+	if server.RejectedUploads != 1 || server.UploadRequests != 0 {
+		t.Errorf("Expected the single request to be rejected without a retry, got %d rejected and %d accepted", server.RejectedUploads, server.UploadRequests)
+	}
+}
@@ -0,0 +1,150 @@
+package operations
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/tympanix/nexus-cli/internal/checksum"
+	"github.com/tympanix/nexus-cli/internal/nexusapi"
+	"github.com/tympanix/nexus-cli/internal/util"
+)
+
+// downloadCache is a shared on-disk cache of downloaded assets. Entries are stored at
+// <dir>/<repository>/<asset path>/<algorithm>-<checksum> so several jobs can share a
+// cache directory. Entries are written atomically via rename and re-validated on every
+// read, so concurrent access and corrupted entries never produce a bad local file.
+type downloadCache struct {
+	dir        string
+	repository string
+	algorithm  string
+	validator  checksum.Validator
+	dirMode    os.FileMode // Mode of the directories created for restored files
+}
+
+func newDownloadCache(dir, repository string, validator checksum.Validator, dirMode os.FileMode) *downloadCache {
+	return &downloadCache{
+		dir:        dir,
+		repository: repository,
+		algorithm:  validator.Algorithm(),
+		validator:  validator,
+		dirMode:    dirMode,
+	}
+}
+
+// entryPath returns the cache path for an asset, or false if the asset has no
+// checksum for the configured algorithm and therefore cannot be cached safely
+func (c *downloadCache) entryPath(asset nexusapi.Asset) (string, bool) {
+	expected, err := checksum.NormalizeChecksum(checksum.ExtractChecksum(asset.Checksum, c.algorithm), c.algorithm)
+	if err != nil {
+		return "", false
+	}
+	assetPath := strings.TrimLeft(path.Clean("/"+asset.Path), "/")
+	name := fmt.Sprintf("%s-%s", c.algorithm, expected)
+	return filepath.Join(c.dir, filepath.FromSlash(c.repository), filepath.FromSlash(assetPath), name), true
+}
+
+// restore places a cached copy of the asset at localPath. It returns false on a cache miss.
+// Entries that fail checksum validation are removed so the asset is downloaded again.
+func (c *downloadCache) restore(asset nexusapi.Asset, localPath string) (bool, error) {
+	entry, ok := c.entryPath(asset)
+	if !ok {
+		return false, nil
+	}
+	if _, err := os.Stat(entry); err != nil {
+		return false, nil
+	}
+
+	valid, err := c.validator.Validate(entry, asset.Checksum)
+	if err != nil || !valid {
+		os.Remove(entry)
+		return false, nil
+	}
+
+	if err := util.MkdirAll(filepath.Dir(localPath), c.dirMode); err != nil {
+		return false, err
+	}
+	if err := linkOrCopy(entry, localPath); err != nil {
+		return false, err
+	}
+
+	// Validate again in case the entry was replaced while it was being copied
+	valid, err = c.validator.Validate(localPath, asset.Checksum)
+	if err != nil || !valid {
+		os.Remove(localPath)
+		return false, nil
+	}
+	return true, nil
+}
+
+// store adds a downloaded file to the cache if its content matches the asset checksum.
+// verified indicates that the checksum was already verified while the file was downloaded,
+// in which case the file is not read again.
+func (c *downloadCache) store(asset nexusapi.Asset, localPath string, verified bool) error {
+	entry, ok := c.entryPath(asset)
+	if !ok {
+		return nil
+	}
+	if !verified {
+		valid, err := c.validator.Validate(localPath, asset.Checksum)
+		if err != nil {
+			return err
+		}
+		if !valid {
+			return fmt.Errorf("downloaded file %s does not match %s checksum, not caching", localPath, c.algorithm)
+		}
+	}
+	if err := util.MkdirAll(filepath.Dir(entry), c.dirMode); err != nil {
+		return err
+	}
+	return linkOrCopy(localPath, entry)
+}
+
+// linkOrCopy atomically places the content of src at dst. It hardlinks when src and dst
+// are on the same filesystem and falls back to copying. The file is first created under a
+// temporary name next to dst and then renamed, so readers never observe a partial file.
+func linkOrCopy(src, dst string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	tmp.Close()
+	os.Remove(tmpPath)
+
+	if err := os.Link(src, tmpPath); err != nil {
+		if err := copyFile(src, tmpPath); err != nil {
+			os.Remove(tmpPath)
+			return err
+		}
+	}
+
+	// Never leave dst as a hardlink shared with a file that is later truncated
+	os.Remove(dst)
+	if err := os.Rename(tmpPath, dst); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
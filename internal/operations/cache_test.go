@@ -0,0 +1,189 @@
+package operations
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/tympanix/nexus-cli/internal/checksum"
+	"github.com/tympanix/nexus-cli/internal/config"
+	"github.com/tympanix/nexus-cli/internal/nexusapi"
+	"github.com/tympanix/nexus-cli/internal/util"
+)
+
+func newCacheTestOptions(t *testing.T, cacheDir string) *DownloadOptions {
+	t.Helper()
+	opts := &DownloadOptions{
+		Logger:    util.NewLogger(io.Discard),
+		QuietMode: true,
+		Recursive: true,
+		CacheDir:  cacheDir,
+	}
+	if err := opts.SetChecksumAlgorithm("sha256"); err != nil {
+		t.Fatal(err)
+	}
+	return opts
+}
+
+// TestDownloadCacheMissAndHit tests that a second download is served from the cache
+func TestDownloadCacheMissAndHit(t *testing.T) {
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+
+	server.AddAsset("test-repo", "/artifacts/a.bin", nexusapi.Asset{}, []byte("artifact a"))
+	server.AddAsset("test-repo", "/artifacts/sub/b.bin", nexusapi.Asset{}, []byte("artifact b"))
+
+	config := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+	cacheDir := t.TempDir()
+
+	// First job: cache miss, downloads and populates the cache
+	firstDest := t.TempDir()
+	if status := downloadFolder("test-repo/artifacts", firstDest, config, newCacheTestOptions(t, cacheDir)); status != DownloadSuccess {
+		t.Fatalf("First download failed with status %d", status)
+	}
+	if got := server.GetDownloadCount(); got != 2 {
+		t.Fatalf("Expected 2 downloads on cache miss, got %d", got)
+	}
+
+	// Second job: fresh destination, everything comes from the cache
+	secondDest := t.TempDir()
+	if status := downloadFolder("test-repo/artifacts", secondDest, config, newCacheTestOptions(t, cacheDir)); status != DownloadSuccess {
+		t.Fatalf("Second download failed with status %d", status)
+	}
+	if got := server.GetDownloadCount(); got != 2 {
+		t.Errorf("Expected no additional downloads on cache hit, got %d total", got)
+	}
+
+	content, err := os.ReadFile(filepath.Join(secondDest, "artifacts", "sub", "b.bin"))
+	if err != nil {
+		t.Fatalf("Expected file restored from cache: %v", err)
+	}
+	if string(content) != "artifact b" {
+		t.Errorf("Expected restored content %q, got %q", "artifact b", content)
+	}
+}
+
+// TestDownloadCacheCorruption tests that a corrupted cache entry is replaced by a fresh download
+func TestDownloadCacheCorruption(t *testing.T) {
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+
+	server.AddAsset("test-repo", "/artifacts/a.bin", nexusapi.Asset{}, []byte("artifact a"))
+
+	config := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+	cacheDir := t.TempDir()
+
+	if status := downloadFolder("test-repo/artifacts", t.TempDir(), config, newCacheTestOptions(t, cacheDir)); status != DownloadSuccess {
+		t.Fatalf("First download failed with status %d", status)
+	}
+
+	// Corrupt every cache entry
+	var entries []string
+	filepath.Walk(cacheDir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			entries = append(entries, path)
+		}
+		return nil
+	})
+	if len(entries) != 1 {
+		t.Fatalf("Expected 1 cache entry, got %v", entries)
+	}
+	if err := os.WriteFile(entries[0]+".corrupt", []byte("garbage"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(entries[0]+".corrupt", entries[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	dest := t.TempDir()
+	if status := downloadFolder("test-repo/artifacts", dest, config, newCacheTestOptions(t, cacheDir)); status != DownloadSuccess {
+		t.Fatalf("Download after corruption failed with status %d", status)
+	}
+	if got := server.GetDownloadCount(); got != 2 {
+		t.Errorf("Expected corrupted entry to trigger a re-download, got %d downloads", got)
+	}
+
+	content, err := os.ReadFile(filepath.Join(dest, "artifacts", "a.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "artifact a" {
+		t.Errorf("Expected downloaded content %q, got %q", "artifact a", content)
+	}
+
+	cached, err := os.ReadFile(entries[0])
+	if err != nil {
+		t.Fatalf("Expected cache entry to be repopulated: %v", err)
+	}
+	if string(cached) != "artifact a" {
+		t.Errorf("Expected repaired cache entry %q, got %q", "artifact a", cached)
+	}
+}
+
+// TestDownloadCacheConcurrentJobs tests that parallel jobs sharing a cache directory all get valid files
+func TestDownloadCacheConcurrentJobs(t *testing.T) {
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+
+	for _, name := range []string{"a.bin", "b.bin", "c.bin"} {
+		server.AddAsset("test-repo", "/artifacts/"+name, nexusapi.Asset{}, []byte("content of "+name))
+	}
+
+	config := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+	cacheDir := t.TempDir()
+
+	var wg sync.WaitGroup
+	dests := make([]string, 4)
+	statuses := make([]DownloadStatus, len(dests))
+	for i := range dests {
+		dests[i] = t.TempDir()
+		opts := newCacheTestOptions(t, cacheDir)
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			statuses[i] = downloadFolder("test-repo/artifacts", dests[i], config, opts)
+		}(i)
+	}
+	wg.Wait()
+
+	for i, dest := range dests {
+		if statuses[i] != DownloadSuccess {
+			t.Errorf("Job %d failed with status %d", i, statuses[i])
+		}
+		for _, name := range []string{"a.bin", "b.bin", "c.bin"} {
+			content, err := os.ReadFile(filepath.Join(dest, "artifacts", name))
+			if err != nil {
+				t.Errorf("Job %d: missing %s: %v", i, name, err)
+				continue
+			}
+			if string(content) != "content of "+name {
+				t.Errorf("Job %d: unexpected content for %s: %q", i, name, content)
+			}
+		}
+	}
+}
+
+// TestDownloadCacheSkippedWithoutChecksum tests that assets without a checksum bypass the cache
+func TestDownloadCacheSkippedWithoutChecksum(t *testing.T) {
+	validator, err := checksum.NewValidator("sha256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache := newDownloadCache(t.TempDir(), "test-repo", validator, 0)
+	if _, ok := cache.entryPath(nexusapi.Asset{Path: "/a.bin"}); ok {
+		t.Error("Expected asset without checksum not to have a cache entry")
+	}
+	if _, ok := cache.entryPath(nexusapi.Asset{Path: "/a.bin", Checksum: nexusapi.Checksum{SHA256: "ABC"}}); ok {
+		t.Error("Expected asset with malformed checksum not to have a cache entry")
+	}
+	entry, ok := cache.entryPath(nexusapi.Asset{Path: "/a.bin", Checksum: nexusapi.Checksum{SHA256: " " + strings.Repeat("AB", 32) + "\n"}})
+	if !ok {
+		t.Fatal("Expected asset with checksum to have a cache entry")
+	}
+	if filepath.Base(entry) != "sha256-"+strings.Repeat("ab", 32) {
+		t.Errorf("Expected normalized cache entry name, got %s", filepath.Base(entry))
+	}
+}
@@ -0,0 +1,229 @@
+package operations
+
+import (
+	"bytes"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/tympanix/nexus-cli/internal/checksum"
+	"github.com/tympanix/nexus-cli/internal/nexusapi"
+	"github.com/tympanix/nexus-cli/internal/util"
+)
+
+// MissingChecksumPolicy controls what happens to a downloaded file that is not listed in
+// the checksums file given with --checksum-from-file or --checksum-manifest
+type MissingChecksumPolicy string
+
+const (
+	MissingChecksumError MissingChecksumPolicy = "error" // Fail the file (default)
+	MissingChecksumWarn  MissingChecksumPolicy = "warn"  // Keep the file and print a warning
+	MissingChecksumNexus MissingChecksumPolicy = "nexus" // Keep the file if it matches the checksum reported by Nexus
+)
+
+// ParseMissingChecksumPolicy parses a string into a MissingChecksumPolicy
+func ParseMissingChecksumPolicy(s string) (MissingChecksumPolicy, error) {
+	switch strings.ToLower(s) {
+	case "", "error":
+		return MissingChecksumError, nil
+	case "warn":
+		return MissingChecksumWarn, nil
+	case "nexus":
+		return MissingChecksumNexus, nil
+	default:
+		return "", fmt.Errorf("unsupported missing checksum policy '%s': must be one of: error, warn, nexus", s)
+	}
+}
+
+// checksumList holds the digests of an external checksums file such as SHA256SUMS
+type checksumList struct {
+	source     string // As given on the command line, for messages
+	entries    map[string]checksum.SumsEntry
+	repository string // Repository and path of the checksums file if it was read from Nexus
+	assetPath  string
+
+	mu     sync.Mutex
+	failed []string // Why downloaded files failed validation against the checksums file
+}
+
+// loadChecksumList reads the checksums file at source. A local file is used if it exists;
+// otherwise source is looked up in Nexus as repository/path.
+func loadChecksumList(source string, client *nexusapi.Client) (*checksumList, error) {
+	data, err := os.ReadFile(source)
+	if os.IsNotExist(err) {
+		if _, _, ok := util.ParseRepositoryPath(source); !ok {
+			return nil, fmt.Errorf("checksums file %s not found", source)
+		}
+		return downloadChecksumList(source, client)
+	} else if err != nil {
+		return nil, err
+	}
+	return parseChecksumList(source, data)
+}
+
+// downloadChecksumList reads the checksums file at source, given as repository/path, from
+// Nexus before anything else is downloaded
+func downloadChecksumList(source string, client *nexusapi.Client) (*checksumList, error) {
+	repository, assetPath, ok := util.ParseRepositoryPath(source)
+	if !ok {
+		return nil, fmt.Errorf("checksums file %s must be given as repository/path", source)
+	}
+	asset, err := findAsset(client, repository, assetPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up checksums file %s: %w", source, err)
+	}
+	if asset == nil {
+		return nil, fmt.Errorf("checksums file %s not found in Nexus", source)
+	}
+	var buf bytes.Buffer
+	if err := client.DownloadAsset(asset.DownloadURL, &buf); err != nil {
+		return nil, fmt.Errorf("failed to download checksums file %s: %w", source, err)
+	}
+	list, err := parseChecksumList(source, buf.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	list.repository = repository
+	list.assetPath = strings.TrimLeft(asset.Path, "/")
+	return list, nil
+}
+
+// parseChecksumList parses the content of the checksums file read from source
+func parseChecksumList(source string, data []byte) (*checksumList, error) {
+	entries, err := checksum.ParseSumsFile(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid checksums file %s: %w", source, err)
+	}
+	return &checksumList{source: source, entries: entries}, nil
+}
+
+// failures returns why downloaded files failed validation against the checksums file,
+// sorted, or nil if no checksums file is used
+func (l *checksumList) failures() []string {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	failed := append([]string(nil), l.failed...)
+	sort.Strings(failed)
+	return failed
+}
+
+// lookup returns the entry for an asset. Names are tried relative to the folder of a
+// checksums file read from Nexus, relative to the download source, as the full path in
+// the repository and finally as the bare file name.
+func (l *checksumList) lookup(asset nexusapi.Asset, basePath string) (checksum.SumsEntry, string, bool) {
+	var candidates []string
+	if l.repository != "" {
+		if folder := path.Dir(l.assetPath); folder != "." {
+			candidates = append(candidates, getRelativePath(asset.Path, folder))
+		}
+	}
+	candidates = append(candidates, getRelativePath(asset.Path, basePath), getRelativePath(asset.Path, ""), path.Base(asset.Path))
+	for _, name := range candidates {
+		if entry, ok := l.entries[name]; ok {
+			return entry, name, true
+		}
+	}
+	return checksum.SumsEntry{}, candidates[0], false
+}
+
+// listedChecksum is the expected digest of one downloaded file, with the hash the
+// downloaded data is fed into
+type listedChecksum struct {
+	list  *checksumList
+	name  string
+	entry checksum.SumsEntry
+	hash  hash.Hash // nil if the file is not listed
+}
+
+// expectListedChecksum looks up an asset in the checksums file. It returns nil if no
+// checksums file is used or the asset is the checksums file itself.
+func expectListedChecksum(asset nexusapi.Asset, basePath string, opts *DownloadOptions) *listedChecksum {
+	list := opts.checksums
+	if list == nil || (list.repository != "" && strings.TrimLeft(asset.Path, "/") == list.assetPath) {
+		return nil
+	}
+	entry, name, ok := list.lookup(asset, basePath)
+	expected := &listedChecksum{list: list, name: name, entry: entry}
+	if ok {
+		expected.hash, _ = checksum.NewHasher(entry.Algorithm)
+	}
+	return expected
+}
+
+// writer returns the hash to write the downloaded data to, or nil
+func (c *listedChecksum) writer() io.Writer {
+	if c == nil || c.hash == nil {
+		return nil
+	}
+	return c.hash
+}
+
+// verify checks the digest of the data written to the hash against the checksums file. A
+// failure is recorded for the summary, see checksumList.failures.
+func (c *listedChecksum) verify(opts *DownloadOptions) error {
+	if c == nil {
+		return nil
+	}
+	var err error
+	if c.hash == nil {
+		err = c.missing(opts)
+	} else {
+		err = c.compare(fmt.Sprintf("%x", c.hash.Sum(nil)))
+	}
+	if err != nil {
+		c.list.mu.Lock()
+		c.list.failed = append(c.list.failed, err.Error())
+		c.list.mu.Unlock()
+	}
+	return err
+}
+
+// verifyFile checks a file that was not downloaded, e.g. one restored from the cache,
+// against the checksums file
+func (c *listedChecksum) verifyFile(localPath string, opts *DownloadOptions) error {
+	if c == nil {
+		return nil
+	}
+	if c.hash == nil {
+		return c.missing(opts)
+	}
+	actual, err := checksum.ComputeChecksum(localPath, c.entry.Algorithm)
+	if err != nil {
+		return err
+	}
+	return c.compare(actual)
+}
+
+func (c *listedChecksum) compare(actual string) error {
+	match, err := checksum.Matches(c.entry.Digest, actual, c.entry.Algorithm)
+	if err != nil {
+		return err
+	}
+	if !match {
+		return fmt.Errorf("checksum mismatch for %s: %s lists %s %s, got %s", c.name, c.list.source, c.entry.Algorithm, c.entry.Digest, actual)
+	}
+	return nil
+}
+
+func (c *listedChecksum) missing(opts *DownloadOptions) error {
+	switch opts.OnMissingChecksum {
+	case MissingChecksumWarn:
+		if !opts.QuietMode {
+			opts.Logger.Printf("Warning: %s is not listed in %s\n", c.name, c.list.source)
+		}
+		return nil
+	case MissingChecksumNexus:
+		// The file has already been verified like any other download, see verifyDownload
+		opts.Logger.VerbosePrintf("%s is not listed in %s; verified against the checksum reported by Nexus\n", c.name, c.list.source)
+		return nil
+	}
+	return fmt.Errorf("%s is not listed in %s", c.name, c.list.source)
+}
@@ -0,0 +1,174 @@
+package operations
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tympanix/nexus-cli/internal/config"
+	"github.com/tympanix/nexus-cli/internal/nexusapi"
+	"github.com/tympanix/nexus-cli/internal/util"
+)
+
+func sha256Line(content, name string) string {
+	return fmt.Sprintf("%x  %s\n", sha256.Sum256([]byte(content)), name)
+}
+
+func newChecksumListTest(t *testing.T) (*nexusapi.MockNexusServer, *config.Config) {
+	t.Helper()
+	server := nexusapi.NewMockNexusServer()
+	t.Cleanup(server.Close)
+	server.AddAsset("raw", "/release/app.tar.gz", nexusapi.Asset{}, []byte("app"))
+	server.AddAsset("raw", "/release/docs/readme.txt", nexusapi.Asset{}, []byte("readme"))
+	return server, &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+}
+
+func writeSumsFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "SHA256SUMS")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestChecksumFromFileMatches(t *testing.T) {
+	_, config := newChecksumListTest(t)
+	sums := writeSumsFile(t, sha256Line("app", "app.tar.gz")+sha256Line("readme", "docs/readme.txt"))
+
+	destDir := t.TempDir()
+	opts := &DownloadOptions{Logger: util.NewLogger(io.Discard), QuietMode: true, Recursive: true, ChecksumFile: sums}
+	if status := downloadFolder("raw/release", destDir, config, opts); status != DownloadSuccess {
+		t.Fatalf("Expected download to succeed, got status %d", status)
+	}
+	if _, err := os.Stat(filepath.Join(destDir, "release", "docs", "readme.txt")); err != nil {
+		t.Errorf("Expected readme.txt to be downloaded: %v", err)
+	}
+}
+
+func TestChecksumFromFileMismatch(t *testing.T) {
+	_, config := newChecksumListTest(t)
+	sums := writeSumsFile(t, sha256Line("tampered", "app.tar.gz")+sha256Line("readme", "docs/readme.txt"))
+
+	destDir := t.TempDir()
+	opts := &DownloadOptions{Logger: util.NewLogger(io.Discard), QuietMode: true, Recursive: true, ChecksumFile: sums}
+	if status := downloadFolder("raw/release", destDir, config, opts); status != DownloadError {
+		t.Fatalf("Expected download to fail on a checksum mismatch, got status %d", status)
+	}
+	if _, err := os.Stat(filepath.Join(destDir, "release", "app.tar.gz")); !os.IsNotExist(err) {
+		t.Error("Expected the mismatching file not to be placed")
+	}
+	if _, err := os.Stat(filepath.Join(destDir, "release", "docs", "readme.txt")); err != nil {
+		t.Errorf("Expected the matching file to be downloaded: %v", err)
+	}
+}
+
+func TestChecksumFromFileMissingEntry(t *testing.T) {
+	_, config := newChecksumListTest(t)
+	sums := writeSumsFile(t, sha256Line("app", "app.tar.gz"))
+
+	opts := &DownloadOptions{Logger: util.NewLogger(io.Discard), QuietMode: true, Recursive: true, ChecksumFile: sums}
+	if status := downloadFolder("raw/release", t.TempDir(), config, opts); status != DownloadError {
+		t.Errorf("Expected an unlisted file to fail the download by default, got status %d", status)
+	}
+
+	opts = &DownloadOptions{Logger: util.NewLogger(io.Discard), QuietMode: true, Recursive: true, ChecksumFile: sums, OnMissingChecksum: MissingChecksumWarn}
+	if status := downloadFolder("raw/release", t.TempDir(), config, opts); status != DownloadSuccess {
+		t.Errorf("Expected an unlisted file to be accepted with a warning, got status %d", status)
+	}
+}
+
+// TestChecksumFromFileInRepository reads SHA256SUMS from the folder it describes, which is
+// downloaded along with the files without being listed in itself
+func TestChecksumFromFileInRepository(t *testing.T) {
+	server, config := newChecksumListTest(t)
+	server.AddAsset("raw", "/release/SHA256SUMS", nexusapi.Asset{}, []byte(sha256Line("app", "app.tar.gz")+sha256Line("readme", "docs/readme.txt")))
+
+	opts := &DownloadOptions{Logger: util.NewLogger(io.Discard), QuietMode: true, Recursive: true, ChecksumFile: "raw/release/SHA256SUMS"}
+	if status := downloadFolder("raw/release/docs", t.TempDir(), config, opts); status != DownloadSuccess {
+		t.Fatalf("Expected download to succeed, got status %d", status)
+	}
+
+	opts = &DownloadOptions{Logger: util.NewLogger(io.Discard), QuietMode: true, Recursive: true, ChecksumFile: "raw/release/SHA256SUMS"}
+	if status := downloadFolder("raw/release", t.TempDir(), config, opts); status != DownloadSuccess {
+		t.Fatalf("Expected download including the checksums file to succeed, got status %d", status)
+	}
+
+	opts = &DownloadOptions{Logger: util.NewLogger(io.Discard), QuietMode: true, Recursive: true, ChecksumFile: "raw/release/MISSING"}
+	if status := downloadFolder("raw/release", t.TempDir(), config, opts); status != DownloadError {
+		t.Errorf("Expected a missing checksums file to fail the download, got status %d", status)
+	}
+}
+
+// TestChecksumManifest fetches a manifest with binary mode markers and CRLF line endings
+// from Nexus, and lists the files that fail validation against it in the summary
+func TestChecksumManifest(t *testing.T) {
+	server, config := newChecksumListTest(t)
+	server.AddAsset("raw", "/release/extra.bin", nexusapi.Asset{}, []byte("extra"))
+	manifest := strings.ReplaceAll(sha256Line("app", "*app.tar.gz")+sha256Line("tampered", "docs/readme.txt"), "\n", "\r\n")
+	server.AddAsset("raw", "/SHA256SUMS", nexusapi.Asset{}, []byte(strings.Replace(manifest, "  *", " *", 1)))
+
+	var logBuf strings.Builder
+	opts := &DownloadOptions{Logger: util.NewLogger(&logBuf), QuietMode: true, Recursive: true, ChecksumManifest: "raw/SHA256SUMS"}
+	if err := opts.SetChecksumAlgorithm("sha256"); err != nil {
+		t.Fatal(err)
+	}
+	destDir := t.TempDir()
+	if status := downloadFolder("raw/release", destDir, config, opts); status != DownloadError {
+		t.Fatalf("Expected the download to fail, got status %d", status)
+	}
+	if _, err := os.Stat(filepath.Join(destDir, "release", "app.tar.gz")); err != nil {
+		t.Errorf("Expected the file matching the manifest to be downloaded: %v", err)
+	}
+	for _, want := range []string{
+		"2 file(s) failed validation against raw/SHA256SUMS:\n",
+		"  checksum mismatch for docs/readme.txt: raw/SHA256SUMS lists sha256",
+		"  extra.bin is not listed in raw/SHA256SUMS\n",
+	} {
+		if !strings.Contains(logBuf.String(), want) {
+			t.Errorf("Expected %q in the summary, got: %s", want, logBuf.String())
+		}
+	}
+
+	// With the Nexus fallback, only the listed file that does not match fails
+	logBuf.Reset()
+	opts = &DownloadOptions{Logger: util.NewLogger(&logBuf), QuietMode: true, Recursive: true, ChecksumManifest: "raw/SHA256SUMS", OnMissingChecksum: MissingChecksumNexus}
+	if err := opts.SetChecksumAlgorithm("sha256"); err != nil {
+		t.Fatal(err)
+	}
+	destDir = t.TempDir()
+	if status := downloadFolder("raw/release", destDir, config, opts); status != DownloadError {
+		t.Fatalf("Expected the download to fail, got status %d", status)
+	}
+	if _, err := os.Stat(filepath.Join(destDir, "release", "extra.bin")); err != nil {
+		t.Errorf("Expected the unlisted file to be verified against Nexus and kept: %v", err)
+	}
+	if !strings.Contains(logBuf.String(), "1 file(s) failed validation against raw/SHA256SUMS:\n") {
+		t.Errorf("Expected only the mismatching file in the summary, got: %s", logBuf.String())
+	}
+
+	// A manifest is never read from the local disk
+	opts = &DownloadOptions{Logger: util.NewLogger(io.Discard), QuietMode: true, Recursive: true, ChecksumManifest: writeSumsFile(t, sha256Line("app", "app.tar.gz"))}
+	if status := downloadFolder("raw/release", t.TempDir(), config, opts); status != DownloadError {
+		t.Errorf("Expected a local manifest to fail the download, got status %d", status)
+	}
+}
+
+func TestParseMissingChecksumPolicy(t *testing.T) {
+	if policy, err := ParseMissingChecksumPolicy(""); err != nil || policy != MissingChecksumError {
+		t.Errorf("Expected error to be the default, got %q, %v", policy, err)
+	}
+	if policy, err := ParseMissingChecksumPolicy("WARN"); err != nil || policy != MissingChecksumWarn {
+		t.Errorf("Expected warn, got %q, %v", policy, err)
+	}
+	if policy, err := ParseMissingChecksumPolicy("nexus"); err != nil || policy != MissingChecksumNexus {
+		t.Errorf("Expected nexus, got %q, %v", policy, err)
+	}
+	if _, err := ParseMissingChecksumPolicy("ignore"); err == nil {
+		t.Error("Expected an error for an unknown policy")
+	}
+}
@@ -0,0 +1,337 @@
+package operations
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tympanix/nexus-cli/internal/checksum"
+	"github.com/tympanix/nexus-cli/internal/nexusapi"
+	"github.com/tympanix/nexus-cli/internal/output"
+	"github.com/tympanix/nexus-cli/internal/progress"
+)
+
+// ChunkManifestSuffix is appended to the path of a file uploaded in parts with --chunked
+// to name the manifest that lists the parts
+const ChunkManifestSuffix = ".parts.json"
+
+// ChunkManifest describes a file uploaded in parts. The parts are stored next to the
+// manifest as <name>.part0001, <name>.part0002, ... and the manifest is uploaded last, so
+// a file without a manifest has not been uploaded completely.
+type ChunkManifest struct {
+	Name     string            `json:"name"`     // Name of the reassembled file
+	Size     int64             `json:"size"`     // Size of the reassembled file
+	Checksum nexusapi.Checksum `json:"checksum"` // Checksums of the reassembled file
+	Parts    []ChunkPart       `json:"parts"`    // Parts in the order they are concatenated
+}
+
+// ChunkPart is one part of a file uploaded with --chunked
+type ChunkPart struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// chunkPartName returns the name of part n (counting from 1) of the file name
+func chunkPartName(name string, n int) string {
+	return fmt.Sprintf("%s.part%04d", name, n)
+}
+
+// chunkPartIndex returns the number of partName if it is a part of the file name
+func chunkPartIndex(partName, name string) (int, bool) {
+	n, ok := strings.CutPrefix(partName, name+".part")
+	if !ok || len(n) < 4 {
+		return 0, false
+	}
+	index, err := strconv.Atoi(n)
+	return index, err == nil && index > 0
+}
+
+// wholeFileHash computes the checksums Nexus reports for an asset in a single pass
+type wholeFileHash struct {
+	hashes map[string]hash.Hash
+	writer io.Writer
+}
+
+func newWholeFileHash() *wholeFileHash {
+	h := &wholeFileHash{hashes: make(map[string]hash.Hash)}
+	var writers []io.Writer
+	for _, algorithm := range []string{"sha1", "sha256", "sha512", "md5"} {
+		hasher, _ := checksum.NewHasher(algorithm)
+		h.hashes[algorithm] = hasher
+		writers = append(writers, hasher)
+	}
+	h.writer = io.MultiWriter(writers...)
+	return h
+}
+
+func (h *wholeFileHash) Write(p []byte) (int, error) {
+	return h.writer.Write(p)
+}
+
+func (h *wholeFileHash) checksum() nexusapi.Checksum {
+	sum := func(algorithm string) string { return fmt.Sprintf("%x", h.hashes[algorithm].Sum(nil)) }
+	return nexusapi.Checksum{SHA1: sum("sha1"), SHA256: sum("sha256"), SHA512: sum("sha512"), MD5: sum("md5")}
+}
+
+// uploadChunkedFile uploads filePath to remotePath in parts of opts.ChunkSize bytes,
+// followed by its manifest. Parts already present with the same checksum, e.g. from an
+// interrupted upload, are not uploaded again unless opts.Force is set. Once the manifest
+// is in place, parts left over from an earlier upload with more parts are deleted.
+func uploadChunkedFile(client *nexusapi.Client, repository, filePath, remotePath string, bar *progress.ProgressBarWithCount, tracker *output.TransferTracker, opts *UploadOptions) error {
+	startTime := time.Now()
+	name := path.Base(remotePath)
+	dir := path.Dir(remotePath)
+
+	existing := make(map[string]nexusapi.Asset)
+	assets, err := client.SearchAssets(repository, remotePath+".part")
+	if err != nil {
+		return fmt.Errorf("failed to list parts of %s: %w", remotePath, err)
+	}
+	for _, asset := range assets {
+		if assetPath := strings.TrimLeft(asset.Path, "/"); path.Dir(assetPath) == dir {
+			existing[path.Base(assetPath)] = asset
+		}
+	}
+
+	f, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	manifest := ChunkManifest{Name: name, Size: info.Size()}
+	whole := newWholeFileHash()
+	uploaded := 0
+	nParts := int((info.Size() + opts.ChunkSize - 1) / opts.ChunkSize)
+	for i := 0; i < nParts; i++ {
+		offset := int64(i) * opts.ChunkSize
+		size := min(opts.ChunkSize, info.Size()-offset)
+		part := ChunkPart{Name: chunkPartName(name, i+1), Size: size}
+
+		partHash := sha256.New()
+		if _, err := io.Copy(io.MultiWriter(partHash, whole), io.NewSectionReader(f, offset, size)); err != nil {
+			return err
+		}
+		part.SHA256 = fmt.Sprintf("%x", partHash.Sum(nil))
+		manifest.Parts = append(manifest.Parts, part)
+
+		if asset, ok := existing[part.Name]; ok && !opts.Force && strings.EqualFold(asset.Checksum.SHA256, part.SHA256) {
+			opts.Logger.VerbosePrintf("Skipped (part already uploaded): %s\n", part.Name)
+			bar.Skip(size)
+			continue
+		}
+		bar.StartFile(part.Name)
+		err := client.UploadRawAsset(repository, path.Join(dir, part.Name), io.TeeReader(io.NewSectionReader(f, offset, size), bar))
+		bar.FinishFile(part.Name)
+		if err != nil {
+			err = fmt.Errorf("failed to upload part %d of %d of %s: %w", i+1, nParts, remotePath, err)
+			tracker.RecordFile(output.FileTransfer{Path: remotePath, Size: info.Size(), Status: output.TransferStatusFailed, Error: err, StartTime: startTime, EndTime: time.Now()})
+			return err
+		}
+		uploaded++
+	}
+	manifest.Checksum = whole.checksum()
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	manifestName := name + ChunkManifestSuffix
+	status := output.TransferStatusSuccess
+	if asset, ok := existing[manifestName]; ok && uploaded == 0 && strings.EqualFold(asset.Checksum.SHA256, fmt.Sprintf("%x", sha256.Sum256(data))) {
+		status = output.TransferStatusSkipped
+	} else if err := client.UploadRawAsset(repository, path.Join(dir, manifestName), bytes.NewReader(data)); err != nil {
+		err = fmt.Errorf("failed to upload %s: %w", manifestName, err)
+		tracker.RecordFile(output.FileTransfer{Path: remotePath, Size: info.Size(), Status: output.TransferStatusFailed, Error: err, StartTime: startTime, EndTime: time.Now()})
+		return err
+	}
+	opts.Logger.VerbosePrintf("Uploaded %s in %d part(s), %d of them new\n", remotePath, nParts, uploaded)
+
+	for partName, asset := range existing {
+		if index, ok := chunkPartIndex(partName, name); !ok || index <= nParts {
+			continue
+		}
+		if err := client.DeleteAsset(asset.ID); err != nil {
+			opts.Logger.Printf("Warning: could not delete leftover part %s: %v\n", partName, err)
+			continue
+		}
+		opts.Logger.VerbosePrintf("Deleted leftover part %s\n", partName)
+	}
+
+	tracker.RecordFile(output.FileTransfer{Path: remotePath, Size: info.Size(), Status: status, StartTime: startTime, EndTime: time.Now()})
+	bar.IncrementFile()
+	return nil
+}
+
+// chunkedFile is a file uploaded with --chunked, found in a download listing
+type chunkedFile struct {
+	manifest ChunkManifest
+	parts    []nexusapi.Asset // In the order of manifest.Parts
+}
+
+// splitChunkedFiles replaces the manifest and the parts of every file uploaded with
+// --chunked by a single asset for the reassembled file, with the size and checksums
+// recorded in the manifest. The returned map holds the parts by the path of that asset.
+func splitChunkedFiles(client *nexusapi.Client, assets []nexusapi.Asset) ([]nexusapi.Asset, map[string]*chunkedFile, error) {
+	byPath := make(map[string]nexusapi.Asset, len(assets))
+	for _, asset := range assets {
+		byPath[strings.TrimLeft(asset.Path, "/")] = asset
+	}
+
+	files := make(map[string]*chunkedFile)
+	used := make(map[string]bool)
+	for _, asset := range assets {
+		if !strings.HasSuffix(asset.Path, ChunkManifestSuffix) {
+			continue
+		}
+		var buf bytes.Buffer
+		if err := client.DownloadAsset(asset.DownloadURL, &buf); err != nil {
+			return nil, nil, fmt.Errorf("failed to read %s: %w", asset.Path, err)
+		}
+		file := &chunkedFile{}
+		if err := json.Unmarshal(buf.Bytes(), &file.manifest); err != nil {
+			return nil, nil, fmt.Errorf("invalid manifest %s: %w", asset.Path, err)
+		}
+		dir := path.Dir(strings.TrimLeft(asset.Path, "/"))
+		for _, part := range file.manifest.Parts {
+			if part.Name != path.Base(part.Name) {
+				return nil, nil, fmt.Errorf("invalid manifest %s: part name %q is not a file name", asset.Path, part.Name)
+			}
+			partAsset, ok := byPath[path.Join(dir, part.Name)]
+			if !ok {
+				return nil, nil, fmt.Errorf("%s lists part %s, which is missing", asset.Path, part.Name)
+			}
+			file.parts = append(file.parts, partAsset)
+			used[partAsset.Path] = true
+		}
+		files[strings.TrimSuffix(asset.Path, ChunkManifestSuffix)] = file
+	}
+	if len(files) == 0 {
+		return assets, nil, nil
+	}
+	// Parts left over from an earlier upload with more parts are not listed in the manifest
+	for _, asset := range assets {
+		if i := strings.LastIndex(asset.Path, ".part"); i > 0 {
+			filePath := asset.Path[:i]
+			if _, ok := chunkPartIndex(path.Base(asset.Path), path.Base(filePath)); ok && files[filePath] != nil {
+				used[asset.Path] = true
+			}
+		}
+	}
+
+	// The reassembled file takes the place of its manifest in the listing order
+	var result []nexusapi.Asset
+	for _, asset := range assets {
+		if used[asset.Path] {
+			continue
+		}
+		filePath := strings.TrimSuffix(asset.Path, ChunkManifestSuffix)
+		if file, ok := files[filePath]; ok {
+			asset = nexusapi.Asset{
+				Path:         filePath,
+				Repository:   asset.Repository,
+				Format:       asset.Format,
+				FileSize:     file.manifest.Size,
+				Checksum:     file.manifest.Checksum,
+				LastModified: asset.LastModified,
+			}
+		}
+		result = append(result, asset)
+	}
+	return result, files, nil
+}
+
+// chunkPartPath returns the local path of part n (counting from 1) of localPath
+func chunkPartPath(localPath string, n int) string {
+	return chunkPartName(localPath, n)
+}
+
+// download writes the reassembled file to w. Each part is kept next to localPath once it
+// has been downloaded and verified, so an interrupted download resumes with the parts that
+// are missing. The reassembled content is verified against the manifest before returning.
+func (c *chunkedFile) download(client *nexusapi.Client, localPath string, w io.Writer, opts *DownloadOptions) error {
+	whole := sha256.New()
+	out := io.MultiWriter(w, whole)
+	for i, part := range c.manifest.Parts {
+		partPath := chunkPartPath(localPath, i+1)
+		if sum, err := checksum.ComputeChecksum(partPath, "sha256"); err == nil && strings.EqualFold(sum, part.SHA256) {
+			opts.Logger.VerbosePrintf("Reusing downloaded part %s\n", partPath)
+			if err := copyFileTo(partPath, out); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := downloadChunkPart(client, c.parts[i], part, partPath, out); err != nil {
+			return err
+		}
+	}
+	if actual := fmt.Sprintf("%x", whole.Sum(nil)); !strings.EqualFold(actual, c.manifest.Checksum.SHA256) {
+		return fmt.Errorf("checksum mismatch for reassembled %s: expected %s, got %s", c.manifest.Name, c.manifest.Checksum.SHA256, actual)
+	}
+	return nil
+}
+
+// downloadChunkPart downloads a part to partPath, also writing it to out, and verifies it
+func downloadChunkPart(client *nexusapi.Client, asset nexusapi.Asset, part ChunkPart, partPath string, out io.Writer) error {
+	f, err := os.Create(partPath)
+	if err != nil {
+		return err
+	}
+	h := sha256.New()
+	err = client.DownloadAsset(asset.DownloadURL, io.MultiWriter(f, h, out))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && !strings.EqualFold(fmt.Sprintf("%x", h.Sum(nil)), part.SHA256) {
+		err = fmt.Errorf("checksum mismatch for part %s", asset.Path)
+	}
+	if err != nil {
+		os.Remove(partPath)
+		return err
+	}
+	return nil
+}
+
+// copyFileTo writes the content of the file at filePath to w
+func copyFileTo(filePath string, w io.Writer) error {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// removeChunkParts removes the local parts of localPath once it has been reassembled,
+// including any left over from an earlier download with more parts
+func removeChunkParts(localPath string, opts *DownloadOptions) {
+	entries, err := os.ReadDir(filepath.Dir(localPath))
+	if err != nil {
+		return
+	}
+	name := filepath.Base(localPath)
+	for _, entry := range entries {
+		if _, ok := chunkPartIndex(entry.Name(), name); !ok {
+			continue
+		}
+		partPath := filepath.Join(filepath.Dir(localPath), entry.Name())
+		if err := os.Remove(partPath); err != nil {
+			opts.Logger.VerbosePrintf("Could not remove part %s: %v\n", partPath, err)
+		}
+	}
+}
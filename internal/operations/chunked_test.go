@@ -0,0 +1,178 @@
+package operations
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tympanix/nexus-cli/internal/config"
+	"github.com/tympanix/nexus-cli/internal/nexusapi"
+	"github.com/tympanix/nexus-cli/internal/util"
+)
+
+const chunkedContent = "0123456789"
+
+// newChunkedUploadTest creates a source folder with a file larger than the chunk size
+// and a small one, and a server that keeps the uploads
+func newChunkedUploadTest(t *testing.T) (string, *nexusapi.MockNexusServer, *config.Config) {
+	t.Helper()
+	srcDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(srcDir, "big.bin"), []byte(chunkedContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(srcDir, "small.txt"), []byte("abc"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	server := nexusapi.NewMockNexusServer()
+	t.Cleanup(server.Close)
+	server.StoreUploads = true
+	return srcDir, server, &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+}
+
+func TestUploadChunked(t *testing.T) {
+	srcDir, server, config := newChunkedUploadTest(t)
+
+	opts := &UploadOptions{Logger: util.NewLogger(io.Discard), QuietMode: true, ChunkSize: 4}
+	if err := uploadFiles(srcDir, "raw", "dist", config, opts); err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+
+	expected := []string{"/dist/big.bin.part0001", "/dist/big.bin.part0002", "/dist/big.bin.part0003", "/dist/big.bin.parts.json", "/dist/small.txt"}
+	if got := uploadedPaths(server); strings.Join(got, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected uploads %v, got %v", expected, got)
+	}
+	if part := findUpload(server, "/dist/big.bin.part0003"); part == nil || string(part.Content) != "89" {
+		t.Errorf("Expected the last part to hold the rest of the file, got %+v", part)
+	}
+
+	var manifest ChunkManifest
+	if err := json.Unmarshal(findUpload(server, "/dist/big.bin.parts.json").Content, &manifest); err != nil {
+		t.Fatalf("Invalid manifest: %v", err)
+	}
+	if manifest.Name != "big.bin" || manifest.Size != 10 || len(manifest.Parts) != 3 || manifest.Parts[2].Size != 2 {
+		t.Errorf("Unexpected manifest: %+v", manifest)
+	}
+	if manifest.Checksum.SHA256 == "" || manifest.Checksum.SHA1 == "" {
+		t.Errorf("Expected the manifest to record the checksums of the whole file, got %+v", manifest.Checksum)
+	}
+}
+
+// TestUploadChunkedResume tests that parts already uploaded are kept and leftover parts are deleted
+func TestUploadChunkedResume(t *testing.T) {
+	srcDir, server, config := newChunkedUploadTest(t)
+	server.AddAsset("raw", "/dist/big.bin.part0001", nexusapi.Asset{}, []byte("0123"))
+	server.AddAsset("raw", "/dist/big.bin.part0002", nexusapi.Asset{}, []byte("xxxx")) // Interrupted
+	server.AddAsset("raw", "/dist/big.bin.part0004", nexusapi.Asset{}, []byte("old"))  // From a smaller chunk size
+
+	opts := &UploadOptions{Logger: util.NewLogger(io.Discard), QuietMode: true, ChunkSize: 4}
+	if err := uploadFiles(srcDir, "raw", "dist", config, opts); err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+
+	expected := []string{"/dist/big.bin.part0002", "/dist/big.bin.part0003", "/dist/big.bin.parts.json", "/dist/small.txt"}
+	if got := uploadedPaths(server); strings.Join(got, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected uploads %v, got %v", expected, got)
+	}
+	if deleted := server.GetDeletedAssets(); len(deleted) != 1 || deleted[0] != "raw:/dist/big.bin.part0004" {
+		t.Errorf("Expected the leftover part to be deleted, got %v", deleted)
+	}
+
+	// Nothing is uploaded again once all parts and the manifest are in place
+	server.UploadedFiles = make([]nexusapi.UploadedFile, 0)
+	if err := uploadFiles(srcDir, "raw", "dist", config, &UploadOptions{Logger: util.NewLogger(io.Discard), QuietMode: true, ChunkSize: 4, SkipChecksum: true}); err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	if got := uploadedPaths(server); len(got) != 0 {
+		t.Errorf("Expected nothing to be uploaded again, got %v", got)
+	}
+}
+
+func TestDownloadChunked(t *testing.T) {
+	srcDir, _, config := newChunkedUploadTest(t)
+	if err := uploadFiles(srcDir, "raw", "dist", config, &UploadOptions{Logger: util.NewLogger(io.Discard), QuietMode: true, ChunkSize: 4}); err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+
+	newOpts := func() *DownloadOptions {
+		opts := &DownloadOptions{Logger: util.NewLogger(io.Discard), QuietMode: true, Recursive: true, Chunked: true}
+		if err := opts.SetChecksumAlgorithm("sha1"); err != nil {
+			t.Fatal(err)
+		}
+		return opts
+	}
+
+	destDir := t.TempDir()
+	if status := downloadFolder("raw/dist", destDir, config, newOpts()); status != DownloadSuccess {
+		t.Fatalf("Expected download to succeed, got %v", status)
+	}
+	entries, _ := os.ReadDir(filepath.Join(destDir, "dist"))
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if strings.Join(names, ",") != "big.bin,small.txt" {
+		t.Errorf("Expected only the reassembled file and the small file, got %v", names)
+	}
+	if data, _ := os.ReadFile(filepath.Join(destDir, "dist", "big.bin")); string(data) != chunkedContent {
+		t.Errorf("Expected the reassembled content, got %q", data)
+	}
+
+	// A single file uploaded in parts is found by its own path
+	singleDir := t.TempDir()
+	opts := newOpts()
+	opts.Recursive = false
+	if status := downloadFolder("raw/dist/big.bin", singleDir, config, opts); status != DownloadSuccess {
+		t.Fatalf("Expected single file download to succeed, got %v", status)
+	}
+	if data, _ := os.ReadFile(filepath.Join(singleDir, "dist", "big.bin")); string(data) != chunkedContent {
+		t.Errorf("Expected the reassembled content, got %q", data)
+	}
+}
+
+// TestDownloadChunkedResume tests that verified local parts are reused and that a file
+// whose parts do not match the manifest is never written under its final name
+func TestDownloadChunkedResume(t *testing.T) {
+	srcDir, server, config := newChunkedUploadTest(t)
+	if err := uploadFiles(srcDir, "raw", "dist", config, &UploadOptions{Logger: util.NewLogger(io.Discard), QuietMode: true, ChunkSize: 4}); err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+
+	destDir := t.TempDir()
+	localPath := filepath.Join(destDir, "dist", "big.bin")
+	os.MkdirAll(filepath.Dir(localPath), 0755)
+	os.WriteFile(localPath+".part0001", []byte("0123"), 0644)
+	os.WriteFile(localPath+".part0002", []byte("45"), 0644) // Interrupted
+
+	opts := &DownloadOptions{Logger: util.NewLogger(io.Discard), QuietMode: true, Recursive: true, Chunked: true, GlobPattern: "**/big.bin"}
+	before := server.GetDownloadCount()
+	if status := downloadFolder("raw/dist", destDir, config, opts); status != DownloadSuccess {
+		t.Fatalf("Expected download to succeed, got %v", status)
+	}
+	// The manifest and the two parts that were missing or incomplete
+	if downloads := server.GetDownloadCount() - before; downloads != 3 {
+		t.Errorf("Expected 3 downloads, got %d", downloads)
+	}
+	if data, _ := os.ReadFile(localPath); string(data) != chunkedContent {
+		t.Errorf("Expected the reassembled content, got %q", data)
+	}
+	if matches, _ := filepath.Glob(localPath + ".part*"); len(matches) != 0 {
+		t.Errorf("Expected the parts to be removed, got %v", matches)
+	}
+
+	// A corrupted part fails the download and keeps the verified parts
+	os.Remove(localPath)
+	server.AddAsset("raw", "/dist/big.bin.part0003", nexusapi.Asset{}, []byte("XX"))
+	opts = &DownloadOptions{Logger: util.NewLogger(io.Discard), QuietMode: true, Recursive: true, Chunked: true, GlobPattern: "**/big.bin"}
+	if status := downloadFolder("raw/dist", destDir, config, opts); status != DownloadError {
+		t.Fatalf("Expected download to fail, got %v", status)
+	}
+	if _, err := os.Stat(localPath); !os.IsNotExist(err) {
+		t.Error("Expected the file not to be written under its final name")
+	}
+	if _, err := os.Stat(localPath + ".part0002"); err != nil {
+		t.Errorf("Expected verified parts to be kept for the next attempt: %v", err)
+	}
+}
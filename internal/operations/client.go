@@ -0,0 +1,18 @@
+package operations
+
+import (
+	"github.com/tympanix/nexus-cli/internal/config"
+	"github.com/tympanix/nexus-cli/internal/nexusapi"
+)
+
+// NewClient creates a client for the server of config, with its Unix socket, TLS settings,
+// token and headers. opts are applied after them.
+func NewClient(config *config.Config, opts ...nexusapi.ClientOption) *nexusapi.Client {
+	opts = append([]nexusapi.ClientOption{
+		nexusapi.WithUnixSocket(config.UnixSocket),
+		nexusapi.WithTLSConfig(config.TLSConfig()),
+		nexusapi.WithToken(config.Token),
+		nexusapi.WithHeaders(config.Headers),
+	}, opts...)
+	return nexusapi.NewClient(config.NexusURL, config.Username, config.Password, opts...)
+}
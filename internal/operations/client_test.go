@@ -0,0 +1,55 @@
+package operations
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tympanix/nexus-cli/internal/config"
+	"github.com/tympanix/nexus-cli/internal/nexusapi"
+	"github.com/tympanix/nexus-cli/internal/util"
+)
+
+// TestUploadDownloadUnixSocket tests a round trip through a Unix socket, with a URL whose
+// host does not resolve, so every request must go through the socket
+func TestUploadDownloadUnixSocket(t *testing.T) {
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+	server.StoreUploads = true
+
+	socket := filepath.Join(t.TempDir(), "nexus.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Skipf("Unix sockets are not supported: %v", err)
+	}
+	unixServer := &http.Server{Handler: server.Config.Handler}
+	go unixServer.Serve(listener)
+	defer unixServer.Close()
+
+	// Download URLs of stored uploads are built from the server URL
+	server.URL = "http://nexus.invalid"
+	config := &config.Config{NexusURL: server.URL, Username: "test", Password: "test", UnixSocket: socket}
+
+	srcDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(srcDir, "file.txt"), []byte("through the socket"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := uploadFiles(srcDir, "raw", "dist", config, &UploadOptions{Logger: util.NewLogger(io.Discard), QuietMode: true}); err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	if uploaded := server.GetUploadedFiles(); len(uploaded) != 1 || uploaded[0].Path != "/dist/file.txt" {
+		t.Fatalf("Expected file.txt to be uploaded, got %+v", uploaded)
+	}
+
+	destDir := t.TempDir()
+	opts := &DownloadOptions{Logger: util.NewLogger(io.Discard), QuietMode: true, Recursive: true}
+	if status := downloadFolder("raw/dist", destDir, config, opts); status != DownloadSuccess {
+		t.Fatalf("Expected download to succeed, got %v", status)
+	}
+	if data, err := os.ReadFile(filepath.Join(destDir, "dist", "file.txt")); err != nil || string(data) != "through the socket" {
+		t.Errorf("Expected the uploaded content, got %q, %v", data, err)
+	}
+}
@@ -0,0 +1,91 @@
+package operations
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tympanix/nexus-cli/internal/nexusapi"
+)
+
+// ConflictPolicy controls what happens when a download would overwrite a local file whose content differs
+type ConflictPolicy string
+
+const (
+	ConflictOverwrite ConflictPolicy = "overwrite" // Replace the local file (default)
+	ConflictBackup    ConflictPolicy = "backup"    // Rename the local file to <name>.bak.<timestamp> before writing
+	ConflictSkip      ConflictPolicy = "skip"      // Keep the local file and report it in the summary
+	ConflictFail      ConflictPolicy = "fail"      // Abort before downloading anything
+)
+
+// backupTimeFormat is the timestamp layout used for conflict backups
+const backupTimeFormat = "20060102T150405"
+
+var backupSuffixPattern = regexp.MustCompile(`\.bak\.\d{8}T\d{6}$`)
+
+// ParseConflictPolicy parses a string into a ConflictPolicy
+func ParseConflictPolicy(s string) (ConflictPolicy, error) {
+	switch strings.ToLower(s) {
+	case "", "overwrite":
+		return ConflictOverwrite, nil
+	case "backup":
+		return ConflictBackup, nil
+	case "skip":
+		return ConflictSkip, nil
+	case "fail":
+		return ConflictFail, nil
+	default:
+		return "", fmt.Errorf("unsupported conflict policy '%s': must be one of: overwrite, backup, skip, fail", s)
+	}
+}
+
+// IsConflictBackup reports whether a file name was created by the backup conflict policy
+func IsConflictBackup(name string) bool {
+	return backupSuffixPattern.MatchString(name)
+}
+
+// hasLocalConflict reports whether localPath exists with content that differs from the asset.
+// Without checksum validation every existing file is considered a conflict.
+func hasLocalConflict(localPath string, asset nexusapi.Asset, opts *DownloadOptions) bool {
+	if _, err := os.Stat(localPath); err != nil {
+		return false
+	}
+	if opts.SkipChecksum || opts.checksumValidator == nil {
+		return true
+	}
+	valid, err := opts.checksumValidator.Validate(localPath, asset.Checksum)
+	return err != nil || !valid
+}
+
+// backupLocalFile renames localPath to <localPath>.bak.<timestamp> and returns the new path
+func backupLocalFile(localPath string) (string, error) {
+	backupPath := localPath + ".bak." + time.Now().Format(backupTimeFormat)
+	if err := os.Rename(localPath, backupPath); err != nil {
+		return "", err
+	}
+	return backupPath, nil
+}
+
+// conflictLog collects conflicts handled during a download so they can be reported in the summary
+type conflictLog struct {
+	mu      sync.Mutex
+	entries []string
+}
+
+func (c *conflictLog) add(entry string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = append(c.entries, entry)
+}
+
+func (c *conflictLog) sorted() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entries := append([]string{}, c.entries...)
+	sort.Strings(entries)
+	return entries
+}
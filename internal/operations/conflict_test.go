@@ -0,0 +1,182 @@
+package operations
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tympanix/nexus-cli/internal/config"
+	"github.com/tympanix/nexus-cli/internal/nexusapi"
+	"github.com/tympanix/nexus-cli/internal/util"
+)
+
+func TestParseConflictPolicy(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    ConflictPolicy
+		wantErr bool
+	}{
+		{input: "", want: ConflictOverwrite},
+		{input: "overwrite", want: ConflictOverwrite},
+		{input: "Backup", want: ConflictBackup},
+		{input: "skip", want: ConflictSkip},
+		{input: "fail", want: ConflictFail},
+		{input: "merge", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseConflictPolicy(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected error for %q, got nil", tt.input)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseConflictPolicy(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsConflictBackup(t *testing.T) {
+	if !IsConflictBackup("dir/conf.json.bak.20250102T030405") {
+		t.Error("Expected backup name to be recognized")
+	}
+	for _, name := range []string{"conf.json", "conf.json.bak", "conf.bak.json", "conf.json.bak.latest"} {
+		if IsConflictBackup(name) {
+			t.Errorf("Expected %q not to be recognized as a backup", name)
+		}
+	}
+}
+
+// setupConflictTest creates a mock server with two assets and a destination where
+// one file has local modifications and the other is already up to date
+func setupConflictTest(t *testing.T) (*nexusapi.MockNexusServer, *config.Config, string) {
+	t.Helper()
+	server := nexusapi.NewMockNexusServer()
+	t.Cleanup(server.Close)
+
+	server.AddAsset("test-repo", "/app/conf.json", nexusapi.Asset{}, []byte(`{"remote": true}`))
+	server.AddAsset("test-repo", "/app/data.txt", nexusapi.Asset{}, []byte("data"))
+
+	destDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(destDir, "app"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(destDir, "app", "conf.json"), []byte(`{"debug": true}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(destDir, "app", "data.txt"), []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	config := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+	return server, config, destDir
+}
+
+func newConflictTestOptions(t *testing.T, policy ConflictPolicy, logger util.Logger) *DownloadOptions {
+	t.Helper()
+	opts := &DownloadOptions{
+		Logger:     logger,
+		QuietMode:  true,
+		Recursive:  true,
+		OnConflict: policy,
+	}
+	if err := opts.SetChecksumAlgorithm("sha1"); err != nil {
+		t.Fatal(err)
+	}
+	return opts
+}
+
+func TestDownloadOnConflictOverwrite(t *testing.T) {
+	_, config, destDir := setupConflictTest(t)
+
+	opts := newConflictTestOptions(t, ConflictOverwrite, util.NewLogger(io.Discard))
+	if status := downloadFolder("test-repo/app", destDir, config, opts); status != DownloadSuccess {
+		t.Fatalf("Expected DownloadSuccess, got %d", status)
+	}
+
+	content, _ := os.ReadFile(filepath.Join(destDir, "app", "conf.json"))
+	if string(content) != `{"remote": true}` {
+		t.Errorf("Expected local file to be overwritten, got %s", content)
+	}
+}
+
+func TestDownloadOnConflictBackup(t *testing.T) {
+	_, config, destDir := setupConflictTest(t)
+
+	opts := newConflictTestOptions(t, ConflictBackup, util.NewLogger(io.Discard))
+	opts.DeleteExtra = true
+	if status := downloadFolder("test-repo/app", destDir, config, opts); status != DownloadSuccess {
+		t.Fatalf("Expected DownloadSuccess, got %d", status)
+	}
+
+	content, _ := os.ReadFile(filepath.Join(destDir, "app", "conf.json"))
+	if string(content) != `{"remote": true}` {
+		t.Errorf("Expected local file to be replaced, got %s", content)
+	}
+
+	backups, _ := filepath.Glob(filepath.Join(destDir, "app", "conf.json.bak.*"))
+	if len(backups) != 1 {
+		t.Fatalf("Expected exactly one backup (kept despite --delete), got %v", backups)
+	}
+	backupContent, _ := os.ReadFile(backups[0])
+	if string(backupContent) != `{"debug": true}` {
+		t.Errorf("Expected backup to hold local edits, got %s", backupContent)
+	}
+
+	// Unchanged files must not be backed up
+	if others, _ := filepath.Glob(filepath.Join(destDir, "app", "data.txt.bak.*")); len(others) != 0 {
+		t.Errorf("Expected no backup for unchanged file, got %v", others)
+	}
+}
+
+func TestDownloadOnConflictSkip(t *testing.T) {
+	_, config, destDir := setupConflictTest(t)
+
+	var logBuf bytes.Buffer
+	opts := newConflictTestOptions(t, ConflictSkip, util.NewLogger(&logBuf))
+	if status := downloadFolder("test-repo/app", destDir, config, opts); status != DownloadSuccess {
+		t.Fatalf("Expected DownloadSuccess, got %d", status)
+	}
+
+	content, _ := os.ReadFile(filepath.Join(destDir, "app", "conf.json"))
+	if string(content) != `{"debug": true}` {
+		t.Errorf("Expected local edits to be kept, got %s", content)
+	}
+	if !strings.Contains(logBuf.String(), "Kept 1 locally modified file(s)") {
+		t.Errorf("Expected summary to report kept file, got: %s", logBuf.String())
+	}
+}
+
+func TestDownloadOnConflictFail(t *testing.T) {
+	server, config, destDir := setupConflictTest(t)
+	server.AddAsset("test-repo", "/app/new.txt", nexusapi.Asset{}, []byte("new"))
+
+	var logBuf bytes.Buffer
+	opts := newConflictTestOptions(t, ConflictFail, util.NewLogger(&logBuf))
+	if status := downloadFolder("test-repo/app", destDir, config, opts); status != DownloadError {
+		t.Fatalf("Expected DownloadError, got %d", status)
+	}
+
+	if !strings.Contains(logBuf.String(), filepath.Join(destDir, "app", "conf.json")) {
+		t.Errorf("Expected error to list conflicting path, got: %s", logBuf.String())
+	}
+	if strings.Contains(logBuf.String(), filepath.Join(destDir, "app", "data.txt")) {
+		t.Errorf("Expected unchanged file not to be listed, got: %s", logBuf.String())
+	}
+	if _, err := os.Stat(filepath.Join(destDir, "app", "new.txt")); !os.IsNotExist(err) {
+		t.Error("Expected nothing to be downloaded when aborting on conflict")
+	}
+	if server.GetDownloadCount() != 0 {
+		t.Errorf("Expected no downloads, got %d", server.GetDownloadCount())
+	}
+}
@@ -0,0 +1,92 @@
+package operations
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SetContentType makes uploaded files with the extension ext, e.g. ".svg" or "tar.gz",
+// carry contentType in the upload form. A later mapping of the same extension replaces an
+// earlier one.
+func (opts *UploadOptions) SetContentType(ext, contentType string) error {
+	ext = normalizeExtension(ext)
+	if ext == "" || ext == "." {
+		return fmt.Errorf("empty extension for content type %q", contentType)
+	}
+	if _, _, err := mime.ParseMediaType(contentType); err != nil {
+		return fmt.Errorf("invalid content type %q for %s: %w", contentType, ext, err)
+	}
+	if opts.ContentTypes == nil {
+		opts.ContentTypes = make(map[string]string)
+	}
+	opts.ContentTypes[ext] = contentType
+	return nil
+}
+
+// contentType returns the Content-Type mapped to the longest matching extension of the
+// file name, so ".tar.gz" wins over ".gz", or "" if no extension is mapped
+func (opts *UploadOptions) contentType(name string) string {
+	name = strings.ToLower(filepath.Base(name))
+	match, contentType := "", ""
+	for ext, mapped := range opts.ContentTypes {
+		if strings.HasSuffix(name, ext) && len(ext) > len(match) {
+			match, contentType = ext, mapped
+		}
+	}
+	return contentType
+}
+
+// ParseContentTypeMapping parses an "ext=type" mapping given with --content-type
+func ParseContentTypeMapping(s string) (string, string, error) {
+	ext, contentType, ok := strings.Cut(s, "=")
+	if !ok {
+		return "", "", fmt.Errorf("invalid content type mapping '%s': must be in the form ext=type, e.g. svg=image/svg+xml", s)
+	}
+	return strings.TrimSpace(ext), strings.TrimSpace(contentType), nil
+}
+
+// LoadContentTypeMap reads a map of extensions to content types for --content-type-map.
+// The file is either a JSON object, or has one "ext=type" or "ext type" mapping per line
+// with blank lines and lines starting with # ignored:
+//
+//	# Vector graphics
+//	svg=image/svg+xml
+//	.wasm application/wasm
+func LoadContentTypeMap(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	mapping := make(map[string]string)
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		if err := json.Unmarshal(data, &mapping); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return mapping, nil
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		ext, contentType, ok := strings.Cut(line, "=")
+		if !ok {
+			fields := strings.Fields(line)
+			if len(fields) != 2 {
+				return nil, fmt.Errorf("%s:%d: expected 'ext=type' or 'ext type', got '%s'", path, lineNo, line)
+			}
+			ext, contentType = fields[0], fields[1]
+		}
+		mapping[strings.TrimSpace(ext)] = strings.TrimSpace(contentType)
+	}
+	return mapping, scanner.Err()
+}
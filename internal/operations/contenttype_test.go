@@ -0,0 +1,103 @@
+package operations
+
+import (
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/tympanix/nexus-cli/internal/config"
+	"github.com/tympanix/nexus-cli/internal/nexusapi"
+	"github.com/tympanix/nexus-cli/internal/util"
+)
+
+func TestLoadContentTypeMap(t *testing.T) {
+	dir := t.TempDir()
+	lines := filepath.Join(dir, "types.txt")
+	if err := os.WriteFile(lines, []byte("# Web assets\nsvg=image/svg+xml\n\n.wasm application/wasm\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	jsonFile := filepath.Join(dir, "types.json")
+	if err := os.WriteFile(jsonFile, []byte(`{"svg": "image/svg+xml", ".wasm": "application/wasm"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, file := range []string{lines, jsonFile} {
+		mapping, err := LoadContentTypeMap(file)
+		if err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+		opts := &UploadOptions{}
+		for ext, contentType := range mapping {
+			if err := opts.SetContentType(ext, contentType); err != nil {
+				t.Fatalf("%s: %v", file, err)
+			}
+		}
+		expected := map[string]string{".svg": "image/svg+xml", ".wasm": "application/wasm"}
+		if !reflect.DeepEqual(opts.ContentTypes, expected) {
+			t.Errorf("%s: got %v, want %v", file, opts.ContentTypes, expected)
+		}
+	}
+
+	invalid := filepath.Join(dir, "invalid.txt")
+	if err := os.WriteFile(invalid, []byte("svg image/svg+xml extra\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadContentTypeMap(invalid); err == nil {
+		t.Error("Expected an error for a line with three fields")
+	}
+	if err := (&UploadOptions{}).SetContentType("svg", "not a type"); err == nil {
+		t.Error("Expected an error for an invalid content type")
+	}
+}
+
+func TestUploadContentTypes(t *testing.T) {
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+	config := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+
+	srcDir := t.TempDir()
+	for _, name := range []string{"logo.svg", "app.wasm", "bundle.tar.gz", "data.bin"} {
+		if err := os.WriteFile(filepath.Join(srcDir, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mapFile := filepath.Join(t.TempDir(), "types")
+	if err := os.WriteFile(mapFile, []byte("svg=image/svg+xml\nwasm=application/wasm\ngz=application/gzip\ntar.gz=application/x-gtar\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := &UploadOptions{Logger: util.NewLogger(io.Discard), QuietMode: true, Force: true}
+	mapping, err := LoadContentTypeMap(mapFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for ext, contentType := range mapping {
+		if err := opts.SetContentType(ext, contentType); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// An inline mapping is applied after the map file and wins
+	if err := opts.SetContentType(".WASM", "application/x-custom-wasm"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := uploadFiles(srcDir, "raw", "assets", config, opts); err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	got := make(map[string]string)
+	for _, file := range server.GetUploadedFiles() {
+		got[path.Base(file.Path)] = file.ContentType
+	}
+	expected := map[string]string{
+		"logo.svg":      "image/svg+xml",
+		"app.wasm":      "application/x-custom-wasm",
+		"bundle.tar.gz": "application/x-gtar",
+		"data.bin":      "application/octet-stream",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Unexpected content types:\n got: %v\nwant: %v", got, expected)
+	}
+}
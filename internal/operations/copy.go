@@ -0,0 +1,167 @@
+package operations
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/tympanix/nexus-cli/internal/config"
+	"github.com/tympanix/nexus-cli/internal/exitcode"
+	"github.com/tympanix/nexus-cli/internal/nexusapi"
+	"github.com/tympanix/nexus-cli/internal/progress"
+	"github.com/tympanix/nexus-cli/internal/util"
+)
+
+// CopyOptions holds options for copy operations
+type CopyOptions struct {
+	Logger      util.Logger
+	QuietMode   bool
+	DryRun      bool       // Report what would be copied without changing the repository
+	PlanFormat  PlanFormat // How DryRun prints the planned actions (default: text)
+	Concurrency int        // Maximum number of parallel copies (0 = unlimited)
+}
+
+// CopyResult counts the outcome of a copy
+type CopyResult struct {
+	Copied   int
+	Existing int // Already present with the same content at the destination
+	Failed   int
+	Bytes    int64
+}
+
+// copyAssets copies the assets below srcPath in srcRepository to dstPath in dstRepository
+// on the same server. Each asset is copied with client.CopyAsset, which copies it on the
+// server if it can and otherwise streams it through the client. Only RAW repositories
+// accept the streamed copies.
+func copyAssets(srcRepository, srcPath, dstRepository, dstPath string, config *config.Config, opts *CopyOptions) (CopyResult, error) {
+	var result CopyResult
+
+	src := strings.Trim(srcPath, "/")
+	dst := strings.Trim(dstPath, "/")
+	if src == "" || dst == "" {
+		return result, fmt.Errorf("source and destination must be paths inside a repository")
+	}
+	if srcRepository == dstRepository && (src == dst || strings.HasPrefix(dst+"/", src+"/") || strings.HasPrefix(src+"/", dst+"/")) {
+		return result, fmt.Errorf("cannot copy %s to %s: the paths overlap", src, dst)
+	}
+
+	client := NewClient(config)
+	// Reading the repository may need more privileges; without them the upload decides
+	if repository, err := client.GetRepository(dstRepository); err == nil && repository != nil && repository.Format != "" && repository.Format != "raw" {
+		return result, fmt.Errorf("cannot copy to '%s': copy supports RAW repositories only, not %s", dstRepository, repository.Format)
+	}
+
+	srcAssets, err := listMoveSources(client, srcRepository, src)
+	if err != nil {
+		return result, err
+	}
+	dstAssets, err := listMoveDestination(client, dstRepository, dst)
+	if err != nil {
+		return result, err
+	}
+
+	// A target with different content is never overwritten
+	var toCopy []nexusapi.Asset
+	totalBytes := int64(0)
+	for _, asset := range srcAssets {
+		target := moveTarget(asset.Path, src, dst)
+		if existing, ok := dstAssets[target]; ok {
+			if sameAssetContent(asset, existing) {
+				result.Existing++
+				opts.Logger.VerbosePrintf("= %s (already at %s)\n", asset.Path, target)
+				continue
+			}
+			result.Failed++
+			opts.Logger.Printf("✗ %s (%s exists with different content)\n", asset.Path, target)
+			continue
+		}
+		toCopy = append(toCopy, asset)
+		totalBytes += asset.FileSize
+	}
+
+	// A dry run prints the plan, otherwise its copies are made
+	plan := NewPlan("copy")
+	for _, asset := range toCopy {
+		plan.Add(Action{Type: ActionCopy, Source: path.Join(srcRepository, asset.Path), Target: path.Join(dstRepository, moveTarget(asset.Path, src, dst)), Size: asset.FileSize, asset: &asset})
+	}
+	if opts.DryRun {
+		plan.Print(opts.Logger, opts.PlanFormat)
+		result.Copied = len(toCopy)
+		result.Bytes = totalBytes
+		return result, nil
+	}
+
+	copies := plan.ofType(ActionCopy)
+	bar := progress.NewProgressBarWithCount(newTransferMeter(), totalBytes, "Copying assets", len(copies), !opts.QuietMode)
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var sem chan struct{}
+	if opts.Concurrency > 0 {
+		sem = make(chan struct{}, opts.Concurrency)
+	}
+	for _, action := range copies {
+		wg.Add(1)
+		go func(asset nexusapi.Asset) {
+			defer wg.Done()
+			if sem != nil {
+				sem <- struct{}{}
+				defer func() { <-sem }()
+			}
+			target := moveTarget(asset.Path, src, dst)
+			err := copyAsset(client, asset, dstRepository, target, bar)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				result.Failed++
+				opts.Logger.Printf("✗ %s (copy failed: %v)\n", asset.Path, err)
+				return
+			}
+			result.Copied++
+			result.Bytes += asset.FileSize
+			bar.IncrementFile()
+			opts.Logger.VerbosePrintf("✓ %s -> %s/%s\n", asset.Path, dstRepository, target)
+		}(*action.asset)
+	}
+	wg.Wait()
+	bar.Finish()
+
+	return result, nil
+}
+
+// CopyMain copies src to dst, both of the form repository/path on the same server, and
+// prints a summary. It exits with status 1 on failure.
+func CopyMain(src, dst string, config *config.Config, opts *CopyOptions) {
+	srcRepository, srcPath, ok := util.ParseRepositoryPath(src)
+	if !ok {
+		fmt.Println("Error: The src argument must be in the form 'repository/path'.")
+		os.Exit(exitcode.Error)
+	}
+	dstRepository, dstPath, ok := util.ParseRepositoryPath(dst)
+	if !ok {
+		fmt.Println("Error: The dest argument must be in the form 'repository/path'.")
+		os.Exit(exitcode.Error)
+	}
+
+	opts.Logger.Printf("Copying %s -> %s\n", src, dst)
+	result, err := copyAssets(srcRepository, srcPath, dstRepository, dstPath, config, opts)
+	if err != nil {
+		fmt.Println("Copy error:", err)
+		os.Exit(exitcode.Error)
+	}
+
+	prefix := ""
+	if opts.DryRun {
+		prefix = "Dry-run: would have "
+	}
+	summary := fmt.Sprintf("%scopied: %d (%s), already at destination: %d", prefix, result.Copied, util.FormatByteSize(result.Bytes), result.Existing)
+	if result.Failed > 0 {
+		summary += fmt.Sprintf(", failed: %d", result.Failed)
+	}
+	opts.Logger.Println(summary)
+	if result.Failed > 0 {
+		os.Exit(exitcode.Error)
+	}
+}
@@ -0,0 +1,120 @@
+package operations
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/tympanix/nexus-cli/internal/nexusapi"
+	"github.com/tympanix/nexus-cli/internal/util"
+)
+
+func TestCopyToOtherRepository(t *testing.T) {
+	server, config := newMoveTest(t)
+
+	opts := &CopyOptions{Logger: util.NewLogger(io.Discard), QuietMode: true}
+	result, err := copyAssets("raw", "builds/2024.1", "releases", "2024.1", config, opts)
+	if err != nil {
+		t.Fatalf("copy failed: %v", err)
+	}
+	if result.Copied != 2 || result.Failed != 0 || result.Bytes != 9 {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+
+	uploaded := make(map[string]string)
+	for _, file := range server.GetUploadedFiles() {
+		uploaded[file.Repository+":"+file.Path] = string(file.Content)
+	}
+	expected := map[string]string{
+		"releases:/2024.1/app.bin":         "app",
+		"releases:/2024.1/docs/readme.txt": "readme",
+	}
+	if len(uploaded) != len(expected) {
+		t.Errorf("Expected uploads %v, got %v", expected, uploaded)
+	}
+	for key, content := range expected {
+		if uploaded[key] != content {
+			t.Errorf("Expected %s to contain %q, got %q", key, content, uploaded[key])
+		}
+	}
+	if len(server.GetDeletedAssets()) != 0 {
+		t.Error("Expected the sources to be kept")
+	}
+}
+
+// TestCopyOnServer checks that a server that copies assets itself is used instead of
+// streaming the content through the client
+func TestCopyOnServer(t *testing.T) {
+	server, config := newMoveTest(t)
+	server.ServerCopy = true
+
+	opts := &CopyOptions{Logger: util.NewLogger(io.Discard), QuietMode: true}
+	result, err := copyAssets("raw", "builds/2024.1", "releases", "2024.1", config, opts)
+	if err != nil {
+		t.Fatalf("copy failed: %v", err)
+	}
+	if result.Copied != 2 || result.Failed != 0 || result.Bytes != 9 {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+	if server.ServerCopies != 2 || len(server.GetUploadedFiles()) != 0 || server.GetDownloadCount() != 0 {
+		t.Errorf("Expected both assets to be copied on the server, got %d server copies, %d uploads and %d downloads",
+			server.ServerCopies, len(server.GetUploadedFiles()), server.GetDownloadCount())
+	}
+	for _, key := range []string{"releases:/2024.1/app.bin", "releases:/2024.1/docs/readme.txt"} {
+		if _, ok := server.Assets[key]; !ok {
+			t.Errorf("Expected %s to be copied", key)
+		}
+	}
+}
+
+func TestCopyExistingDestination(t *testing.T) {
+	server, config := newMoveTest(t)
+	server.AddAsset("raw", "/copy/app.bin", nexusapi.Asset{}, []byte("app"))
+	server.AddAsset("raw", "/copy/docs/readme.txt", nexusapi.Asset{}, []byte("changed"))
+
+	opts := &CopyOptions{Logger: util.NewLogger(io.Discard), QuietMode: true}
+	result, err := copyAssets("raw", "builds/2024.1", "raw", "copy", config, opts)
+	if err != nil {
+		t.Fatalf("copy failed: %v", err)
+	}
+	if result.Existing != 1 || result.Failed != 1 || result.Copied != 0 {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+	if len(server.GetUploadedFiles()) != 0 {
+		t.Error("Expected an asset with different content not to be overwritten")
+	}
+}
+
+func TestCopyDryRun(t *testing.T) {
+	server, config := newMoveTest(t)
+
+	opts := &CopyOptions{Logger: util.NewLogger(io.Discard), QuietMode: true, DryRun: true}
+	result, err := copyAssets("raw", "builds/2024.1/app.bin", "raw", "archive/app.bin", config, opts)
+	if err != nil {
+		t.Fatalf("copy failed: %v", err)
+	}
+	if result.Copied != 1 || result.Bytes != 3 {
+		t.Errorf("Expected one asset to be reported, got %+v", result)
+	}
+	if len(server.GetUploadedFiles()) != 0 {
+		t.Error("Expected no changes in dry-run mode")
+	}
+}
+
+func TestCopyRejected(t *testing.T) {
+	server, config := newMoveTest(t)
+	server.Repositories = []nexusapi.Repository{{Name: "maven-releases", Format: "maven2", Type: "hosted"}}
+
+	opts := &CopyOptions{Logger: util.NewLogger(io.Discard), QuietMode: true}
+	if _, err := copyAssets("raw", "builds/2024.1", "maven-releases", "2024.1", config, opts); err == nil || !strings.Contains(err.Error(), "RAW repositories only") {
+		t.Errorf("Expected a copy to a maven2 repository to be rejected, got %v", err)
+	}
+	for _, dst := range []string{"builds/2024.1", "builds/2024.1/old", "builds"} {
+		if _, err := copyAssets("raw", "builds/2024.1", "raw", dst, config, opts); err == nil {
+			t.Errorf("Expected copying to %s to be rejected", dst)
+		}
+	}
+	if len(server.GetUploadedFiles()) != 0 {
+		t.Error("Expected nothing to be copied")
+	}
+}
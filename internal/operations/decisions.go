@@ -0,0 +1,47 @@
+package operations
+
+import (
+	"path/filepath"
+	"sync"
+)
+
+// FileDecision describes what a download did with a single file
+type FileDecision string
+
+const (
+	DecisionDownloaded FileDecision = "downloaded"                  // Fetched from Nexus and verified
+	DecisionSkipped    FileDecision = "skipped, already matching"   // The local file was up to date
+	DecisionRestored   FileDecision = "restored from cache"         // Copied or linked from the shared cache
+	DecisionConflict   FileDecision = "skipped, local file differs" // Kept by the conflict policy
+	DecisionFailed     FileDecision = "failed"                      // The download or its verification failed
+)
+
+// FileDecisions collects the decision made for each file of a download, keyed by local
+// path. Set DownloadOptions.Decisions to collect them.
+type FileDecisions struct {
+	mu        sync.Mutex
+	decisions map[string]FileDecision
+}
+
+// NewFileDecisions returns an empty decision log
+func NewFileDecisions() *FileDecisions {
+	return &FileDecisions{decisions: make(map[string]FileDecision)}
+}
+
+// record stores the decision for localPath. It is a no-op on a nil log.
+func (d *FileDecisions) record(localPath string, decision FileDecision) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.decisions[filepath.Clean(localPath)] = decision
+}
+
+// Get returns the decision recorded for localPath, if the download handled it
+func (d *FileDecisions) Get(localPath string) (FileDecision, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	decision, ok := d.decisions[filepath.Clean(localPath)]
+	return decision, ok
+}
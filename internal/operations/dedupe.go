@@ -0,0 +1,141 @@
+package operations
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tympanix/nexus-cli/internal/checksum"
+	"github.com/tympanix/nexus-cli/internal/nexusapi"
+	"github.com/tympanix/nexus-cli/internal/output"
+	"github.com/tympanix/nexus-cli/internal/progress"
+)
+
+// DedupeReport is written as JSON by --dedupe-report
+type DedupeReport struct {
+	DryRun     bool               `json:"dryRun"`
+	Duplicates []DuplicateContent `json:"duplicates"`
+}
+
+// DuplicateContent is a file of an upload whose content already exists in the destination
+// repository under other paths
+type DuplicateContent struct {
+	Repository string   `json:"repository"`
+	Path       string   `json:"path"` // Path the file is uploaded to
+	Source     string   `json:"source"`
+	SHA256     string   `json:"sha256"`
+	Size       int64    `json:"size"`
+	Existing   []string `json:"existing"` // Paths of the assets with the same content, sorted
+}
+
+// contentSearch searches the destination repository of an upload, with --dedupe-check, for
+// the content of each file to upload by its SHA-256 checksum. A file whose content is already at its own path
+// is up to date. Assets with the same content at other paths are logged as duplicate
+// content and added to the report of --dedupe-report; the file is still uploaded then,
+// as Nexus cannot link an asset to another.
+type contentSearch struct {
+	client     *nexusapi.Client
+	repository string
+	subdir     string
+	disabled   bool // Set without --dedupe-check, offline, and after a search failed
+}
+
+func newContentSearch(client *nexusapi.Client, repository, subdir string, opts *UploadOptions) *contentSearch {
+	return &contentSearch{
+		client:     client,
+		repository: repository,
+		subdir:     subdir,
+		disabled:   !opts.dedupeCheck() || opts.offline(),
+	}
+}
+
+// dedupeCheck reports whether the content of the files to upload is searched for
+func (opts *UploadOptions) dedupeCheck() bool {
+	return opts.DedupeCheck || opts.DedupeReport != ""
+}
+
+// sha256Digest returns the SHA-256 digest of the file at filePath. A digest computed by the
+// comparison with the remote asset is used instead of reading the file again.
+func (opts *UploadOptions) sha256Digest(filePath string, tracker *output.TransferTracker) (string, error) {
+	if sum, ok := opts.digests[filePath]; ok {
+		delete(opts.digests, filePath)
+		return sum, nil
+	}
+	hashStart := time.Now()
+	defer func() { tracker.Stats().AddHashTime(time.Since(hashStart)) }()
+	return checksum.ComputeChecksum(filePath, "sha256")
+}
+
+// upToDate reports whether the content of the file at filePath, to be uploaded to relPath
+// below the folder of the search, is already there. The bytes of such a file are counted
+// as skipped on bar. If the search fails, it is given up and every file is uploaded.
+func (s *contentSearch) upToDate(filePath, relPath string, size int64, bar *progress.ProgressBarWithCount, tracker *output.TransferTracker, opts *UploadOptions) bool {
+	if s.disabled {
+		return false
+	}
+	sum, err := opts.sha256Digest(filePath, tracker)
+	if err != nil {
+		opts.Logger.VerbosePrintf("Could not hash %s to check for duplicate content: %v\n", filePath, err)
+		return false
+	}
+	assets, err := s.client.SearchAssetsBySHA256(s.repository, sum)
+	if err != nil {
+		opts.Logger.VerbosePrintf("Could not search for duplicate content (will upload all files): %v\n", err)
+		s.disabled = true
+		return false
+	}
+
+	assetPath := path.Join(s.subdir, relPath)
+	var existing []string
+	for _, asset := range assets {
+		if existingPath := strings.TrimPrefix(asset.Path, "/"); existingPath != assetPath {
+			existing = append(existing, existingPath)
+		} else if !opts.Force {
+			bar.Skip(size)
+			return true
+		}
+	}
+	if len(existing) == 0 {
+		return false
+	}
+
+	sort.Strings(existing)
+	opts.Logger.Printf("Duplicate content: %s/%s is identical to %s in the same repository\n", s.repository, assetPath, strings.Join(existing, ", "))
+	opts.duplicates = append(opts.duplicates, DuplicateContent{
+		Repository: s.repository,
+		Path:       assetPath,
+		Source:     filePath,
+		SHA256:     sum,
+		Size:       size,
+		Existing:   existing,
+	})
+	return false
+}
+
+// saveDedupeReport writes the report of --dedupe-report, if it is set
+func saveDedupeReport(opts *UploadOptions) error {
+	if opts.DedupeReport == "" {
+		return nil
+	}
+	if err := writeDedupeReport(opts.DedupeReport, opts); err != nil {
+		return fmt.Errorf("failed to write %s: %w", opts.DedupeReport, err)
+	}
+	return nil
+}
+
+// writeDedupeReport writes the duplicates found so far to file as indented JSON
+func writeDedupeReport(file string, opts *UploadOptions) error {
+	report := DedupeReport{DryRun: opts.DryRun, Duplicates: opts.duplicates}
+	if report.Duplicates == nil {
+		report.Duplicates = []DuplicateContent{}
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, append(data, '\n'), 0644)
+}
@@ -0,0 +1,134 @@
+package operations
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tympanix/nexus-cli/internal/config"
+	"github.com/tympanix/nexus-cli/internal/nexusapi"
+	"github.com/tympanix/nexus-cli/internal/output"
+	"github.com/tympanix/nexus-cli/internal/util"
+)
+
+func TestUploadDuplicateContent(t *testing.T) {
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+	server.StoreUploads = true
+	server.AddAsset("raw", "/releases/1.0/app.bin", nexusapi.Asset{}, []byte("app binary"))
+	server.AddAsset("raw", "/mirror/app.bin", nexusapi.Asset{}, []byte("app binary"))
+	// The listing reports another size, but the content at the path is identical
+	server.AddAsset("raw", "/releases/2.0/notes.txt", nexusapi.Asset{FileSize: 1}, []byte("release notes"))
+	server.AddAsset("other", "/app.bin", nexusapi.Asset{}, []byte("new content"))
+	cfg := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+
+	srcDir := t.TempDir()
+	for name, content := range map[string]string{"app.bin": "app binary", "notes.txt": "release notes", "new.txt": "new content"} {
+		if err := os.WriteFile(filepath.Join(srcDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var logs bytes.Buffer
+	expected := "Duplicate content: raw/releases/2.0/app.bin is identical to mirror/app.bin, releases/1.0/app.bin in the same repository"
+
+	// A dry run, which does not stream, reports the duplicate and skips the file at its own path
+	opts := &UploadOptions{Logger: util.NewLogger(&logs), QuietMode: true, DryRun: true, Compare: CompareSize, DedupeCheck: true}
+	if err := uploadFiles(srcDir, "raw", "releases/2.0", cfg, opts); err != nil {
+		t.Fatalf("Dry run failed: %v", err)
+	}
+	if len(opts.duplicates) != 1 || !strings.Contains(logs.String(), expected) {
+		t.Errorf("Expected the duplicate to be reported in a dry run, got %+v and %q", opts.duplicates, logs.String())
+	}
+	if strings.Contains(logs.String(), "notes.txt") {
+		t.Errorf("Expected notes.txt to be skipped in a dry run, got %q", logs.String())
+	}
+
+	logs.Reset()
+	reportFile := filepath.Join(t.TempDir(), "dedupe.json")
+	opts = &UploadOptions{Logger: util.NewLogger(&logs), QuietMode: true, Compare: CompareSize, DedupeReport: reportFile}
+	if err := uploadFiles(srcDir, "raw", "releases/2.0", cfg, opts); err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	if err := saveDedupeReport(opts); err != nil {
+		t.Fatal(err)
+	}
+
+	// A duplicate is still uploaded, a file whose content is at its own path is not
+	var uploaded []string
+	for _, file := range server.UploadedFiles {
+		uploaded = append(uploaded, file.Path)
+	}
+	if !reflect.DeepEqual(uploaded, []string{"/releases/2.0/app.bin", "/releases/2.0/new.txt"}) && !reflect.DeepEqual(uploaded, []string{"/releases/2.0/new.txt", "/releases/2.0/app.bin"}) {
+		t.Errorf("Expected app.bin and new.txt to be uploaded, got %v", uploaded)
+	}
+	if !strings.Contains(logs.String(), expected) {
+		t.Errorf("Expected the duplicate to be logged, got %q", logs.String())
+	}
+	if strings.Contains(logs.String(), "new.txt") {
+		t.Errorf("Expected content in another repository not to count as a duplicate, got %q", logs.String())
+	}
+
+	data, err := os.ReadFile(reportFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report DedupeReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Duplicates) != 1 {
+		t.Fatalf("Expected one duplicate in the report, got %+v", report)
+	}
+	duplicate := report.Duplicates[0]
+	if duplicate.Repository != "raw" || duplicate.Path != "releases/2.0/app.bin" || duplicate.Size != 10 || len(duplicate.SHA256) != 64 || !reflect.DeepEqual(duplicate.Existing, []string{"mirror/app.bin", "releases/1.0/app.bin"}) {
+		t.Errorf("Unexpected duplicate %+v", duplicate)
+	}
+
+	// Without the check nothing is searched by checksum
+	server.UploadedFiles = nil
+	logs.Reset()
+	opts = &UploadOptions{Logger: util.NewLogger(&logs), QuietMode: true, Force: true}
+	if err := uploadFiles(srcDir, "raw", "releases/3.0", cfg, opts); err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	if len(server.UploadedFiles) != 3 || strings.Contains(logs.String(), "Duplicate content") {
+		t.Errorf("Expected all files to be uploaded without the check, got %d files and %q", len(server.UploadedFiles), logs.String())
+	}
+}
+
+func TestDedupeReusesComparedDigest(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "app.bin")
+	if err := os.WriteFile(filePath, []byte("changed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	opts := &UploadOptions{Logger: util.NewLogger(io.Discard), DedupeCheck: true}
+	if err := opts.SetChecksumAlgorithm("sha256"); err != nil {
+		t.Fatal(err)
+	}
+	opts.meter = newTransferMeter()
+	asset := nexusapi.Asset{Checksum: nexusapi.Checksum{SHA256: fmt.Sprintf("%x", sha256.Sum256([]byte("old")))}}
+	if valid, err := validateUploadFile(filePath, asset, opts.checksumValidator, opts); err != nil || valid {
+		t.Fatalf("Expected a mismatch, got %v, %v", valid, err)
+	}
+
+	// The search takes the digest of the comparison, without reading the file again
+	if err := os.Remove(filePath); err != nil {
+		t.Fatal(err)
+	}
+	tracker := output.NewTransferTracker(output.TransferTypeUpload, "raw", opts.Logger, true, false, false)
+	sum, err := opts.sha256Digest(filePath, tracker)
+	if err != nil || sum != fmt.Sprintf("%x", sha256.Sum256([]byte("changed"))) {
+		t.Errorf("Expected the digest of the comparison, got %q, %v", sum, err)
+	}
+	if len(opts.digests) != 0 {
+		t.Errorf("Expected the digest to be used once, got %v", opts.digests)
+	}
+}
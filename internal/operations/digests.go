@@ -0,0 +1,85 @@
+package operations
+
+import (
+	"fmt"
+	"hash"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/tympanix/nexus-cli/internal/checksum"
+)
+
+// FileDigest is the size and checksums of a downloaded file, computed while it was written
+type FileDigest struct {
+	Size      int64
+	Checksums map[string]string // Hex checksum by lower case algorithm
+}
+
+// FileDigests collects the digests of the files a download fetched, keyed by local path,
+// so they can be checked without reading the files again. Set DownloadOptions.Digests to
+// collect them. Files that were skipped or restored from the cache have no digest, unless
+// they were compared against DownloadOptions.Expected.
+type FileDigests struct {
+	algorithms []string
+	mu         sync.Mutex
+	digests    map[string]FileDigest
+}
+
+// NewFileDigests returns an empty digest log computing the given algorithms
+func NewFileDigests(algorithms ...string) (*FileDigests, error) {
+	d := &FileDigests{digests: make(map[string]FileDigest)}
+	seen := make(map[string]bool)
+	for _, algorithm := range algorithms {
+		algorithm = strings.ToLower(algorithm)
+		if seen[algorithm] {
+			continue
+		}
+		if _, err := checksum.NewHasher(algorithm); err != nil {
+			return nil, err
+		}
+		seen[algorithm] = true
+		d.algorithms = append(d.algorithms, algorithm)
+	}
+	return d, nil
+}
+
+// hashers returns a hasher for each algorithm of the log. digest is the hasher of the
+// download verification, which is shared rather than computed twice. It is nil on a nil log.
+func (d *FileDigests) hashers(digest hash.Hash, opts *DownloadOptions) map[string]hash.Hash {
+	if d == nil {
+		return nil
+	}
+	hashers := make(map[string]hash.Hash, len(d.algorithms))
+	for _, algorithm := range d.algorithms {
+		if digest != nil && strings.EqualFold(opts.checksumValidator.Algorithm(), algorithm) {
+			hashers[algorithm] = digest
+			continue
+		}
+		hashers[algorithm], _ = checksum.NewHasher(algorithm)
+	}
+	return hashers
+}
+
+// record stores the digest of the data written to hashers for localPath. It is a no-op on
+// a nil log.
+func (d *FileDigests) record(localPath string, size int64, hashers map[string]hash.Hash) {
+	if d == nil {
+		return
+	}
+	checksums := make(map[string]string, len(hashers))
+	for algorithm, h := range hashers {
+		checksums[algorithm] = fmt.Sprintf("%x", h.Sum(nil))
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.digests[filepath.Clean(localPath)] = FileDigest{Size: size, Checksums: checksums}
+}
+
+// Get returns the digest recorded for localPath, if the download fetched it
+func (d *FileDigests) Get(localPath string) (FileDigest, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	digest, ok := d.digests[filepath.Clean(localPath)]
+	return digest, ok
+}
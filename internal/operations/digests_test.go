@@ -0,0 +1,134 @@
+package operations
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tympanix/nexus-cli/internal/config"
+	"github.com/tympanix/nexus-cli/internal/nexusapi"
+	"github.com/tympanix/nexus-cli/internal/util"
+)
+
+// TestDownloadDigests tests that the checksums of downloaded files are collected while
+// they are written, and that only files the download fetched have a digest
+func TestDownloadDigests(t *testing.T) {
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+	server.AddAsset("repo", "/docs/a.txt", nexusapi.Asset{}, []byte("content of a"))
+	server.AddAsset("repo", "/docs/b.txt", nexusapi.Asset{}, []byte("content of b"))
+	config := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+
+	destDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(destDir, "docs"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(destDir, "docs", "b.txt"), []byte("content of b"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	digests, err := NewFileDigests("sha256", "SHA512", "sha256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := &DownloadOptions{Logger: util.NewLogger(io.Discard), QuietMode: true, Recursive: true, Digests: digests}
+	if err := opts.SetChecksumAlgorithm("sha256"); err != nil {
+		t.Fatal(err)
+	}
+	if status := downloadFolder("repo/docs", destDir, config, opts); status != DownloadSuccess {
+		t.Fatalf("Expected download to succeed, got %v", status)
+	}
+
+	digest, ok := digests.Get(filepath.Join(destDir, "docs", "a.txt"))
+	if !ok {
+		t.Fatal("Expected a digest for the downloaded file")
+	}
+	if digest.Size != int64(len("content of a")) {
+		t.Errorf("Expected a size of %d, got %d", len("content of a"), digest.Size)
+	}
+	want := map[string]string{
+		"sha256": fmt.Sprintf("%x", sha256.Sum256([]byte("content of a"))),
+		"sha512": fmt.Sprintf("%x", sha512.Sum512([]byte("content of a"))),
+	}
+	for algorithm, sum := range want {
+		if digest.Checksums[algorithm] != sum {
+			t.Errorf("Expected %s %s, got %s", algorithm, sum, digest.Checksums[algorithm])
+		}
+	}
+	if len(digest.Checksums) != len(want) {
+		t.Errorf("Expected one checksum per algorithm, got %v", digest.Checksums)
+	}
+	if _, ok := digests.Get(filepath.Join(destDir, "docs", "b.txt")); ok {
+		t.Error("Expected no digest for the skipped file")
+	}
+}
+
+// TestDownloadDigestsMismatch tests that a file whose data does not match the checksum
+// reported by Nexus fails without a digest
+func TestDownloadDigestsMismatch(t *testing.T) {
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+	server.AddAsset("repo", "/docs/a.txt", nexusapi.Asset{Checksum: nexusapi.Checksum{SHA256: strings.Repeat("0", 64)}}, []byte("content of a"))
+	config := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+
+	digests, err := NewFileDigests("sha256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	destDir := t.TempDir()
+	opts := &DownloadOptions{Logger: util.NewLogger(io.Discard), QuietMode: true, Recursive: true, Digests: digests}
+	if err := opts.SetChecksumAlgorithm("sha256"); err != nil {
+		t.Fatal(err)
+	}
+	if status := downloadFolder("repo/docs", destDir, config, opts); status != DownloadError {
+		t.Fatalf("Expected download to fail, got %v", status)
+	}
+	localPath := filepath.Join(destDir, "docs", "a.txt")
+	if _, ok := digests.Get(localPath); ok {
+		t.Error("Expected no digest for the rejected file")
+	}
+	if _, err := os.Stat(localPath); !os.IsNotExist(err) {
+		t.Error("Expected the rejected file not to be written")
+	}
+}
+
+// TestDownloadDigestsChunkedResume tests that the digest of a file reassembled from parts
+// covers the parts reused from an interrupted download
+func TestDownloadDigestsChunkedResume(t *testing.T) {
+	srcDir, _, config := newChunkedUploadTest(t)
+	if err := uploadFiles(srcDir, "raw", "dist", config, &UploadOptions{Logger: util.NewLogger(io.Discard), QuietMode: true, ChunkSize: 4}); err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+
+	destDir := t.TempDir()
+	localPath := filepath.Join(destDir, "dist", "big.bin")
+	os.MkdirAll(filepath.Dir(localPath), 0755)
+	os.WriteFile(localPath+".part0001", []byte("0123"), 0644)
+
+	digests, err := NewFileDigests("sha256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := &DownloadOptions{Logger: util.NewLogger(io.Discard), QuietMode: true, Recursive: true, Chunked: true, GlobPattern: "**/big.bin", Digests: digests}
+	if status := downloadFolder("raw/dist", destDir, config, opts); status != DownloadSuccess {
+		t.Fatalf("Expected download to succeed, got %v", status)
+	}
+	digest, ok := digests.Get(localPath)
+	if !ok {
+		t.Fatal("Expected a digest for the reassembled file")
+	}
+	if want := fmt.Sprintf("%x", sha256.Sum256([]byte(chunkedContent))); digest.Checksums["sha256"] != want || digest.Size != int64(len(chunkedContent)) {
+		t.Errorf("Expected the digest of the whole file, got %+v", digest)
+	}
+}
+
+func TestNewFileDigestsUnsupportedAlgorithm(t *testing.T) {
+	if _, err := NewFileDigests("sha256", "crc32"); err == nil {
+		t.Error("Expected an error for an unsupported algorithm")
+	}
+}
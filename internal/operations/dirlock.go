@@ -0,0 +1,281 @@
+package operations
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tympanix/nexus-cli/internal/util"
+)
+
+// DirLockPollInterval is the delay between two attempts to take a lock while waiting for it
+var DirLockPollInterval = time.Second
+
+var (
+	errLockHeld        = errors.New("lock is held by another process")
+	errLockUnsupported = errors.New("file locking is not supported")
+	errLockRemoved     = errors.New("lock file was removed by its previous owner")
+)
+
+// DirLock is an advisory lock on a directory that a command writes to or deletes from,
+// so two overlapping runs, e.g. CI jobs syncing the same dependency directory, never
+// interleave. The lock is a file in the user's cache directory named after the absolute
+// path of the directory, so locking never writes to the directory or its parent, which
+// may not be writable. It is locked with flock (LockFileEx on Windows) and holds the PID
+// and host of its owner. Where the filesystem cannot lock files the lock file is created
+// exclusively instead, and a lock file left behind by a process that no longer runs is
+// taken over.
+type DirLock struct {
+	path  string
+	file  *os.File // Locked lock file, nil if only the existence of the file locks
+	count int      // Number of LockDir calls of this process not unlocked yet
+}
+
+var (
+	heldLocksMu sync.Mutex
+	heldLocks   = make(map[string]*DirLock) // Locks held by this process, by lock file path
+)
+
+// lockOwner identifies the process that holds a lock, as recorded in the lock file
+type lockOwner struct {
+	pid  int
+	host string
+}
+
+func (o lockOwner) String() string {
+	if o.pid == 0 {
+		return "unknown process"
+	}
+	return fmt.Sprintf("PID %d on %s", o.pid, o.host)
+}
+
+// stale reports whether the owner is a process on this host that no longer runs. The
+// liveness of a process on another host cannot be checked, so its lock is never stale.
+func (o lockOwner) stale() bool {
+	host, _ := os.Hostname()
+	return o.pid != 0 && o.host == host && !processAlive(o.pid)
+}
+
+// dirLockPath returns the path of the lock file of dir in $XDG_CACHE_HOME/nexuscli/locks,
+// or the same directory in the platform's user cache directory. The name is the base name
+// of dir followed by a hash of its absolute path, with symbolic links resolved if dir
+// exists, so every path to the same directory shares the lock.
+func dirLockPath(dir string) (string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+		abs = resolved
+	}
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("no directory for lock files: %w", err)
+	}
+	sum := sha256.Sum256([]byte(abs))
+	name := fmt.Sprintf("%s-%x.lock", filepath.Base(abs), sum[:8])
+	return filepath.Join(cacheDir, "nexuscli", "locks", name), nil
+}
+
+// LockDir locks dir against other nexuscli-go processes. If another process holds the
+// lock, it waits up to wait for it to be released, or fails at once if wait is 0. A
+// process may lock the same directory several times, e.g. deps sync and each of its
+// downloads; it is released when every lock is unlocked.
+func LockDir(dir string, wait time.Duration, logger util.Logger) (*DirLock, error) {
+	path, err := dirLockPath(dir)
+	if err != nil {
+		return nil, err
+	}
+	heldLocksMu.Lock()
+	if lock, ok := heldLocks[path]; ok {
+		lock.count++
+		heldLocksMu.Unlock()
+		return lock, nil
+	}
+	heldLocksMu.Unlock()
+	if err := util.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to lock %s: %w", dir, err)
+	}
+
+	start := time.Now()
+	deadline := start.Add(wait)
+	for attempt := 0; ; attempt++ {
+		lock, owner, err := acquireDirLock(path)
+		if err == nil {
+			if owner.pid != 0 {
+				logger.VerbosePrintf("Took over the lock on %s left behind by %s\n", dir, owner)
+			}
+			if attempt > 0 {
+				logger.Printf("Locked %s after waiting %s\n", dir, time.Since(start).Round(time.Second))
+			}
+			lock.count = 1
+			heldLocksMu.Lock()
+			heldLocks[path] = lock
+			heldLocksMu.Unlock()
+			return lock, nil
+		}
+		if !errors.Is(err, errLockHeld) {
+			return nil, fmt.Errorf("failed to lock %s: %w", dir, err)
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			if wait > 0 {
+				return nil, fmt.Errorf("gave up waiting after %s: another nexuscli-go process (%s) is operating on %s", wait, owner, dir)
+			}
+			return nil, fmt.Errorf("another nexuscli-go process (%s) is operating on %s; use --wait-lock to wait for it (lock file: %s)", owner, dir, path)
+		}
+		if attempt == 0 {
+			logger.Printf("Another nexuscli-go process (%s) is operating on %s, waiting up to %s\n", owner, dir, wait)
+		}
+		delay := DirLockPollInterval
+		if delay > remaining {
+			delay = remaining
+		}
+		time.Sleep(delay)
+	}
+}
+
+// acquireDirLock tries once to take the lock file at path. It returns errLockHeld and the
+// owner if another process holds it. On success the owner is that of a stale lock file
+// that was taken over, if any.
+func acquireDirLock(path string) (*DirLock, lockOwner, error) {
+	for {
+		f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+		if err != nil {
+			return nil, lockOwner{}, err
+		}
+		err = lockFile(f)
+		if err == nil {
+			err = checkLockFile(f, path)
+		}
+		switch {
+		case err == nil:
+			// A lock file that was not released was left behind by a process that died
+			previous := readLockOwner(f)
+			if err := writeLockOwner(f); err != nil {
+				f.Close()
+				return nil, lockOwner{}, err
+			}
+			return &DirLock{path: path, file: f}, previous, nil
+		case errors.Is(err, errLockRemoved):
+			f.Close()
+		case errors.Is(err, errLockHeld):
+			owner := readLockOwner(f)
+			f.Close()
+			return nil, owner, errLockHeld
+		case errors.Is(err, errLockUnsupported):
+			f.Close()
+			return acquireExclusiveLock(path)
+		default:
+			f.Close()
+			return nil, lockOwner{}, err
+		}
+	}
+}
+
+// checkLockFile returns errLockRemoved if the locked file f is no longer the lock file at
+// path, because its previous owner removed it after f was opened
+func checkLockFile(f *os.File, path string) error {
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	current, err := os.Stat(path)
+	if os.IsNotExist(err) || (err == nil && !os.SameFile(info, current)) {
+		return errLockRemoved
+	}
+	return err
+}
+
+// acquireExclusiveLock takes the lock file at path by creating it, for filesystems that
+// cannot lock files. A lock file of a process on this host that no longer runs is removed.
+func acquireExclusiveLock(path string) (*DirLock, lockOwner, error) {
+	var previous lockOwner
+	for {
+		f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			err = writeLockOwner(f)
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				os.Remove(path)
+				return nil, lockOwner{}, err
+			}
+			return &DirLock{path: path}, previous, nil
+		}
+		if !os.IsExist(err) {
+			return nil, lockOwner{}, err
+		}
+		f, err = os.Open(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, lockOwner{}, err
+		}
+		owner := readLockOwner(f)
+		f.Close()
+		if !owner.stale() {
+			return nil, owner, errLockHeld
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return nil, lockOwner{}, err
+		}
+		previous = owner
+	}
+}
+
+// readLockOwner reads the owner recorded in the lock file f. A lock file that is empty or
+// cannot be read has an unknown owner.
+func readLockOwner(f *os.File) lockOwner {
+	data := make([]byte, 512)
+	n, _ := f.ReadAt(data, 0)
+	fields := strings.Fields(string(data[:n]))
+	if len(fields) != 2 {
+		return lockOwner{}
+	}
+	pid, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return lockOwner{}
+	}
+	return lockOwner{pid: pid, host: fields[1]}
+}
+
+// writeLockOwner records this process as the owner in the lock file f
+func writeLockOwner(f *os.File) error {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	_, err = f.WriteAt([]byte(fmt.Sprintf("%d %s\n", os.Getpid(), host)), 0)
+	return err
+}
+
+// Unlock releases the lock once every LockDir call of this process for its directory is
+// unlocked. It is a no-op on a nil lock.
+func (l *DirLock) Unlock() error {
+	if l == nil {
+		return nil
+	}
+	heldLocksMu.Lock()
+	defer heldLocksMu.Unlock()
+	if l.count--; l.count > 0 {
+		return nil
+	}
+	delete(heldLocks, l.path)
+	if l.file == nil {
+		return os.Remove(l.path)
+	}
+	return releaseLockFile(l.file, l.path)
+}
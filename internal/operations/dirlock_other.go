@@ -0,0 +1,28 @@
+//go:build !(linux || darwin || freebsd || windows)
+
+package operations
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockFile is not implemented on this platform, so the lock file is created exclusively
+func lockFile(f *os.File) error {
+	return errLockUnsupported
+}
+
+// releaseLockFile removes the lock file and closes it
+func releaseLockFile(f *os.File, path string) error {
+	err := os.Remove(path)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// processAlive reports whether the process pid runs on this host
+func processAlive(pid int) bool {
+	process, err := os.FindProcess(pid)
+	return err == nil && process.Signal(syscall.Signal(0)) == nil
+}
@@ -0,0 +1,184 @@
+package operations
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tympanix/nexus-cli/internal/config"
+	"github.com/tympanix/nexus-cli/internal/nexusapi"
+	"github.com/tympanix/nexus-cli/internal/util"
+)
+
+// deadPID returns the PID of a process that has exited
+func deadPID(t *testing.T) int {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	return cmd.Process.Pid
+}
+
+func writeLockFile(t *testing.T, path string, pid int) {
+	t.Helper()
+	host, _ := os.Hostname()
+	if err := os.WriteFile(path, []byte(fmt.Sprintf("%d %s\n", pid, host)), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testLockPath returns the lock file of dir in a cache directory of the test
+func testLockPath(t *testing.T, dir string) string {
+	t.Helper()
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	path, err := dirLockPath(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLockDir(t *testing.T) {
+	cacheDir := t.TempDir()
+	t.Setenv("XDG_CACHE_HOME", cacheDir)
+	parent := t.TempDir()
+	dir := filepath.Join(parent, "deps")
+	logger := util.NewLogger(&bytes.Buffer{})
+	lock, err := LockDir(dir, 0, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path, _ := dirLockPath(dir)
+	if filepath.Dir(path) != filepath.Join(cacheDir, "nexuscli", "locks") || !strings.HasPrefix(filepath.Base(path), "deps-") {
+		t.Errorf("Expected the lock file in the cache directory, got %s", path)
+	}
+	// Locking writes nothing to the directory or its parent, which may not be writable
+	if entries, _ := os.ReadDir(parent); len(entries) != 0 {
+		t.Errorf("Expected nothing to be created next to the directory, got %v", entries)
+	}
+	if other, _ := dirLockPath(filepath.Join(parent, "other")); other == path {
+		t.Error("Expected another directory to have another lock file")
+	}
+
+	// The same process may lock the directory again, e.g. a download during deps sync
+	again, err := LockDir(dir, 0, logger)
+	if err != nil {
+		t.Fatalf("Expected the lock to be reentrant, got %v", err)
+	}
+	again.Unlock()
+
+	// Another process, which opens the lock file on its own, finds it held
+	if _, owner, err := acquireDirLock(path); err != errLockHeld || owner.pid != os.Getpid() {
+		t.Errorf("Expected the lock to be held by this process, got %v, %v", owner, err)
+	}
+
+	if err := lock.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Expected the lock file to be removed, got %v", err)
+	}
+	other, _, err := acquireDirLock(path)
+	if err != nil {
+		t.Fatalf("Expected the lock to be released, got %v", err)
+	}
+	other.Unlock()
+}
+
+func TestLockDirHeld(t *testing.T) {
+	previous := DirLockPollInterval
+	DirLockPollInterval = time.Millisecond
+	t.Cleanup(func() { DirLockPollInterval = previous })
+
+	dir := t.TempDir()
+	path := testLockPath(t, dir)
+	held, _, err := acquireDirLock(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var logs bytes.Buffer
+	_, err = LockDir(dir, 0, util.NewLogger(&logs))
+	if err == nil || !strings.Contains(err.Error(), "another nexuscli-go process") || !strings.Contains(err.Error(), fmt.Sprintf("PID %d", os.Getpid())) {
+		t.Errorf("Expected an error naming the process operating on the directory, got %v", err)
+	}
+
+	time.AfterFunc(20*time.Millisecond, func() { held.Unlock() })
+	lock, err := LockDir(dir, time.Minute, util.NewLogger(&logs))
+	if err != nil {
+		t.Fatalf("Expected the lock once it is released, got %v", err)
+	}
+	lock.Unlock()
+	if !strings.Contains(logs.String(), "waiting up to 1m0s") || !strings.Contains(logs.String(), "after waiting") {
+		t.Errorf("Expected the wait to be logged, got %q", logs.String())
+	}
+}
+
+func TestLockDirStale(t *testing.T) {
+	dir := t.TempDir()
+	path := testLockPath(t, dir)
+	pid := deadPID(t)
+
+	// A lock file left behind by a process that was killed is not locked
+	writeLockFile(t, path, pid)
+	var logs bytes.Buffer
+	lock, err := LockDir(dir, 0, util.NewVerboseLogger(&logs))
+	if err != nil {
+		t.Fatalf("Expected the stale lock to be taken over, got %v", err)
+	}
+	lock.Unlock()
+	if !strings.Contains(logs.String(), fmt.Sprintf("left behind by PID %d", pid)) {
+		t.Errorf("Expected the stale lock to be logged, got %q", logs.String())
+	}
+
+	// Without file locking, only the PID tells a stale lock file from a held one
+	writeLockFile(t, path, pid)
+	lock, owner, err := acquireExclusiveLock(path)
+	if err != nil || owner.pid != pid {
+		t.Fatalf("Expected the stale lock file of PID %d to be taken over, got %v, %v", pid, owner, err)
+	}
+	if _, _, err := acquireExclusiveLock(path); err != errLockHeld {
+		t.Errorf("Expected the lock file of a running process to be held, got %v", err)
+	}
+	lock.count = 1
+	if err := lock.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDownloadLocked(t *testing.T) {
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+	server.AddAsset("test-repo", "/folder/file.txt", nexusapi.Asset{}, []byte("content"))
+	config := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+
+	destDir := t.TempDir()
+	path := testLockPath(t, destDir)
+	held, _, err := acquireDirLock(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer held.Unlock()
+
+	opts := &DownloadOptions{ChecksumAlgorithm: "sha1", Logger: util.NewLogger(&bytes.Buffer{}), QuietMode: true, Recursive: true}
+	if status := Download("test-repo/folder", destDir, config, opts); status != DownloadError {
+		t.Errorf("Expected the download to fail while another process holds the lock, got %v", status)
+	}
+	if _, err := os.Stat(filepath.Join(destDir, "folder", "file.txt")); !os.IsNotExist(err) {
+		t.Errorf("Expected nothing to be downloaded, got %v", err)
+	}
+
+	opts.DryRun = true
+	if status := Download("test-repo/folder", destDir, config, opts); status != DownloadSuccess {
+		t.Errorf("Expected a dry run not to lock the destination, got %v", status)
+	}
+}
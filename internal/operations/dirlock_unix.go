@@ -0,0 +1,40 @@
+//go:build linux || darwin || freebsd
+
+package operations
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// lockFile locks f with flock without blocking
+func lockFile(f *os.File) error {
+	err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, syscall.EWOULDBLOCK):
+		return errLockHeld
+	case errors.Is(err, syscall.ENOLCK), errors.Is(err, syscall.EOPNOTSUPP), errors.Is(err, syscall.ENOTSUP):
+		return errLockUnsupported
+	default:
+		return err
+	}
+}
+
+// releaseLockFile removes the lock file before closing it, which releases the lock, so a
+// process waiting for it never locks a file that is no longer the lock file
+func releaseLockFile(f *os.File, path string) error {
+	err := os.Remove(path)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// processAlive reports whether the process pid runs on this host
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
@@ -0,0 +1,49 @@
+//go:build windows
+
+package operations
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// stillActive is the exit code of a process that has not exited
+const stillActive = 259
+
+// lockFile locks f with LockFileEx without blocking. The locked byte lies far beyond the
+// end of the file, so other processes can still read the owner recorded in it.
+func lockFile(f *os.File) error {
+	overlapped := &windows.Overlapped{OffsetHigh: 0x7fffffff}
+	err := windows.LockFileEx(windows.Handle(f.Fd()), windows.LOCKFILE_EXCLUSIVE_LOCK|windows.LOCKFILE_FAIL_IMMEDIATELY, 0, 1, 0, overlapped)
+	if errors.Is(err, windows.ERROR_LOCK_VIOLATION) {
+		return errLockHeld
+	}
+	return err
+}
+
+// releaseLockFile closes the lock file, which releases the lock. An open file cannot be
+// removed on Windows, and removing it after closing could remove the lock file of the
+// next owner, so the file is left in place, emptied so that it records no owner.
+func releaseLockFile(f *os.File, path string) error {
+	err := f.Truncate(0)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// processAlive reports whether the process pid runs on this host
+func processAlive(pid int) bool {
+	process, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, uint32(pid))
+	if err != nil {
+		return errors.Is(err, windows.ERROR_ACCESS_DENIED)
+	}
+	defer windows.CloseHandle(process)
+	var code uint32
+	if err := windows.GetExitCodeProcess(process, &code); err != nil {
+		return true
+	}
+	return code == stillActive
+}
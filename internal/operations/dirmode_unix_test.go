@@ -0,0 +1,166 @@
+//go:build linux || darwin || freebsd
+
+package operations
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/tympanix/nexus-cli/internal/archive"
+	"github.com/tympanix/nexus-cli/internal/config"
+	"github.com/tympanix/nexus-cli/internal/nexusapi"
+	"github.com/tympanix/nexus-cli/internal/util"
+)
+
+func assertDirMode(t *testing.T, dir string, want os.FileMode) {
+	t.Helper()
+	info, err := os.Stat(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode() & (os.ModePerm | os.ModeSetgid); mode != want {
+		t.Errorf("Expected %s to have mode %v, got %v", dir, want, mode)
+	}
+}
+
+// TestDownloadDirMode tests that the directories created for downloaded files get
+// DirMode, or 0777 less the umask without it
+func TestDownloadDirMode(t *testing.T) {
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+	server.AddAsset("repo", "/builds/lib/a.txt", nexusapi.Asset{}, []byte("a"))
+	config := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+
+	defer syscall.Umask(syscall.Umask(0002))
+
+	for _, tt := range []struct {
+		name    string
+		dirMode os.FileMode
+		want    os.FileMode
+	}{
+		{"umask", 0, 0775},
+		{"explicit", 0750 | os.ModeSetgid, 0750 | os.ModeSetgid},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			destDir := filepath.Join(t.TempDir(), "out")
+			opts := &DownloadOptions{Logger: util.NewLogger(io.Discard), QuietMode: true, Recursive: true, DirMode: tt.dirMode}
+			if err := opts.SetChecksumAlgorithm("sha1"); err != nil {
+				t.Fatal(err)
+			}
+			if status := downloadFolder("repo/builds", destDir, config, opts); status != DownloadSuccess {
+				t.Fatalf("Download failed with status %v", status)
+			}
+			for _, dir := range []string{destDir, filepath.Join(destDir, "builds"), filepath.Join(destDir, "builds", "lib")} {
+				assertDirMode(t, dir, tt.want)
+			}
+		})
+	}
+}
+
+// TestDownloadCompressedDirMode tests that directories created while extracting an
+// archive get DirMode
+func TestDownloadCompressedDirMode(t *testing.T) {
+	srcDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(srcDir, "lib", "nested"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(srcDir, "lib", "nested", "a.txt"), []byte("a"), 0644)
+	var buf bytes.Buffer
+	if err := archive.CreateTarGz(srcDir, &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+	server.AddAsset("repo", "/builds/archive.tar.gz", nexusapi.Asset{}, buf.Bytes())
+	config := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+
+	destDir := filepath.Join(t.TempDir(), "out")
+	opts := &DownloadOptions{Logger: util.NewLogger(io.Discard), QuietMode: true, Recursive: true, Compress: true, CompressionFormat: archive.FormatGzip, DirMode: 0770}
+	if err := opts.SetChecksumAlgorithm("sha1"); err != nil {
+		t.Fatal(err)
+	}
+	if status := downloadFolderCompressedWithArchiveName("repo", "builds", "archive.tar.gz", destDir, config, opts); status != DownloadSuccess {
+		t.Fatalf("Download failed with status %v", status)
+	}
+	for _, dir := range []string{destDir, filepath.Join(destDir, "lib"), filepath.Join(destDir, "lib", "nested")} {
+		assertDirMode(t, dir, 0770)
+	}
+}
+
+// TestDownloadCacheDirMode tests that the directories of a shared cache get DirMode, so
+// entries stored by one user can be restored by the group
+func TestDownloadCacheDirMode(t *testing.T) {
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+	server.AddAsset("repo", "/builds/lib/a.txt", nexusapi.Asset{}, []byte("a"))
+	config := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+
+	cacheDir := filepath.Join(t.TempDir(), "cache")
+	opts := &DownloadOptions{Logger: util.NewLogger(io.Discard), QuietMode: true, Recursive: true, CacheDir: cacheDir, DirMode: 0770 | os.ModeSetgid}
+	if err := opts.SetChecksumAlgorithm("sha1"); err != nil {
+		t.Fatal(err)
+	}
+	if status := downloadFolder("repo/builds", filepath.Join(t.TempDir(), "out"), config, opts); status != DownloadSuccess {
+		t.Fatalf("Download failed with status %v", status)
+	}
+	dirs := 0
+	err := filepath.WalkDir(cacheDir, func(path string, entry os.DirEntry, err error) error {
+		if err == nil && entry.IsDir() {
+			assertDirMode(t, path, 0770|os.ModeSetgid)
+			dirs++
+		}
+		return err
+	})
+	if err != nil || dirs < 2 {
+		t.Errorf("Expected the cache directories to be created, found %d: %v", dirs, err)
+	}
+}
+
+// TestDownloadFileModeHonorsUmask tests that a new downloaded file gets 0666 less the
+// umask and that a file it replaces keeps its mode
+func TestDownloadFileModeHonorsUmask(t *testing.T) {
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+	server.AddAsset("repo", "/builds/new.txt", nexusapi.Asset{}, []byte("new"))
+	server.AddAsset("repo", "/builds/replaced.txt", nexusapi.Asset{}, []byte("replaced"))
+	config := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+
+	defer syscall.Umask(syscall.Umask(0077))
+
+	destDir := t.TempDir()
+	replaced := filepath.Join(destDir, "builds", "replaced.txt")
+	if err := os.MkdirAll(filepath.Dir(replaced), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(replaced, []byte("old"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(replaced, 0640); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := &DownloadOptions{Logger: util.NewLogger(io.Discard), QuietMode: true, Recursive: true, OnConflict: ConflictOverwrite}
+	if err := opts.SetChecksumAlgorithm("sha1"); err != nil {
+		t.Fatal(err)
+	}
+	if status := downloadFolder("repo/builds", destDir, config, opts); status != DownloadSuccess {
+		t.Fatalf("Download failed with status %v", status)
+	}
+	for name, want := range map[string]os.FileMode{"new.txt": 0600, "replaced.txt": 0640} {
+		info, err := os.Stat(filepath.Join(destDir, "builds", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if mode := info.Mode().Perm(); mode != want {
+			t.Errorf("Expected %s to have mode %v, got %v", name, want, mode)
+		}
+	}
+	if data, _ := os.ReadFile(replaced); string(data) != "replaced" {
+		t.Errorf("Expected replaced.txt to be downloaded, got %q", data)
+	}
+}
@@ -0,0 +1,104 @@
+package operations
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/tympanix/nexus-cli/internal/nexusapi"
+	"github.com/tympanix/nexus-cli/internal/util"
+)
+
+// isOutOfSpace reports whether err was caused by a full filesystem
+func isOutOfSpace(err error) bool {
+	return errors.Is(err, syscall.ENOSPC)
+}
+
+// outOfSpace stops a download once the destination filesystem is full. Downloads that
+// have not started yet are skipped, and the size of every asset that was not written is
+// added up so the run can report it in a single error.
+type outOfSpace struct {
+	full    atomic.Bool
+	pending atomic.Int64
+	files   atomic.Int64
+	cause   atomic.Value // First error that hit the full filesystem
+}
+
+// record marks the filesystem as full because asset could not be written
+func (o *outOfSpace) record(asset nexusapi.Asset, err error) {
+	if o.full.CompareAndSwap(false, true) {
+		o.cause.Store(err)
+	}
+	o.skip(asset)
+}
+
+// skip counts an asset that is not downloaded because the filesystem is full
+func (o *outOfSpace) skip(asset nexusapi.Asset) {
+	o.pending.Add(asset.FileSize)
+	o.files.Add(1)
+}
+
+// err returns the error reported for the whole run, or nil if space never ran out
+func (o *outOfSpace) err(destDir string) error {
+	if !o.full.Load() {
+		return nil
+	}
+	return fmt.Errorf("destination out of space: %s is full (%v); %d file(s) with %s still pending",
+		destDir, o.cause.Load(), o.files.Load(), util.FormatByteSize(o.pending.Load()))
+}
+
+// requiredSpace estimates how many bytes downloading assets writes below destDir. Local
+// files with the size reported by Nexus are assumed to be up to date and skipped.
+func requiredSpace(assets []nexusapi.Asset, localPaths map[string]string, destDir string) int64 {
+	var required int64
+	for _, asset := range assets {
+		if info, err := os.Stat(filepath.Join(destDir, localPaths[asset.Path])); err == nil && info.Size() == asset.FileSize {
+			continue
+		}
+		required += asset.FileSize
+	}
+	return required
+}
+
+// checkSpace fails if the filesystem holding destDir has less than required bytes
+// available. destDir does not need to exist yet; its nearest existing parent is checked.
+func checkSpace(destDir string, required int64) error {
+	dir := destDir
+	for {
+		if _, err := os.Stat(dir); err == nil {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil
+		}
+		dir = parent
+	}
+
+	available, ok := availableSpace(dir)
+	if !ok || available >= required {
+		return nil
+	}
+	return fmt.Errorf("not enough space in %s: %s needed, %s available, %s short (use --no-space-check to skip this check)",
+		destDir, util.FormatByteSize(required), util.FormatByteSize(available), util.FormatByteSize(required-available))
+}
+
+// checkTotalSize fails if assets are larger than maxTotal bytes in total, going by the
+// sizes reported by Nexus. A maxTotal of 0 means no limit.
+func checkTotalSize(assets []nexusapi.Asset, maxTotal int64) error {
+	if maxTotal <= 0 {
+		return nil
+	}
+	var total int64
+	for _, asset := range assets {
+		total += asset.FileSize
+	}
+	if total > maxTotal {
+		return fmt.Errorf("the %d matched asset(s) total %s, more than the maximum of %s (--max-total-size)",
+			len(assets), util.FormatByteSize(total), util.FormatByteSize(maxTotal))
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package operations
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/tympanix/nexus-cli/internal/config"
+	"github.com/tympanix/nexus-cli/internal/nexusapi"
+	"github.com/tympanix/nexus-cli/internal/util"
+)
+
+func TestOutOfSpace(t *testing.T) {
+	writeErr := &os.PathError{Op: "write", Path: "/dest/a.bin", Err: syscall.ENOSPC}
+	if !isOutOfSpace(fmt.Errorf("download failed: %w", writeErr)) {
+		t.Error("Expected wrapped ENOSPC to be detected")
+	}
+	if isOutOfSpace(&os.PathError{Op: "write", Path: "/dest/a.bin", Err: syscall.EACCES}) {
+		t.Error("Expected other errors not to be treated as out of space")
+	}
+
+	var o outOfSpace
+	if err := o.err("/dest"); err != nil {
+		t.Errorf("Expected no error before space runs out, got %v", err)
+	}
+	o.record(nexusapi.Asset{FileSize: 2048}, writeErr)
+	o.skip(nexusapi.Asset{FileSize: 1024})
+	err := o.err("/dest")
+	if err == nil || !strings.Contains(err.Error(), "destination out of space") || !strings.Contains(err.Error(), "2 file(s) with 3.0 KiB still pending") {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestDownloadSpaceCheck(t *testing.T) {
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+	// Nexus reports a size beyond any real filesystem; the content is never requested
+	server.AddAsset("builds", "/app/huge.bin", nexusapi.Asset{FileSize: 1 << 50}, nil)
+	config := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+
+	var logBuf strings.Builder
+	destDir := filepath.Join(t.TempDir(), "not", "created", "yet")
+	opts := &DownloadOptions{Logger: util.NewLogger(&logBuf), QuietMode: true, SkipChecksum: true, Recursive: true}
+	if status := downloadFolder("builds/app", destDir, config, opts); status != DownloadError {
+		t.Fatalf("Expected the space check to fail the download, got status %v", status)
+	}
+	if !strings.Contains(logBuf.String(), "not enough space in "+destDir) {
+		t.Errorf("Expected space check error, got: %s", logBuf.String())
+	}
+	if server.GetDownloadCount() != 0 {
+		t.Error("Expected nothing to be downloaded")
+	}
+}
+
+func TestDownloadNoSpaceCheck(t *testing.T) {
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+	// The reported size exceeds the free space, but the check is skipped
+	server.AddAsset("builds", "/app/huge.bin", nexusapi.Asset{FileSize: 1 << 50}, []byte("small"))
+	config := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+
+	opts := &DownloadOptions{Logger: util.NewLogger(io.Discard), QuietMode: true, SkipChecksum: true, Recursive: true, NoSpaceCheck: true}
+	downloadFolder("builds/app", t.TempDir(), config, opts)
+	if server.GetDownloadCount() != 1 {
+		t.Errorf("Expected the download to be attempted with --no-space-check, got %d requests", server.GetDownloadCount())
+	}
+}
@@ -0,0 +1,15 @@
+//go:build linux || darwin || freebsd
+
+package operations
+
+import "syscall"
+
+// availableSpace returns the number of bytes available to unprivileged users on the
+// filesystem holding dir
+func availableSpace(dir string) (int64, bool) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return 0, false
+	}
+	return int64(st.Bavail) * int64(st.Bsize), true
+}
@@ -0,0 +1,19 @@
+//go:build windows
+
+package operations
+
+import "golang.org/x/sys/windows"
+
+// availableSpace returns the number of bytes available to the current user on the volume
+// holding dir
+func availableSpace(dir string) (int64, bool) {
+	path, err := windows.UTF16PtrFromString(dir)
+	if err != nil {
+		return 0, false
+	}
+	var available uint64
+	if err := windows.GetDiskFreeSpaceEx(path, &available, nil, nil); err != nil {
+		return 0, false
+	}
+	return int64(available), true
+}
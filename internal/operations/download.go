@@ -1,17 +1,23 @@
 package operations
 
 import (
+	"errors"
 	"fmt"
 	"io"
+	"math/rand/v2"
 	"os"
 	"path"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/tympanix/nexus-cli/internal/archive"
+	"github.com/tympanix/nexus-cli/internal/checksum"
 	"github.com/tympanix/nexus-cli/internal/config"
+	"github.com/tympanix/nexus-cli/internal/metrics"
 	"github.com/tympanix/nexus-cli/internal/nexusapi"
 	"github.com/tympanix/nexus-cli/internal/output"
 	"github.com/tympanix/nexus-cli/internal/progress"
@@ -19,7 +25,7 @@ import (
 )
 
 func listAssets(repository, src string, config *config.Config, recursive bool) ([]nexusapi.Asset, error) {
-	client := nexusapi.NewClient(config.NexusURL, config.Username, config.Password)
+	client := NewClient(config)
 	return client.ListAssets(repository, src, recursive)
 }
 
@@ -29,39 +35,67 @@ func filterAssetsByGlob(assets []nexusapi.Asset, basePath string, globPattern st
 	})
 }
 
-func downloadAsset(asset nexusapi.Asset, destDir string, basePath string, wg *sync.WaitGroup, errCh chan error, bar *progress.ProgressBarWithCount, tracker *output.TransferTracker, config *config.Config, opts *DownloadOptions) {
+func downloadAsset(asset nexusapi.Asset, localPath string, basePath string, wg *sync.WaitGroup, errCh chan error, bar *progress.ProgressBarWithCount, tracker *output.TransferTracker, config *config.Config, opts *DownloadOptions) {
 	defer wg.Done()
-	// Use helper to get relative path, applying flatten logic if enabled
-	resultPath := getRelativePath(asset.Path, "")
-	if opts.Flatten && basePath != "" {
-		resultPath = getRelativePath(asset.Path, basePath)
-	}
-
-	localPath := filepath.Join(destDir, resultPath)
 	startTime := time.Now()
 
-	// Check if file exists and validate checksum or skip based on file existence (skip this check if Force is enabled)
-	shouldSkip := false
+	// Once the destination is full, every remaining download would fail the same way
+	if opts.outOfSpace != nil && opts.outOfSpace.full.Load() {
+		opts.outOfSpace.skip(asset)
+		return
+	}
 
-	if !opts.Force {
-		if _, err := os.Stat(localPath); err == nil {
-			if opts.SkipChecksum {
+	// Check if file exists and validate checksum or skip based on file existence (skip this check if Force is enabled)
+	skipReason := "" // Why the local file is up to date, "" if it is to be downloaded
+	expected := opts.Expected.expect(localPath)
+
+	if !opts.Force && expected != nil {
+		// A file with an expected digest is compared against it rather than against the
+		// checksum Nexus reports, which may have changed since the digest was recorded
+		hashStart := time.Now()
+		if expected.matchesFile(opts.meter.skipWriter(), opts) {
+			skipReason = "expected digest match"
+		}
+		tracker.Stats().AddHashTime(time.Since(hashStart))
+	} else if !opts.Force {
+		if info, err := os.Stat(localPath); err == nil {
+			switch opts.EffectiveCompare() {
+			case CompareExistence:
 				// When checksum validation is skipped, only check if file exists and add to progress
-				shouldSkip = true
+				skipReason = "file exists"
 				if bar != nil {
-					bar.Add64(asset.FileSize)
+					bar.Skip(asset.FileSize)
+				}
+			case CompareSize:
+				// A size mismatch repairs files truncated by an interrupted download
+				if info.Size() == asset.FileSize {
+					skipReason = "size match"
+					if bar != nil {
+						bar.Skip(asset.FileSize)
+					}
+				} else {
+					opts.Logger.VerbosePrintf("Size mismatch (local %d bytes, remote %d bytes): %s\n", info.Size(), asset.FileSize, localPath)
+				}
+			case CompareChecksum:
+				if opts.checksumValidator == nil {
+					break
 				}
-			} else if opts.checksumValidator != nil {
 				// Use the new checksum.Validator for validation with progress tracking
-				valid, err := opts.checksumValidator.ValidateWithProgress(localPath, asset.Checksum, bar)
+				hashStart := time.Now()
+				// Hashing a local file transfers nothing, so it must not count against --max-rate
+				valid, err := opts.checksumValidator.ValidateWithProgress(localPath, asset.Checksum, opts.meter.skipWriter())
+				tracker.Stats().AddHashTime(time.Since(hashStart))
 				if err == nil && valid {
-					shouldSkip = true
+					skipReason = strings.ToUpper(opts.checksumValidator.Algorithm()) + " match"
+				} else if errors.Is(err, checksum.ErrMalformedChecksum) && !opts.QuietMode {
+					opts.Logger.Printf("Warning: cannot verify %s: %v\n", asset.Path, err)
 				}
 			}
 		}
 	}
 
-	if shouldSkip {
+	if skipReason != "" {
+		opts.Decisions.record(localPath, DecisionSkipped)
 		relPath := getRelativePath(asset.Path, basePath)
 		tracker.RecordFile(output.FileTransfer{
 			Path:      relPath,
@@ -69,6 +103,7 @@ func downloadAsset(asset nexusapi.Asset, destDir string, basePath string, wg *sy
 			Status:    output.TransferStatusSkipped,
 			StartTime: startTime,
 			EndTime:   time.Now(),
+			Reason:    skipReason,
 		})
 		// Increment file count for skipped files
 		if bar != nil {
@@ -80,7 +115,7 @@ func downloadAsset(asset nexusapi.Asset, destDir string, basePath string, wg *sy
 	// If dry-run is enabled, just log what would be downloaded (without creating directories)
 	if opts.DryRun {
 		relPath := getRelativePath(asset.Path, basePath)
-		opts.Logger.VerbosePrintf("Would download: %s\n", relPath)
+		opts.plan.Add(Action{Type: ActionDownload, Source: path.Join(asset.Repository, asset.Path), Target: localPath, Size: asset.FileSize})
 		tracker.RecordFile(output.FileTransfer{
 			Path:      relPath,
 			Size:      asset.FileSize,
@@ -89,18 +124,113 @@ func downloadAsset(asset nexusapi.Asset, destDir string, basePath string, wg *sy
 			EndTime:   time.Now(),
 		})
 		if bar != nil {
-			bar.Add64(asset.FileSize)
+			bar.Skip(asset.FileSize)
 			bar.IncrementFile()
 		}
 		return
 	}
 
+	// Protect locally modified files according to the conflict policy
+	if (opts.OnConflict == ConflictBackup || opts.OnConflict == ConflictSkip) && hasLocalConflict(localPath, asset, opts) {
+		relPath := getRelativePath(asset.Path, basePath)
+		if opts.OnConflict == ConflictSkip {
+			opts.conflicts.add(localPath)
+			opts.Decisions.record(localPath, DecisionConflict)
+			tracker.RecordFile(output.FileTransfer{
+				Path:      relPath,
+				Size:      asset.FileSize,
+				Status:    output.TransferStatusSkipped,
+				StartTime: startTime,
+				EndTime:   time.Now(),
+				Reason:    "local file differs",
+			})
+			if bar != nil {
+				bar.Skip(asset.FileSize)
+				bar.IncrementFile()
+			}
+			return
+		}
+		backupPath, err := backupLocalFile(localPath)
+		if err != nil {
+			tracker.RecordFile(output.FileTransfer{
+				Path:      relPath,
+				Size:      asset.FileSize,
+				Status:    output.TransferStatusFailed,
+				Error:     err,
+				StartTime: startTime,
+				EndTime:   time.Now(),
+			})
+			opts.Decisions.record(localPath, DecisionFailed)
+			errCh <- fmt.Errorf("failed to back up %s: %w", localPath, err)
+			return
+		}
+		opts.Logger.VerbosePrintf("Backed up local file: %s -> %s\n", localPath, backupPath)
+		opts.conflicts.add(fmt.Sprintf("%s -> %s", localPath, backupPath))
+	}
+
+	listed := expectListedChecksum(asset, basePath, opts)
+
+	// Restore the asset from the shared cache if a valid copy exists
+	if opts.cache != nil {
+		restored, err := opts.cache.restore(asset, localPath)
+		if err != nil {
+			opts.Logger.VerbosePrintf("Could not restore %s from cache: %v\n", asset.Path, err)
+		}
+		if restored {
+			// A cached copy that does not match the checksums file or the expected digest is downloaded again
+			if err := listed.verifyFile(localPath, opts); err != nil {
+				opts.Logger.VerbosePrintf("Not using cached copy of %s: %v\n", asset.Path, err)
+				restored = false
+			} else if expected != nil && !expected.matchesFile(io.Discard, opts) {
+				opts.Logger.VerbosePrintf("Not using cached copy of %s: it does not match its expected digest\n", asset.Path)
+				restored = false
+			}
+		}
+		if restored {
+			opts.Logger.VerbosePrintf("Restored from cache: %s\n", localPath)
+			opts.Decisions.record(localPath, DecisionRestored)
+			tracker.RecordFile(output.FileTransfer{
+				Path:      getRelativePath(asset.Path, basePath),
+				Size:      asset.FileSize,
+				Status:    output.TransferStatusSuccess,
+				StartTime: startTime,
+				EndTime:   time.Now(),
+			})
+			if bar != nil {
+				bar.Skip(asset.FileSize)
+				bar.IncrementFile()
+			}
+			return
+		}
+	}
+
 	// Create directory structure for actual download
-	os.MkdirAll(filepath.Dir(localPath), 0755)
+	err := util.MkdirAll(filepath.Dir(localPath), opts.DirMode)
 
-	client := nexusapi.NewClient(config.NexusURL, config.Username, config.Password)
-	f, err := os.Create(localPath)
+	client := NewClient(config)
+	// A missing signature fails the file before anything is downloaded
+	var signature *signatureCheck
+	if err == nil {
+		signature, err = opts.signatures.expect(client, asset)
+	}
+	// Download into a temporary file next to localPath and move it into place only once it
+	// has been verified, so a failed download never replaces (or truncates) the local file.
+	// This also keeps a local file that is hardlinked to a cache entry from being overwritten.
+	// With Resume the partial file has a fixed name and is kept if the transfer fails.
+	chunked := opts.chunked[asset.Path]
+	var f *os.File
+	var resumable *resumableDownload
+	if err == nil && opts.Resume && chunked == nil {
+		blockSize := opts.blockSize
+		if blockSize == 0 {
+			blockSize = resumeBlockSize
+		}
+		f, resumable, err = openResumable(localPath, asset, blockSize)
+	} else if err == nil {
+		f, err = createPartFile(localPath, ".part-")
+	}
 	if err != nil {
+		signature.close()
 		relPath := getRelativePath(asset.Path, basePath)
 		tracker.RecordFile(output.FileTransfer{
 			Path:      relPath,
@@ -110,17 +240,101 @@ func downloadAsset(asset nexusapi.Asset, destDir string, basePath string, wg *sy
 			StartTime: startTime,
 			EndTime:   time.Now(),
 		})
-		errCh <- err
+		opts.Decisions.record(localPath, DecisionFailed)
+		reportDownloadError(asset, err, errCh, opts)
 		return
 	}
+	tmpPath := f.Name()
+	keepPartial := false
+	defer func() {
+		// No-op once the file has been moved into place
+		if !keepPartial {
+			os.Remove(tmpPath)
+			resumable.remove()
+		}
+	}()
 	defer f.Close()
-
-	// Use a tee reader to update progress bar while downloading
-	writer := io.MultiWriter(f, bar)
-	err = client.DownloadAsset(asset.DownloadURL, writer)
+	defer signature.close()
+
+	// Everything computed from the data, which a resumed download also feeds with the
+	// part it reuses
+	var sums []io.Writer
+	digest := newVerifyHash(opts)
+	if digest != nil {
+		sums = append(sums, digest)
+	}
+	if listedWriter := listed.writer(); listedWriter != nil {
+		sums = append(sums, listedWriter)
+	}
+	if signatureWriter := signature.writer(); signatureWriter != nil {
+		sums = append(sums, signatureWriter)
+	}
+	// The checksums collected for the caller and checked against the expected digest are
+	// computed from the same data
+	hashers := expected.hashers(opts.Digests.hashers(digest, opts))
+	for _, h := range hashers {
+		if h != digest {
+			sums = append(sums, h)
+		}
+	}
+	if resumable != nil {
+		sums = append(sums, resumable)
+	}
+	// Received bytes update the progress bar and wire statistics; only a reused part of a
+	// resumed download skips the file, the transfer limit and the bar
+	transfer := limitWriter(io.MultiWriter(bar, tracker.Stats().WireWriter()), opts.limiter)
+	writer := limitWriter(io.MultiWriter(append([]io.Writer{f, bar, tracker.Stats().WireWriter()}, sums...)...), opts.limiter)
+	relPath := getRelativePath(asset.Path, basePath)
+	bar.StartFile(relPath)
+	transferStart := time.Now()
+	switch {
+	case chunked != nil:
+		err = chunked.download(client, localPath, writer, opts)
+	case resumable != nil:
+		var offset int64
+		var fetched int
+		offset, fetched, err = resumable.resume(f, client, asset, io.MultiWriter(sums...), transfer, bar.Skip)
+		if err == nil && offset > 0 {
+			opts.Logger.VerbosePrintf("Resuming %s at %d bytes, fetched %d corrupt block(s) again\n", localPath, offset, fetched)
+		}
+		if err == nil {
+			err = client.DownloadAssetRange(asset.DownloadURL, offset, -1, writer)
+		}
+		// A failed transfer is resumed on the next run; failed verification starts over
+		keepPartial = err != nil
+	default:
+		err = client.DownloadAsset(asset.DownloadURL, writer)
+	}
 	endTime := time.Now()
+	bar.FinishFile(relPath)
+	tracker.Stats().AddTransferTime(endTime.Sub(transferStart))
 
-	relPath := getRelativePath(asset.Path, basePath)
+	if err == nil {
+		err = f.Close()
+	}
+	if err == nil {
+		// The digest was computed while the data was written, so the file is never read back
+		err = verifyDownload(tmpPath, asset, digest, opts)
+	}
+	if err == nil {
+		err = listed.verify(opts)
+	}
+	if err == nil {
+		err = signature.verify()
+	}
+	var size int64
+	if err == nil && hashers != nil {
+		var info os.FileInfo
+		if info, err = os.Stat(tmpPath); err == nil {
+			size = info.Size()
+		}
+	}
+	if err == nil {
+		err = expected.verify(size, hashers)
+	}
+	if err == nil {
+		err = placeDownload(tmpPath, localPath)
+	}
 
 	if err != nil {
 		tracker.RecordFile(output.FileTransfer{
@@ -131,7 +345,8 @@ func downloadAsset(asset nexusapi.Asset, destDir string, basePath string, wg *sy
 			StartTime: startTime,
 			EndTime:   endTime,
 		})
-		errCh <- err
+		opts.Decisions.record(localPath, DecisionFailed)
+		reportDownloadError(asset, err, errCh, opts)
 	} else {
 		tracker.RecordFile(output.FileTransfer{
 			Path:      relPath,
@@ -140,11 +355,57 @@ func downloadAsset(asset nexusapi.Asset, destDir string, basePath string, wg *sy
 			StartTime: startTime,
 			EndTime:   endTime,
 		})
+		opts.Decisions.record(localPath, DecisionDownloaded)
+		opts.Digests.record(localPath, size, hashers)
 		// Only increment file count on successful download
 		bar.IncrementFile()
+		if chunked != nil {
+			removeChunkParts(localPath, opts)
+		}
+
+		if opts.cache != nil {
+			if err := opts.cache.store(asset, localPath, digest != nil); err != nil {
+				opts.Logger.VerbosePrintf("Could not add %s to cache: %v\n", asset.Path, err)
+			}
+		}
 	}
 }
 
+// reportDownloadError reports a failed download. Running out of space is collected by
+// opts.outOfSpace and reported once for the whole run instead of once per file.
+func reportDownloadError(asset nexusapi.Asset, err error, errCh chan error, opts *DownloadOptions) {
+	if opts.outOfSpace != nil && isOutOfSpace(err) {
+		opts.outOfSpace.record(asset, err)
+		return
+	}
+	errCh <- err
+}
+
+// createPartFile creates the temporary file that localPath is written to before it is
+// moved into place, named "." + its name + infix + a random number. Like os.Create, it is
+// created with 0666 less the umask.
+func createPartFile(localPath, infix string) (*os.File, error) {
+	prefix := filepath.Join(filepath.Dir(localPath), "."+filepath.Base(localPath)+infix)
+	for range 10000 {
+		f, err := os.OpenFile(prefix+strconv.FormatUint(uint64(rand.Uint32()), 10), os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
+		if !os.IsExist(err) {
+			return f, err
+		}
+	}
+	return nil, &os.PathError{Op: "createtemp", Path: prefix + "*", Err: os.ErrExist}
+}
+
+// placeDownload moves a completed temporary download to its final path. A file it
+// replaces keeps its permissions, as it did when downloads truncated it in place.
+func placeDownload(tmpPath, localPath string) error {
+	if info, err := os.Stat(localPath); err == nil && info.Mode().IsRegular() {
+		if err := os.Chmod(tmpPath, info.Mode().Perm()); err != nil {
+			return err
+		}
+	}
+	return os.Rename(tmpPath, localPath)
+}
+
 func downloadFolder(srcArg, destDir string, config *config.Config, opts *DownloadOptions) DownloadStatus {
 	repository, src, ok := util.ParseRepositoryPath(srcArg)
 	if !ok {
@@ -152,6 +413,73 @@ func downloadFolder(srcArg, destDir string, config *config.Config, opts *Downloa
 		return DownloadError
 	}
 
+	// A source path with glob metacharacters selects every path it matches below its prefix,
+	// which is the base path of the download. Escaped metacharacters are part of the path.
+	var glob *sourceGlob
+	if hasGlobMeta(src) {
+		if opts.Compress || opts.Chunked {
+			opts.Logger.Println("Error: --compress and --chunked cannot be combined with a glob in the source path")
+			return DownloadError
+		}
+		var err error
+		if glob, err = newSourceGlob(src); err != nil {
+			opts.Logger.Println("Error:", err)
+			return DownloadError
+		}
+		if glob.prefix == "" && !opts.QuietMode {
+			opts.Logger.Printf("Warning: '%s' has no folder before its first glob segment, so all of repository '%s' is listed to expand it\n", glob.pattern, repository)
+		}
+	} else {
+		src = unescapeGlobMeta(src)
+	}
+	basePath := src
+	if glob != nil {
+		basePath = glob.prefix
+	}
+
+	if opts.DryRun {
+		opts.startPlan()
+	}
+
+	// A single asset may be written to destDir itself, which is then the name of the file
+	fileDest := opts.FileDestination && !opts.recursiveListing() && src != "" && glob == nil && opts.ToArchive == "" && !opts.Compress && !isDirDestination(destDir)
+
+	if opts.LongPaths && opts.ToArchive == "" {
+		var err error
+		if destDir, err = longPath(destDir); err != nil {
+			opts.Logger.Println("Error:", err)
+			return DownloadError
+		}
+	}
+
+	opts.meter = newTransferMeter()
+	if opts.MaxRate > 0 {
+		opts.limiter = newRateLimiter(opts.MaxRate, opts.meter)
+	}
+
+	if (opts.ChecksumFile != "" || opts.ChecksumManifest != "") && !opts.Compress {
+		client := NewClient(config)
+		var checksums *checksumList
+		var err error
+		if opts.ChecksumManifest != "" {
+			checksums, err = downloadChecksumList(opts.ChecksumManifest, client)
+		} else {
+			checksums, err = loadChecksumList(opts.ChecksumFile, client)
+		}
+		if err != nil {
+			opts.Logger.Println("Error:", err)
+			return DownloadError
+		}
+		opts.checksums = checksums
+		opts.Logger.VerbosePrintf("Validating downloads against %d entries in %s\n", len(checksums.entries), checksums.source)
+	}
+
+	// The cache is keyed by checksum, so it is only used when checksums are validated. Cached
+	// copies are not used when signatures are verified, since only downloads are checked.
+	if opts.CacheDir != "" && !opts.SkipChecksum && opts.checksumValidator != nil && !opts.Compress && !opts.VerifySignature {
+		opts.cache = newDownloadCache(opts.CacheDir, repository, opts.checksumValidator, opts.DirMode)
+	}
+
 	// Check if src ends with .tar.gz, .tar.zst, or .zip for explicit archive name
 	explicitArchiveName := ""
 	if opts.Compress && (strings.HasSuffix(src, ".tar.gz") || strings.HasSuffix(src, ".tar.zst") || strings.HasSuffix(src, ".zip")) {
@@ -173,34 +501,225 @@ func downloadFolder(srcArg, destDir string, config *config.Config, opts *Downloa
 	}
 
 	// Original uncompressed download logic
-	assets, err := listAssets(repository, src, config, opts.Recursive)
+	opts.filtered = output.FilterCounts{}
+	stopList := metrics.Active().Phase(metrics.PhaseList)
+	assets, err := listDownloadAssets(repository, basePath, glob, config, opts)
+	stopList()
 	if err != nil {
 		opts.Logger.Println("Error listing assets:", err)
 		return DownloadError
 	}
+	// A single file uploaded with --chunked is stored as its parts and their manifest
+	if opts.Chunked && !opts.recursiveListing() && src != "" {
+		client := NewClient(config)
+		parts, err := client.SearchAssets(repository, src+".part")
+		if err != nil {
+			opts.Logger.Println("Error listing assets:", err)
+			return DownloadError
+		}
+		name := path.Base(src)
+		for _, part := range parts {
+			partName := path.Base(part.Path)
+			if _, ok := chunkPartIndex(partName, name); ok || partName == name+ChunkManifestSuffix {
+				assets = append(assets, part)
+			}
+		}
+	}
+	listed := len(assets)
+
+	// Count and download every path once, even if the search returned it several times
+	assets, duplicates := dedupeAssets(assets)
+	if duplicates > 0 {
+		opts.Logger.VerbosePrintf("Ignored %d duplicate asset path(s) in the listing\n", duplicates)
+	}
+
+	// Metadata manifests and attribute sidecars describe the other files and are never
+	// downloaded themselves
+	assets, manifests := splitMetadataManifests(assets)
+	assets = nexusapi.WithoutAttributeSidecars(assets)
 
-	// Apply glob filtering if specified
+	// Signatures are looked up among all listed assets, including those the filters exclude
+	if opts.VerifySignature {
+		opts.signatures, err = newSignatureVerifier(opts.PublicKeyFile, repository, assets)
+		if err != nil {
+			opts.Logger.Println("Error:", err)
+			return DownloadError
+		}
+		opts.Logger.VerbosePrintf("Verifying signatures against %d public key(s) in %s\n", len(opts.signatures.keyring), opts.PublicKeyFile)
+	}
+
+	if opts.Chunked {
+		client := NewClient(config)
+		assets, opts.chunked, err = splitChunkedFiles(client, assets)
+		if err != nil {
+			opts.Logger.Println("Error:", err)
+			return DownloadError
+		}
+	}
+
+	// Apply glob filtering if specified, to the paths within each match of a source glob
+	relativeTo := func(asset nexusapi.Asset) string {
+		if glob != nil {
+			return glob.base(asset.Path)
+		}
+		return src
+	}
 	if opts.GlobPattern != "" {
-		assets, err = filterAssetsByGlob(assets, src, opts.GlobPattern)
+		if opts.GlobDebug {
+			relPaths := make([]string, len(assets))
+			for i, asset := range assets {
+				relPaths[i] = getRelativePath(asset.Path, relativeTo(asset))
+			}
+			logGlobDecisions(relPaths, opts.GlobPattern, opts.Logger)
+		}
+		unfiltered := len(assets)
+		assets, err = util.FilterWithGlob(assets, opts.GlobPattern, func(asset nexusapi.Asset) string {
+			return getRelativePath(asset.Path, relativeTo(asset))
+		})
 		if err != nil {
 			opts.Logger.Println("Error filtering assets:", err)
 			return DownloadError
 		}
+		opts.filtered.Glob = unfiltered - len(assets)
 	}
 
 	if len(assets) == 0 {
-		opts.Logger.Printf("No assets found in folder '%s' in repository '%s'\n", src, repository)
+		if listed == 0 && repositoryMissing(repository, config, opts) {
+			return DownloadError
+		}
+		if opts.Expected != nil {
+			// Every expected file is gone, so there is nothing to download
+			return checkExpectedListed(nil, destDir, opts)
+		}
+		if glob != nil && len(glob.bases) == 0 && opts.filtered.Depth == 0 {
+			opts.Logger.Println(glob.noMatch(repository))
+			return DownloadNoAssetsFound
+		}
+		if total := opts.filtered.Total(); total > 0 {
+			opts.Logger.Printf("No assets found in folder '%s' in repository '%s' (%d excluded by filters: %s)\n", src, repository, total, opts.filtered)
+		} else {
+			opts.Logger.Printf("No assets found in folder '%s' in repository '%s'\n", src, repository)
+		}
 		return DownloadNoAssetsFound
 	}
+	if len(assets) < opts.MinFiles {
+		opts.Logger.Printf("Error: only %d asset(s) matched in folder '%s' in repository '%s', expected at least %d (--min-files)\n", len(assets), src, repository, opts.MinFiles)
+		return DownloadTooFewAssets
+	}
 
-	// Build a map of remote asset paths for delete-extra functionality
-	remoteAssetPaths := make(map[string]bool)
+	// The limit counts assets that passed the filters, in the order selected by --sort-server
+	if opts.Limit > 0 && len(assets) > opts.Limit {
+		opts.Logger.VerbosePrintf("Limiting download to the first %d of %d assets\n", opts.Limit, len(assets))
+		opts.filtered.Limit = len(assets) - opts.Limit
+		assets = assets[:opts.Limit]
+	}
+	if opts.DryRun {
+		opts.plan.AddFiltered(opts.filtered)
+	}
+
+	// Build the local path for every asset up front, applying flatten logic if enabled
+	resultPaths := make(map[string]string, len(assets))
 	for _, asset := range assets {
 		resultPath := getRelativePath(asset.Path, "")
-		if opts.Flatten && src != "" {
-			resultPath = getRelativePath(asset.Path, src)
+		if opts.Flatten && basePath != "" {
+			resultPath = getRelativePath(asset.Path, basePath)
+		}
+		resultPaths[asset.Path] = resultPath
+	}
+
+	singleFile := fileDest && len(assets) == 1
+	if singleFile {
+		// The asset is downloaded as the file destDir, in its parent directory
+		opts.Logger.VerbosePrintf("Downloading %s to the file %s\n", assets[0].Path, destDir)
+		resultPaths[assets[0].Path] = filepath.Base(destDir)
+		destDir = filepath.Dir(destDir)
+	}
+
+	// Detect assets that flatten onto the same local path before downloading anything
+	if opts.Flatten {
+		resultPaths, err = resolveFlattenCollisions(resultPaths, opts.FlattenOnConflict)
+		if err != nil {
+			opts.Logger.Println("Error:", err)
+			return DownloadError
+		}
+		// With --flatten-on-conflict=skip only the first asset for each local path is kept
+		kept := assets[:0:0]
+		for _, asset := range assets {
+			if _, ok := resultPaths[asset.Path]; !ok {
+				opts.Logger.Printf("Skipping %s (flattens onto the same local path as another asset)\n", asset.Path)
+				continue
+			}
+			kept = append(kept, asset)
+		}
+		assets = kept
+	}
+
+	if status := checkExpectedListed(resultPaths, destDir, opts); status != DownloadSuccess {
+		return status
+	}
+
+	// Fail fast on an unexpectedly large download, before anything is written or deleted
+	if err := checkTotalSize(assets, opts.MaxTotalSize); err != nil {
+		opts.Logger.Println("Error:", err)
+		return DownloadError
+	}
+
+	// Fail before downloading anything if a deep tree would hit the path limits halfway
+	if !opts.NoPathCheck && opts.ToArchive == "" {
+		if err := checkPathLengths(destDir, resultPaths, opts.LongPaths); err != nil {
+			opts.Logger.Println("Error:", err)
+			return DownloadError
 		}
+	}
+
+	if opts.ToArchive != "" {
+		target := repository
+		if src != "" {
+			target = path.Join(repository, src)
+		}
+		return downloadToArchive(assets, resultPaths, target, basePath, config, opts)
+	}
+
+	// The destination is only cleaned once the listing and every check above succeeded, so
+	// a failed or empty listing never destroys local files
+	if !fileDest {
+		if err := applyNonEmptyPolicy(destDir, opts); err != nil {
+			opts.Logger.Println("Error:", err)
+			return DownloadError
+		}
+	}
+
+	// With --on-conflict=fail, abort before downloading if any local file would be overwritten
+	if opts.OnConflict == ConflictFail {
+		var conflicting []string
+		for _, asset := range assets {
+			localPath := filepath.Join(destDir, resultPaths[asset.Path])
+			if hasLocalConflict(localPath, asset, opts) {
+				conflicting = append(conflicting, localPath)
+			}
+		}
+		if len(conflicting) > 0 {
+			sort.Strings(conflicting)
+			opts.Logger.Printf("Error: %d local file(s) differ from Nexus and would be overwritten (--on-conflict=fail):\n", len(conflicting))
+			for _, localPath := range conflicting {
+				opts.Logger.Printf("  %s\n", localPath)
+			}
+			return DownloadError
+		}
+	}
+	opts.conflicts = &conflictLog{}
+	opts.outOfSpace = &outOfSpace{}
+
+	// Build a map of remote asset paths for delete-extra functionality
+	remoteAssetPaths := make(map[string]bool)
+	for assetPath, resultPath := range resultPaths {
 		remoteAssetPaths[filepath.Join(destDir, resultPath)] = true
+		// Parts of a file that failed to reassemble are kept to resume from
+		if chunked := opts.chunked[assetPath]; chunked != nil {
+			for i := range chunked.manifest.Parts {
+				remoteAssetPaths[chunkPartPath(filepath.Join(destDir, resultPath), i+1)] = true
+			}
+		}
 	}
 
 	// Calculate total bytes to download using fileSize from search API
@@ -209,25 +728,49 @@ func downloadFolder(srcArg, destDir string, config *config.Config, opts *Downloa
 		totalBytes += asset.FileSize
 	}
 
+	// Fail before downloading anything if the destination cannot hold the new files
+	if !opts.NoSpaceCheck && !opts.DryRun {
+		if err := checkSpace(destDir, requiredSpace(assets, resultPaths, destDir)); err != nil {
+			opts.Logger.Println("Error:", err)
+			return DownloadError
+		}
+	}
+
 	target := repository
 	if src != "" {
 		target = path.Join(repository, src)
 	}
 	showProgress := util.IsATTY() && !opts.QuietMode && !opts.DryRun
 	tracker := output.NewTransferTracker(output.TransferTypeDownload, target, opts.Logger, opts.QuietMode, opts.Logger.IsVerbose(), showProgress)
+	tracker.SetMeter(opts.meter)
+	tracker.AddFiltered(opts.filtered)
 	tracker.PrintHeader(len(assets), totalBytes)
 
-	bar := progress.NewProgressBarWithCount(totalBytes, "Processing files", len(assets), showProgress)
+	bar := progress.NewProgressBarWithCount(opts.meter, totalBytes, "Processing files", len(assets), !opts.QuietMode && !opts.DryRun)
 
+	// Limit the number of in-flight downloads when concurrency is configured
+	var sem chan struct{}
+	if opts.Concurrency > 0 {
+		sem = make(chan struct{}, opts.Concurrency)
+	}
+
+	stopTransfer := metrics.Active().Phase(metrics.PhaseTransfer)
 	var wg sync.WaitGroup
 	errCh := make(chan error, len(assets))
 	for _, asset := range assets {
 		wg.Add(1)
+		if sem != nil {
+			sem <- struct{}{}
+		}
 		go func(asset nexusapi.Asset) {
-			downloadAsset(asset, destDir, src, &wg, errCh, bar, tracker, config, opts)
+			downloadAsset(asset, filepath.Join(destDir, resultPaths[asset.Path]), basePath, &wg, errCh, bar, tracker, config, opts)
+			if sem != nil {
+				<-sem
+			}
 		}(asset)
 	}
 	wg.Wait()
+	stopTransfer()
 	close(errCh)
 
 	nErrors := 0
@@ -237,19 +780,69 @@ func downloadFolder(srcArg, destDir string, config *config.Config, opts *Downloa
 	}
 
 	bar.Finish()
+	tracker.FlushSkipped()
+
+	if err := opts.outOfSpace.err(destDir); err != nil {
+		opts.Logger.Println("Error:", err)
+		return DownloadError
+	}
+
+	if opts.PreserveMtime && !opts.DryRun {
+		localPaths := make(map[string]string, len(resultPaths))
+		for assetPath, resultPath := range resultPaths {
+			localPaths[strings.TrimLeft(assetPath, "/")] = filepath.Join(destDir, resultPath)
+		}
+		client := NewClient(config)
+		restoreMetadata(client, repository, basePath, manifests, localPaths, opts)
+	}
 
 	// Delete extra files if requested (but not in dry-run mode)
 	var nDeleted int
-	if opts.DeleteExtra && !opts.DryRun {
+	if opts.DeleteExtra && opts.Limit > 0 {
+		// Every local file outside the limited selection would look extra
+		opts.Logger.Println("Warning: --delete is ignored with --limit (no files were deleted)")
+	} else if opts.DeleteExtra && singleFile {
+		// The other files next to the downloaded file were never part of the download
+		opts.Logger.Println("Warning: --delete is ignored when downloading to a file (no files were deleted)")
+	} else if opts.DeleteExtra && !opts.DryRun {
+		stopDelete := metrics.Active().Phase(metrics.PhaseDelete)
 		nDeleted = deleteExtraFiles(destDir, remoteAssetPaths, opts)
+		stopDelete()
 	} else if opts.DeleteExtra && opts.DryRun {
-		opts.Logger.Println("Dry-run mode: --delete flag ignored (no files would be deleted)")
+		extra, err := extraFiles(destDir, remoteAssetPaths)
+		if err != nil && !os.IsNotExist(err) {
+			opts.Logger.Printf("Error walking directory: %v\n", err)
+		}
+		for _, path := range extra {
+			opts.plan.Add(Action{Type: ActionDeleteLocal, Target: path})
+		}
 	}
 
 	if nDeleted > 0 {
 		opts.Logger.VerbosePrintf("Deleted %d extra files\n", nDeleted)
 	}
 
+	if entries := opts.conflicts.sorted(); len(entries) > 0 {
+		if opts.OnConflict == ConflictSkip {
+			opts.Logger.Printf("Kept %d locally modified file(s) (--on-conflict=skip):\n", len(entries))
+		} else {
+			opts.Logger.Printf("Backed up %d locally modified file(s) (--on-conflict=backup):\n", len(entries))
+		}
+		for _, entry := range entries {
+			opts.Logger.Printf("  %s\n", entry)
+		}
+	}
+
+	if failed := opts.checksums.failures(); len(failed) > 0 {
+		opts.Logger.Printf("%d file(s) failed validation against %s:\n", len(failed), opts.checksums.source)
+		for _, failure := range failed {
+			opts.Logger.Printf("  %s\n", failure)
+		}
+	}
+
+	if opts.DryRun {
+		opts.printPlan()
+	}
 	tracker.PrintSummary()
 
 	if nErrors == 0 {
@@ -258,6 +851,35 @@ func downloadFolder(srcArg, destDir string, config *config.Config, opts *Downloa
 	return DownloadError
 }
 
+// checkExpectedListed fails with DownloadMissing, before anything is downloaded, if files of
+// opts.Expected are not among resultPaths, the local paths of the listed assets relative to
+// destDir. With opts.AllowMissing they are only recorded, see ExpectedFiles.Missing.
+func checkExpectedListed(resultPaths map[string]string, destDir string, opts *DownloadOptions) DownloadStatus {
+	if opts.Expected == nil {
+		return DownloadSuccess
+	}
+	listed := make(map[string]bool, len(resultPaths))
+	for _, resultPath := range resultPaths {
+		listed[filepath.Join(destDir, filepath.FromSlash(resultPath))] = true
+	}
+	missing := opts.Expected.recordMissing(listed)
+	if len(missing) == 0 || opts.AllowMissing {
+		return DownloadSuccess
+	}
+	opts.Logger.Printf("Error: %d expected file(s) no longer exist in Nexus: %s\n", len(missing), strings.Join(missing, ", "))
+	return DownloadMissing
+}
+
+// isDirDestination reports whether dest names a directory to download into: it exists as
+// a directory or ends in a path separator
+func isDirDestination(dest string) bool {
+	if strings.HasSuffix(dest, "/") || strings.HasSuffix(dest, string(filepath.Separator)) {
+		return true
+	}
+	info, err := os.Stat(dest)
+	return err == nil && info.IsDir()
+}
+
 // downloadFolderCompressed downloads and extracts a compressed archive
 func downloadFolderCompressed(repository, src, destDir string, config *config.Config, opts *DownloadOptions) DownloadStatus {
 	return downloadFolderCompressedWithArchiveName(repository, src, "", destDir, config, opts)
@@ -285,7 +907,7 @@ func downloadFolderCompressedWithArchiveName(repository, src, explicitArchiveNam
 	opts.Logger.VerbosePrintf("Looking for compressed archive: %s (format: %s)\n", archiveName, opts.CompressionFormat)
 
 	// List assets to find the archive
-	assets, err := listAssets(repository, src, config, opts.Recursive)
+	assets, err := listDownloadAssets(repository, src, nil, config, opts)
 	if err != nil {
 		opts.Logger.Println("Error listing assets:", err)
 		return DownloadError
@@ -301,6 +923,9 @@ func downloadFolderCompressedWithArchiveName(repository, src, explicitArchiveNam
 	}
 
 	if archiveAsset == nil {
+		if len(assets) == 0 && repositoryMissing(repository, config, opts) {
+			return DownloadError
+		}
 		opts.Logger.Printf("Archive '%s' not found in '%s' in repository '%s'\n", archiveName, src, repository)
 		opts.Logger.VerbosePrintln("Available assets:")
 		for _, asset := range assets {
@@ -314,18 +939,32 @@ func downloadFolderCompressedWithArchiveName(repository, src, explicitArchiveNam
 		return DownloadError
 	}
 
+	if opts.DryRun && opts.plan == nil {
+		opts.startPlan() // Not called through downloadFolder
+	}
+	// Like an uncompressed download, the destination is only cleaned once the archive was found
+	if err := applyNonEmptyPolicy(destDir, opts); err != nil {
+		opts.Logger.Println("Error:", err)
+		return DownloadError
+	}
+
 	// If dry-run is enabled, just report what would be downloaded
 	if opts.DryRun {
+		opts.plan.Add(Action{Type: ActionExtract, Source: path.Join(repository, archiveAsset.Path), Target: destDir, Size: archiveAsset.FileSize})
+		opts.printPlan()
 		opts.Logger.Printf("Dry-run mode: Would download and extract archive '%s' from '%s' in repository '%s' to '%s'\n",
 			archiveName, src, repository, destDir)
 		return DownloadSuccess
 	}
 
-	showProgress := util.IsATTY() && !opts.QuietMode
-	bar := progress.NewProgressBarWithCount(archiveAsset.FileSize, "Downloading archive", 1, showProgress)
+	if opts.meter == nil {
+		opts.meter = newTransferMeter() // Not called through downloadFolder
+	}
+	bar := progress.NewProgressBarWithCount(opts.meter, archiveAsset.FileSize, "Downloading archive", 1, !opts.QuietMode)
+	stats := output.NewTransferStats()
 
 	// Download and extract archive
-	client := nexusapi.NewClient(config.NexusURL, config.Username, config.Password)
+	client := NewClient(config)
 
 	// Create a pipe for streaming decompression
 	pr, pw := io.Pipe()
@@ -333,7 +972,9 @@ func downloadFolderCompressedWithArchiveName(repository, src, explicitArchiveNam
 
 	// Extract in a goroutine
 	go func() {
-		if err := opts.CompressionFormat.ExtractArchive(pr, destDir); err != nil {
+		extracted, err := opts.CompressionFormat.ExtractArchiveWithSize(pr, destDir, opts.DirMode)
+		stats.AddLogicalBytes(extracted)
+		if err != nil {
 			errChan <- fmt.Errorf("failed to extract archive: %w", err)
 		} else {
 			errChan <- nil
@@ -341,8 +982,10 @@ func downloadFolderCompressedWithArchiveName(repository, src, explicitArchiveNam
 	}()
 
 	// Download with progress tracking
-	progressWriter := io.MultiWriter(pw, bar)
+	progressWriter := limitWriter(io.MultiWriter(pw, bar, stats.WireWriter()), opts.limiter)
+	transferStart := time.Now()
 	err = client.DownloadAsset(archiveAsset.DownloadURL, progressWriter)
+	stats.AddTransferTime(time.Since(transferStart))
 	pw.Close()
 
 	if err != nil {
@@ -359,6 +1002,7 @@ func downloadFolderCompressedWithArchiveName(repository, src, explicitArchiveNam
 	bar.Finish()
 	opts.Logger.Printf("Downloaded and extracted archive '%s' from '%s' in repository '%s' to '%s'\n",
 		archiveName, src, repository, destDir)
+	opts.Logger.Println(stats.Snapshot().String())
 	return DownloadSuccess
 }
 
@@ -366,38 +1010,45 @@ func downloadFolderCompressedWithArchiveName(repository, src, explicitArchiveNam
 func deleteExtraFiles(destDir string, remoteAssetPaths map[string]bool, opts *DownloadOptions) int {
 	nDeleted := 0
 
-	// Walk through all files in the destination directory
+	extra, err := extraFiles(destDir, remoteAssetPaths)
+	if err != nil {
+		opts.Logger.Printf("Error walking directory: %v\n", err)
+	}
+	for _, path := range extra {
+		opts.Logger.VerbosePrintf("Deleting extra file: %s\n", path)
+		if err := os.Remove(path); err != nil {
+			opts.Logger.Printf("Failed to delete file %s: %v\n", path, err)
+		} else {
+			nDeleted++
+		}
+	}
+
+	// Clean up empty directories
+	cleanupEmptyDirectories(destDir, opts)
+
+	return nDeleted
+}
+
+// extraFiles returns the files in destDir that are not in remoteAssetPaths, in walk order.
+// Backups made by --on-conflict=backup are never extra, nor are the partial files kept by
+// --resume for a listed file.
+func extraFiles(destDir string, remoteAssetPaths map[string]bool) ([]string, error) {
+	var extra []string
 	err := filepath.Walk(destDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
-
-		// Skip directories
-		if info.IsDir() {
+		if info.IsDir() || IsConflictBackup(path) || remoteAssetPaths[path] {
 			return nil
 		}
-
-		// Check if this file exists in remote assets
-		if !remoteAssetPaths[path] {
-			opts.Logger.VerbosePrintf("Deleting extra file: %s\n", path)
-			if err := os.Remove(path); err != nil {
-				opts.Logger.Printf("Failed to delete file %s: %v\n", path, err)
-			} else {
-				nDeleted++
-			}
+		// The partial file of a download to resume is kept as long as the file is listed
+		if target, ok := resumeTarget(path); ok && remoteAssetPaths[target] {
+			return nil
 		}
-
+		extra = append(extra, path)
 		return nil
 	})
-
-	if err != nil {
-		opts.Logger.Printf("Error walking directory: %v\n", err)
-	}
-
-	// Clean up empty directories
-	cleanupEmptyDirectories(destDir, opts)
-
-	return nDeleted
+	return extra, err
 }
 
 // cleanupEmptyDirectories removes empty directories from the destination
@@ -431,18 +1082,77 @@ func cleanupEmptyDirectories(destDir string, opts *DownloadOptions) {
 }
 
 func DownloadMain(src, dest string, config *config.Config, opts *DownloadOptions) {
-	processedSrc, err := processKeyTemplateWrapper(src, opts.KeyFromFile)
+	if status := Download(src, dest, config, opts); status != DownloadSuccess {
+		os.Exit(int(status))
+	}
+}
+
+// Download downloads src to dest like DownloadMain, but returns the status instead of
+// exiting, so callers can go on after a failed download.
+func Download(src, dest string, config *config.Config, opts *DownloadOptions) DownloadStatus {
+	if opts.WaitForAvailable > 0 {
+		client := NewClient(config)
+		if err := waitForStatus(client.StatusAvailable, "available", opts.WaitForAvailable, opts.Logger); err != nil {
+			fmt.Println("Error:", err)
+			return DownloadError
+		}
+	}
+
+	processedSrc, err := processKeyTemplateWrapper(src, opts.KeyFromFile, opts.GlobPattern)
 	if err != nil {
 		fmt.Println("Error:", err)
-		os.Exit(1)
+		return DownloadError
 	}
 
 	if opts.KeyFromFile != "" {
 		opts.Logger.Printf("Using key template: %s -> %s\n", src, processedSrc)
 	}
 
+	if util.HasLatest(processedSrc) {
+		resolvedSrc, err := resolveLatest(processedSrc, config, opts)
+		if err != nil {
+			fmt.Println("Error:", err)
+			return DownloadError
+		}
+		opts.Logger.Printf("Resolved %s -> %s\n", processedSrc, resolvedSrc)
+		processedSrc = resolvedSrc
+	}
+
+	// A dry run changes nothing, and an archive is written under a new name
+	if !opts.DryRun && opts.ToArchive == "" {
+		lock, err := LockDir(dest, opts.WaitLock, opts.Logger)
+		if err != nil {
+			opts.Logger.Println("Error:", err)
+			return DownloadError
+		}
+		defer lock.Unlock()
+	}
+
 	status := downloadFolder(processedSrc, dest, config, opts)
 	if status != DownloadSuccess {
-		os.Exit(int(status))
+		return status
+	}
+
+	if opts.TreeChecksum && !opts.DryRun {
+		if err := printTreeChecksum(dest, opts); err != nil {
+			fmt.Println("Error computing tree checksum:", err)
+			return DownloadError
+		}
+	}
+	return DownloadSuccess
+}
+
+// printTreeChecksum computes and prints the root checksum of the downloaded tree.
+// In quiet mode only the bare checksum is printed so it can be captured by scripts.
+func printTreeChecksum(dest string, opts *DownloadOptions) error {
+	treeChecksum, err := checksum.ComputeTreeChecksum(dest, opts.ChecksumAlgorithm)
+	if err != nil {
+		return err
+	}
+	if opts.QuietMode {
+		fmt.Println(treeChecksum)
+	} else {
+		opts.Logger.Printf("Tree checksum: %s\n", treeChecksum)
 	}
+	return nil
 }
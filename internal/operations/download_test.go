@@ -1,14 +1,19 @@
 package operations
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
 	"github.com/tympanix/nexus-cli/internal/archive"
 	"github.com/tympanix/nexus-cli/internal/config"
+	"github.com/tympanix/nexus-cli/internal/output"
 	"github.com/tympanix/nexus-cli/internal/util"
 	"io"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/tympanix/nexus-cli/internal/nexusapi"
 )
@@ -177,6 +182,73 @@ func TestDownloadFlatten(t *testing.T) {
 	}
 }
 
+// TestDownloadFlattenCollision tests each --flatten-on-conflict mode with assets that
+// flatten onto the same local path
+func TestDownloadFlattenCollision(t *testing.T) {
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+
+	// Both paths clean to test-folder/file.txt
+	server.AddAsset("test-repo", "/test-folder/file.txt", nexusapi.Asset{}, []byte("first"))
+	server.AddAsset("test-repo", "/test-folder//file.txt", nexusapi.Asset{}, []byte("second"))
+	server.AddAsset("test-repo", "/test-folder/other.txt", nexusapi.Asset{}, []byte("other"))
+
+	config := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+
+	tests := []struct {
+		mode     FlattenConflictMode
+		status   DownloadStatus
+		expected map[string]string
+	}{
+		{mode: FlattenConflictError, status: DownloadError, expected: map[string]string{}},
+		{mode: FlattenConflictSkip, status: DownloadSuccess, expected: map[string]string{
+			"file.txt":  "second",
+			"other.txt": "other",
+		}},
+		{mode: FlattenConflictRename, status: DownloadSuccess, expected: map[string]string{
+			"file.txt":   "second",
+			"file-1.txt": "first",
+			"other.txt":  "other",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			destDir := t.TempDir()
+			opts := &DownloadOptions{
+				ChecksumAlgorithm: "sha1",
+				Flatten:           true,
+				FlattenOnConflict: tt.mode,
+				Logger:            util.NewLogger(io.Discard),
+				QuietMode:         true,
+				Recursive:         true,
+			}
+
+			if status := downloadFolder("test-repo/test-folder", destDir, config, opts); status != tt.status {
+				t.Fatalf("Expected status %v, got %v", tt.status, status)
+			}
+
+			entries, err := os.ReadDir(destDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != len(tt.expected) {
+				t.Errorf("Expected %d files, got %d", len(tt.expected), len(entries))
+			}
+			for name, content := range tt.expected {
+				data, err := os.ReadFile(filepath.Join(destDir, name))
+				if err != nil {
+					t.Errorf("Expected %s to be downloaded: %v", name, err)
+					continue
+				}
+				if string(data) != content {
+					t.Errorf("Expected %s to contain %q, got %q", name, content, string(data))
+				}
+			}
+		})
+	}
+}
+
 // TestDownloadNoFlatten tests that download without flatten preserves full path
 func TestDownloadNoFlatten(t *testing.T) {
 	testContent := "test content"
@@ -1175,6 +1247,70 @@ func TestDownloadWithGlobPatternNoMatch(t *testing.T) {
 	}
 }
 
+// TestDownloadWithGlobDebug tests that --glob-debug logs the pattern deciding each file
+func TestDownloadWithGlobDebug(t *testing.T) {
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+
+	server.AddAsset("test-repo", "/test-folder/main.go", nexusapi.Asset{}, []byte("package main"))
+	server.AddAsset("test-repo", "/test-folder/main_test.go", nexusapi.Asset{}, []byte("package main"))
+	server.AddAsset("test-repo", "/test-folder/README.md", nexusapi.Asset{}, []byte("# readme"))
+
+	config := &config.Config{
+		NexusURL: server.URL,
+		Username: "test",
+		Password: "test",
+	}
+
+	var logBuf bytes.Buffer
+	opts := &DownloadOptions{
+		Logger:    util.NewLogger(&logBuf),
+		Recursive: true,
+		GlobDebug: true,
+	}
+	if err := opts.SetChecksumAlgorithm("sha1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := opts.SetGlobPattern("**/*.go,!**/*_test.go"); err != nil {
+		t.Fatal(err)
+	}
+
+	status := downloadFolder("test-repo/test-folder", t.TempDir(), config, opts)
+	if status != DownloadSuccess {
+		t.Fatalf("Expected DownloadSuccess, got %d", status)
+	}
+
+	logOutput := logBuf.String()
+	for _, expected := range []string{
+		"[glob] include main.go (matched '**/*.go')",
+		"[glob] exclude main_test.go (excluded by '!**/*_test.go')",
+		"[glob] exclude README.md (no include pattern matched)",
+	} {
+		if !strings.Contains(logOutput, expected) {
+			t.Errorf("Expected log to contain %q, got:\n%s", expected, logOutput)
+		}
+	}
+}
+
+// TestSetGlobPatternInvalid tests that malformed glob patterns are rejected when configuring options
+func TestSetGlobPatternInvalid(t *testing.T) {
+	downloadOpts := &DownloadOptions{}
+	if err := downloadOpts.SetGlobPattern("**/*.{go,md}"); err == nil {
+		t.Error("Expected error for brace expansion pattern on download options")
+	}
+	if downloadOpts.GlobPattern != "" {
+		t.Errorf("Expected invalid pattern not to be set, got %q", downloadOpts.GlobPattern)
+	}
+
+	uploadOpts := &UploadOptions{}
+	if err := uploadOpts.SetGlobPattern("!"); err == nil {
+		t.Error("Expected error for lone exclusion pattern on upload options")
+	}
+	if err := uploadOpts.SetGlobPattern("**/*.go,!**/vendor/**"); err != nil {
+		t.Errorf("Unexpected error for valid pattern: %v", err)
+	}
+}
+
 // TestDownloadSingleFileNonRecursive tests downloading a single file without recursive flag
 func TestDownloadSingleFileNonRecursive(t *testing.T) {
 	testContent := "Single file content"
@@ -1228,6 +1364,82 @@ func TestDownloadSingleFileNonRecursive(t *testing.T) {
 	}
 }
 
+// TestDownloadToFile tests that a single asset is written to a destination that is not an
+// existing directory, and that an existing directory keeps the usual layout
+func TestDownloadToFile(t *testing.T) {
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+	server.AddAsset("repo", "/path/tool.bin", nexusapi.Asset{}, []byte("tool v2"))
+	server.AddAsset("repo", "/path/other.bin", nexusapi.Asset{}, []byte("other"))
+	config := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+
+	newOpts := func() *DownloadOptions {
+		opts := &DownloadOptions{Logger: util.NewLogger(io.Discard), QuietMode: true, FileDestination: true}
+		if err := opts.SetChecksumAlgorithm("sha1"); err != nil {
+			t.Fatal(err)
+		}
+		return opts
+	}
+
+	t.Run("new file", func(t *testing.T) {
+		dest := filepath.Join(t.TempDir(), "bin", "tool")
+		if status := downloadFolder("repo/path/tool.bin", dest, config, newOpts()); status != DownloadSuccess {
+			t.Fatalf("Expected success, got %v", status)
+		}
+		if content, err := os.ReadFile(dest); err != nil || string(content) != "tool v2" {
+			t.Errorf("Expected %s to be the downloaded file, got %q, %v", dest, content, err)
+		}
+	})
+
+	t.Run("existing file", func(t *testing.T) {
+		dest := filepath.Join(t.TempDir(), "tool")
+		os.WriteFile(dest, []byte("tool v1"), 0644)
+
+		opts := newOpts()
+		opts.OnConflict = ConflictSkip
+		if status := downloadFolder("repo/path/tool.bin", dest, config, opts); status != DownloadSuccess {
+			t.Fatalf("Expected success, got %v", status)
+		}
+		if content, _ := os.ReadFile(dest); string(content) != "tool v1" {
+			t.Errorf("Expected --on-conflict=skip to keep the local file, got %q", content)
+		}
+
+		if status := downloadFolder("repo/path/tool.bin", dest, config, newOpts()); status != DownloadSuccess {
+			t.Fatalf("Expected success, got %v", status)
+		}
+		if content, _ := os.ReadFile(dest); string(content) != "tool v2" {
+			t.Errorf("Expected the file to be replaced, got %q", content)
+		}
+		downloads := server.GetDownloadCount()
+		if status := downloadFolder("repo/path/tool.bin", dest, config, newOpts()); status != DownloadSuccess {
+			t.Fatalf("Expected success, got %v", status)
+		}
+		if server.GetDownloadCount() != downloads {
+			t.Error("Expected a file matching its checksum to be skipped")
+		}
+	})
+
+	t.Run("existing directory", func(t *testing.T) {
+		dest := t.TempDir()
+		if status := downloadFolder("repo/path/tool.bin", dest, config, newOpts()); status != DownloadSuccess {
+			t.Fatalf("Expected success, got %v", status)
+		}
+		if content, err := os.ReadFile(filepath.Join(dest, "path", "tool.bin")); err != nil || string(content) != "tool v2" {
+			t.Errorf("Expected the usual layout in an existing directory, got %q, %v", content, err)
+		}
+	})
+
+	t.Run("trailing separator", func(t *testing.T) {
+		dest := filepath.Join(t.TempDir(), "out")
+		if status := downloadFolder("repo/path/tool.bin", dest+string(filepath.Separator), config, newOpts()); status != DownloadSuccess {
+			t.Fatalf("Expected success, got %v", status)
+		}
+		if _, err := os.Stat(filepath.Join(dest, "path", "tool.bin")); err != nil {
+			t.Errorf("Expected a destination ending in a separator to be a directory: %v", err)
+		}
+	})
+}
+
 // TestDownloadRecursiveFolder tests downloading a folder with recursive flag
 func TestDownloadRecursiveFolder(t *testing.T) {
 	testContent := "Folder file content"
@@ -1291,3 +1503,580 @@ func TestDownloadRecursiveFolder(t *testing.T) {
 		t.Errorf("Expected file2 content '%s', got '%s'", testContent, string(content2))
 	}
 }
+
+// TestDownloadSpecialCharacterPaths tests downloading assets whose names contain spaces, plus and percent signs
+func TestDownloadSpecialCharacterPaths(t *testing.T) {
+	files := map[string]string{
+		"/test-folder/my file (v2)+final.txt": "spaces and plus",
+		"/test-folder/100%done.bin":           "percent sign",
+	}
+
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+
+	for assetPath, content := range files {
+		server.AddAsset("test-repo", assetPath, nexusapi.Asset{}, []byte(content))
+	}
+
+	config := &config.Config{
+		NexusURL: server.URL,
+		Username: "test",
+		Password: "test",
+	}
+
+	opts := &DownloadOptions{
+		Logger:    util.NewLogger(io.Discard),
+		QuietMode: true,
+		Recursive: true,
+	}
+	if err := opts.SetChecksumAlgorithm("sha1"); err != nil {
+		t.Fatal(err)
+	}
+
+	destDir := t.TempDir()
+
+	status := downloadFolder("test-repo/test-folder", destDir, config, opts)
+	if status != DownloadSuccess {
+		t.Fatalf("Download failed with status %d", status)
+	}
+
+	for assetPath, expected := range files {
+		content, err := os.ReadFile(filepath.Join(destDir, assetPath))
+		if err != nil {
+			t.Fatalf("Failed to read downloaded file %s: %v", assetPath, err)
+		}
+		if string(content) != expected {
+			t.Errorf("Expected content %q for %s, got %q", expected, assetPath, string(content))
+		}
+	}
+}
+
+// TestDownloadWithConcurrencyAndMaxRate tests that downloads honor concurrency and rate limits
+func TestDownloadWithConcurrencyAndMaxRate(t *testing.T) {
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+
+	content := []byte(strings.Repeat("x", 2048))
+	for _, name := range []string{"a.bin", "b.bin", "c.bin"} {
+		server.AddAsset("test-repo", "/rated/"+name, nexusapi.Asset{}, content)
+	}
+
+	config := &config.Config{
+		NexusURL: server.URL,
+		Username: "test",
+		Password: "test",
+	}
+
+	opts := &DownloadOptions{
+		Logger:      util.NewLogger(io.Discard),
+		QuietMode:   true,
+		Recursive:   true,
+		Concurrency: 1,
+		MaxRate:     8 * 1024,
+	}
+	if err := opts.SetChecksumAlgorithm("sha1"); err != nil {
+		t.Fatal(err)
+	}
+
+	destDir := t.TempDir()
+
+	start := time.Now()
+	status := downloadFolder("test-repo/rated", destDir, config, opts)
+	elapsed := time.Since(start)
+	if status != DownloadSuccess {
+		t.Fatalf("Download failed with status %d", status)
+	}
+
+	// 6 KiB at 8 KiB/s must take at least ~750ms
+	if elapsed < 600*time.Millisecond {
+		t.Errorf("Expected rate limit to slow the download, took only %v", elapsed)
+	}
+
+	for _, name := range []string{"a.bin", "b.bin", "c.bin"} {
+		if _, err := os.Stat(filepath.Join(destDir, "rated", name)); err != nil {
+			t.Errorf("Expected %s to be downloaded: %v", name, err)
+		}
+	}
+}
+
+func TestDownloadTransferStats(t *testing.T) {
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+
+	content := []byte(strings.Repeat("x", 4096))
+	for _, name := range []string{"a.bin", "b.bin", "c.bin", "d.bin"} {
+		server.AddAsset("test-repo", "/stats/"+name, nexusapi.Asset{}, content)
+	}
+
+	config := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+	var logBuf strings.Builder
+	opts := &DownloadOptions{
+		Logger:      util.NewLogger(&logBuf),
+		Recursive:   true,
+		Concurrency: 4,
+	}
+	if err := opts.SetChecksumAlgorithm("sha1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if status := downloadFolder("test-repo/stats", t.TempDir(), config, opts); status != DownloadSuccess {
+		t.Fatalf("Download failed with status %d", status)
+	}
+	if !strings.Contains(logBuf.String(), "Transfer stats: content: 16.0 KiB, wire: 16.0 KiB") {
+		t.Errorf("Expected stats for 4 parallel downloads, got: %s", logBuf.String())
+	}
+}
+
+func TestDownloadCompressedTransferStats(t *testing.T) {
+	srcDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(srcDir, "big.txt"), []byte(strings.Repeat("compressible ", 8192)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var archiveBuf bytes.Buffer
+	if err := archive.CreateTarGz(srcDir, &archiveBuf); err != nil {
+		t.Fatal(err)
+	}
+
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+	server.AddAsset("test-repo", "/stats/archive.tar.gz", nexusapi.Asset{}, archiveBuf.Bytes())
+
+	config := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+	var logBuf strings.Builder
+	opts := &DownloadOptions{
+		Logger:            util.NewLogger(&logBuf),
+		Recursive:         true,
+		Compress:          true,
+		CompressionFormat: archive.FormatGzip,
+	}
+
+	status := downloadFolderCompressedWithArchiveName("test-repo", "stats", "archive.tar.gz", t.TempDir(), config, opts)
+	if status != DownloadSuccess {
+		t.Fatalf("Download failed with status %d", status)
+	}
+	// Logical bytes are the extracted content, wire bytes the much smaller archive
+	if !strings.Contains(logBuf.String(), "Transfer stats: content: 104.0 KiB, wire: ") {
+		t.Errorf("Expected extracted content size in stats, got: %s", logBuf.String())
+	}
+	if strings.Contains(logBuf.String(), "wire: 104.0 KiB") {
+		t.Errorf("Expected wire bytes to be the compressed size, got: %s", logBuf.String())
+	}
+}
+
+func TestDownloadCheckOnlineSkipsOfflineGroupMember(t *testing.T) {
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+
+	server.RepositoryStatuses = []nexusapi.RepositoryStatus{
+		{Name: "raw-hosted", Format: "raw", Type: "hosted", Online: true},
+		{Name: "raw-proxy", Format: "raw", Type: "proxy", Online: false},
+		{Name: "raw-group", Format: "raw", Type: "group", Online: true, Group: &nexusapi.RepositoryGroup{MemberNames: []string{"raw-proxy", "raw-hosted"}}},
+	}
+	server.AddAsset("raw-hosted", "/libs/a.txt", nexusapi.Asset{}, []byte("hosted a"))
+	server.AddAsset("raw-hosted", "/libs/b.txt", nexusapi.Asset{}, []byte("hosted b"))
+	server.AddAsset("raw-proxy", "/libs/c.txt", nexusapi.Asset{}, []byte("proxy c"))
+
+	config := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+
+	// The offline member fails the listing with a retryable status; don't wait between retries
+	oldBackoff := nexusapi.ListRetryBackoff
+	nexusapi.ListRetryBackoff = time.Millisecond
+	defer func() { nexusapi.ListRetryBackoff = oldBackoff }()
+
+	t.Run("without check", func(t *testing.T) {
+		opts := &DownloadOptions{Logger: util.NewLogger(io.Discard), QuietMode: true, Recursive: true}
+		if status := downloadFolder("raw-group/libs", t.TempDir(), config, opts); status != DownloadError {
+			t.Errorf("Expected listing through a group with an offline member to fail, got status %d", status)
+		}
+	})
+
+	t.Run("with check", func(t *testing.T) {
+		var logBuf strings.Builder
+		opts := &DownloadOptions{Logger: util.NewLogger(&logBuf), Recursive: true, CheckOnline: true}
+		destDir := t.TempDir()
+		server.StatusRequests = 0
+
+		if status := downloadFolder("raw-group/libs", destDir, config, opts); status != DownloadSuccess {
+			t.Fatalf("Expected download from online members to succeed, got status %d: %s", status, logBuf.String())
+		}
+		for name, want := range map[string]string{"a.txt": "hosted a", "b.txt": "hosted b"} {
+			content, err := os.ReadFile(filepath.Join(destDir, "libs", name))
+			if err != nil || string(content) != want {
+				t.Errorf("Expected %s with %q, got %q (%v)", name, want, content, err)
+			}
+		}
+		if _, err := os.Stat(filepath.Join(destDir, "libs", "c.txt")); err == nil {
+			t.Error("Expected asset of the offline member not to be downloaded")
+		}
+		if !strings.Contains(logBuf.String(), "skipping unavailable members of group 'raw-group': raw-proxy (offline)") {
+			t.Errorf("Expected skipped member to be reported, got: %s", logBuf.String())
+		}
+		// The group and its members are checked with one listing of the settings
+		if server.StatusRequests != 1 {
+			t.Errorf("Expected the repository settings to be listed once, got %d requests", server.StatusRequests)
+		}
+	})
+
+	t.Run("offline repository", func(t *testing.T) {
+		opts := &DownloadOptions{Logger: util.NewLogger(io.Discard), QuietMode: true, Recursive: true, CheckOnline: true}
+		if status := downloadFolder("raw-proxy/libs", t.TempDir(), config, opts); status != DownloadError {
+			t.Errorf("Expected download from an offline repository to fail, got status %d", status)
+		}
+	})
+}
+
+// TestDownloadReportsDirectoryError tests that a directory that cannot be created fails
+// the file with the error of creating it
+func TestDownloadReportsDirectoryError(t *testing.T) {
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+	server.AddAsset("repo", "/builds/lib/a.txt", nexusapi.Asset{}, []byte("a"))
+	config := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+
+	// A file where the download needs a directory
+	destDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(destDir, "builds"), []byte("not a directory"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var logBuf strings.Builder
+	opts := &DownloadOptions{Logger: util.NewLogger(&logBuf), QuietMode: true, Recursive: true}
+	if status := downloadFolder("repo/builds", destDir, config, opts); status == DownloadSuccess {
+		t.Fatal("Expected the download to fail")
+	}
+	if !strings.Contains(logBuf.String(), "Error downloading asset: mkdir "+filepath.Join(destDir, "builds")+":") {
+		t.Errorf("Expected the error of creating the directory, got: %s", logBuf.String())
+	}
+}
+
+func TestDownloadDepth(t *testing.T) {
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+	server.AddAsset("builds", "/app/latest.txt", nexusapi.Asset{}, []byte("1.1"))
+	server.AddAsset("builds", "/app/1.0/app.bin", nexusapi.Asset{}, []byte("app 1.0"))
+	server.AddAsset("builds", "/app/1.0/debug/app.pdb", nexusapi.Asset{}, []byte("symbols"))
+	config := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+
+	files := []string{"latest.txt", "1.0/app.bin", "1.0/debug/app.pdb"}
+	for depth, wantCount := range map[int]int{1: 1, 2: 2, 3: 3} {
+		destDir := t.TempDir()
+		// Without --recursive, a depth turns the source into a folder download
+		opts := &DownloadOptions{Logger: util.NewLogger(io.Discard), QuietMode: true, SkipChecksum: true, Depth: depth}
+		if status := downloadFolder("builds/app", destDir, config, opts); status != DownloadSuccess {
+			t.Fatalf("depth %d: download failed with status %v", depth, status)
+		}
+		for i, rel := range files {
+			_, err := os.Stat(filepath.Join(destDir, "app", filepath.FromSlash(rel)))
+			if want := i < wantCount; (err == nil) != want {
+				t.Errorf("depth %d: expected %s downloaded=%v, got error %v", depth, rel, want, err)
+			}
+		}
+	}
+}
+
+func TestDownloadLimit(t *testing.T) {
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+	for _, name := range []string{"a.bin", "b.txt", "c.bin", "d.bin"} {
+		server.AddAsset("builds", "/app/"+name, nexusapi.Asset{}, []byte(name))
+	}
+	config := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+
+	tests := []struct {
+		limit int
+		glob  string
+		want  []string
+	}{
+		{0, "", []string{"a.bin", "b.txt", "c.bin", "d.bin"}},
+		{1, "", []string{"a.bin"}},
+		{3, "", []string{"a.bin", "b.txt", "c.bin"}},
+		{4, "", []string{"a.bin", "b.txt", "c.bin", "d.bin"}},
+		{5, "", []string{"a.bin", "b.txt", "c.bin", "d.bin"}},
+		// The limit counts files that pass the glob filter
+		{2, "**/*.bin", []string{"a.bin", "c.bin"}},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("limit %d %s", tt.limit, tt.glob), func(t *testing.T) {
+			destDir := t.TempDir()
+			opts := &DownloadOptions{Logger: util.NewLogger(io.Discard), QuietMode: true, SkipChecksum: true, Recursive: true, Limit: tt.limit}
+			if err := opts.SetGlobPattern(tt.glob); err != nil {
+				t.Fatal(err)
+			}
+			if status := downloadFolder("builds/app", destDir, config, opts); status != DownloadSuccess {
+				t.Fatalf("Download failed with status %v", status)
+			}
+			entries, err := os.ReadDir(filepath.Join(destDir, "app"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, entry := range entries {
+				got = append(got, entry.Name())
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Expected %v to be downloaded, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+// TestDownloadFilteredCounts tests that the summary and the JSON plan count the assets
+// excluded by each filter separately from the files skipped as up to date
+func TestDownloadFilteredCounts(t *testing.T) {
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+	for _, name := range []string{"a.bin", "b.bin", "c.bin", "d.txt", "e.txt", "1.0/deep.bin"} {
+		server.AddAsset("builds", "/app/"+name, nexusapi.Asset{}, []byte(name))
+	}
+	config := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+
+	// deep.bin is too deep, the .txt files do not match the glob and c.bin is beyond the limit
+	newOpts := func(logBuf *strings.Builder) *DownloadOptions {
+		opts := &DownloadOptions{Logger: util.NewLogger(logBuf), QuietMode: true, Depth: 1, GlobPattern: "**/*.bin", Limit: 2}
+		if err := opts.SetChecksumAlgorithm("sha1"); err != nil {
+			t.Fatal(err)
+		}
+		return opts
+	}
+	destDir := t.TempDir()
+	os.MkdirAll(filepath.Join(destDir, "app"), 0755)
+	os.WriteFile(filepath.Join(destDir, "app", "a.bin"), []byte("a.bin"), 0644)
+
+	t.Run("summary", func(t *testing.T) {
+		var logBuf strings.Builder
+		if status := downloadFolder("builds/app", destDir, config, newOpts(&logBuf)); status != DownloadSuccess {
+			t.Fatalf("Expected the download to succeed, got %v: %s", status, logBuf.String())
+		}
+		for _, want := range []string{"Files downloaded: 1, skipped: 1,", "Files excluded by filters: 4 (glob: 2, depth: 1, limit: 1)\n"} {
+			if !strings.Contains(logBuf.String(), want) {
+				t.Errorf("Expected %q in the summary, got: %s", want, logBuf.String())
+			}
+		}
+	})
+
+	t.Run("plan", func(t *testing.T) {
+		var logBuf strings.Builder
+		opts := newOpts(&logBuf)
+		opts.DryRun = true
+		opts.Plan = NewPlan("download")
+		if status := downloadFolder("builds/app", t.TempDir(), config, opts); status != DownloadSuccess {
+			t.Fatalf("Expected the dry run to succeed, got %v: %s", status, logBuf.String())
+		}
+		var data strings.Builder
+		if err := opts.Plan.Render(&data, PlanJSON); err != nil {
+			t.Fatal(err)
+		}
+		var decoded struct {
+			Filtered output.FilterCounts `json:"filtered"`
+		}
+		if err := json.Unmarshal([]byte(data.String()), &decoded); err != nil {
+			t.Fatal(err)
+		}
+		if want := (output.FilterCounts{Glob: 2, Depth: 1, Limit: 1}); decoded.Filtered != want {
+			t.Errorf("Expected filtered counts %+v, got %+v", want, decoded.Filtered)
+		}
+	})
+}
+
+func TestDownloadLimitIgnoresDelete(t *testing.T) {
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+	server.AddAsset("builds", "/app/a.bin", nexusapi.Asset{}, []byte("a"))
+	server.AddAsset("builds", "/app/b.bin", nexusapi.Asset{}, []byte("b"))
+	config := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+
+	destDir := t.TempDir()
+	localOnly := filepath.Join(destDir, "app", "b.bin")
+	if err := os.MkdirAll(filepath.Dir(localOnly), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(localOnly, []byte("b"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var logBuf strings.Builder
+	opts := &DownloadOptions{Logger: util.NewLogger(&logBuf), SkipChecksum: true, Recursive: true, Limit: 1, DeleteExtra: true}
+	if status := downloadFolder("builds/app", destDir, config, opts); status != DownloadSuccess {
+		t.Fatalf("Download failed with status %v", status)
+	}
+	if _, err := os.Stat(localOnly); err != nil {
+		t.Errorf("Expected file outside the limit to be kept: %v", err)
+	}
+	if !strings.Contains(logBuf.String(), "--delete is ignored with --limit") {
+		t.Errorf("Expected warning about --delete, got: %s", logBuf.String())
+	}
+}
+
+func TestDownloadMinFiles(t *testing.T) {
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+	for _, name := range []string{"a.bin", "b.txt", "c.bin"} {
+		server.AddAsset("builds", "/app/"+name, nexusapi.Asset{}, []byte(name))
+	}
+	config := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+
+	tests := []struct {
+		name     string
+		minFiles int
+		limit    int
+		glob     string
+		want     DownloadStatus
+	}{
+		{"below threshold", 3, 0, "**/*.bin", DownloadTooFewAssets},
+		{"at threshold", 2, 0, "**/*.bin", DownloadSuccess},
+		{"above threshold", 2, 0, "", DownloadSuccess},
+		// The minimum counts matched assets, before the limit
+		{"with limit", 3, 1, "", DownloadSuccess},
+		// Nothing matched at all keeps its own status
+		{"zero", 2, 0, "**/*.jar", DownloadNoAssetsFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logBuf strings.Builder
+			opts := &DownloadOptions{Logger: util.NewLogger(&logBuf), QuietMode: true, SkipChecksum: true, Recursive: true, MinFiles: tt.minFiles, Limit: tt.limit}
+			if err := opts.SetGlobPattern(tt.glob); err != nil {
+				t.Fatal(err)
+			}
+			destDir := t.TempDir()
+			if status := downloadFolder("builds/app", destDir, config, opts); status != tt.want {
+				t.Fatalf("Expected status %v, got %v: %s", tt.want, status, logBuf.String())
+			}
+			if tt.want == DownloadTooFewAssets {
+				if !strings.Contains(logBuf.String(), "only 2 asset(s) matched") {
+					t.Errorf("Expected the matched count in the error, got: %s", logBuf.String())
+				}
+				if _, err := os.Stat(filepath.Join(destDir, "app")); !os.IsNotExist(err) {
+					t.Errorf("Expected nothing to be downloaded below the threshold")
+				}
+			}
+		})
+	}
+}
+
+// TestDownloadDeduplicatesGroupAssets lists a group whose members both hold the same path
+// and checks that it is downloaded and counted once, from the first member holding it
+func TestDownloadDeduplicatesGroupAssets(t *testing.T) {
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+
+	server.RepositoryStatuses = []nexusapi.RepositoryStatus{
+		{Name: "raw-hosted", Format: "raw", Type: "hosted", Online: true},
+		{Name: "raw-proxy", Format: "raw", Type: "proxy", Online: true},
+		{Name: "raw-group", Format: "raw", Type: "group", Online: true, Group: &nexusapi.RepositoryGroup{MemberNames: []string{"raw-hosted", "raw-proxy"}}},
+	}
+	server.AddAsset("raw-hosted", "/libs/shared.jar", nexusapi.Asset{}, []byte("shared"))
+	server.AddAsset("raw-proxy", "/libs/shared.jar", nexusapi.Asset{}, []byte("shared"))
+	server.AddAsset("raw-proxy", "/libs/other.jar", nexusapi.Asset{}, []byte("other"))
+
+	config := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+	var logBuf strings.Builder
+	opts := &DownloadOptions{Logger: util.NewVerboseLogger(&logBuf), Recursive: true}
+	opts.SetChecksumAlgorithm("sha1")
+	destDir := t.TempDir()
+
+	if status := downloadFolder("raw-group/libs", destDir, config, opts); status != DownloadSuccess {
+		t.Fatalf("Expected download to succeed, got status %d: %s", status, logBuf.String())
+	}
+	if got := server.GetDownloadCount(); got != 2 {
+		t.Errorf("Expected 2 downloads, got %d", got)
+	}
+	if !strings.Contains(logBuf.String(), "Total files: 2,") || !strings.Contains(logBuf.String(), "Files downloaded: 2,") {
+		t.Errorf("Expected 2 files to be counted, got: %s", logBuf.String())
+	}
+	if !strings.Contains(logBuf.String(), "Ignored /libs/shared.jar in group member 'raw-proxy', provided by 'raw-hosted'") {
+		t.Errorf("Expected the shadowed duplicate to be logged, got: %s", logBuf.String())
+	}
+}
+
+func TestDedupeAssetsPrefersChecksum(t *testing.T) {
+	assets := []nexusapi.Asset{
+		{Path: "/a.txt", Repository: "first"},
+		{Path: "b.txt", Repository: "first"},
+		{Path: "a.txt", Repository: "second", Checksum: nexusapi.Checksum{SHA1: "abc"}},
+		{Path: "/b.txt", Repository: "second", Checksum: nexusapi.Checksum{SHA1: "def"}},
+		{Path: "/a.txt", Repository: "third", Checksum: nexusapi.Checksum{SHA1: "ghi"}},
+	}
+	deduped, removed := dedupeAssets(assets)
+	if removed != 3 || len(deduped) != 2 {
+		t.Fatalf("Expected 2 assets and 3 removed, got %d and %d", len(deduped), removed)
+	}
+	if deduped[0].Repository != "second" || deduped[1].Repository != "second" {
+		t.Errorf("Expected the first assets with a checksum to be kept, got %+v", deduped)
+	}
+}
+
+// TestDownloadMissingRepository checks that an empty listing is reported as a missing
+// repository with exit code 1, and as no assets found (66) only if the repository exists
+func TestDownloadMissingRepository(t *testing.T) {
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+
+	server.AddAsset("releases", "/app/v1/app.bin", nexusapi.Asset{}, []byte("app"))
+	server.AddAsset("releases", "/empty/.keep", nexusapi.Asset{}, []byte{})
+	server.SetRepositoryNotFound("relases")
+	config := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+
+	tests := []struct {
+		name     string
+		src      string
+		compress bool
+		glob     string
+		status   DownloadStatus
+		message  string
+	}{
+		{name: "missing repository", src: "relases/app", status: DownloadError, message: "repository 'relases' does not exist on " + server.URL},
+		{name: "missing repository compressed", src: "relases/app/app.tar.gz", compress: true, status: DownloadError, message: "repository 'relases' does not exist"},
+		{name: "wrong path", src: "releases/ap", status: DownloadNoAssetsFound, message: "No assets found in folder 'ap' in repository 'releases'"},
+		{name: "empty folder", src: "releases/empty", glob: "**/*.bin", status: DownloadNoAssetsFound, message: "No assets found in folder 'empty'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logBuf strings.Builder
+			opts := &DownloadOptions{Logger: util.NewLogger(&logBuf), QuietMode: true, Recursive: true, Compress: tt.compress, GlobPattern: tt.glob}
+			if status := downloadFolder(tt.src, t.TempDir(), config, opts); status != tt.status {
+				t.Errorf("Expected status %d, got %d", tt.status, status)
+			}
+			if !strings.Contains(logBuf.String(), tt.message) {
+				t.Errorf("Expected %q in output, got: %s", tt.message, logBuf.String())
+			}
+		})
+	}
+}
+
+func TestDownloadMaxTotalSize(t *testing.T) {
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+	server.AddAsset("builds", "/app/a.bin", nexusapi.Asset{}, []byte(strings.Repeat("a", 600)))
+	server.AddAsset("builds", "/app/b.bin", nexusapi.Asset{}, []byte(strings.Repeat("b", 600)))
+	config := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+
+	destDir := t.TempDir()
+	extra := filepath.Join(destDir, "app", "extra.txt")
+	if err := os.MkdirAll(filepath.Dir(extra), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(extra, []byte("extra"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var logBuf strings.Builder
+	opts := &DownloadOptions{Logger: util.NewLogger(&logBuf), QuietMode: true, SkipChecksum: true, Recursive: true, DeleteExtra: true, MaxTotalSize: 1000}
+	if status := downloadFolder("builds/app", destDir, config, opts); status != DownloadError {
+		t.Fatalf("Expected the download to fail over budget, got %v: %s", status, logBuf.String())
+	}
+	if !strings.Contains(logBuf.String(), "the 2 matched asset(s) total 1.2 KiB, more than the maximum of 1000 B") {
+		t.Errorf("Expected the total and the maximum in the error, got: %s", logBuf.String())
+	}
+	if _, err := os.Stat(filepath.Join(destDir, "app", "a.bin")); !os.IsNotExist(err) {
+		t.Error("Expected nothing to be downloaded over budget")
+	}
+	if _, err := os.Stat(extra); err != nil {
+		t.Error("Expected --delete not to remove anything over budget")
+	}
+
+	// The budget counts what is left after --limit
+	opts = &DownloadOptions{Logger: util.NewLogger(&logBuf), QuietMode: true, SkipChecksum: true, Recursive: true, Limit: 1, MaxTotalSize: 1000}
+	if status := downloadFolder("builds/app", destDir, config, opts); status != DownloadSuccess {
+		t.Fatalf("Expected the limited download to fit, got %v: %s", status, logBuf.String())
+	}
+}
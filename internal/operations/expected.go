@@ -0,0 +1,195 @@
+package operations
+
+import (
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/tympanix/nexus-cli/internal/checksum"
+)
+
+// ExpectedFiles holds the digests files must have, keyed by local path, e.g. from a lock
+// file. Set DownloadOptions.Expected to use them: an existing file that matches its digest
+// is skipped whatever Nexus reports, and downloaded data that does not match is never moved
+// into place, so a changed asset in Nexus cannot replace a good local copy.
+type ExpectedFiles struct {
+	mu         sync.Mutex
+	files      map[string]FileDigest
+	mismatched []Mismatch
+	missing    []string
+}
+
+// Mismatch is a downloaded file that did not match its expected digest
+type Mismatch struct {
+	LocalPath string
+	Err       error // What did not match
+}
+
+// NewExpectedFiles returns an empty set of expected digests
+func NewExpectedFiles() *ExpectedFiles {
+	return &ExpectedFiles{files: make(map[string]FileDigest)}
+}
+
+// Add sets the digest expected for localPath. A negative size is not checked.
+func (e *ExpectedFiles) Add(localPath string, digest FileDigest) error {
+	for algorithm := range digest.Checksums {
+		if _, err := checksum.NewHasher(algorithm); err != nil {
+			return fmt.Errorf("%s: %w", localPath, err)
+		}
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	checksums := make(map[string]string, len(digest.Checksums))
+	for algorithm, value := range digest.Checksums {
+		checksums[strings.ToLower(algorithm)] = value
+	}
+	e.files[filepath.Clean(localPath)] = FileDigest{Size: digest.Size, Checksums: checksums}
+	return nil
+}
+
+// Mismatched returns the files whose downloaded data did not match the expected digest,
+// sorted by local path
+func (e *ExpectedFiles) Mismatched() []Mismatch {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	mismatched := append([]Mismatch(nil), e.mismatched...)
+	sort.Slice(mismatched, func(i, j int) bool {
+		return mismatched[i].LocalPath < mismatched[j].LocalPath
+	})
+	return mismatched
+}
+
+// Missing returns the local paths of the expected files the listing of the download did
+// not contain, e.g. locked files deleted from Nexus, sorted
+func (e *ExpectedFiles) Missing() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]string(nil), e.missing...)
+}
+
+// recordMissing records the expected files that are not among listed, the local paths of
+// the listed assets, see Missing, and returns them
+func (e *ExpectedFiles) recordMissing(listed map[string]bool) []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.missing = nil
+	for localPath := range e.files {
+		if !listed[localPath] {
+			e.missing = append(e.missing, localPath)
+		}
+	}
+	sort.Strings(e.missing)
+	return append([]string(nil), e.missing...)
+}
+
+// expect returns the check of localPath against its expected digest, or nil if there is none
+func (e *ExpectedFiles) expect(localPath string) *expectedFile {
+	if e == nil {
+		return nil
+	}
+	e.mu.Lock()
+	digest, ok := e.files[filepath.Clean(localPath)]
+	e.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	return &expectedFile{files: e, localPath: localPath, digest: digest}
+}
+
+// expectedFile is the check of one file against its expected digest. The data is hashed by
+// the hashers of FileDigests, see hashers, so each algorithm is computed once.
+type expectedFile struct {
+	files     *ExpectedFiles
+	localPath string
+	digest    FileDigest
+}
+
+// hashers adds a hasher to hashers for each algorithm of the expected digest it lacks, and
+// returns them
+func (x *expectedFile) hashers(hashers map[string]hash.Hash) map[string]hash.Hash {
+	if x == nil {
+		return hashers
+	}
+	if hashers == nil {
+		hashers = make(map[string]hash.Hash, len(x.digest.Checksums))
+	}
+	for algorithm := range x.digest.Checksums {
+		if _, ok := hashers[algorithm]; !ok {
+			hashers[algorithm], _ = checksum.NewHasher(algorithm)
+		}
+	}
+	return hashers
+}
+
+// verify checks the size and the data written to hashers against the expected digest. A
+// mismatch is recorded, see ExpectedFiles.Mismatched.
+func (x *expectedFile) verify(size int64, hashers map[string]hash.Hash) error {
+	if x == nil {
+		return nil
+	}
+	err := x.compare(size, func(algorithm string) string {
+		return fmt.Sprintf("%x", hashers[algorithm].Sum(nil))
+	})
+	if err != nil {
+		x.files.mu.Lock()
+		x.files.mismatched = append(x.files.mismatched, Mismatch{LocalPath: x.localPath, Err: err})
+		x.files.mu.Unlock()
+		return fmt.Errorf("downloaded data for %s does not match its expected digest: %w", x.localPath, err)
+	}
+	return nil
+}
+
+// matchesFile reports whether the file at localPath has the expected digest. Hashed
+// bytes are written to progress. A missing file does not match. The digest of a matching
+// file is recorded in opts.Digests, so it is not read again to verify it.
+func (x *expectedFile) matchesFile(progress io.Writer, opts *DownloadOptions) bool {
+	f, err := os.Open(x.localPath)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || (x.digest.Size >= 0 && info.Size() != x.digest.Size) {
+		return false
+	}
+
+	hashers := x.hashers(opts.Digests.hashers(nil, opts))
+	writers := []io.Writer{progress}
+	for _, h := range hashers {
+		writers = append(writers, h)
+	}
+	if _, err := io.Copy(io.MultiWriter(writers...), f); err != nil {
+		return false
+	}
+	if x.compare(info.Size(), func(algorithm string) string {
+		return fmt.Sprintf("%x", hashers[algorithm].Sum(nil))
+	}) != nil {
+		return false
+	}
+	opts.Digests.record(x.localPath, info.Size(), hashers)
+	return true
+}
+
+// compare checks a size and the checksums returned by sum against the expected digest
+func (x *expectedFile) compare(size int64, sum func(algorithm string) string) error {
+	if x.digest.Size >= 0 && size != x.digest.Size {
+		return fmt.Errorf("size mismatch: expected %d bytes, got %d", x.digest.Size, size)
+	}
+	algorithms := make([]string, 0, len(x.digest.Checksums))
+	for algorithm := range x.digest.Checksums {
+		algorithms = append(algorithms, algorithm)
+	}
+	sort.Strings(algorithms)
+	for _, algorithm := range algorithms {
+		expected, actual := x.digest.Checksums[algorithm], sum(algorithm)
+		if match, err := checksum.Matches(expected, actual, algorithm); err != nil || !match {
+			return fmt.Errorf("%s checksum mismatch: expected %s, got %s", strings.ToLower(algorithm), expected, actual)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package operations
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tympanix/nexus-cli/internal/config"
+	"github.com/tympanix/nexus-cli/internal/nexusapi"
+	"github.com/tympanix/nexus-cli/internal/util"
+)
+
+func sha256Digest(content string) FileDigest {
+	return FileDigest{Size: int64(len(content)), Checksums: map[string]string{"sha256": fmt.Sprintf("%x", sha256.Sum256([]byte(content)))}}
+}
+
+// TestDownloadExpectedFiles tests that files are compared against their expected digests
+// instead of the checksums reported by Nexus
+func TestDownloadExpectedFiles(t *testing.T) {
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+	server.AddAsset("repo", "/docs/kept.txt", nexusapi.Asset{}, []byte("changed in nexus"))
+	server.AddAsset("repo", "/docs/drifted.txt", nexusapi.Asset{}, []byte("changed in nexus"))
+	server.AddAsset("repo", "/docs/missing.txt", nexusapi.Asset{}, []byte("as expected"))
+	config := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+
+	destDir := t.TempDir()
+	docsDir := filepath.Join(destDir, "docs")
+	if err := os.MkdirAll(docsDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(docsDir, "kept.txt"), []byte("good local copy"), 0644)
+	os.WriteFile(filepath.Join(docsDir, "drifted.txt"), []byte("edited locally!!"), 0644)
+
+	expected := NewExpectedFiles()
+	expected.Add(filepath.Join(docsDir, "kept.txt"), sha256Digest("good local copy"))
+	// Same size as the asset in Nexus, so only the checksum tells them apart
+	expected.Add(filepath.Join(docsDir, "drifted.txt"), sha256Digest("original content"))
+	expected.Add(filepath.Join(docsDir, "missing.txt"), FileDigest{Size: -1, Checksums: sha256Digest("as expected").Checksums})
+
+	decisions := NewFileDecisions()
+	digests, _ := NewFileDigests("sha256")
+	opts := &DownloadOptions{Logger: util.NewLogger(io.Discard), QuietMode: true, Recursive: true, Expected: expected, Decisions: decisions, Digests: digests}
+	if err := opts.SetChecksumAlgorithm("sha256"); err != nil {
+		t.Fatal(err)
+	}
+	if status := downloadFolder("repo/docs", destDir, config, opts); status != DownloadError {
+		t.Fatalf("Expected the drifted file to fail the download, got %v", status)
+	}
+
+	if decision, _ := decisions.Get(filepath.Join(docsDir, "kept.txt")); decision != DecisionSkipped {
+		t.Errorf("Expected the file matching its digest to be skipped, got %q", decision)
+	}
+	// The digest computed by the comparison is kept, so the file is not hashed again to verify it
+	if digest, ok := digests.Get(filepath.Join(docsDir, "kept.txt")); !ok || digest.Checksums["sha256"] != sha256Digest("good local copy").Checksums["sha256"] {
+		t.Errorf("Expected the digest of the skipped file to be recorded, got %+v", digest)
+	}
+	for name, want := range map[string]string{"kept.txt": "good local copy", "drifted.txt": "edited locally!!", "missing.txt": "as expected"} {
+		if content, _ := os.ReadFile(filepath.Join(docsDir, name)); string(content) != want {
+			t.Errorf("Expected %s to contain %q, got %q", name, want, content)
+		}
+	}
+	entries, _ := os.ReadDir(docsDir)
+	if len(entries) != 3 {
+		t.Errorf("Expected no partial downloads to be left behind, got %v", entries)
+	}
+
+	mismatched := expected.Mismatched()
+	if len(mismatched) != 1 || mismatched[0].LocalPath != filepath.Join(docsDir, "drifted.txt") {
+		t.Fatalf("Expected only the drifted file to be reported, got %v", mismatched)
+	}
+	if !strings.Contains(mismatched[0].Err.Error(), "sha256 checksum mismatch") {
+		t.Errorf("Expected a checksum mismatch, got %v", mismatched[0].Err)
+	}
+}
+
+func TestExpectedFilesUnsupportedAlgorithm(t *testing.T) {
+	if err := NewExpectedFiles().Add("file.txt", FileDigest{Size: -1, Checksums: map[string]string{"crc32": "00"}}); err == nil {
+		t.Error("Expected an error for an unsupported algorithm")
+	}
+}
@@ -0,0 +1,99 @@
+package operations
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// DeniedExtensionPolicy controls what happens when an upload includes a file whose
+// extension is not allowed by --allow-ext or is blocked by --deny-ext
+type DeniedExtensionPolicy string
+
+const (
+	DeniedExtensionAbort DeniedExtensionPolicy = "abort" // Fail before uploading anything (default)
+	DeniedExtensionSkip  DeniedExtensionPolicy = "skip"  // Leave the file out and print a warning
+)
+
+// ParseDeniedExtensionPolicy parses a string into a DeniedExtensionPolicy
+func ParseDeniedExtensionPolicy(s string) (DeniedExtensionPolicy, error) {
+	switch strings.ToLower(s) {
+	case "", "abort":
+		return DeniedExtensionAbort, nil
+	case "skip":
+		return DeniedExtensionSkip, nil
+	default:
+		return "", fmt.Errorf("unsupported denied extension policy '%s': must be one of: abort, skip", s)
+	}
+}
+
+// normalizeExtension lowercases an extension and ensures it starts with a dot,
+// so "PEM", "pem" and ".pem" are equivalent
+func normalizeExtension(ext string) string {
+	ext = strings.ToLower(strings.TrimSpace(ext))
+	if ext != "" && !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	return ext
+}
+
+// hasExtension reports whether the file name ends with one of the given extensions.
+// Matching is on the whole suffix so multi-part extensions like ".tar.gz" work, and a
+// dotfile such as ".env" matches the extension ".env".
+func hasExtension(name string, extensions []string) bool {
+	name = strings.ToLower(name)
+	for _, ext := range extensions {
+		ext = normalizeExtension(ext)
+		if ext != "" && strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// isExtensionDenied reports whether a file is rejected by the allow and deny lists.
+// A non-empty allow list rejects everything not on it; the deny list always wins.
+func isExtensionDenied(name string, allow, deny []string) bool {
+	if len(allow) > 0 && !hasExtension(name, allow) {
+		return true
+	}
+	return hasExtension(name, deny)
+}
+
+// filterDeniedExtensions applies --allow-ext and --deny-ext to the collected files.
+// With the abort policy it returns an error listing every offending path; with the
+// skip policy it warns about each offending file and returns the remaining files.
+func filterDeniedExtensions(src string, filePaths []string, opts *UploadOptions) ([]string, error) {
+	if len(opts.AllowExtensions) == 0 && len(opts.DenyExtensions) == 0 {
+		return filePaths, nil
+	}
+
+	var allowed, denied []string
+	for _, filePath := range filePaths {
+		if isExtensionDenied(filepath.Base(filePath), opts.AllowExtensions, opts.DenyExtensions) {
+			relPath, _ := filepath.Rel(src, filePath)
+			denied = append(denied, filepath.ToSlash(relPath))
+			continue
+		}
+		allowed = append(allowed, filePath)
+	}
+	if len(denied) == 0 {
+		return filePaths, nil
+	}
+
+	if opts.OnDeniedExt == DeniedExtensionSkip {
+		if !opts.QuietMode {
+			for _, relPath := range denied {
+				opts.Logger.Printf("Warning: skipping file with denied extension %s\n", relPath)
+			}
+		}
+		return allowed, nil
+	}
+
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "%d file(s) with denied extensions in %s:", len(denied), src)
+	for _, relPath := range denied {
+		fmt.Fprintf(&msg, "\n  %s", relPath)
+	}
+	return nil, fmt.Errorf("%s", msg.String())
+}
@@ -0,0 +1,60 @@
+package operations
+
+import "testing"
+
+func TestParseDeniedExtensionPolicy(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    DeniedExtensionPolicy
+		wantErr bool
+	}{
+		{input: "", want: DeniedExtensionAbort},
+		{input: "abort", want: DeniedExtensionAbort},
+		{input: "SKIP", want: DeniedExtensionSkip},
+		{input: "ignore", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseDeniedExtensionPolicy(tt.input)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseDeniedExtensionPolicy(%q) expected error", tt.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseDeniedExtensionPolicy(%q) unexpected error: %v", tt.input, err)
+		}
+		if got != tt.want {
+			t.Errorf("ParseDeniedExtensionPolicy(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestIsExtensionDenied(t *testing.T) {
+	tests := []struct {
+		name  string
+		allow []string
+		deny  []string
+		want  bool
+	}{
+		{name: "app.jar", want: false},
+		{name: "app.jar", allow: []string{".jar"}, want: false},
+		{name: "app.JAR", allow: []string{"jar"}, want: false},
+		{name: "notes.txt", allow: []string{".jar"}, want: true},
+		{name: "server.pem", deny: []string{".pem", ".key"}, want: true},
+		{name: "server.KEY", deny: []string{".pem", ".key"}, want: true},
+		{name: ".env", deny: []string{".env"}, want: true},
+		{name: "prod.env", deny: []string{"env"}, want: true},
+		{name: "environment.txt", deny: []string{".env"}, want: false},
+		{name: "dist.tar.gz", allow: []string{".tar.gz"}, want: false},
+		{name: "dist.gz", allow: []string{".tar.gz"}, want: true},
+		{name: "secret.pem", allow: []string{".pem"}, deny: []string{".pem"}, want: true},
+	}
+
+	for _, tt := range tests {
+		if got := isExtensionDenied(tt.name, tt.allow, tt.deny); got != tt.want {
+			t.Errorf("isExtensionDenied(%q, %v, %v) = %v, want %v", tt.name, tt.allow, tt.deny, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package operations
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// FlattenConflictMode controls what happens when --flatten maps several files to the same path
+type FlattenConflictMode string
+
+const (
+	FlattenConflictError  FlattenConflictMode = "error"  // Fail before transferring anything (default)
+	FlattenConflictRename FlattenConflictMode = "rename" // Disambiguate with a numeric suffix
+	FlattenConflictSkip   FlattenConflictMode = "skip"   // Keep the first file and leave out the others
+)
+
+// ParseFlattenConflictMode parses a string into a FlattenConflictMode
+func ParseFlattenConflictMode(s string) (FlattenConflictMode, error) {
+	switch strings.ToLower(s) {
+	case "", "error":
+		return FlattenConflictError, nil
+	case "rename", "suffix":
+		return FlattenConflictRename, nil
+	case "skip":
+		return FlattenConflictSkip, nil
+	default:
+		return "", fmt.Errorf("unsupported flatten conflict mode '%s': must be one of: error, rename, skip", s)
+	}
+}
+
+// resolveFlattenCollisions checks a mapping of source paths to flattened target paths
+// for collisions. Target paths are slash separated. In error mode a collision returns an
+// error listing the conflicting sources. In rename mode the first source (in sorted order)
+// keeps its target and the others get a numeric suffix, e.g. conf.json -> conf-1.json.
+// In skip mode the first source keeps its target and the others are left out of the
+// returned map. Otherwise the returned map contains the final target for every source.
+func resolveFlattenCollisions(targets map[string]string, mode FlattenConflictMode) (map[string]string, error) {
+	sources := make([]string, 0, len(targets))
+	for source := range targets {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+
+	byTarget := make(map[string][]string)
+	for _, source := range sources {
+		target := targets[source]
+		byTarget[target] = append(byTarget[target], source)
+	}
+
+	var collisions []string
+	for target, group := range byTarget {
+		if len(group) > 1 {
+			collisions = append(collisions, target)
+		}
+	}
+	if len(collisions) == 0 {
+		return targets, nil
+	}
+	sort.Strings(collisions)
+
+	if mode != FlattenConflictRename && mode != FlattenConflictSkip {
+		var msg strings.Builder
+		msg.WriteString("--flatten would map multiple files to the same path:")
+		for _, target := range collisions {
+			fmt.Fprintf(&msg, "\n  %s <- %s", target, strings.Join(byTarget[target], ", "))
+		}
+		msg.WriteString("\nuse --flatten-on-conflict=rename to keep all files, or skip to keep only the first")
+		return nil, fmt.Errorf("%s", msg.String())
+	}
+
+	resolved := make(map[string]string, len(targets))
+	taken := make(map[string]bool, len(targets))
+	for source, target := range targets {
+		resolved[source] = target
+		taken[target] = true
+	}
+	for _, target := range collisions {
+		for _, source := range byTarget[target][1:] {
+			if mode == FlattenConflictSkip {
+				delete(resolved, source)
+				continue
+			}
+			resolved[source] = nextFreeName(target, taken)
+			taken[resolved[source]] = true
+		}
+	}
+	return resolved, nil
+}
+
+// nextFreeName returns the first "<name>-<n><ext>" variant of target not present in taken
+func nextFreeName(target string, taken map[string]bool) string {
+	dir, file := path.Split(target)
+	ext := path.Ext(file)
+	stem := strings.TrimSuffix(file, ext)
+	for n := 1; ; n++ {
+		candidate := fmt.Sprintf("%s%s-%d%s", dir, stem, n, ext)
+		if !taken[candidate] {
+			return candidate
+		}
+	}
+}
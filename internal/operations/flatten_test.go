@@ -0,0 +1,130 @@
+package operations
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseFlattenConflictMode(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    FlattenConflictMode
+		wantErr bool
+	}{
+		{input: "", want: FlattenConflictError},
+		{input: "error", want: FlattenConflictError},
+		{input: "RENAME", want: FlattenConflictRename},
+		{input: "suffix", want: FlattenConflictRename},
+		{input: "skip", want: FlattenConflictSkip},
+		{input: "overwrite", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseFlattenConflictMode(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected error for %q, got nil", tt.input)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseFlattenConflictMode(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveFlattenCollisionsNoConflict(t *testing.T) {
+	targets := map[string]string{
+		"a/conf.json": "conf.json",
+		"b/app.yml":   "app.yml",
+	}
+
+	resolved, err := resolveFlattenCollisions(targets, FlattenConflictError)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for source, target := range targets {
+		if resolved[source] != target {
+			t.Errorf("Expected %s -> %s, got %s", source, target, resolved[source])
+		}
+	}
+}
+
+func TestResolveFlattenCollisionsError(t *testing.T) {
+	targets := map[string]string{
+		"b/conf.json": "conf.json",
+		"a/conf.json": "conf.json",
+		"c/other.txt": "other.txt",
+	}
+
+	_, err := resolveFlattenCollisions(targets, FlattenConflictError)
+	if err == nil {
+		t.Fatal("Expected collision error, got nil")
+	}
+	if !strings.Contains(err.Error(), "conf.json <- a/conf.json, b/conf.json") {
+		t.Errorf("Expected error to list conflicting paths, got: %v", err)
+	}
+	if strings.Contains(err.Error(), "other.txt") {
+		t.Errorf("Expected error to list only conflicting paths, got: %v", err)
+	}
+}
+
+func TestResolveFlattenCollisionsRename(t *testing.T) {
+	targets := map[string]string{
+		"c/conf.json":   "conf.json",
+		"a/conf.json":   "conf.json",
+		"b/conf.json":   "conf.json",
+		"d/conf-1.json": "conf-1.json",
+		"x/README":      "README",
+		"y/README":      "README",
+	}
+
+	resolved, err := resolveFlattenCollisions(targets, FlattenConflictRename)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := map[string]string{
+		"a/conf.json":   "conf.json",
+		"b/conf.json":   "conf-2.json",
+		"c/conf.json":   "conf-3.json",
+		"d/conf-1.json": "conf-1.json",
+		"x/README":      "README",
+		"y/README":      "README-1",
+	}
+	for source, want := range expected {
+		if resolved[source] != want {
+			t.Errorf("Expected %s -> %s, got %s", source, want, resolved[source])
+		}
+	}
+}
+
+func TestResolveFlattenCollisionsSkip(t *testing.T) {
+	targets := map[string]string{
+		"b/conf.json": "conf.json",
+		"a/conf.json": "conf.json",
+		"c/other.txt": "other.txt",
+	}
+
+	resolved, err := resolveFlattenCollisions(targets, FlattenConflictSkip)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := map[string]string{
+		"a/conf.json": "conf.json",
+		"c/other.txt": "other.txt",
+	}
+	if len(resolved) != len(expected) {
+		t.Errorf("Expected %v, got %v", expected, resolved)
+	}
+	for source, want := range expected {
+		if resolved[source] != want {
+			t.Errorf("Expected %s -> %s, got %s", source, want, resolved[source])
+		}
+	}
+}
@@ -1,15 +1,24 @@
 package operations
 
 import (
+	"os"
 	"path"
+	"path/filepath"
 	"strings"
 
 	"github.com/tympanix/nexus-cli/internal/checksum"
+	"github.com/tympanix/nexus-cli/internal/nexusapi"
 	"github.com/tympanix/nexus-cli/internal/util"
 )
 
-func processKeyTemplateWrapper(input string, keyFromFile string) (string, error) {
-	return util.ProcessKeyTemplate(input, keyFromFile, checksum.ComputeChecksum)
+func processKeyTemplateWrapper(input string, keyFromFile string, globPattern string) (string, error) {
+	return util.ProcessKeyTemplate(input, keyFromFile, func(path, algorithm string) (string, error) {
+		// A directory is hashed as a tree, with the same --glob filter as the transfer
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			return checksum.ComputeDirectoryKey(path, algorithm, util.ParseGlobPattern(globPattern).Match)
+		}
+		return checksum.ComputeChecksum(path, algorithm)
+	})
 }
 
 // getRelativePath returns the relative path from basePath to assetPath using path.Clean for normalization.
@@ -35,3 +44,73 @@ func getRelativePath(assetPath, basePath string) string {
 
 	return cleanAsset
 }
+
+// withinDepth reports whether assetPath is at most depth path segments below basePath.
+// A file directly in basePath has depth 1. A depth of 0 allows any depth.
+func withinDepth(assetPath, basePath string, depth int) bool {
+	return depth <= 0 || strings.Count(getRelativePath(assetPath, basePath), "/") < depth
+}
+
+// dedupeAssets removes assets listed more than once under the same path, which Nexus
+// search can return for group repositories or while its index catches up. The first
+// occurrence is kept unless only a later one has a checksum. The order of the remaining
+// assets is preserved and the number of assets removed is returned.
+func dedupeAssets(assets []nexusapi.Asset) ([]nexusapi.Asset, int) {
+	index := make(map[string]int, len(assets))
+	deduped := assets[:0:0]
+	for _, asset := range assets {
+		key := strings.TrimLeft(asset.Path, "/")
+		if i, seen := index[key]; seen {
+			if deduped[i].Checksum == (nexusapi.Checksum{}) && asset.Checksum != (nexusapi.Checksum{}) {
+				deduped[i] = asset
+			}
+			continue
+		}
+		index[key] = len(deduped)
+		deduped = append(deduped, asset)
+	}
+	return deduped, len(assets) - len(deduped)
+}
+
+// logGlobDecisions logs for every path whether the glob pattern includes or excludes it
+// and which sub-pattern decided. Paths are relative to the directory being filtered.
+func logGlobDecisions(paths []string, globPattern string, logger util.Logger) {
+	if globPattern == "" {
+		return
+	}
+	gp := util.ParseGlobPattern(globPattern)
+	for _, p := range paths {
+		p = filepath.ToSlash(p)
+		matched, reason, err := gp.Explain(p)
+		if err != nil {
+			logger.Printf("[glob] error  %s: %v\n", p, err)
+			continue
+		}
+		if matched {
+			logger.Printf("[glob] include %s (%s)\n", p, reason)
+		} else {
+			logger.Printf("[glob] exclude %s (%s)\n", p, reason)
+		}
+	}
+}
+
+// logUploadGlobDecisions logs glob decisions for every file below src when --glob-debug is enabled
+func logUploadGlobDecisions(src string, opts *UploadOptions) {
+	if !opts.GlobDebug || opts.GlobPattern == "" {
+		return
+	}
+	filePaths, err := collectFiles(src)
+	if err != nil {
+		opts.Logger.Printf("[glob] could not list files in %s: %v\n", src, err)
+		return
+	}
+	relPaths := make([]string, 0, len(filePaths))
+	for _, filePath := range filePaths {
+		relPath, err := filepath.Rel(src, filePath)
+		if err != nil {
+			relPath = filePath
+		}
+		relPaths = append(relPaths, relPath)
+	}
+	logGlobDecisions(relPaths, opts.GlobPattern, opts.Logger)
+}
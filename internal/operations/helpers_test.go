@@ -1,6 +1,13 @@
 package operations
 
-import "testing"
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tympanix/nexus-cli/internal/nexusapi"
+)
 
 func TestGetRelativePath(t *testing.T) {
 	tests := []struct {
@@ -68,3 +75,56 @@ func TestGetRelativePath(t *testing.T) {
 		})
 	}
 }
+
+func TestWithinDepth(t *testing.T) {
+	assets := []nexusapi.Asset{
+		{Path: "/builds/app.bin"},
+		{Path: "/builds/1.0/app.bin"},
+		{Path: "/builds/1.0/debug/app.pdb"},
+	}
+	for depth, want := range map[int]int{0: 3, 1: 1, 2: 2, 3: 3} {
+		kept := 0
+		for _, asset := range assets {
+			if withinDepth(asset.Path, "builds", depth) {
+				kept++
+			}
+		}
+		if kept != want {
+			t.Errorf("withinDepth(depth=%d) kept %d assets, want %d", depth, kept, want)
+		}
+	}
+}
+
+func TestProcessKeyTemplateDirectory(t *testing.T) {
+	root := t.TempDir()
+	for name, content := range map[string]string{"main.go": "package main", "main_test.go": "package main", "README.md": "docs"} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	key := func(glob string) string {
+		t.Helper()
+		dest, err := processKeyTemplateWrapper("repo/cache-{key}", root, glob)
+		if err != nil {
+			t.Fatalf("processKeyTemplateWrapper failed: %v", err)
+		}
+		return strings.TrimPrefix(dest, "repo/cache-")
+	}
+
+	all, sources := key(""), key("**/*.go,!**/*_test.go")
+	if len(all) != 64 || all == sources {
+		t.Fatalf("Expected distinct sha256 keys with and without --glob, got %s and %s", all, sources)
+	}
+
+	// Files excluded by --glob do not affect the key
+	if err := os.WriteFile(filepath.Join(root, "main_test.go"), []byte("package main // more tests"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if key("**/*.go,!**/*_test.go") != sources {
+		t.Error("Expected a change to an excluded file to keep the key")
+	}
+	if key("") == all {
+		t.Error("Expected a change to an included file to change the key")
+	}
+}
@@ -0,0 +1,49 @@
+package operations
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/tympanix/nexus-cli/internal/config"
+	"github.com/tympanix/nexus-cli/internal/util"
+)
+
+// resolveLatest replaces the {latest} placeholders of the source path src, e.g.
+// test-repo/builds/{latest}/app.zip, by the folder or file names they select in Nexus.
+// Placeholders without a mode select by opts.Select, or by semver if it is not set.
+func resolveLatest(src string, config *config.Config, opts *DownloadOptions) (string, error) {
+	repository, srcPath, ok := strings.Cut(src, "/")
+	if !ok || repository == "" {
+		return "", fmt.Errorf("invalid source path '%s': expected repository/path", src)
+	}
+	mode := opts.Select
+	if mode == "" {
+		mode = util.SelectSemver
+	}
+
+	client := NewClient(config)
+	resolved, selections, err := util.ResolveLatest(srcPath, mode, func(parent string) ([]util.LatestAsset, error) {
+		assets, err := client.ListAssets(repository, parent, true)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list assets of repository '%s' for {latest}: %w", repository, err)
+		}
+		latestAssets := make([]util.LatestAsset, len(assets))
+		for i, asset := range assets {
+			latestAssets[i] = util.LatestAsset{Path: asset.Path, Modified: lastModified(asset)}
+		}
+		return latestAssets, nil
+	})
+	if errors.Is(err, util.ErrNoSemver) {
+		return "", fmt.Errorf("%w; use --select lexical or --select mtime, or {latest:lexical}", err)
+	}
+	if err != nil {
+		return "", err
+	}
+	for _, selection := range selections {
+		if len(selection.Ignored) > 0 {
+			opts.Logger.VerbosePrintf("Ignored %d name(s) under '%s/%s' that are not semantic versions: %s\n", len(selection.Ignored), repository, selection.Parent, strings.Join(selection.Ignored, ", "))
+		}
+	}
+	return repository + "/" + resolved, nil
+}
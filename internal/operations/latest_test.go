@@ -0,0 +1,61 @@
+package operations
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tympanix/nexus-cli/internal/config"
+	"github.com/tympanix/nexus-cli/internal/nexusapi"
+	"github.com/tympanix/nexus-cli/internal/util"
+)
+
+func TestDownloadLatest(t *testing.T) {
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+	for assetPath, modified := range map[string]string{
+		"/app/1.9.0/app.zip":        "2024-03-01T00:00:00Z",
+		"/app/1.10.0/app.zip":       "2024-02-01T00:00:00Z",
+		"/app/1.10.0-rc.2/app.zip":  "2024-01-15T00:00:00Z",
+		"/app/nightly/app.zip":      "2024-01-01T00:00:00Z",
+		"/tools/tool-v2.1.0.tar.gz": "2024-01-01T00:00:00Z",
+		"/tools/tool-v2.0.9.tar.gz": "2024-01-01T00:00:00Z",
+	} {
+		server.AddAsset("test-repo", assetPath, nexusapi.Asset{LastModified: modified}, []byte(assetPath))
+	}
+	config := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+
+	for _, tc := range []struct {
+		src      string
+		mode     util.SelectMode
+		folder   bool
+		expected string
+	}{
+		{"test-repo/app/{latest}", "", true, "app/1.10.0/app.zip"},
+		{"test-repo/app/{latest}", util.SelectLexical, true, "app/nightly/app.zip"},
+		{"test-repo/app/{latest:mtime}", util.SelectLexical, true, "app/1.9.0/app.zip"},
+		{"test-repo/tools/tool-{latest}.tar.gz", "", false, "tools/tool-v2.1.0.tar.gz"},
+	} {
+		destDir := t.TempDir()
+		var logs bytes.Buffer
+		opts := &DownloadOptions{ChecksumAlgorithm: "sha1", Logger: util.NewLogger(&logs), QuietMode: true, Recursive: tc.folder, Select: tc.mode}
+		if status := Download(tc.src, destDir, config, opts); status != DownloadSuccess {
+			t.Errorf("Download(%s) with %q failed: %v", tc.src, tc.mode, status)
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(destDir, tc.expected)); err != nil {
+			t.Errorf("Download(%s) with %q: expected %s to be downloaded: %v", tc.src, tc.mode, tc.expected, err)
+		}
+		if !strings.Contains(logs.String(), "Resolved "+tc.src+" -> test-repo/") {
+			t.Errorf("Expected the resolved source to be logged, got %q", logs.String())
+		}
+	}
+
+	opts := &DownloadOptions{ChecksumAlgorithm: "sha1", Logger: util.NewLogger(io.Discard), QuietMode: true, Recursive: true}
+	if status := Download("test-repo/{latest}/app.zip", t.TempDir(), config, opts); status != DownloadError {
+		t.Errorf("Expected an error if no name is a semantic version, got %v", status)
+	}
+}
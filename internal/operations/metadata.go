@@ -0,0 +1,250 @@
+package operations
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tympanix/nexus-cli/internal/nexusapi"
+)
+
+// MetadataManifestName is the name of the manifest that --preserve-mtime uploads into each
+// destination folder. Downloads never write it as a file.
+const MetadataManifestName = nexusapi.MetadataManifestName
+
+// MetadataManifestVersion is the manifest format written by this version
+const MetadataManifestVersion = 1
+
+// MetadataManifest records the file metadata that RAW repositories do not keep, for the
+// files in the folder the manifest is stored in and below it
+type MetadataManifest struct {
+	Version int                     `json:"version"`
+	Files   map[string]FileMetadata `json:"files"` // By path relative to the manifest's folder, with forward slashes
+}
+
+// FileMetadata is the metadata recorded for a single file
+type FileMetadata struct {
+	ModTime time.Time `json:"mtime"` // Modification time in UTC
+	Mode    string    `json:"mode"`  // Permission bits in octal, e.g. "0755"
+}
+
+// newFileMetadata returns the metadata to record for a local file
+func newFileMetadata(info fs.FileInfo) FileMetadata {
+	return FileMetadata{
+		ModTime: info.ModTime().UTC(),
+		Mode:    fmt.Sprintf("%04o", info.Mode().Perm()),
+	}
+}
+
+// perm parses the recorded permission bits
+func (m FileMetadata) perm() (fs.FileMode, error) {
+	mode, err := strconv.ParseUint(m.Mode, 8, 32)
+	if err != nil || mode > 0777 {
+		return 0, fmt.Errorf("invalid mode '%s'", m.Mode)
+	}
+	return fs.FileMode(mode), nil
+}
+
+// parseMetadataManifest decodes a manifest, rejecting versions newer than this one understands
+func parseMetadataManifest(data []byte) (*MetadataManifest, error) {
+	var manifest MetadataManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", MetadataManifestName, err)
+	}
+	if manifest.Version < 1 || manifest.Version > MetadataManifestVersion {
+		return nil, fmt.Errorf("unsupported %s version %d (supported: %d)", MetadataManifestName, manifest.Version, MetadataManifestVersion)
+	}
+	if manifest.Files == nil {
+		manifest.Files = make(map[string]FileMetadata)
+	}
+	return &manifest, nil
+}
+
+// findAsset looks up the asset at assetPath. It returns nil without an error if there is none.
+func findAsset(client *nexusapi.Client, repository, assetPath string) (*nexusapi.Asset, error) {
+	assets, err := client.ListAssets(repository, assetPath, false)
+	if err != nil {
+		return nil, err
+	}
+	for _, asset := range assets {
+		if strings.TrimLeft(asset.Path, "/") == strings.TrimLeft(assetPath, "/") {
+			return &asset, nil
+		}
+	}
+	return nil, nil
+}
+
+// fetchMetadataManifest downloads the manifest at manifestPath. It returns nil without an
+// error if there is none.
+func fetchMetadataManifest(client *nexusapi.Client, repository, manifestPath string) (*MetadataManifest, error) {
+	asset, err := findAsset(client, repository, manifestPath)
+	if err != nil || asset == nil {
+		return nil, err
+	}
+	return downloadMetadataManifest(client, *asset)
+}
+
+// downloadMetadataManifest downloads and decodes a manifest asset
+func downloadMetadataManifest(client *nexusapi.Client, asset nexusapi.Asset) (*MetadataManifest, error) {
+	var buf bytes.Buffer
+	if err := client.DownloadAsset(asset.DownloadURL, &buf); err != nil {
+		return nil, err
+	}
+	return parseMetadataManifest(buf.Bytes())
+}
+
+// uploadMetadataManifest records the modification time and mode of filePaths under their
+// remote paths and uploads the manifest to repository/subdir. Entries of an existing
+// manifest there are kept, so uploading into the same folder in several runs does not
+// lose the metadata of earlier files.
+func uploadMetadataManifest(client *nexusapi.Client, repository, subdir string, filePaths []string, remotePaths map[string]string, opts *UploadOptions) error {
+	manifestPath := path.Join(subdir, MetadataManifestName)
+
+	manifest, err := fetchMetadataManifest(client, repository, manifestPath)
+	if err != nil && !opts.QuietMode {
+		opts.Logger.Printf("Warning: replacing unreadable %s: %v\n", path.Join(repository, manifestPath), err)
+	}
+	if manifest == nil {
+		manifest = &MetadataManifest{Files: make(map[string]FileMetadata)}
+	}
+	manifest.Version = MetadataManifestVersion
+
+	for _, filePath := range filePaths {
+		info, err := os.Stat(filePath)
+		if err != nil {
+			return err
+		}
+		manifest.Files[remotePaths[filePath]] = newFileMetadata(info)
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := client.UploadRawAsset(repository, manifestPath, bytes.NewReader(data)); err != nil {
+		return fmt.Errorf("failed to upload %s: %w", MetadataManifestName, err)
+	}
+	opts.Logger.VerbosePrintf("Uploaded %s with metadata of %d file(s)\n", manifestPath, len(filePaths))
+	return nil
+}
+
+// manifestFolder returns the folder a manifest applies to, without leading or trailing slashes
+func manifestFolder(manifestPath string) string {
+	return strings.Trim(path.Dir("/"+strings.TrimLeft(manifestPath, "/")), "/")
+}
+
+// splitMetadataManifests separates the metadata manifests from the assets to download
+func splitMetadataManifests(assets []nexusapi.Asset) (files, manifests []nexusapi.Asset) {
+	files = assets[:0:0]
+	for _, asset := range assets {
+		if nexusapi.IsMetadataManifest(asset) {
+			manifests = append(manifests, asset)
+		} else {
+			files = append(files, asset)
+		}
+	}
+	return files, manifests
+}
+
+// restoreMetadata applies the modification times and modes recorded in manifests to the
+// downloaded files. localPaths maps each remote asset path, without a leading slash, to
+// its local path. Manifests of the folders above src are fetched as well, since src may be
+// a subfolder of the upload destination. Deeper manifests take precedence.
+func restoreMetadata(client *nexusapi.Client, repository, src string, manifests []nexusapi.Asset, localPaths map[string]string, opts *DownloadOptions) {
+	// Collect the folders above src that were not covered by the listing
+	listed := make(map[string]bool, len(manifests))
+	for _, manifest := range manifests {
+		listed[manifestFolder(manifest.Path)] = true
+	}
+	folder := strings.Trim(src, "/")
+	for {
+		folder = path.Dir(folder)
+		if folder == "." || folder == "/" {
+			folder = ""
+		}
+		if !listed[folder] {
+			asset, err := findAsset(client, repository, path.Join(folder, MetadataManifestName))
+			if err != nil {
+				opts.Logger.VerbosePrintf("Could not look up %s in '%s': %v\n", MetadataManifestName, folder, err)
+			} else if asset != nil {
+				manifests = append(manifests, *asset)
+			}
+		}
+		if folder == "" {
+			break
+		}
+	}
+
+	if len(manifests) == 0 {
+		if !opts.QuietMode {
+			opts.Logger.Printf("Warning: no %s found; modification times were not restored\n", MetadataManifestName)
+		}
+		return
+	}
+
+	// Apply shallow manifests first, so deeper ones override their entries
+	depth := func(manifest nexusapi.Asset) int {
+		return strings.Count("/"+strings.TrimLeft(manifest.Path, "/"), "/")
+	}
+	sort.SliceStable(manifests, func(i, j int) bool {
+		return depth(manifests[i]) < depth(manifests[j])
+	})
+	recorded := make(map[string]FileMetadata)
+	for _, asset := range manifests {
+		manifest, err := downloadMetadataManifest(client, asset)
+		if err != nil {
+			if !opts.QuietMode {
+				opts.Logger.Printf("Warning: ignoring %s: %v\n", strings.TrimLeft(asset.Path, "/"), err)
+			}
+			continue
+		}
+		folder := manifestFolder(asset.Path)
+		for relPath, meta := range manifest.Files {
+			recorded[strings.TrimLeft(path.Join(folder, relPath), "/")] = meta
+		}
+	}
+
+	// Files kept because they differ locally are left as they are
+	kept := make(map[string]bool)
+	if opts.OnConflict == ConflictSkip {
+		for _, localPath := range opts.conflicts.sorted() {
+			kept[localPath] = true
+		}
+	}
+
+	restored := 0
+	for remotePath, localPath := range localPaths {
+		meta, ok := recorded[remotePath]
+		if !ok || kept[localPath] {
+			continue
+		}
+		if err := applyFileMetadata(localPath, meta); err != nil {
+			if !errors.Is(err, os.ErrNotExist) && !opts.QuietMode {
+				opts.Logger.Printf("Warning: cannot restore metadata of %s: %v\n", localPath, err)
+			}
+			continue
+		}
+		restored++
+	}
+	opts.Logger.VerbosePrintf("Restored modification time and mode of %d file(s)\n", restored)
+}
+
+// applyFileMetadata sets the recorded mode and modification time on a local file
+func applyFileMetadata(localPath string, meta FileMetadata) error {
+	perm, err := meta.perm()
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(localPath, perm); err != nil {
+		return err
+	}
+	return os.Chtimes(localPath, meta.ModTime, meta.ModTime)
+}
@@ -0,0 +1,178 @@
+package operations
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/tympanix/nexus-cli/internal/config"
+	"github.com/tympanix/nexus-cli/internal/nexusapi"
+	"github.com/tympanix/nexus-cli/internal/util"
+)
+
+// writeFileWithMetadata creates a file with the given mode and modification time
+func writeFileWithMetadata(t *testing.T, path, content string, mode os.FileMode, mtime time.Time) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), mode); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// checkFileMetadata fails the test if the file does not have the given mode and modification time
+func checkFileMetadata(t *testing.T, path string, mode os.FileMode, mtime time.Time) {
+	t.Helper()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Expected %s to exist: %v", path, err)
+	}
+	if !info.ModTime().Equal(mtime) {
+		t.Errorf("Expected %s to have mtime %v, got %v", path, mtime, info.ModTime())
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm() != mode {
+		t.Errorf("Expected %s to have mode %04o, got %04o", path, mode, info.Mode().Perm())
+	}
+}
+
+func findUpload(server *nexusapi.MockNexusServer, remotePath string) *nexusapi.UploadedFile {
+	var found *nexusapi.UploadedFile
+	for _, file := range server.GetUploadedFiles() {
+		if file.Path == remotePath {
+			found = &file
+		}
+	}
+	return found
+}
+
+func TestPreserveMtimeRoundTrip(t *testing.T) {
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+	server.StoreUploads = true
+	config := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+
+	srcDir := t.TempDir()
+	readmeTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	scriptTime := time.Date(2021, 6, 7, 8, 9, 10, 0, time.UTC)
+	writeFileWithMetadata(t, filepath.Join(srcDir, "README.md"), "readme", 0644, readmeTime)
+	writeFileWithMetadata(t, filepath.Join(srcDir, "bin", "run.sh"), "#!/bin/sh", 0755, scriptTime)
+
+	uploadOpts := &UploadOptions{Logger: util.NewLogger(io.Discard), QuietMode: true, PreserveMtime: true}
+	if err := uploadFiles(srcDir, "raw", "app", config, uploadOpts); err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+
+	uploaded := findUpload(server, "/app/"+MetadataManifestName)
+	if uploaded == nil {
+		t.Fatalf("Expected %s to be uploaded", MetadataManifestName)
+	}
+	manifest, err := parseMetadataManifest(uploaded.Content)
+	if err != nil {
+		t.Fatalf("Uploaded manifest is invalid: %v", err)
+	}
+	if manifest.Version != MetadataManifestVersion || len(manifest.Files) != 2 {
+		t.Fatalf("Unexpected manifest: %+v", manifest)
+	}
+	if meta := manifest.Files["bin/run.sh"]; !meta.ModTime.Equal(scriptTime) || meta.Mode != "0755" {
+		t.Errorf("Unexpected metadata for bin/run.sh: %+v", meta)
+	}
+
+	t.Run("download folder", func(t *testing.T) {
+		destDir := t.TempDir()
+		opts := &DownloadOptions{Logger: util.NewLogger(io.Discard), QuietMode: true, Recursive: true, PreserveMtime: true}
+		if status := downloadFolder("raw/app", destDir, config, opts); status != DownloadSuccess {
+			t.Fatalf("Download failed with status %d", status)
+		}
+		checkFileMetadata(t, filepath.Join(destDir, "app", "README.md"), 0644, readmeTime)
+		checkFileMetadata(t, filepath.Join(destDir, "app", "bin", "run.sh"), 0755, scriptTime)
+		if _, err := os.Stat(filepath.Join(destDir, "app", MetadataManifestName)); !os.IsNotExist(err) {
+			t.Errorf("Expected %s not to be written locally", MetadataManifestName)
+		}
+	})
+
+	t.Run("download subfolder uses manifest of parent", func(t *testing.T) {
+		destDir := t.TempDir()
+		opts := &DownloadOptions{Logger: util.NewLogger(io.Discard), QuietMode: true, Recursive: true, PreserveMtime: true}
+		if status := downloadFolder("raw/app/bin", destDir, config, opts); status != DownloadSuccess {
+			t.Fatalf("Download failed with status %d", status)
+		}
+		checkFileMetadata(t, filepath.Join(destDir, "app", "bin", "run.sh"), 0755, scriptTime)
+	})
+
+	t.Run("download without flag", func(t *testing.T) {
+		destDir := t.TempDir()
+		opts := &DownloadOptions{Logger: util.NewLogger(io.Discard), QuietMode: true, Recursive: true}
+		if status := downloadFolder("raw/app", destDir, config, opts); status != DownloadSuccess {
+			t.Fatalf("Download failed with status %d", status)
+		}
+		info, err := os.Stat(filepath.Join(destDir, "app", "README.md"))
+		if err != nil || info.ModTime().Equal(readmeTime) {
+			t.Errorf("Expected the modification time not to be restored without --preserve-mtime")
+		}
+		if _, err := os.Stat(filepath.Join(destDir, "app", MetadataManifestName)); !os.IsNotExist(err) {
+			t.Errorf("Expected %s not to be written locally", MetadataManifestName)
+		}
+	})
+}
+
+// TestPreserveMtimeMergesManifest checks that a second upload into the same folder keeps
+// the entries of files uploaded earlier
+func TestPreserveMtimeMergesManifest(t *testing.T) {
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+	server.StoreUploads = true
+	config := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+	opts := &UploadOptions{Logger: util.NewLogger(io.Discard), QuietMode: true, PreserveMtime: true}
+
+	first := t.TempDir()
+	firstTime := time.Date(2019, 5, 1, 0, 0, 0, 0, time.UTC)
+	writeFileWithMetadata(t, filepath.Join(first, "a.txt"), "a", 0644, firstTime)
+	if err := uploadFiles(first, "raw", "shared", config, opts); err != nil {
+		t.Fatalf("First upload failed: %v", err)
+	}
+
+	second := t.TempDir()
+	writeFileWithMetadata(t, filepath.Join(second, "b.txt"), "b", 0600, time.Date(2022, 5, 1, 0, 0, 0, 0, time.UTC))
+	if err := uploadFiles(second, "raw", "shared", config, opts); err != nil {
+		t.Fatalf("Second upload failed: %v", err)
+	}
+
+	uploaded := findUpload(server, "/shared/"+MetadataManifestName)
+	if uploaded == nil {
+		t.Fatalf("Expected %s to be uploaded", MetadataManifestName)
+	}
+	var manifest MetadataManifest
+	if err := json.Unmarshal(uploaded.Content, &manifest); err != nil {
+		t.Fatal(err)
+	}
+	if len(manifest.Files) != 2 || !manifest.Files["a.txt"].ModTime.Equal(firstTime) || manifest.Files["b.txt"].Mode != "0600" {
+		t.Errorf("Expected the manifest to hold both uploads, got %+v", manifest.Files)
+	}
+}
+
+func TestParseMetadataManifest(t *testing.T) {
+	if _, err := parseMetadataManifest([]byte(`{"version": 2, "files": {}}`)); err == nil {
+		t.Error("Expected an error for a newer manifest version")
+	}
+	if _, err := parseMetadataManifest([]byte(`{"files": {}}`)); err == nil {
+		t.Error("Expected an error for a manifest without version")
+	}
+	manifest, err := parseMetadataManifest([]byte(`{"version": 1}`))
+	if err != nil || manifest.Files == nil {
+		t.Errorf("Expected an empty manifest, got %+v, %v", manifest, err)
+	}
+	if _, err := (FileMetadata{Mode: "1777"}).perm(); err == nil {
+		t.Error("Expected an error for mode bits outside the permissions")
+	}
+}
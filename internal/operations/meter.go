@@ -0,0 +1,94 @@
+package operations
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tympanix/nexus-cli/internal/output"
+)
+
+// rateWindow is how long the current rate is measured over
+const rateWindow = 2 * time.Second
+
+// transferMeter is the output.Meter of a transfer. The progress bar, the --max-rate
+// limiter, the ETA and the summary all read from the same meter, so parallel workers
+// update one set of counts instead of each feature keeping its own.
+type transferMeter struct {
+	start       time.Time
+	total       atomic.Int64
+	transferred atomic.Int64
+	skipped     atomic.Int64
+
+	mu          sync.Mutex // Guards the rate measurement
+	windowStart time.Time
+	windowBytes int64 // Transferred bytes at windowStart
+	rate        float64
+	sampled     bool // Whether a full window has been measured
+}
+
+func newTransferMeter() *transferMeter {
+	now := time.Now()
+	return &transferMeter{start: now, windowStart: now}
+}
+
+func (m *transferMeter) Add(n int64) {
+	m.transferred.Add(n)
+}
+
+func (m *transferMeter) Skip(n int64) {
+	m.skipped.Add(n)
+}
+
+func (m *transferMeter) Grow(n int64) {
+	m.total.Add(n)
+}
+
+// Write counts len(p) transferred bytes, so the meter can be used in an io.MultiWriter
+func (m *transferMeter) Write(p []byte) (int, error) {
+	m.Add(int64(len(p)))
+	return len(p), nil
+}
+
+// skipWriter returns a writer that counts every byte written to it as skipped, e.g. for
+// local files hashed to decide whether they have to be transferred
+func (m *transferMeter) skipWriter() io.Writer {
+	return meterSkipWriter{m}
+}
+
+type meterSkipWriter struct {
+	meter *transferMeter
+}
+
+func (w meterSkipWriter) Write(p []byte) (int, error) {
+	w.meter.Skip(int64(len(p)))
+	return len(p), nil
+}
+
+// Reading returns the counts so far. The current rate is the rate over the last full
+// window, or the average rate until a window has passed.
+func (m *transferMeter) Reading() output.MeterReading {
+	now := time.Now()
+	transferred := m.transferred.Load()
+	reading := output.MeterReading{
+		TotalBytes:       m.total.Load(),
+		TransferredBytes: transferred,
+		SkippedBytes:     m.skipped.Load(),
+		Elapsed:          now.Sub(m.start),
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if elapsed := now.Sub(m.windowStart); elapsed >= rateWindow {
+		m.rate = float64(transferred-m.windowBytes) / elapsed.Seconds()
+		m.windowStart = now
+		m.windowBytes = transferred
+		m.sampled = true
+	}
+	reading.Rate = m.rate
+	if !m.sampled {
+		reading.Rate = reading.AverageRate()
+	}
+	return reading
+}
@@ -0,0 +1,97 @@
+package operations
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestTransferMeterConcurrentUpdates checks that updates from many goroutines are all counted
+func TestTransferMeterConcurrentUpdates(t *testing.T) {
+	meter := newTransferMeter()
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			meter.Grow(3000)
+			for j := 0; j < 100; j++ {
+				meter.Write(make([]byte, 10))
+				meter.Skip(5)
+				meter.skipWriter().Write(make([]byte, 5))
+				meter.Reading() // Readers race with the writers
+			}
+		}()
+	}
+	wg.Wait()
+
+	reading := meter.Reading()
+	if reading.TotalBytes != 50*3000 {
+		t.Errorf("Expected a total of %d bytes, got %d", 50*3000, reading.TotalBytes)
+	}
+	if reading.TransferredBytes != 50*100*10 {
+		t.Errorf("Expected %d transferred bytes, got %d", 50*100*10, reading.TransferredBytes)
+	}
+	if reading.SkippedBytes != 50*100*10 {
+		t.Errorf("Expected %d skipped bytes, got %d", 50*100*10, reading.SkippedBytes)
+	}
+	if reading.Done() != reading.TotalBytes*2/3 {
+		t.Errorf("Expected %d bytes done, got %d", reading.TotalBytes*2/3, reading.Done())
+	}
+}
+
+// TestTransferMeterRate checks that the current rate only counts transferred bytes
+func TestTransferMeterRate(t *testing.T) {
+	meter := newTransferMeter()
+	if reading := meter.Reading(); reading.Rate != 0 {
+		t.Errorf("Expected no rate before anything is transferred, got %f", reading.Rate)
+	}
+	meter.Skip(1 << 20)
+	if reading := meter.Reading(); reading.Rate != 0 {
+		t.Errorf("Expected skipped bytes not to count towards the rate, got %f", reading.Rate)
+	}
+
+	// Pretend the transfer started a window ago
+	meter.start = meter.start.Add(-rateWindow)
+	meter.windowStart = meter.start
+	meter.Add(1000)
+	reading := meter.Reading()
+	if reading.Rate <= 0 || reading.Rate > 1000/rateWindow.Seconds() {
+		t.Errorf("Expected a rate of at most %f, got %f", 1000/rateWindow.Seconds(), reading.Rate)
+	}
+	if reading.Rate != meter.Reading().Rate {
+		t.Error("Expected the rate to be kept until the next window")
+	}
+}
+
+// TestRateLimiterReadsMeter checks that --max-rate throttles by the bytes counted on the
+// meter, which are the same bytes the progress bar shows
+func TestRateLimiterReadsMeter(t *testing.T) {
+	meter := newTransferMeter()
+	limiter := newRateLimiter(10000, meter)
+
+	var buf bytes.Buffer
+	writer := limitWriter(io.MultiWriter(&buf, meter), limiter)
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		writer.Write(make([]byte, 500))
+	}
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("Expected 2000 bytes at 10000 B/s to take about 200ms, took %s", elapsed)
+	}
+	if buf.Len() != 2000 || meter.Reading().TransferredBytes != 2000 {
+		t.Errorf("Expected every byte to be written and counted, got %d written, %d counted", buf.Len(), meter.Reading().TransferredBytes)
+	}
+
+	// Skipped bytes do not count against the limit
+	meter = newTransferMeter()
+	limiter = newRateLimiter(10000, meter)
+	meter.Skip(1 << 20)
+	start = time.Now()
+	limiter.wait()
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Errorf("Expected no delay for skipped bytes, waited %s", elapsed)
+	}
+}
@@ -0,0 +1,281 @@
+package operations
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/tympanix/nexus-cli/internal/checksum"
+	"github.com/tympanix/nexus-cli/internal/config"
+	"github.com/tympanix/nexus-cli/internal/exitcode"
+	"github.com/tympanix/nexus-cli/internal/metrics"
+	"github.com/tympanix/nexus-cli/internal/nexusapi"
+	"github.com/tympanix/nexus-cli/internal/util"
+)
+
+// MirrorOptions holds options for mirror operations
+type MirrorOptions struct {
+	Logger      util.Logger
+	QuietMode   bool
+	DryRun      bool       // Report what would be copied or deleted without changing the destination
+	PlanFormat  PlanFormat // How DryRun prints the planned actions (default: text)
+	Delete      bool       // Delete destination assets that are not present in the source
+	GlobPattern string     // Optional glob pattern(s) to filter assets (comma-separated, supports negation with !)
+	Concurrency int        // Maximum number of parallel copies (0 = unlimited)
+}
+
+// SetGlobPattern validates and sets the glob pattern used to filter assets
+// Returns an error naming the offending sub-pattern if the pattern is malformed
+func (opts *MirrorOptions) SetGlobPattern(pattern string) error {
+	if err := util.ValidateGlobPattern(pattern); err != nil {
+		return err
+	}
+	opts.GlobPattern = pattern
+	return nil
+}
+
+// MirrorEndpoint is one side of a mirror: a Nexus instance with its own
+// credentials, a repository and an optional path inside it
+type MirrorEndpoint struct {
+	Config     *config.Config
+	Repository string
+	Path       string
+}
+
+func (e MirrorEndpoint) String() string {
+	return strings.TrimSuffix(e.Config.NexusURL, "/") + "/" + path.Join(e.Repository, e.Path)
+}
+
+// assetURL returns the URL of the asset at relPath below the endpoint
+func (e MirrorEndpoint) assetURL(relPath string) string {
+	return e.String() + "/" + relPath
+}
+
+// ParseMirrorEndpoint parses an argument of the form <url>/<repo>[/<path>], e.g.
+// https://nexus.example.com/raw-releases/firmware. The Nexus base URL is the server of
+// cfg or of a [host] section in settings that the argument starts with, context path
+// included, or else the scheme and host of the argument, see config.ServerURL. The
+// endpoint connects with the settings of its server, see config.Config.ForURL, and the
+// given credentials. settings may be nil.
+func ParseMirrorEndpoint(arg, username, password string, cfg *config.Config, settings *config.Settings) (MirrorEndpoint, error) {
+	u, err := url.Parse(arg)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return MirrorEndpoint{}, fmt.Errorf("invalid mirror location '%s': must be in the form <url>/<repo>[/<path>]", arg)
+	}
+	serverURL := config.ServerURL(arg, cfg.NexusURL, settings)
+	server, err := url.Parse(serverURL)
+	if err != nil {
+		return MirrorEndpoint{}, fmt.Errorf("invalid mirror location '%s': %w", arg, err)
+	}
+	rest := strings.TrimPrefix(strings.TrimRight(u.Path, "/"), strings.TrimRight(server.Path, "/"))
+	repository, repoPath, _ := strings.Cut(strings.Trim(rest, "/"), "/")
+	if repository == "" {
+		return MirrorEndpoint{}, fmt.Errorf("invalid mirror location '%s': missing repository name", arg)
+	}
+	endpointConfig, err := cfg.ForURL(serverURL, settings, nil)
+	if err != nil {
+		return MirrorEndpoint{}, err
+	}
+	endpointConfig.Username = username
+	endpointConfig.Password = password
+	return MirrorEndpoint{
+		Config:     endpointConfig,
+		Repository: repository,
+		Path:       repoPath,
+	}, nil
+}
+
+// MirrorResult counts the outcome of a mirror run
+type MirrorResult struct {
+	Copied    int
+	Deleted   int
+	Identical int
+	Failed    int
+}
+
+// sameAssetContent reports whether two assets have the same content according to
+// the strongest checksum both servers report. Assets without a common checksum differ.
+func sameAssetContent(a, b nexusapi.Asset) bool {
+	for _, algorithm := range []string{"sha256", "sha1", "md5"} {
+		expected := checksum.ExtractChecksum(a.Checksum, algorithm)
+		actual := checksum.ExtractChecksum(b.Checksum, algorithm)
+		if expected == "" || actual == "" {
+			continue
+		}
+		match, err := checksum.Matches(expected, actual, algorithm)
+		return err == nil && match
+	}
+	return false
+}
+
+// listMirrorAssets lists the assets below the endpoint path keyed by their path relative to it.
+// Metadata manifests are mirrored like any other asset, so downloads from the destination
+// restore the recorded modification times as well.
+func listMirrorAssets(endpoint MirrorEndpoint, globPattern string) (map[string]nexusapi.Asset, error) {
+	client := NewClient(endpoint.Config)
+	assets, err := client.ListAssets(endpoint.Repository, endpoint.Path, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", endpoint, err)
+	}
+	if globPattern != "" {
+		assets, err = filterAssetsByGlob(assets, endpoint.Path, globPattern)
+		if err != nil {
+			return nil, err
+		}
+	}
+	byPath := make(map[string]nexusapi.Asset, len(assets))
+	for _, asset := range assets {
+		byPath[getRelativePath(asset.Path, endpoint.Path)] = asset
+	}
+	return byPath, nil
+}
+
+// mirror copies assets that are missing or changed in dst from src, and with
+// opts.Delete removes dst assets absent from src. Content is streamed from the
+// source server straight into the upload to the destination server.
+func mirror(src, dst MirrorEndpoint, opts *MirrorOptions) (MirrorResult, error) {
+	var result MirrorResult
+	registry := metrics.Active()
+
+	stopList := registry.Phase(metrics.PhaseList)
+	srcAssets, err := listMirrorAssets(src, opts.GlobPattern)
+	if err != nil {
+		return result, err
+	}
+	dstAssets, err := listMirrorAssets(dst, opts.GlobPattern)
+	if err != nil {
+		return result, err
+	}
+	stopList()
+
+	var toCopy, toDelete []string
+	for relPath, asset := range srcAssets {
+		if existing, ok := dstAssets[relPath]; ok && sameAssetContent(asset, existing) {
+			result.Identical++
+			registry.Add(metrics.Files, 1, metrics.OutcomeSkipped)
+			continue
+		}
+		toCopy = append(toCopy, relPath)
+	}
+	if opts.Delete {
+		for relPath := range dstAssets {
+			if _, ok := srcAssets[relPath]; !ok {
+				toDelete = append(toDelete, relPath)
+			}
+		}
+	}
+	sort.Strings(toCopy)
+	sort.Strings(toDelete)
+
+	// A dry run prints the plan, otherwise it is executed
+	plan := NewPlan("mirror")
+	for _, relPath := range toCopy {
+		asset := srcAssets[relPath]
+		plan.Add(Action{Type: ActionCopy, Source: src.assetURL(relPath), Target: dst.assetURL(relPath), Size: asset.FileSize, asset: &asset, relPath: relPath})
+	}
+	for _, relPath := range toDelete {
+		asset := dstAssets[relPath]
+		plan.Add(Action{Type: ActionDeleteRemote, Target: dst.assetURL(relPath), asset: &asset, relPath: relPath})
+	}
+	if opts.DryRun {
+		plan.Print(opts.Logger, opts.PlanFormat)
+		result.Copied = len(toCopy)
+		result.Deleted = len(toDelete)
+		return result, nil
+	}
+
+	srcClient := NewClient(src.Config)
+	dstClient := NewClient(dst.Config)
+
+	stopTransfer := registry.Phase(metrics.PhaseTransfer)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var sem chan struct{}
+	if opts.Concurrency > 0 {
+		sem = make(chan struct{}, opts.Concurrency)
+	}
+	for _, action := range plan.ofType(ActionCopy) {
+		wg.Add(1)
+		go func(asset nexusapi.Asset, relPath string) {
+			defer wg.Done()
+			if sem != nil {
+				sem <- struct{}{}
+				defer func() { <-sem }()
+			}
+			err := copyMirrorAsset(srcClient, dstClient, asset, dst.Repository, path.Join(dst.Path, relPath))
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				result.Failed++
+				registry.Add(metrics.Files, 1, metrics.OutcomeFailed)
+				opts.Logger.Printf("✗ %s (copy failed: %v)\n", relPath, err)
+				return
+			}
+			result.Copied++
+			registry.Add(metrics.Files, 1, metrics.OutcomeSucceeded)
+			registry.Add(metrics.TransferredBytes, float64(asset.FileSize))
+			opts.Logger.VerbosePrintf("✓ %s (copied)\n", relPath)
+		}(*action.asset, action.relPath)
+	}
+	wg.Wait()
+	stopTransfer()
+
+	// Delete only after copying, so a failed run never leaves the destination emptier than before
+	defer registry.Phase(metrics.PhaseDelete)()
+	for _, action := range plan.ofType(ActionDeleteRemote) {
+		if err := dstClient.DeleteAsset(action.asset.ID); err != nil {
+			result.Failed++
+			opts.Logger.Printf("✗ %s (delete failed: %v)\n", action.relPath, err)
+			continue
+		}
+		result.Deleted++
+		opts.Logger.VerbosePrintf("- %s (deleted)\n", action.relPath)
+	}
+
+	return result, nil
+}
+
+// copyMirrorAsset streams a single asset from the source server into the destination repository
+func copyMirrorAsset(srcClient, dstClient *nexusapi.Client, asset nexusapi.Asset, repository, remotePath string) error {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(srcClient.DownloadAsset(asset.DownloadURL, pw))
+	}()
+	err := dstClient.UploadRawAsset(repository, remotePath, pr)
+	pr.CloseWithError(err)
+	return err
+}
+
+// MirrorMain mirrors src to dst and prints a summary. It exits with status 1 on failure.
+func MirrorMain(src, dst MirrorEndpoint, opts *MirrorOptions) {
+	if !Mirror(src, dst, opts) {
+		os.Exit(exitcode.Error)
+	}
+}
+
+// Mirror mirrors src to dst like MirrorMain, but reports whether it succeeded instead of
+// exiting
+func Mirror(src, dst MirrorEndpoint, opts *MirrorOptions) bool {
+	opts.Logger.Printf("Mirroring %s -> %s\n", src, dst)
+	result, err := mirror(src, dst, opts)
+	if err != nil {
+		fmt.Println("Mirror error:", err)
+		return false
+	}
+
+	prefix := ""
+	if opts.DryRun {
+		prefix = "Dry-run: would have "
+	}
+	summary := fmt.Sprintf("%scopied: %d, deleted: %d, identical: %d", prefix, result.Copied, result.Deleted, result.Identical)
+	if result.Failed > 0 {
+		summary += fmt.Sprintf(", failed: %d", result.Failed)
+	}
+	opts.Logger.Println(summary)
+	return result.Failed == 0
+}
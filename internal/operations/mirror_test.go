@@ -0,0 +1,218 @@
+package operations
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/tympanix/nexus-cli/internal/config"
+	"github.com/tympanix/nexus-cli/internal/nexusapi"
+	"github.com/tympanix/nexus-cli/internal/util"
+)
+
+func TestParseMirrorEndpoint(t *testing.T) {
+	cfg := &config.Config{NexusURL: "http://localhost:8081"}
+	endpoint, err := ParseMirrorEndpoint("https://nexus.example.com/raw-releases/firmware/v1", "user", "pass", cfg, nil)
+	if err != nil {
+		t.Fatalf("ParseMirrorEndpoint failed: %v", err)
+	}
+	if endpoint.Config.NexusURL != "https://nexus.example.com" || endpoint.Repository != "raw-releases" || endpoint.Path != "firmware/v1" {
+		t.Errorf("Unexpected endpoint: %+v (url %s)", endpoint, endpoint.Config.NexusURL)
+	}
+	if endpoint.Config.Username != "user" || endpoint.Config.Password != "pass" {
+		t.Errorf("Expected credentials to be kept, got %+v", endpoint.Config)
+	}
+
+	for _, arg := range []string{"raw-releases/firmware", "https://nexus.example.com", "https://nexus.example.com/"} {
+		if _, err := ParseMirrorEndpoint(arg, "", "", cfg, nil); err == nil {
+			t.Errorf("Expected error for %q", arg)
+		}
+	}
+}
+
+func TestParseMirrorEndpointServerSettings(t *testing.T) {
+	// The server of --url keeps its context path and Unix socket
+	cfg := &config.Config{NexusURL: "https://example.com/nexus/", UnixSocket: "/run/nexus.sock"}
+	endpoint, err := ParseMirrorEndpoint("https://example.com/nexus/raw/fw", "user", "pass", cfg, nil)
+	if err != nil {
+		t.Fatalf("ParseMirrorEndpoint failed: %v", err)
+	}
+	if endpoint.Config.NexusURL != "https://example.com/nexus/" || endpoint.Repository != "raw" || endpoint.Path != "fw" || endpoint.Config.UnixSocket != "/run/nexus.sock" {
+		t.Errorf("Expected the server of --url with its socket, got %+v (url %s)", endpoint, endpoint.Config.NexusURL)
+	}
+
+	// A server with a [host] section keeps its context path and gets its TLS settings,
+	// but not the Unix socket of another server
+	settings := &config.Settings{Hosts: map[string]config.HostSettings{"https://partner.example.com/repo-manager": {Insecure: true}}}
+	endpoint, err = ParseMirrorEndpoint("https://partner.example.com/repo-manager/mirror", "user", "pass", cfg, settings)
+	if err != nil {
+		t.Fatalf("ParseMirrorEndpoint failed: %v", err)
+	}
+	if endpoint.Config.NexusURL != "https://partner.example.com/repo-manager" || endpoint.Repository != "mirror" || endpoint.Path != "" {
+		t.Errorf("Expected the context path to be kept, got %+v (url %s)", endpoint, endpoint.Config.NexusURL)
+	}
+	if endpoint.Config.TLSConfig() == nil || !endpoint.Config.TLSConfig().InsecureSkipVerify || endpoint.Config.UnixSocket != "" {
+		t.Errorf("Expected the TLS settings of the host section and no socket, got %+v", endpoint.Config)
+	}
+}
+
+func TestMirrorContextPath(t *testing.T) {
+	srcServer, dstServer, _, _ := setupMirrorServers(t)
+	srcServer.AddAsset("releases", "/fw/a.bin", nexusapi.Asset{}, []byte("content"))
+
+	// Serve both servers below /nexus only, as behind a reverse proxy
+	srcProxy := httptest.NewServer(http.StripPrefix("/nexus", srcServer.Server.Config.Handler))
+	defer srcProxy.Close()
+	dstProxy := httptest.NewServer(http.StripPrefix("/nexus", dstServer.Server.Config.Handler))
+	defer dstProxy.Close()
+
+	cfg := &config.Config{NexusURL: srcProxy.URL + "/nexus"}
+	src, err := ParseMirrorEndpoint(srcProxy.URL+"/nexus/releases/fw", "src", "src", cfg, nil)
+	if err != nil {
+		t.Fatalf("ParseMirrorEndpoint failed: %v", err)
+	}
+	settings := &config.Settings{Hosts: map[string]config.HostSettings{dstProxy.URL + "/nexus": {}}}
+	dst, err := ParseMirrorEndpoint(dstProxy.URL+"/nexus/mirror/copy", "dst", "dst", cfg, settings)
+	if err != nil {
+		t.Fatalf("ParseMirrorEndpoint failed: %v", err)
+	}
+
+	var logBuf strings.Builder
+	result, err := mirror(src, dst, &MirrorOptions{Logger: util.NewLogger(&logBuf)})
+	if err != nil {
+		t.Fatalf("mirror failed: %v\n%s", err, logBuf.String())
+	}
+	if result != (MirrorResult{Copied: 1}) {
+		t.Errorf("Unexpected result: %+v\n%s", result, logBuf.String())
+	}
+	if got := strings.Join(uploadedPaths(dstServer), ","); got != "/copy/a.bin" {
+		t.Errorf("Expected the asset to be copied through the context path, got %s", got)
+	}
+}
+
+// setupMirrorServers starts a source and a destination server with endpoints using separate credentials
+func setupMirrorServers(t *testing.T) (*nexusapi.MockNexusServer, *nexusapi.MockNexusServer, MirrorEndpoint, MirrorEndpoint) {
+	t.Helper()
+	srcServer := nexusapi.NewMockNexusServer()
+	dstServer := nexusapi.NewMockNexusServer()
+	t.Cleanup(srcServer.Close)
+	t.Cleanup(dstServer.Close)
+
+	src := MirrorEndpoint{Config: &config.Config{NexusURL: srcServer.URL, Username: "src", Password: "src"}, Repository: "releases", Path: "fw"}
+	dst := MirrorEndpoint{Config: &config.Config{NexusURL: dstServer.URL, Username: "dst", Password: "dst"}, Repository: "mirror", Path: "copy"}
+	return srcServer, dstServer, src, dst
+}
+
+func uploadedPaths(server *nexusapi.MockNexusServer) []string {
+	var paths []string
+	for _, file := range server.GetUploadedFiles() {
+		paths = append(paths, file.Path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func TestMirrorCopiesMissingAndChangedAssets(t *testing.T) {
+	srcServer, dstServer, src, dst := setupMirrorServers(t)
+	srcServer.AddAsset("releases", "/fw/a.bin", nexusapi.Asset{}, []byte("same"))
+	srcServer.AddAsset("releases", "/fw/b.bin", nexusapi.Asset{}, []byte("new content"))
+	srcServer.AddAsset("releases", "/fw/sub/c.bin", nexusapi.Asset{}, []byte("missing"))
+	dstServer.AddAsset("mirror", "/copy/a.bin", nexusapi.Asset{}, []byte("same"))
+	dstServer.AddAsset("mirror", "/copy/b.bin", nexusapi.Asset{}, []byte("old content"))
+	dstServer.AddAsset("mirror", "/copy/stale.bin", nexusapi.Asset{}, []byte("stale"))
+
+	var logBuf strings.Builder
+	result, err := mirror(src, dst, &MirrorOptions{Logger: util.NewLogger(&logBuf), Concurrency: 2})
+	if err != nil {
+		t.Fatalf("mirror failed: %v", err)
+	}
+
+	if result != (MirrorResult{Copied: 2, Identical: 1}) {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+	if got := strings.Join(uploadedPaths(dstServer), ","); got != "/copy/b.bin,/copy/sub/c.bin" {
+		t.Errorf("Expected changed and missing assets to be copied, got %s", got)
+	}
+	for _, file := range dstServer.GetUploadedFiles() {
+		if file.Repository != "mirror" {
+			t.Errorf("Expected upload to destination repository, got %s", file.Repository)
+		}
+		if file.Path == "/copy/b.bin" && string(file.Content) != "new content" {
+			t.Errorf("Expected source content to be streamed, got %q", file.Content)
+		}
+	}
+	if deleted := dstServer.GetDeletedAssets(); len(deleted) != 0 {
+		t.Errorf("Expected no deletions without --delete, got %v", deleted)
+	}
+}
+
+func TestMirrorDelete(t *testing.T) {
+	srcServer, dstServer, src, dst := setupMirrorServers(t)
+	srcServer.AddAsset("releases", "/fw/a.bin", nexusapi.Asset{}, []byte("same"))
+	dstServer.AddAsset("mirror", "/copy/a.bin", nexusapi.Asset{}, []byte("same"))
+	dstServer.AddAsset("mirror", "/copy/stale.bin", nexusapi.Asset{ID: "stale-id"}, []byte("stale"))
+
+	var logBuf strings.Builder
+	result, err := mirror(src, dst, &MirrorOptions{Logger: util.NewLogger(&logBuf), Delete: true})
+	if err != nil {
+		t.Fatalf("mirror failed: %v", err)
+	}
+
+	if result != (MirrorResult{Deleted: 1, Identical: 1}) {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+	if deleted := dstServer.GetDeletedAssets(); len(deleted) != 1 || deleted[0] != "stale-id" {
+		t.Errorf("Expected stale asset to be deleted, got %v", deleted)
+	}
+}
+
+func TestMirrorDryRun(t *testing.T) {
+	srcServer, dstServer, src, dst := setupMirrorServers(t)
+	srcServer.AddAsset("releases", "/fw/a.bin", nexusapi.Asset{}, []byte("content"))
+	dstServer.AddAsset("mirror", "/copy/stale.bin", nexusapi.Asset{}, []byte("stale"))
+
+	var logBuf strings.Builder
+	result, err := mirror(src, dst, &MirrorOptions{Logger: util.NewVerboseLogger(&logBuf), Delete: true, DryRun: true})
+	if err != nil {
+		t.Fatalf("mirror failed: %v", err)
+	}
+
+	if result != (MirrorResult{Copied: 1, Deleted: 1}) {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+	if len(dstServer.GetUploadedFiles()) != 0 || len(dstServer.GetDeletedAssets()) != 0 {
+		t.Error("Expected dry-run to leave the destination untouched")
+	}
+	output := logBuf.String()
+	if !strings.Contains(output, "Would copy: "+src.String()+"/a.bin -> "+dst.String()+"/a.bin") || !strings.Contains(output, "Would delete: "+dst.String()+"/stale.bin") {
+		t.Errorf("Expected dry-run plan in output, got: %s", output)
+	}
+}
+
+func TestMirrorGlob(t *testing.T) {
+	srcServer, dstServer, src, dst := setupMirrorServers(t)
+	srcServer.AddAsset("releases", "/fw/a.bin", nexusapi.Asset{}, []byte("bin"))
+	srcServer.AddAsset("releases", "/fw/a.txt", nexusapi.Asset{}, []byte("txt"))
+	dstServer.AddAsset("mirror", "/copy/notes.txt", nexusapi.Asset{}, []byte("keep"))
+
+	opts := &MirrorOptions{Logger: util.NewLogger(&strings.Builder{}), Delete: true}
+	if err := opts.SetGlobPattern("**/*.bin"); err != nil {
+		t.Fatal(err)
+	}
+	result, err := mirror(src, dst, opts)
+	if err != nil {
+		t.Fatalf("mirror failed: %v", err)
+	}
+
+	if result != (MirrorResult{Copied: 1}) {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+	if got := strings.Join(uploadedPaths(dstServer), ","); got != "/copy/a.bin" {
+		t.Errorf("Expected only matching assets to be copied, got %s", got)
+	}
+	if len(dstServer.GetDeletedAssets()) != 0 {
+		t.Error("Expected assets outside the glob to be left alone by --delete")
+	}
+}
@@ -0,0 +1,283 @@
+package operations
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/tympanix/nexus-cli/internal/config"
+	"github.com/tympanix/nexus-cli/internal/exitcode"
+	"github.com/tympanix/nexus-cli/internal/nexusapi"
+	"github.com/tympanix/nexus-cli/internal/progress"
+	"github.com/tympanix/nexus-cli/internal/util"
+)
+
+// MoveOptions holds options for move operations
+type MoveOptions struct {
+	Logger      util.Logger
+	QuietMode   bool
+	DryRun      bool       // Report what would be moved without changing the repository
+	PlanFormat  PlanFormat // How DryRun prints the planned actions (default: text)
+	Concurrency int        // Maximum number of parallel copies (0 = unlimited)
+}
+
+// MoveResult counts the outcome of a move
+type MoveResult struct {
+	Moved    int // Copied, verified and removed from the source
+	Existing int // Already present with the same content at the destination; removed from the source
+	Failed   int // Left in place at the source
+	Bytes    int64
+}
+
+// moveTarget returns the destination path of an asset below srcPath. Moving a single
+// asset, whose path is srcPath itself, renames it to dstPath.
+func moveTarget(assetPath, srcPath, dstPath string) string {
+	if strings.Trim(assetPath, "/") == strings.Trim(srcPath, "/") {
+		return strings.Trim(dstPath, "/")
+	}
+	return path.Join(strings.Trim(dstPath, "/"), getRelativePath(assetPath, srcPath))
+}
+
+// move moves the assets below srcPath to dstPath inside repository. Nexus has no API to
+// move assets, so each asset is copied with client.CopyAsset, on the server where it can
+// and otherwise streamed through the client without touching the local disk. Sources are deleted only once
+// the destination lists their copy with the same checksum, so an interrupted or failed
+// move leaves both copies behind and can simply be run again.
+func move(repository, srcPath, dstPath string, config *config.Config, opts *MoveOptions) (MoveResult, error) {
+	var result MoveResult
+
+	src := strings.Trim(srcPath, "/")
+	dst := strings.Trim(dstPath, "/")
+	if src == "" || dst == "" {
+		return result, fmt.Errorf("source and destination must be paths inside the repository")
+	}
+	if src == dst || strings.HasPrefix(dst+"/", src+"/") || strings.HasPrefix(src+"/", dst+"/") {
+		return result, fmt.Errorf("cannot move %s to %s: the paths overlap", src, dst)
+	}
+
+	client := NewClient(config)
+	srcAssets, err := listMoveSources(client, repository, src)
+	if err != nil {
+		return result, err
+	}
+
+	dstAssets, err := listMoveDestination(client, repository, dst)
+	if err != nil {
+		return result, err
+	}
+
+	// Assets whose target already holds the same content only need the source removed.
+	// A target with different content is never overwritten.
+	var toCopy, verified []nexusapi.Asset
+	totalBytes := int64(0)
+	for _, asset := range srcAssets {
+		target := moveTarget(asset.Path, src, dst)
+		if existing, ok := dstAssets[target]; ok {
+			if sameAssetContent(asset, existing) {
+				result.Existing++
+				verified = append(verified, asset)
+				opts.Logger.VerbosePrintf("= %s (already at %s)\n", asset.Path, target)
+				continue
+			}
+			result.Failed++
+			opts.Logger.Printf("✗ %s (%s exists with different content)\n", asset.Path, target)
+			continue
+		}
+		toCopy = append(toCopy, asset)
+		totalBytes += asset.FileSize
+	}
+
+	// A move is a copy followed by deleting the source once the copy is verified. A dry run
+	// prints the plan, otherwise it is executed.
+	plan := NewPlan("mv")
+	for _, asset := range toCopy {
+		plan.Add(Action{Type: ActionCopy, Source: path.Join(repository, asset.Path), Target: path.Join(repository, moveTarget(asset.Path, src, dst)), Size: asset.FileSize, asset: &asset})
+	}
+	for _, asset := range append(toCopy, verified...) {
+		plan.Add(Action{Type: ActionDeleteRemote, Target: path.Join(repository, asset.Path), asset: &asset})
+	}
+	if opts.DryRun {
+		plan.Print(opts.Logger, opts.PlanFormat)
+		result.Moved = len(toCopy)
+		result.Bytes = totalBytes
+		return result, nil
+	}
+
+	copies := plan.ofType(ActionCopy)
+	bar := progress.NewProgressBarWithCount(newTransferMeter(), totalBytes, "Moving assets", len(copies), !opts.QuietMode)
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var sem chan struct{}
+	if opts.Concurrency > 0 {
+		sem = make(chan struct{}, opts.Concurrency)
+	}
+	var copied []nexusapi.Asset
+	for _, action := range copies {
+		wg.Add(1)
+		go func(asset nexusapi.Asset) {
+			defer wg.Done()
+			if sem != nil {
+				sem <- struct{}{}
+				defer func() { <-sem }()
+			}
+			err := copyAsset(client, asset, repository, moveTarget(asset.Path, src, dst), bar)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				result.Failed++
+				opts.Logger.Printf("✗ %s (copy failed: %v)\n", asset.Path, err)
+				return
+			}
+			copied = append(copied, asset)
+			bar.IncrementFile()
+		}(*action.asset)
+	}
+	wg.Wait()
+	bar.Finish()
+
+	// Verify the copies against a fresh listing of the destination before deleting anything
+	if len(copied) > 0 {
+		dstAssets, err = listMoveDestination(client, repository, dst)
+		if err != nil {
+			return result, fmt.Errorf("%w; no source assets were deleted", err)
+		}
+	}
+	for _, asset := range copied {
+		target := moveTarget(asset.Path, src, dst)
+		if existing, ok := dstAssets[target]; !ok || !sameAssetContent(asset, existing) {
+			result.Failed++
+			opts.Logger.Printf("✗ %s (copy at %s could not be verified, source kept)\n", asset.Path, target)
+			continue
+		}
+		verified = append(verified, asset)
+		result.Moved++
+		result.Bytes += asset.FileSize
+	}
+
+	// Only the sources whose copy was verified are deleted
+	deletable := make(map[string]bool, len(verified))
+	for _, asset := range verified {
+		deletable[asset.ID] = true
+	}
+	for _, action := range plan.ofType(ActionDeleteRemote) {
+		asset := action.asset
+		if !deletable[asset.ID] {
+			continue
+		}
+		if err := client.DeleteAsset(asset.ID); err != nil {
+			result.Failed++
+			opts.Logger.Printf("✗ %s (copied, but deleting the source failed: %v)\n", asset.Path, err)
+			continue
+		}
+		opts.Logger.VerbosePrintf("✓ %s -> %s\n", asset.Path, moveTarget(asset.Path, src, dst))
+	}
+
+	return result, nil
+}
+
+// listMoveSources lists the asset src, or the assets below the folder src, sorted by path
+func listMoveSources(client *nexusapi.Client, repository, src string) ([]nexusapi.Asset, error) {
+	listed, err := client.ListAssets(repository, src, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s/%s: %w", repository, src, err)
+	}
+	// Never include a sibling that merely shares the prefix, e.g. builds/2024.10 for builds/2024.1
+	var assets []nexusapi.Asset
+	for _, asset := range listed {
+		if assetPath := strings.Trim(asset.Path, "/"); assetPath == src || strings.HasPrefix(assetPath, src+"/") {
+			assets = append(assets, asset)
+		}
+	}
+	// The listing searches below a folder, so a single asset is looked up by its path
+	if len(assets) == 0 {
+		asset, err := client.GetAssetByPath(repository, src)
+		if err != nil && !errors.Is(err, nexusapi.ErrAssetNotFound) {
+			return nil, fmt.Errorf("failed to look up %s/%s: %w", repository, src, err)
+		}
+		if asset == nil {
+			return nil, fmt.Errorf("no assets found in %s/%s", repository, src)
+		}
+		assets = append(assets, *asset)
+	}
+	sort.Slice(assets, func(i, j int) bool { return assets[i].Path < assets[j].Path })
+	return assets, nil
+}
+
+// listMoveDestination lists the asset dst, or the assets below the folder dst, keyed by
+// their path without a leading slash
+func listMoveDestination(client *nexusapi.Client, repository, dst string) (map[string]nexusapi.Asset, error) {
+	assets, err := client.ListAssets(repository, dst, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s/%s: %w", repository, dst, err)
+	}
+	if len(assets) == 0 {
+		asset, err := client.GetAssetByPath(repository, dst)
+		if err != nil && !errors.Is(err, nexusapi.ErrAssetNotFound) {
+			return nil, fmt.Errorf("failed to look up %s/%s: %w", repository, dst, err)
+		}
+		if asset != nil {
+			assets = append(assets, *asset)
+		}
+	}
+	byPath := make(map[string]nexusapi.Asset, len(assets))
+	for _, asset := range assets {
+		byPath[strings.TrimLeft(asset.Path, "/")] = asset
+	}
+	return byPath, nil
+}
+
+// copyAsset copies one asset to target in repository, reporting progress to bar. The
+// bytes of a copy made on the server count as skipped, since they were not transferred.
+func copyAsset(client *nexusapi.Client, asset nexusapi.Asset, repository, target string, bar *progress.ProgressBarWithCount) error {
+	bar.StartFile(asset.Path)
+	defer bar.FinishFile(asset.Path)
+	onServer, err := client.CopyAsset(asset, repository, target, bar)
+	if onServer {
+		bar.Skip(asset.FileSize)
+	}
+	return err
+}
+
+// MoveMain moves src to dst, both of the form repository/path in the same repository,
+// and prints a summary. It exits with status 1 on failure.
+func MoveMain(src, dst string, config *config.Config, opts *MoveOptions) {
+	srcRepository, srcPath, ok := util.ParseRepositoryPath(src)
+	if !ok {
+		fmt.Println("Error: The src argument must be in the form 'repository/path'.")
+		os.Exit(exitcode.Error)
+	}
+	dstRepository, dstPath, ok := util.ParseRepositoryPath(dst)
+	if !ok {
+		fmt.Println("Error: The dest argument must be in the form 'repository/path'.")
+		os.Exit(exitcode.Error)
+	}
+	if srcRepository != dstRepository {
+		fmt.Printf("Error: mv only moves assets within a repository, got '%s' and '%s' (use mirror to copy between repositories)\n", srcRepository, dstRepository)
+		os.Exit(exitcode.Error)
+	}
+
+	opts.Logger.Printf("Moving %s -> %s\n", src, dst)
+	result, err := move(srcRepository, srcPath, dstPath, config, opts)
+	if err != nil {
+		fmt.Println("Move error:", err)
+		os.Exit(exitcode.Error)
+	}
+
+	prefix := ""
+	if opts.DryRun {
+		prefix = "Dry-run: would have "
+	}
+	summary := fmt.Sprintf("%smoved: %d (%s), already at destination: %d", prefix, result.Moved, util.FormatByteSize(result.Bytes), result.Existing)
+	if result.Failed > 0 {
+		summary += fmt.Sprintf(", failed: %d (left in place)", result.Failed)
+	}
+	opts.Logger.Println(summary)
+	if result.Failed > 0 {
+		os.Exit(exitcode.Error)
+	}
+}
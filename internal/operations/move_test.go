@@ -0,0 +1,139 @@
+package operations
+
+import (
+	"io"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/tympanix/nexus-cli/internal/config"
+	"github.com/tympanix/nexus-cli/internal/nexusapi"
+	"github.com/tympanix/nexus-cli/internal/util"
+)
+
+func newMoveTest(t *testing.T) (*nexusapi.MockNexusServer, *config.Config) {
+	t.Helper()
+	server := nexusapi.NewMockNexusServer()
+	t.Cleanup(server.Close)
+	server.StoreUploads = true
+	server.AddAsset("raw", "/builds/2024.1/app.bin", nexusapi.Asset{}, []byte("app"))
+	server.AddAsset("raw", "/builds/2024.1/docs/readme.txt", nexusapi.Asset{}, []byte("readme"))
+	server.AddAsset("raw", "/builds/2024.10/app.bin", nexusapi.Asset{}, []byte("other build"))
+	return server, &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+}
+
+func remainingAssets(server *nexusapi.MockNexusServer) []string {
+	var paths []string
+	for _, asset := range server.Assets {
+		paths = append(paths, asset.Path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func TestMoveFolder(t *testing.T) {
+	server, config := newMoveTest(t)
+
+	opts := &MoveOptions{Logger: util.NewLogger(io.Discard), QuietMode: true}
+	result, err := move("raw", "builds/2024.1", "releases/2024.1", config, opts)
+	if err != nil {
+		t.Fatalf("move failed: %v", err)
+	}
+	if result.Moved != 2 || result.Failed != 0 || result.Bytes != 9 {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+
+	expected := []string{"/builds/2024.10/app.bin", "/releases/2024.1/app.bin", "/releases/2024.1/docs/readme.txt"}
+	if got := remainingAssets(server); strings.Join(got, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected assets %v, got %v", expected, got)
+	}
+	if uploaded := findUpload(server, "/releases/2024.1/docs/readme.txt"); uploaded == nil || string(uploaded.Content) != "readme" {
+		t.Errorf("Expected readme.txt to be copied with its content, got %+v", uploaded)
+	}
+}
+
+// TestMoveFile checks that a single asset, which the folder listing does not find, is moved
+func TestMoveFile(t *testing.T) {
+	server, config := newMoveTest(t)
+
+	opts := &MoveOptions{Logger: util.NewLogger(io.Discard), QuietMode: true}
+	result, err := move("raw", "builds/2024.1/app.bin", "releases/app-2024.1.bin", config, opts)
+	if err != nil {
+		t.Fatalf("move failed: %v", err)
+	}
+	if result.Moved != 1 || result.Failed != 0 || result.Bytes != 3 {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+
+	expected := []string{"/builds/2024.1/docs/readme.txt", "/builds/2024.10/app.bin", "/releases/app-2024.1.bin"}
+	if got := remainingAssets(server); strings.Join(got, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected assets %v, got %v", expected, got)
+	}
+}
+
+func TestMoveDryRun(t *testing.T) {
+	server, config := newMoveTest(t)
+
+	opts := &MoveOptions{Logger: util.NewLogger(io.Discard), QuietMode: true, DryRun: true}
+	result, err := move("raw", "builds/2024.1", "releases/2024.1", config, opts)
+	if err != nil {
+		t.Fatalf("move failed: %v", err)
+	}
+	if result.Moved != 2 {
+		t.Errorf("Expected 2 assets to be reported, got %+v", result)
+	}
+	if len(server.GetUploadedFiles()) != 0 || len(server.GetDeletedAssets()) != 0 {
+		t.Error("Expected no changes in dry-run mode")
+	}
+}
+
+// TestMoveKeepsUnverifiedSources checks that nothing is deleted when a copy cannot be confirmed
+func TestMoveKeepsUnverifiedSources(t *testing.T) {
+	server, config := newMoveTest(t)
+	server.StoreUploads = false // The copies never show up in the destination listing
+
+	opts := &MoveOptions{Logger: util.NewLogger(io.Discard), QuietMode: true}
+	result, err := move("raw", "builds/2024.1", "releases/2024.1", config, opts)
+	if err != nil {
+		t.Fatalf("move failed: %v", err)
+	}
+	if result.Moved != 0 || result.Failed != 2 {
+		t.Errorf("Expected both assets to fail verification, got %+v", result)
+	}
+	if len(server.GetDeletedAssets()) != 0 {
+		t.Error("Expected no source to be deleted")
+	}
+}
+
+func TestMoveExistingDestination(t *testing.T) {
+	server, config := newMoveTest(t)
+	// Left behind by an interrupted run, and a different file in the way
+	server.AddAsset("raw", "/releases/2024.1/app.bin", nexusapi.Asset{}, []byte("app"))
+	server.AddAsset("raw", "/releases/2024.1/docs/readme.txt", nexusapi.Asset{}, []byte("changed"))
+
+	opts := &MoveOptions{Logger: util.NewLogger(io.Discard), QuietMode: true}
+	result, err := move("raw", "builds/2024.1", "releases/2024.1", config, opts)
+	if err != nil {
+		t.Fatalf("move failed: %v", err)
+	}
+	if result.Existing != 1 || result.Failed != 1 || result.Moved != 0 {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+	if len(server.GetUploadedFiles()) != 0 {
+		t.Error("Expected an asset with different content not to be overwritten")
+	}
+	if deleted := server.GetDeletedAssets(); len(deleted) != 1 || deleted[0] != "raw:/builds/2024.1/app.bin" {
+		t.Errorf("Expected only the source of the existing copy to be deleted, got %v", deleted)
+	}
+}
+
+func TestMoveRejectsOverlap(t *testing.T) {
+	_, config := newMoveTest(t)
+
+	opts := &MoveOptions{Logger: util.NewLogger(io.Discard), QuietMode: true}
+	for _, dst := range []string{"builds/2024.1", "builds/2024.1/old", "builds"} {
+		if _, err := move("raw", "builds/2024.1", dst, config, opts); err == nil {
+			t.Errorf("Expected moving to %s to be rejected", dst)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package operations
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// NonEmptyPolicy controls what happens when the download destination already has content
+type NonEmptyPolicy string
+
+const (
+	NonEmptyMerge NonEmptyPolicy = "merge" // Download into the existing content (default)
+	NonEmptyFail  NonEmptyPolicy = "fail"  // Abort before downloading anything
+	NonEmptyClean NonEmptyPolicy = "clean" // Remove the existing content first, after confirmation
+)
+
+// ParseNonEmptyPolicy parses a string into a NonEmptyPolicy
+func ParseNonEmptyPolicy(s string) (NonEmptyPolicy, error) {
+	switch strings.ToLower(s) {
+	case "", "merge":
+		return NonEmptyMerge, nil
+	case "fail":
+		return NonEmptyFail, nil
+	case "clean":
+		return NonEmptyClean, nil
+	default:
+		return "", fmt.Errorf("unsupported non-empty destination policy '%s': must be one of: fail, merge, clean", s)
+	}
+}
+
+// applyNonEmptyPolicy checks destDir against opts.OnNonEmpty before anything is downloaded,
+// once the listing and the checks on it succeeded.
+// A destination that does not exist yet counts as empty. With NonEmptyClean the entries of
+// destDir are removed once confirmed on opts.in, or right away with opts.Yes.
+func applyNonEmptyPolicy(destDir string, opts *DownloadOptions) error {
+	if opts.OnNonEmpty == "" || opts.OnNonEmpty == NonEmptyMerge {
+		return nil
+	}
+	entries, err := os.ReadDir(destDir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+
+	if opts.OnNonEmpty == NonEmptyFail {
+		return fmt.Errorf("destination %s is not empty (%d entries); use --on-nonempty merge or clean to download anyway", destDir, len(entries))
+	}
+
+	if opts.DryRun {
+		for _, entry := range entries {
+			opts.plan.Add(Action{Type: ActionDeleteLocal, Target: filepath.Join(destDir, entry.Name())})
+		}
+		opts.Logger.Printf("Dry-run mode: Would remove %d entries from %s before downloading\n", len(entries), destDir)
+		return nil
+	}
+	if !opts.Yes {
+		in := opts.in
+		if in == nil {
+			in = os.Stdin
+		}
+		fmt.Printf("Remove %d entries from %s before downloading? [y/N]: ", len(entries), destDir)
+		line, _ := bufio.NewReader(in).ReadString('\n')
+		answer := strings.ToLower(strings.TrimSpace(line))
+		if answer != "y" && answer != "yes" {
+			return fmt.Errorf("aborted, %s was not cleaned (use --yes to clean without confirmation)", destDir)
+		}
+	}
+
+	for _, entry := range entries {
+		if err := os.RemoveAll(filepath.Join(destDir, entry.Name())); err != nil {
+			return fmt.Errorf("failed to clean %s: %w", destDir, err)
+		}
+	}
+	opts.Logger.VerbosePrintf("Removed %d entries from %s\n", len(entries), destDir)
+	return nil
+}
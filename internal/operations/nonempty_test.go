@@ -0,0 +1,127 @@
+package operations
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tympanix/nexus-cli/internal/config"
+	"github.com/tympanix/nexus-cli/internal/nexusapi"
+	"github.com/tympanix/nexus-cli/internal/util"
+)
+
+// newNonEmptyTest returns a destination holding an unrelated file and a folder
+func newNonEmptyTest(t *testing.T) (*config.Config, string) {
+	t.Helper()
+	server := nexusapi.NewMockNexusServer()
+	t.Cleanup(server.Close)
+	server.AddAsset("raw", "/release/app.bin", nexusapi.Asset{}, []byte("app"))
+
+	destDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(destDir, "notes.txt"), []byte("local"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(destDir, "old", "build"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}, destDir
+}
+
+func TestDownloadOnNonEmptyFail(t *testing.T) {
+	config, destDir := newNonEmptyTest(t)
+
+	opts := &DownloadOptions{Logger: util.NewLogger(io.Discard), QuietMode: true, Recursive: true, OnNonEmpty: NonEmptyFail}
+	if status := downloadFolder("raw/release", destDir, config, opts); status != DownloadError {
+		t.Fatalf("Expected download into a non-empty destination to fail, got status %d", status)
+	}
+	if _, err := os.Stat(filepath.Join(destDir, "release", "app.bin")); !os.IsNotExist(err) {
+		t.Error("Expected nothing to be downloaded")
+	}
+
+	emptyDir := filepath.Join(t.TempDir(), "new")
+	if status := downloadFolder("raw/release", emptyDir, config, opts); status != DownloadSuccess {
+		t.Errorf("Expected download into a missing destination to succeed, got status %d", status)
+	}
+}
+
+func TestDownloadOnNonEmptyMerge(t *testing.T) {
+	config, destDir := newNonEmptyTest(t)
+
+	opts := &DownloadOptions{Logger: util.NewLogger(io.Discard), QuietMode: true, Recursive: true, OnNonEmpty: NonEmptyMerge}
+	if status := downloadFolder("raw/release", destDir, config, opts); status != DownloadSuccess {
+		t.Fatalf("Expected download to succeed, got status %d", status)
+	}
+	for _, name := range []string{"notes.txt", "old/build", "release/app.bin"} {
+		if _, err := os.Stat(filepath.Join(destDir, name)); err != nil {
+			t.Errorf("Expected %s to exist: %v", name, err)
+		}
+	}
+}
+
+func TestDownloadOnNonEmptyClean(t *testing.T) {
+	config, destDir := newNonEmptyTest(t)
+
+	opts := &DownloadOptions{Logger: util.NewLogger(io.Discard), QuietMode: true, Recursive: true, OnNonEmpty: NonEmptyClean, in: strings.NewReader("n\n")}
+	if status := downloadFolder("raw/release", destDir, config, opts); status != DownloadError {
+		t.Fatalf("Expected download to be aborted when cleaning is declined, got status %d", status)
+	}
+	if _, err := os.Stat(filepath.Join(destDir, "notes.txt")); err != nil {
+		t.Errorf("Expected local content to be kept when cleaning is declined: %v", err)
+	}
+
+	opts = &DownloadOptions{Logger: util.NewLogger(io.Discard), QuietMode: true, Recursive: true, OnNonEmpty: NonEmptyClean, DryRun: true}
+	if status := downloadFolder("raw/release", destDir, config, opts); status != DownloadSuccess {
+		t.Fatalf("Expected dry run to succeed, got status %d", status)
+	}
+	if _, err := os.Stat(filepath.Join(destDir, "notes.txt")); err != nil {
+		t.Errorf("Expected local content to be kept in dry-run mode: %v", err)
+	}
+
+	opts = &DownloadOptions{Logger: util.NewLogger(io.Discard), QuietMode: true, Recursive: true, OnNonEmpty: NonEmptyClean, in: strings.NewReader("y\n")}
+	if status := downloadFolder("raw/release", destDir, config, opts); status != DownloadSuccess {
+		t.Fatalf("Expected download to succeed, got status %d", status)
+	}
+	entries, err := os.ReadDir(destDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "release" {
+		t.Errorf("Expected only the downloaded folder in the destination, got %v", entries)
+	}
+}
+
+func TestDownloadOnNonEmptyCleanAfterChecks(t *testing.T) {
+	config, destDir := newNonEmptyTest(t)
+
+	// A listing that finds nothing or fails a check leaves the destination untouched
+	for name, opts := range map[string]*DownloadOptions{
+		"no assets":      {Logger: util.NewLogger(io.Discard), QuietMode: true, Recursive: true, OnNonEmpty: NonEmptyClean, Yes: true},
+		"min files":      {Logger: util.NewLogger(io.Discard), QuietMode: true, Recursive: true, OnNonEmpty: NonEmptyClean, Yes: true, MinFiles: 100},
+		"max total size": {Logger: util.NewLogger(io.Discard), QuietMode: true, Recursive: true, OnNonEmpty: NonEmptyClean, Yes: true, MaxTotalSize: 1},
+	} {
+		src := "raw/release"
+		if name == "no assets" {
+			src = "raw/missing"
+		}
+		if status := downloadFolder(src, destDir, config, opts); status == DownloadSuccess {
+			t.Errorf("%s: expected the download to fail", name)
+		}
+		if _, err := os.Stat(filepath.Join(destDir, "notes.txt")); err != nil {
+			t.Errorf("%s: expected local content to be kept: %v", name, err)
+		}
+	}
+}
+
+func TestParseNonEmptyPolicy(t *testing.T) {
+	if policy, err := ParseNonEmptyPolicy(""); err != nil || policy != NonEmptyMerge {
+		t.Errorf("Expected merge to be the default, got %q, %v", policy, err)
+	}
+	if policy, err := ParseNonEmptyPolicy("Clean"); err != nil || policy != NonEmptyClean {
+		t.Errorf("Expected clean, got %q, %v", policy, err)
+	}
+	if _, err := ParseNonEmptyPolicy("wipe"); err == nil {
+		t.Error("Expected an error for an unknown policy")
+	}
+}
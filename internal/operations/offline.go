@@ -0,0 +1,43 @@
+package operations
+
+import (
+	"errors"
+	"net"
+)
+
+// isConnectivityError reports whether err means Nexus could not be reached at all: the
+// connection could not be made, the host name did not resolve or the request timed out.
+// Other errors of a request, e.g. a response that cannot be read, are not.
+func isConnectivityError(err error) bool {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return true
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// offline reports whether a dry run does without Nexus, because of --offline or because
+// an earlier request could not reach it
+func (opts *UploadOptions) offline() bool {
+	return opts.DryRun && opts.Offline
+}
+
+// continueOffline reports whether a dry run does without Nexus after the request that
+// failed with err. A dry run that cannot reach the server carries on offline instead of
+// failing from then on, and warns once that the remote state is not evaluated.
+func (opts *UploadOptions) continueOffline(err error) bool {
+	if opts.offline() {
+		return true
+	}
+	if !opts.DryRun || !isConnectivityError(err) {
+		return false
+	}
+	opts.Offline = true
+	opts.Logger.Printf("Warning: cannot reach Nexus, continuing the dry run offline: %v\n", err)
+	return true
+}
@@ -0,0 +1,43 @@
+package operations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"testing"
+)
+
+func TestIsConnectivityError(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedURL := "http://" + listener.Addr().String()
+	listener.Close()
+	_, refused := http.Get(closedURL)
+
+	for _, tc := range []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{"connection refused", refused, true},
+		{"dial", &url.Error{Op: "Get", URL: "http://nexus", Err: &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}}, true},
+		{"DNS", &url.Error{Op: "Get", URL: "http://nexus", Err: &net.DNSError{Err: "no such host", Name: "nexus", IsNotFound: true}}, true},
+		{"timeout", &url.Error{Op: "Get", URL: "http://nexus", Err: context.DeadlineExceeded}, true},
+		{"wrapped dial", fmt.Errorf("upload failed: %w", &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}), true},
+		{"unexpected EOF", &url.Error{Op: "Get", URL: "http://nexus", Err: io.ErrUnexpectedEOF}, false},
+		{"connection reset", &url.Error{Op: "Put", URL: "http://nexus", Err: &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}}, false},
+		{"server error", errors.New("upload failed with status 500"), false},
+		{"nil", nil, false},
+	} {
+		if got := isConnectivityError(tc.err); got != tc.expected {
+			t.Errorf("%s: isConnectivityError(%v) = %v, expected %v", tc.name, tc.err, got, tc.expected)
+		}
+	}
+}
@@ -1,8 +1,14 @@
 package operations
 
 import (
+	"io"
+	"os"
+	"time"
+
 	"github.com/tympanix/nexus-cli/internal/archive"
 	"github.com/tympanix/nexus-cli/internal/checksum"
+	"github.com/tympanix/nexus-cli/internal/exitcode"
+	"github.com/tympanix/nexus-cli/internal/output"
 	"github.com/tympanix/nexus-cli/internal/util"
 )
 
@@ -13,12 +19,44 @@ type UploadOptions struct {
 	Force             bool
 	Logger            util.Logger
 	QuietMode         bool
-	DryRun            bool           // Perform a dry-run without actual upload
-	Compress          bool           // Enable compression (tar.gz, tar.zst, or zip)
-	CompressionFormat archive.Format // Compression format to use (gzip, zstd, or zip)
-	GlobPattern       string         // Optional glob pattern(s) to filter files (comma-separated, supports negation with !)
-	KeyFromFile       string         // Path to file to compute hash from for {key} template
+	DryRun            bool                  // Perform a dry-run without actual upload
+	Compress          bool                  // Enable compression (tar.gz, tar.zst, or zip)
+	CompressionFormat archive.Format        // Compression format to use (gzip, zstd, or zip)
+	GlobPattern       string                // Optional glob pattern(s) to filter files (comma-separated, supports negation with !)
+	KeyFromFile       string                // Path to file to compute hash from for {key} template
+	Flatten           bool                  // Upload all files directly into the destination, dropping local subdirectories
+	FlattenOnConflict FlattenConflictMode   // How to handle files that flatten onto the same remote path
+	GlobDebug         bool                  // Log which glob pattern included or excluded each file
+	Strict            bool                  // Fail instead of skipping files that cannot be read
+	AllowExtensions   []string              // Only upload files with one of these extensions (empty = all)
+	DenyExtensions    []string              // Never upload files with one of these extensions
+	OnDeniedExt       DeniedExtensionPolicy // What to do with files rejected by AllowExtensions/DenyExtensions
+	Watch             bool                  // Keep running and re-upload whenever files in the source change
+	WatchInterval     time.Duration         // Poll for changes at this interval instead of using filesystem notifications
+	Routes            []UploadRoute         // Send files matching a route pattern to its destination instead (first match wins)
+	BuildnumStart     int                   // Build number for {buildnum} when the destination folder has no numbered entries yet
+	SkipWriteCheck    bool                  // Upload without first checking that the destination repositories accept uploads
+	PreserveMtime     bool                  // Upload a metadata manifest recording the modification time and mode of each file
+	Append            bool                  // Merge the files into the existing remote archive instead of replacing it
+	Manifest          bool                  // With Compress, also upload a manifest listing each file in the archive with its checksum
+	BatchSize         int                   // Maximum number of files per upload request (0 = no limit)
+	MaxRequestBytes   int64                 // Maximum file content per upload request; larger files are sent alone (0 = no limit)
+	Offline           bool                  // With DryRun, never contact Nexus and list all files as candidates without checking the remote state
+	WaitForWritable   time.Duration         // Wait up to this long for Nexus to leave read-only mode before uploading (0 = do not wait)
+	ChunkSize         int64                 // Upload files larger than this in parts of this size that can be resumed (0 = never)
+	Compare           CompareMode           // How to decide that an existing asset is up to date (default: checksum, or existence with SkipChecksum)
+	Queue             string                // Record the upload in this queue directory instead of failing when Nexus cannot be reached ("" = fail)
+	PlanFormat        PlanFormat            // How DryRun prints the planned actions (default: text)
+	ContentTypes      map[string]string     // Content-Type of uploaded files by normalized extension, set with SetContentType (unmapped files are detected by Nexus)
+	Attributes        map[string]string     // Attributes stored for every uploaded file, set with SetAttribute (nil = none)
+	DedupeCheck       bool                  // Search the destination repository for the content of each file to upload
+	DedupeReport      string                // Write a JSON report of the files whose content already exists at other paths to this file (implies DedupeCheck)
 	checksumValidator checksum.Validator
+	streamHooks       *uploadStreamHooks
+	meter             *transferMeter
+	filtered          output.FilterCounts // Files the filters excluded, reported by the next summary
+	duplicates        []DuplicateContent  // Files whose content exists at other paths, for DedupeReport
+	digests           map[string]string   // SHA-256 digests computed by the comparison, reused by the content search
 }
 
 // SetChecksumAlgorithm validates and sets the checksum algorithm
@@ -33,6 +71,16 @@ func (opts *UploadOptions) SetChecksumAlgorithm(algorithm string) error {
 	return nil
 }
 
+// SetGlobPattern validates and sets the glob pattern used to filter files
+// Returns an error naming the offending sub-pattern if the pattern is malformed
+func (opts *UploadOptions) SetGlobPattern(pattern string) error {
+	if err := util.ValidateGlobPattern(pattern); err != nil {
+		return err
+	}
+	opts.GlobPattern = pattern
+	return nil
+}
+
 // DownloadOptions holds options for download operations
 type DownloadOptions struct {
 	ChecksumAlgorithm string
@@ -42,13 +90,87 @@ type DownloadOptions struct {
 	QuietMode         bool
 	DryRun            bool // Perform a dry-run without actual download
 	Flatten           bool
+	FlattenOnConflict FlattenConflictMode // How to handle assets that flatten onto the same local path
 	DeleteExtra       bool
-	Compress          bool           // Enable decompression (tar.gz, tar.zst, or zip)
-	CompressionFormat archive.Format // Compression format to use (gzip, zstd, or zip)
-	GlobPattern       string         // Optional glob pattern(s) to filter files (comma-separated, supports negation with !)
-	KeyFromFile       string         // Path to file to compute hash from for {key} template
-	Recursive         bool           // Download folder recursively (default: false for single file)
+	Compress          bool                  // Enable decompression (tar.gz, tar.zst, or zip)
+	CompressionFormat archive.Format        // Compression format to use (gzip, zstd, or zip)
+	GlobPattern       string                // Optional glob pattern(s) to filter files (comma-separated, supports negation with !)
+	KeyFromFile       string                // Path to file to compute hash from for {key} template
+	Select            util.SelectMode       // How {latest} placeholders without a mode pick a name (default: semver)
+	Recursive         bool                  // Download folder recursively (default: false for single file)
+	Depth             int                   // Only download assets this many levels below the source folder (0 = unlimited); implies a folder download
+	Limit             int                   // Only download the first this many assets that pass the filters (0 = unlimited); disables DeleteExtra
+	MinFiles          int                   // Fail with DownloadTooFewAssets if fewer assets pass the filters (0 = no minimum)
+	MaxTotalSize      int64                 // Fail before downloading if the assets to download are larger in total, in bytes (0 = unlimited)
+	NoSpaceCheck      bool                  // Skip the check that the destination has room for the files to download
+	NoPathCheck       bool                  // Skip the check that every local path is within the length limits of the system
+	LongPaths         bool                  // Write below the destination through a \\?\ path on Windows, lifting the MAX_PATH limit
+	Concurrency       int                   // Maximum number of parallel file downloads (0 = unlimited)
+	MaxRate           int64                 // Maximum combined download rate in bytes per second (0 = unlimited)
+	TreeChecksum      bool                  // Print a root checksum over the whole destination tree after download
+	GlobDebug         bool                  // Log which glob pattern included or excluded each file
+	CacheDir          string                // Shared cache directory to restore assets from and populate after download
+	OnConflict        ConflictPolicy        // How to handle local files whose content differs from Nexus (default: overwrite)
+	CheckOnline       bool                  // Check repository status first and skip offline members of a group
+	Verify            VerifyLevel           // How to verify downloaded files: checksum (default), size or none
+	Compare           CompareMode           // How to decide that an existing local file is up to date (default: checksum, or existence with SkipChecksum)
+	Sort              SortKey               // Order in which assets are listed and downloaded (default: name)
+	Direction         SortDirection         // Ascending (default) or descending order for Sort
+	PreserveMtime     bool                  // Restore modification times and modes from the metadata manifest uploaded with the files
+	ChecksumFile      string                // Local path or repository/path of a sha256sum-style file to validate downloaded files against
+	ChecksumManifest  string                // repository/path of a sha256sum-style manifest in Nexus to validate downloaded files against, like ChecksumFile but never read locally
+	OnMissingChecksum MissingChecksumPolicy // What to do with downloaded files that ChecksumFile or ChecksumManifest does not list (default: error)
+	ToArchive         string                // Stream the assets into this local archive instead of writing individual files
+	FileDestination   bool                  // Write a single asset to the destination path itself, unless it is an existing directory or ends in a path separator
+	OnNonEmpty        NonEmptyPolicy        // What to do if the destination already has content (default: merge)
+	Yes               bool                  // Clean the destination for OnNonEmpty without asking for confirmation
+	Decisions         *FileDecisions        // If set, collects whether each file was downloaded, skipped or restored from cache
+	Digests           *FileDigests          // If set, collects the checksums of downloaded files, computed while they are written
+	Expected          *ExpectedFiles        // If set, files are compared against and verified with these digests instead of the checksums reported by Nexus
+	AllowMissing      bool                  // Only record the Expected files Nexus no longer lists instead of failing with DownloadMissing
+	WaitForAvailable  time.Duration         // Wait up to this long for Nexus to be available before downloading (0 = do not wait)
+	WaitLock          time.Duration         // Wait up to this long for another nexuscli-go process to release the lock on the destination (0 = fail at once)
+	Chunked           bool                  // Reassemble files uploaded in parts with --chunked, resuming from parts already downloaded
+	Resume            bool                  // Keep the partial file of a failed download and resume it, verifying its blocks, on the next run
+	VerifySignature   bool                  // Verify every downloaded file against its detached OpenPGP signature (<file>.asc) in Nexus
+	PublicKeyFile     string                // OpenPGP public key(s), armored or binary, to verify signatures with
+	PlanFormat        PlanFormat            // How DryRun prints the planned actions (default: text)
+	DirMode           os.FileMode           // Mode of the directories created for downloaded files (0 = 0777 less the umask); new directories keep the setgid bit of their parent
+	Plan              *Plan                 // If set, DryRun adds the planned actions to it instead of printing them, e.g. to print one plan for several downloads
 	checksumValidator checksum.Validator
+	meter             *transferMeter
+	limiter           *rateLimiter
+	cache             *downloadCache
+	conflicts         *conflictLog
+	outOfSpace        *outOfSpace
+	checksums         *checksumList
+	signatures        *signatureVerifier
+	plan              *Plan                   // Actions planned by the current dry run
+	filtered          output.FilterCounts     // Assets the filters excluded from the current download
+	chunked           map[string]*chunkedFile // Files to reassemble from parts by the path of the reassembled file
+	blockSize         int64                   // Size of the verified blocks of a download with Resume (0 = resumeBlockSize)
+	in                io.Reader               // Confirmations are read from here instead of stdin
+}
+
+// startPlan sets up the plan the actions of a dry run are added to
+func (opts *DownloadOptions) startPlan() {
+	opts.plan = opts.Plan
+	if opts.plan == nil {
+		opts.plan = NewPlan("download")
+	}
+}
+
+// printPlan prints the plan of a dry run, unless the caller collects it in Plan
+func (opts *DownloadOptions) printPlan() {
+	opts.plan.sortActions()
+	if opts.Plan == nil {
+		opts.plan.Print(opts.Logger, opts.PlanFormat)
+	}
+}
+
+// recursiveListing reports whether the source is listed as a folder rather than a single file
+func (opts *DownloadOptions) recursiveListing() bool {
+	return opts.Recursive || opts.Depth > 0
 }
 
 // SetChecksumAlgorithm validates and sets the checksum algorithm
@@ -63,11 +185,23 @@ func (opts *DownloadOptions) SetChecksumAlgorithm(algorithm string) error {
 	return nil
 }
 
+// SetGlobPattern validates and sets the glob pattern used to filter files
+// Returns an error naming the offending sub-pattern if the pattern is malformed
+func (opts *DownloadOptions) SetGlobPattern(pattern string) error {
+	if err := util.ValidateGlobPattern(pattern); err != nil {
+		return err
+	}
+	opts.GlobPattern = pattern
+	return nil
+}
+
 // DownloadStatus represents the exit status of a download operation
 type DownloadStatus int
 
 const (
-	DownloadSuccess       DownloadStatus = 0
-	DownloadError         DownloadStatus = 1
-	DownloadNoAssetsFound DownloadStatus = 66
+	DownloadSuccess       DownloadStatus = exitcode.Success
+	DownloadError         DownloadStatus = exitcode.Error
+	DownloadNoAssetsFound DownloadStatus = exitcode.NoFiles
+	DownloadTooFewAssets  DownloadStatus = exitcode.TooFewFiles // Some, but fewer than MinFiles, assets matched
+	DownloadMissing       DownloadStatus = exitcode.Missing     // Expected files are no longer listed in Nexus
 )
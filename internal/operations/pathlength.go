@@ -0,0 +1,70 @@
+package operations
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// maxReportedPaths is the number of offending paths named when local paths are too long
+const maxReportedPaths = 10
+
+// partFileOverhead is the number of characters the temporary file a download is written
+// to adds to the name of the file: "." + name + ".part-" + a random number
+const partFileOverhead = len(".") + len(".part-") + 10
+
+// checkPathLengths fails if any of the local paths, relative to destDir, is longer than
+// this system allows, naming the offending paths. Each file is first written to a
+// temporary file next to it, so that name must fit too. With longPaths the path limit of
+// Windows long paths applies instead; the limit on file names is the same.
+func checkPathLengths(destDir string, localPaths map[string]string, longPaths bool) error {
+	absDest, err := filepath.Abs(destDir)
+	if err != nil {
+		return err
+	}
+	limits := localPathLimits(longPaths)
+
+	var offending []string
+	for _, localPath := range localPaths {
+		full := filepath.Join(absDest, localPath)
+		switch {
+		case pathLength(full)+partFileOverhead > limits.path:
+			offending = append(offending, fmt.Sprintf("%s (%d characters)", full, pathLength(full)))
+		default:
+			names := strings.Split(filepath.ToSlash(localPath), "/")
+			for i, name := range names {
+				length := pathLength(name)
+				if i == len(names)-1 {
+					length += partFileOverhead
+				}
+				if length > limits.name {
+					offending = append(offending, fmt.Sprintf("%s (name of %d characters)", full, pathLength(name)))
+					break
+				}
+			}
+		}
+	}
+	if len(offending) == 0 {
+		return nil
+	}
+
+	sort.Strings(offending)
+	more := ""
+	if len(offending) > maxReportedPaths {
+		more = fmt.Sprintf("\n  ... and %d more", len(offending)-maxReportedPaths)
+	}
+	hint := "use a shorter destination or --flatten"
+	if limits.longPathsSupported && !longPaths {
+		hint = "use --long-paths, a shorter destination or --flatten"
+	}
+	return fmt.Errorf("%d local path(s) would be longer than this system allows (%d characters per path, %d per file name, including %d for the temporary download file); %s, or --no-path-check to skip this check:\n  %s%s",
+		len(offending), limits.path, limits.name, partFileOverhead, hint, strings.Join(offending[:min(len(offending), maxReportedPaths)], "\n  "), more)
+}
+
+// pathLimits are the maximum lengths of a path and of a file name on this system
+type pathLimits struct {
+	path               int
+	name               int
+	longPathsSupported bool // Whether --long-paths lifts the path limit
+}
@@ -0,0 +1,24 @@
+//go:build !windows
+
+package operations
+
+import "runtime"
+
+// localPathLimits returns PATH_MAX, without the terminating NUL, and NAME_MAX. Long
+// paths only exist on Windows, so longPaths does not change them.
+func localPathLimits(longPaths bool) pathLimits {
+	if runtime.GOOS == "darwin" {
+		return pathLimits{path: 1023, name: 255}
+	}
+	return pathLimits{path: 4095, name: 255}
+}
+
+// pathLength returns the length of p in bytes, which Unix systems limit
+func pathLength(p string) int {
+	return len(p)
+}
+
+// longPath returns dir unchanged: paths are only limited to MAX_PATH on Windows
+func longPath(dir string) (string, error) {
+	return dir, nil
+}
@@ -0,0 +1,59 @@
+package operations
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tympanix/nexus-cli/internal/config"
+	"github.com/tympanix/nexus-cli/internal/nexusapi"
+	"github.com/tympanix/nexus-cli/internal/util"
+)
+
+func TestCheckPathLengths(t *testing.T) {
+	destDir := t.TempDir()
+	limits := localPathLimits(false)
+	longName := strings.Repeat("n", limits.name-partFileOverhead+1)
+	deep := strings.Repeat(strings.Repeat("d", 100)+"/", limits.path/100) + "file.txt"
+
+	if err := checkPathLengths(destDir, map[string]string{"a": "dir/file.txt", "b": strings.Repeat("n", limits.name-partFileOverhead)}, false); err != nil {
+		t.Errorf("Expected paths within the limits to pass, got %v", err)
+	}
+	// A folder name may use the whole limit, since only files get a temporary name
+	if err := checkPathLengths(destDir, map[string]string{"a": strings.Repeat("n", limits.name) + "/file.txt"}, false); err != nil {
+		t.Errorf("Expected a folder name within the limit to pass, got %v", err)
+	}
+
+	err := checkPathLengths(destDir, map[string]string{"a": "ok.txt", "b": "dir/" + longName, "c": deep}, false)
+	if err == nil {
+		t.Fatal("Expected the check to fail")
+	}
+	for _, expected := range []string{"2 local path(s)", filepath.Join(destDir, "dir", longName) + " (name of", "--no-path-check"} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected %q in the error, got %v", expected, err)
+		}
+	}
+	if strings.Contains(err.Error(), "ok.txt") {
+		t.Errorf("Expected only offending paths in the error, got %v", err)
+	}
+}
+
+func TestDownloadPathCheck(t *testing.T) {
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+	server.AddAsset("builds", "/app/short.txt", nexusapi.Asset{}, []byte("short"))
+	server.AddAsset("builds", "/app/"+strings.Repeat("x", 300)+".txt", nexusapi.Asset{}, []byte("long"))
+	config := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+
+	var logBuf strings.Builder
+	opts := &DownloadOptions{Logger: util.NewLogger(&logBuf), QuietMode: true, SkipChecksum: true, Recursive: true}
+	if status := downloadFolder("builds/app", t.TempDir(), config, opts); status != DownloadError {
+		t.Fatalf("Expected the path check to fail the download, got status %v", status)
+	}
+	if !strings.Contains(logBuf.String(), "1 local path(s) would be longer than this system allows") {
+		t.Errorf("Expected the path check error, got: %s", logBuf.String())
+	}
+	if server.GetDownloadCount() != 0 {
+		t.Errorf("Expected nothing to be downloaded, got %d downloads", server.GetDownloadCount())
+	}
+}
@@ -0,0 +1,43 @@
+//go:build windows
+
+package operations
+
+import (
+	"path/filepath"
+	"strings"
+	"unicode/utf16"
+)
+
+// longPathPrefix makes Windows APIs accept paths longer than MAX_PATH
+const longPathPrefix = `\\?\`
+
+// localPathLimits returns MAX_PATH, without the terminating NUL, or the limit of paths
+// with the \\?\ prefix if longPaths is set
+func localPathLimits(longPaths bool) pathLimits {
+	if longPaths {
+		return pathLimits{path: 32767 - len(longPathPrefix), name: 255, longPathsSupported: true}
+	}
+	return pathLimits{path: 259, name: 255, longPathsSupported: true}
+}
+
+// pathLength returns the length of p in UTF-16 code units, which Windows limits
+func pathLength(p string) int {
+	return len(utf16.Encode([]rune(p)))
+}
+
+// longPath returns dir as an absolute path with the \\?\ prefix, so that files below it
+// can be created past MAX_PATH
+func longPath(dir string) (string, error) {
+	if strings.HasPrefix(dir, longPathPrefix) {
+		return dir, nil
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	if strings.HasPrefix(abs, `\\`) {
+		// A UNC path \\server\share becomes \\?\UNC\server\share
+		return longPathPrefix + `UNC\` + abs[2:], nil
+	}
+	return longPathPrefix + abs, nil
+}
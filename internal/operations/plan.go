@@ -0,0 +1,202 @@
+package operations
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/tympanix/nexus-cli/internal/nexusapi"
+	"github.com/tympanix/nexus-cli/internal/output"
+	"github.com/tympanix/nexus-cli/internal/util"
+)
+
+// ActionType is the kind of change an Action makes
+type ActionType string
+
+const (
+	ActionUpload       ActionType = "upload"        // Upload a local file to Nexus
+	ActionDownload     ActionType = "download"      // Download an asset to a local file
+	ActionCopy         ActionType = "copy"          // Copy an asset to another path or repository
+	ActionExtract      ActionType = "extract"       // Download an archive and extract it into a local directory
+	ActionDeleteLocal  ActionType = "delete-local"  // Delete a local file or directory
+	ActionDeleteRemote ActionType = "delete-remote" // Delete an asset from Nexus
+)
+
+// verb is the verb the text plan uses for the action type
+func (t ActionType) verb() string {
+	switch t {
+	case ActionDeleteLocal, ActionDeleteRemote:
+		return "delete"
+	default:
+		return string(t)
+	}
+}
+
+// Action is a single change a command makes to Nexus or the local disk
+type Action struct {
+	Type   ActionType `json:"type"`
+	Source string     `json:"source,omitempty"` // Where the data comes from, empty for deletes
+	Target string     `json:"target"`           // The asset or local path that is changed
+	Size   int64      `json:"size,omitempty"`   // Bytes transferred, if known
+
+	// What the command executes the action with, not part of the printed plan
+	asset     *nexusapi.Asset // The asset that is copied or deleted
+	relPath   string          // The path of the asset below the base path of the command
+	localPath string          // The local file that is uploaded
+}
+
+// String returns the action as a line of the text plan, e.g. "Would upload: a.txt -> raw/a.txt"
+func (a Action) String() string {
+	if a.Source == "" {
+		return fmt.Sprintf("Would %s: %s", a.Type.verb(), a.Target)
+	}
+	return fmt.Sprintf("Would %s: %s -> %s", a.Type.verb(), a.Source, a.Target)
+}
+
+// Plan is the list of actions a command takes, in the order they are taken. A dry run
+// prints the plan; otherwise the command executes the actions of the same plan. Actions
+// may be added concurrently.
+type Plan struct {
+	Command  string
+	mu       sync.Mutex
+	actions  []Action
+	filtered output.FilterCounts
+}
+
+// NewPlan returns an empty plan of command
+func NewPlan(command string) *Plan {
+	return &Plan{Command: command}
+}
+
+// Add appends actions to the plan
+func (p *Plan) Add(actions ...Action) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.actions = append(p.actions, actions...)
+}
+
+// AddFiltered records files the filters left out of the plan
+func (p *Plan) AddFiltered(counts output.FilterCounts) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.filtered = p.filtered.Add(counts)
+}
+
+// Actions returns the actions of the plan
+func (p *Plan) Actions() []Action {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]Action(nil), p.actions...)
+}
+
+// ofType returns the actions of the plan of type t, in order
+func (p *Plan) ofType(t ActionType) []Action {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var actions []Action
+	for _, action := range p.actions {
+		if action.Type == t {
+			actions = append(actions, action)
+		}
+	}
+	return actions
+}
+
+// sortActions sorts actions of the same type by target, keeping the order of the types.
+// Actions added by concurrent workers are sorted so the plan is the same on every run.
+func (p *Plan) sortActions() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	rank := make(map[ActionType]int)
+	for _, action := range p.actions {
+		if _, ok := rank[action.Type]; !ok {
+			rank[action.Type] = len(rank)
+		}
+	}
+	sort.SliceStable(p.actions, func(i, j int) bool {
+		a, b := p.actions[i], p.actions[j]
+		if a.Type != b.Type {
+			return rank[a.Type] < rank[b.Type]
+		}
+		return a.Target < b.Target
+	})
+}
+
+// planLocalPath returns the path of a local file in a plan, relative to src, the
+// directory or file given on the command line
+func planLocalPath(src, filePath string) string {
+	relPath, err := filepath.Rel(src, filePath)
+	if err != nil || relPath == "." {
+		return filePath
+	}
+	return filepath.ToSlash(relPath)
+}
+
+// PlanFormat is how a dry run prints its plan
+type PlanFormat string
+
+const (
+	PlanText PlanFormat = "text" // One "Would <action>" line per action (default)
+	PlanJSON PlanFormat = "json" // A JSON document with the command and its actions
+)
+
+// ParsePlanFormat parses a string into a PlanFormat
+func ParsePlanFormat(s string) (PlanFormat, error) {
+	switch strings.ToLower(s) {
+	case "", "text":
+		return PlanText, nil
+	case "json":
+		return PlanJSON, nil
+	default:
+		return "", fmt.Errorf("unsupported plan format '%s': must be one of: text, json", s)
+	}
+}
+
+// Render writes the plan to w in format
+func (p *Plan) Render(w io.Writer, format PlanFormat) error {
+	actions := p.Actions()
+	if format == PlanJSON {
+		// Filtered counts are only included if a filter excluded anything
+		var filtered *output.FilterCounts
+		p.mu.Lock()
+		if p.filtered.Total() > 0 {
+			counts := p.filtered
+			filtered = &counts
+		}
+		p.mu.Unlock()
+		data, err := json.MarshalIndent(struct {
+			Command  string               `json:"command"`
+			Actions  []Action             `json:"actions"`
+			Filtered *output.FilterCounts `json:"filtered,omitempty"`
+		}{p.Command, append([]Action{}, actions...), filtered}, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(data))
+		return err
+	}
+	for _, action := range actions {
+		if _, err := fmt.Fprintln(w, action); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Print prints the plan of a dry run. The text plan is printed through logger, so it is
+// silenced with --quiet like the rest of the output. The JSON plan is always printed to
+// stdout, since it was asked for explicitly.
+func (p *Plan) Print(logger util.Logger, format PlanFormat) {
+	if format == PlanJSON {
+		p.Render(os.Stdout, format)
+		return
+	}
+	for _, action := range p.Actions() {
+		logger.Println(action)
+	}
+}
@@ -0,0 +1,144 @@
+package operations
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tympanix/nexus-cli/internal/config"
+	"github.com/tympanix/nexus-cli/internal/nexusapi"
+	"github.com/tympanix/nexus-cli/internal/util"
+)
+
+func TestParsePlanFormat(t *testing.T) {
+	for input, want := range map[string]PlanFormat{"": PlanText, "text": PlanText, "JSON": PlanJSON} {
+		if got, err := ParsePlanFormat(input); err != nil || got != want {
+			t.Errorf("ParsePlanFormat(%q) = %q, %v; expected %q", input, got, err, want)
+		}
+	}
+	if _, err := ParsePlanFormat("yaml"); err == nil {
+		t.Error("Expected an error for an unsupported format")
+	}
+}
+
+func TestPlanRender(t *testing.T) {
+	plan := NewPlan("mv")
+	plan.Add(Action{Type: ActionCopy, Source: "raw/a.txt", Target: "raw/b.txt", Size: 3})
+	plan.Add(Action{Type: ActionDeleteRemote, Target: "raw/a.txt"})
+
+	var text strings.Builder
+	if err := plan.Render(&text, PlanText); err != nil {
+		t.Fatal(err)
+	}
+	if want := "Would copy: raw/a.txt -> raw/b.txt\nWould delete: raw/a.txt\n"; text.String() != want {
+		t.Errorf("Expected text plan %q, got %q", want, text.String())
+	}
+
+	var data strings.Builder
+	if err := plan.Render(&data, PlanJSON); err != nil {
+		t.Fatal(err)
+	}
+	var decoded struct {
+		Command string   `json:"command"`
+		Actions []Action `json:"actions"`
+	}
+	if err := json.Unmarshal([]byte(data.String()), &decoded); err != nil {
+		t.Fatalf("Expected valid JSON, got %v: %s", err, data.String())
+	}
+	if decoded.Command != "mv" || len(decoded.Actions) != 2 || decoded.Actions[1] != (Action{Type: ActionDeleteRemote, Target: "raw/a.txt"}) {
+		t.Errorf("Unexpected JSON plan: %s", data.String())
+	}
+
+	data.Reset()
+	NewPlan("copy").Render(&data, PlanJSON)
+	if !strings.Contains(data.String(), `"actions": []`) {
+		t.Errorf("Expected an empty plan to have an empty list of actions, got %s", data.String())
+	}
+}
+
+// TestPlanSortActions checks that actions are sorted by target within their type, and the
+// types stay in the order they were first added
+func TestPlanSortActions(t *testing.T) {
+	plan := NewPlan("download")
+	plan.Add(
+		Action{Type: ActionDeleteLocal, Target: "out/z"},
+		Action{Type: ActionDownload, Target: "out/b"},
+		Action{Type: ActionDeleteLocal, Target: "out/y"},
+		Action{Type: ActionDownload, Target: "out/a"},
+	)
+	plan.sortActions()
+
+	var targets []string
+	for _, action := range plan.Actions() {
+		targets = append(targets, action.Target)
+	}
+	if got := strings.Join(targets, ","); got != "out/y,out/z,out/a,out/b" {
+		t.Errorf("Unexpected order: %s", got)
+	}
+}
+
+// TestDownloadDryRunPlan tests that a download dry run plans the missing files and, with
+// --delete, the extra local files, without changing anything
+func TestDownloadDryRunPlan(t *testing.T) {
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+	server.AddAsset("repo", "/docs/new.txt", nexusapi.Asset{}, []byte("new"))
+	server.AddAsset("repo", "/docs/kept.txt", nexusapi.Asset{}, []byte("kept"))
+	config := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+
+	destDir := t.TempDir()
+	os.MkdirAll(filepath.Join(destDir, "docs"), 0755)
+	os.WriteFile(filepath.Join(destDir, "docs", "kept.txt"), []byte("kept"), 0644)
+	os.WriteFile(filepath.Join(destDir, "docs", "extra.txt"), []byte("extra"), 0644)
+
+	plan := NewPlan("download")
+	opts := &DownloadOptions{Logger: util.NewLogger(&strings.Builder{}), QuietMode: true, Recursive: true, DryRun: true, DeleteExtra: true, Plan: plan}
+	if err := opts.SetChecksumAlgorithm("sha1"); err != nil {
+		t.Fatal(err)
+	}
+	if status := downloadFolder("repo/docs", destDir, config, opts); status != DownloadSuccess {
+		t.Fatalf("Expected the dry run to succeed, got %v", status)
+	}
+
+	want := []Action{
+		{Type: ActionDownload, Source: "repo/docs/new.txt", Target: filepath.Join(destDir, "docs", "new.txt"), Size: 3},
+		{Type: ActionDeleteLocal, Target: filepath.Join(destDir, "docs", "extra.txt")},
+	}
+	actions := plan.Actions()
+	if len(actions) != len(want) {
+		t.Fatalf("Expected %d actions, got %v", len(want), actions)
+	}
+	for i := range want {
+		if actions[i] != want[i] {
+			t.Errorf("Expected action %d to be %+v, got %+v", i, want[i], actions[i])
+		}
+	}
+	if _, err := os.Stat(filepath.Join(destDir, "docs", "extra.txt")); err != nil {
+		t.Error("Expected the dry run to keep the extra file")
+	}
+	if _, err := os.Stat(filepath.Join(destDir, "docs", "new.txt")); !os.IsNotExist(err) {
+		t.Error("Expected the dry run not to download anything")
+	}
+}
+
+// TestMoveDryRunPlan tests that a move is planned as a copy followed by deleting the source
+func TestMoveDryRunPlan(t *testing.T) {
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+	server.AddAsset("raw", "/builds/1/app.bin", nexusapi.Asset{}, []byte("app"))
+	config := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+
+	var logBuf strings.Builder
+	if _, err := move("raw", "builds/1", "releases/1", config, &MoveOptions{Logger: util.NewLogger(&logBuf), DryRun: true}); err != nil {
+		t.Fatalf("move failed: %v", err)
+	}
+	want := "Would copy: raw/builds/1/app.bin -> raw/releases/1/app.bin\nWould delete: raw/builds/1/app.bin\n"
+	if !strings.Contains(logBuf.String(), want) {
+		t.Errorf("Expected plan %q in output, got: %s", want, logBuf.String())
+	}
+	if len(server.GetDeletedAssets()) != 0 {
+		t.Error("Expected the dry run not to delete anything")
+	}
+}
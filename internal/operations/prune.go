@@ -0,0 +1,274 @@
+package operations
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tympanix/nexus-cli/internal/config"
+	"github.com/tympanix/nexus-cli/internal/exitcode"
+	"github.com/tympanix/nexus-cli/internal/nexusapi"
+	"github.com/tympanix/nexus-cli/internal/util"
+)
+
+// PruneOptions holds options for prune operations
+type PruneOptions struct {
+	Logger      util.Logger
+	QuietMode   bool
+	DryRun      bool          // Show what would be deleted without deleting anything
+	PlanFormat  PlanFormat    // How DryRun prints the planned actions (default: text)
+	Yes         bool          // Delete without asking for confirmation
+	OlderThan   time.Duration // Only delete entries whose newest asset is older than this (0 = any age)
+	KeepLast    int           // Always keep the newest N entries directly below the path (0 = no minimum)
+	GlobPattern string        // Optional glob pattern(s) to select the assets considered (comma-separated, supports negation with !)
+	ReportFile  string        // Write a JSON report of the deleted assets to this file
+}
+
+// SetGlobPattern validates and sets the glob pattern used to filter assets
+// Returns an error naming the offending sub-pattern if the pattern is malformed
+func (opts *PruneOptions) SetGlobPattern(pattern string) error {
+	if err := util.ValidateGlobPattern(pattern); err != nil {
+		return err
+	}
+	opts.GlobPattern = pattern
+	return nil
+}
+
+// pruneEntry is a file or an immediate subdirectory of the pruned path, e.g. one nightly
+// build. Entries are kept or deleted as a whole, so a build is never left half deleted.
+type pruneEntry struct {
+	name   string // Followed by "/" for a subdirectory
+	assets []nexusapi.Asset
+	newest time.Time // Most recent lastModified of the assets; zero if any is unknown
+	size   int64
+}
+
+// PruneReport is written as JSON by --report
+type PruneReport struct {
+	Repository string        `json:"repository"`
+	Path       string        `json:"path"`
+	DryRun     bool          `json:"dryRun"`
+	OlderThan  string        `json:"olderThan,omitempty"`
+	KeepLast   int           `json:"keepLast,omitempty"`
+	Deleted    []PrunedAsset `json:"deleted"` // Assets deleted, or that would be deleted in a dry run
+	Failed     []PrunedAsset `json:"failed,omitempty"`
+}
+
+// PrunedAsset is an asset in a PruneReport
+type PrunedAsset struct {
+	Path         string `json:"path"`
+	ID           string `json:"id"`
+	Size         int64  `json:"size"`
+	LastModified string `json:"lastModified"`
+	Error        string `json:"error,omitempty"`
+}
+
+func newPrunedAsset(asset nexusapi.Asset) PrunedAsset {
+	return PrunedAsset{
+		Path:         strings.TrimLeft(asset.Path, "/"),
+		ID:           asset.ID,
+		Size:         asset.FileSize,
+		LastModified: asset.LastModified,
+	}
+}
+
+// groupPruneEntries groups assets by the file or subdirectory directly below basePath
+func groupPruneEntries(assets []nexusapi.Asset, basePath string) []*pruneEntry {
+	byName := make(map[string]*pruneEntry)
+	var entries []*pruneEntry
+	for _, asset := range assets {
+		name, _, isDir := strings.Cut(getRelativePath(asset.Path, basePath), "/")
+		if isDir {
+			name += "/"
+		}
+		entry, ok := byName[name]
+		if !ok {
+			entry = &pruneEntry{name: name}
+			byName[name] = entry
+			entries = append(entries, entry)
+		}
+		entry.assets = append(entry.assets, asset)
+		entry.size += asset.FileSize
+	}
+
+	for _, entry := range entries {
+		for i, asset := range entry.assets {
+			modified := lastModified(asset)
+			if modified.IsZero() {
+				entry.newest = time.Time{}
+				break
+			}
+			if i == 0 || modified.After(entry.newest) {
+				entry.newest = modified
+			}
+		}
+	}
+	return entries
+}
+
+// planPrune decides which entries to delete. Entries are ranked by their newest asset; the
+// newest opts.KeepLast entries are always kept and of the rest, those not older than
+// opts.OlderThan are kept too. Entries with an asset of unknown age are never deleted.
+func planPrune(entries []*pruneEntry, opts *PruneOptions, now time.Time) (remove, keep, undated []*pruneEntry) {
+	var dated []*pruneEntry
+	for _, entry := range entries {
+		if entry.newest.IsZero() {
+			undated = append(undated, entry)
+		} else {
+			dated = append(dated, entry)
+		}
+	}
+	sort.SliceStable(dated, func(i, j int) bool {
+		if !dated[i].newest.Equal(dated[j].newest) {
+			return dated[i].newest.After(dated[j].newest)
+		}
+		return dated[i].name > dated[j].name
+	})
+
+	for i, entry := range dated {
+		switch {
+		case i < opts.KeepLast:
+			keep = append(keep, entry)
+		case opts.OlderThan > 0 && now.Sub(entry.newest) <= opts.OlderThan:
+			keep = append(keep, entry)
+		default:
+			remove = append(remove, entry)
+		}
+	}
+	return remove, keep, undated
+}
+
+// prune lists the assets below repository/basePath, deletes the entries selected by
+// planPrune after confirmation on in, and returns a report of the deleted assets
+func prune(repository, basePath string, config *config.Config, opts *PruneOptions, in io.Reader, now time.Time) (*PruneReport, error) {
+	if opts.OlderThan < 0 {
+		// A negative age would disable the age filter and delete everything beyond --keep-last
+		return nil, fmt.Errorf("--older-than must not be negative")
+	}
+	if opts.OlderThan <= 0 && opts.KeepLast <= 0 {
+		return nil, fmt.Errorf("at least one of --older-than or --keep-last is required")
+	}
+
+	client := NewClient(config)
+	assets, err := client.ListAssets(repository, basePath, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", path.Join(repository, basePath), err)
+	}
+	assets, _ = dedupeAssets(assets)
+	if opts.GlobPattern != "" {
+		assets, err = filterAssetsByGlob(assets, basePath, opts.GlobPattern)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	remove, keep, undated := planPrune(groupPruneEntries(assets, basePath), opts, now)
+
+	report := &PruneReport{Repository: repository, Path: basePath, DryRun: opts.DryRun, KeepLast: opts.KeepLast, Deleted: []PrunedAsset{}}
+	if opts.OlderThan > 0 {
+		report.OlderThan = opts.OlderThan.String()
+	}
+
+	for _, entry := range keep {
+		opts.Logger.VerbosePrintf("  keep    %s (%d files, %s, modified %s)\n", entry.name, len(entry.assets), util.FormatByteSize(entry.size), entry.newest.Format(time.RFC3339))
+	}
+	for _, entry := range undated {
+		if !opts.QuietMode {
+			opts.Logger.Printf("Warning: keeping %s: an asset has no valid lastModified time\n", entry.name)
+		}
+	}
+	var toDelete []nexusapi.Asset
+	var totalSize int64
+	for _, entry := range remove {
+		opts.Logger.Printf("  delete  %s (%d files, %s, modified %s)\n", entry.name, len(entry.assets), util.FormatByteSize(entry.size), entry.newest.Format(time.RFC3339))
+		toDelete = append(toDelete, entry.assets...)
+		totalSize += entry.size
+	}
+
+	if len(toDelete) == 0 {
+		opts.Logger.Printf("Nothing to prune in %s (%d entries kept)\n", path.Join(repository, basePath), len(keep)+len(undated))
+		return report, nil
+	}
+
+	// A dry run prints the plan, otherwise it is executed after confirmation
+	plan := NewPlan("prune")
+	for _, asset := range toDelete {
+		plan.Add(Action{Type: ActionDeleteRemote, Target: path.Join(repository, asset.Path), Size: asset.FileSize, asset: &asset})
+	}
+	if opts.DryRun {
+		for _, action := range plan.ofType(ActionDeleteRemote) {
+			report.Deleted = append(report.Deleted, newPrunedAsset(*action.asset))
+		}
+		plan.Print(opts.Logger, opts.PlanFormat)
+		opts.Logger.Printf("Dry-run mode: Would delete %d assets in %d entries (%s), keeping %d entries\n", len(toDelete), len(remove), util.FormatByteSize(totalSize), len(keep)+len(undated))
+		return report, nil
+	}
+
+	if !opts.Yes {
+		fmt.Printf("Delete %d assets in %d entries (%s) from %s? [y/N]: ", len(toDelete), len(remove), util.FormatByteSize(totalSize), path.Join(repository, basePath))
+		line, _ := bufio.NewReader(in).ReadString('\n')
+		answer := strings.ToLower(strings.TrimSpace(line))
+		if answer != "y" && answer != "yes" {
+			return nil, fmt.Errorf("aborted, nothing was deleted (use --yes to delete without confirmation)")
+		}
+	}
+
+	for _, action := range plan.ofType(ActionDeleteRemote) {
+		pruned := newPrunedAsset(*action.asset)
+		if err := client.DeleteAsset(action.asset.ID); err != nil {
+			pruned.Error = err.Error()
+			report.Failed = append(report.Failed, pruned)
+			opts.Logger.Printf("✗ %s (delete failed: %v)\n", pruned.Path, err)
+			continue
+		}
+		report.Deleted = append(report.Deleted, pruned)
+		opts.Logger.VerbosePrintf("- %s (deleted)\n", pruned.Path)
+	}
+
+	summary := fmt.Sprintf("Deleted %d assets in %d entries (%s), kept %d entries", len(report.Deleted), len(remove), util.FormatByteSize(totalSize), len(keep)+len(undated))
+	if len(report.Failed) > 0 {
+		summary += fmt.Sprintf(", failed: %d", len(report.Failed))
+	}
+	opts.Logger.Println(summary)
+	return report, nil
+}
+
+// writePruneReport writes the report as indented JSON
+func writePruneReport(report *PruneReport, file string) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, append(data, '\n'), 0644)
+}
+
+// PruneMain prunes the assets below target, given as repository/path. It exits with
+// status 1 on failure, including when some assets could not be deleted.
+func PruneMain(target string, config *config.Config, opts *PruneOptions, in io.Reader) {
+	repository, basePath, ok := util.ParseRepositoryPath(target)
+	if !ok {
+		fmt.Println("Error: The target must be in the form 'repository/folder'.")
+		os.Exit(exitcode.Error)
+	}
+
+	report, err := prune(repository, basePath, config, opts, in, time.Now())
+	if err != nil {
+		fmt.Println("Prune error:", err)
+		os.Exit(exitcode.Error)
+	}
+	if opts.ReportFile != "" {
+		if err := writePruneReport(report, opts.ReportFile); err != nil {
+			fmt.Println("Error: failed to write report:", err)
+			os.Exit(exitcode.Error)
+		}
+	}
+	if len(report.Failed) > 0 {
+		os.Exit(exitcode.Error)
+	}
+}
@@ -0,0 +1,149 @@
+package operations
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tympanix/nexus-cli/internal/config"
+	"github.com/tympanix/nexus-cli/internal/nexusapi"
+	"github.com/tympanix/nexus-cli/internal/util"
+)
+
+var pruneNow = time.Date(2026, 3, 31, 12, 0, 0, 0, time.UTC)
+
+// newPruneTest creates nightly builds 1 to 5 days old, each with two files, plus a build
+// without a valid timestamp
+func newPruneTest(t *testing.T) (*nexusapi.MockNexusServer, *config.Config) {
+	t.Helper()
+	server := nexusapi.NewMockNexusServer()
+	t.Cleanup(server.Close)
+	for days := 1; days <= 5; days++ {
+		modified := pruneNow.AddDate(0, 0, -days).Format(time.RFC3339)
+		build := "/nightly/build-" + string(rune('0'+days))
+		server.AddAsset("raw", build+"/app.bin", nexusapi.Asset{LastModified: modified}, []byte("app"))
+		server.AddAsset("raw", build+"/app.log", nexusapi.Asset{LastModified: modified}, []byte("log"))
+	}
+	server.AddAsset("raw", "/nightly/build-x/app.bin", nexusapi.Asset{LastModified: "unknown"}, []byte("app"))
+	return server, &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+}
+
+func deletedPaths(report *PruneReport) []string {
+	var paths []string
+	for _, asset := range report.Deleted {
+		paths = append(paths, asset.Path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func TestPruneOlderThanKeepsLast(t *testing.T) {
+	server, config := newPruneTest(t)
+
+	opts := &PruneOptions{Logger: util.NewLogger(io.Discard), Yes: true, OlderThan: 36 * time.Hour, KeepLast: 3}
+	report, err := prune("raw", "nightly", config, opts, strings.NewReader(""), pruneNow)
+	if err != nil {
+		t.Fatalf("prune failed: %v", err)
+	}
+
+	expected := []string{"nightly/build-4/app.bin", "nightly/build-4/app.log", "nightly/build-5/app.bin", "nightly/build-5/app.log"}
+	if got := deletedPaths(report); strings.Join(got, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected deleted %v, got %v", expected, got)
+	}
+	if deleted := server.GetDeletedAssets(); len(deleted) != len(expected) {
+		t.Errorf("Expected %d assets deleted on the server, got %d", len(expected), len(deleted))
+	}
+}
+
+func TestPruneOlderThanOnly(t *testing.T) {
+	_, config := newPruneTest(t)
+
+	opts := &PruneOptions{Logger: util.NewLogger(io.Discard), Yes: true, OlderThan: 60 * time.Hour, GlobPattern: "**/*.bin"}
+	report, err := prune("raw", "nightly", config, opts, strings.NewReader(""), pruneNow)
+	if err != nil {
+		t.Fatalf("prune failed: %v", err)
+	}
+
+	expected := []string{"nightly/build-3/app.bin", "nightly/build-4/app.bin", "nightly/build-5/app.bin"}
+	if got := deletedPaths(report); strings.Join(got, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected deleted %v, got %v", expected, got)
+	}
+}
+
+func TestPruneRejectsNegativeAge(t *testing.T) {
+	server, config := newPruneTest(t)
+
+	opts := &PruneOptions{Logger: util.NewLogger(io.Discard), Yes: true, OlderThan: -time.Hour, KeepLast: 1}
+	if _, err := prune("raw", "nightly", config, opts, strings.NewReader(""), pruneNow); err == nil {
+		t.Error("Expected a negative --older-than to be rejected")
+	}
+	if deleted := server.GetDeletedAssets(); len(deleted) != 0 {
+		t.Errorf("Expected nothing to be deleted, got %v", deleted)
+	}
+}
+
+func TestPruneDryRunWritesReport(t *testing.T) {
+	server, config := newPruneTest(t)
+
+	reportFile := filepath.Join(t.TempDir(), "report.json")
+	opts := &PruneOptions{Logger: util.NewLogger(io.Discard), DryRun: true, KeepLast: 4, ReportFile: reportFile}
+	report, err := prune("raw", "nightly", config, opts, strings.NewReader(""), pruneNow)
+	if err != nil {
+		t.Fatalf("prune failed: %v", err)
+	}
+	if len(server.GetDeletedAssets()) != 0 {
+		t.Error("Expected nothing to be deleted in dry-run mode")
+	}
+	if err := writePruneReport(report, reportFile); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(reportFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded PruneReport
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Invalid report: %v", err)
+	}
+	if !decoded.DryRun || len(decoded.Deleted) != 2 || !strings.HasPrefix(decoded.Deleted[0].Path, "nightly/build-5/") {
+		t.Errorf("Unexpected report: %+v", decoded)
+	}
+}
+
+func TestPruneRequiresConfirmation(t *testing.T) {
+	server, config := newPruneTest(t)
+
+	opts := &PruneOptions{Logger: util.NewLogger(io.Discard), KeepLast: 1}
+	if _, err := prune("raw", "nightly", config, opts, strings.NewReader("n\n"), pruneNow); err == nil {
+		t.Error("Expected prune to be aborted when the confirmation is declined")
+	}
+	if _, err := prune("raw", "nightly", config, opts, strings.NewReader(""), pruneNow); err == nil {
+		t.Error("Expected prune to be aborted without input")
+	}
+	if len(server.GetDeletedAssets()) != 0 {
+		t.Fatal("Expected nothing to be deleted without confirmation")
+	}
+
+	report, err := prune("raw", "nightly", config, opts, strings.NewReader("y\n"), pruneNow)
+	if err != nil {
+		t.Fatalf("prune failed: %v", err)
+	}
+	if len(report.Deleted) != 8 {
+		t.Errorf("Expected 8 assets deleted, got %d", len(report.Deleted))
+	}
+}
+
+func TestPruneRequiresRule(t *testing.T) {
+	_, config := newPruneTest(t)
+
+	opts := &PruneOptions{Logger: util.NewLogger(io.Discard), Yes: true}
+	if _, err := prune("raw", "nightly", config, opts, strings.NewReader(""), pruneNow); err == nil {
+		t.Error("Expected an error without --older-than or --keep-last")
+	}
+}
@@ -0,0 +1,404 @@
+package operations
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tympanix/nexus-cli/internal/archive"
+	"github.com/tympanix/nexus-cli/internal/checksum"
+	"github.com/tympanix/nexus-cli/internal/config"
+	"github.com/tympanix/nexus-cli/internal/exitcode"
+	"github.com/tympanix/nexus-cli/internal/nexusapi"
+	"github.com/tympanix/nexus-cli/internal/util"
+)
+
+// QueueEntry is an upload recorded by upload --queue while Nexus could not be reached. It
+// is stored as a JSON file in the queue directory and only removed once the upload has
+// been replayed and verified.
+type QueueEntry struct {
+	ID        string                `json:"-"` // Name of the entry file in the queue directory
+	URL       string                `json:"url"`
+	Source    string                `json:"source"` // Absolute path of the source directory
+	Dest      string                `json:"dest"`   // Destination as given; its templates are expanded on flush
+	QueuedAt  time.Time             `json:"queued_at"`
+	Algorithm string                `json:"algorithm"`
+	Files     map[string]QueuedFile `json:"files"` // Source files by slash-separated path relative to Source
+
+	GlobPattern       string                `json:"glob,omitempty"`
+	AllowExtensions   []string              `json:"allow_ext,omitempty"`
+	DenyExtensions    []string              `json:"deny_ext,omitempty"`
+	OnDeniedExt       DeniedExtensionPolicy `json:"on_denied_ext,omitempty"`
+	Compress          bool                  `json:"compress,omitempty"`
+	CompressionFormat archive.Format        `json:"compress_format,omitempty"`
+	ContentTypes      map[string]string     `json:"content_types,omitempty"`
+	Attributes        map[string]string     `json:"attributes,omitempty"`
+}
+
+// QueuedFile is the state of a source file when its upload was queued
+type QueuedFile struct {
+	Size     int64  `json:"size"`
+	Checksum string `json:"checksum"`
+}
+
+// QueueFlushResult counts what a flush did with the queued uploads
+type QueueFlushResult struct {
+	Flushed int // Uploaded, verified and removed from the queue
+	Skipped int // Left in the queue because the source changed or it was queued for another server
+	Failed  int // Left in the queue because the upload or its verification failed
+}
+
+// queueEntrySuffix is the file extension of queue entries. Entries are written under a
+// temporary dot-name first and renamed, so a crash never leaves half an entry.
+const queueEntrySuffix = ".json"
+
+// queueUpload snapshots the files of src that the upload would send and records the
+// upload to dest in the queue directory opts.Queue
+func queueUpload(src, dest string, config *config.Config, opts *UploadOptions, now time.Time) (*QueueEntry, error) {
+	source, err := filepath.Abs(src)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(source); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("--queue needs a source directory, got %s", src)
+	}
+
+	algorithm := opts.ChecksumAlgorithm
+	if algorithm == "" {
+		algorithm = "sha1"
+	}
+	entry := &QueueEntry{
+		URL:               config.NexusURL,
+		Source:            source,
+		Dest:              dest,
+		QueuedAt:          now,
+		Algorithm:         algorithm,
+		GlobPattern:       opts.GlobPattern,
+		AllowExtensions:   opts.AllowExtensions,
+		DenyExtensions:    opts.DenyExtensions,
+		OnDeniedExt:       opts.OnDeniedExt,
+		Compress:          opts.Compress,
+		CompressionFormat: opts.CompressionFormat,
+		ContentTypes:      opts.ContentTypes,
+		Attributes:        opts.Attributes,
+	}
+	if entry.Files, err = snapshotSource(source, algorithm, opts); err != nil {
+		return nil, err
+	}
+	if len(entry.Files) == 0 {
+		return nil, fmt.Errorf("no files to upload in %s", src)
+	}
+	if err := writeQueueEntry(opts.Queue, entry); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// snapshotSource returns the size and checksum of each file in src that passes the filters
+// of opts, by slash-separated path relative to src
+func snapshotSource(src, algorithm string, opts *UploadOptions) (map[string]QueuedFile, error) {
+	filePaths, unreadable, err := archive.CollectReadableFilesWithGlob(src, opts.GlobPattern)
+	if err != nil {
+		return nil, err
+	}
+	if len(unreadable) > 0 {
+		return nil, fmt.Errorf("cannot read %s: %v", unreadable[0].Path, unreadable[0].Err)
+	}
+	if filePaths, err = filterDeniedExtensions(src, filePaths, opts); err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]QueuedFile, len(filePaths))
+	for _, filePath := range filePaths {
+		info, err := os.Stat(filePath)
+		if err != nil {
+			return nil, err
+		}
+		sum, err := checksum.ComputeChecksum(filePath, algorithm)
+		if err != nil {
+			return nil, err
+		}
+		relPath, _ := filepath.Rel(src, filePath)
+		files[filepath.ToSlash(relPath)] = QueuedFile{Size: info.Size(), Checksum: sum}
+	}
+	return files, nil
+}
+
+// writeQueueEntry stores entry in dir and sets its ID. Entry names start with the time
+// the upload was queued, so they sort in queue order.
+func writeQueueEntry(dir string, entry *QueueEntry) error {
+	if err := util.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, ".entry-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	unique := strings.TrimPrefix(filepath.Base(tmp.Name()), ".entry-")
+	id := entry.QueuedAt.UTC().Format("20060102T150405.000000000Z") + "-" + unique + queueEntrySuffix
+	if err := os.Rename(tmp.Name(), filepath.Join(dir, id)); err != nil {
+		return err
+	}
+	entry.ID = id
+	return nil
+}
+
+// ListQueue returns the entries in the queue directory in the order they were queued. A
+// missing directory is an empty queue.
+func ListQueue(dir string) ([]*QueueEntry, error) {
+	dirEntries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*QueueEntry
+	for _, dirEntry := range dirEntries {
+		name := dirEntry.Name()
+		if dirEntry.IsDir() || strings.HasPrefix(name, ".") || !strings.HasSuffix(name, queueEntrySuffix) {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		entry := &QueueEntry{}
+		if err := json.Unmarshal(data, entry); err != nil {
+			return nil, fmt.Errorf("invalid queue entry %s: %w", filepath.Join(dir, name), err)
+		}
+		entry.ID = name
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// uploadOptions returns the options to replay the entry with, based on opts
+func (entry *QueueEntry) uploadOptions(opts *UploadOptions) (*UploadOptions, error) {
+	entryOpts := *opts
+	entryOpts.GlobPattern = entry.GlobPattern
+	entryOpts.AllowExtensions = entry.AllowExtensions
+	entryOpts.DenyExtensions = entry.DenyExtensions
+	entryOpts.OnDeniedExt = entry.OnDeniedExt
+	entryOpts.Compress = entry.Compress
+	entryOpts.CompressionFormat = entry.CompressionFormat
+	entryOpts.ContentTypes = entry.ContentTypes
+	entryOpts.Attributes = entry.Attributes
+	if !entryOpts.SkipChecksum {
+		if err := entryOpts.SetChecksumAlgorithm(entry.Algorithm); err != nil {
+			return nil, err
+		}
+	}
+	return &entryOpts, nil
+}
+
+// sourceChange describes how the source of the entry differs from when it was queued, or
+// returns "" if it is unchanged
+func (entry *QueueEntry) sourceChange(opts *UploadOptions) (string, error) {
+	if _, err := os.Stat(entry.Source); errors.Is(err, os.ErrNotExist) {
+		return fmt.Sprintf("%s no longer exists", entry.Source), nil
+	}
+	current, err := snapshotSource(entry.Source, entry.Algorithm, opts)
+	if err != nil {
+		return "", err
+	}
+
+	var changes []string
+	for relPath, queued := range entry.Files {
+		file, ok := current[relPath]
+		switch {
+		case !ok:
+			changes = append(changes, relPath+" was removed")
+		case file.Checksum != queued.Checksum:
+			changes = append(changes, relPath+" was modified")
+		}
+	}
+	for relPath := range current {
+		if _, ok := entry.Files[relPath]; !ok {
+			changes = append(changes, relPath+" was added")
+		}
+	}
+	sort.Strings(changes)
+	return strings.Join(changes, ", "), nil
+}
+
+// sameServer reports whether two Nexus URLs name the same server
+func sameServer(a, b string) bool {
+	return strings.EqualFold(strings.TrimRight(a, "/"), strings.TrimRight(b, "/"))
+}
+
+// flushQueue replays the uploads queued in dir, in order. An entry whose source changed
+// since it was queued is skipped with a warning and kept. An entry is only removed once
+// its upload succeeded and the uploaded files match the queued checksums. Flushing stops
+// with an error if Nexus cannot be reached, keeping the remaining entries.
+func flushQueue(dir string, config *config.Config, opts *UploadOptions) (QueueFlushResult, error) {
+	var result QueueFlushResult
+	entries, err := ListQueue(dir)
+	if err != nil || len(entries) == 0 {
+		return result, err
+	}
+
+	client := NewClient(config)
+	if _, err := client.StatusAvailable(); isConnectivityError(err) {
+		return result, fmt.Errorf("Nexus is still unreachable, %d upload(s) remain queued: %w", len(entries), err)
+	}
+
+	for i, entry := range entries {
+		if !sameServer(entry.URL, config.NexusURL) {
+			opts.Logger.Printf("Skipping queued upload %s: it was queued for %s\n", entry.ID, entry.URL)
+			result.Skipped++
+			continue
+		}
+		err := flushQueueEntry(client, dir, entry, config, opts)
+		var changed *sourceChangedError
+		switch {
+		case err == nil:
+			opts.Logger.Printf("Uploaded queued %s -> %s (%d files)\n", entry.Source, entry.Dest, len(entry.Files))
+			result.Flushed++
+		case errors.As(err, &changed):
+			opts.Logger.Printf("Warning: skipping queued upload %s -> %s: %v; delete %s to drop it\n", entry.Source, entry.Dest, err, filepath.Join(dir, entry.ID))
+			result.Skipped++
+		case isConnectivityError(err):
+			return result, fmt.Errorf("lost the connection to Nexus, %d upload(s) remain queued: %w", len(entries)-i, err)
+		default:
+			opts.Logger.Printf("Error: queued upload %s -> %s failed, keeping it queued: %v\n", entry.Source, entry.Dest, err)
+			result.Failed++
+		}
+	}
+	return result, nil
+}
+
+// sourceChangedError is returned by flushQueueEntry if the source of an entry changed
+// since it was queued
+type sourceChangedError struct {
+	change string
+}
+
+func (e *sourceChangedError) Error() string {
+	return "source changed since it was queued: " + e.change
+}
+
+// flushQueueEntry uploads the queued entry, verifies the result and removes the entry
+func flushQueueEntry(client *nexusapi.Client, dir string, entry *QueueEntry, config *config.Config, opts *UploadOptions) error {
+	entryOpts, err := entry.uploadOptions(opts)
+	if err != nil {
+		return err
+	}
+	change, err := entry.sourceChange(entryOpts)
+	if err != nil {
+		return err
+	}
+	if change != "" {
+		return &sourceChangedError{change: change}
+	}
+
+	// Templates such as {date} refer to when the upload was queued
+	dest, err := expandUploadTemplates(entry.Dest, client, entryOpts, entry.QueuedAt)
+	if err != nil {
+		return err
+	}
+	repository, subdir, archiveName, err := splitUploadDest(dest, entry.Dest, entryOpts)
+	if err != nil {
+		return err
+	}
+	if !entryOpts.SkipWriteCheck {
+		if err := checkUploadRepositories(client, []string{repository}, entryOpts); err != nil {
+			return err
+		}
+	}
+	if err := uploadFilesWithArchiveName(entry.Source, repository, subdir, archiveName, config, entryOpts); err != nil {
+		return err
+	}
+	if err := verifyQueuedUpload(client, repository, subdir, archiveName, entry); err != nil {
+		return fmt.Errorf("verification failed: %w", err)
+	}
+	return os.Remove(filepath.Join(dir, entry.ID))
+}
+
+// verifyQueuedUpload checks that Nexus has every queued file with its queued checksum, or
+// its size if Nexus reports no checksum for the algorithm. An archive is only checked to
+// exist, since its checksum is not known before it is created.
+func verifyQueuedUpload(client *nexusapi.Client, repository, subdir, archiveName string, entry *QueueEntry) error {
+	if archiveName != "" {
+		_, err := client.GetAssetByPath(repository, path.Join(subdir, archiveName))
+		return err
+	}
+
+	assets, err := client.ListAssets(repository, subdir, true)
+	if err != nil {
+		return err
+	}
+	remote := make(map[string]nexusapi.Asset, len(assets))
+	for _, asset := range assets {
+		remote[getRelativePath(asset.Path, subdir)] = asset
+	}
+	for relPath, file := range entry.Files {
+		asset, ok := remote[relPath]
+		if !ok {
+			return fmt.Errorf("%s is missing in %s", relPath, path.Join(repository, subdir))
+		}
+		if expected := checksum.ExtractChecksum(asset.Checksum, entry.Algorithm); expected != "" {
+			if match, err := checksum.Matches(expected, file.Checksum, entry.Algorithm); err != nil || !match {
+				return fmt.Errorf("%s has %s %s in Nexus, expected %s", relPath, entry.Algorithm, expected, file.Checksum)
+			}
+		} else if asset.FileSize != file.Size {
+			return fmt.Errorf("%s has %d bytes in Nexus, expected %d", relPath, asset.FileSize, file.Size)
+		}
+	}
+	return nil
+}
+
+// queueUploadMain queues the upload of src to dest after cause showed that Nexus cannot be
+// reached, and exits with status 1 if it cannot be queued
+func queueUploadMain(src, dest string, config *config.Config, opts *UploadOptions, cause error) {
+	opts.Logger.Printf("Warning: cannot reach Nexus: %v\n", cause)
+	entry, err := queueUpload(src, dest, config, opts, time.Now())
+	if err != nil {
+		fmt.Println("Error: cannot queue the upload:", err)
+		os.Exit(exitcode.Error)
+	}
+	opts.Logger.Printf("Queued the upload of %d files from %s to %s as %s\n", len(entry.Files), src, dest, entry.ID)
+	opts.Logger.Println("Run 'nexuscli-go queue flush' to upload it once Nexus is reachable")
+}
+
+// FlushQueueMain replays the uploads queued in dir and exits with status 1 if Nexus is
+// unreachable or an upload failed
+func FlushQueueMain(dir string, config *config.Config, opts *UploadOptions) {
+	result, err := flushQueue(dir, config, opts)
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(exitcode.Error)
+	}
+	if result.Flushed+result.Skipped+result.Failed == 0 {
+		opts.Logger.Println("No queued uploads")
+		return
+	}
+	opts.Logger.Printf("Queued uploads: %d uploaded, %d skipped, %d failed\n", result.Flushed, result.Skipped, result.Failed)
+	if result.Failed > 0 {
+		os.Exit(exitcode.Error)
+	}
+}
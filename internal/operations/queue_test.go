@@ -0,0 +1,139 @@
+package operations
+
+import (
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tympanix/nexus-cli/internal/config"
+	"github.com/tympanix/nexus-cli/internal/nexusapi"
+	"github.com/tympanix/nexus-cli/internal/util"
+)
+
+// unreachableURL returns the URL of a server that has already been shut down
+func unreachableURL(t *testing.T) string {
+	t.Helper()
+	server := httptest.NewServer(nil)
+	server.Close()
+	return server.URL
+}
+
+func newQueueSource(t *testing.T) string {
+	t.Helper()
+	srcDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(srcDir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{"app.bin": "binary", "sub/notes.txt": "notes"} {
+		if err := os.WriteFile(filepath.Join(srcDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return srcDir
+}
+
+func newQueueOptions(queueDir string) *UploadOptions {
+	return &UploadOptions{Logger: util.NewLogger(io.Discard), QuietMode: true, Queue: queueDir}
+}
+
+func TestUploadQueuesWhenUnreachable(t *testing.T) {
+	srcDir := newQueueSource(t)
+	queueDir := filepath.Join(t.TempDir(), "queue")
+	cfg := &config.Config{NexusURL: unreachableURL(t), Username: "test", Password: "test"}
+
+	UploadMain(srcDir, "raw/builds/{date}", cfg, newQueueOptions(queueDir))
+
+	entries, err := ListQueue(queueDir)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("Expected one queued upload, got %d, %v", len(entries), err)
+	}
+	entry := entries[0]
+	if entry.Dest != "raw/builds/{date}" || entry.URL != cfg.NexusURL || entry.Algorithm != "sha1" {
+		t.Errorf("Unexpected entry %+v", entry)
+	}
+	if len(entry.Files) != 2 || entry.Files["sub/notes.txt"].Size != 5 || entry.Files["app.bin"].Checksum == "" {
+		t.Errorf("Expected a snapshot of both files, got %+v", entry.Files)
+	}
+
+	// Flushing while Nexus is still down keeps the entry
+	if _, err := flushQueue(queueDir, cfg, newQueueOptions("")); err == nil {
+		t.Error("Expected flushing to fail while Nexus is unreachable")
+	}
+	if entries, _ := ListQueue(queueDir); len(entries) != 1 {
+		t.Errorf("Expected the entry to stay queued, got %d entries", len(entries))
+	}
+}
+
+func TestFlushQueueUploadsAndRemovesEntry(t *testing.T) {
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+	server.StoreUploads = true
+	cfg := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+
+	srcDir := newQueueSource(t)
+	queueDir := t.TempDir()
+	if _, err := queueUpload(srcDir, "raw/dist", cfg, newQueueOptions(queueDir), time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("Queueing failed: %v", err)
+	}
+
+	result, err := flushQueue(queueDir, cfg, newQueueOptions(""))
+	if err != nil || result != (QueueFlushResult{Flushed: 1}) {
+		t.Fatalf("Expected one flushed upload, got %+v, %v", result, err)
+	}
+	if paths := uploadedPaths(server); len(paths) != 2 || paths[0] != "/dist/app.bin" || paths[1] != "/dist/sub/notes.txt" {
+		t.Errorf("Expected both files in dist, got %v", paths)
+	}
+	if entries, _ := ListQueue(queueDir); len(entries) != 0 {
+		t.Errorf("Expected the flushed entry to be removed, got %d entries", len(entries))
+	}
+}
+
+func TestFlushQueueSkipsChangedSource(t *testing.T) {
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+	server.StoreUploads = true
+	cfg := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+
+	srcDir := newQueueSource(t)
+	queueDir := t.TempDir()
+	if _, err := queueUpload(srcDir, "raw/dist", cfg, newQueueOptions(queueDir), time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("Queueing failed: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(srcDir, "app.bin"), []byte("rebuilt"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := flushQueue(queueDir, cfg, newQueueOptions(""))
+	if err != nil || result != (QueueFlushResult{Skipped: 1}) {
+		t.Fatalf("Expected the changed upload to be skipped, got %+v, %v", result, err)
+	}
+	if len(server.GetUploadedFiles()) != 0 {
+		t.Errorf("Expected nothing to be uploaded, got %v", uploadedPaths(server))
+	}
+	if entries, _ := ListQueue(queueDir); len(entries) != 1 {
+		t.Errorf("Expected the skipped entry to stay queued, got %d entries", len(entries))
+	}
+}
+
+func TestFlushQueueKeepsUnverifiedEntry(t *testing.T) {
+	// Without StoreUploads the uploaded files never show up in Nexus
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+	cfg := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+
+	queueDir := t.TempDir()
+	if _, err := queueUpload(newQueueSource(t), "raw/dist", cfg, newQueueOptions(queueDir), time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("Queueing failed: %v", err)
+	}
+
+	result, err := flushQueue(queueDir, cfg, newQueueOptions(""))
+	if err != nil || result != (QueueFlushResult{Failed: 1}) {
+		t.Fatalf("Expected the unverified upload to fail, got %+v, %v", result, err)
+	}
+	if entries, _ := ListQueue(queueDir); len(entries) != 1 {
+		t.Errorf("Expected the entry to stay queued, got %d entries", len(entries))
+	}
+}
@@ -0,0 +1,52 @@
+package operations
+
+import (
+	"io"
+	"time"
+)
+
+// rateLimiter throttles the combined throughput of all writers sharing it. It reads
+// the bytes transferred from the meter of the transfer, so it counts exactly what the
+// progress bar and the summary report.
+type rateLimiter struct {
+	bytesPerSecond int64
+	meter          *transferMeter
+}
+
+func newRateLimiter(bytesPerSecond int64, meter *transferMeter) *rateLimiter {
+	return &rateLimiter{
+		bytesPerSecond: bytesPerSecond,
+		meter:          meter,
+	}
+}
+
+// wait blocks until the average rate since the meter started is back under the
+// configured limit
+func (l *rateLimiter) wait() {
+	transferred := l.meter.transferred.Load()
+	due := l.meter.start.Add(time.Duration(float64(transferred) / float64(l.bytesPerSecond) * float64(time.Second)))
+	if delay := time.Until(due); delay > 0 {
+		time.Sleep(delay)
+	}
+}
+
+// rateLimitedWriter wraps a writer and throttles it through a shared rateLimiter. The
+// writer must count the bytes written to it on the meter of the limiter.
+type rateLimitedWriter struct {
+	writer  io.Writer
+	limiter *rateLimiter
+}
+
+func (w *rateLimitedWriter) Write(p []byte) (int, error) {
+	n, err := w.writer.Write(p)
+	w.limiter.wait()
+	return n, err
+}
+
+// limitWriter wraps writer with the rate limiter if one is configured
+func limitWriter(writer io.Writer, limiter *rateLimiter) io.Writer {
+	if limiter == nil {
+		return writer
+	}
+	return &rateLimitedWriter{writer: writer, limiter: limiter}
+}
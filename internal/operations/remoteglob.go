@@ -0,0 +1,181 @@
+package operations
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/tympanix/nexus-cli/internal/nexusapi"
+)
+
+// maxGlobCandidates is the number of candidate paths named when a source glob matches nothing
+const maxGlobCandidates = 20
+
+// sourceGlob is a download source path with glob metacharacters, e.g. builds/2024-*/logs.
+// Nexus cannot search by pattern, so the literal prefix before the first segment with a
+// metacharacter is listed and the pattern is matched against the listed paths. Each match
+// is downloaded under its own name, as if it had been given as the source. A
+// metacharacter escaped with a backslash, as in file\[1\].txt, is matched literally, and
+// a source path that exists as it is, such as file[1].txt, is not expanded at all.
+type sourceGlob struct {
+	pattern    string            // Pattern without leading or trailing slashes
+	literal    string            // The pattern read as a path, without escapes
+	prefix     string            // Segments of the pattern before the first one with a metacharacter
+	bases      map[string]string // Folder each selected asset is relative to, by asset path
+	matches    map[string]bool   // Matched paths, with whether they are folders
+	candidates map[string]bool   // Paths at the depth of the pattern that were considered
+}
+
+// hasGlobMeta reports whether the source path src contains glob metacharacters that are
+// not escaped with a backslash
+func hasGlobMeta(src string) bool {
+	for i := 0; i < len(src); i++ {
+		switch src[i] {
+		case '\\':
+			i++
+		case '*', '?', '[':
+			return true
+		}
+	}
+	return false
+}
+
+// unescapeGlobMeta removes the backslashes that escape characters in src
+func unescapeGlobMeta(src string) string {
+	if !strings.Contains(src, "\\") {
+		return src
+	}
+	var b strings.Builder
+	for i := 0; i < len(src); i++ {
+		if src[i] == '\\' && i+1 < len(src) {
+			i++
+		}
+		b.WriteByte(src[i])
+	}
+	return b.String()
+}
+
+// escapeGlobMeta escapes the metacharacters in literal, so it matches only itself
+func escapeGlobMeta(literal string) string {
+	var b strings.Builder
+	for i := 0; i < len(literal); i++ {
+		if strings.IndexByte("\\*?[]{}", literal[i]) >= 0 {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(literal[i])
+	}
+	return b.String()
+}
+
+// newSourceGlob parses the source path src as a glob
+func newSourceGlob(src string) (*sourceGlob, error) {
+	pattern := strings.Trim(src, "/")
+	if !doublestar.ValidatePattern(pattern) {
+		return nil, fmt.Errorf("invalid glob pattern in source path '%s'", src)
+	}
+	var prefix []string
+	for _, segment := range strings.Split(pattern, "/") {
+		if hasGlobMeta(segment) {
+			break
+		}
+		prefix = append(prefix, unescapeGlobMeta(segment))
+	}
+	return &sourceGlob{
+		pattern:    pattern,
+		literal:    unescapeGlobMeta(pattern),
+		prefix:     strings.Join(prefix, "/"),
+		bases:      make(map[string]string),
+		matches:    make(map[string]bool),
+		candidates: make(map[string]bool),
+	}, nil
+}
+
+// expand keeps the assets that match the pattern or lie in a folder that does, recording
+// the folder each one is relative to. Folders are only expanded if recursive is set; then
+// assets more than depth levels below their folder are dropped, unless depth is 0, and
+// their number is returned. If an asset exists at or below the literal path, the source
+// path is taken literally instead.
+func (g *sourceGlob) expand(assets []nexusapi.Asset, recursive bool, depth int) ([]nexusapi.Asset, int) {
+	for _, asset := range assets {
+		if assetPath := strings.Trim(asset.Path, "/"); assetPath == g.literal || strings.HasPrefix(assetPath, g.literal+"/") {
+			return g.match(escapeGlobMeta(g.literal), assets, recursive, depth)
+		}
+	}
+	return g.match(g.pattern, assets, recursive, depth)
+}
+
+func (g *sourceGlob) match(pattern string, assets []nexusapi.Asset, recursive bool, depth int) ([]nexusapi.Asset, int) {
+	patternDepth := strings.Count(pattern, "/") + 1
+	start := 1
+	if g.prefix != "" {
+		start = strings.Count(g.prefix, "/") + 2
+	}
+
+	var selected []nexusapi.Asset
+	tooDeep := 0
+	for _, asset := range assets {
+		segments := strings.Split(strings.Trim(asset.Path, "/"), "/")
+		g.candidates[strings.Join(segments[:min(patternDepth, len(segments))], "/")] = true
+		for i := start; i <= len(segments); i++ {
+			candidate := strings.Join(segments[:i], "/")
+			if ok, _ := doublestar.Match(pattern, candidate); !ok {
+				continue
+			}
+			folder := i < len(segments)
+			g.matches[candidate] = folder
+			base := candidate
+			if !folder {
+				base = path.Dir(candidate)
+			}
+			switch {
+			case folder && !recursive:
+			case depth > 0 && strings.Count(getRelativePath(asset.Path, base), "/") >= depth:
+				tooDeep++
+			default:
+				g.bases[asset.Path] = base
+				selected = append(selected, asset)
+			}
+			break
+		}
+	}
+	return selected, tooDeep
+}
+
+// base returns the folder the asset at assetPath is relative to, for --glob and --depth
+func (g *sourceGlob) base(assetPath string) string {
+	return g.bases[assetPath]
+}
+
+// noMatch describes why the pattern selected no assets, naming the candidates considered
+func (g *sourceGlob) noMatch(repository string) string {
+	var folders int
+	for _, folder := range g.matches {
+		if folder {
+			folders++
+		}
+	}
+	if folders > 0 {
+		return fmt.Sprintf("'%s' matches %d folder(s) in repository '%s'; use --recursive to download folders", g.pattern, folders, repository)
+	}
+
+	under := "the repository root"
+	if g.prefix != "" {
+		under = "'" + g.prefix + "'"
+	}
+	if len(g.candidates) == 0 {
+		return fmt.Sprintf("No paths match '%s' in repository '%s': no assets found under %s", g.pattern, repository, under)
+	}
+	candidates := make([]string, 0, len(g.candidates))
+	for candidate := range g.candidates {
+		candidates = append(candidates, candidate)
+	}
+	sort.Strings(candidates)
+	more := ""
+	if len(candidates) > maxGlobCandidates {
+		more = fmt.Sprintf(", ... (%d more)", len(candidates)-maxGlobCandidates)
+		candidates = candidates[:maxGlobCandidates]
+	}
+	return fmt.Sprintf("No paths match '%s' in repository '%s'; candidates considered under %s: %s%s", g.pattern, repository, under, strings.Join(candidates, ", "), more)
+}
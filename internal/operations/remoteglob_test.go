@@ -0,0 +1,136 @@
+package operations
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tympanix/nexus-cli/internal/config"
+	"github.com/tympanix/nexus-cli/internal/nexusapi"
+	"github.com/tympanix/nexus-cli/internal/util"
+)
+
+func newGlobSourceServer(t *testing.T) (*nexusapi.MockNexusServer, *config.Config) {
+	t.Helper()
+	server := nexusapi.NewMockNexusServer()
+	t.Cleanup(server.Close)
+	for _, assetPath := range []string{
+		"/builds/2023-12/logs/build.log",
+		"/builds/2024-01/logs/build.log",
+		"/builds/2024-01/logs/test.txt",
+		"/builds/2024-01/bin/app",
+		"/builds/2024-02/logs/build.log",
+		"/builds/2024-02/logs/nested/deep.log",
+	} {
+		server.AddAsset("test-repo", assetPath, nexusapi.Asset{}, []byte(assetPath))
+	}
+	return server, &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+}
+
+func TestDownloadSourceGlob(t *testing.T) {
+	_, config := newGlobSourceServer(t)
+
+	destDir := t.TempDir()
+	opts := &DownloadOptions{ChecksumAlgorithm: "sha1", Logger: util.NewLogger(io.Discard), QuietMode: true, Recursive: true, GlobPattern: "*.log"}
+	if status := downloadFolder("test-repo/builds/2024-*/logs", destDir, config, opts); status != DownloadSuccess {
+		t.Fatalf("Expected the glob to be expanded, got %v", status)
+	}
+	for _, expected := range []string{"builds/2024-01/logs/build.log", "builds/2024-02/logs/build.log"} {
+		if _, err := os.Stat(filepath.Join(destDir, expected)); err != nil {
+			t.Errorf("Expected %s to be downloaded: %v", expected, err)
+		}
+	}
+	// --glob applies within each match, so *.log does not reach into subfolders
+	for _, unexpected := range []string{"builds/2023-12", "builds/2024-01/bin", "builds/2024-01/logs/test.txt", "builds/2024-02/logs/nested"} {
+		if _, err := os.Stat(filepath.Join(destDir, unexpected)); !os.IsNotExist(err) {
+			t.Errorf("Expected %s not to be downloaded, got %v", unexpected, err)
+		}
+	}
+
+	// With --flatten each match keeps its name below the prefix of the pattern
+	destDir = t.TempDir()
+	opts = &DownloadOptions{ChecksumAlgorithm: "sha1", Logger: util.NewLogger(io.Discard), QuietMode: true, Recursive: true, Flatten: true, Depth: 1}
+	if status := downloadFolder("test-repo/builds/2024-*/logs", destDir, config, opts); status != DownloadSuccess {
+		t.Fatalf("Expected the glob to be expanded, got %v", status)
+	}
+	for _, expected := range []string{"2024-01/logs/build.log", "2024-01/logs/test.txt", "2024-02/logs/build.log"} {
+		if _, err := os.Stat(filepath.Join(destDir, expected)); err != nil {
+			t.Errorf("Expected %s to be downloaded: %v", expected, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(destDir, "2024-02/logs/nested")); !os.IsNotExist(err) {
+		t.Errorf("Expected --depth to apply within each match, got %v", err)
+	}
+
+	// Without --recursive the pattern selects files
+	destDir = t.TempDir()
+	opts = &DownloadOptions{ChecksumAlgorithm: "sha1", Logger: util.NewLogger(io.Discard), QuietMode: true}
+	if status := downloadFolder("test-repo/builds/*/logs/build.log", destDir, config, opts); status != DownloadSuccess {
+		t.Fatalf("Expected the glob to match files, got %v", status)
+	}
+	if _, err := os.Stat(filepath.Join(destDir, "builds/2023-12/logs/build.log")); err != nil {
+		t.Errorf("Expected the matched file to be downloaded: %v", err)
+	}
+}
+
+func TestDownloadSourceGlobNoMatch(t *testing.T) {
+	_, config := newGlobSourceServer(t)
+
+	var logs bytes.Buffer
+	opts := &DownloadOptions{ChecksumAlgorithm: "sha1", Logger: util.NewLogger(&logs), QuietMode: true, Recursive: true}
+	if status := downloadFolder("test-repo/builds/2025-*/logs", t.TempDir(), config, opts); status != DownloadNoAssetsFound {
+		t.Errorf("Expected exit code 66 if nothing matches, got %v", status)
+	}
+	expected := "candidates considered under 'builds': builds/2023-12/logs, builds/2024-01/bin, builds/2024-01/logs, builds/2024-02/logs"
+	if !strings.Contains(logs.String(), expected) {
+		t.Errorf("Expected the candidates to be listed, got %q", logs.String())
+	}
+
+	logs.Reset()
+	opts = &DownloadOptions{ChecksumAlgorithm: "sha1", Logger: util.NewLogger(&logs), QuietMode: true}
+	if status := downloadFolder("test-repo/builds/2024-*", t.TempDir(), config, opts); status != DownloadNoAssetsFound {
+		t.Errorf("Expected exit code 66 for folders without --recursive, got %v", status)
+	}
+	if !strings.Contains(logs.String(), "matches 2 folder(s)") || !strings.Contains(logs.String(), "--recursive") {
+		t.Errorf("Expected a hint to use --recursive, got %q", logs.String())
+	}
+
+	opts = &DownloadOptions{ChecksumAlgorithm: "sha1", Logger: util.NewLogger(&logs), QuietMode: true, Recursive: true, Compress: true}
+	if status := downloadFolder("test-repo/builds/2024-*", t.TempDir(), config, opts); status != DownloadError {
+		t.Errorf("Expected --compress with a glob to fail, got %v", status)
+	}
+}
+
+func TestDownloadSourceGlobLiteral(t *testing.T) {
+	server, config := newGlobSourceServer(t)
+	server.AddAsset("test-repo", "/docs/file[1].txt", nexusapi.Asset{}, []byte("first"))
+	server.AddAsset("test-repo", "/docs/file1.txt", nexusapi.Asset{}, []byte("other"))
+
+	// Brackets that match nothing as a pattern are part of the name, and escaped ones always are
+	for _, src := range []string{"test-repo/docs/file[1].txt", `test-repo/docs/file\[1\].txt`} {
+		destDir := t.TempDir()
+		opts := &DownloadOptions{ChecksumAlgorithm: "sha1", Logger: util.NewLogger(io.Discard), QuietMode: true}
+		if status := downloadFolder(src, destDir, config, opts); status != DownloadSuccess {
+			t.Fatalf("Expected %s to be downloaded as a literal path, got %v", src, status)
+		}
+		if data, err := os.ReadFile(filepath.Join(destDir, "docs/file[1].txt")); err != nil || string(data) != "first" {
+			t.Errorf("Expected docs/file[1].txt to be downloaded from %s, got %q (%v)", src, data, err)
+		}
+		if _, err := os.Stat(filepath.Join(destDir, "docs/file1.txt")); !os.IsNotExist(err) {
+			t.Errorf("Expected docs/file1.txt not to be downloaded from %s, got %v", src, err)
+		}
+	}
+
+	// A pattern in the first segment lists the whole repository, which is warned about
+	var logs bytes.Buffer
+	opts := &DownloadOptions{ChecksumAlgorithm: "sha1", Logger: util.NewLogger(&logs), Recursive: true}
+	if status := downloadFolder("test-repo/doc*", t.TempDir(), config, opts); status != DownloadSuccess {
+		t.Fatalf("Expected the glob to be expanded, got %v", status)
+	}
+	if !strings.Contains(logs.String(), "all of repository 'test-repo' is listed") {
+		t.Errorf("Expected a warning about listing the whole repository, got %q", logs.String())
+	}
+}
@@ -0,0 +1,114 @@
+package operations
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/tympanix/nexus-cli/internal/config"
+	"github.com/tympanix/nexus-cli/internal/exitcode"
+	"github.com/tympanix/nexus-cli/internal/nexusapi"
+	"github.com/tympanix/nexus-cli/internal/util"
+)
+
+// RepoListOptions holds options for listing repositories
+type RepoListOptions struct {
+	Logger util.Logger
+	Long   bool   // Show format, type, status and URL of each repository
+	Output string // Output format: text (default) or json
+}
+
+// listRepositories lists the repositories sorted by name. Repositories whose online state
+// is not part of the listing get it from the repository settings, if those can be read.
+func listRepositories(config *config.Config) ([]nexusapi.Repository, error) {
+	client := NewClient(config)
+	repositories, err := client.ListRepositories()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(repositories, func(i, j int) bool { return repositories[i].Name < repositories[j].Name })
+
+	var statuses map[string]bool
+	for i := range repositories {
+		if repositories[i].Online != nil {
+			continue
+		}
+		if statuses == nil {
+			statuses = make(map[string]bool)
+			// Reading the settings needs more privileges; without them the state stays unknown
+			if list, err := client.ListRepositoryStatuses(); err == nil {
+				for _, status := range list {
+					statuses[status.Name] = status.Online
+				}
+			}
+		}
+		if online, ok := statuses[repositories[i].Name]; ok {
+			repositories[i].Online = &online
+		}
+	}
+	return repositories, nil
+}
+
+// repositoryStatus describes the online state of a repository for the long listing
+func repositoryStatus(repository nexusapi.Repository) string {
+	switch {
+	case repository.Online == nil:
+		return "unknown"
+	case *repository.Online:
+		return "online"
+	default:
+		return "offline"
+	}
+}
+
+// writeRepositoryList writes repositories in the format of opts
+func writeRepositoryList(sb *strings.Builder, repositories []nexusapi.Repository, opts *RepoListOptions) error {
+	switch opts.Output {
+	case "", "text":
+	case "json":
+		if repositories == nil {
+			repositories = []nexusapi.Repository{}
+		}
+		data, err := json.MarshalIndent(repositories, "", "  ")
+		if err != nil {
+			return err
+		}
+		sb.Write(data)
+		sb.WriteString("\n")
+		return nil
+	default:
+		return fmt.Errorf("unsupported output format '%s': must be one of: text, json", opts.Output)
+	}
+
+	if !opts.Long {
+		for _, repository := range repositories {
+			sb.WriteString(repository.Name + "\n")
+		}
+		return nil
+	}
+	w := tabwriter.NewWriter(sb, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tFORMAT\tTYPE\tSTATUS\tURL")
+	for _, repository := range repositories {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", repository.Name, repository.Format, repository.Type, repositoryStatus(repository), repository.URL)
+	}
+	return w.Flush()
+}
+
+// RepoListMain prints the repositories of the Nexus server. It exits with status 1 on failure.
+func RepoListMain(config *config.Config, opts *RepoListOptions) {
+	repositories, err := listRepositories(config)
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(exitcode.Error)
+	}
+
+	var sb strings.Builder
+	if err := writeRepositoryList(&sb, repositories, opts); err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(exitcode.Error)
+	}
+	opts.Logger.Printf("%s", sb.String())
+}
@@ -0,0 +1,91 @@
+package operations
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/tympanix/nexus-cli/internal/config"
+	"github.com/tympanix/nexus-cli/internal/nexusapi"
+)
+
+func newRepoListTest(t *testing.T) *config.Config {
+	t.Helper()
+	server := nexusapi.NewMockNexusServer()
+	t.Cleanup(server.Close)
+	server.Repositories = []nexusapi.Repository{
+		{Name: "raw-proxy", Format: "raw", Type: "proxy", URL: "http://nexus/repository/raw-proxy"},
+		{Name: "raw-hosted", Format: "raw", Type: "hosted", URL: "http://nexus/repository/raw-hosted"},
+		{Name: "raw-group", Format: "raw", Type: "group", URL: "http://nexus/repository/raw-group"},
+	}
+	server.RepositoryStatuses = []nexusapi.RepositoryStatus{
+		{Name: "raw-hosted", Format: "raw", Type: "hosted", Online: true},
+		{Name: "raw-proxy", Format: "raw", Type: "proxy", Online: false},
+	}
+	return &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+}
+
+func TestRepoListLong(t *testing.T) {
+	config := newRepoListTest(t)
+
+	repositories, err := listRepositories(config)
+	if err != nil {
+		t.Fatalf("listRepositories failed: %v", err)
+	}
+
+	var sb strings.Builder
+	if err := writeRepositoryList(&sb, repositories, &RepoListOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if sb.String() != "raw-group\nraw-hosted\nraw-proxy\n" {
+		t.Errorf("Expected the names sorted, got:\n%s", sb.String())
+	}
+
+	sb.Reset()
+	if err := writeRepositoryList(&sb, repositories, &RepoListOptions{Long: true}); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(sb.String()), "\n")
+	expected := [][]string{
+		{"NAME", "FORMAT", "TYPE", "STATUS", "URL"},
+		{"raw-group", "raw", "group", "unknown", "http://nexus/repository/raw-group"},
+		{"raw-hosted", "raw", "hosted", "online", "http://nexus/repository/raw-hosted"},
+		{"raw-proxy", "raw", "proxy", "offline", "http://nexus/repository/raw-proxy"},
+	}
+	if len(lines) != len(expected) {
+		t.Fatalf("Expected %d lines, got:\n%s", len(expected), sb.String())
+	}
+	for i, line := range lines {
+		if got := strings.Fields(line); strings.Join(got, " ") != strings.Join(expected[i], " ") {
+			t.Errorf("Expected line %d to be %v, got %q", i, expected[i], line)
+		}
+	}
+}
+
+func TestRepoListJSON(t *testing.T) {
+	config := newRepoListTest(t)
+
+	repositories, err := listRepositories(config)
+	if err != nil {
+		t.Fatalf("listRepositories failed: %v", err)
+	}
+	var sb strings.Builder
+	if err := writeRepositoryList(&sb, repositories, &RepoListOptions{Output: "json"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded []map[string]interface{}
+	if err := json.Unmarshal([]byte(sb.String()), &decoded); err != nil {
+		t.Fatalf("Expected valid JSON, got %v:\n%s", err, sb.String())
+	}
+	if len(decoded) != 3 || decoded[1]["name"] != "raw-hosted" || decoded[1]["type"] != "hosted" || decoded[1]["online"] != true {
+		t.Errorf("Unexpected JSON output: %v", decoded)
+	}
+	if _, ok := decoded[0]["online"]; ok {
+		t.Error("Expected the online state to be left out when it is unknown")
+	}
+
+	if err := writeRepositoryList(&sb, repositories, &RepoListOptions{Output: "yaml"}); err == nil {
+		t.Error("Expected an error for an unsupported output format")
+	}
+}
@@ -0,0 +1,221 @@
+package operations
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/tympanix/nexus-cli/internal/config"
+	"github.com/tympanix/nexus-cli/internal/nexusapi"
+)
+
+// listDownloadAssets lists the assets to download from repository in the order selected
+// by opts.Sort, limited to opts.Depth levels below src. Assets that are too deep are
+// dropped from each page of the listing as it arrives. A group repository is searched
+// member by member, see listGroupAssets. With CheckOnline set, offline members of a group
+// repository are skipped. If glob is set, src is its prefix, which is listed recursively
+// and expanded by the glob.
+func listDownloadAssets(repository, src string, glob *sourceGlob, config *config.Config, opts *DownloadOptions) ([]nexusapi.Asset, error) {
+	client := NewClient(config)
+	recursive := opts.recursiveListing() || glob != nil
+	var keep nexusapi.ListFilter
+	tooDeep := 0
+	if glob == nil && opts.Depth > 0 {
+		keep = func(asset nexusapi.Asset) bool {
+			if withinDepth(asset.Path, src, opts.Depth) {
+				return true
+			}
+			tooDeep++
+			return false
+		}
+	}
+	var assets []nexusapi.Asset
+	var err error
+	if opts.CheckOnline {
+		assets, err = listOnlineAssets(client, repository, src, recursive, keep, opts)
+	} else {
+		assets, err = listGroupOrRepositoryAssets(client, repository, src, recursive, keep, opts)
+	}
+	if err != nil {
+		return nil, err
+	}
+	opts.Logger.VerbosePrintf("Listed %d asset(s): %d bytes received, %d bytes of JSON decoded\n",
+		len(assets), client.Stats.WireBytes(), client.Stats.DecodedBytes())
+	if glob != nil {
+		// Assets outside the matches were never part of the download, so only those
+		// below a match but too deep are counted as filtered
+		assets, opts.filtered.Depth = glob.expand(assets, opts.recursiveListing(), opts.Depth)
+		opts.Logger.VerbosePrintf("Expanded '%s' to %d path(s)\n", glob.pattern, len(glob.matches))
+	} else {
+		opts.filtered.Depth = tooDeep
+	}
+	sortAssets(assets, opts.Sort, opts.Direction)
+	return assets, nil
+}
+
+// repositoryMissing reports whether Nexus says repository does not exist, logging an error
+// if so. It is used to tell an empty listing of a missing repository from an empty folder;
+// if the check itself fails, the repository is assumed to exist.
+func repositoryMissing(repository string, config *config.Config, opts *DownloadOptions) bool {
+	client := NewClient(config)
+	repo, err := client.GetRepository(repository)
+	if err != nil {
+		opts.Logger.VerbosePrintf("Could not check whether repository '%s' exists: %v\n", repository, err)
+		return false
+	}
+	if repo != nil {
+		return false
+	}
+	opts.Logger.Printf("Error: repository '%s' does not exist on %s\n", repository, config.NexusURL)
+	return true
+}
+
+// listOnlineAssets checks the repository status before listing. A group repository is
+// listed member by member so one offline proxy does not fail the whole listing. The
+// statuses of the repository and its members come from a single listing of the settings.
+func listOnlineAssets(client *nexusapi.Client, repository, src string, recursive bool, keep nexusapi.ListFilter, opts *DownloadOptions) ([]nexusapi.Asset, error) {
+	statuses, err := client.ListRepositoryStatuses()
+	if err != nil {
+		return nil, fmt.Errorf("failed to check status of repository '%s': %w", repository, err)
+	}
+	byName := make(map[string]nexusapi.RepositoryStatus, len(statuses))
+	for _, status := range statuses {
+		byName[status.Name] = status
+	}
+	status, ok := byName[repository]
+	if !ok {
+		return nil, fmt.Errorf("failed to check status of repository '%s': repository '%s' not found", repository, repository)
+	}
+	if !status.Online {
+		return nil, fmt.Errorf("repository '%s' is offline", repository)
+	}
+	if status.Group == nil {
+		return client.ListAssetsFiltered(repository, src, recursive, searchSort(opts.Sort, opts.Direction), keep)
+	}
+
+	var online, skipped []string
+	for _, member := range status.Group.MemberNames {
+		memberStatus, ok := byName[member]
+		if !ok {
+			skipped = append(skipped, fmt.Sprintf("%s (repository '%s' not found)", member, member))
+			continue
+		}
+		if !memberStatus.Online {
+			skipped = append(skipped, fmt.Sprintf("%s (offline)", member))
+			continue
+		}
+		online = append(online, member)
+	}
+
+	if len(skipped) > 0 && !opts.QuietMode {
+		opts.Logger.Printf("Warning: skipping unavailable members of group '%s': %s\n", repository, strings.Join(skipped, ", "))
+	}
+	if len(online) == 0 {
+		return nil, fmt.Errorf("no online members in group '%s'", repository)
+	}
+
+	return listGroupAssets(client, repository, online, src, recursive, keep, opts)
+}
+
+// listGroupOrRepositoryAssets lists the assets below src, searching the members of a group
+// repository. If the members cannot be read, the repository is searched directly.
+func listGroupOrRepositoryAssets(client *nexusapi.Client, repository, src string, recursive bool, keep nexusapi.ListFilter, opts *DownloadOptions) ([]nexusapi.Asset, error) {
+	members, err := client.GetGroupMembers(repository)
+	if err != nil {
+		opts.Logger.VerbosePrintf("Could not check whether repository '%s' is a group: %v\n", repository, err)
+	}
+	if len(members) == 0 {
+		return client.ListAssetsFiltered(repository, src, recursive, searchSort(opts.Sort, opts.Direction), keep)
+	}
+	return listGroupAssets(client, repository, members, src, recursive, keep, opts)
+}
+
+// listGroupAssets lists the assets below src in the members of the group repository with
+// nexusapi.Client.ListGroupAssets, logging which member provides each path
+func listGroupAssets(client *nexusapi.Client, repository string, members []string, src string, recursive bool, keep nexusapi.ListFilter, opts *DownloadOptions) ([]nexusapi.Asset, error) {
+	assets, err := client.ListGroupAssets(members, src, recursive, searchSort(opts.Sort, opts.Direction), keep, func(asset nexusapi.Asset, provider string) {
+		opts.Logger.VerbosePrintf("Ignored %s in group member '%s', provided by '%s'\n", asset.Path, asset.Repository, provider)
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, asset := range assets {
+		opts.Logger.VerbosePrintf("Found %s in group member '%s' of '%s'\n", asset.Path, asset.Repository, repository)
+	}
+	return assets, nil
+}
+
+// checkUploadRepositories fails fast if any repository files are uploaded to will reject
+// them, before anything is transferred. If the settings of a repository cannot be read,
+// e.g. because the user lacks the privilege, the upload goes ahead and Nexus decides.
+func checkUploadRepositories(client *nexusapi.Client, repositories []string, opts *UploadOptions) error {
+	checked := make(map[string]bool)
+	for _, repository := range repositories {
+		if repository == "" || checked[repository] {
+			continue
+		}
+		checked[repository] = true
+		err := client.CheckWritable(repository)
+		var notWritable *nexusapi.NotWritableError
+		if errors.As(err, &notWritable) {
+			if notWritable.WritableMember != "" {
+				return err
+			}
+			return fmt.Errorf("%w (use --skip-write-check to upload anyway)", err)
+		}
+		if opts.continueOffline(err) {
+			return nil
+		}
+		if err != nil {
+			opts.Logger.VerbosePrintf("Could not check whether repository '%s' accepts uploads: %v\n", repository, err)
+		}
+	}
+	return nil
+}
+
+// uploadRepositories returns the repositories an upload to dest writes to, including
+// those of opts.Routes
+func uploadRepositories(dest string, opts *UploadOptions) []string {
+	repository, _, _ := strings.Cut(dest, "/")
+	repositories := []string{repository}
+	for _, route := range opts.Routes {
+		routeRepository, _, _ := strings.Cut(route.Dest, "/")
+		repositories = append(repositories, routeRepository)
+	}
+	return repositories
+}
+
+// checkUploadFolders fails if a destination folder of the upload, or a folder above it, is
+// an existing file asset. Files uploaded below it would end up nested under the path of
+// that file. dests are of the form 'repository' or 'repository/folder'.
+func checkUploadFolders(client *nexusapi.Client, dests []string, opts *UploadOptions) error {
+	for _, dest := range dests {
+		repository, folder, _ := strings.Cut(strings.Trim(dest, "/"), "/")
+		for folder = strings.Trim(folder, "/"); folder != "" && folder != "."; folder = path.Dir(folder) {
+			asset, err := client.GetAssetByPath(repository, folder)
+			if errors.Is(err, nexusapi.ErrAssetNotFound) {
+				continue
+			}
+			if opts.continueOffline(err) {
+				return nil
+			}
+			if err != nil {
+				opts.Logger.VerbosePrintf("Could not check whether '%s/%s' is a file: %v\n", repository, folder, err)
+				break
+			}
+			return fmt.Errorf("destination %s is below the existing file asset %s/%s (use --force to upload anyway)", dest, repository, strings.TrimPrefix(asset.Path, "/"))
+		}
+	}
+	return nil
+}
+
+// uploadFolders returns the destination folders an upload writes to, including those of
+// opts.Routes
+func uploadFolders(repository, subdir string, opts *UploadOptions) []string {
+	dests := []string{path.Join(repository, subdir)}
+	for _, route := range opts.Routes {
+		dests = append(dests, route.Dest)
+	}
+	return dests
+}
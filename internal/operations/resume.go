@@ -0,0 +1,188 @@
+package operations
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tympanix/nexus-cli/internal/nexusapi"
+)
+
+// resumeBlockSize is the size of the blocks of a resumable download whose checksums are
+// recorded, so a resumed download only fetches the blocks that do not match again
+const resumeBlockSize = 4 << 20
+
+// The partial file of a download with --resume is "." + its name + resumePartInfix, with
+// the checksums of its blocks next to it in "." + its name + resumeBlocksInfix. Both fit
+// in partFileOverhead and are left out by serve like the other partial files.
+const (
+	resumePartInfix   = ".part-resume"
+	resumeBlocksInfix = ".part-blocks"
+)
+
+// resumeBlocks is the sidecar of a resumable partial download. It holds the SHA-256 of
+// every complete block written to the partial file, recorded as each block is written,
+// so a download resumed after an interruption or crash can verify the data it reuses.
+type resumeBlocks struct {
+	Checksum  nexusapi.Checksum `json:"checksum"`   // Checksums of the asset, so a partial of another version is not reused
+	Size      int64             `json:"size"`       // Size of the asset
+	BlockSize int64             `json:"block_size"` // Size of each block
+	Blocks    []string          `json:"blocks"`     // SHA-256 of each complete block, in order
+}
+
+// resumableDownload is a download to a partial file that is kept when the transfer fails.
+// As a writer it records the checksums of the blocks of the data written to the partial
+// file, which must be written first.
+type resumableDownload struct {
+	partPath   string
+	blocksPath string
+	blocks     resumeBlocks
+	previous   []string // Block checksums recorded by an earlier attempt
+	block      hash.Hash
+	filled     int64 // Bytes of the current block written to block
+}
+
+// openResumable opens the partial file of localPath for a download of asset with
+// --resume. A partial file is reused if its sidecar was recorded for the same asset;
+// otherwise it is truncated. Like os.Create, a new file gets 0666 less the umask.
+func openResumable(localPath string, asset nexusapi.Asset, blockSize int64) (*os.File, *resumableDownload, error) {
+	prefix := filepath.Join(filepath.Dir(localPath), "."+filepath.Base(localPath))
+	r := &resumableDownload{
+		partPath:   prefix + resumePartInfix,
+		blocksPath: prefix + resumeBlocksInfix,
+		blocks:     resumeBlocks{Checksum: asset.Checksum, Size: asset.FileSize, BlockSize: blockSize},
+		block:      sha256.New(),
+	}
+	var recorded resumeBlocks
+	if data, err := os.ReadFile(r.blocksPath); err == nil && json.Unmarshal(data, &recorded) == nil &&
+		recorded.Checksum == asset.Checksum && recorded.Size == asset.FileSize && recorded.BlockSize == blockSize {
+		r.previous = recorded.Blocks
+	}
+	f, err := os.OpenFile(r.partPath, os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		return nil, nil, err
+	}
+	if r.previous == nil {
+		if err := f.Truncate(0); err != nil {
+			f.Close()
+			return nil, nil, err
+		}
+	}
+	return f, r, nil
+}
+
+// resume verifies the blocks of the partial file f that an earlier attempt recorded and
+// positions f after them, so the rest of the asset is appended. A block that is missing or
+// does not match its checksum, e.g. because it was not flushed before a crash, is fetched
+// again on its own. Every block is written to sums, so the checksums of the whole file are
+// computed in the same pass; fetched blocks are also written to transfer and reused ones
+// are reported to skip. It returns the offset to download the rest of the asset from.
+func (r *resumableDownload) resume(f *os.File, client *nexusapi.Client, asset nexusapi.Asset, sums, transfer io.Writer, skip func(int64)) (offset int64, fetched int, err error) {
+	buf := make([]byte, r.blocks.BlockSize)
+	for _, sum := range r.previous {
+		n, err := f.ReadAt(buf, offset)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return 0, 0, err
+		}
+		if actual := sha256.Sum256(buf[:n]); int64(n) < r.blocks.BlockSize || !strings.EqualFold(hex.EncodeToString(actual[:]), sum) {
+			block := &fixedBuffer{buf: buf[:0]}
+			if err := client.DownloadAssetRange(asset.DownloadURL, offset, r.blocks.BlockSize, io.MultiWriter(block, transfer)); err != nil {
+				return 0, 0, err
+			}
+			if _, err := f.WriteAt(block.buf, offset); err != nil {
+				return 0, 0, err
+			}
+			fetched++
+		} else {
+			skip(int64(n))
+		}
+		if _, err := sums.Write(buf); err != nil {
+			return 0, 0, err
+		}
+		offset += r.blocks.BlockSize
+	}
+	if err := f.Truncate(offset); err != nil {
+		return 0, 0, err
+	}
+	_, err = f.Seek(offset, io.SeekStart)
+	return offset, fetched, err
+}
+
+// Write records the checksums of the blocks of p. The sidecar is saved whenever a block
+// beyond those of the earlier attempt is complete.
+func (r *resumableDownload) Write(p []byte) (int, error) {
+	written := len(p)
+	for len(p) > 0 {
+		n := min(int64(len(p)), r.blocks.BlockSize-r.filled)
+		r.block.Write(p[:n])
+		r.filled += n
+		p = p[n:]
+		if r.filled < r.blocks.BlockSize {
+			break
+		}
+		r.blocks.Blocks = append(r.blocks.Blocks, hex.EncodeToString(r.block.Sum(nil)))
+		r.block.Reset()
+		r.filled = 0
+		if len(r.blocks.Blocks) > len(r.previous) {
+			if err := r.save(); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return written, nil
+}
+
+// save writes the sidecar
+func (r *resumableDownload) save() error {
+	data, err := json.Marshal(r.blocks)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(r.blocksPath, data, 0666); err != nil {
+		return fmt.Errorf("failed to record the blocks of %s: %w", r.partPath, err)
+	}
+	return nil
+}
+
+// remove removes the sidecar, e.g. once the download is in place. It does nothing for a
+// download that is not resumable.
+func (r *resumableDownload) remove() {
+	if r != nil {
+		os.Remove(r.blocksPath)
+	}
+}
+
+// resumeTarget returns the path of the file that a partial file or sidecar kept for a
+// download with --resume belongs to
+func resumeTarget(filePath string) (string, bool) {
+	name := filepath.Base(filepath.Clean(filePath))
+	if !strings.HasPrefix(name, ".") {
+		return "", false
+	}
+	for _, infix := range []string{resumePartInfix, resumeBlocksInfix} {
+		if target, ok := strings.CutSuffix(name[1:], infix); ok && target != "" {
+			return filepath.Join(filepath.Dir(filePath), target), true
+		}
+	}
+	return "", false
+}
+
+// fixedBuffer collects a block fetched again into the buffer of the block
+type fixedBuffer struct {
+	buf []byte
+}
+
+func (b *fixedBuffer) Write(p []byte) (int, error) {
+	if len(b.buf)+len(p) > cap(b.buf) {
+		return 0, fmt.Errorf("received more than the %d bytes of a block", cap(b.buf))
+	}
+	b.buf = append(b.buf, p...)
+	return len(p), nil
+}
@@ -0,0 +1,169 @@
+package operations
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/tympanix/nexus-cli/internal/config"
+	"github.com/tympanix/nexus-cli/internal/nexusapi"
+	"github.com/tympanix/nexus-cli/internal/util"
+)
+
+// resumeTestContent is 5 blocks and a bit of 16 bytes each
+var resumeTestContent = []byte("block 0 ........block 1 ........block 2 ........block 3 ........block 4 ........tail")
+
+// writeResumePartial leaves the partial file and sidecar of an interrupted download of
+// asset to localPath after the first n bytes, recorded as a resumed download records them
+func writeResumePartial(t *testing.T, localPath string, asset nexusapi.Asset, n int) string {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	f, r, err := openResumable(localPath, asset, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := io.MultiWriter(f, r).Write(resumeTestContent[:n]); err != nil {
+		t.Fatal(err)
+	}
+	return f.Name()
+}
+
+func TestDownloadResumeFetchesCorruptBlocksAgain(t *testing.T) {
+	for _, noRanges := range []bool{false, true} {
+		server := nexusapi.NewMockNexusServer()
+		defer server.Close()
+		server.NoRanges = noRanges
+		server.AddAsset("repo", "/builds/app.bin", nexusapi.Asset{}, resumeTestContent)
+		config := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+		asset := server.Assets["repo:/builds/app.bin"]
+
+		// An interrupted download wrote 3 blocks and part of the fourth, and block 1 was
+		// not flushed to disk before a crash
+		destDir := t.TempDir()
+		localPath := filepath.Join(destDir, "builds", "app.bin")
+		partPath := writeResumePartial(t, localPath, asset, 56)
+		f, err := os.OpenFile(partPath, os.O_WRONLY, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.WriteAt(bytes.Repeat([]byte{0}, 16), 16); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+
+		var logBuf strings.Builder
+		opts := &DownloadOptions{Logger: util.NewVerboseLogger(&logBuf), QuietMode: true, Recursive: true, Resume: true, blockSize: 16}
+		if status := downloadFolder("repo/builds", destDir, config, opts); status != DownloadSuccess {
+			t.Fatalf("Download failed with status %v: %s", status, logBuf.String())
+		}
+		content, err := os.ReadFile(localPath)
+		if err != nil || !bytes.Equal(content, resumeTestContent) {
+			t.Errorf("Expected the resumed file to be complete, got %q, %v", content, err)
+		}
+		// Only the corrupt block and the rest after the verified blocks are fetched
+		if ranges := strings.Join(server.GetDownloadRanges(), ","); ranges != "bytes=16-31,bytes=48-" {
+			t.Errorf("noRanges=%v: expected only block 1 and the rest to be fetched, got %s", noRanges, ranges)
+		}
+		if !strings.Contains(logBuf.String(), "Resuming "+localPath+" at 48 bytes, fetched 1 corrupt block(s) again") {
+			t.Errorf("Expected the resume to be logged, got: %s", logBuf.String())
+		}
+		entries, _ := os.ReadDir(filepath.Dir(localPath))
+		if len(entries) != 1 {
+			t.Errorf("Expected the partial file and its blocks to be removed, got %v", entries)
+		}
+	}
+}
+
+func TestDownloadResumeAfterInterruptedTransfer(t *testing.T) {
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+	server.AddAsset("repo", "/builds/app.bin", nexusapi.Asset{}, resumeTestContent)
+	config := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+
+	// The first download breaks off after 40 bytes
+	handler := server.Config.Handler
+	interrupted := false
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "/repository/") && !interrupted {
+			interrupted = true
+			w.Header().Set("Content-Length", strconv.Itoa(len(resumeTestContent)))
+			w.Write(resumeTestContent[:40])
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		}
+		handler.ServeHTTP(w, r)
+	})
+
+	destDir := t.TempDir()
+	localPath := filepath.Join(destDir, "builds", "app.bin")
+	opts := &DownloadOptions{Logger: util.NewLogger(io.Discard), QuietMode: true, Recursive: true, Resume: true, blockSize: 16}
+	if status := downloadFolder("repo/builds", destDir, config, opts); status == DownloadSuccess {
+		t.Fatal("Expected the interrupted download to fail")
+	}
+	if info, err := os.Stat(filepath.Join(destDir, "builds", ".app.bin.part-resume")); err != nil || info.Size() != 40 {
+		t.Fatalf("Expected the partial file to be kept, got %v, %v", info, err)
+	}
+
+	opts = &DownloadOptions{Logger: util.NewLogger(io.Discard), QuietMode: true, Recursive: true, Resume: true, blockSize: 16}
+	if status := downloadFolder("repo/builds", destDir, config, opts); status != DownloadSuccess {
+		t.Fatalf("Resumed download failed with status %v", status)
+	}
+	if content, err := os.ReadFile(localPath); err != nil || !bytes.Equal(content, resumeTestContent) {
+		t.Errorf("Expected the resumed file to be complete, got %q, %v", content, err)
+	}
+	// The 2 complete blocks are reused, the incomplete third is fetched with the rest
+	if ranges := strings.Join(server.GetDownloadRanges(), ","); ranges != "bytes=32-" {
+		t.Errorf("Expected the rest after the complete blocks to be fetched, got %s", ranges)
+	}
+}
+
+func TestDownloadResumeDiscardsOtherVersion(t *testing.T) {
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+	server.AddAsset("repo", "/builds/app.bin", nexusapi.Asset{}, resumeTestContent)
+	config := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+
+	// The partial was recorded for an earlier version of the asset
+	destDir := t.TempDir()
+	localPath := filepath.Join(destDir, "builds", "app.bin")
+	writeResumePartial(t, localPath, nexusapi.Asset{Checksum: nexusapi.Checksum{SHA1: "old"}, FileSize: int64(len(resumeTestContent))}, 48)
+
+	opts := &DownloadOptions{Logger: util.NewLogger(io.Discard), QuietMode: true, Recursive: true, Resume: true, blockSize: 16}
+	if status := downloadFolder("repo/builds", destDir, config, opts); status != DownloadSuccess {
+		t.Fatalf("Download failed with status %v", status)
+	}
+	if content, err := os.ReadFile(localPath); err != nil || !bytes.Equal(content, resumeTestContent) {
+		t.Errorf("Expected the file to be downloaded again, got %q, %v", content, err)
+	}
+	if ranges := server.GetDownloadRanges(); len(ranges) != 0 {
+		t.Errorf("Expected a full download, got ranges %v", ranges)
+	}
+}
+
+func TestDownloadResumeKeepsPartialOfListedFile(t *testing.T) {
+	destDir := t.TempDir()
+	listed := filepath.Join(destDir, "app.bin")
+	kept := []string{filepath.Join(destDir, ".app.bin.part-resume"), filepath.Join(destDir, ".app.bin.part-blocks")}
+	stale := filepath.Join(destDir, ".gone.bin.part-resume")
+	for _, name := range append(kept, stale) {
+		if err := os.WriteFile(name, []byte("partial"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	extra, err := extraFiles(destDir, map[string]bool{listed: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(extra) != 1 || extra[0] != stale {
+		t.Errorf("Expected only the partial file of an unlisted file to be extra, got %v", extra)
+	}
+}
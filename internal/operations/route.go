@@ -0,0 +1,110 @@
+package operations
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/tympanix/nexus-cli/internal/archive"
+	"github.com/tympanix/nexus-cli/internal/config"
+	"github.com/tympanix/nexus-cli/internal/util"
+)
+
+// UploadRoute sends the files whose path relative to the upload source matches Pattern
+// to Dest instead of the upload destination
+type UploadRoute struct {
+	Pattern string // Glob pattern(s) matched against the relative path (comma-separated, supports negation with !)
+	Dest    string // Destination in the form 'repository' or 'repository/folder'
+	glob    *util.GlobPattern
+}
+
+// ParseUploadRoute parses a route of the form 'pattern=dest', e.g. 'docs/**=repo/documentation'
+func ParseUploadRoute(s string) (UploadRoute, error) {
+	pattern, dest, ok := strings.Cut(s, "=")
+	pattern = strings.TrimSpace(pattern)
+	dest = strings.Trim(strings.TrimSpace(dest), "/")
+	if !ok || pattern == "" || dest == "" {
+		return UploadRoute{}, fmt.Errorf("invalid route '%s': must be in the form 'pattern=repository[/folder]'", s)
+	}
+	if err := util.ValidateGlobPattern(pattern); err != nil {
+		return UploadRoute{}, fmt.Errorf("invalid route '%s': %w", s, err)
+	}
+	return UploadRoute{Pattern: pattern, Dest: dest, glob: util.ParseGlobPattern(pattern)}, nil
+}
+
+// AddRoute parses and appends a route. Routes are tried in the order they were added.
+func (opts *UploadOptions) AddRoute(route string) error {
+	r, err := ParseUploadRoute(route)
+	if err != nil {
+		return err
+	}
+	opts.Routes = append(opts.Routes, r)
+	return nil
+}
+
+// routeGroup is the set of files sent to a single destination
+type routeGroup struct {
+	repository string
+	subdir     string
+	files      []string
+}
+
+// routeFiles assigns every file to the first route whose pattern matches its path relative
+// to src. Unmatched files go to the default destination. Groups are returned in route order
+// with the default last; destinations without files are left out.
+func routeFiles(src string, filePaths []string, routes []UploadRoute, defaultRepository, defaultSubdir string) ([]routeGroup, error) {
+	groups := make([]routeGroup, len(routes)+1)
+	for i, route := range routes {
+		groups[i].repository = route.Dest
+		if repository, subdir, ok := util.ParseRepositoryPath(route.Dest); ok {
+			groups[i].repository, groups[i].subdir = repository, subdir
+		}
+	}
+	groups[len(routes)] = routeGroup{repository: defaultRepository, subdir: defaultSubdir}
+
+	for _, filePath := range filePaths {
+		relPath, err := filepath.Rel(src, filePath)
+		if err != nil {
+			return nil, err
+		}
+		target := len(routes)
+		for i, route := range routes {
+			matched, err := route.glob.Match(relPath)
+			if err != nil {
+				return nil, err
+			}
+			if matched {
+				target = i
+				break
+			}
+		}
+		groups[target].files = append(groups[target].files, filePath)
+	}
+
+	var nonEmpty []routeGroup
+	for _, group := range groups {
+		if len(group.files) > 0 {
+			nonEmpty = append(nonEmpty, group)
+		}
+	}
+	return nonEmpty, nil
+}
+
+// uploadRoutedFiles splits the collected files by route and uploads each group to its
+// destination, so the source is only walked once however many routes there are
+func uploadRoutedFiles(src string, filePaths []string, unreadable []archive.UnreadableFile, repository, subdir string, config *config.Config, opts *UploadOptions) error {
+	groups, err := routeFiles(src, filePaths, opts.Routes, repository, subdir)
+	if err != nil {
+		return err
+	}
+	for i, group := range groups {
+		// Unreadable files are reported once, with the first destination
+		if i > 0 {
+			unreadable = nil
+		}
+		if err := uploadFileList(src, group.files, unreadable, group.repository, group.subdir, config, opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
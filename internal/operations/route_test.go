@@ -0,0 +1,114 @@
+package operations
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/tympanix/nexus-cli/internal/config"
+	"github.com/tympanix/nexus-cli/internal/nexusapi"
+	"github.com/tympanix/nexus-cli/internal/util"
+)
+
+func TestParseUploadRoute(t *testing.T) {
+	route, err := ParseUploadRoute("docs/**=repo/documentation/")
+	if err != nil {
+		t.Fatalf("ParseUploadRoute failed: %v", err)
+	}
+	if route.Pattern != "docs/**" || route.Dest != "repo/documentation" {
+		t.Errorf("Unexpected route: %+v", route)
+	}
+
+	for _, invalid := range []string{"docs/**", "=repo", "docs/**=", "docs/[a=repo"} {
+		if _, err := ParseUploadRoute(invalid); err == nil {
+			t.Errorf("Expected error for route %q", invalid)
+		}
+	}
+}
+
+func TestRouteFilesFirstMatchWins(t *testing.T) {
+	src := filepath.FromSlash("/src")
+	files := []string{
+		filepath.FromSlash("/src/docs/api/index.html"),
+		filepath.FromSlash("/src/docs/guide.md"),
+		filepath.FromSlash("/src/bin/app"),
+		filepath.FromSlash("/src/README.md"),
+	}
+	opts := &UploadOptions{}
+	for _, route := range []string{"docs/api/**=api-docs", "**/*.md=repo/markdown", "docs/**=repo/documentation"} {
+		if err := opts.AddRoute(route); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	groups, err := routeFiles(src, files, opts.Routes, "repo", "artifacts")
+	if err != nil {
+		t.Fatalf("routeFiles failed: %v", err)
+	}
+
+	got := make(map[string][]string)
+	for _, group := range groups {
+		dest := group.repository + "/" + group.subdir
+		for _, file := range group.files {
+			relPath, _ := filepath.Rel(src, file)
+			got[dest] = append(got[dest], filepath.ToSlash(relPath))
+		}
+	}
+	expected := map[string][]string{
+		"api-docs/":      {"docs/api/index.html"},
+		"repo/markdown":  {"docs/guide.md", "README.md"},
+		"repo/artifacts": {"bin/app"},
+	}
+	if len(got) != len(expected) {
+		t.Errorf("Expected %d groups, got %v", len(expected), got)
+	}
+	for dest, want := range expected {
+		if strings.Join(got[dest], ",") != strings.Join(want, ",") {
+			t.Errorf("Expected %s to receive %v, got %v", dest, want, got[dest])
+		}
+	}
+}
+
+func TestUploadWithRoutes(t *testing.T) {
+	testDir := t.TempDir()
+	for _, name := range []string{"docs/guide.md", "docs/img/logo.png", "bin/app", "VERSION"} {
+		fullPath := filepath.Join(testDir, name)
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fullPath, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+	config := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+
+	var logBuf strings.Builder
+	opts := &UploadOptions{Logger: util.NewLogger(&logBuf)}
+	if err := opts.AddRoute("docs/**=site/documentation"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := uploadFiles(testDir, "builds", "artifacts", config, opts); err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+
+	var uploaded []string
+	for _, file := range server.GetUploadedFiles() {
+		uploaded = append(uploaded, file.Repository+":"+file.Path)
+	}
+	sort.Strings(uploaded)
+	expected := []string{
+		"builds:/artifacts/VERSION",
+		"builds:/artifacts/bin/app",
+		"site:/documentation/docs/guide.md",
+		"site:/documentation/docs/img/logo.png",
+	}
+	if strings.Join(uploaded, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected uploads %v, got %v", expected, uploaded)
+	}
+}
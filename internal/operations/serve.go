@@ -0,0 +1,499 @@
+package operations
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/tympanix/nexus-cli/internal/checksum"
+	"github.com/tympanix/nexus-cli/internal/exitcode"
+	"github.com/tympanix/nexus-cli/internal/nexusapi"
+	"github.com/tympanix/nexus-cli/internal/util"
+)
+
+// servePageSize is the number of assets per page of a search, as in Nexus
+const servePageSize = 50
+
+// ServeOptions configures serving a local directory with serve
+type ServeOptions struct {
+	Logger     util.Logger
+	Addr       string // Address to listen on, e.g. "127.0.0.1:8080"
+	Repository string // Serve the directory as this repository instead of one repository per subdirectory
+	Username   string // Credentials clients must send with basic auth
+	Password   string
+	NoAuth     bool // Serve without authentication
+}
+
+// serveHandler serves a local directory with the URLs of Nexus a nexuscli-go client uses:
+// files are downloaded from /repository/<name>/<path> and found with a minimal search API
+// over an index of the directory. The directory is only read: any request other than GET
+// and HEAD is rejected, and paths cannot escape it, not even through symbolic links.
+type serveHandler struct {
+	root         *os.Root
+	repositories map[string]*serveIndex
+	opts         *ServeOptions
+}
+
+// serveIndex lists the files of a served repository, sorted by path, with their sizes and
+// checksums. It is built when the server starts and refreshed by every search, which walks
+// the directory but only hashes the files added or changed since, so files written while
+// serving are found without hashing the whole directory on every request.
+type serveIndex struct {
+	dir    string // Directory below the root
+	mu     sync.Mutex
+	files  []nexusapi.Asset
+	hashed map[string]servedFile // By name below the root
+}
+
+// servedFile is an indexed file with the size and modification time it was hashed at
+type servedFile struct {
+	asset   nexusapi.Asset
+	size    int64
+	modTime time.Time
+}
+
+// newServeHandler serves dir. Without opts.Repository every subdirectory of dir is a
+// repository of the same name; hidden directories are left out. Every file is read once
+// to index its checksums, and again only when it changes.
+func newServeHandler(dir string, opts *ServeOptions) (*serveHandler, error) {
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		return nil, err
+	}
+	h := &serveHandler{root: root, repositories: make(map[string]*serveIndex), opts: opts}
+	if opts.Repository != "" {
+		h.repositories[opts.Repository] = &serveIndex{dir: "."}
+	} else {
+		entries, err := fs.ReadDir(root.FS(), ".")
+		if err != nil {
+			root.Close()
+			return nil, err
+		}
+		for _, entry := range entries {
+			if entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
+				h.repositories[entry.Name()] = &serveIndex{dir: entry.Name()}
+			}
+		}
+		if len(h.repositories) == 0 {
+			root.Close()
+			return nil, fmt.Errorf("no repositories to serve: %s has no subdirectories; use --repository to serve it as a single repository", dir)
+		}
+	}
+	for name, index := range h.repositories {
+		if _, err := index.refresh(root, name); err != nil {
+			root.Close()
+			return nil, fmt.Errorf("failed to index repository %s: %w", name, err)
+		}
+	}
+	return h, nil
+}
+
+// refresh walks the directory of the index and returns its files. Files that are new or
+// whose size or modification time changed are hashed in parallel; the others keep their
+// checksums, and removed files are dropped.
+func (index *serveIndex) refresh(root *os.Root, repository string) ([]nexusapi.Asset, error) {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+
+	hashed := make(map[string]servedFile, len(index.hashed))
+	var changed []string
+	err := fs.WalkDir(root.FS(), index.dir, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.Type().IsRegular() || !servedPath(index.assetPath(name)) {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		if file, ok := index.hashed[name]; ok && file.size == info.Size() && file.modTime.Equal(info.ModTime()) {
+			hashed[name] = file
+		} else {
+			changed = append(changed, name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]servedFile, len(changed))
+	errs := make([]error, len(changed))
+	sem := make(chan struct{}, runtime.NumCPU())
+	var wg sync.WaitGroup
+	for i, name := range changed {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			files[i], errs[i] = indexServedFile(root, repository, name, index.assetPath(name))
+		}()
+	}
+	wg.Wait()
+	for i, name := range changed {
+		switch {
+		case errors.Is(errs[i], fs.ErrNotExist):
+			// Removed since the walk
+			errs[i] = nil
+		case errs[i] == nil:
+			hashed[name] = files[i]
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	index.hashed = hashed
+	index.files = make([]nexusapi.Asset, 0, len(hashed))
+	for _, file := range hashed {
+		index.files = append(index.files, file.asset)
+	}
+	sort.Slice(index.files, func(i, j int) bool { return index.files[i].Path < index.files[j].Path })
+	return index.files, nil
+}
+
+// assetPath returns the path within the repository of the file at name below the root
+func (index *serveIndex) assetPath(name string) string {
+	if index.dir == "." {
+		return name
+	}
+	return strings.TrimPrefix(name, index.dir+"/")
+}
+
+// indexServedFile describes a served file, computing all checksums in a single read. The
+// download URL is left for the response, which knows the host the client used.
+func indexServedFile(root *os.Root, repository, name, assetPath string) (servedFile, error) {
+	file, err := root.Open(filepath.FromSlash(name))
+	if err != nil {
+		return servedFile{}, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return servedFile{}, err
+	}
+	algorithms := []string{"sha1", "sha256", "sha512", "md5"}
+	writers := make([]io.Writer, len(algorithms))
+	sums := make(map[string]func() string, len(algorithms))
+	for i, algorithm := range algorithms {
+		hasher, err := checksum.NewHasher(algorithm)
+		if err != nil {
+			return servedFile{}, err
+		}
+		writers[i] = hasher
+		sums[algorithm] = func() string { return fmt.Sprintf("%x", hasher.Sum(nil)) }
+	}
+	if _, err := io.Copy(io.MultiWriter(writers...), file); err != nil {
+		return servedFile{}, err
+	}
+	asset := nexusapi.Asset{
+		Path:         assetPath,
+		ID:           repository + ":" + assetPath,
+		Repository:   repository,
+		Format:       "raw",
+		Checksum:     nexusapi.Checksum{SHA1: sums["sha1"](), SHA256: sums["sha256"](), SHA512: sums["sha512"](), MD5: sums["md5"]()},
+		ContentType:  serveContentType(assetPath),
+		LastModified: info.ModTime().UTC().Format(time.RFC3339),
+		FileSize:     info.Size(),
+	}
+	return servedFile{asset: asset, size: info.Size(), modTime: info.ModTime()}, nil
+}
+
+// Close releases the directory
+func (h *serveHandler) Close() error {
+	return h.root.Close()
+}
+
+// repositoryNames returns the names of the served repositories, sorted
+func (h *serveHandler) repositoryNames() []string {
+	names := make([]string, 0, len(h.repositories))
+	for name := range h.repositories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// fileCount returns the number of indexed files of all repositories
+func (h *serveHandler) fileCount() int {
+	count := 0
+	for _, index := range h.repositories {
+		index.mu.Lock()
+		count += len(index.files)
+		index.mu.Unlock()
+	}
+	return count
+}
+
+func (h *serveHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.opts.Logger.VerbosePrintf("%s %s\n", r.Method, r.URL.RequestURI())
+	if !h.opts.NoAuth {
+		username, password, ok := r.BasicAuth()
+		if !ok || subtle.ConstantTimeCompare([]byte(username), []byte(h.opts.Username)) != 1 || subtle.ConstantTimeCompare([]byte(password), []byte(h.opts.Password)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="nexuscli-go serve"`)
+			http.Error(w, "authentication required", http.StatusUnauthorized)
+			return
+		}
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "read-only: the served directory cannot be changed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	switch urlPath := r.URL.Path; {
+	case strings.HasPrefix(urlPath, "/repository/"):
+		h.serveFile(w, r, strings.TrimPrefix(urlPath, "/repository/"))
+	case urlPath == "/service/rest/v1/search/assets":
+		h.serveSearch(w, r)
+	case urlPath == "/service/rest/v1/repositories":
+		var repositories []nexusapi.Repository
+		for _, name := range h.repositoryNames() {
+			repositories = append(repositories, h.repository(r, name))
+		}
+		writeServeJSON(w, repositories)
+	case strings.HasPrefix(urlPath, "/service/rest/v1/repositories/"):
+		name := strings.TrimPrefix(urlPath, "/service/rest/v1/repositories/")
+		if _, ok := h.repositories[name]; !ok {
+			http.NotFound(w, r)
+			return
+		}
+		writeServeJSON(w, h.repository(r, name))
+	case urlPath == "/service/rest/v1/repositorySettings":
+		var statuses []nexusapi.RepositoryStatus
+		for _, name := range h.repositoryNames() {
+			statuses = append(statuses, nexusapi.RepositoryStatus{Name: name, Format: "raw", Type: "hosted", Online: true, Storage: &nexusapi.RepositoryStorage{WritePolicy: "DENY"}})
+		}
+		writeServeJSON(w, statuses)
+	case urlPath == "/service/rest/v1/status":
+		w.WriteHeader(http.StatusOK)
+	case urlPath == "/service/rest/v1/status/writable":
+		// Nexus answers 503 while it is read-only
+		http.Error(w, "read-only", http.StatusServiceUnavailable)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// repository describes a served repository
+func (h *serveHandler) repository(r *http.Request, name string) nexusapi.Repository {
+	online := true
+	return nexusapi.Repository{Name: name, Format: "raw", Type: "hosted", URL: serveBaseURL(r) + "/repository/" + name, Online: &online}
+}
+
+// serveFile serves the file at repository/path, with range and conditional requests
+func (h *serveHandler) serveFile(w http.ResponseWriter, r *http.Request, repositoryPath string) {
+	name, assetPath, _ := strings.Cut(repositoryPath, "/")
+	index, ok := h.repositories[name]
+	if !ok || assetPath == "" || !servedPath(assetPath) {
+		http.NotFound(w, r)
+		return
+	}
+	file, err := h.root.Open(filepath.FromSlash(path.Join(index.dir, assetPath)))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", serveContentType(assetPath))
+	http.ServeContent(w, r, path.Base(assetPath), info.ModTime(), file)
+}
+
+// serveSearch answers the search API for the assets of one repository from its index.
+// Like the Nexus search API, q and name match the path with "*" as a wildcard, name and
+// sort=name order by path, and the sha1, sha256, sha512 and md5 parameters filter by
+// checksum. Results are paged with continuation tokens, which are offsets into the
+// matching files. The index is refreshed first, see serveIndex.refresh.
+func (h *serveHandler) serveSearch(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	repository := query.Get("repository")
+	index, ok := h.repositories[repository]
+	if !ok {
+		http.Error(w, fmt.Sprintf("unknown repository '%s'", repository), http.StatusBadRequest)
+		return
+	}
+	files, err := index.refresh(h.root, repository)
+	if err != nil {
+		h.opts.Logger.Printf("Error: failed to index repository %s: %v\n", repository, err)
+		http.Error(w, "failed to index repository", http.StatusInternalServerError)
+		return
+	}
+	pattern := query.Get("name")
+	if pattern == "" {
+		pattern = query.Get("q")
+	}
+	offset := 0
+	if token := query.Get("continuationToken"); token != "" {
+		var err error
+		if offset, err = strconv.Atoi(token); err != nil || offset < 0 {
+			http.Error(w, "invalid continuation token", http.StatusBadRequest)
+			return
+		}
+	}
+	checksums := make(map[string]string)
+	for _, param := range []string{"sha1", "sha256", "sha512", "md5"} {
+		if value := query.Get(param); value != "" {
+			checksums[param] = value
+		}
+	}
+
+	matches := func(asset nexusapi.Asset) bool {
+		if pattern != "" && !matchServePattern(pattern, "/"+asset.Path) {
+			return false
+		}
+		for algorithm, value := range checksums {
+			if !strings.EqualFold(value, checksum.ExtractChecksum(asset.Checksum, algorithm)) {
+				return false
+			}
+		}
+		return true
+	}
+	// The index is sorted by path, so matching stops at the end of the page
+	descending := query.Get("direction") == "desc"
+	response := nexusapi.SearchResponse{Items: []nexusapi.Asset{}}
+	matched := 0
+	for i := range files {
+		asset := files[i]
+		if descending {
+			asset = files[len(files)-1-i]
+		}
+		if !matches(asset) {
+			continue
+		}
+		if matched == offset+servePageSize {
+			response.ContinuationToken = strconv.Itoa(matched)
+			break
+		}
+		if matched >= offset {
+			asset.DownloadURL = serveBaseURL(r) + "/repository/" + repository + "/" + nexusapi.EscapePath(asset.Path)
+			response.Items = append(response.Items, asset)
+		}
+		matched++
+	}
+	writeServeJSON(w, response)
+}
+
+// servedPath reports whether a file is served. Files a download is still writing, such
+// as .<name>.part-*, are left out.
+func servedPath(assetPath string) bool {
+	name := path.Base(assetPath)
+	if !strings.HasPrefix(name, ".") {
+		return true
+	}
+	return !strings.Contains(name, ".part-") && !strings.Contains(name, ".tmp-")
+}
+
+// matchServePattern matches a path against a search pattern in which "*" matches any
+// sequence of characters, including "/". The match backtracks only to the last "*" seen,
+// so it takes at most len(pattern)*len(assetPath) steps whatever the pattern.
+func matchServePattern(pattern, assetPath string) bool {
+	p, n := 0, 0
+	star, resume := -1, 0
+	for n < len(assetPath) {
+		switch {
+		case p < len(pattern) && pattern[p] == '*':
+			star, resume = p, n
+			p++
+		case p < len(pattern) && pattern[p] == assetPath[n]:
+			p++
+			n++
+		case star >= 0:
+			// Let the last "*" match one more character
+			resume++
+			p, n = star+1, resume
+		default:
+			return false
+		}
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
+// serveContentType returns the Content-Type of a served file by its extension
+func serveContentType(assetPath string) string {
+	if contentType := mime.TypeByExtension(path.Ext(assetPath)); contentType != "" {
+		return contentType
+	}
+	return "application/octet-stream"
+}
+
+// serveBaseURL returns the URL the client reached the server at, for download URLs
+func serveBaseURL(r *http.Request) string {
+	if r.TLS != nil {
+		return "https://" + r.Host
+	}
+	return "http://" + r.Host
+}
+
+func writeServeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// Serve serves dir read-only over HTTP on opts.Addr until ctx is done
+func Serve(ctx context.Context, dir string, opts *ServeOptions) error {
+	if !opts.NoAuth && (opts.Username == "" || opts.Password == "") {
+		return errors.New("serve requires credentials for basic auth: set --username and --password (or NEXUS_USER and NEXUS_PASS), or use --no-auth")
+	}
+	opts.Logger.Printf("Indexing %s\n", dir)
+	handler, err := newServeHandler(dir, opts)
+	if err != nil {
+		return err
+	}
+	defer handler.Close()
+
+	listener, err := net.Listen("tcp", opts.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", opts.Addr, err)
+	}
+	server := &http.Server{Handler: handler, ReadHeaderTimeout: 30 * time.Second}
+	opts.Logger.Printf("Serving %s read-only on http://%s, repositories: %s (%d files)\n", dir, listener.Addr(), strings.Join(handler.repositoryNames(), ", "), handler.fileCount())
+
+	done := make(chan error, 1)
+	go func() { done <- server.Serve(listener) }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return server.Shutdown(shutdownCtx)
+	}
+}
+
+// ServeMain serves dir until the process is interrupted
+func ServeMain(dir string, opts *ServeOptions) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := Serve(ctx, dir, opts); err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(exitcode.Error)
+	}
+}
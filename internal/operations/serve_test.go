@@ -0,0 +1,250 @@
+package operations
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tympanix/nexus-cli/internal/config"
+	"github.com/tympanix/nexus-cli/internal/nexusapi"
+	"github.com/tympanix/nexus-cli/internal/util"
+)
+
+// newServeTestServer serves dir with the credentials test/test
+func newServeTestServer(t *testing.T, dir string, opts *ServeOptions) *httptest.Server {
+	t.Helper()
+	opts.Logger = util.NewLogger(io.Discard)
+	if !opts.NoAuth {
+		opts.Username, opts.Password = "test", "test"
+	}
+	handler, err := newServeHandler(dir, opts)
+	if err != nil {
+		t.Fatalf("newServeHandler: %v", err)
+	}
+	server := httptest.NewServer(handler)
+	t.Cleanup(func() {
+		server.Close()
+		handler.Close()
+	})
+	return server
+}
+
+func writeServeTestFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestServeDownload(t *testing.T) {
+	mirrorDir := t.TempDir()
+	writeServeTestFiles(t, mirrorDir, map[string]string{
+		"libs/app/1.0/app.zip":           "app 1.0",
+		"libs/app/1.0/app zip.txt":       "notes",
+		"libs/app/2.0/app.zip":           "app 2.0",
+		"libs/app/2.0/.app.zip.part-123": "partial",
+		"tools/tool.bin":                 "tool",
+	})
+	server := newServeTestServer(t, mirrorDir, &ServeOptions{})
+	cfg := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+
+	destDir := t.TempDir()
+	opts := &DownloadOptions{ChecksumAlgorithm: "sha256", Logger: util.NewLogger(io.Discard), QuietMode: true, Recursive: true}
+	if status := Download("libs/app", destDir, cfg, opts); status != DownloadSuccess {
+		t.Fatalf("Download from the mirror failed: %v", status)
+	}
+	for name, content := range map[string]string{"app/1.0/app.zip": "app 1.0", "app/1.0/app zip.txt": "notes", "app/2.0/app.zip": "app 2.0"} {
+		data, err := os.ReadFile(filepath.Join(destDir, filepath.FromSlash(name)))
+		if err != nil || string(data) != content {
+			t.Errorf("Expected %s to be downloaded with %q, got %q (%v)", name, content, data, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(destDir, "app/2.0/.app.zip.part-123")); err == nil {
+		t.Error("Expected partial download files not to be served")
+	}
+
+	// A second download compares the local files with the checksums of the search
+	opts = &DownloadOptions{ChecksumAlgorithm: "sha256", Logger: util.NewLogger(io.Discard), QuietMode: true, Recursive: true}
+	if status := Download("libs/app", destDir, cfg, opts); status != DownloadSuccess {
+		t.Fatalf("Second download from the mirror failed: %v", status)
+	}
+
+	// Wrong credentials are rejected
+	cfg.Password = "wrong"
+	if status := Download("tools", t.TempDir(), cfg, &DownloadOptions{ChecksumAlgorithm: "sha1", Logger: util.NewLogger(io.Discard), QuietMode: true, Recursive: true}); status == DownloadSuccess {
+		t.Error("Expected a download with wrong credentials to fail")
+	}
+}
+
+func TestServeReadOnly(t *testing.T) {
+	mirrorDir := t.TempDir()
+	writeServeTestFiles(t, mirrorDir, map[string]string{"repo/file.txt": "content"})
+	outside := filepath.Join(filepath.Dir(mirrorDir), "outside.txt")
+	os.WriteFile(outside, []byte("secret"), 0644)
+	server := newServeTestServer(t, mirrorDir, &ServeOptions{})
+
+	for _, tc := range []struct {
+		method, path string
+		auth         bool
+		expected     int
+	}{
+		{http.MethodGet, "/repository/repo/file.txt", true, http.StatusOK},
+		{http.MethodHead, "/repository/repo/file.txt", true, http.StatusOK},
+		{http.MethodGet, "/repository/repo/file.txt", false, http.StatusUnauthorized},
+		{http.MethodPut, "/repository/repo/file.txt", true, http.StatusMethodNotAllowed},
+		{http.MethodDelete, "/service/rest/v1/assets/repo:file.txt", true, http.StatusMethodNotAllowed},
+		{http.MethodPost, "/service/rest/v1/components?repository=repo", true, http.StatusMethodNotAllowed},
+		{http.MethodGet, "/repository/repo/missing.txt", true, http.StatusNotFound},
+		{http.MethodGet, "/repository/repo/%2e%2e/%2e%2e/outside.txt", true, http.StatusNotFound},
+		{http.MethodGet, "/repository/other/file.txt", true, http.StatusNotFound},
+		{http.MethodGet, "/service/rest/v1/status/writable", true, http.StatusServiceUnavailable},
+	} {
+		req, _ := http.NewRequest(tc.method, server.URL+tc.path, nil)
+		if tc.auth {
+			req.SetBasicAuth("test", "test")
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s: %v", tc.method, tc.path, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tc.expected {
+			t.Errorf("%s %s: expected status %d, got %d", tc.method, tc.path, tc.expected, resp.StatusCode)
+		}
+	}
+	if data, _ := os.ReadFile(filepath.Join(mirrorDir, "repo/file.txt")); string(data) != "content" {
+		t.Errorf("Expected the served file to be unchanged, got %q", data)
+	}
+}
+
+func TestServeSearch(t *testing.T) {
+	mirrorDir := t.TempDir()
+	files := make(map[string]string)
+	for i := range 120 {
+		files[fmt.Sprintf("pkg/%03d.bin", i)] = fmt.Sprintf("content %d", i)
+	}
+	writeServeTestFiles(t, mirrorDir, files)
+	server := newServeTestServer(t, mirrorDir, &ServeOptions{Repository: "mirror", NoAuth: true})
+
+	search := func(query string) nexusapi.SearchResponse {
+		t.Helper()
+		resp, err := http.Get(server.URL + "/service/rest/v1/search/assets?" + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var response nexusapi.SearchResponse
+		if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+			t.Fatalf("Search %s: %v", query, err)
+		}
+		return response
+	}
+
+	var paths []string
+	token := ""
+	for pages := 0; pages == 0 || token != ""; pages++ {
+		response := search("repository=mirror&q=/pkg/*&continuationToken=" + token)
+		for _, asset := range response.Items {
+			paths = append(paths, asset.Path)
+		}
+		token = response.ContinuationToken
+	}
+	if len(paths) != 120 || paths[0] != "pkg/000.bin" || paths[119] != "pkg/119.bin" {
+		t.Errorf("Expected all 120 assets in order across pages, got %d: %v...", len(paths), paths[:min(len(paths), 3)])
+	}
+
+	response := search("repository=mirror&sha1=" + fmt.Sprintf("%x", sha1.Sum([]byte("content 7"))))
+	if len(response.Items) != 1 || response.Items[0].Path != "pkg/007.bin" || response.Items[0].Checksum.SHA256 == "" {
+		t.Errorf("Expected a search by sha1 to find pkg/007.bin with all checksums, got %+v", response.Items)
+	}
+	if response := search("repository=mirror&sort=name&direction=desc&name=/pkg/11*"); len(response.Items) != 10 || response.Items[0].Path != "pkg/119.bin" {
+		t.Errorf("Expected a descending search by name, got %+v", response.Items)
+	}
+}
+
+func TestMatchServePattern(t *testing.T) {
+	for _, tc := range []struct {
+		pattern, path string
+		expected      bool
+	}{
+		{"/pkg/*", "/pkg/a/b.bin", true},
+		{"/pkg/*.bin", "/pkg/a/b.bin", true},
+		{"/pkg/*.bin", "/pkg/a/b.txt", false},
+		{"*a*b", "/xaxxb", true},
+		{"*a*b", "/xaxxbc", false},
+		{"/exact.txt", "/exact.txt", true},
+		{"/exact.txt", "/exact.txt2", false},
+		{"**", "", true},
+	} {
+		if got := matchServePattern(tc.pattern, tc.path); got != tc.expected {
+			t.Errorf("matchServePattern(%q, %q) = %v, expected %v", tc.pattern, tc.path, got, tc.expected)
+		}
+	}
+
+	// A pattern with many wildcards is matched without exponential backtracking
+	start := time.Now()
+	if matchServePattern(strings.Repeat("*a", 30)+"*b", "/"+strings.Repeat("a", 5000)) {
+		t.Error("Expected the pattern not to match")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected the match to be fast, took %s", elapsed)
+	}
+}
+
+func TestServeIndex(t *testing.T) {
+	mirrorDir := t.TempDir()
+	writeServeTestFiles(t, mirrorDir, map[string]string{"repo/a.txt": "indexed", "repo/b.txt": "removed", "repo/.hidden/c.txt": "hidden dir"})
+	server := newServeTestServer(t, mirrorDir, &ServeOptions{NoAuth: true})
+
+	search := func() map[string]string {
+		t.Helper()
+		resp, err := http.Get(server.URL + "/service/rest/v1/search/assets?repository=repo")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var response nexusapi.SearchResponse
+		if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+			t.Fatal(err)
+		}
+		checksums := make(map[string]string)
+		for _, asset := range response.Items {
+			checksums[asset.Path] = asset.Checksum.SHA1
+		}
+		return checksums
+	}
+	sum := func(content string) string { return fmt.Sprintf("%x", sha1.Sum([]byte(content))) }
+
+	if got := search(); len(got) != 3 || got["a.txt"] != sum("indexed") {
+		t.Errorf("Expected the files indexed at start, got %v", got)
+	}
+
+	// Searches see files changed, added and removed while serving. The change keeps the
+	// size, so only the modification time tells it apart.
+	writeServeTestFiles(t, mirrorDir, map[string]string{"repo/a.txt": "changed", "repo/sub/d.txt": "added later"})
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(filepath.Join(mirrorDir, "repo", "a.txt"), later, later); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(mirrorDir, "repo", "b.txt")); err != nil {
+		t.Fatal(err)
+	}
+	got := search()
+	if len(got) != 3 || got["a.txt"] != sum("changed") || got["sub/d.txt"] != sum("added later") || got[".hidden/c.txt"] != sum("hidden dir") {
+		t.Errorf("Expected the changed, added and unchanged files, got %v", got)
+	}
+}
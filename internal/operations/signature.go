@@ -0,0 +1,135 @@
+package operations
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/tympanix/nexus-cli/internal/nexusapi"
+)
+
+// SignatureSuffix is the suffix of the detached signature stored next to a signed asset
+const SignatureSuffix = ".asc"
+
+// signatureVerifier checks downloaded files against the detached OpenPGP signatures
+// stored next to them in Nexus
+type signatureVerifier struct {
+	keyring    openpgp.EntityList
+	keyFile    string
+	repository string
+	listed     map[string]nexusapi.Asset // Assets of the listing by path, to find signatures without a search
+}
+
+// loadPublicKeys reads the OpenPGP public keys in keyFile, armored or binary
+func loadPublicKeys(keyFile string) (openpgp.EntityList, error) {
+	data, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	var keyring openpgp.EntityList
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("-----BEGIN")) {
+		keyring, err = openpgp.ReadArmoredKeyRing(bytes.NewReader(data))
+	} else {
+		keyring, err = openpgp.ReadKeyRing(bytes.NewReader(data))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid public key %s: %w", keyFile, err)
+	}
+	if len(keyring) == 0 {
+		return nil, fmt.Errorf("no public key found in %s", keyFile)
+	}
+	return keyring, nil
+}
+
+// newSignatureVerifier reads the public keys in keyFile to verify the signatures of the
+// given assets of repository with
+func newSignatureVerifier(keyFile, repository string, assets []nexusapi.Asset) (*signatureVerifier, error) {
+	keyring, err := loadPublicKeys(keyFile)
+	if err != nil {
+		return nil, err
+	}
+	listed := make(map[string]nexusapi.Asset, len(assets))
+	for _, asset := range assets {
+		listed[strings.TrimLeft(asset.Path, "/")] = asset
+	}
+	return &signatureVerifier{keyring: keyring, keyFile: keyFile, repository: repository, listed: listed}, nil
+}
+
+// expect fetches the signature of asset and starts checking it against the data written to
+// the returned check. It returns nil if signatures are not verified or asset is a
+// signature itself, and an error if the signature is missing or cannot be downloaded.
+func (v *signatureVerifier) expect(client *nexusapi.Client, asset nexusapi.Asset) (*signatureCheck, error) {
+	if v == nil || strings.HasSuffix(asset.Path, SignatureSuffix) {
+		return nil, nil
+	}
+	sigPath := strings.TrimLeft(asset.Path, "/") + SignatureSuffix
+	sigAsset, ok := v.listed[sigPath]
+	if !ok {
+		found, err := findAsset(client, v.repository, sigPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up signature %s: %w", sigPath, err)
+		}
+		if found == nil {
+			return nil, fmt.Errorf("missing signature for %s: %s not found", asset.Path, sigPath)
+		}
+		sigAsset = *found
+	}
+	var signature bytes.Buffer
+	if err := client.DownloadAsset(sigAsset.DownloadURL, &signature); err != nil {
+		return nil, fmt.Errorf("failed to download signature %s: %w", sigPath, err)
+	}
+
+	// The signature is checked while the data is downloaded, so the file is not read back
+	pr, pw := io.Pipe()
+	check := &signatureCheck{name: asset.Path, pw: pw, done: make(chan error, 1)}
+	go func() {
+		var err error
+		if bytes.HasPrefix(bytes.TrimSpace(signature.Bytes()), []byte("-----BEGIN")) {
+			_, err = openpgp.CheckArmoredDetachedSignature(v.keyring, pr, &signature, nil)
+		} else {
+			_, err = openpgp.CheckDetachedSignature(v.keyring, pr, &signature, nil)
+		}
+		// Keep consuming the data so the download is not blocked by a check that ended early
+		io.Copy(io.Discard, pr)
+		check.done <- err
+	}()
+	return check, nil
+}
+
+// signatureCheck is the verification of one downloaded file against its signature
+type signatureCheck struct {
+	name string
+	pw   *io.PipeWriter
+	done chan error
+}
+
+// writer returns the writer to write the downloaded data to, or nil
+func (c *signatureCheck) writer() io.Writer {
+	if c == nil {
+		return nil
+	}
+	return c.pw
+}
+
+// verify waits for the check of the data written so far and returns its result
+func (c *signatureCheck) verify() error {
+	if c == nil {
+		return nil
+	}
+	c.pw.Close()
+	if err := <-c.done; err != nil {
+		return fmt.Errorf("invalid signature for %s: %w", c.name, err)
+	}
+	return nil
+}
+
+// close ends a check that is not verified, e.g. because the download failed. It is
+// a no-op once the check has been verified.
+func (c *signatureCheck) close() {
+	if c != nil {
+		c.pw.CloseWithError(io.ErrUnexpectedEOF)
+	}
+}
@@ -0,0 +1,166 @@
+package operations
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/tympanix/nexus-cli/internal/config"
+	"github.com/tympanix/nexus-cli/internal/nexusapi"
+	"github.com/tympanix/nexus-cli/internal/util"
+)
+
+// newSigningKey generates a key pair and writes its armored public key to a file
+func newSigningKey(t *testing.T) (*openpgp.Entity, string) {
+	t.Helper()
+	entity, err := openpgp.NewEntity("Test Release", "", "release@nexus.local", &packet.Config{Algorithm: packet.PubKeyAlgoEdDSA})
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	var buf bytes.Buffer
+	w, err := armor.Encode(&buf, openpgp.PublicKeyType, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := entity.Serialize(w); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	keyFile := filepath.Join(t.TempDir(), "release.asc")
+	if err := os.WriteFile(keyFile, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return entity, keyFile
+}
+
+// sign returns the detached signature of content, armored or binary
+func sign(t *testing.T, entity *openpgp.Entity, content string, armored bool) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var err error
+	if armored {
+		err = openpgp.ArmoredDetachSign(&buf, entity, strings.NewReader(content), nil)
+	} else {
+		err = openpgp.DetachSign(&buf, entity, strings.NewReader(content), nil)
+	}
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func newSignatureDownloadOptions(keyFile string) *DownloadOptions {
+	return &DownloadOptions{
+		Logger:          util.NewLogger(io.Discard),
+		QuietMode:       true,
+		Recursive:       true,
+		VerifySignature: true,
+		PublicKeyFile:   keyFile,
+	}
+}
+
+func TestDownloadVerifySignature(t *testing.T) {
+	entity, keyFile := newSigningKey(t)
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+	server.AddAsset("releases", "/app/app.tar.gz", nexusapi.Asset{}, []byte("release archive"))
+	server.AddAsset("releases", "/app/app.tar.gz.asc", nexusapi.Asset{}, sign(t, entity, "release archive", true))
+	server.AddAsset("releases", "/app/notes.txt", nexusapi.Asset{}, []byte("release notes"))
+	server.AddAsset("releases", "/app/notes.txt.asc", nexusapi.Asset{}, sign(t, entity, "release notes", false))
+	config := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+
+	destDir := t.TempDir()
+	if status := downloadFolder("releases/app", destDir, config, newSignatureDownloadOptions(keyFile)); status != DownloadSuccess {
+		t.Fatalf("Expected download with valid signatures to succeed, got %v", status)
+	}
+	for _, name := range []string{"app.tar.gz", "app.tar.gz.asc", "notes.txt", "notes.txt.asc"} {
+		if _, err := os.Stat(filepath.Join(destDir, "app", name)); err != nil {
+			t.Errorf("Expected %s to be downloaded: %v", name, err)
+		}
+	}
+
+	// The signature is found even if the glob excludes it
+	opts := newSignatureDownloadOptions(keyFile)
+	opts.GlobPattern = "*.tar.gz"
+	globDir := t.TempDir()
+	if status := downloadFolder("releases/app", globDir, config, opts); status != DownloadSuccess {
+		t.Fatalf("Expected download of a filtered file to succeed, got %v", status)
+	}
+	if _, err := os.Stat(filepath.Join(globDir, "app", "app.tar.gz.asc")); !os.IsNotExist(err) {
+		t.Error("Expected the signature not to be downloaded when the glob excludes it")
+	}
+
+	// The signature of a single file is looked up next to it
+	opts = newSignatureDownloadOptions(keyFile)
+	opts.Recursive = false
+	if status := downloadFolder("releases/app/notes.txt", t.TempDir(), config, opts); status != DownloadSuccess {
+		t.Fatalf("Expected download of a single signed file to succeed, got %v", status)
+	}
+}
+
+func TestDownloadVerifySignatureRejected(t *testing.T) {
+	entity, keyFile := newSigningKey(t)
+	other, _ := newSigningKey(t)
+
+	tests := []struct {
+		name      string
+		signature []byte // nil for no signature
+	}{
+		{"tampered", sign(t, entity, "another release", true)},
+		{"other key", sign(t, other, "release archive", true)},
+		{"missing", nil},
+		{"garbage", []byte("not a signature")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := nexusapi.NewMockNexusServer()
+			defer server.Close()
+			server.AddAsset("releases", "/app/app.tar.gz", nexusapi.Asset{}, []byte("release archive"))
+			if tt.signature != nil {
+				server.AddAsset("releases", "/app/app.tar.gz.asc", nexusapi.Asset{}, tt.signature)
+			}
+			config := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+
+			destDir := t.TempDir()
+			opts := newSignatureDownloadOptions(keyFile)
+			opts.GlobPattern = "*.tar.gz"
+			if status := downloadFolder("releases/app", destDir, config, opts); status != DownloadError {
+				t.Fatalf("Expected download to fail, got %v", status)
+			}
+			if _, err := os.Stat(filepath.Join(destDir, "app", "app.tar.gz")); !os.IsNotExist(err) {
+				t.Error("Expected the file not to be written")
+			}
+		})
+	}
+}
+
+func TestDownloadWithoutVerifySignatureIgnoresSignatures(t *testing.T) {
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+	server.AddAsset("releases", "/app/app.tar.gz", nexusapi.Asset{}, []byte("release archive"))
+	config := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+
+	opts := &DownloadOptions{Logger: util.NewLogger(io.Discard), QuietMode: true, Recursive: true}
+	if status := downloadFolder("releases/app", t.TempDir(), config, opts); status != DownloadSuccess {
+		t.Fatalf("Expected an unsigned download to succeed without --verify-signature, got %v", status)
+	}
+}
+
+func TestLoadPublicKeysInvalid(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "key.asc")
+	if err := os.WriteFile(keyFile, []byte("-----BEGIN PGP PUBLIC KEY BLOCK-----\n\nnope\n-----END PGP PUBLIC KEY BLOCK-----\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadPublicKeys(keyFile); err == nil {
+		t.Error("Expected an error for an invalid key file")
+	}
+	if _, err := loadPublicKeys(filepath.Join(t.TempDir(), "missing.asc")); err == nil {
+		t.Error("Expected an error for a missing key file")
+	}
+}
@@ -0,0 +1,109 @@
+package operations
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tympanix/nexus-cli/internal/nexusapi"
+)
+
+// SortKey selects the order in which assets are listed and downloaded
+type SortKey string
+
+const (
+	SortName       SortKey = "name"       // Sort by asset path (sorted by Nexus)
+	SortVersion    SortKey = "version"    // Sort by component version (sorted by Nexus)
+	SortGroup      SortKey = "group"      // Sort by component group (sorted by Nexus)
+	SortRepository SortKey = "repository" // Sort by repository, e.g. for group repositories (sorted by Nexus)
+	SortModified   SortKey = "modified"   // Sort by last modification time (sorted locally)
+	SortSize       SortKey = "size"       // Sort by file size (sorted locally)
+)
+
+// ParseSortKey parses a string into a SortKey. An empty string keeps the default order.
+func ParseSortKey(s string) (SortKey, error) {
+	switch key := SortKey(strings.ToLower(s)); key {
+	case "", SortName, SortVersion, SortGroup, SortRepository, SortModified, SortSize:
+		return key, nil
+	default:
+		return "", fmt.Errorf("unsupported sort key '%s': must be one of: name, version, group, repository, modified, size", s)
+	}
+}
+
+// SortDirection selects ascending or descending order
+type SortDirection string
+
+const (
+	SortAscending  SortDirection = "asc"
+	SortDescending SortDirection = "desc"
+)
+
+// ParseSortDirection parses a string into a SortDirection. An empty string means ascending.
+func ParseSortDirection(s string) (SortDirection, error) {
+	switch strings.ToLower(s) {
+	case "", "asc":
+		return SortAscending, nil
+	case "desc":
+		return SortDescending, nil
+	default:
+		return "", fmt.Errorf("unsupported sort direction '%s': must be one of: asc, desc", s)
+	}
+}
+
+// searchSort returns the order to request from the Nexus search API. The search API
+// only sorts by name, version, group and repository; for any other key the default
+// order is requested and the assets are sorted by sortAssets instead.
+func searchSort(key SortKey, direction SortDirection) nexusapi.SearchSort {
+	switch key {
+	case SortName, SortVersion, SortGroup, SortRepository:
+		if direction == "" {
+			direction = SortAscending
+		}
+		return nexusapi.SearchSort{Key: string(key), Direction: string(direction)}
+	default:
+		return nexusapi.DefaultSearchSort
+	}
+}
+
+// sortAssets sorts assets locally by key. This covers keys the search API cannot sort
+// by and restores the order after assets of several repositories have been merged.
+// Version and group are not part of the asset metadata, so the order returned by
+// Nexus is kept for them.
+func sortAssets(assets []nexusapi.Asset, key SortKey, direction SortDirection) {
+	var less func(a, b nexusapi.Asset) bool
+	switch key {
+	case SortName:
+		less = func(a, b nexusapi.Asset) bool { return a.Path < b.Path }
+	case SortRepository:
+		less = func(a, b nexusapi.Asset) bool {
+			if a.Repository != b.Repository {
+				return a.Repository < b.Repository
+			}
+			return a.Path < b.Path
+		}
+	case SortModified:
+		less = func(a, b nexusapi.Asset) bool { return lastModified(a).Before(lastModified(b)) }
+	case SortSize:
+		less = func(a, b nexusapi.Asset) bool { return a.FileSize < b.FileSize }
+	default:
+		return
+	}
+
+	sort.SliceStable(assets, func(i, j int) bool {
+		if direction == SortDescending {
+			return less(assets[j], assets[i])
+		}
+		return less(assets[i], assets[j])
+	})
+}
+
+// lastModified parses the modification time reported by Nexus. Assets without a
+// valid timestamp sort as the oldest.
+func lastModified(asset nexusapi.Asset) time.Time {
+	t, err := time.Parse(time.RFC3339, asset.LastModified)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
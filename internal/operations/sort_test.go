@@ -0,0 +1,78 @@
+package operations
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/tympanix/nexus-cli/internal/config"
+	"github.com/tympanix/nexus-cli/internal/nexusapi"
+	"github.com/tympanix/nexus-cli/internal/util"
+)
+
+func TestParseSortKeyAndDirection(t *testing.T) {
+	if key, err := ParseSortKey("Modified"); err != nil || key != SortModified {
+		t.Errorf("ParseSortKey(Modified) = %q, %v", key, err)
+	}
+	if key, err := ParseSortKey(""); err != nil || key != "" {
+		t.Errorf("ParseSortKey(\"\") = %q, %v; want default", key, err)
+	}
+	if _, err := ParseSortKey("date"); err == nil {
+		t.Error("Expected error for unsupported sort key")
+	}
+	if dir, err := ParseSortDirection(""); err != nil || dir != SortAscending {
+		t.Errorf("ParseSortDirection(\"\") = %q, %v", dir, err)
+	}
+	if dir, err := ParseSortDirection("DESC"); err != nil || dir != SortDescending {
+		t.Errorf("ParseSortDirection(DESC) = %q, %v", dir, err)
+	}
+	if _, err := ParseSortDirection("down"); err == nil {
+		t.Error("Expected error for unsupported sort direction")
+	}
+}
+
+func TestListDownloadAssetsSortOrder(t *testing.T) {
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+	server.AddAsset("builds", "/app/1.bin", nexusapi.Asset{LastModified: "2025-01-01T10:00:00.000+00:00", FileSize: 30}, nil)
+	server.AddAsset("builds", "/app/2.bin", nexusapi.Asset{LastModified: "2025-03-01T10:00:00.000+00:00", FileSize: 10}, nil)
+	server.AddAsset("builds", "/app/3.bin", nexusapi.Asset{LastModified: "2025-02-01T10:00:00.000+00:00", FileSize: 20}, nil)
+	config := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+
+	tests := []struct {
+		key       SortKey
+		direction SortDirection
+		wantSort  string // Sort parameter sent to Nexus
+		wantDir   string
+		wantOrder string
+	}{
+		{"", "", "name", "asc", "1,2,3"},
+		{SortName, SortDescending, "name", "desc", "3,2,1"},
+		{SortModified, SortDescending, "name", "asc", "2,3,1"},
+		{SortSize, SortAscending, "name", "asc", "2,3,1"},
+		{SortVersion, SortDescending, "version", "desc", "1,2,3"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.key)+"-"+string(tt.direction), func(t *testing.T) {
+			opts := &DownloadOptions{Logger: util.NewLogger(io.Discard), Recursive: true, Sort: tt.key, Direction: tt.direction}
+			assets, err := listDownloadAssets("builds", "app", nil, config, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			query := server.LastListQuery
+			if query.Get("sort") != tt.wantSort || query.Get("direction") != tt.wantDir {
+				t.Errorf("Expected sort=%s&direction=%s to be sent, got %v", tt.wantSort, tt.wantDir, query)
+			}
+
+			var order []string
+			for _, asset := range assets {
+				order = append(order, strings.TrimSuffix(asset.Path[len("/app/"):], ".bin"))
+			}
+			// The mock server does not sort; version is left to Nexus and keeps the listed order
+			if got := strings.Join(order, ","); got != tt.wantOrder {
+				t.Errorf("Expected order %s, got %s", tt.wantOrder, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package operations
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/tympanix/nexus-cli/internal/config"
+	"github.com/tympanix/nexus-cli/internal/exitcode"
+	"github.com/tympanix/nexus-cli/internal/nexusapi"
+	"github.com/tympanix/nexus-cli/internal/util"
+)
+
+// StatOptions holds options for the stat command
+type StatOptions struct {
+	Logger util.Logger
+}
+
+// AssetStat describes an asset together with the attributes stored for it on upload
+type AssetStat struct {
+	Asset           nexusapi.Asset
+	Attributes      map[string]interface{}
+	AttributeSource string // Where the attributes were found, "" if there are none
+}
+
+// statAsset looks up the asset at target, in the form repository/path, and its attributes
+func statAsset(target string, config *config.Config) (*AssetStat, error) {
+	repository, assetPath, ok := util.ParseRepositoryPath(target)
+	if !ok || assetPath == "" {
+		return nil, fmt.Errorf("the target argument must be in the form 'repository/path'")
+	}
+
+	client := NewClient(config)
+	asset, err := client.GetAssetByPath(repository, assetPath)
+	if errors.Is(err, nexusapi.ErrAssetNotFound) {
+		return nil, fmt.Errorf("no asset %s in repository '%s'", assetPath, repository)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	stat := &AssetStat{Asset: *asset}
+	stat.Attributes, stat.AttributeSource, err = fetchAttributes(client, repository, asset.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read attributes: %w", err)
+	}
+	return stat, nil
+}
+
+// printStat writes the details of an asset and its attributes, sorted by key
+func printStat(w io.Writer, stat *AssetStat) {
+	asset := stat.Asset
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fields := [][2]string{
+		{"Path", asset.Repository + "/" + strings.TrimPrefix(asset.Path, "/")},
+		{"Format", asset.Format},
+		{"Size", fmt.Sprintf("%s (%d bytes)", util.FormatByteSize(asset.FileSize), asset.FileSize)},
+		{"Content type", asset.ContentType},
+		{"Last modified", asset.LastModified},
+		{"Uploader", asset.Uploader},
+		{"SHA-1", asset.Checksum.SHA1},
+		{"SHA-256", asset.Checksum.SHA256},
+		{"SHA-512", asset.Checksum.SHA512},
+		{"MD5", asset.Checksum.MD5},
+	}
+	for _, field := range fields {
+		if field[1] != "" {
+			fmt.Fprintf(tw, "%s:\t%s\n", field[0], field[1])
+		}
+	}
+	tw.Flush()
+
+	if len(stat.Attributes) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "Attributes (%s):\n", stat.AttributeSource)
+	keys := make([]string, 0, len(stat.Attributes))
+	for key := range stat.Attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(tw, "  %s\t%v\n", key, stat.Attributes[key])
+	}
+	tw.Flush()
+}
+
+// StatMain prints the details of the asset at target and the attributes stored for it
+func StatMain(target string, config *config.Config, opts *StatOptions) {
+	stat, err := statAsset(target, config)
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(exitcode.Error)
+	}
+
+	var sb strings.Builder
+	printStat(&sb, stat)
+	opts.Logger.Printf("%s", sb.String())
+}
@@ -0,0 +1,105 @@
+package operations
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tympanix/nexus-cli/internal/nexusapi"
+)
+
+// defaultTimestampLayout is used for {timestamp} without an explicit layout
+const defaultTimestampLayout = "20060102-150405"
+
+// timestampTemplate matches {timestamp} and {timestamp:<go layout>}
+var timestampTemplate = regexp.MustCompile(`\{timestamp(?::([^}]*))?\}`)
+
+const buildNumberTemplate = "{buildnum}"
+
+// expandTimestamp replaces every {timestamp} in dest with now in UTC, formatted with the
+// Go layout given after the colon or defaultTimestampLayout. All occurrences get the
+// same time, so a destination naming the time twice is consistent.
+func expandTimestamp(dest string, now time.Time) string {
+	now = now.UTC()
+	return timestampTemplate.ReplaceAllStringFunc(dest, func(match string) string {
+		layout := timestampTemplate.FindStringSubmatch(match)[1]
+		if layout == "" {
+			layout = defaultTimestampLayout
+		}
+		return now.Format(layout)
+	})
+}
+
+// expandBuildNumber replaces {buildnum} in dest with the next build number: one more than
+// the highest number found among the entries of its parent folder in Nexus, or start if
+// that is higher. {buildnum} may be part of a longer folder or file name, e.g. build-{buildnum},
+// in which case only siblings with the same text around the number are considered.
+//
+// The number is not reserved. Two uploads that list the parent folder at the same time
+// get the same number.
+func expandBuildNumber(dest string, client *nexusapi.Client, start int) (string, error) {
+	switch strings.Count(dest, buildNumberTemplate) {
+	case 0:
+		return dest, nil
+	case 1:
+	default:
+		return "", fmt.Errorf("the destination may contain {buildnum} only once")
+	}
+
+	segments := strings.Split(dest, "/")
+	idx := 0
+	for i, segment := range segments {
+		if strings.Contains(segment, buildNumberTemplate) {
+			idx = i
+		}
+	}
+	if idx == 0 {
+		return "", fmt.Errorf("{buildnum} cannot be used in the repository name")
+	}
+
+	repository := segments[0]
+	parent := strings.Trim(path.Clean("/"+strings.Join(segments[1:idx], "/")), "/")
+	prefix, suffix, _ := strings.Cut(segments[idx], buildNumberTemplate)
+	pattern := regexp.MustCompile("^" + regexp.QuoteMeta(prefix) + "([0-9]+)" + regexp.QuoteMeta(suffix) + "$")
+
+	assets, err := client.ListAssets(repository, parent, true)
+	if err != nil {
+		return "", fmt.Errorf("failed to list %s to determine {buildnum}: %w", strings.Join(segments[:idx], "/"), err)
+	}
+
+	next := start
+	for _, asset := range assets {
+		rel := strings.TrimLeft(asset.Path, "/")
+		if parent != "" {
+			var ok bool
+			if rel, ok = strings.CutPrefix(rel, parent+"/"); !ok {
+				continue
+			}
+		}
+		child, _, _ := strings.Cut(rel, "/")
+		match := pattern.FindStringSubmatch(child)
+		if match == nil {
+			continue
+		}
+		n, err := strconv.Atoi(match[1])
+		if err != nil {
+			continue
+		}
+		if n+1 > next {
+			next = n + 1
+		}
+	}
+
+	segments[idx] = prefix + strconv.Itoa(next) + suffix
+	return strings.Join(segments, "/"), nil
+}
+
+// expandUploadTemplates expands {timestamp} and then {buildnum} in an upload destination,
+// so a build number can be counted within a folder named by the time. {key} is expanded
+// before this by processKeyTemplateWrapper. Nexus is only contacted for {buildnum}.
+func expandUploadTemplates(dest string, client *nexusapi.Client, opts *UploadOptions, now time.Time) (string, error) {
+	return expandBuildNumber(expandTimestamp(dest, now), client, opts.BuildnumStart)
+}
@@ -0,0 +1,116 @@
+package operations
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tympanix/nexus-cli/internal/nexusapi"
+)
+
+func TestExpandTimestamp(t *testing.T) {
+	now := time.Date(2024, 3, 9, 14, 5, 7, 0, time.FixedZone("CET", 3600))
+
+	tests := []struct {
+		dest     string
+		expected string
+	}{
+		{"repo/nightly/{timestamp}/", "repo/nightly/20240309-130507/"},
+		{"repo/nightly/{timestamp:2006-01-02}", "repo/nightly/2024-03-09"},
+		{"repo/{timestamp:2006/01}/{timestamp:150405}", "repo/2024/03/130507"},
+		{"repo/{timestamp:}", "repo/20240309-130507"},
+		{"repo/plain/{other}", "repo/plain/{other}"},
+	}
+	for _, tt := range tests {
+		if got := expandTimestamp(tt.dest, now); got != tt.expected {
+			t.Errorf("expandTimestamp(%q) = %q, want %q", tt.dest, got, tt.expected)
+		}
+	}
+}
+
+func TestExpandBuildNumber(t *testing.T) {
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+	client := nexusapi.NewClient(server.URL, "user", "pass")
+
+	// Nothing uploaded yet: the start number is used
+	dest, err := expandBuildNumber("builds/nightly/{buildnum}/", client, 1)
+	if err != nil {
+		t.Fatalf("expandBuildNumber failed: %v", err)
+	}
+	if dest != "builds/nightly/1/" {
+		t.Errorf("Expected builds/nightly/1/, got %s", dest)
+	}
+
+	server.AddAsset("builds", "/nightly/1/app.bin", nexusapi.Asset{}, []byte("1"))
+	server.AddAsset("builds", "/nightly/7/app.bin", nexusapi.Asset{}, []byte("7"))
+	server.AddAsset("builds", "/nightly/7/docs/readme.txt", nexusapi.Asset{}, []byte("7"))
+	server.AddAsset("builds", "/nightly/latest/app.bin", nexusapi.Asset{}, []byte("latest"))
+	server.AddAsset("builds", "/nightly/12x/app.bin", nexusapi.Asset{}, []byte("12x"))
+	server.AddAsset("builds", "/nightly/build-41/app.bin", nexusapi.Asset{}, []byte("41"))
+	server.AddAsset("builds", "/release/99/app.bin", nexusapi.Asset{}, []byte("99"))
+
+	tests := []struct {
+		dest     string
+		start    int
+		expected string
+	}{
+		{"builds/nightly/{buildnum}/", 1, "builds/nightly/8/"},
+		{"builds/nightly/{buildnum}", 100, "builds/nightly/100"},
+		{"builds/nightly/build-{buildnum}/app", 1, "builds/nightly/build-42/app"},
+		{"builds/nightly/7/docs-{buildnum}", 1, "builds/nightly/7/docs-1"},
+		{"builds/{buildnum}", 1, "builds/1"},
+		{"builds/nightly/no-template", 1, "builds/nightly/no-template"},
+	}
+	for _, tt := range tests {
+		got, err := expandBuildNumber(tt.dest, client, tt.start)
+		if err != nil {
+			t.Errorf("expandBuildNumber(%q) failed: %v", tt.dest, err)
+			continue
+		}
+		if got != tt.expected {
+			t.Errorf("expandBuildNumber(%q, %d) = %q, want %q", tt.dest, tt.start, got, tt.expected)
+		}
+	}
+
+	for _, dest := range []string{"{buildnum}/nightly", "builds/{buildnum}/{buildnum}"} {
+		if _, err := expandBuildNumber(dest, client, 1); err == nil {
+			t.Errorf("Expected error for %q", dest)
+		}
+	}
+}
+
+// TestExpandUploadTemplatesComposesWithKey expands {key}, {timestamp} and {buildnum} in one
+// destination, counting build numbers within the folder named by the key and the date
+func TestExpandUploadTemplatesComposesWithKey(t *testing.T) {
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+	client := nexusapi.NewClient(server.URL, "user", "pass")
+
+	keyFile := filepath.Join(t.TempDir(), "go.sum")
+	if err := os.WriteFile(keyFile, []byte("deps"), 0644); err != nil {
+		t.Fatalf("Failed to write key file: %v", err)
+	}
+	dest, err := processKeyTemplateWrapper("cache/{key}/{timestamp:20060102}/{buildnum}", keyFile, "")
+	if err != nil {
+		t.Fatalf("Failed to process key template: %v", err)
+	}
+	key := strings.Split(dest, "/")[1]
+
+	server.AddAsset("cache", "/"+key+"/20240309/3/a.txt", nexusapi.Asset{}, []byte("a"))
+	server.AddAsset("cache", "/"+key+"/20240308/9/a.txt", nexusapi.Asset{}, []byte("a"))
+
+	now := time.Date(2024, 3, 9, 12, 0, 0, 0, time.UTC)
+	got, err := expandUploadTemplates(dest, client, &UploadOptions{BuildnumStart: 1}, now)
+	if err != nil {
+		t.Fatalf("expandUploadTemplates failed: %v", err)
+	}
+	if expected := "cache/" + key + "/20240309/4"; got != expected {
+		t.Errorf("Expected %s, got %s", expected, got)
+	}
+	if server.LastListPath != key+"/20240309" {
+		t.Errorf("Expected the dated folder to be listed, got %s", server.LastListPath)
+	}
+}
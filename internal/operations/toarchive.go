@@ -0,0 +1,179 @@
+package operations
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/tympanix/nexus-cli/internal/archive"
+	"github.com/tympanix/nexus-cli/internal/config"
+	"github.com/tympanix/nexus-cli/internal/nexusapi"
+	"github.com/tympanix/nexus-cli/internal/output"
+	"github.com/tympanix/nexus-cli/internal/progress"
+	"github.com/tympanix/nexus-cli/internal/util"
+)
+
+// byteCounter counts the bytes written to it
+type byteCounter struct {
+	n int64
+}
+
+func (c *byteCounter) Write(p []byte) (int, error) {
+	c.n += int64(len(p))
+	return len(p), nil
+}
+
+// downloadToArchive streams the assets into the local archive opts.ToArchive instead of
+// writing them as individual files. Entries are named by resultPaths, the paths a normal
+// download would create below its destination, so the archive extracts to the same tree.
+//
+// Every asset is verified as it streams into the archive. An entry cannot be taken back
+// once written, so a failed asset aborts the whole archive and nothing is left behind.
+func downloadToArchive(assets []nexusapi.Asset, resultPaths map[string]string, target, src string, config *config.Config, opts *DownloadOptions) DownloadStatus {
+	format := opts.CompressionFormat
+	if format == "" {
+		format = archive.DetectFromFilename(opts.ToArchive)
+	}
+
+	// Entries are written in path order so the same assets always give the same archive
+	sorted := append([]nexusapi.Asset{}, assets...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return resultPaths[sorted[i].Path] < resultPaths[sorted[j].Path]
+	})
+
+	if opts.DryRun {
+		size := int64(0)
+		for _, asset := range sorted {
+			opts.Logger.VerbosePrintf("Would add: %s\n", resultPaths[asset.Path])
+			size += asset.FileSize
+		}
+		opts.plan.Add(Action{Type: ActionDownload, Source: target, Target: opts.ToArchive, Size: size})
+		opts.printPlan()
+		opts.Logger.Printf("Dry-run mode: Would write %d assets from '%s' to archive '%s' (format: %s)\n", len(sorted), target, opts.ToArchive, format)
+		return DownloadSuccess
+	}
+
+	if err := util.MkdirAll(filepath.Dir(opts.ToArchive), opts.DirMode); err != nil {
+		opts.Logger.Println("Error:", err)
+		return DownloadError
+	}
+	tmp, err := createPartFile(opts.ToArchive, ".tmp-")
+	if err != nil {
+		opts.Logger.Println("Error:", err)
+		return DownloadError
+	}
+	defer os.Remove(tmp.Name()) // No-op once the archive has been moved into place
+	defer tmp.Close()
+
+	writer, err := format.NewWriter(tmp)
+	if err != nil {
+		opts.Logger.Println("Error:", err)
+		return DownloadError
+	}
+
+	totalBytes := int64(0)
+	for _, asset := range sorted {
+		totalBytes += asset.FileSize
+	}
+	showProgress := util.IsATTY() && !opts.QuietMode
+	tracker := output.NewTransferTracker(output.TransferTypeDownload, target, opts.Logger, opts.QuietMode, opts.Logger.IsVerbose(), showProgress)
+	tracker.SetMeter(opts.meter)
+	tracker.AddFiltered(opts.filtered)
+	tracker.PrintHeader(len(sorted), totalBytes)
+	bar := progress.NewProgressBarWithCount(opts.meter, totalBytes, "Archiving files", len(sorted), !opts.QuietMode)
+
+	client := NewClient(config)
+	for _, asset := range sorted {
+		name := resultPaths[asset.Path]
+		startTime := time.Now()
+		bar.StartFile(name)
+		err := streamAssetToArchive(client, writer, asset, name, src, bar, tracker, opts)
+		bar.FinishFile(name)
+		endTime := time.Now()
+		tracker.Stats().AddTransferTime(endTime.Sub(startTime))
+		if err != nil {
+			tracker.RecordFile(output.FileTransfer{Path: name, Size: asset.FileSize, Status: output.TransferStatusFailed, Error: err, StartTime: startTime, EndTime: endTime})
+			bar.Finish()
+			opts.Logger.Println("Error downloading asset:", err)
+			opts.Logger.Printf("Error: archive '%s' was not written\n", opts.ToArchive)
+			return DownloadError
+		}
+		tracker.RecordFile(output.FileTransfer{Path: name, Size: asset.FileSize, Status: output.TransferStatusSuccess, StartTime: startTime, EndTime: endTime})
+		bar.IncrementFile()
+	}
+	bar.Finish()
+
+	if err := writer.Close(); err == nil {
+		err = tmp.Close()
+	}
+	if err == nil {
+		err = placeDownload(tmp.Name(), opts.ToArchive)
+	}
+	if err != nil {
+		opts.Logger.Printf("Error: failed to write archive '%s': %v\n", opts.ToArchive, err)
+		return DownloadError
+	}
+
+	tracker.PrintSummary()
+	opts.Logger.VerbosePrintf("Wrote %d assets to %s\n", len(sorted), opts.ToArchive)
+	return DownloadSuccess
+}
+
+// streamAssetToArchive downloads one asset straight into the archive. The data is hashed
+// on its way through a TeeReader and verified once the entry is complete.
+func streamAssetToArchive(client *nexusapi.Client, writer *archive.Writer, asset nexusapi.Asset, name, src string, bar io.Writer, tracker *output.TransferTracker, opts *DownloadOptions) error {
+	pr, pw := io.Pipe()
+	defer pr.Close()
+	go func() {
+		pw.CloseWithError(client.DownloadAsset(asset.DownloadURL, pw))
+	}()
+
+	counter := &byteCounter{}
+	writers := []io.Writer{counter, bar, tracker.Stats().WireWriter()}
+	digest := newVerifyHash(opts)
+	if digest != nil {
+		writers = append(writers, digest)
+	}
+	listed := expectListedChecksum(asset, src, opts)
+	if listedWriter := listed.writer(); listedWriter != nil {
+		writers = append(writers, listedWriter)
+	}
+	var content io.Reader = io.TeeReader(pr, limitWriter(io.MultiWriter(writers...), opts.limiter))
+
+	// A tar header records the size before the content. Older assets may not report a
+	// size, so those are spooled to a temporary file first to learn it.
+	size := asset.FileSize
+	if size == 0 {
+		spool, err := os.CreateTemp("", "nexus-archive-*")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(spool.Name())
+		defer spool.Close()
+		if size, err = io.Copy(spool, content); err != nil {
+			return err
+		}
+		if _, err := spool.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		content = spool
+	}
+
+	modTime := lastModified(asset)
+	if modTime.IsZero() {
+		modTime = time.Now()
+	}
+	if err := writer.Add(name, size, 0644, modTime, content); err != nil {
+		return err
+	}
+	if err := verifyTransfer(asset, digest, counter.n, opts); err != nil {
+		return err
+	}
+	if err := listed.verify(opts); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package operations
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tympanix/nexus-cli/internal/archive"
+	"github.com/tympanix/nexus-cli/internal/config"
+	"github.com/tympanix/nexus-cli/internal/nexusapi"
+	"github.com/tympanix/nexus-cli/internal/util"
+)
+
+func newToArchiveTest(t *testing.T) (*nexusapi.MockNexusServer, *config.Config) {
+	t.Helper()
+	server := nexusapi.NewMockNexusServer()
+	t.Cleanup(server.Close)
+	server.AddAsset("raw", "/release/app.bin", nexusapi.Asset{LastModified: "2026-01-02T03:04:05Z"}, []byte("app binary"))
+	server.AddAsset("raw", "/release/docs/readme.txt", nexusapi.Asset{}, []byte("readme"))
+	server.AddAsset("raw", "/release/docs/empty.txt", nexusapi.Asset{}, []byte{})
+	return server, &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+}
+
+// TestDownloadToArchive checks that the archive extracts to the tree a normal download creates
+func TestDownloadToArchive(t *testing.T) {
+	_, config := newToArchiveTest(t)
+
+	for _, name := range []string{"out.tar.gz", "out.tar.zst", "out.zip"} {
+		t.Run(name, func(t *testing.T) {
+			archivePath := filepath.Join(t.TempDir(), "nested", name)
+			opts := &DownloadOptions{Logger: util.NewLogger(io.Discard), QuietMode: true, Recursive: true, ToArchive: archivePath}
+			opts.SetChecksumAlgorithm("sha256")
+			if status := downloadFolder("raw/release", "", config, opts); status != DownloadSuccess {
+				t.Fatalf("Expected download to succeed, got status %d", status)
+			}
+
+			f, err := os.Open(archivePath)
+			if err != nil {
+				t.Fatalf("Expected archive to be written: %v", err)
+			}
+			defer f.Close()
+			extracted := t.TempDir()
+			if err := archive.DetectFromFilename(name).ExtractArchive(f, extracted); err != nil {
+				t.Fatalf("Failed to extract archive: %v", err)
+			}
+
+			expected := map[string]string{
+				"release/app.bin":         "app binary",
+				"release/docs/readme.txt": "readme",
+				"release/docs/empty.txt":  "",
+			}
+			for relPath, content := range expected {
+				data, err := os.ReadFile(filepath.Join(extracted, relPath))
+				if err != nil {
+					t.Errorf("Expected %s in the archive: %v", relPath, err)
+					continue
+				}
+				if string(data) != content {
+					t.Errorf("Expected %s to contain %q, got %q", relPath, content, data)
+				}
+			}
+			if info, err := os.Stat(filepath.Join(extracted, "release/app.bin")); err == nil && info.ModTime().UTC().Format("2006-01-02T15:04:05Z") != "2026-01-02T03:04:05Z" {
+				t.Errorf("Expected the modification time of the asset, got %v", info.ModTime())
+			}
+		})
+	}
+}
+
+// TestDownloadToArchiveChecksumMismatch checks that a corrupt asset aborts the archive
+func TestDownloadToArchiveChecksumMismatch(t *testing.T) {
+	server, config := newToArchiveTest(t)
+	server.AddAsset("raw", "/release/corrupt.bin", nexusapi.Asset{Checksum: nexusapi.Checksum{SHA1: "0000000000000000000000000000000000000000"}}, []byte("corrupt"))
+
+	archivePath := filepath.Join(t.TempDir(), "out.tar.gz")
+	opts := &DownloadOptions{Logger: util.NewLogger(io.Discard), QuietMode: true, Recursive: true, ToArchive: archivePath}
+	opts.SetChecksumAlgorithm("sha1")
+	if status := downloadFolder("raw/release", "", config, opts); status != DownloadError {
+		t.Fatalf("Expected download to fail on a checksum mismatch, got status %d", status)
+	}
+	if _, err := os.Stat(archivePath); !os.IsNotExist(err) {
+		t.Error("Expected no archive to be left behind")
+	}
+	entries, _ := os.ReadDir(filepath.Dir(archivePath))
+	if len(entries) != 0 {
+		t.Errorf("Expected no temporary files to be left behind, got %d entries", len(entries))
+	}
+}
+
+func TestDownloadToArchiveDryRun(t *testing.T) {
+	server, config := newToArchiveTest(t)
+
+	archivePath := filepath.Join(t.TempDir(), "out.zip")
+	opts := &DownloadOptions{Logger: util.NewLogger(io.Discard), QuietMode: true, Recursive: true, ToArchive: archivePath, DryRun: true}
+	if status := downloadFolder("raw/release", "", config, opts); status != DownloadSuccess {
+		t.Fatalf("Expected dry run to succeed, got status %d", status)
+	}
+	if _, err := os.Stat(archivePath); !os.IsNotExist(err) {
+		t.Error("Expected no archive to be written in dry-run mode")
+	}
+	if server.GetDownloadCount() != 0 {
+		t.Errorf("Expected no downloads in dry-run mode, got %d", server.GetDownloadCount())
+	}
+}
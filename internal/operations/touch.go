@@ -0,0 +1,119 @@
+package operations
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/tympanix/nexus-cli/internal/checksum"
+	"github.com/tympanix/nexus-cli/internal/config"
+	"github.com/tympanix/nexus-cli/internal/exitcode"
+	"github.com/tympanix/nexus-cli/internal/nexusapi"
+	"github.com/tympanix/nexus-cli/internal/util"
+)
+
+// touchAsset uploads an empty asset to remotePath in repository, e.g. a BUILD_SUCCESS
+// marker. An existing empty asset is kept unless opts.Force is set; an asset with content
+// is replaced like any changed file. It reports whether the asset was (or, in a dry run,
+// would be) uploaded.
+func touchAsset(repository, remotePath string, config *config.Config, opts *UploadOptions) (bool, error) {
+	remotePath = strings.Trim(remotePath, "/")
+	if remotePath == "" {
+		return false, fmt.Errorf("--touch needs a path inside the repository")
+	}
+
+	client := NewClient(config)
+	if !opts.Force && !opts.offline() {
+		asset, err := client.GetAssetByPath(repository, remotePath)
+		if opts.continueOffline(err) {
+			asset, err = nil, nexusapi.ErrAssetNotFound
+		}
+		if err != nil && !errors.Is(err, nexusapi.ErrAssetNotFound) {
+			return false, err
+		}
+		if asset != nil && isEmptyAsset(*asset, opts) {
+			opts.Logger.VerbosePrintf("Skipping %s/%s (already exists and is empty)\n", repository, remotePath)
+			return false, nil
+		}
+	}
+
+	if opts.DryRun {
+		plan := NewPlan("upload")
+		plan.Add(Action{Type: ActionUpload, Target: repository + "/" + remotePath})
+		plan.Print(opts.Logger, opts.PlanFormat)
+		return true, nil
+	}
+	if err := client.UploadRawAsset(repository, remotePath, bytes.NewReader(nil)); err != nil {
+		return false, err
+	}
+	opts.Logger.VerbosePrintf("Created empty asset: %s/%s\n", repository, remotePath)
+	return true, nil
+}
+
+// isEmptyAsset reports whether asset has no content, compared like uploaded files: any
+// existing asset counts with --compare existence (or --skip-checksum) and the size with
+// --compare size. Otherwise the reported checksum is compared with the checksum of empty
+// content, falling back to the size if Nexus reported none.
+func isEmptyAsset(asset nexusapi.Asset, opts *UploadOptions) bool {
+	switch opts.EffectiveCompare() {
+	case CompareExistence:
+		return true
+	case CompareSize:
+		return asset.FileSize == 0
+	}
+	preferred := "sha1"
+	if opts.checksumValidator != nil {
+		preferred = opts.checksumValidator.Algorithm()
+	}
+	algorithm := checksum.AvailableAlgorithm(asset.Checksum, preferred)
+	if algorithm == "" {
+		return asset.FileSize == 0
+	}
+	hasher, err := checksum.NewHasher(algorithm)
+	if err != nil {
+		return false
+	}
+	match, err := checksum.Matches(checksum.ExtractChecksum(asset.Checksum, algorithm), hex.EncodeToString(hasher.Sum(nil)), algorithm)
+	return err == nil && match
+}
+
+// TouchMain creates the empty asset dest, of the form repository/path, and exits with
+// status 1 on failure
+func TouchMain(dest string, config *config.Config, opts *UploadOptions) {
+	repository, remotePath, ok := util.ParseRepositoryPath(dest)
+	if !ok {
+		fmt.Println("Error: --touch must be in the form 'repository/path'.")
+		os.Exit(exitcode.Error)
+	}
+
+	client := NewClient(config)
+	if opts.WaitForWritable > 0 && !opts.DryRun {
+		if err := waitForStatus(client.StatusWritable, "writable", opts.WaitForWritable, opts.Logger); err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(exitcode.Error)
+		}
+	}
+	if !opts.SkipWriteCheck && !opts.offline() {
+		if err := checkUploadRepositories(client, []string{repository}, opts); err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(exitcode.Error)
+		}
+	}
+
+	created, err := touchAsset(repository, remotePath, config, opts)
+	if err != nil {
+		fmt.Println("Upload error:", err)
+		os.Exit(exitcode.Error)
+	}
+	switch {
+	case created && opts.DryRun:
+		opts.Logger.Printf("Dry-run: would have created empty asset %s\n", dest)
+	case created:
+		opts.Logger.Printf("Created empty asset %s\n", dest)
+	default:
+		opts.Logger.Printf("Empty asset %s already exists\n", dest)
+	}
+}
@@ -0,0 +1,73 @@
+package operations
+
+import (
+	"io"
+	"testing"
+
+	"github.com/tympanix/nexus-cli/internal/config"
+	"github.com/tympanix/nexus-cli/internal/nexusapi"
+	"github.com/tympanix/nexus-cli/internal/util"
+)
+
+func newTouchOptions(t *testing.T) *UploadOptions {
+	t.Helper()
+	opts := &UploadOptions{Logger: util.NewLogger(io.Discard), QuietMode: true}
+	if err := opts.SetChecksumAlgorithm("sha1"); err != nil {
+		t.Fatal(err)
+	}
+	return opts
+}
+
+func TestTouchCreatesEmptyAsset(t *testing.T) {
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+	server.StoreUploads = true
+	config := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+
+	created, err := touchAsset("raw", "/builds/42/BUILD_SUCCESS", config, newTouchOptions(t))
+	if err != nil || !created {
+		t.Fatalf("Expected the marker to be created, got %v, %v", created, err)
+	}
+	uploaded := server.GetUploadedFiles()
+	if len(uploaded) != 1 || uploaded[0].Path != "/builds/42/BUILD_SUCCESS" || len(uploaded[0].Content) != 0 {
+		t.Fatalf("Expected one empty asset at /builds/42/BUILD_SUCCESS, got %+v", uploaded)
+	}
+
+	// The stored marker has the checksum of empty content and is not uploaded again
+	created, err = touchAsset("raw", "builds/42/BUILD_SUCCESS", config, newTouchOptions(t))
+	if err != nil || created {
+		t.Errorf("Expected the existing marker to be kept, got %v, %v", created, err)
+	}
+	if len(server.GetUploadedFiles()) != 1 {
+		t.Errorf("Expected no second upload, got %d uploads", len(server.GetUploadedFiles()))
+	}
+
+	opts := newTouchOptions(t)
+	opts.Force = true
+	if created, err = touchAsset("raw", "builds/42/BUILD_SUCCESS", config, opts); err != nil || !created {
+		t.Errorf("Expected --force to upload the marker again, got %v, %v", created, err)
+	}
+}
+
+func TestTouchReplacesAssetWithContent(t *testing.T) {
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+	server.AddAsset("raw", "/builds/42/BUILD_SUCCESS", nexusapi.Asset{}, []byte("stale"))
+	config := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+
+	opts := newTouchOptions(t)
+	opts.DryRun = true
+	if created, err := touchAsset("raw", "builds/42/BUILD_SUCCESS", config, opts); err != nil || !created {
+		t.Errorf("Expected the dry run to report the marker, got %v, %v", created, err)
+	}
+	if len(server.GetUploadedFiles()) != 0 {
+		t.Fatal("Expected no upload in dry-run mode")
+	}
+
+	if created, err := touchAsset("raw", "builds/42/BUILD_SUCCESS", config, newTouchOptions(t)); err != nil || !created {
+		t.Fatalf("Expected an asset with content to be replaced, got %v, %v", created, err)
+	}
+	if uploaded := server.GetUploadedFiles(); len(uploaded) != 1 || len(uploaded[0].Content) != 0 {
+		t.Errorf("Expected one empty upload, got %+v", uploaded)
+	}
+}
@@ -0,0 +1,54 @@
+package operations
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/tympanix/nexus-cli/internal/config"
+	"github.com/tympanix/nexus-cli/internal/exitcode"
+	"github.com/tympanix/nexus-cli/internal/output"
+	"github.com/tympanix/nexus-cli/internal/util"
+)
+
+// TreeOptions holds options for the tree command
+type TreeOptions struct {
+	Logger util.Logger
+	Depth  int  // Maximum number of directory levels to show (0 = unlimited)
+	ASCII  bool // Draw the tree with ASCII instead of box-drawing characters
+}
+
+// buildAssetTree lists all assets below src (in the form repository[/path]) and
+// builds a tree from their paths relative to src
+func buildAssetTree(src string, config *config.Config) (*output.TreeNode, error) {
+	repository, basePath, ok := util.ParseRepositoryPath(src)
+	if !ok {
+		repository, basePath = strings.Trim(src, "/"), ""
+	}
+	if repository == "" {
+		return nil, fmt.Errorf("the src argument must be in the form 'repository' or 'repository/folder'")
+	}
+
+	assets, err := listAssets(repository, basePath, config, true)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]output.TreeEntry, len(assets))
+	for i, asset := range assets {
+		entries[i] = output.TreeEntry{Path: getRelativePath(asset.Path, basePath), Size: asset.FileSize}
+	}
+	return output.BuildTree(entries), nil
+}
+
+// TreeMain prints the assets below src as an indented tree
+func TreeMain(src string, config *config.Config, opts *TreeOptions) {
+	root, err := buildAssetTree(src, config)
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(exitcode.Error)
+	}
+
+	var sb strings.Builder
+	output.RenderTree(&sb, strings.TrimRight(src, "/"), root, opts.Depth, opts.ASCII)
+	opts.Logger.Printf("%s", sb.String())
+}
@@ -0,0 +1,32 @@
+package operations
+
+import (
+	"testing"
+
+	"github.com/tympanix/nexus-cli/internal/config"
+	"github.com/tympanix/nexus-cli/internal/nexusapi"
+)
+
+func TestBuildAssetTree(t *testing.T) {
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+	server.AddAsset("test-repo", "/releases/v1/fw.bin", nexusapi.Asset{}, []byte("firmware"))
+	server.AddAsset("test-repo", "/releases/v1/docs/notes.txt", nexusapi.Asset{}, []byte("notes"))
+	server.AddAsset("test-repo", "/other/skip.txt", nexusapi.Asset{}, []byte("other"))
+
+	config := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+	root, err := buildAssetTree("test-repo/releases/", config)
+	if err != nil {
+		t.Fatalf("buildAssetTree failed: %v", err)
+	}
+
+	if root.Files != 2 || root.Size != int64(len("firmware")+len("notes")) {
+		t.Errorf("Expected 2 files below releases, got %d files and %d bytes", root.Files, root.Size)
+	}
+	if len(root.Children) != 1 || root.Children[0].Name != "v1" || !root.Children[0].IsDir {
+		t.Fatalf("Expected paths relative to releases/, got %+v", root.Children)
+	}
+	if len(root.Children[0].Children) != 2 {
+		t.Errorf("Expected docs and fw.bin below v1, got %+v", root.Children[0].Children)
+	}
+}
@@ -1,17 +1,25 @@
 package operations
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"hash"
 	"io"
 	"mime/multipart"
 	"os"
+	"os/signal"
 	"path"
 	"path/filepath"
+	"sort"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/tympanix/nexus-cli/internal/archive"
+	"github.com/tympanix/nexus-cli/internal/checksum"
 	"github.com/tympanix/nexus-cli/internal/config"
+	"github.com/tympanix/nexus-cli/internal/exitcode"
 	"github.com/tympanix/nexus-cli/internal/nexusapi"
 	"github.com/tympanix/nexus-cli/internal/output"
 	"github.com/tympanix/nexus-cli/internal/progress"
@@ -30,36 +38,28 @@ func uploadAptPackage(debFile, repository string, config *config.Config, opts *U
 
 	// If dry-run is enabled, just report what would be uploaded
 	if opts.DryRun {
-		opts.Logger.VerbosePrintf("Would upload: %s\n", filepath.Base(debFile))
+		plan := NewPlan("upload")
+		plan.Add(Action{Type: ActionUpload, Source: debFile, Target: repository, Size: info.Size()})
+		plan.Print(opts.Logger, opts.PlanFormat)
 		opts.Logger.Printf("Dry-run mode: Would upload apt package %s\n", filepath.Base(debFile))
 		return nil
 	}
 
 	totalBytes := info.Size()
-	showProgress := util.IsATTY() && !opts.QuietMode
-	bar := progress.NewProgressBarWithCount(totalBytes, "Uploading apt package", 1, showProgress)
+	bar := progress.NewProgressBarWithCount(newTransferMeter(), totalBytes, "Uploading apt package", 1, !opts.QuietMode)
 
-	pr, pw := io.Pipe()
-	writer := multipart.NewWriter(pw)
+	form := nexusapi.NewFormStream(func(writer *multipart.Writer) error {
+		return nexusapi.BuildAptUploadForm(writer, debFile, bar)
+	})
 
-	errChan := make(chan error, 1)
-	go func() {
-		defer pw.Close()
-		err := nexusapi.BuildAptUploadForm(writer, debFile, bar)
-		writer.Close()
-		errChan <- err
-	}()
-
-	client := nexusapi.NewClient(config.NexusURL, config.Username, config.Password)
-	contentType := nexusapi.GetFormDataContentType(writer)
-
-	err = client.UploadComponent(repository, pr, contentType)
+	client := NewClient(config)
+	err = client.UploadComponent(repository, form, form.ContentType())
+	if formErr := form.Close(); formErr != nil {
+		return formErr
+	}
 	if err != nil {
 		return err
 	}
-	if goroutineErr := <-errChan; goroutineErr != nil {
-		return goroutineErr
-	}
 	bar.Finish()
 	opts.Logger.Printf("Uploaded apt package %s\n", filepath.Base(debFile))
 	return nil
@@ -73,36 +73,28 @@ func uploadYumPackage(rpmFile, repository string, config *config.Config, opts *U
 
 	// If dry-run is enabled, just report what would be uploaded
 	if opts.DryRun {
-		opts.Logger.VerbosePrintf("Would upload: %s\n", filepath.Base(rpmFile))
+		plan := NewPlan("upload")
+		plan.Add(Action{Type: ActionUpload, Source: rpmFile, Target: repository, Size: info.Size()})
+		plan.Print(opts.Logger, opts.PlanFormat)
 		opts.Logger.Printf("Dry-run mode: Would upload yum package %s\n", filepath.Base(rpmFile))
 		return nil
 	}
 
 	totalBytes := info.Size()
-	showProgress := util.IsATTY() && !opts.QuietMode
-	bar := progress.NewProgressBarWithCount(totalBytes, "Uploading yum package", 1, showProgress)
+	bar := progress.NewProgressBarWithCount(newTransferMeter(), totalBytes, "Uploading yum package", 1, !opts.QuietMode)
 
-	pr, pw := io.Pipe()
-	writer := multipart.NewWriter(pw)
+	form := nexusapi.NewFormStream(func(writer *multipart.Writer) error {
+		return nexusapi.BuildYumUploadForm(writer, rpmFile, bar)
+	})
 
-	errChan := make(chan error, 1)
-	go func() {
-		defer pw.Close()
-		err := nexusapi.BuildYumUploadForm(writer, rpmFile, bar)
-		writer.Close()
-		errChan <- err
-	}()
-
-	client := nexusapi.NewClient(config.NexusURL, config.Username, config.Password)
-	contentType := nexusapi.GetFormDataContentType(writer)
-
-	err = client.UploadComponent(repository, pr, contentType)
+	client := NewClient(config)
+	err = client.UploadComponent(repository, form, form.ContentType())
+	if formErr := form.Close(); formErr != nil {
+		return formErr
+	}
 	if err != nil {
 		return err
 	}
-	if goroutineErr := <-errChan; goroutineErr != nil {
-		return goroutineErr
-	}
 	bar.Finish()
 	opts.Logger.Printf("Uploaded yum package %s\n", filepath.Base(rpmFile))
 	return nil
@@ -114,47 +106,49 @@ func uploadFiles(src, repository, subdir string, config *config.Config, opts *Up
 		return uploadFilesCompressed(src, repository, subdir, config, opts)
 	}
 
+	logUploadGlobDecisions(src, opts)
+	if canStreamUpload(opts) {
+		return uploadFromSource(walkedUploadSource(src, opts), repository, subdir, config, opts)
+	}
+
 	// Original uncompressed upload logic
-	filePaths, err := archive.CollectFilesWithGlob(src, opts.GlobPattern)
+	filePaths, unreadable, excluded, err := archive.CollectReadableFilesCountingExcluded(src, opts.GlobPattern)
 	if err != nil {
 		return err
 	}
-
-	// Build a map of remote assets if checksum validation is enabled or skip-checksum is enabled
-	// Skip this step if Force is enabled (always upload all files)
-	var remoteAssets map[string]nexusapi.Asset
-	if !opts.Force && (opts.SkipChecksum || opts.checksumValidator != nil) {
-		basePath := subdir
-		if basePath == "" {
-			basePath = ""
-		}
-		assets, err := listAssets(repository, basePath, config, true)
-		if err != nil {
-			opts.Logger.VerbosePrintf("Could not list existing assets (will upload all files): %v\n", err)
-			remoteAssets = make(map[string]nexusapi.Asset)
-		} else {
-			remoteAssets = make(map[string]nexusapi.Asset)
-			for _, asset := range assets {
-				resultPath := getRelativePath(asset.Path, basePath)
-				remoteAssets[resultPath] = asset
-			}
-		}
+	if err := checkUnreadableFiles(src, unreadable, opts); err != nil {
+		return err
+	}
+	collected := len(filePaths)
+	filePaths, err = filterDeniedExtensions(src, filePaths, opts)
+	if err != nil {
+		return err
 	}
+	opts.filtered = output.FilterCounts{Glob: excluded, Extension: collected - len(filePaths)}
 
-	// Filter files based on checksum validation
-	var filesToUpload []string
-	var filesToUploadSizes []int64
-	totalBytesToUpload := int64(0)
+	if len(opts.Routes) > 0 {
+		return uploadRoutedFiles(src, filePaths, unreadable, repository, subdir, config, opts)
+	}
+	return uploadFileList(src, filePaths, unreadable, repository, subdir, config, opts)
+}
 
-	// Calculate total bytes for progress bar (validation + upload)
-	totalBytes := int64(0)
-	for _, filePath := range filePaths {
-		info, err := os.Stat(filePath)
-		if err != nil {
-			return err
-		}
-		totalBytes += info.Size()
+// uploadFileList uploads the given files from src into repository/subdir, skipping files that
+// already exist remotely with a matching checksum. Unreadable files are reported in the summary.
+func uploadFileList(src string, filePaths []string, unreadable []archive.UnreadableFile, repository, subdir string, config *config.Config, opts *UploadOptions) error {
+	source, err := collectedUploadSource(src, filePaths, unreadable, opts)
+	if err != nil {
+		return err
 	}
+	return uploadFromSource(source, repository, subdir, config, opts)
+}
+
+// uploadFromSource uploads the files of source into repository/subdir. Each file is
+// compared with the remote assets as it is found and, unless it is up to date, planned
+// for upload. A dry run prints the plan once every file is compared; otherwise each
+// planned upload is handed to the requests as soon as it is planned, so the requests of a
+// walked source are sent while the walk goes on.
+func uploadFromSource(source *uploadSource, repository, subdir string, config *config.Config, opts *UploadOptions) error {
+	remoteAssets := listUploadedAssets(repository, subdir, config, opts)
 
 	target := repository
 	if subdir != "" {
@@ -162,141 +156,316 @@ func uploadFiles(src, repository, subdir string, config *config.Config, opts *Up
 	}
 	showProgress := util.IsATTY() && !opts.QuietMode && !opts.DryRun
 	tracker := output.NewTransferTracker(output.TransferTypeUpload, target, opts.Logger, opts.QuietMode, opts.Logger.IsVerbose(), showProgress)
-	tracker.PrintHeader(len(filePaths), totalBytes)
+	opts.meter = newTransferMeter()
+	tracker.SetMeter(opts.meter)
+	tracker.PrintHeader(source.count, source.bytes)
+
+	// Create a single progress bar for all operations; the totals of a walked source grow
+	// as its files are found. In dry-run mode, suppress the progress bar to avoid
+	// interleaving with output
+	bar := progress.NewProgressBarWithCount(opts.meter, source.bytes, "Processing files", max(source.count, 0), !opts.QuietMode && !opts.DryRun)
+
+	client := NewClient(config)
+	compare := &uploadCompare{
+		source:       source,
+		target:       target,
+		remoteAssets: remoteAssets,
+		search:       newContentSearch(client, repository, subdir, opts),
+		plan:         NewPlan("upload"),
+		bar:          bar,
+		tracker:      tracker,
+		opts:         opts,
+		remotePaths:  make(map[string]string),
+	}
+	uploads := make(chan Action, streamBuffer)
+	stop := make(chan struct{})
+	if source.start != nil {
+		source.start()
+	}
+	go compare.run(uploads, stop)
 
-	// Create a single progress bar for all operations
-	// In dry-run mode, suppress the progress bar to avoid interleaving with output
-	bar := progress.NewProgressBarWithCount(totalBytes, "Processing files", len(filePaths), showProgress)
-
-	for _, filePath := range filePaths {
-		relPath, _ := filepath.Rel(src, filePath)
-		relPath = filepath.ToSlash(relPath)
-		info, err := os.Stat(filePath)
-		if err != nil {
-			return err
+	if opts.DryRun {
+		planned := 0
+		for action := range uploads {
+			planned++
+			tracker.RecordFile(output.FileTransfer{
+				Path:   action.relPath,
+				Size:   action.Size,
+				Status: output.TransferStatusSuccess,
+			})
 		}
+		bar.Finish()
+		tracker.AddFiltered(source.filtered)
+		compare.plan.AddFiltered(source.filtered)
+		if opts.PreserveMtime {
+			compare.plan.Add(Action{Type: ActionUpload, Target: path.Join(target, MetadataManifestName)})
+		}
+		compare.plan.Print(opts.Logger, opts.PlanFormat)
+		if len(opts.Attributes) > 0 {
+			opts.Logger.VerbosePrintf("Would store %d attribute(s) of %d file(s)\n", len(opts.Attributes), len(compare.remotePaths))
+		}
+		tracker.PrintSummary()
+		if opts.Offline {
+			opts.Logger.Printf("Dry-run mode: Nexus was not contacted; all %d files are listed as candidates and some may already exist in %s\n", planned, target)
+		}
+		return nil
+	}
 
-		shouldSkip := false
-		skipReason := ""
-
-		// Check if file exists remotely and validate checksum (skip this check if Force is enabled)
-		if !opts.Force && remoteAssets != nil {
-			if asset, exists := remoteAssets[relPath]; exists {
-				if opts.SkipChecksum {
-					// For skip-checksum, just check existence and add file size to progress
-					shouldSkip = true
-					skipReason = "Skipped (file exists): %s\n"
-					bar.Add64(info.Size())
-				} else if opts.checksumValidator != nil {
-					// Validate checksum with progress tracking
-					valid, err := opts.checksumValidator.ValidateWithProgress(filePath, asset.Checksum, bar)
-					if err == nil && valid {
-						shouldSkip = true
-						skipReason = fmt.Sprintf("Skipped (%s match): %%s\n", strings.ToUpper(opts.ChecksumAlgorithm))
-					}
-				}
-			}
+	if err := sendPlannedUploads(client, repository, subdir, uploads, source, bar, tracker, opts); err != nil {
+		// Stop the comparison and the walk, and wait for them to end
+		close(stop)
+		for range uploads {
 		}
+		return err
+	}
 
-		if shouldSkip {
-			opts.Logger.VerbosePrintf(skipReason, filePath)
-			tracker.RecordFile(output.FileTransfer{
-				Path:   relPath,
-				Size:   info.Size(),
-				Status: output.TransferStatusSkipped,
-			})
-			bar.IncrementFile()
-		} else {
-			filesToUpload = append(filesToUpload, filePath)
-			filesToUploadSizes = append(filesToUploadSizes, info.Size())
-			totalBytesToUpload += info.Size()
+	// Files larger than --chunked are uploaded in parts, one file at a time, after the others
+	for _, action := range compare.chunked {
+		if err := uploadChunkedFile(client, repository, action.localPath, path.Join(subdir, compare.remotePaths[action.localPath]), bar, tracker, opts); err != nil {
+			return err
 		}
 	}
+	bar.Finish()
+	tracker.AddFiltered(source.filtered)
+	tracker.PrintSummary()
 
-	if len(filesToUpload) == 0 {
-		bar.Finish()
-		tracker.PrintSummary()
+	// Attributes are stored for skipped files too, since they describe this upload
+	if err := storeAttributes(client, repository, subdir, uploadedRelPaths(compare.remotePaths), remoteAssets, opts); err != nil {
+		return err
+	}
+
+	// The manifest goes up only once the files are in place, and covers skipped files too
+	// since their modification time may have changed
+	if opts.PreserveMtime {
+		return uploadMetadataManifest(client, repository, subdir, compare.filePaths, compare.remotePaths, opts)
+	}
+	return nil
+}
+
+// uploadedRelPaths returns the sorted remote paths of the uploaded files, relative to the
+// destination folder
+func uploadedRelPaths(remotePaths map[string]string) []string {
+	relPaths := make([]string, 0, len(remotePaths))
+	for _, relPath := range remotePaths {
+		relPaths = append(relPaths, relPath)
+	}
+	sort.Strings(relPaths)
+	return relPaths
+}
+
+// assetValidator returns the validator to compare a local file with an existing asset. If
+// Nexus did not report a checksum for the configured algorithm, the strongest one it did
+// report is used instead, so a missing field is not taken for a mismatch. It returns nil
+// if checksums are not compared or Nexus reported none.
+func assetValidator(asset nexusapi.Asset, relPath string, opts *UploadOptions) checksum.Validator {
+	if opts.checksumValidator == nil {
 		return nil
 	}
+	algorithm := checksum.AvailableAlgorithm(asset.Checksum, opts.checksumValidator.Algorithm())
+	if algorithm == "" {
+		opts.Logger.VerbosePrintf("No checksum reported for %s; uploading it again\n", relPath)
+		return nil
+	}
+	if algorithm == opts.checksumValidator.Algorithm() {
+		return opts.checksumValidator
+	}
+	opts.Logger.VerbosePrintf("No %s checksum reported for %s; comparing %s instead\n", opts.checksumValidator.Algorithm(), relPath, algorithm)
+	validator, _ := checksum.NewValidator(algorithm)
+	return validator
+}
 
-	// If dry-run is enabled, just report what would be uploaded
-	if opts.DryRun {
-		bar.Finish()
-		for i, filePath := range filesToUpload {
-			relPath, _ := filepath.Rel(src, filePath)
-			opts.Logger.VerbosePrintf("Would upload: %s\n", relPath)
-			tracker.RecordFile(output.FileTransfer{
-				Path:   relPath,
-				Size:   filesToUploadSizes[i],
-				Status: output.TransferStatusSuccess,
-			})
-		}
-		tracker.PrintSummary()
+// listUploadedAssets returns the assets below repository/subdir by their path relative to
+// subdir, to skip files that are already uploaded. It returns nil if every file is to be
+// uploaded anyway, with Force, or cannot be compared, offline.
+func listUploadedAssets(repository, subdir string, config *config.Config, opts *UploadOptions) map[string]nexusapi.Asset {
+	if opts.Force || (opts.EffectiveCompare() == CompareChecksum && opts.checksumValidator == nil) || opts.offline() {
+		return nil
+	}
+	assets, err := listAssets(repository, subdir, config, true)
+	if opts.continueOffline(err) {
 		return nil
 	}
+	remoteAssets := make(map[string]nexusapi.Asset)
+	if err != nil {
+		opts.Logger.VerbosePrintf("Could not list existing assets (will upload all files): %v\n", err)
+		return remoteAssets
+	}
+	// A metadata manifest is written by the upload itself, never compared with a local file
+	for _, asset := range nexusapi.WithoutMetadataManifests(assets) {
+		remoteAssets[getRelativePath(asset.Path, subdir)] = asset
+	}
+	return remoteAssets
+}
 
-	// Prepare file upload information
-	files := make([]nexusapi.FileUpload, len(filesToUpload))
-	for i, filePath := range filesToUpload {
-		relPath, _ := filepath.Rel(src, filePath)
-		relPath = filepath.ToSlash(relPath)
-		files[i] = nexusapi.FileUpload{
-			FilePath:     filePath,
-			RelativePath: relPath,
+// checkRemoteMatch compares the local file at filePath with the asset at relPath in
+// remoteAssets. It returns why the file is skipped if it is up to date, e.g. "SHA1 match",
+// or "" if it is to be uploaded. The bytes of a skipped file
+// are counted as skipped on bar.
+func checkRemoteMatch(filePath, relPath string, size int64, remoteAssets map[string]nexusapi.Asset, bar *progress.ProgressBarWithCount, tracker *output.TransferTracker, opts *UploadOptions) string {
+	asset, exists := remoteAssets[relPath]
+	if opts.Force || !exists {
+		return ""
+	}
+	switch opts.EffectiveCompare() {
+	case CompareExistence:
+		// For skip-checksum, just check existence and add file size to progress
+		bar.Skip(size)
+		return "file exists"
+	case CompareSize:
+		if size == asset.FileSize {
+			bar.Skip(size)
+			return "size match"
+		}
+		opts.Logger.VerbosePrintf("Size mismatch (local %d bytes, remote %d bytes): %s\n", size, asset.FileSize, relPath)
+	case CompareChecksum:
+		// A file of another size has changed, so it is not hashed. Nexus reports
+		// no size for some assets; those are always hashed.
+		if asset.FileSize > 0 && asset.FileSize != size {
+			opts.Logger.VerbosePrintf("Size mismatch (local %d bytes, remote %d bytes): %s\n", size, asset.FileSize, relPath)
+			return ""
+		}
+		validator := assetValidator(asset, relPath, opts)
+		if validator == nil {
+			return ""
+		}
+		// Validate checksum with progress tracking
+		hashStart := time.Now()
+		valid, err := validateUploadFile(filePath, asset, validator, opts)
+		tracker.Stats().AddHashTime(time.Since(hashStart))
+		if err == nil && valid {
+			return strings.ToUpper(validator.Algorithm()) + " match"
+		} else if errors.Is(err, checksum.ErrMalformedChecksum) && !opts.QuietMode {
+			opts.Logger.Printf("Warning: cannot verify %s: %v\n", relPath, err)
 		}
 	}
+	return ""
+}
 
-	pr, pw := io.Pipe()
-	writer := multipart.NewWriter(pw)
+// validateUploadFile compares the file at filePath with the checksum of asset. A SHA-256
+// digest is kept for the content search, so a changed file is not read twice.
+func validateUploadFile(filePath string, asset nexusapi.Asset, validator checksum.Validator, opts *UploadOptions) (bool, error) {
+	if validator.Algorithm() != "sha256" || !opts.dedupeCheck() {
+		return validator.ValidateWithProgress(filePath, asset.Checksum, opts.meter.skipWriter())
+	}
+	expected := checksum.ExtractChecksum(asset.Checksum, "sha256")
+	if _, err := checksum.NormalizeChecksum(expected, "sha256"); err != nil {
+		return false, fmt.Errorf("server provided %w", err)
+	}
+	sum, err := checksum.ComputeChecksumWithProgress(filePath, "sha256", opts.meter.skipWriter())
+	if err != nil {
+		return false, err
+	}
+	valid, err := checksum.Matches(expected, sum, "sha256")
+	if err == nil && !valid {
+		if opts.digests == nil {
+			opts.digests = make(map[string]string)
+		}
+		opts.digests[filePath] = sum
+	}
+	return valid, err
+}
 
-	uploadStartTime := time.Now()
+// uploadBatchWithRetry uploads files in a single request. If the server rejects it as too
+// large although batch limits are set, the limits are above what the server accepts, so
+// the batch is halved, once.
+func uploadBatchWithRetry(client *nexusapi.Client, repository, subdir string, files []nexusapi.FileUpload, sizes []int64, bar *progress.ProgressBarWithCount, tracker *output.TransferTracker, opts *UploadOptions) error {
+	err := uploadBatch(client, repository, subdir, files, sizes, bar, tracker)
+	var tooLarge *nexusapi.RequestTooLargeError
+	if errors.As(err, &tooLarge) && (opts.BatchSize > 0 || opts.MaxRequestBytes > 0) && len(files) > 1 {
+		half := len(files) / 2
+		opts.Logger.Printf("Warning: %v\nRetrying the batch as two requests of %d and %d files\n", err, half, len(files)-half)
+		if err = uploadBatch(client, repository, subdir, files[:half], sizes[:half], bar, tracker); err == nil {
+			err = uploadBatch(client, repository, subdir, files[half:], sizes[half:], bar, tracker)
+		}
+	}
+	return err
+}
+
+// uploadBatch uploads files in a single request and, once the request has succeeded,
+// records each one with the tracker with the time it was written to the request
+func uploadBatch(client *nexusapi.Client, repository, subdir string, files []nexusapi.FileUpload, sizes []int64, bar *progress.ProgressBarWithCount, tracker *output.TransferTracker) error {
+	idx := 0
+	return uploadStream(client, repository, subdir, func() (streamedFile, error) {
+		if idx == len(files) {
+			return streamedFile{}, io.EOF
+		}
+		idx++
+		return streamedFile{upload: files[idx-1], size: sizes[idx-1]}, nil
+	}, bar, tracker)
+}
 
-	// Write multipart form in a goroutine
-	errChan := make(chan error, 1)
-	fileCompleteChan := make(chan int, len(files))
-	go func() {
-		defer pw.Close()
+// uploadStream uploads the files returned by next, until it returns io.EOF, in a single
+// request. next is called while the request is sent, so it may wait for files that are
+// still being found. Once the request has succeeded each file is recorded with the
+// tracker with the time it was written to the request. If it fails, the progress it made
+// on bar is taken back, so a retry of the files is not counted twice.
+func uploadStream(client *nexusapi.Client, repository, subdir string, next func() (streamedFile, error), bar *progress.ProgressBarWithCount, tracker *output.TransferTracker) error {
+	uploadStartTime := time.Now()
+	sentBytes := &byteCounter{}
+
+	// The multipart form is written while it is uploaded, reading each file on demand. A
+	// rejected request may be retried, so the written files are only recorded once it has
+	// succeeded; they are only read once the form is closed.
+	var sent []streamedFile
+	var written []output.FileTransfer
+	form := nexusapi.NewFormStream(func(writer *multipart.Writer) error {
+		nextUpload := func() (nexusapi.FileUpload, error) {
+			file, err := next()
+			if err != nil {
+				return nexusapi.FileUpload{}, err
+			}
+			sent = append(sent, file)
+			return file.upload, nil
+		}
+		onFileStart := func(idx, total int) {
+			bar.StartFile(sent[idx].upload.RelativePath)
+		}
 		// Callback to update progress bar description when each file completes
 		onFileComplete := func(idx, total int) {
+			bar.FinishFile(sent[idx].upload.RelativePath)
 			bar.IncrementFile()
-			fileCompleteChan <- idx
-		}
-		err := nexusapi.BuildRawUploadForm(writer, files, subdir, bar, nil, onFileComplete)
-		writer.Close()
-		close(fileCompleteChan)
-		errChan <- err
-	}()
-
-	// Track completed files in another goroutine
-	go func() {
-		for idx := range fileCompleteChan {
-			if idx >= 0 && idx < len(files) {
-				tracker.RecordFile(output.FileTransfer{
-					Path:      files[idx].RelativePath,
-					Size:      filesToUploadSizes[idx],
-					Status:    output.TransferStatusSuccess,
-					StartTime: uploadStartTime,
-					EndTime:   time.Now(),
-				})
-			}
+			written = append(written, output.FileTransfer{
+				Path:      sent[idx].upload.RelativePath,
+				Size:      sent[idx].size,
+				Status:    output.TransferStatusSuccess,
+				StartTime: uploadStartTime,
+				EndTime:   time.Now(),
+			})
 		}
-	}()
+		return nexusapi.BuildRawUploadFormStream(writer, nextUpload, subdir, io.MultiWriter(bar, sentBytes), onFileStart, onFileComplete)
+	})
 
-	client := nexusapi.NewClient(config.NexusURL, config.Username, config.Password)
-	contentType := nexusapi.GetFormDataContentType(writer)
-
-	err = client.UploadComponent(repository, pr, contentType)
+	err := client.UploadComponent(repository, io.TeeReader(form, tracker.Stats().WireWriter()), form.ContentType())
+	tracker.Stats().AddTransferTime(time.Since(uploadStartTime))
+	formErr := form.Close()
+	if formErr != nil || err != nil {
+		bar.Rewind(sentBytes.n, len(written))
+	}
+	if formErr != nil {
+		return formErr
+	}
 	if err != nil {
 		return err
 	}
-	if goroutineErr := <-errChan; goroutineErr != nil {
-		return goroutineErr
+	for _, transfer := range written {
+		tracker.RecordFile(transfer)
 	}
-	bar.Finish()
-	tracker.PrintSummary()
 	return nil
 }
 
+// checkUnreadableFiles fails the upload when unreadable files were found and --strict is set
+func checkUnreadableFiles(src string, unreadable []archive.UnreadableFile, opts *UploadOptions) error {
+	if !opts.Strict || len(unreadable) == 0 {
+		return nil
+	}
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "%d unreadable file(s) in %s (--strict):", len(unreadable), src)
+	for _, file := range unreadable {
+		fmt.Fprintf(&msg, "\n  %s: %v", file.Path, file.Err)
+	}
+	return fmt.Errorf("%s", msg.String())
+}
+
 // uploadFilesCompressed creates a tar.gz archive and uploads it as a single file
 func uploadFilesCompressed(src, repository, subdir string, config *config.Config, opts *UploadOptions) error {
 	return uploadFilesCompressedWithArchiveName(src, repository, subdir, "", config, opts)
@@ -304,10 +473,25 @@ func uploadFilesCompressed(src, repository, subdir string, config *config.Config
 
 // uploadFilesCompressedWithArchiveName creates a compressed archive and uploads it as a single file with optional explicit name
 func uploadFilesCompressedWithArchiveName(src, repository, subdir, explicitArchiveName string, config *config.Config, opts *UploadOptions) error {
-	filePaths, err := archive.CollectFilesWithGlob(src, opts.GlobPattern)
+	logUploadGlobDecisions(src, opts)
+
+	filePaths, unreadable, err := archive.CollectReadableFilesWithGlob(src, opts.GlobPattern)
+	if err != nil {
+		return err
+	}
+	if err := checkUnreadableFiles(src, unreadable, opts); err != nil {
+		return err
+	}
+	filePaths, err = filterDeniedExtensions(src, filePaths, opts)
 	if err != nil {
 		return err
 	}
+	if len(unreadable) > 0 && !opts.QuietMode {
+		for _, file := range unreadable {
+			opts.Logger.Printf("Warning: skipping unreadable file %s: %v\n", file.Path, file.Err)
+		}
+		opts.Logger.Printf("Archive excludes %d unreadable file(s)\n", len(unreadable))
+	}
 
 	if len(filePaths) == 0 {
 		return fmt.Errorf("no files to upload in %s", src)
@@ -319,19 +503,52 @@ func uploadFilesCompressedWithArchiveName(src, repository, subdir, explicitArchi
 		return fmt.Errorf("when using --compress, you must specify the %s filename in the destination path (e.g., repo/path/archive%s)", ext, ext)
 	}
 
+	if opts.Append {
+		return appendToArchive(src, filePaths, repository, subdir, explicitArchiveName, config, opts)
+	}
+
 	archiveName := explicitArchiveName
 	opts.Logger.VerbosePrintf("Creating compressed archive: %s (format: %s)\n", archiveName, opts.CompressionFormat)
 
 	// If dry-run is enabled, just report what would be uploaded
 	if opts.DryRun {
 		for _, filePath := range filePaths {
-			relPath, _ := filepath.Rel(src, filePath)
-			opts.Logger.VerbosePrintf("Would upload: %s\n", relPath)
+			opts.Logger.VerbosePrintf("Would add: %s\n", planLocalPath(src, filePath))
 		}
+		plan := NewPlan("upload")
+		plan.Add(Action{Type: ActionUpload, Source: src, Target: path.Join(repository, subdir, archiveName)})
+		if opts.Manifest {
+			plan.Add(Action{Type: ActionUpload, Target: path.Join(repository, subdir, archiveName+ArchiveManifestSuffix)})
+		}
+		plan.Print(opts.Logger, opts.PlanFormat)
 		opts.Logger.Printf("Dry-run mode: Would upload compressed archive containing %d files from %s\n", len(filePaths), src)
+		if len(opts.Attributes) > 0 {
+			opts.Logger.Printf("Dry-run mode: Would store %d attribute(s) of %s\n", len(opts.Attributes), archiveName)
+		}
 		return nil
 	}
 
+	client := NewClient(config)
+	summary := fmt.Sprintf("Uploaded compressed archive containing %d files from %s", len(filePaths), src)
+	if err := uploadArchive(client, src, filePaths, repository, subdir, archiveName, summary, opts); err != nil {
+		return err
+	}
+	return storeAttributes(client, repository, subdir, []string{archiveName}, nil, opts)
+}
+
+// uploadArchive streams an archive of filePaths, stored relative to srcDir, to
+// repository/subdir/archiveName and logs summary once the upload has succeeded. With
+// opts.Manifest the files are hashed as they are archived and the manifest is uploaded
+// after the archive.
+func uploadArchive(client *nexusapi.Client, srcDir string, filePaths []string, repository, subdir, archiveName, summary string, opts *UploadOptions) error {
+	var newHash func() hash.Hash
+	if opts.Manifest {
+		var err error
+		if newHash, err = manifestHash(opts); err != nil {
+			return err
+		}
+	}
+
 	// Calculate total uncompressed size for progress bar
 	totalBytes := int64(0)
 	for _, filePath := range filePaths {
@@ -343,22 +560,16 @@ func uploadFilesCompressedWithArchiveName(src, repository, subdir, explicitArchi
 	}
 
 	// Create progress bar using uncompressed size as approximation
-	showProgress := util.IsATTY() && !opts.QuietMode
-	bar := progress.NewProgressBarWithCount(totalBytes, "Uploading compressed archive", 1, showProgress)
-
-	pr, pw := io.Pipe()
-	writer := multipart.NewWriter(pw)
-
-	// Create archive and upload in a goroutine
-	errChan := make(chan error, 1)
-	go func() {
-		defer pw.Close()
+	bar := progress.NewProgressBarWithCount(newTransferMeter(), totalBytes, "Uploading compressed archive", 1, !opts.QuietMode)
+	stats := output.NewTransferStats()
 
+	// Create the archive while it is uploaded
+	var sums map[string]string
+	form := nexusapi.NewFormStream(func(writer *multipart.Writer) error {
 		// Create form file for the archive
 		part, err := writer.CreateFormFile("raw.asset1", archiveName)
 		if err != nil {
-			errChan <- err
-			return
+			return err
 		}
 
 		// Wrap part with capping writer and progress bar
@@ -367,9 +578,13 @@ func uploadFilesCompressedWithArchiveName(src, repository, subdir, explicitArchi
 		progressWriter := io.MultiWriter(part, cappedBar)
 
 		// Create compressed archive with progress tracking
-		if err := opts.CompressionFormat.CreateArchiveWithGlob(src, progressWriter, opts.GlobPattern); err != nil {
-			errChan <- fmt.Errorf("failed to create archive: %w", err)
-			return
+		if newHash != nil {
+			sums, err = opts.CompressionFormat.CreateArchiveFromFilesWithChecksums(srcDir, filePaths, progressWriter, newHash)
+		} else {
+			err = opts.CompressionFormat.CreateArchiveFromFiles(srcDir, filePaths, progressWriter)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to create archive: %w", err)
 		}
 
 		// Set the filename field - archive goes to subdir if specified
@@ -379,53 +594,103 @@ func uploadFilesCompressedWithArchiveName(src, repository, subdir, explicitArchi
 		} else {
 			_ = writer.WriteField("raw.asset1.filename", archiveName)
 		}
+		return nil
+	})
 
-		writer.Close()
-		errChan <- nil
-	}()
-
-	client := nexusapi.NewClient(config.NexusURL, config.Username, config.Password)
-	contentType := nexusapi.GetFormDataContentType(writer)
-
-	err = client.UploadComponent(repository, pr, contentType)
+	transferStart := time.Now()
+	err := client.UploadComponent(repository, io.TeeReader(form, stats.WireWriter()), form.ContentType())
+	stats.AddTransferTime(time.Since(transferStart))
+	if formErr := form.Close(); formErr != nil {
+		return formErr
+	}
 	if err != nil {
 		return err
 	}
-	if goroutineErr := <-errChan; goroutineErr != nil {
-		return goroutineErr
-	}
 	bar.Finish()
-	opts.Logger.Printf("Uploaded compressed archive containing %d files from %s\n", len(filePaths), src)
+	if newHash != nil {
+		if err := uploadArchiveManifest(client, repository, subdir, archiveName, sums, opts); err != nil {
+			return err
+		}
+	}
+	stats.AddLogicalBytes(totalBytes)
+	opts.Logger.Println(summary)
+	opts.Logger.Println(stats.Snapshot().String())
 	return nil
 }
 
 func UploadMain(src, dest string, config *config.Config, opts *UploadOptions) {
-	processedDest, err := processKeyTemplateWrapper(dest, opts.KeyFromFile)
+	processedDest, err := processKeyTemplateWrapper(dest, opts.KeyFromFile, opts.GlobPattern)
 	if err != nil {
 		fmt.Println("Error:", err)
-		os.Exit(1)
+		os.Exit(exitcode.Error)
 	}
 
 	if opts.KeyFromFile != "" {
 		opts.Logger.Printf("Using key template: %s -> %s\n", dest, processedDest)
 	}
 
+	client := NewClient(config)
+	if opts.Queue != "" && !opts.DryRun {
+		if _, err := client.StatusAvailable(); isConnectivityError(err) {
+			queueUploadMain(src, processedDest, config, opts, err)
+			return
+		}
+	}
+	if opts.WaitForWritable > 0 && !opts.DryRun {
+		if err := waitForStatus(client.StatusWritable, "writable", opts.WaitForWritable, opts.Logger); err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(exitcode.Error)
+		}
+	}
+
+	now := time.Now()
+	var expandedDest string
+	if !opts.offline() {
+		expandedDest, err = expandUploadTemplates(processedDest, client, opts, now)
+	}
+	if opts.continueOffline(err) {
+		// {buildnum} needs a listing of the destination, so it stays unexpanded offline
+		expandedDest, err = expandTimestamp(processedDest, now), nil
+		if strings.Contains(expandedDest, buildNumberTemplate) {
+			opts.Logger.Printf("Warning: %s is not expanded without Nexus\n", buildNumberTemplate)
+		}
+	}
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(exitcode.Error)
+	}
+	if expandedDest != processedDest {
+		opts.Logger.Printf("Using destination template: %s -> %s\n", processedDest, expandedDest)
+		processedDest = expandedDest
+	}
+
+	if !opts.SkipWriteCheck && !opts.offline() {
+		if err := checkUploadRepositories(client, uploadRepositories(processedDest, opts), opts); err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(exitcode.Error)
+		}
+	}
+
 	// Check if src is a single .deb file for APT package upload
 	if info, err := os.Stat(src); err == nil && !info.IsDir() && strings.HasSuffix(strings.ToLower(src), ".deb") {
 		// APT package upload - repository is the destination
 		repository := processedDest
 		if strings.Contains(processedDest, "/") {
 			fmt.Println("Error: APT package upload does not support subdirectories. Use only repository name as destination.")
-			os.Exit(1)
+			os.Exit(exitcode.Error)
 		}
 		if opts.Compress {
 			fmt.Println("Error: APT package upload does not support compression.")
-			os.Exit(1)
+			os.Exit(exitcode.Error)
+		}
+		if len(opts.Attributes) > 0 {
+			fmt.Println("Error: APT package upload does not support --attr.")
+			os.Exit(exitcode.Error)
 		}
 		err := uploadAptPackage(src, repository, config, opts)
 		if err != nil {
 			fmt.Println("Upload error:", err)
-			os.Exit(1)
+			os.Exit(exitcode.Error)
 		}
 		return
 	}
@@ -436,30 +701,88 @@ func UploadMain(src, dest string, config *config.Config, opts *UploadOptions) {
 		repository := processedDest
 		if strings.Contains(processedDest, "/") {
 			fmt.Println("Error: YUM package upload does not support subdirectories. Use only repository name as destination.")
-			os.Exit(1)
+			os.Exit(exitcode.Error)
 		}
 		if opts.Compress {
 			fmt.Println("Error: YUM package upload does not support compression.")
-			os.Exit(1)
+			os.Exit(exitcode.Error)
+		}
+		if len(opts.Attributes) > 0 {
+			fmt.Println("Error: YUM package upload does not support --attr.")
+			os.Exit(exitcode.Error)
 		}
 		err := uploadYumPackage(src, repository, config, opts)
 		if err != nil {
 			fmt.Println("Upload error:", err)
-			os.Exit(1)
+			os.Exit(exitcode.Error)
 		}
 		return
 	}
 
-	repository := processedDest
-	subdir := ""
-	explicitArchiveName := ""
+	repository, subdir, explicitArchiveName, err := splitUploadDest(processedDest, dest, opts)
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(exitcode.Error)
+	}
+
+	if !opts.Force && !opts.offline() {
+		if err := checkUploadFolders(client, uploadFolders(repository, subdir, opts), opts); err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(exitcode.Error)
+		}
+	}
+
+	if opts.Watch {
+		if info, err := os.Stat(src); err != nil || !info.IsDir() {
+			fmt.Println("Error: --watch requires the source to be a directory.")
+			os.Exit(exitcode.Error)
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		err = watchAndUpload(ctx, src, func() error {
+			// The report covers the latest upload
+			opts.duplicates = nil
+			err := uploadFilesWithArchiveName(src, repository, subdir, explicitArchiveName, config, opts)
+			if reportErr := saveDedupeReport(opts); err == nil {
+				err = reportErr
+			}
+			return err
+		}, opts)
+		if err != nil {
+			fmt.Println("Watch error:", err)
+			os.Exit(exitcode.Error)
+		}
+		return
+	}
+
+	err = uploadFilesWithArchiveName(src, repository, subdir, explicitArchiveName, config, opts)
+	if reportErr := saveDedupeReport(opts); reportErr != nil {
+		fmt.Println("Error:", reportErr)
+		os.Exit(exitcode.Error)
+	}
+	if err != nil && opts.Queue != "" && !opts.DryRun && isConnectivityError(err) {
+		// The connection was lost during the upload; files already uploaded are skipped
+		// when the queue is flushed
+		queueUploadMain(src, processedDest, config, opts, err)
+		return
+	}
+	if err != nil {
+		fmt.Println("Upload error:", err)
+		os.Exit(exitcode.Error)
+	}
+}
+
+// splitUploadDest splits processedDest, the destination of an upload with its templates
+// expanded, into repository and folder, and with compression the archive name, detecting
+// the compression format from it if none was set. dest is the destination as given.
+func splitUploadDest(processedDest, dest string, opts *UploadOptions) (string, string, string, error) {
+	repository, subdir, explicitArchiveName := processedDest, "", ""
 
 	if strings.Contains(processedDest, "/") {
 		var ok bool
 		repository, subdir, ok = util.ParseRepositoryPath(processedDest)
 		if !ok {
-			fmt.Println("Error: The dest argument must be in the form 'repository' or 'repository/folder'.")
-			os.Exit(1)
+			return "", "", "", errors.New("The dest argument must be in the form 'repository' or 'repository/folder'.")
 		}
 
 		// If compress is enabled and dest ends with .tar.gz or .tar.zst or .zip, treat it as explicit archive name
@@ -497,12 +820,7 @@ func UploadMain(src, dest string, config *config.Config, opts *UploadOptions) {
 	if opts.Compress && opts.CompressionFormat == "" {
 		opts.CompressionFormat = archive.FormatGzip
 	}
-
-	err = uploadFilesWithArchiveName(src, repository, subdir, explicitArchiveName, config, opts)
-	if err != nil {
-		fmt.Println("Upload error:", err)
-		os.Exit(1)
-	}
+	return repository, subdir, explicitArchiveName, nil
 }
 
 func uploadFilesWithArchiveName(src, repository, subdir, explicitArchiveName string, config *config.Config, opts *UploadOptions) error {
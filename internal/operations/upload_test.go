@@ -1,14 +1,21 @@
 package operations
 
 import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"github.com/tympanix/nexus-cli/internal/archive"
 	"github.com/tympanix/nexus-cli/internal/config"
 	"github.com/tympanix/nexus-cli/internal/util"
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/tympanix/nexus-cli/internal/nexusapi"
 )
@@ -118,6 +125,43 @@ func TestUploadLogging(t *testing.T) {
 	}
 }
 
+// TestUploadFilteredCounts tests that the summary counts the files excluded by the glob
+// and extension filters separately from the files skipped as up to date, both for a
+// streamed upload and for one that collects the files first
+func TestUploadFilteredCounts(t *testing.T) {
+	srcDir := t.TempDir()
+	for name, content := range map[string]string{"a.txt": "up to date", "b.txt": "new", "c.log": "log", "d.key": "secret"} {
+		os.WriteFile(filepath.Join(srcDir, name), []byte(content), 0644)
+	}
+
+	for _, dryRun := range []bool{false, true} {
+		t.Run(fmt.Sprintf("dry run %v", dryRun), func(t *testing.T) {
+			server := nexusapi.NewMockNexusServer()
+			defer server.Close()
+			server.AddAsset("test-repo", "/a.txt", nexusapi.Asset{}, []byte("up to date"))
+			config := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+
+			var logBuf strings.Builder
+			opts := &UploadOptions{Logger: util.NewLogger(&logBuf), QuietMode: true, DryRun: dryRun,
+				DenyExtensions: []string{".key"}, OnDeniedExt: DeniedExtensionSkip}
+			if err := opts.SetGlobPattern("**/*.txt,**/*.key"); err != nil {
+				t.Fatal(err)
+			}
+			if err := opts.SetChecksumAlgorithm("sha1"); err != nil {
+				t.Fatal(err)
+			}
+			if err := uploadFiles(srcDir, "test-repo", "", config, opts); err != nil {
+				t.Fatalf("Upload failed: %v", err)
+			}
+			for _, want := range []string{"Files uploaded: 1, skipped: 1,", "Files excluded by filters: 2 (glob: 1, extension: 1)\n"} {
+				if !strings.Contains(logBuf.String(), want) {
+					t.Errorf("Expected %q in the summary, got: %s", want, logBuf.String())
+				}
+			}
+		})
+	}
+}
+
 // TestUploadWithChecksumValidation tests that upload skips files with matching checksums
 func TestUploadWithChecksumValidation(t *testing.T) {
 	testContent := "test content for checksum validation"
@@ -474,6 +518,110 @@ func TestUploadToNonExistentRepository(t *testing.T) {
 	}
 }
 
+// TestUploadWriteCheckRejectsGroupRepository tests that an upload to a group repository
+// fails before any file is transferred, naming the member to upload to instead
+func TestUploadWriteCheckRejectsGroupRepository(t *testing.T) {
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+
+	server.RepositoryStatuses = []nexusapi.RepositoryStatus{
+		{Name: "raw-proxy", Format: "raw", Type: "proxy", Online: true},
+		{Name: "raw-hosted", Format: "raw", Type: "hosted", Online: true, Storage: &nexusapi.RepositoryStorage{WritePolicy: "ALLOW"}},
+		{Name: "raw-group", Format: "raw", Type: "group", Online: true, Group: &nexusapi.RepositoryGroup{MemberNames: []string{"raw-proxy", "raw-hosted"}}},
+	}
+	client := nexusapi.NewClient(server.URL, "test", "test")
+	opts := &UploadOptions{Logger: util.NewLogger(io.Discard), QuietMode: true}
+
+	err := checkUploadRepositories(client, uploadRepositories("raw-group/app", opts), opts)
+	if err == nil {
+		t.Fatal("Expected the write check to reject raw-group")
+	}
+	if want := "repository 'raw-group' is read-only (group repository); uploads will be rejected, upload to its member 'raw-hosted' instead"; err.Error() != want {
+		t.Errorf("Expected error %q, got: %v", want, err)
+	}
+}
+
+// TestUploadWriteCheckRejectsReadOnlyRepository tests that an upload to a repository with
+// write policy DENY fails before any file is transferred, with a message naming the cause
+func TestUploadWriteCheckRejectsReadOnlyRepository(t *testing.T) {
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+
+	server.RepositoryStatuses = []nexusapi.RepositoryStatus{
+		{Name: "raw-snapshots", Format: "raw", Type: "hosted", Online: true, Storage: &nexusapi.RepositoryStorage{WritePolicy: "ALLOW"}},
+		{Name: "raw-releases", Format: "raw", Type: "hosted", Online: true, Storage: &nexusapi.RepositoryStorage{WritePolicy: "DENY"}},
+	}
+	client := nexusapi.NewClient(server.URL, "test", "test")
+	opts := &UploadOptions{Logger: util.NewLogger(io.Discard), QuietMode: true}
+
+	err := checkUploadRepositories(client, uploadRepositories("raw-releases/app/1.0", opts), opts)
+	if err == nil {
+		t.Fatal("Expected the write check to reject raw-releases")
+	}
+	for _, want := range []string{"raw-releases", "read-only", "uploads will be rejected", "--skip-write-check"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error to mention %q, got: %v", want, err)
+		}
+	}
+	if len(server.GetUploadedFiles()) != 0 {
+		t.Error("Expected no files to be uploaded")
+	}
+
+	// A route into the read-only repository fails the check as well
+	if err := opts.AddRoute("docs/**=raw-releases/docs"); err != nil {
+		t.Fatalf("Failed to add route: %v", err)
+	}
+	if err := checkUploadRepositories(client, uploadRepositories("raw-snapshots/app", opts), opts); err == nil {
+		t.Error("Expected the write check to reject the routed repository")
+	}
+
+	// Repositories whose settings cannot be read are left for Nexus to decide
+	opts.Routes = nil
+	var logBuf strings.Builder
+	opts.Logger = util.NewVerboseLogger(&logBuf)
+	if err := checkUploadRepositories(client, uploadRepositories("raw-unknown/app", opts), opts); err != nil {
+		t.Errorf("Expected an unknown repository to pass the check, got %v", err)
+	}
+	if !strings.Contains(logBuf.String(), "Could not check whether repository 'raw-unknown' accepts uploads") {
+		t.Errorf("Expected a verbose note about the skipped check, got: %s", logBuf.String())
+	}
+
+	// A group repository is rejected from the repository list when its settings cannot be read
+	server.Repositories = []nexusapi.Repository{{Name: "raw-all", Format: "raw", Type: "group"}}
+	err = checkUploadRepositories(client, uploadRepositories("raw-all/app", opts), opts)
+	if err == nil || !strings.Contains(err.Error(), "group repository") {
+		t.Errorf("Expected the write check to reject the group repository, got %v", err)
+	}
+}
+
+// TestUploadFolderCheckRejectsFileAsset tests that a destination below an existing file
+// asset is rejected before anything is uploaded, while ordinary folders pass
+func TestUploadFolderCheckRejectsFileAsset(t *testing.T) {
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+	server.AddAsset("raw", "/app/file.txt", nexusapi.Asset{}, []byte("content"))
+	client := nexusapi.NewClient(server.URL, "test", "test")
+	opts := &UploadOptions{Logger: util.NewLogger(io.Discard), QuietMode: true}
+
+	for _, dest := range []string{"raw/app/file.txt", "raw/app/file.txt/sub"} {
+		err := checkUploadFolders(client, uploadFolders(dest, "", opts), opts)
+		if err == nil {
+			t.Fatalf("Expected destination %s to be rejected", dest)
+		}
+		for _, want := range []string{"raw/app/file.txt", "--force"} {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("Expected error to mention %q, got: %v", want, err)
+			}
+		}
+	}
+
+	for _, dest := range []string{"raw/app", "raw/app/new/folder", "raw"} {
+		if err := checkUploadFolders(client, uploadFolders(dest, "", opts), opts); err != nil {
+			t.Errorf("Expected destination %s to pass the check, got %v", dest, err)
+		}
+	}
+}
+
 // TestUploadCompressedGzipWithProgressBar tests uploading with gzip compression and progress bar validation
 func TestUploadCompressedGzipWithProgressBar(t *testing.T) {
 	testDir, err := os.MkdirTemp("", "test-upload-gzip-*")
@@ -751,3 +899,592 @@ func TestUploadYumPackage(t *testing.T) {
 		t.Errorf("Expected repository 'yum-repo', got '%s'", receivedRepository)
 	}
 }
+
+// TestUploadSpecialCharacterPaths tests that file names with spaces, plus and percent signs reach Nexus unmodified
+func TestUploadSpecialCharacterPaths(t *testing.T) {
+	testDir := t.TempDir()
+	files := map[string]string{
+		"my file (v2)+final.txt": "spaces and plus",
+		"sub dir/100%done.bin":   "percent sign",
+	}
+	for name, content := range files {
+		fullPath := filepath.Join(testDir, name)
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fullPath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+
+	config := &config.Config{
+		NexusURL: server.URL,
+		Username: "test",
+		Password: "test",
+	}
+
+	opts := &UploadOptions{
+		Logger:    util.NewLogger(io.Discard),
+		QuietMode: true,
+	}
+
+	if err := uploadFiles(testDir, "test-repo", "target dir", config, opts); err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+
+	uploaded := make(map[string]string)
+	for _, file := range server.GetUploadedFiles() {
+		uploaded[file.Path] = string(file.Content)
+	}
+
+	for name, content := range files {
+		remotePath := "/target dir/" + name
+		got, ok := uploaded[remotePath]
+		if !ok {
+			t.Errorf("Expected upload at %q, got paths %v", remotePath, uploaded)
+			continue
+		}
+		if got != content {
+			t.Errorf("Expected content %q at %q, got %q", content, remotePath, got)
+		}
+	}
+}
+
+// TestUploadFlattenCollision tests that --flatten detects files mapping to the same remote path
+func TestUploadFlattenCollision(t *testing.T) {
+	testDir := t.TempDir()
+	files := map[string]string{
+		"a/conf.json": "from a",
+		"b/conf.json": "from b",
+		"c/app.yml":   "app",
+	}
+	for name, content := range files {
+		fullPath := filepath.Join(testDir, name)
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fullPath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("error", func(t *testing.T) {
+		server := nexusapi.NewMockNexusServer()
+		defer server.Close()
+
+		config := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+		opts := &UploadOptions{
+			Logger:    util.NewLogger(io.Discard),
+			QuietMode: true,
+			Flatten:   true,
+		}
+
+		err := uploadFiles(testDir, "test-repo", "flat", config, opts)
+		if err == nil {
+			t.Fatal("Expected upload to fail on flatten collision")
+		}
+		if !strings.Contains(err.Error(), "conf.json <- a/conf.json, b/conf.json") {
+			t.Errorf("Expected error to list conflicting paths, got: %v", err)
+		}
+		if len(server.GetUploadedFiles()) != 0 {
+			t.Errorf("Expected no files to be uploaded, got %d", len(server.GetUploadedFiles()))
+		}
+	})
+
+	t.Run("rename", func(t *testing.T) {
+		server := nexusapi.NewMockNexusServer()
+		defer server.Close()
+
+		config := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+		opts := &UploadOptions{
+			Logger:            util.NewLogger(io.Discard),
+			QuietMode:         true,
+			Flatten:           true,
+			FlattenOnConflict: FlattenConflictRename,
+		}
+
+		if err := uploadFiles(testDir, "test-repo", "flat", config, opts); err != nil {
+			t.Fatalf("Upload failed: %v", err)
+		}
+
+		uploaded := make(map[string]string)
+		for _, file := range server.GetUploadedFiles() {
+			uploaded[file.Path] = string(file.Content)
+		}
+		expected := map[string]string{
+			"/flat/conf.json":   "from a",
+			"/flat/conf-1.json": "from b",
+			"/flat/app.yml":     "app",
+		}
+		if len(uploaded) != len(expected) {
+			t.Errorf("Expected %d uploaded files, got %v", len(expected), uploaded)
+		}
+		for remotePath, content := range expected {
+			if uploaded[remotePath] != content {
+				t.Errorf("Expected content %q at %q, got %q", content, remotePath, uploaded[remotePath])
+			}
+		}
+	})
+
+	t.Run("skip", func(t *testing.T) {
+		server := nexusapi.NewMockNexusServer()
+		defer server.Close()
+
+		config := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+		opts := &UploadOptions{
+			Logger:            util.NewLogger(io.Discard),
+			QuietMode:         true,
+			Flatten:           true,
+			FlattenOnConflict: FlattenConflictSkip,
+		}
+
+		if err := uploadFiles(testDir, "test-repo", "flat", config, opts); err != nil {
+			t.Fatalf("Upload failed: %v", err)
+		}
+
+		uploaded := make(map[string]string)
+		for _, file := range server.GetUploadedFiles() {
+			uploaded[file.Path] = string(file.Content)
+		}
+		expected := map[string]string{
+			"/flat/conf.json": "from a",
+			"/flat/app.yml":   "app",
+		}
+		if len(uploaded) != len(expected) {
+			t.Errorf("Expected %d uploaded files, got %v", len(expected), uploaded)
+		}
+		for remotePath, content := range expected {
+			if uploaded[remotePath] != content {
+				t.Errorf("Expected content %q at %q, got %q", content, remotePath, uploaded[remotePath])
+			}
+		}
+	})
+}
+
+// TestUploadUnreadableFiles tests that unreadable files are skipped with a warning unless --strict is set
+func TestUploadUnreadableFiles(t *testing.T) {
+	testDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(testDir, "readable.txt"), []byte("ok"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	lockedFile := filepath.Join(testDir, "core.dump")
+	if err := os.WriteFile(lockedFile, []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(lockedFile, 0000); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chmod(lockedFile, 0644)
+
+	if f, err := os.Open(lockedFile); err == nil {
+		f.Close()
+		t.Skip("file permissions are not enforced on this platform or for this user")
+	}
+
+	t.Run("skip with warning", func(t *testing.T) {
+		server := nexusapi.NewMockNexusServer()
+		defer server.Close()
+
+		config := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+		var logBuf strings.Builder
+		opts := &UploadOptions{
+			Logger: util.NewLogger(&logBuf),
+		}
+
+		if err := uploadFiles(testDir, "test-repo", "", config, opts); err != nil {
+			t.Fatalf("Upload failed: %v", err)
+		}
+
+		uploaded := server.GetUploadedFiles()
+		if len(uploaded) != 1 || uploaded[0].Filename != "readable.txt" {
+			t.Errorf("Expected only readable.txt to be uploaded, got %v", uploaded)
+		}
+		logOutput := logBuf.String()
+		if !strings.Contains(logOutput, "Warning: skipping unreadable file core.dump") {
+			t.Errorf("Expected warning for unreadable file, got: %s", logOutput)
+		}
+		if !strings.Contains(logOutput, "unreadable: 1") {
+			t.Errorf("Expected summary to count unreadable files, got: %s", logOutput)
+		}
+	})
+
+	t.Run("strict", func(t *testing.T) {
+		server := nexusapi.NewMockNexusServer()
+		defer server.Close()
+
+		config := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+		opts := &UploadOptions{
+			Logger:    util.NewLogger(io.Discard),
+			QuietMode: true,
+			Strict:    true,
+		}
+
+		err := uploadFiles(testDir, "test-repo", "", config, opts)
+		if err == nil {
+			t.Fatal("Expected upload to fail in strict mode")
+		}
+		if !strings.Contains(err.Error(), "1 unreadable file(s)") || !strings.Contains(err.Error(), "core.dump") {
+			t.Errorf("Expected error to list unreadable file, got: %v", err)
+		}
+		if len(server.GetUploadedFiles()) != 0 {
+			t.Errorf("Expected nothing to be uploaded in strict mode, got %d files", len(server.GetUploadedFiles()))
+		}
+	})
+}
+
+func TestUploadExtensionFilters(t *testing.T) {
+	testDir := t.TempDir()
+	for _, name := range []string{"app.jar", "app.pom", "certs/server.pem", "certs/server.key", ".env", "README.md"} {
+		fullPath := filepath.Join(testDir, name)
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fullPath, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name         string
+		allow        []string
+		deny         []string
+		policy       DeniedExtensionPolicy
+		wantUploaded []string
+		wantDenied   []string
+	}{
+		{
+			name:         "allow only",
+			allow:        []string{".jar", "pom"},
+			policy:       DeniedExtensionSkip,
+			wantUploaded: []string{"app.jar", "app.pom"},
+			wantDenied:   []string{".env", "README.md", "certs/server.key", "certs/server.pem"},
+		},
+		{
+			name:         "deny only",
+			deny:         []string{".pem", ".key", ".env"},
+			policy:       DeniedExtensionSkip,
+			wantUploaded: []string{"README.md", "app.jar", "app.pom"},
+			wantDenied:   []string{".env", "certs/server.key", "certs/server.pem"},
+		},
+		{
+			name:         "allow and deny",
+			allow:        []string{".jar", ".pem"},
+			deny:         []string{".pem"},
+			policy:       DeniedExtensionSkip,
+			wantUploaded: []string{"app.jar"},
+			wantDenied:   []string{".env", "README.md", "app.pom", "certs/server.key", "certs/server.pem"},
+		},
+		{
+			name:       "abort",
+			deny:       []string{".pem", ".key", ".env"},
+			policy:     DeniedExtensionAbort,
+			wantDenied: []string{".env", "certs/server.key", "certs/server.pem"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := nexusapi.NewMockNexusServer()
+			defer server.Close()
+
+			config := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+			var logBuf strings.Builder
+			opts := &UploadOptions{
+				Logger:          util.NewLogger(&logBuf),
+				AllowExtensions: tt.allow,
+				DenyExtensions:  tt.deny,
+				OnDeniedExt:     tt.policy,
+			}
+
+			err := uploadFiles(testDir, "test-repo", "", config, opts)
+
+			if tt.policy == DeniedExtensionAbort {
+				if err == nil {
+					t.Fatal("Expected upload to abort on denied extensions")
+				}
+				for _, denied := range tt.wantDenied {
+					if !strings.Contains(err.Error(), denied) {
+						t.Errorf("Expected error to list %s, got: %v", denied, err)
+					}
+				}
+				if len(server.GetUploadedFiles()) != 0 {
+					t.Errorf("Expected no files to be uploaded, got %d", len(server.GetUploadedFiles()))
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Upload failed: %v", err)
+			}
+			var uploaded []string
+			for _, file := range server.GetUploadedFiles() {
+				uploaded = append(uploaded, strings.TrimPrefix(file.Path, "/"))
+			}
+			sort.Strings(uploaded)
+			if strings.Join(uploaded, ",") != strings.Join(tt.wantUploaded, ",") {
+				t.Errorf("Expected uploaded files %v, got %v", tt.wantUploaded, uploaded)
+			}
+			for _, denied := range tt.wantDenied {
+				if !strings.Contains(logBuf.String(), "Warning: skipping file with denied extension "+denied) {
+					t.Errorf("Expected warning for %s, got: %s", denied, logBuf.String())
+				}
+			}
+		})
+	}
+}
+
+func TestUploadCompressedDeniedExtension(t *testing.T) {
+	testDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(testDir, "app.jar"), []byte("jar"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(testDir, "id_rsa.key"), []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+
+	config := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+	opts := &UploadOptions{
+		Logger:            util.NewLogger(io.Discard),
+		QuietMode:         true,
+		Compress:          true,
+		CompressionFormat: archive.FormatGzip,
+		DenyExtensions:    []string{".key"},
+	}
+
+	err := uploadFilesCompressedWithArchiveName(testDir, "test-repo", "", "bundle.tar.gz", config, opts)
+	if err == nil || !strings.Contains(err.Error(), "id_rsa.key") {
+		t.Fatalf("Expected compressed upload to abort listing id_rsa.key, got: %v", err)
+	}
+	if len(server.GetUploadedFiles()) != 0 {
+		t.Errorf("Expected no archive to be uploaded, got %d", len(server.GetUploadedFiles()))
+	}
+}
+
+func newOfflineUploadSource(t *testing.T) string {
+	t.Helper()
+	src := t.TempDir()
+	for _, name := range []string{"a.txt", "sub/b.txt"} {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(src, name)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(src, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return src
+}
+
+// TestUploadDryRunUnreachableServer checks that a dry run lists the local candidates with
+// a warning instead of failing when Nexus cannot be reached
+func TestUploadDryRunUnreachableServer(t *testing.T) {
+	oldBackoff := nexusapi.ListRetryBackoff
+	nexusapi.ListRetryBackoff = time.Millisecond
+	defer func() { nexusapi.ListRetryBackoff = oldBackoff }()
+
+	server := nexusapi.NewMockNexusServer()
+	server.Close()
+	config := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+	src := newOfflineUploadSource(t)
+
+	var logBuf strings.Builder
+	opts := &UploadOptions{Logger: util.NewVerboseLogger(&logBuf), DryRun: true}
+	opts.SetChecksumAlgorithm("sha1")
+	if err := checkUploadRepositories(nexusapi.NewClient(config.NexusURL, "test", "test"), []string{"repo"}, opts); err != nil {
+		t.Fatalf("Expected the write check to be skipped, got: %v", err)
+	}
+	if err := uploadFiles(src, "repo", "dir", config, opts); err != nil {
+		t.Fatalf("Expected dry run to succeed without Nexus, got: %v", err)
+	}
+
+	output := logBuf.String()
+	if strings.Count(output, "cannot reach Nexus") != 1 {
+		t.Errorf("Expected a single warning about the unreachable server, got: %s", output)
+	}
+	for _, name := range []string{"Would upload: a.txt", "Would upload: sub/b.txt", "Nexus was not contacted"} {
+		if !strings.Contains(output, name) {
+			t.Errorf("Expected %q in output, got: %s", name, output)
+		}
+	}
+
+	opts = &UploadOptions{Logger: util.NewLogger(io.Discard), QuietMode: true}
+	opts.SetChecksumAlgorithm("sha1")
+	if err := uploadFiles(src, "repo", "dir", config, opts); err == nil {
+		t.Error("Expected a real upload to fail without Nexus")
+	}
+}
+
+// TestUploadDryRunOffline checks that --offline never contacts Nexus, even if files exist there
+func TestUploadDryRunOffline(t *testing.T) {
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+	server.AddAsset("repo", "/dir/a.txt", nexusapi.Asset{}, []byte("a.txt"))
+	config := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+	src := newOfflineUploadSource(t)
+
+	var logBuf strings.Builder
+	opts := &UploadOptions{Logger: util.NewVerboseLogger(&logBuf), DryRun: true, Offline: true}
+	opts.SetChecksumAlgorithm("sha1")
+	if err := uploadFiles(src, "repo", "dir", config, opts); err != nil {
+		t.Fatalf("Expected dry run to succeed, got: %v", err)
+	}
+	if server.GetRequestCount() != 0 {
+		t.Errorf("Expected no requests to Nexus, got %d", server.GetRequestCount())
+	}
+	if !strings.Contains(logBuf.String(), "Would upload: a.txt") {
+		t.Errorf("Expected the existing file to be listed as a candidate, got: %s", logBuf.String())
+	}
+}
+
+// TestUploadChecksumFallback tests that an asset reporting only SHA256 is compared with
+// SHA256 rather than uploaded again when SHA1 is configured
+func TestUploadChecksumFallback(t *testing.T) {
+	testDir := t.TempDir()
+	content := []byte("only sha256 is known")
+	if err := os.WriteFile(filepath.Join(testDir, "same.txt"), content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(testDir, "changed.txt"), []byte("new content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+	// Without content the mock server reports only the checksums given here
+	sum := sha256.Sum256(content)
+	server.AddAsset("test-repo", "/same.txt", nexusapi.Asset{FileSize: int64(len(content)), Checksum: nexusapi.Checksum{SHA256: hex.EncodeToString(sum[:])}}, nil)
+	sum = sha256.Sum256([]byte("old content"))
+	server.AddAsset("test-repo", "/changed.txt", nexusapi.Asset{FileSize: 11, Checksum: nexusapi.Checksum{SHA256: hex.EncodeToString(sum[:])}}, nil)
+
+	config := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+	var logBuf strings.Builder
+	opts := &UploadOptions{Logger: util.NewVerboseLogger(&logBuf), QuietMode: true}
+	if err := opts.SetChecksumAlgorithm("sha1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := uploadFiles(testDir, "test-repo", "", config, opts); err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+
+	uploadedFiles := server.GetUploadedFiles()
+	if len(uploadedFiles) != 1 || uploadedFiles[0].Filename != "changed.txt" {
+		t.Errorf("Expected only changed.txt to be uploaded, got %+v", uploadedFiles)
+	}
+	if !strings.Contains(logBuf.String(), "comparing sha256 instead") {
+		t.Errorf("Expected the fallback to be logged, got: %s", logBuf.String())
+	}
+}
+
+// TestUploadCompareSizeFirst tests that only files of the same size as the asset are hashed,
+// and that --compare size skips them without hashing
+func TestUploadCompareSizeFirst(t *testing.T) {
+	testDir := t.TempDir()
+	files := map[string]string{"same.txt": "unchanged", "samesize.txt": "new value", "grown.txt": "grown content"}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(testDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+	server.AddAsset("test-repo", "/same.txt", nexusapi.Asset{}, []byte("unchanged"))
+	server.AddAsset("test-repo", "/samesize.txt", nexusapi.Asset{}, []byte("old value"))
+	// The reported checksum matches the local file, so only the size can cause the upload
+	sum := sha1.Sum([]byte("grown content"))
+	server.AddAsset("test-repo", "/grown.txt", nexusapi.Asset{FileSize: 5, Checksum: nexusapi.Checksum{SHA1: hex.EncodeToString(sum[:])}}, nil)
+	config := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+
+	uploaded := func(compare CompareMode) []string {
+		server.UploadedFiles = make([]nexusapi.UploadedFile, 0)
+		opts := &UploadOptions{Logger: util.NewLogger(io.Discard), QuietMode: true, Compare: compare}
+		if err := opts.SetChecksumAlgorithm("sha1"); err != nil {
+			t.Fatal(err)
+		}
+		if err := uploadFiles(testDir, "test-repo", "", config, opts); err != nil {
+			t.Fatalf("Upload failed: %v", err)
+		}
+		var names []string
+		for _, file := range server.GetUploadedFiles() {
+			names = append(names, file.Filename)
+		}
+		sort.Strings(names)
+		return names
+	}
+
+	if got := uploaded(""); strings.Join(got, ",") != "grown.txt,samesize.txt" {
+		t.Errorf("Expected the files with another size or checksum to be uploaded, got %v", got)
+	}
+	if got := uploaded(CompareSize); strings.Join(got, ",") != "grown.txt" {
+		t.Errorf("Expected only the file with another size to be uploaded, got %v", got)
+	}
+}
+
+func TestUploadStreamsWhileWalking(t *testing.T) {
+	const total, existing = 300, 30
+	testDir := t.TempDir()
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+	for i := 0; i < total; i++ {
+		relPath := fmt.Sprintf("dir%02d/file%03d.txt", i/25, i)
+		content := []byte(fmt.Sprintf("content of file %d", i))
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(testDir, relPath)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(testDir, relPath), content, 0644); err != nil {
+			t.Fatal(err)
+		}
+		// Every few files are already uploaded and are skipped by the walk
+		if i%(total/existing) == 0 {
+			server.AddAsset("test-repo", "/dist/"+relPath, nexusapi.Asset{}, content)
+		}
+	}
+	config := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+
+	for _, batchSize := range []int{0, 50} {
+		server.UploadedFiles = make([]nexusapi.UploadedFile, 0)
+		var logBuf strings.Builder
+		opts := &UploadOptions{Logger: util.NewLogger(&logBuf), QuietMode: true, BatchSize: batchSize}
+		if err := opts.SetChecksumAlgorithm("sha1"); err != nil {
+			t.Fatal(err)
+		}
+
+		// The walk holds back its last file until a file has been handed to a request, so
+		// the upload only completes if the transfer begins before the walk is done
+		var walked int
+		var once sync.Once
+		started := make(chan struct{})
+		opts.streamHooks = &uploadStreamHooks{
+			walked: func(relPath string) {
+				if walked++; walked < total {
+					return
+				}
+				select {
+				case <-started:
+				case <-time.After(10 * time.Second):
+					t.Errorf("batch size %d: no transfer began before the walk completed", batchSize)
+				}
+			},
+			sent: func(relPath string) { once.Do(func() { close(started) }) },
+		}
+
+		if err := uploadFiles(testDir, "test-repo", "dist", config, opts); err != nil {
+			t.Fatalf("batch size %d: upload failed: %v", batchSize, err)
+		}
+		if walked != total {
+			t.Errorf("batch size %d: expected %d files to be walked, got %d", batchSize, total, walked)
+		}
+		if uploaded := len(server.GetUploadedFiles()); uploaded != total-existing {
+			t.Errorf("batch size %d: expected %d files to be uploaded, got %d", batchSize, total-existing, uploaded)
+		}
+		summary := fmt.Sprintf("Files uploaded: %d, skipped: %d", total-existing, existing)
+		if !strings.Contains(logBuf.String(), summary) {
+			t.Errorf("batch size %d: expected %q in the summary, got: %s", batchSize, summary, logBuf.String())
+		}
+	}
+}
@@ -0,0 +1,410 @@
+package operations
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/tympanix/nexus-cli/internal/archive"
+	"github.com/tympanix/nexus-cli/internal/nexusapi"
+	"github.com/tympanix/nexus-cli/internal/output"
+	"github.com/tympanix/nexus-cli/internal/progress"
+	"github.com/tympanix/nexus-cli/internal/util"
+)
+
+// streamBuffer is how many found files an upload holds ahead of the requests
+const streamBuffer = 64
+
+// errStreamStopped ends the walk of an upload whose requests have failed
+var errStreamStopped = errors.New("upload stopped")
+
+// streamedFile is a file of an upload with its size
+type streamedFile struct {
+	upload nexusapi.FileUpload
+	size   int64
+}
+
+// uploadStreamHooks instrument a walked upload in tests
+type uploadStreamHooks struct {
+	walked func(relPath string) // A file was found by the walk, before it is compared
+	sent   func(relPath string) // A file was handed to an upload request
+}
+
+// canStreamUpload reports whether files can be uploaded while the source is still being
+// walked. Flattening, routes, dry-run, --strict and --on-denied-ext=abort all need the
+// complete list of files before anything is uploaded.
+func canStreamUpload(opts *UploadOptions) bool {
+	extFilter := len(opts.AllowExtensions) > 0 || len(opts.DenyExtensions) > 0
+	return !opts.Flatten && len(opts.Routes) == 0 && !opts.DryRun && !opts.Strict &&
+		(!extFilter || opts.OnDeniedExt == DeniedExtensionSkip)
+}
+
+// uploadCandidate is a file found for an upload, or a file that could not be read
+type uploadCandidate struct {
+	filePath string
+	relPath  string // The remote path relative to the destination folder
+	size     int64
+	err      error // Why the file could not be read; it is reported and not uploaded
+}
+
+// uploadSource yields the files of an upload on files, which is closed once all of them
+// are found. A collected source has all its files before the upload begins; a walked
+// source finds them while the first requests are sent.
+type uploadSource struct {
+	src   string
+	files <-chan uploadCandidate
+	count int           // The number of files, or -1 while they are still being found
+	bytes int64         // The total size of the files, if count is known
+	stop  chan struct{} // Closed to end a walk early
+	start func()        // Begins the walk, once the upload has started; nil if collected
+
+	// Set before files is closed
+	filtered output.FilterCounts
+	err      error
+}
+
+// collectedUploadSource yields the given files from src, with their remote paths flattened
+// if enabled, after the unreadable files
+func collectedUploadSource(src string, filePaths []string, unreadable []archive.UnreadableFile, opts *UploadOptions) (*uploadSource, error) {
+	var err error
+
+	// Compute the remote path of every file relative to subdir, applying flatten logic if enabled
+	remotePaths := make(map[string]string, len(filePaths))
+	for _, filePath := range filePaths {
+		relPath, _ := filepath.Rel(src, filePath)
+		remotePaths[filePath] = filepath.ToSlash(relPath)
+	}
+
+	// Detect files that flatten onto the same remote path before uploading anything
+	if opts.Flatten {
+		flattened := make(map[string]string, len(filePaths))
+		for _, relPath := range remotePaths {
+			flattened[relPath] = path.Base(relPath)
+		}
+		flattened, err = resolveFlattenCollisions(flattened, opts.FlattenOnConflict)
+		if err != nil {
+			return nil, err
+		}
+		// With --flatten-on-conflict=skip only the first file for each remote path is kept
+		kept := filePaths[:0:0]
+		for _, filePath := range filePaths {
+			target, ok := flattened[remotePaths[filePath]]
+			if !ok {
+				opts.Logger.Printf("Skipping %s (flattens onto the same remote path as another file)\n", remotePaths[filePath])
+				delete(remotePaths, filePath)
+				continue
+			}
+			remotePaths[filePath] = target
+			kept = append(kept, filePath)
+		}
+		filePaths = kept
+	}
+
+	files := make(chan uploadCandidate, len(unreadable)+len(filePaths))
+	source := &uploadSource{src: src, files: files, count: len(filePaths), stop: make(chan struct{})}
+	for _, file := range unreadable {
+		relPath, _ := filepath.Rel(src, file.Path)
+		files <- uploadCandidate{filePath: file.Path, relPath: filepath.ToSlash(relPath), err: file.Err}
+	}
+	for _, filePath := range filePaths {
+		info, err := os.Stat(filePath)
+		if err != nil {
+			return nil, err
+		}
+		source.bytes += info.Size()
+		files <- uploadCandidate{filePath: filePath, relPath: remotePaths[filePath], size: info.Size()}
+	}
+	close(files)
+
+	// Reported once, by the summary of the first destination of a routed upload
+	source.filtered = opts.filtered
+	opts.filtered = output.FilterCounts{}
+	return source, nil
+}
+
+// uploadWalk finds the files of a walked upload source
+type uploadWalk struct {
+	src    string
+	glob   *util.GlobPattern
+	source *uploadSource
+	opts   *UploadOptions
+	found  int
+}
+
+// walkedUploadSource yields the files in src while a goroutine walks it
+func walkedUploadSource(src string, opts *UploadOptions) *uploadSource {
+	files := make(chan uploadCandidate, streamBuffer)
+	walk := &uploadWalk{
+		src:    src,
+		source: &uploadSource{src: src, files: files, count: -1, stop: make(chan struct{})},
+		opts:   opts,
+	}
+	if opts.GlobPattern != "" {
+		walk.glob = util.ParseGlobPattern(opts.GlobPattern)
+	}
+	walk.source.start = func() { go walk.run(files) }
+	return walk.source
+}
+
+// run walks the source, sending the files found on files, and closes files when the walk
+// is done or the source is stopped
+func (w *uploadWalk) run(files chan<- uploadCandidate) {
+	defer close(files)
+	totalBytes := int64(0)
+	w.source.err = filepath.Walk(w.src, func(filePath string, info os.FileInfo, err error) error {
+		if err != nil {
+			if filePath == w.src {
+				return err
+			}
+			if err := w.send(files, uploadCandidate{filePath: filePath, relPath: w.relPath(filePath), err: err}); err != nil {
+				return err
+			}
+			if info != nil && info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() {
+			return nil
+		}
+		candidate, ok, err := w.include(filePath)
+		if !ok || err != nil {
+			return err
+		}
+		if candidate.err == nil {
+			totalBytes += candidate.size
+			w.found++
+			if hooks := w.opts.streamHooks; hooks != nil && hooks.walked != nil {
+				hooks.walked(candidate.relPath)
+			}
+		}
+		return w.send(files, candidate)
+	})
+	if w.source.err == nil {
+		w.opts.Logger.VerbosePrintf("Found %d files (%s) in %s\n", w.found, util.FormatByteSize(totalBytes), w.src)
+	}
+}
+
+// include reports whether the file at filePath is part of the upload and returns it as a
+// candidate. Files are filtered in the same order as for an upload that collects them first.
+func (w *uploadWalk) include(filePath string) (uploadCandidate, bool, error) {
+	relPath := w.relPath(filePath)
+	if w.glob != nil {
+		matched, err := w.glob.Match(filepath.FromSlash(relPath))
+		if err != nil || !matched {
+			if err == nil {
+				w.source.filtered.Glob++
+			}
+			return uploadCandidate{}, false, err
+		}
+	}
+	f, err := os.Open(filePath)
+	if err != nil {
+		return uploadCandidate{filePath: filePath, relPath: relPath, err: err}, true, nil
+	}
+	f.Close()
+	if isExtensionDenied(filepath.Base(filePath), w.opts.AllowExtensions, w.opts.DenyExtensions) {
+		if !w.opts.QuietMode {
+			w.opts.Logger.Printf("Warning: skipping file with denied extension %s\n", relPath)
+		}
+		w.source.filtered.Extension++
+		return uploadCandidate{}, false, nil
+	}
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return uploadCandidate{}, false, err
+	}
+	return uploadCandidate{filePath: filePath, relPath: relPath, size: info.Size()}, true, nil
+}
+
+// send sends a found file on files, unless the source is stopped first
+func (w *uploadWalk) send(files chan<- uploadCandidate, candidate uploadCandidate) error {
+	select {
+	case files <- candidate:
+		return nil
+	case <-w.source.stop:
+		return errStreamStopped
+	}
+}
+
+// relPath returns the remote path of filePath relative to the destination folder
+func (w *uploadWalk) relPath(filePath string) string {
+	relPath, _ := filepath.Rel(w.src, filePath)
+	return filepath.ToSlash(relPath)
+}
+
+// uploadCompare compares the files of an upload source with the remote assets and plans
+// the upload of the files that are not up to date
+type uploadCompare struct {
+	source       *uploadSource
+	target       string // The repository and folder uploaded to
+	remoteAssets map[string]nexusapi.Asset
+	search       *contentSearch
+	plan         *Plan
+	bar          *progress.ProgressBarWithCount
+	tracker      *output.TransferTracker
+	opts         *UploadOptions
+
+	// Set by run; only read once the channel it sends on is closed
+	filePaths   []string
+	remotePaths map[string]string
+	chunked     []Action // Files larger than --chunked, uploaded in parts after the others
+}
+
+// run compares the files of the source as they are found and sends the planned uploads
+// on uploads, except those kept back for a chunked upload. It closes uploads once the
+// source is done, or once stop is closed and the source has stopped.
+func (c *uploadCompare) run(uploads chan<- Action, stop <-chan struct{}) {
+	defer close(uploads)
+	stopSource := func() {
+		close(c.source.stop)
+		for range c.source.files {
+		}
+	}
+	for candidate := range c.source.files {
+		select {
+		case <-stop:
+			stopSource()
+			return
+		default:
+		}
+		if candidate.err != nil {
+			c.tracker.RecordFile(output.FileTransfer{
+				Path:   candidate.relPath,
+				Status: output.TransferStatusUnreadable,
+				Error:  candidate.err,
+			})
+			continue
+		}
+		action, ok := c.compare(candidate)
+		if !ok {
+			continue
+		}
+		if c.opts.ChunkSize > 0 && candidate.size > c.opts.ChunkSize && !c.opts.DryRun {
+			c.chunked = append(c.chunked, action)
+			continue
+		}
+		select {
+		case uploads <- action:
+		case <-stop:
+			stopSource()
+			return
+		}
+	}
+}
+
+// compare records a found file and plans its upload, unless it is up to date
+func (c *uploadCompare) compare(candidate uploadCandidate) (Action, bool) {
+	filePath, relPath, size := candidate.filePath, candidate.relPath, candidate.size
+	c.filePaths = append(c.filePaths, filePath)
+	c.remotePaths[filePath] = relPath
+	if c.source.count < 0 {
+		c.bar.Grow(size, 1)
+	}
+
+	skipReason := checkRemoteMatch(filePath, relPath, size, c.remoteAssets, c.bar, c.tracker, c.opts)
+	if skipReason == "" && c.search.upToDate(filePath, relPath, size, c.bar, c.tracker, c.opts) {
+		skipReason = "SHA256 match"
+	}
+	if skipReason != "" {
+		c.tracker.RecordFile(output.FileTransfer{
+			Path:   relPath,
+			Size:   size,
+			Status: output.TransferStatusSkipped,
+			Reason: skipReason,
+		})
+		c.bar.IncrementFile()
+		return Action{}, false
+	}
+
+	action := Action{Type: ActionUpload, Source: planLocalPath(c.source.src, filePath), Target: path.Join(c.target, relPath), Size: size, localPath: filePath, relPath: relPath}
+	c.plan.Add(action)
+	return action, true
+}
+
+// sentUpload reports file to the instrumentation of tests
+func sentUpload(opts *UploadOptions, file streamedFile) {
+	if hooks := opts.streamHooks; hooks != nil && hooks.sent != nil {
+		hooks.sent(file.upload.RelativePath)
+	}
+}
+
+// plannedUpload returns the file uploaded by action
+func plannedUpload(action Action, opts *UploadOptions) streamedFile {
+	return streamedFile{
+		upload: nexusapi.FileUpload{
+			FilePath:     action.localPath,
+			RelativePath: action.relPath,
+			ContentType:  opts.contentType(action.localPath),
+		},
+		size: action.Size,
+	}
+}
+
+// sendPlannedUploads uploads the files of the actions received on uploads until it is
+// closed: in a single request, or with batch limits in as many requests as the limits
+// require. It returns once all are sent, or on the first error. An error of the source
+// fails the upload.
+func sendPlannedUploads(client *nexusapi.Client, repository, subdir string, uploads <-chan Action, source *uploadSource, bar *progress.ProgressBarWithCount, tracker *output.TransferTracker, opts *UploadOptions) error {
+	if opts.BatchSize == 0 && opts.MaxRequestBytes == 0 {
+		// The request is only started with its first file, since a form without files
+		// would be rejected. An error of the source aborts the request.
+		first, ok := <-uploads
+		if !ok {
+			return source.err
+		}
+		pending := &first
+		return uploadStream(client, repository, subdir, func() (streamedFile, error) {
+			action := pending
+			if action == nil {
+				next, ok := <-uploads
+				if !ok && source.err != nil {
+					return streamedFile{}, source.err
+				}
+				if !ok {
+					return streamedFile{}, io.EOF
+				}
+				action = &next
+			}
+			pending = nil
+			file := plannedUpload(*action, opts)
+			sentUpload(opts, file)
+			return file, nil
+		}, bar, tracker)
+	}
+
+	var batch []nexusapi.FileUpload
+	var sizes []int64
+	var batchBytes int64
+	n := 0
+	flush := func() error {
+		n++
+		opts.Logger.VerbosePrintf("Uploading batch %d (%d files, %s)\n", n, len(batch), util.FormatByteSize(batchBytes))
+		for i := range batch {
+			sentUpload(opts, streamedFile{upload: batch[i], size: sizes[i]})
+		}
+		err := uploadBatchWithRetry(client, repository, subdir, batch, sizes, bar, tracker, opts)
+		batch, sizes, batchBytes = nil, nil, 0
+		return err
+	}
+	for action := range uploads {
+		file := plannedUpload(action, opts)
+		if startsNewBatch(len(batch), batchBytes, file.size, opts.BatchSize, opts.MaxRequestBytes) {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+		batch = append(batch, file.upload)
+		sizes = append(sizes, file.size)
+		batchBytes += file.size
+	}
+	if source.err != nil {
+		return source.err
+	}
+	if len(batch) == 0 {
+		return nil
+	}
+	return flush()
+}
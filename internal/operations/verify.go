@@ -0,0 +1,157 @@
+package operations
+
+import (
+	"errors"
+	"fmt"
+	"hash"
+	"os"
+	"strings"
+
+	"github.com/tympanix/nexus-cli/internal/checksum"
+	"github.com/tympanix/nexus-cli/internal/nexusapi"
+)
+
+// VerifyLevel controls how a file is verified after it has been downloaded
+type VerifyLevel string
+
+const (
+	VerifyChecksum VerifyLevel = "checksum" // Compare the checksum of the written data with Nexus (default)
+	VerifySize     VerifyLevel = "size"     // Compare the written file size with Nexus without hashing
+	VerifyNone     VerifyLevel = "none"     // Do not verify downloaded files
+)
+
+// ParseVerifyLevel parses a string into a VerifyLevel
+func ParseVerifyLevel(s string) (VerifyLevel, error) {
+	switch strings.ToLower(s) {
+	case "", "checksum":
+		return VerifyChecksum, nil
+	case "size":
+		return VerifySize, nil
+	case "none":
+		return VerifyNone, nil
+	default:
+		return "", fmt.Errorf("unsupported verify level '%s': must be one of: checksum, size, none", s)
+	}
+}
+
+// CompareMode controls how an existing local file is compared with Nexus to decide
+// whether it is up to date and can be skipped, on download and on upload
+type CompareMode string
+
+const (
+	CompareExistence CompareMode = "existence" // Any existing file is up to date (default with --skip-checksum)
+	CompareSize      CompareMode = "size"      // The local size must match the size reported by Nexus
+	CompareChecksum  CompareMode = "checksum"  // The local checksum must match Nexus (default)
+)
+
+// ParseCompareMode parses a string into a CompareMode. An empty string selects the
+// default, which depends on --skip-checksum and is resolved by DownloadOptions.
+func ParseCompareMode(s string) (CompareMode, error) {
+	switch strings.ToLower(s) {
+	case "":
+		return "", nil
+	case "existence":
+		return CompareExistence, nil
+	case "size":
+		return CompareSize, nil
+	case "checksum":
+		return CompareChecksum, nil
+	default:
+		return "", fmt.Errorf("unsupported compare mode '%s': must be one of: existence, size, checksum", s)
+	}
+}
+
+// EffectiveCompare returns the effective compare mode. --skip-checksum keeps its original
+// meaning of an existence check unless a mode is given explicitly.
+func (opts *DownloadOptions) EffectiveCompare() CompareMode {
+	if opts.Compare != "" {
+		return opts.Compare
+	}
+	if opts.SkipChecksum {
+		return CompareExistence
+	}
+	return CompareChecksum
+}
+
+// EffectiveCompare returns the effective compare mode of an upload, resolved like for downloads
+func (opts *UploadOptions) EffectiveCompare() CompareMode {
+	if opts.Compare != "" {
+		return opts.Compare
+	}
+	if opts.SkipChecksum {
+		return CompareExistence
+	}
+	return CompareChecksum
+}
+
+// verifyLevel returns the effective verification level. --skip-checksum turns off
+// checksum verification entirely, but an explicit size check is still honoured.
+func (opts *DownloadOptions) verifyLevel() VerifyLevel {
+	level := opts.Verify
+	if level == "" {
+		level = VerifyChecksum
+	}
+	if level == VerifyChecksum && (opts.SkipChecksum || opts.checksumValidator == nil) {
+		return VerifyNone
+	}
+	return level
+}
+
+// newVerifyHash returns a hash to feed the downloaded data into when checksums are verified, or nil
+func newVerifyHash(opts *DownloadOptions) hash.Hash {
+	if opts.verifyLevel() != VerifyChecksum {
+		return nil
+	}
+	h, _ := checksum.NewHasher(opts.checksumValidator.Algorithm())
+	return h
+}
+
+// verifyDownload checks a downloaded file against the asset metadata according to the
+// verification level. digest holds the data written when checksums are verified. If Nexus
+// reports no usable checksum for the asset, the size is verified instead.
+func verifyDownload(localPath string, asset nexusapi.Asset, digest hash.Hash, opts *DownloadOptions) error {
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return err
+	}
+	return verifyTransfer(asset, digest, info.Size(), opts)
+}
+
+// verifyTransfer checks downloaded data of the given size against the asset metadata like
+// verifyDownload, for data that was not written to a file of its own
+func verifyTransfer(asset nexusapi.Asset, digest hash.Hash, size int64, opts *DownloadOptions) error {
+	level := opts.verifyLevel()
+	if level == VerifyNone {
+		return nil
+	}
+
+	if level == VerifyChecksum && digest != nil {
+		algorithm := opts.checksumValidator.Algorithm()
+		expected := checksum.ExtractChecksum(asset.Checksum, algorithm)
+		if expected != "" {
+			actual := fmt.Sprintf("%x", digest.Sum(nil))
+			match, err := checksum.Matches(expected, actual, algorithm)
+			if err == nil {
+				if !match {
+					return fmt.Errorf("checksum mismatch for %s: expected %s, got %s", asset.Path, expected, actual)
+				}
+				return nil
+			}
+			if !errors.Is(err, checksum.ErrMalformedChecksum) {
+				return err
+			}
+			if !opts.QuietMode {
+				opts.Logger.Printf("Warning: cannot verify %s: server provided %v\n", asset.Path, err)
+			}
+		}
+	}
+
+	// Older assets may not report a size; only an explicit size check treats that as zero bytes
+	if level == VerifyChecksum && asset.FileSize == 0 {
+		return nil
+	}
+	if size != asset.FileSize {
+		return fmt.Errorf("size mismatch for %s: expected %d bytes, got %d", asset.Path, asset.FileSize, size)
+	}
+	return nil
+}
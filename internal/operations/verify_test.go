@@ -0,0 +1,226 @@
+package operations
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tympanix/nexus-cli/internal/config"
+	"github.com/tympanix/nexus-cli/internal/nexusapi"
+	"github.com/tympanix/nexus-cli/internal/util"
+)
+
+func TestParseVerifyLevel(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    VerifyLevel
+		wantErr bool
+	}{
+		{"", VerifyChecksum, false},
+		{"checksum", VerifyChecksum, false},
+		{"SIZE", VerifySize, false},
+		{"none", VerifyNone, false},
+		{"full", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseVerifyLevel(tt.input)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseVerifyLevel(%q) = %q, %v; want %q, error %v", tt.input, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestDownloadVerifyLevels(t *testing.T) {
+	const content = "complete firmware image"
+
+	tests := []struct {
+		name        string
+		level       VerifyLevel
+		served      string // Content served by the server; differs from what Nexus reports when corrupted
+		wantSuccess bool
+	}{
+		{"checksum intact", VerifyChecksum, content, true},
+		{"size intact", VerifySize, content, true},
+		{"none intact", VerifyNone, content, true},
+		{"checksum truncated", VerifyChecksum, content[:8], false},
+		{"size truncated", VerifySize, content[:8], false},
+		{"none truncated", VerifyNone, content[:8], true},
+		// Same size, different bytes: only a checksum catches this
+		{"checksum corrupted", VerifyChecksum, "COMPLETE FIRMWARE IMAGE", false},
+		{"size corrupted", VerifySize, "COMPLETE FIRMWARE IMAGE", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := nexusapi.NewMockNexusServer()
+			defer server.Close()
+			server.AddAsset("test-repo", "/fw/image.bin", nexusapi.Asset{}, []byte(content))
+			server.SetAssetContent("/repository/test-repo/fw/image.bin", []byte(tt.served))
+
+			config := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+			opts := &DownloadOptions{
+				Logger:    util.NewLogger(io.Discard),
+				QuietMode: true,
+				Recursive: true,
+				Verify:    tt.level,
+			}
+			if err := opts.SetChecksumAlgorithm("sha256"); err != nil {
+				t.Fatal(err)
+			}
+
+			destDir := t.TempDir()
+			status := downloadFolder("test-repo/fw", destDir, config, opts)
+
+			localPath := filepath.Join(destDir, "fw", "image.bin")
+			if tt.wantSuccess {
+				if status != DownloadSuccess {
+					t.Fatalf("Expected download to succeed, got status %v", status)
+				}
+				if data, err := os.ReadFile(localPath); err != nil || string(data) != tt.served {
+					t.Errorf("Expected served content on disk, got %q (%v)", data, err)
+				}
+				return
+			}
+			if status != DownloadError {
+				t.Fatalf("Expected verification failure, got status %v", status)
+			}
+			if _, err := os.Stat(localPath); !os.IsNotExist(err) {
+				t.Error("Expected file that failed verification to be removed")
+			}
+		})
+	}
+}
+
+func TestDownloadSkipChecksumDisablesChecksumVerification(t *testing.T) {
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+	server.AddAsset("test-repo", "/fw/image.bin", nexusapi.Asset{}, []byte("complete firmware image"))
+	server.SetAssetContent("/repository/test-repo/fw/image.bin", []byte("COMPLETE FIRMWARE IMAGE"))
+
+	config := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+	opts := &DownloadOptions{
+		Logger:       util.NewLogger(io.Discard),
+		QuietMode:    true,
+		Recursive:    true,
+		SkipChecksum: true,
+	}
+	if err := opts.SetChecksumAlgorithm("sha1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if status := downloadFolder("test-repo/fw", t.TempDir(), config, opts); status != DownloadSuccess {
+		t.Errorf("Expected --skip-checksum to download without checksum verification, got status %v", status)
+	}
+}
+
+func TestParseCompareMode(t *testing.T) {
+	for input, want := range map[string]CompareMode{"": "", "existence": CompareExistence, "Size": CompareSize, "checksum": CompareChecksum} {
+		if got, err := ParseCompareMode(input); err != nil || got != want {
+			t.Errorf("ParseCompareMode(%q) = %q, %v; want %q", input, got, err, want)
+		}
+	}
+	if _, err := ParseCompareMode("mtime"); err == nil {
+		t.Error("Expected error for unsupported compare mode")
+	}
+}
+
+func TestDownloadCompareModes(t *testing.T) {
+	const content = "complete firmware image"
+
+	tests := []struct {
+		name           string
+		compare        CompareMode
+		local          string
+		wantRedownload bool
+	}{
+		{"existence keeps truncated file", CompareExistence, content[:8], false},
+		{"size repairs truncated file", CompareSize, content[:8], true},
+		{"size keeps same-size file", CompareSize, "COMPLETE FIRMWARE IMAGE", false},
+		{"checksum repairs same-size file", CompareChecksum, "COMPLETE FIRMWARE IMAGE", true},
+		{"default with skip-checksum is existence", "", content[:8], false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := nexusapi.NewMockNexusServer()
+			defer server.Close()
+			server.AddAsset("test-repo", "/fw/image.bin", nexusapi.Asset{}, []byte(content))
+
+			destDir := t.TempDir()
+			localPath := filepath.Join(destDir, "fw", "image.bin")
+			if err := os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(localPath, []byte(tt.local), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			config := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+			opts := &DownloadOptions{
+				Logger:       util.NewLogger(io.Discard),
+				QuietMode:    true,
+				Recursive:    true,
+				SkipChecksum: tt.compare != CompareChecksum,
+				Compare:      tt.compare,
+			}
+			if err := opts.SetChecksumAlgorithm("sha1"); err != nil {
+				t.Fatal(err)
+			}
+
+			if status := downloadFolder("test-repo/fw", destDir, config, opts); status != DownloadSuccess {
+				t.Fatalf("Download failed with status %v", status)
+			}
+
+			data, err := os.ReadFile(localPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantRedownload && string(data) != content {
+				t.Errorf("Expected file to be downloaded again, got %q", data)
+			}
+			if !tt.wantRedownload && string(data) != tt.local {
+				t.Errorf("Expected local file to be kept, got %q", data)
+			}
+			if got := server.GetDownloadCount() > 0; got != tt.wantRedownload {
+				t.Errorf("Expected download request %v, got %v", tt.wantRedownload, got)
+			}
+		})
+	}
+}
+
+func TestDownloadVerificationFailureKeepsExistingFile(t *testing.T) {
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+	server.AddAsset("test-repo", "/fw/image.bin", nexusapi.Asset{}, []byte("complete firmware image"))
+	server.SetAssetContent("/repository/test-repo/fw/image.bin", []byte("COMPLETE FIRMWARE IMAGE"))
+
+	destDir := t.TempDir()
+	localPath := filepath.Join(destDir, "fw", "image.bin")
+	if err := os.MkdirAll(filepath.Dir(localPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(localPath, []byte("old image"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	config := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+	opts := &DownloadOptions{Logger: util.NewLogger(io.Discard), QuietMode: true, Recursive: true}
+	if err := opts.SetChecksumAlgorithm("sha256"); err != nil {
+		t.Fatal(err)
+	}
+
+	if status := downloadFolder("test-repo/fw", destDir, config, opts); status != DownloadError {
+		t.Fatalf("Expected verification failure, got status %v", status)
+	}
+	if data, err := os.ReadFile(localPath); err != nil || string(data) != "old image" {
+		t.Errorf("Expected existing file to be left untouched, got %q (%v)", data, err)
+	}
+	entries, err := os.ReadDir(filepath.Dir(localPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("Expected temporary download to be removed, found %d entries", len(entries))
+	}
+}
@@ -0,0 +1,50 @@
+package operations
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/tympanix/nexus-cli/internal/util"
+)
+
+// StatusPollInterval is the delay between two status checks while waiting for Nexus
+var StatusPollInterval = 10 * time.Second
+
+// waitForStatus polls check until it reports true or timeout expires, logging how long it
+// has waited. state names what is waited for, e.g. "writable". A check that fails, e.g.
+// because Nexus is restarting and refuses connections, counts as not yet in that state.
+func waitForStatus(check func() (bool, error), state string, timeout time.Duration, logger util.Logger) error {
+	start := time.Now()
+	deadline := start.Add(timeout)
+	for attempt := 0; ; attempt++ {
+		ok, err := check()
+		if err == nil && ok {
+			if attempt > 0 {
+				logger.Printf("Nexus is %s after waiting %s\n", state, time.Since(start).Round(time.Second))
+			}
+			return nil
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			if err != nil {
+				return fmt.Errorf("gave up waiting for Nexus to be %s after %s: %w", state, timeout, err)
+			}
+			return fmt.Errorf("gave up waiting for Nexus to be %s after %s", state, timeout)
+		}
+		if attempt == 0 {
+			logger.Printf("Nexus is not %s, waiting up to %s\n", state, timeout)
+		} else {
+			logger.Printf("Still waiting for Nexus to be %s (%s left)\n", state, remaining.Round(time.Second))
+		}
+		if err != nil {
+			logger.VerbosePrintf("Status check failed: %v\n", err)
+		}
+
+		delay := StatusPollInterval
+		if delay > remaining {
+			delay = remaining
+		}
+		time.Sleep(delay)
+	}
+}
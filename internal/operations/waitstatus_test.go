@@ -0,0 +1,74 @@
+package operations
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tympanix/nexus-cli/internal/config"
+	"github.com/tympanix/nexus-cli/internal/nexusapi"
+	"github.com/tympanix/nexus-cli/internal/util"
+)
+
+func setStatusPollInterval(t *testing.T, interval time.Duration) {
+	t.Helper()
+	previous := StatusPollInterval
+	StatusPollInterval = interval
+	t.Cleanup(func() { StatusPollInterval = previous })
+}
+
+// TestDownloadWaitForAvailable tests that a download waits for Nexus to become available
+func TestDownloadWaitForAvailable(t *testing.T) {
+	setStatusPollInterval(t, time.Millisecond)
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+	server.AddAsset("test-repo", "/folder/file.txt", nexusapi.Asset{}, []byte("content"))
+	server.Unavailable = 3
+
+	var logs bytes.Buffer
+	config := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+	opts := &DownloadOptions{
+		ChecksumAlgorithm: "sha1",
+		Logger:            util.NewLogger(&logs),
+		QuietMode:         true,
+		Recursive:         true,
+		WaitForAvailable:  time.Minute,
+	}
+
+	destDir := t.TempDir()
+	if status := Download("test-repo/folder", destDir, config, opts); status != DownloadSuccess {
+		t.Fatalf("Expected download to succeed, got %v", status)
+	}
+	if _, err := os.Stat(filepath.Join(destDir, "folder", "file.txt")); err != nil {
+		t.Errorf("Expected file to be downloaded: %v", err)
+	}
+	if server.Unavailable != 0 {
+		t.Errorf("Expected every unavailable status to be seen, %d left", server.Unavailable)
+	}
+	if !strings.Contains(logs.String(), "Nexus is not available, waiting up to 1m0s") || !strings.Contains(logs.String(), "Nexus is available after waiting") {
+		t.Errorf("Expected the wait to be logged, got %q", logs.String())
+	}
+}
+
+func TestWaitForStatusTimeout(t *testing.T) {
+	setStatusPollInterval(t, time.Millisecond)
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+	server.ReadOnly = 1000
+
+	client := nexusapi.NewClient(server.URL, "test", "test")
+	err := waitForStatus(client.StatusWritable, "writable", 20*time.Millisecond, util.NewLogger(io.Discard))
+	if err == nil || !strings.Contains(err.Error(), "gave up waiting for Nexus to be writable") {
+		t.Errorf("Expected the wait to time out, got %v", err)
+	}
+
+	// Without a wait the first check decides
+	server.ReadOnly = 0
+	if err := waitForStatus(client.StatusWritable, "writable", 0, util.NewLogger(io.Discard)); err != nil {
+		t.Errorf("Expected a writable server not to be waited for, got %v", err)
+	}
+}
@@ -0,0 +1,191 @@
+package operations
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDebounce is how long the source directory must be quiet before a change
+// burst (e.g. a build writing many files) triggers an upload
+var watchDebounce = 500 * time.Millisecond
+
+// watchAndUpload runs upload once and then again every time files under src change,
+// until ctx is cancelled. Upload errors are reported but do not stop watching.
+// With a non-zero interval the directory is polled instead of using filesystem
+// notifications, which do not fire on network filesystems such as NFS.
+func watchAndUpload(ctx context.Context, src string, upload func() error, opts *UploadOptions) error {
+	var events <-chan struct{}
+	if opts.WatchInterval > 0 {
+		events = pollChanges(ctx, src, opts.WatchInterval)
+	} else {
+		var err error
+		events, err = notifyChanges(ctx, src, opts)
+		if err != nil {
+			return err
+		}
+	}
+	changes := debounce(ctx, events, watchDebounce)
+
+	for iteration := 1; ; iteration++ {
+		start := time.Now()
+		if err := upload(); err != nil {
+			opts.Logger.Printf("[watch] upload #%d failed after %s: %v\n", iteration, time.Since(start).Round(time.Millisecond), err)
+		} else {
+			opts.Logger.Printf("[watch] upload #%d finished in %s\n", iteration, time.Since(start).Round(time.Millisecond))
+		}
+		opts.Logger.Printf("[watch] waiting for changes in %s (Ctrl-C to stop)\n", src)
+
+		select {
+		case <-ctx.Done():
+			opts.Logger.Println("[watch] stopped")
+			return nil
+		case <-changes:
+			opts.Logger.Printf("[watch] change detected, starting upload #%d\n", iteration+1)
+		}
+	}
+}
+
+// notifyChanges reports changes under src using filesystem notifications. Directories
+// created while watching are added so new subtrees are picked up as well.
+func notifyChanges(ctx context.Context, src string, opts *UploadOptions) (<-chan struct{}, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	if err := addWatchDirs(watcher, src); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+
+	events := make(chan struct{}, 1)
+	go func() {
+		defer watcher.Close()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if event.Has(fsnotify.Create) {
+					if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+						addWatchDirs(watcher, event.Name)
+					}
+				}
+				notify(events)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				opts.Logger.VerbosePrintf("[watch] watcher error: %v\n", err)
+			}
+		}
+	}()
+	return events, nil
+}
+
+// addWatchDirs adds root and all directories below it to the watcher
+func addWatchDirs(watcher *fsnotify.Watcher, root string) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return watcher.Add(path)
+		}
+		return nil
+	})
+}
+
+// fileState is the part of a file's metadata used to detect changes when polling
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+// pollChanges reports changes under src by comparing directory snapshots every interval
+func pollChanges(ctx context.Context, src string, interval time.Duration) <-chan struct{} {
+	events := make(chan struct{}, 1)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		previous := snapshotDir(src)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				current := snapshotDir(src)
+				if !sameSnapshot(previous, current) {
+					notify(events)
+				}
+				previous = current
+			}
+		}
+	}()
+	return events
+}
+
+// snapshotDir records the size and modification time of every file under root.
+// Files that disappear or cannot be read during the walk are left out.
+func snapshotDir(root string) map[string]fileState {
+	snapshot := make(map[string]fileState)
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			snapshot[path] = fileState{size: info.Size(), modTime: info.ModTime()}
+		}
+		return nil
+	})
+	return snapshot
+}
+
+func sameSnapshot(a, b map[string]fileState) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for path, state := range a {
+		other, ok := b[path]
+		if !ok || other.size != state.size || !other.modTime.Equal(state.modTime) {
+			return false
+		}
+	}
+	return true
+}
+
+// debounce forwards a single signal once no events have arrived on in for the quiet period
+func debounce(ctx context.Context, in <-chan struct{}, quiet time.Duration) <-chan struct{} {
+	out := make(chan struct{}, 1)
+	go func() {
+		timer := time.NewTimer(quiet)
+		timer.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-in:
+				timer.Reset(quiet)
+			case <-timer.C:
+				notify(out)
+			}
+		}
+	}()
+	return out
+}
+
+// notify sends a signal without blocking; a pending signal already covers the new change
+func notify(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
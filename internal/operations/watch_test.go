@@ -0,0 +1,167 @@
+package operations
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tympanix/nexus-cli/internal/config"
+	"github.com/tympanix/nexus-cli/internal/nexusapi"
+	"github.com/tympanix/nexus-cli/internal/util"
+)
+
+func TestDebounceCoalescesBursts(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	in := make(chan struct{})
+	out := debounce(ctx, in, 50*time.Millisecond)
+	for i := 0; i < 5; i++ {
+		in <- struct{}{}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	select {
+	case <-out:
+	case <-time.After(time.Second):
+		t.Fatal("Expected a debounced signal after the burst")
+	}
+	select {
+	case <-out:
+		t.Error("Expected a single signal for one burst")
+	case <-time.After(150 * time.Millisecond):
+	}
+}
+
+func TestSnapshotDirDetectsChanges(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "fw.bin")
+	if err := os.WriteFile(file, []byte("v1"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	before := snapshotDir(dir)
+	if !sameSnapshot(before, snapshotDir(dir)) {
+		t.Fatal("Expected unchanged directory to produce the same snapshot")
+	}
+
+	if err := os.WriteFile(file, []byte("v2 longer"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if sameSnapshot(before, snapshotDir(dir)) {
+		t.Error("Expected modified file to change the snapshot")
+	}
+}
+
+// runWatchTest runs watchAndUpload against the mock server, changes a file and checks it is uploaded again
+func runWatchTest(t *testing.T, interval time.Duration) {
+	t.Helper()
+	oldDebounce := watchDebounce
+	watchDebounce = 50 * time.Millisecond
+	defer func() { watchDebounce = oldDebounce }()
+
+	srcDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(srcDir, "fw.bin"), []byte("build 1"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	server := nexusapi.NewMockNexusServer()
+	defer server.Close()
+	config := &config.Config{NexusURL: server.URL, Username: "test", Password: "test"}
+
+	var logBuf safeBuffer
+	opts := &UploadOptions{
+		Logger:        util.NewLogger(&logBuf),
+		Watch:         true,
+		WatchInterval: interval,
+	}
+
+	var mu sync.Mutex
+	iterations := 0
+	uploaded := make(chan struct{}, 10)
+	upload := func() error {
+		mu.Lock()
+		iterations++
+		mu.Unlock()
+		err := uploadFiles(srcDir, "test-repo", "", config, opts)
+		uploaded <- struct{}{}
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- watchAndUpload(ctx, srcDir, upload, opts)
+	}()
+
+	waitFor := func(what string) {
+		select {
+		case <-uploaded:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for %s", what)
+		}
+	}
+	waitFor("initial upload")
+
+	// Make sure the modification time differs for the polling watcher
+	time.Sleep(20 * time.Millisecond)
+	if err := os.WriteFile(filepath.Join(srcDir, "fw.bin"), []byte("build 2"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	waitFor("upload after change")
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Expected clean exit, got: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Watch did not stop after cancellation")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if iterations != 2 {
+		t.Errorf("Expected 2 upload iterations, got %d", iterations)
+	}
+	files := server.GetUploadedFiles()
+	if len(files) != 2 || string(files[1].Content) != "build 2" {
+		t.Errorf("Expected the changed file to be uploaded again, got %d uploads", len(files))
+	}
+	output := logBuf.String()
+	for _, want := range []string{"[watch] upload #1 finished", "[watch] change detected, starting upload #2", "[watch] stopped"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected %q in output, got: %s", want, output)
+		}
+	}
+}
+
+func TestWatchAndUploadNotify(t *testing.T) {
+	runWatchTest(t, 0)
+}
+
+func TestWatchAndUploadPolling(t *testing.T) {
+	runWatchTest(t, 20*time.Millisecond)
+}
+
+// safeBuffer is a strings.Builder that can be written from several goroutines
+type safeBuffer struct {
+	mu  sync.Mutex
+	buf strings.Builder
+}
+
+func (b *safeBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *safeBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
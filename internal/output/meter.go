@@ -0,0 +1,52 @@
+package output
+
+import "time"
+
+// Meter counts the bytes of a transfer. A single meter is shared by everything that
+// reports on or acts on the progress of a transfer, so the progress bar, the rate limit
+// and the summary agree on the numbers. Implementations must be safe for concurrent use.
+type Meter interface {
+	// Add counts n bytes sent or received
+	Add(n int64)
+	// Skip counts n bytes that did not have to be transferred, e.g. of files that are up to date
+	Skip(n int64)
+	// Grow adds n bytes to the expected total
+	Grow(n int64)
+	// Reading returns the counts so far
+	Reading() MeterReading
+}
+
+// MeterReading is a point-in-time copy of the counts of a Meter
+type MeterReading struct {
+	TotalBytes       int64
+	TransferredBytes int64
+	SkippedBytes     int64
+	Elapsed          time.Duration
+	Rate             float64 // Bytes per second over the last few seconds
+}
+
+// Done returns the bytes that no longer have to be transferred
+func (r MeterReading) Done() int64 {
+	return r.TransferredBytes + r.SkippedBytes
+}
+
+// AverageRate returns the bytes transferred per second since the meter started
+func (r MeterReading) AverageRate() float64 {
+	if r.Elapsed <= 0 {
+		return 0
+	}
+	return float64(r.TransferredBytes) / r.Elapsed.Seconds()
+}
+
+// ETA estimates the time left at the current rate. It returns false when there is
+// nothing to estimate from, i.e. before any bytes were transferred.
+func (r MeterReading) ETA() (time.Duration, bool) {
+	remaining := r.TotalBytes - r.Done()
+	if remaining <= 0 {
+		return 0, true
+	}
+	if r.Rate <= 0 {
+		return 0, false
+	}
+	return time.Duration(float64(remaining) / r.Rate * float64(time.Second)), true
+}
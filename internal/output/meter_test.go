@@ -0,0 +1,38 @@
+package output
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMeterReadingETA(t *testing.T) {
+	tests := []struct {
+		name    string
+		reading MeterReading
+		eta     time.Duration
+		ok      bool
+	}{
+		{"remaining at rate", MeterReading{TotalBytes: 3000, TransferredBytes: 500, SkippedBytes: 500, Rate: 1000}, 2 * time.Second, true},
+		{"done", MeterReading{TotalBytes: 1000, SkippedBytes: 1000}, 0, true},
+		{"more than estimated", MeterReading{TotalBytes: 1000, TransferredBytes: 1500, Rate: 1000}, 0, true},
+		{"no rate yet", MeterReading{TotalBytes: 1000}, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			eta, ok := tt.reading.ETA()
+			if eta != tt.eta || ok != tt.ok {
+				t.Errorf("Expected ETA %s (%v), got %s (%v)", tt.eta, tt.ok, eta, ok)
+			}
+		})
+	}
+}
+
+func TestMeterReadingAverageRate(t *testing.T) {
+	reading := MeterReading{TransferredBytes: 4000, SkippedBytes: 1000, Elapsed: 2 * time.Second}
+	if rate := reading.AverageRate(); rate != 2000 {
+		t.Errorf("Expected skipped bytes not to count towards the average rate of 2000, got %f", rate)
+	}
+	if rate := (MeterReading{}).AverageRate(); rate != 0 {
+		t.Errorf("Expected no rate without elapsed time, got %f", rate)
+	}
+}
@@ -0,0 +1,136 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/tympanix/nexus-cli/internal/util"
+)
+
+// peakWindow is the sampling window used to measure peak throughput
+const peakWindow = 250 * time.Millisecond
+
+// TransferStats collects throughput statistics for a transfer. It is safe for
+// concurrent use by parallel workers.
+//
+// Logical bytes are the content of the files transferred, wire bytes are the bytes
+// sent or received over the network. They differ for compressed transfers, and for
+// downloads restored from the cache which transfer no bytes at all.
+type TransferStats struct {
+	mu           sync.Mutex
+	startTime    time.Time
+	logicalBytes int64
+	wireBytes    int64
+	hashTime     time.Duration
+	transferTime time.Duration
+	windowStart  time.Time
+	windowBytes  int64
+	peakRate     float64
+}
+
+// TransferStatsSnapshot is a point-in-time copy of TransferStats suitable for printing or JSON encoding.
+// Hash and transfer times are summed across parallel workers and can exceed the elapsed wall time.
+type TransferStatsSnapshot struct {
+	LogicalBytes int64         `json:"logical_bytes"`
+	WireBytes    int64         `json:"wire_bytes"`
+	Elapsed      time.Duration `json:"elapsed_ns"`
+	AverageRate  float64       `json:"average_bytes_per_second"`
+	PeakRate     float64       `json:"peak_bytes_per_second"`
+	HashTime     time.Duration `json:"hash_ns"`
+	TransferTime time.Duration `json:"transfer_ns"`
+}
+
+// NewTransferStats creates a stats collector whose elapsed time starts now
+func NewTransferStats() *TransferStats {
+	now := time.Now()
+	return &TransferStats{startTime: now, windowStart: now}
+}
+
+// AddLogicalBytes records n bytes of file content as transferred
+func (s *TransferStats) AddLogicalBytes(n int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.logicalBytes += n
+}
+
+// AddHashTime records time spent computing checksums
+func (s *TransferStats) AddHashTime(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hashTime += d
+}
+
+// AddTransferTime records time spent sending or receiving data
+func (s *TransferStats) AddTransferTime(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.transferTime += d
+}
+
+// addWireBytes records n bytes sent or received and updates the peak rate
+func (s *TransferStats) addWireBytes(n int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.wireBytes += n
+	s.windowBytes += n
+	now := time.Now()
+	if elapsed := now.Sub(s.windowStart); elapsed >= peakWindow {
+		if rate := float64(s.windowBytes) / elapsed.Seconds(); rate > s.peakRate {
+			s.peakRate = rate
+		}
+		s.windowStart = now
+		s.windowBytes = 0
+	}
+}
+
+// WireWriter returns a writer that counts every byte written to it as wire bytes.
+// It is meant to be combined with the actual destination using io.MultiWriter or io.TeeReader.
+func (s *TransferStats) WireWriter() io.Writer {
+	return wireWriter{stats: s}
+}
+
+type wireWriter struct {
+	stats *TransferStats
+}
+
+func (w wireWriter) Write(p []byte) (int, error) {
+	w.stats.addWireBytes(int64(len(p)))
+	return len(p), nil
+}
+
+// Snapshot returns the statistics collected so far
+func (s *TransferStats) Snapshot() TransferStatsSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snapshot := TransferStatsSnapshot{
+		LogicalBytes: s.logicalBytes,
+		WireBytes:    s.wireBytes,
+		Elapsed:      time.Since(s.startTime),
+		PeakRate:     s.peakRate,
+		HashTime:     s.hashTime,
+		TransferTime: s.transferTime,
+	}
+	if snapshot.Elapsed.Seconds() > 0 {
+		snapshot.AverageRate = float64(s.wireBytes) / snapshot.Elapsed.Seconds()
+	}
+	// Short transfers may not fill a sampling window; the peak is never below the average
+	if snapshot.PeakRate < snapshot.AverageRate {
+		snapshot.PeakRate = snapshot.AverageRate
+	}
+	return snapshot
+}
+
+// String formats the statistics as a single summary line
+func (s TransferStatsSnapshot) String() string {
+	return fmt.Sprintf("Transfer stats: content: %s, wire: %s, time: %s, avg: %s, peak: %s, hashing: %s, transferring: %s",
+		util.FormatByteSize(s.LogicalBytes), util.FormatByteSize(s.WireBytes), formatDuration(s.Elapsed),
+		formatRate(s.AverageRate), formatRate(s.PeakRate), formatDuration(s.HashTime), formatDuration(s.TransferTime))
+}
+
+// formatRate formats a byte rate in MB/s
+func formatRate(bytesPerSecond float64) string {
+	return fmt.Sprintf("%.2f MB/s", bytesPerSecond/1e6)
+}
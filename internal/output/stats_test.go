@@ -0,0 +1,109 @@
+package output
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tympanix/nexus-cli/internal/util"
+)
+
+func TestTransferStatsConcurrent(t *testing.T) {
+	stats := NewTransferStats()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				io.WriteString(stats.WireWriter(), strings.Repeat("x", 10))
+				stats.AddLogicalBytes(20)
+				stats.AddHashTime(time.Millisecond)
+				stats.AddTransferTime(2 * time.Millisecond)
+			}
+		}()
+	}
+	wg.Wait()
+
+	snapshot := stats.Snapshot()
+	if snapshot.WireBytes != 8000 {
+		t.Errorf("Expected 8000 wire bytes, got %d", snapshot.WireBytes)
+	}
+	if snapshot.LogicalBytes != 16000 {
+		t.Errorf("Expected 16000 logical bytes, got %d", snapshot.LogicalBytes)
+	}
+	if snapshot.HashTime != 800*time.Millisecond {
+		t.Errorf("Expected 800ms hash time, got %s", snapshot.HashTime)
+	}
+	if snapshot.TransferTime != 1600*time.Millisecond {
+		t.Errorf("Expected 1.6s transfer time, got %s", snapshot.TransferTime)
+	}
+	if snapshot.AverageRate <= 0 || snapshot.PeakRate < snapshot.AverageRate {
+		t.Errorf("Expected peak rate >= average rate > 0, got avg=%f peak=%f", snapshot.AverageRate, snapshot.PeakRate)
+	}
+}
+
+func TestTransferStatsPeakRate(t *testing.T) {
+	stats := NewTransferStats()
+	// A burst followed by an idle period: the peak must exceed the overall average
+	io.WriteString(stats.WireWriter(), strings.Repeat("x", 1000))
+	time.Sleep(peakWindow)
+	io.WriteString(stats.WireWriter(), "x")
+	time.Sleep(2 * peakWindow)
+
+	snapshot := stats.Snapshot()
+	if snapshot.PeakRate <= snapshot.AverageRate {
+		t.Errorf("Expected peak rate above average, got avg=%f peak=%f", snapshot.AverageRate, snapshot.PeakRate)
+	}
+}
+
+func TestTransferStatsSnapshotOutput(t *testing.T) {
+	snapshot := TransferStatsSnapshot{
+		LogicalBytes: 4096,
+		WireBytes:    1024,
+		Elapsed:      2 * time.Second,
+		AverageRate:  512,
+		PeakRate:     2_000_000,
+		HashTime:     500 * time.Millisecond,
+		TransferTime: 1500 * time.Millisecond,
+	}
+
+	line := snapshot.String()
+	for _, want := range []string{"content: 4.0 KiB", "wire: 1.0 KiB", "peak: 2.00 MB/s", "hashing: 500ms", "transferring: 1.5s"} {
+		if !strings.Contains(line, want) {
+			t.Errorf("Expected %q in stats line, got: %s", want, line)
+		}
+	}
+
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]any
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded["logical_bytes"] != float64(4096) || decoded["wire_bytes"] != float64(1024) {
+		t.Errorf("Unexpected JSON encoding: %s", data)
+	}
+}
+
+func TestTransferTrackerStats(t *testing.T) {
+	var buf strings.Builder
+	tracker := NewTransferTracker(TransferTypeDownload, "test-repo", util.NewLogger(&buf), false, false, false)
+
+	tracker.RecordFile(FileTransfer{Path: "a.txt", Size: 100, Status: TransferStatusSuccess})
+	tracker.RecordFile(FileTransfer{Path: "b.txt", Size: 50, Status: TransferStatusSkipped})
+	tracker.PrintSummary()
+
+	if got := tracker.Stats().Snapshot().LogicalBytes; got != 100 {
+		t.Errorf("Expected only successful files to count as logical bytes, got %d", got)
+	}
+	if !strings.Contains(buf.String(), "Transfer stats: content: 100 B") {
+		t.Errorf("Expected stats line in summary, got: %s", buf.String())
+	}
+}
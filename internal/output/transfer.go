@@ -3,9 +3,12 @@ package output
 import (
 	"fmt"
 	"io"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/tympanix/nexus-cli/internal/metrics"
 	"github.com/tympanix/nexus-cli/internal/util"
 )
 
@@ -19,9 +22,10 @@ const (
 type TransferStatus string
 
 const (
-	TransferStatusSuccess TransferStatus = "success"
-	TransferStatusSkipped TransferStatus = "skipped"
-	TransferStatusFailed  TransferStatus = "failed"
+	TransferStatusSuccess    TransferStatus = "success"
+	TransferStatusSkipped    TransferStatus = "skipped"
+	TransferStatusFailed     TransferStatus = "failed"
+	TransferStatusUnreadable TransferStatus = "unreadable"
 )
 
 type FileTransfer struct {
@@ -32,6 +36,46 @@ type FileTransfer struct {
 	StartTime  time.Time
 	EndTime    time.Time
 	BytesCount int64
+	Reason     string // Why a skipped file was skipped, e.g. "SHA1 match"
+}
+
+// FilterCounts counts the files a transfer left out before comparing them with the
+// destination, by the filter that excluded them. Files skipped because they are up to
+// date are not counted here.
+type FilterCounts struct {
+	Glob      int `json:"glob"`      // Excluded by the glob pattern, including negated patterns
+	Extension int `json:"extension"` // Excluded by --allow-ext or --deny-ext
+	Depth     int `json:"depth"`     // Deeper below the source than --depth
+	Limit     int `json:"limit"`     // Passed the other filters but beyond --limit
+}
+
+// Total returns the number of files excluded by any filter
+func (c FilterCounts) Total() int {
+	return c.Glob + c.Extension + c.Depth + c.Limit
+}
+
+// Add returns the sum of both counts
+func (c FilterCounts) Add(other FilterCounts) FilterCounts {
+	return FilterCounts{
+		Glob:      c.Glob + other.Glob,
+		Extension: c.Extension + other.Extension,
+		Depth:     c.Depth + other.Depth,
+		Limit:     c.Limit + other.Limit,
+	}
+}
+
+// String returns the non-zero counts, e.g. "glob: 4890, limit: 10"
+func (c FilterCounts) String() string {
+	var parts []string
+	for _, count := range []struct {
+		name string
+		n    int
+	}{{"glob", c.Glob}, {"extension", c.Extension}, {"depth", c.Depth}, {"limit", c.Limit}} {
+		if count.n > 0 {
+			parts = append(parts, fmt.Sprintf("%s: %d", count.name, count.n))
+		}
+	}
+	return strings.Join(parts, ", ")
 }
 
 type TransferTracker struct {
@@ -40,11 +84,56 @@ type TransferTracker struct {
 	startTime    time.Time
 	endTime      time.Time
 	files        []FileTransfer
+	filtered     FilterCounts
 	mu           sync.Mutex
 	logger       util.Logger
 	quietMode    bool
 	verboseMode  bool
 	showProgress bool
+	stats        *TransferStats
+	meter        Meter
+	skips        skipRun
+}
+
+// SkipLogBatch is the number of consecutive skipped files that verbose mode reports in
+// one line. Every skipped file is listed only with -vv.
+var SkipLogBatch = 1000
+
+// skipRun counts consecutive skipped files that are not reported yet, by reason
+type skipRun struct {
+	count   int
+	reasons map[string]int
+	order   []string // Reasons in the order they first occurred
+}
+
+func (r *skipRun) add(reason string) {
+	if reason == "" {
+		reason = "up to date"
+	}
+	if r.reasons == nil {
+		r.reasons = make(map[string]int)
+	}
+	if _, ok := r.reasons[reason]; !ok {
+		r.order = append(r.order, reason)
+	}
+	r.reasons[reason]++
+	r.count++
+}
+
+// String returns the run as a log line, e.g. "- skipped 1,000 files (SHA1 match: 990, local file differs: 10)"
+func (r *skipRun) String() string {
+	noun := "files"
+	if r.count == 1 {
+		noun = "file"
+	}
+	if len(r.order) == 1 {
+		return fmt.Sprintf("- skipped %s %s (%s)", formatCount(int64(r.count)), noun, r.order[0])
+	}
+	parts := make([]string, len(r.order))
+	for i, reason := range r.order {
+		parts[i] = fmt.Sprintf("%s: %s", reason, formatCount(int64(r.reasons[reason])))
+	}
+	return fmt.Sprintf("- skipped %s %s (%s)", formatCount(int64(r.count)), noun, strings.Join(parts, ", "))
 }
 
 func NewTransferTracker(transferType TransferType, target string, logger util.Logger, quietMode, verboseMode, showProgress bool) *TransferTracker {
@@ -57,9 +146,25 @@ func NewTransferTracker(transferType TransferType, target string, logger util.Lo
 		quietMode:    quietMode,
 		verboseMode:  verboseMode,
 		showProgress: showProgress,
+		stats:        NewTransferStats(),
 	}
 }
 
+// Stats returns the throughput statistics collector for this transfer
+func (t *TransferTracker) Stats() *TransferStats {
+	return t.stats
+}
+
+// SetMeter makes the summary report the throughput counted by meter, the meter the
+// progress bar and rate limit of the transfer read from
+func (t *TransferTracker) SetMeter(meter Meter) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.meter = meter
+}
+
+// PrintHeader prints the target of the transfer and, in verbose mode, its totals. A
+// negative totalFiles means the totals are not known yet.
 func (t *TransferTracker) PrintHeader(totalFiles int, totalSize int64) {
 	if t.quietMode {
 		return
@@ -69,20 +174,57 @@ func (t *TransferTracker) PrintHeader(totalFiles int, totalSize int64) {
 		action = "Downloading from"
 	}
 	t.logger.Printf("%s %s\n", action, t.target)
-	if t.verboseMode {
-		t.logger.Printf("Total files: %d, Total size: %s\n", totalFiles, formatBytes(totalSize))
+	if t.verboseMode && totalFiles >= 0 {
+		t.logger.Printf("Total files: %d, Total size: %s\n", totalFiles, util.FormatByteSize(totalSize))
 	}
 }
 
+// AddFiltered records files the filters excluded from the transfer, which the summary
+// reports separately from the files skipped as up to date
+func (t *TransferTracker) AddFiltered(counts FilterCounts) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.filtered = t.filtered.Add(counts)
+}
+
 func (t *TransferTracker) RecordFile(file FileTransfer) {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 	t.files = append(t.files, file)
+	if file.Status == TransferStatusSuccess {
+		t.stats.AddLogicalBytes(file.Size)
+	}
+	recordFileMetrics(file)
 
 	if t.quietMode {
 		return
 	}
 
+	// Skipped files are reported in runs at -v, so they do not bury the files that changed
+	if file.Status == TransferStatusSkipped {
+		switch {
+		case t.logger.Verbosity() > 1:
+			reason := file.Reason
+			if reason == "" {
+				reason = "up to date"
+			}
+			t.logger.Printf("- %s (skipped: %s)\n", file.Path, reason)
+		case t.verboseMode:
+			t.skips.add(file.Reason)
+			if t.skips.count >= SkipLogBatch {
+				t.flushSkipsLocked()
+			}
+		}
+		return
+	}
+	t.flushSkipsLocked()
+
+	// Unreadable files are always reported since they are silently missing from the transfer otherwise
+	if file.Status == TransferStatusUnreadable {
+		t.logger.Printf("Warning: skipping unreadable file %s: %v\n", file.Path, file.Error)
+		return
+	}
+
 	if !t.showProgress {
 		status := ""
 		switch file.Status {
@@ -90,12 +232,10 @@ func (t *TransferTracker) RecordFile(file FileTransfer) {
 			elapsed := file.EndTime.Sub(file.StartTime)
 			if elapsed > 0 {
 				speed := float64(file.Size) / elapsed.Seconds()
-				status = fmt.Sprintf("✓ %s (%s, %s/s)", file.Path, formatBytes(file.Size), formatBytes(int64(speed)))
+				status = fmt.Sprintf("✓ %s (%s, %s/s)", file.Path, util.FormatByteSize(file.Size), util.FormatByteSize(int64(speed)))
 			} else {
-				status = fmt.Sprintf("✓ %s (%s)", file.Path, formatBytes(file.Size))
+				status = fmt.Sprintf("✓ %s (%s)", file.Path, util.FormatByteSize(file.Size))
 			}
-		case TransferStatusSkipped:
-			status = fmt.Sprintf("- %s (skipped)", file.Path)
 		case TransferStatusFailed:
 			status = fmt.Sprintf("✗ %s (failed: %v)", file.Path, file.Error)
 		}
@@ -105,13 +245,44 @@ func (t *TransferTracker) RecordFile(file FileTransfer) {
 	}
 }
 
+// FlushSkipped reports the skipped files that verbose mode has not reported yet, e.g.
+// before logging deletions after the transfer. PrintSummary does so itself.
+func (t *TransferTracker) FlushSkipped() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.flushSkipsLocked()
+}
+
+func (t *TransferTracker) flushSkipsLocked() {
+	if t.skips.count == 0 {
+		return
+	}
+	t.logger.Println(t.skips.String())
+	t.skips = skipRun{}
+}
+
+// recordFileMetrics counts the outcome and size of a file in the metrics of the run
+func recordFileMetrics(file FileTransfer) {
+	registry := metrics.Active()
+	switch file.Status {
+	case TransferStatusSuccess:
+		registry.Add(metrics.Files, 1, metrics.OutcomeSucceeded)
+		registry.Add(metrics.TransferredBytes, float64(file.Size))
+	case TransferStatusSkipped:
+		registry.Add(metrics.Files, 1, metrics.OutcomeSkipped)
+	default:
+		registry.Add(metrics.Files, 1, metrics.OutcomeFailed)
+	}
+}
+
 func (t *TransferTracker) PrintSummary() {
 	t.endTime = time.Now()
 
 	t.mu.Lock()
 	defer t.mu.Unlock()
+	t.flushSkipsLocked()
 
-	var successful, skipped, failed int
+	var successful, skipped, failed, unreadable int
 	var totalBytes int64
 
 	for _, file := range t.files {
@@ -123,12 +294,16 @@ func (t *TransferTracker) PrintSummary() {
 			skipped++
 		case TransferStatusFailed:
 			failed++
+		case TransferStatusUnreadable:
+			unreadable++
 		}
 	}
 
 	elapsed := t.endTime.Sub(t.startTime)
 	avgSpeed := float64(0)
-	if elapsed.Seconds() > 0 {
+	if t.meter != nil {
+		avgSpeed = t.meter.Reading().AverageRate()
+	} else if elapsed.Seconds() > 0 {
 		avgSpeed = float64(totalBytes) / elapsed.Seconds()
 	}
 
@@ -144,26 +319,33 @@ func (t *TransferTracker) PrintSummary() {
 	if failed > 0 {
 		summary += fmt.Sprintf(", failed: %d", failed)
 	}
-	summary += fmt.Sprintf(", size: %s", formatBytes(totalBytes))
+	if unreadable > 0 {
+		summary += fmt.Sprintf(", unreadable: %d", unreadable)
+	}
+	summary += fmt.Sprintf(", size: %s", util.FormatByteSize(totalBytes))
 	summary += fmt.Sprintf(", time: %s", formatDuration(elapsed))
 	if avgSpeed > 0 {
-		summary += fmt.Sprintf(", speed: %s/s", formatBytes(int64(avgSpeed)))
+		summary += fmt.Sprintf(", speed: %s/s", util.FormatByteSize(int64(avgSpeed)))
 	}
 
 	t.logger.Println(summary)
+	if total := t.filtered.Total(); total > 0 {
+		t.logger.Printf("Files excluded by filters: %d (%s)\n", total, t.filtered)
+	}
+	t.logger.Println(t.stats.Snapshot().String())
 }
 
-func formatBytes(bytes int64) string {
-	const unit = 1024
-	if bytes < unit {
-		return fmt.Sprintf("%d B", bytes)
+// formatCount formats a count with thousands separators, e.g. "50,000"
+func formatCount(n int64) string {
+	s := strconv.FormatInt(n, 10)
+	start := 0
+	if n < 0 {
+		start = 1
 	}
-	div, exp := int64(unit), 0
-	for n := bytes / unit; n >= unit; n /= unit {
-		div *= unit
-		exp++
+	for i := len(s) - 3; i > start; i -= 3 {
+		s = s[:i] + "," + s[i:]
 	}
-	return fmt.Sprintf("%.1f %ciB", float64(bytes)/float64(div), "KMGTPE"[exp])
+	return s
 }
 
 func formatDuration(d time.Duration) string {
@@ -3,6 +3,7 @@ package output
 import (
 	"bytes"
 	"errors"
+	"fmt"
 	"strings"
 	"testing"
 	"time"
@@ -10,31 +11,6 @@ import (
 	"github.com/tympanix/nexus-cli/internal/util"
 )
 
-func TestFormatBytes(t *testing.T) {
-	tests := []struct {
-		name     string
-		bytes    int64
-		expected string
-	}{
-		{"zero", 0, "0 B"},
-		{"bytes", 512, "512 B"},
-		{"1 KiB", 1024, "1.0 KiB"},
-		{"1.5 KiB", 1536, "1.5 KiB"},
-		{"1 MiB", 1048576, "1.0 MiB"},
-		{"2.5 MiB", 2621440, "2.5 MiB"},
-		{"1 GiB", 1073741824, "1.0 GiB"},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			result := formatBytes(tt.bytes)
-			if result != tt.expected {
-				t.Errorf("formatBytes(%d) = %s, want %s", tt.bytes, result, tt.expected)
-			}
-		})
-	}
-}
-
 func TestFormatDuration(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -107,6 +83,31 @@ func TestTransferTracker(t *testing.T) {
 	}
 }
 
+func TestTransferTrackerUnreadable(t *testing.T) {
+	var buf bytes.Buffer
+	logger := util.NewLogger(&buf)
+
+	tracker := NewTransferTracker(TransferTypeUpload, "test-repo", logger, false, false, false)
+
+	tracker.RecordFile(FileTransfer{
+		Path:   "core.dump",
+		Status: TransferStatusUnreadable,
+		Error:  errors.New("permission denied"),
+	})
+
+	tracker.PrintSummary()
+
+	output := buf.String()
+
+	if !strings.Contains(output, "Warning: skipping unreadable file core.dump: permission denied") {
+		t.Errorf("Expected warning for unreadable file, got: %s", output)
+	}
+
+	if !strings.Contains(output, "unreadable: 1") {
+		t.Errorf("Expected 'unreadable: 1' in summary, got: %s", output)
+	}
+}
+
 func TestTransferTrackerQuietMode(t *testing.T) {
 	var buf bytes.Buffer
 	logger := util.NewLogger(&buf)
@@ -147,6 +148,54 @@ func TestTransferTrackerVerboseMode(t *testing.T) {
 	}
 }
 
+func TestTransferTrackerSkippedRuns(t *testing.T) {
+	previous := SkipLogBatch
+	SkipLogBatch = 3
+	t.Cleanup(func() { SkipLogBatch = previous })
+
+	var buf bytes.Buffer
+	tracker := NewTransferTracker(TransferTypeDownload, "test-repo", util.NewVerboseLogger(&buf), false, true, false)
+	for i, reason := range []string{"SHA1 match", "SHA1 match", "local file differs", "SHA1 match"} {
+		tracker.RecordFile(FileTransfer{Path: fmt.Sprintf("file%d.txt", i), Status: TransferStatusSkipped, Reason: reason})
+	}
+	tracker.RecordFile(FileTransfer{Path: "new.txt", Size: 10, Status: TransferStatusSuccess})
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	expected := []string{
+		"- skipped 3 files (SHA1 match: 2, local file differs: 1)",
+		"- skipped 1 file (SHA1 match)",
+		"✓ new.txt (10 B)",
+	}
+	if strings.Join(lines, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected skipped files to be collapsed, got:\n%s", buf.String())
+	}
+	if strings.Contains(buf.String(), "file0.txt") {
+		t.Errorf("Expected no per-file skip lines at -v, got:\n%s", buf.String())
+	}
+}
+
+func TestTransferTrackerSkippedPerFile(t *testing.T) {
+	var buf bytes.Buffer
+	tracker := NewTransferTracker(TransferTypeUpload, "test-repo", util.NewLoggerWithVerbosity(&buf, 2), false, true, false)
+	tracker.RecordFile(FileTransfer{Path: "a.txt", Status: TransferStatusSkipped, Reason: "size match"})
+	tracker.RecordFile(FileTransfer{Path: "b.txt", Status: TransferStatusSkipped})
+	tracker.FlushSkipped()
+
+	expected := "- a.txt (skipped: size match)\n- b.txt (skipped: up to date)\n"
+	if buf.String() != expected {
+		t.Errorf("Expected every skipped file at -vv, got %q", buf.String())
+	}
+}
+
+func TestFormatCount(t *testing.T) {
+	tests := map[int64]string{0: "0", 999: "999", 1000: "1,000", 50000: "50,000", 1234567: "1,234,567", -1000: "-1,000"}
+	for n, expected := range tests {
+		if got := formatCount(n); got != expected {
+			t.Errorf("formatCount(%d) = %q, expected %q", n, got, expected)
+		}
+	}
+}
+
 func TestProgressWriter(t *testing.T) {
 	var buf bytes.Buffer
 	pw := NewProgressWriter(&buf)
@@ -169,3 +218,27 @@ func TestProgressWriter(t *testing.T) {
 		t.Errorf("Expected buffer to contain %q, got %q", data, buf.String())
 	}
 }
+
+func TestTransferTrackerFilteredCounts(t *testing.T) {
+	var buf bytes.Buffer
+	tracker := NewTransferTracker(TransferTypeDownload, "repo/path", util.NewLogger(&buf), true, false, false)
+	tracker.RecordFile(FileTransfer{Path: "a.txt", Size: 1, Status: TransferStatusSuccess})
+	tracker.RecordFile(FileTransfer{Path: "b.txt", Size: 1, Status: TransferStatusSkipped})
+	tracker.AddFiltered(FilterCounts{Glob: 4890})
+	tracker.AddFiltered(FilterCounts{Glob: 10, Limit: 10})
+	tracker.PrintSummary()
+
+	if !strings.Contains(buf.String(), "Files downloaded: 1, skipped: 1,") {
+		t.Errorf("Expected the checksum skip in the summary, got: %s", buf.String())
+	}
+	if !strings.Contains(buf.String(), "Files excluded by filters: 4910 (glob: 4900, limit: 10)\n") {
+		t.Errorf("Expected the filtered counts in the summary, got: %s", buf.String())
+	}
+
+	buf.Reset()
+	tracker = NewTransferTracker(TransferTypeUpload, "repo/path", util.NewLogger(&buf), true, false, false)
+	tracker.PrintSummary()
+	if strings.Contains(buf.String(), "excluded by filters") {
+		t.Errorf("Expected no filtered counts without filters, got: %s", buf.String())
+	}
+}
@@ -0,0 +1,137 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/tympanix/nexus-cli/internal/util"
+)
+
+// TreeEntry is a file to place in a tree, identified by its slash separated path
+type TreeEntry struct {
+	Path string
+	Size int64
+}
+
+// TreeNode is a file or directory in a tree built from a set of paths.
+// For directories, Size and Files summarize everything below the directory.
+type TreeNode struct {
+	Name     string
+	IsDir    bool
+	Size     int64
+	Files    int
+	Children []*TreeNode // Sorted by name
+}
+
+// BuildTree builds a prefix tree from a set of slash separated paths. The returned root
+// is an unnamed directory containing every entry.
+func BuildTree(entries []TreeEntry) *TreeNode {
+	root := &TreeNode{IsDir: true}
+	for _, entry := range entries {
+		segments := strings.Split(strings.Trim(entry.Path, "/"), "/")
+		if len(segments) == 1 && segments[0] == "" {
+			continue
+		}
+
+		node := root
+		for i, segment := range segments {
+			node.Size += entry.Size
+			node.Files++
+			isDir := i < len(segments)-1
+			child := node.child(segment, isDir)
+			if child == nil {
+				child = &TreeNode{Name: segment, IsDir: isDir}
+				node.Children = append(node.Children, child)
+			}
+			node = child
+		}
+		node.Size = entry.Size
+	}
+	root.sort()
+	return root
+}
+
+// child returns the child with the given name and kind, or nil
+func (n *TreeNode) child(name string, isDir bool) *TreeNode {
+	for _, c := range n.Children {
+		if c.Name == name && c.IsDir == isDir {
+			return c
+		}
+	}
+	return nil
+}
+
+func (n *TreeNode) sort() {
+	sort.Slice(n.Children, func(i, j int) bool {
+		return n.Children[i].Name < n.Children[j].Name
+	})
+	for _, c := range n.Children {
+		c.sort()
+	}
+}
+
+// treeConnectors holds the strings used to draw the branches of a tree
+type treeConnectors struct {
+	branch, last, pipe, space string
+}
+
+var (
+	unicodeConnectors = treeConnectors{branch: "├── ", last: "└── ", pipe: "│   ", space: "    "}
+	asciiConnectors   = treeConnectors{branch: "|-- ", last: "`-- ", pipe: "|   ", space: "    "}
+)
+
+// RenderTree writes the tree below root in the style of tree(1), headed by title.
+// Directories are annotated with the number and total size of the files below them.
+// With depth > 0, only that many levels are shown; deeper directories are summarized.
+// ASCII connectors are used instead of box-drawing characters when ascii is set.
+func RenderTree(w io.Writer, title string, root *TreeNode, depth int, ascii bool) {
+	connectors := unicodeConnectors
+	if ascii {
+		connectors = asciiConnectors
+	}
+
+	fmt.Fprintf(w, "%s %s\n", title, root.summary())
+	dirs, files := renderTreeChildren(w, root, "", 1, depth, connectors)
+	fmt.Fprintf(w, "\n%d %s, %d %s\n", dirs, plural(dirs, "directory", "directories"), files, plural(files, "file", "files"))
+}
+
+// renderTreeChildren renders the children of node and returns how many directories and files it printed
+func renderTreeChildren(w io.Writer, node *TreeNode, prefix string, level, depth int, c treeConnectors) (int, int) {
+	dirs, files := 0, 0
+	for i, child := range node.Children {
+		connector, indent := c.branch, c.pipe
+		if i == len(node.Children)-1 {
+			connector, indent = c.last, c.space
+		}
+
+		if !child.IsDir {
+			fmt.Fprintf(w, "%s%s%s (%s)\n", prefix, connector, child.Name, util.FormatByteSize(child.Size))
+			files++
+			continue
+		}
+
+		fmt.Fprintf(w, "%s%s%s/ %s\n", prefix, connector, child.Name, child.summary())
+		dirs++
+		if depth > 0 && level >= depth {
+			continue
+		}
+		subDirs, subFiles := renderTreeChildren(w, child, prefix+indent, level+1, depth, c)
+		dirs += subDirs
+		files += subFiles
+	}
+	return dirs, files
+}
+
+// summary describes the files below a directory
+func (n *TreeNode) summary() string {
+	return fmt.Sprintf("(%d %s, %s)", n.Files, plural(n.Files, "file", "files"), util.FormatByteSize(n.Size))
+}
+
+func plural(n int, singular, pluralForm string) string {
+	if n == 1 {
+		return singular
+	}
+	return pluralForm
+}
@@ -0,0 +1,79 @@
+package output
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuildTree(t *testing.T) {
+	root := BuildTree([]TreeEntry{
+		{Path: "docs/guide.md", Size: 100},
+		{Path: "/bin/app", Size: 2048},
+		{Path: "docs/api/index.html", Size: 50},
+		{Path: "README.md", Size: 10},
+	})
+
+	if root.Files != 4 || root.Size != 2208 {
+		t.Errorf("Expected root summary of 4 files and 2208 bytes, got %d files and %d bytes", root.Files, root.Size)
+	}
+
+	var names []string
+	for _, child := range root.Children {
+		names = append(names, child.Name)
+	}
+	if strings.Join(names, ",") != "README.md,bin,docs" {
+		t.Errorf("Expected sorted children README.md,bin,docs, got %v", names)
+	}
+
+	docs := root.Children[2]
+	if !docs.IsDir || docs.Files != 2 || docs.Size != 150 {
+		t.Errorf("Expected docs to summarize 2 files and 150 bytes, got %+v", docs)
+	}
+	if api := docs.Children[0]; !api.IsDir || api.Name != "api" || api.Files != 1 || api.Children[0].Name != "index.html" {
+		t.Errorf("Expected nested api directory, got %+v", api)
+	}
+	if readme := root.Children[0]; readme.IsDir || readme.Size != 10 {
+		t.Errorf("Expected README.md file node of 10 bytes, got %+v", readme)
+	}
+}
+
+func TestRenderTree(t *testing.T) {
+	root := BuildTree([]TreeEntry{
+		{Path: "docs/guide.md", Size: 100},
+		{Path: "docs/api/index.html", Size: 50},
+		{Path: "fw.bin", Size: 2048},
+	})
+
+	var sb strings.Builder
+	RenderTree(&sb, "repo/path", root, 0, false)
+	expected := `repo/path (3 files, 2.1 KiB)
+├── docs/ (2 files, 150 B)
+│   ├── api/ (1 file, 50 B)
+│   │   └── index.html (50 B)
+│   └── guide.md (100 B)
+└── fw.bin (2.0 KiB)
+
+2 directories, 3 files
+`
+	if sb.String() != expected {
+		t.Errorf("Unexpected tree:\n%s\nexpected:\n%s", sb.String(), expected)
+	}
+}
+
+func TestRenderTreeDepthAndASCII(t *testing.T) {
+	root := BuildTree([]TreeEntry{
+		{Path: "docs/guide.md", Size: 100},
+		{Path: "docs/api/index.html", Size: 50},
+		{Path: "fw.bin", Size: 2048},
+	})
+
+	var sb strings.Builder
+	RenderTree(&sb, "repo", root, 1, true)
+	expected := "repo (3 files, 2.1 KiB)\n" +
+		"|-- docs/ (2 files, 150 B)\n" +
+		"`-- fw.bin (2.0 KiB)\n" +
+		"\n1 directory, 1 file\n"
+	if sb.String() != expected {
+		t.Errorf("Unexpected tree:\n%s\nexpected:\n%s", sb.String(), expected)
+	}
+}
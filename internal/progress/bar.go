@@ -1,120 +1,74 @@
 package progress
 
 import (
-	"fmt"
 	"io"
-	"sync"
-	"sync/atomic"
+	"os"
 
 	"github.com/k0kubun/go-ansi"
-	"github.com/schollz/progressbar/v3"
+	"github.com/tympanix/nexus-cli/internal/output"
+	"github.com/tympanix/nexus-cli/internal/util"
 )
 
-// ProgressBar wraps a progress bar to track whether progress should be shown
-type ProgressBar struct {
-	bar          *progressbar.ProgressBar
-	showProgress bool
-}
-
-// Write implements io.Writer
-func (p *ProgressBar) Write(b []byte) (int, error) {
-	return p.bar.Write(b)
+// ProgressBarWithCount tracks the bytes and files of a transfer on a Renderer. The bytes
+// are counted on the meter of the transfer. Parallel transfers update it from several goroutines.
+type ProgressBarWithCount struct {
+	renderer *Renderer
 }
 
-// Add64 adds to the progress bar
-func (p *ProgressBar) Add64(n int64) error {
-	return p.bar.Add64(n)
+// Write reports progress. It never fails, because progress reporting must never fail
+// the transfer it tracks.
+func (p *ProgressBarWithCount) Write(b []byte) (int, error) {
+	return p.renderer.Write(b)
 }
 
-// Describe sets the description of the progress bar
-func (p *ProgressBar) Describe(description string) {
-	p.bar.Describe(description)
+// Skip counts n bytes that did not have to be transferred, e.g. of a file that is up to date
+func (p *ProgressBarWithCount) Skip(n int64) {
+	p.renderer.Skip(n)
 }
 
-// Finish completes the progress bar and prints a newline if progress is shown
-func (p *ProgressBar) Finish() error {
-	err := p.bar.Finish()
-	if p.showProgress {
-		fmt.Println()
-	}
-	return err
+// Grow adds bytes and files to the totals of the bar
+func (p *ProgressBarWithCount) Grow(bytes int64, files int) {
+	p.renderer.Grow(bytes, files)
 }
 
-// NewProgressBar creates a new progress bar with standard configuration
-// The description parameter should describe the operation (e.g., "Uploading", "Downloading")
-// The currentFile and totalFiles parameters track which file is being processed
-// The showProgress parameter controls whether progress should be shown (typically util.IsATTY() && !quietMode)
-func NewProgressBar(totalBytes int64, description string, currentFile, totalFiles int, showProgress bool) *ProgressBar {
-	var writer io.Writer = ansi.NewAnsiStdout()
-	if !showProgress {
-		writer = io.Discard
-	}
-
-	descWithCount := fmt.Sprintf("[cyan][%d/%d][reset] %s", currentFile, totalFiles, description)
-
-	bar := progressbar.NewOptions64(totalBytes,
-		progressbar.OptionSetWriter(writer),
-		progressbar.OptionEnableColorCodes(true),
-		progressbar.OptionShowBytes(true),
-		progressbar.OptionFullWidth(),
-		progressbar.OptionSetDescription(descWithCount),
-		progressbar.OptionSetTheme(progressbar.Theme{
-			Saucer:        "[green]=[reset]",
-			SaucerHead:    "[green]>[reset]",
-			SaucerPadding: " ",
-			BarStart:      "[",
-			BarEnd:        "]",
-		}),
-	)
-
-	return &ProgressBar{
-		bar:          bar,
-		showProgress: showProgress,
-	}
+func (p *ProgressBarWithCount) IncrementFile() {
+	p.renderer.IncrementFile()
 }
 
-// ProgressBarWithCount wraps a progress bar to track file count atomically
-// Used for parallel download operations where multiple goroutines update progress
-type ProgressBarWithCount struct {
-	bar          *ProgressBar
-	current      *int32
-	total        int
-	description  string
-	mu           sync.Mutex // Protects bar.Describe() calls
-	showProgress bool       // Whether progress is being shown (not quiet mode and is TTY)
+// Rewind takes back bytes and files of a transfer that failed and is retried
+func (p *ProgressBarWithCount) Rewind(bytes int64, files int) {
+	p.renderer.Rewind(bytes, files)
 }
 
-func (p *ProgressBarWithCount) Write(b []byte) (int, error) {
-	return p.bar.Write(b)
+// StartFile shows name as in flight until FinishFile is called with it
+func (p *ProgressBarWithCount) StartFile(name string) {
+	p.renderer.StartFile(name)
 }
 
-func (p *ProgressBarWithCount) Add64(n int64) error {
-	return p.bar.Add64(n)
-}
-
-func (p *ProgressBarWithCount) IncrementFile() {
-	newCount := atomic.AddInt32(p.current, 1)
-	p.mu.Lock()
-	p.bar.Describe(fmt.Sprintf("[cyan][%d/%d][reset] %s", newCount, p.total, p.description))
-	p.mu.Unlock()
+func (p *ProgressBarWithCount) FinishFile(name string) {
+	p.renderer.FinishFile(name)
 }
 
 func (p *ProgressBarWithCount) Finish() error {
-	return p.bar.Finish()
+	p.renderer.Finish()
+	return nil
 }
 
-// NewProgressBarWithCount creates a new progress bar with file count tracking
-// The showProgress parameter controls whether progress should be shown (typically util.IsATTY() && !quietMode)
-func NewProgressBarWithCount(totalBytes int64, description string, total int, showProgress bool) *ProgressBarWithCount {
-	var current int32
-	baseBar := NewProgressBar(totalBytes, description, 0, total, showProgress)
-	return &ProgressBarWithCount{
-		bar:          baseBar,
-		current:      &current,
-		total:        total,
-		description:  description,
-		showProgress: showProgress,
+// NewProgressBarWithCount creates a new progress bar with file count tracking that shows the
+// bytes counted by meter, adding totalBytes to its total.
+// The showProgress parameter controls whether progress should be shown (typically !quietMode).
+// On a terminal the bar is redrawn in place, otherwise a status line is printed periodically.
+func NewProgressBarWithCount(meter output.Meter, totalBytes int64, description string, total int, showProgress bool) *ProgressBarWithCount {
+	var out io.Writer
+	tty := util.IsATTY()
+	if showProgress {
+		out = os.Stdout
+		if tty {
+			out = ansi.NewAnsiStdout()
+		}
 	}
+	meter.Grow(totalBytes)
+	return &ProgressBarWithCount{renderer: NewRenderer(out, tty, meter, description, total)}
 }
 
 // CappingWriter wraps an io.Writer and caps the total bytes written to a maximum value
@@ -0,0 +1,298 @@
+package progress
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tympanix/nexus-cli/internal/output"
+	"github.com/tympanix/nexus-cli/internal/util"
+	"golang.org/x/term"
+)
+
+const (
+	// redrawInterval is how often the bars are redrawn on a terminal
+	redrawInterval = 100 * time.Millisecond
+	// plainInterval is how often a status line is printed when output is not a terminal
+	plainInterval = 10 * time.Second
+)
+
+// Renderer draws the progress of a transfer. It is safe for concurrent use, so parallel
+// transfers report to the same renderer and only the renderer writes to the output.
+// On a terminal an aggregate bar and a line listing the files in flight are redrawn in
+// place; on other output a plain status line is printed periodically instead, and once
+// more when the transfer finishes if any was printed before.
+type Renderer struct {
+	mu          sync.Mutex
+	out         io.Writer // nil when progress is not shown
+	tty         bool
+	width       func() int
+	description string
+	meter       output.Meter // Source of the byte counts, shared with the rest of the transfer
+	totalFiles  int
+	files       int
+	active      []string // Files in flight, in the order they were started
+	lines       int      // Lines drawn by the last redraw on a terminal
+	plainLines  int      // Status lines printed on other output
+
+	done     chan struct{}
+	stopped  chan struct{}
+	finished sync.Once
+}
+
+// NewRenderer creates a renderer for a transfer of totalFiles files whose bytes are
+// counted by meter. With a nil out progress is only counted, never drawn. tty selects
+// between redrawing bars in place and printing plain status lines.
+func NewRenderer(out io.Writer, tty bool, meter output.Meter, description string, totalFiles int) *Renderer {
+	r := &Renderer{
+		out:         out,
+		tty:         tty,
+		width:       terminalWidth,
+		description: description,
+		meter:       meter,
+		totalFiles:  totalFiles,
+	}
+	if out != nil {
+		interval := plainInterval
+		if tty {
+			interval = redrawInterval
+		}
+		r.done = make(chan struct{})
+		r.stopped = make(chan struct{})
+		go r.run(interval)
+	}
+	return r
+}
+
+// terminalWidth returns the width of the terminal on stdout, or 80 if it is unknown
+func terminalWidth() int {
+	if width, _, err := term.GetSize(int(os.Stdout.Fd())); err == nil && width > 0 {
+		return width
+	}
+	return 80
+}
+
+func (r *Renderer) run(interval time.Duration) {
+	defer close(r.stopped)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-ticker.C:
+			r.mu.Lock()
+			r.render()
+			r.mu.Unlock()
+		}
+	}
+}
+
+// Write counts len(b) transferred bytes on the meter, so the renderer can be used in an io.MultiWriter
+func (r *Renderer) Write(b []byte) (int, error) {
+	r.meter.Add(int64(len(b)))
+	return len(b), nil
+}
+
+// Skip counts n bytes that did not have to be transferred on the meter
+func (r *Renderer) Skip(n int64) {
+	r.meter.Skip(n)
+}
+
+// Grow adds bytes and files to the totals, for transfers whose files are still being
+// found while they are transferred
+func (r *Renderer) Grow(bytes int64, files int) {
+	r.meter.Grow(bytes)
+	r.mu.Lock()
+	r.totalFiles += files
+	r.mu.Unlock()
+}
+
+// IncrementFile counts a completed file
+func (r *Renderer) IncrementFile() {
+	r.mu.Lock()
+	r.files++
+	r.mu.Unlock()
+}
+
+// Rewind takes back bytes counted as transferred and files counted as completed, e.g. of
+// a request that was rejected and is sent again
+func (r *Renderer) Rewind(bytes int64, files int) {
+	r.meter.Add(-bytes)
+	r.mu.Lock()
+	r.files -= files
+	r.mu.Unlock()
+}
+
+// StartFile adds name to the files in flight
+func (r *Renderer) StartFile(name string) {
+	r.mu.Lock()
+	r.active = append(r.active, name)
+	r.mu.Unlock()
+}
+
+// FinishFile removes name from the files in flight. It does not count the file as
+// completed, see IncrementFile.
+func (r *Renderer) FinishFile(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, active := range r.active {
+		if active == name {
+			r.active = append(r.active[:i], r.active[i+1:]...)
+			return
+		}
+	}
+}
+
+// Finish stops the periodic updates and draws the final state. It may be called more than once.
+func (r *Renderer) Finish() {
+	r.finished.Do(func() {
+		if r.done != nil {
+			close(r.done)
+			<-r.stopped
+		}
+		if r.out == nil {
+			return
+		}
+
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.active = nil
+		if r.tty {
+			r.redraw()
+			fmt.Fprintln(r.out)
+		} else if r.plainLines > 0 {
+			r.printPlain()
+		}
+	})
+}
+
+// render draws the current state. Callers must hold r.mu.
+func (r *Renderer) render() {
+	if r.tty {
+		r.redraw()
+	} else {
+		r.printPlain()
+	}
+}
+
+// redraw replaces the lines of the previous redraw with the current state
+func (r *Renderer) redraw() {
+	width := r.width()
+	lines := []string{r.barLine(width)}
+	if line := r.activeLine(width); line != "" {
+		lines = append(lines, line)
+	}
+
+	var sb strings.Builder
+	if r.lines > 1 {
+		fmt.Fprintf(&sb, "\x1b[%dA", r.lines-1)
+	}
+	sb.WriteString("\r")
+	for i, line := range lines {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		sb.WriteString("\x1b[2K" + line)
+	}
+	// Clear what is left of a previous redraw with more lines
+	for i := len(lines); i < r.lines; i++ {
+		sb.WriteString("\n\x1b[2K")
+	}
+	if extra := r.lines - len(lines); extra > 0 {
+		fmt.Fprintf(&sb, "\x1b[%dA", extra)
+	}
+	r.lines = len(lines)
+	io.WriteString(r.out, sb.String())
+}
+
+// printPlain prints a status line for output that is not a terminal
+func (r *Renderer) printPlain() {
+	reading := r.meter.Reading()
+	fmt.Fprintf(r.out, "%s: %d/%d files, %s / %s (%d%%)%s\n", r.description, r.files, r.totalFiles,
+		util.FormatByteSize(reading.Done()), util.FormatByteSize(reading.TotalBytes), r.percent(reading), rateETA(reading))
+	r.plainLines++
+}
+
+// percent returns the share of bytes done. Transfers may report more than the
+// estimated total, e.g. after retrying a file, so it is capped at 100.
+func (r *Renderer) percent(reading output.MeterReading) int {
+	if reading.TotalBytes <= 0 {
+		if r.totalFiles > 0 && r.files >= r.totalFiles {
+			return 100
+		}
+		return 0
+	}
+	percent := int(reading.Done() * 100 / reading.TotalBytes)
+	if percent > 100 {
+		percent = 100
+	}
+	return percent
+}
+
+// rateETA formats the current rate and the time left, or returns "" while nothing has
+// been transferred
+func rateETA(reading output.MeterReading) string {
+	if reading.Rate <= 0 {
+		return ""
+	}
+	s := fmt.Sprintf(", %s/s", util.FormatByteSize(int64(reading.Rate)))
+	if eta, ok := reading.ETA(); ok && eta.Round(time.Second) > 0 {
+		s += fmt.Sprintf(", ETA %s", eta.Round(time.Second))
+	}
+	return s
+}
+
+// barLine formats the aggregate bar, fitting it into width columns
+func (r *Renderer) barLine(width int) string {
+	reading := r.meter.Reading()
+	percent := r.percent(reading)
+	prefix := fmt.Sprintf("[%d/%d] %s %3d%% ", r.files, r.totalFiles, r.description, percent)
+	suffix := fmt.Sprintf(" %s / %s", util.FormatByteSize(reading.Done()), util.FormatByteSize(reading.TotalBytes)) + rateETA(reading)
+
+	barWidth := width - len(prefix) - len(suffix) - 3
+	if barWidth < 10 {
+		return truncate(prefix+strings.TrimSpace(suffix), width)
+	}
+	filled := barWidth * percent / 100
+	bar := strings.Repeat("=", filled)
+	if filled < barWidth {
+		bar += ">" + strings.Repeat(" ", barWidth-filled-1)
+	}
+	return prefix + "[" + bar + "]" + suffix
+}
+
+// activeLine lists the files in flight, or returns "" if there are none
+func (r *Renderer) activeLine(width int) string {
+	if len(r.active) == 0 {
+		return ""
+	}
+	line := "  current: "
+	for i, name := range r.active {
+		more := ""
+		if rest := len(r.active) - i - 1; rest > 0 {
+			more = fmt.Sprintf(" (+%d more)", rest)
+		}
+		entry := name
+		if i > 0 {
+			entry = ", " + name
+		}
+		if i > 0 && len(line)+len(entry)+len(more) > width-1 {
+			return line + fmt.Sprintf(" (+%d more)", len(r.active)-i)
+		}
+		line += entry
+	}
+	return truncate(line, width-1)
+}
+
+// truncate shortens s to at most width characters
+func truncate(s string, width int) string {
+	runes := []rune(s)
+	if width <= 3 || len(runes) <= width {
+		return s
+	}
+	return string(runes[:width-3]) + "..."
+}
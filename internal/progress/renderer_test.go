@@ -0,0 +1,181 @@
+package progress
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/tympanix/nexus-cli/internal/output"
+)
+
+// testMeter is an output.Meter whose rate is set by the test
+type testMeter struct {
+	mu      sync.Mutex
+	reading output.MeterReading
+}
+
+func newTestMeter(total int64) *testMeter {
+	return &testMeter{reading: output.MeterReading{TotalBytes: total}}
+}
+
+func (m *testMeter) Add(n int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.reading.TransferredBytes += n
+}
+
+func (m *testMeter) Skip(n int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.reading.SkippedBytes += n
+}
+
+func (m *testMeter) Grow(n int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.reading.TotalBytes += n
+}
+
+func (m *testMeter) Reading() output.MeterReading {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.reading
+}
+
+// TestRendererConcurrentUpdates checks that updates from many goroutines are all counted
+func TestRendererConcurrentUpdates(t *testing.T) {
+	var out bytes.Buffer
+	meter := newTestMeter(100 * 1024)
+	r := NewRenderer(&out, true, meter, "Downloading", 100)
+	r.width = func() int { return 100 }
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			name := strings.Repeat("f", i%7+1)
+			r.StartFile(name)
+			r.Write(make([]byte, 1024))
+			r.FinishFile(name)
+			r.IncrementFile()
+		}(i)
+	}
+	wg.Wait()
+	r.Finish()
+	r.Finish() // A second call is a no-op
+
+	if bytes := meter.Reading().TransferredBytes; bytes != 100*1024 || r.files != 100 || len(r.active) != 0 {
+		t.Errorf("Expected all updates to be counted, got %d bytes, %d files, %d active", bytes, r.files, len(r.active))
+	}
+	if !strings.Contains(out.String(), "[100/100] Downloading 100% [") {
+		t.Errorf("Expected the final bar to show completion, got %q", out.String())
+	}
+}
+
+func TestRendererRedraw(t *testing.T) {
+	var out bytes.Buffer
+	r := NewRenderer(nil, true, newTestMeter(1000), "Uploading", 3)
+	r.out = &out
+	r.width = func() int { return 80 }
+
+	r.Skip(500)
+	r.StartFile("a.bin")
+	r.StartFile("b.bin")
+	r.redraw()
+	first := out.String()
+	if !strings.Contains(first, "[0/3] Uploading  50% [") || !strings.Contains(first, "current: a.bin, b.bin") {
+		t.Errorf("Expected the bar and the files in flight, got %q", first)
+	}
+	for _, line := range strings.Split(first, "\n") {
+		if line = strings.TrimPrefix(strings.TrimPrefix(line, "\r"), "\x1b[2K"); len(line) > 80 {
+			t.Errorf("Expected lines to fit the width, got %d characters: %q", len(line), line)
+		}
+	}
+
+	// The next redraw moves up over the line of files in flight and clears it once empty
+	out.Reset()
+	r.FinishFile("a.bin")
+	r.FinishFile("b.bin")
+	r.redraw()
+	if !strings.HasPrefix(out.String(), "\x1b[1A\r\x1b[2K") || !strings.Contains(out.String(), "\n\x1b[2K\x1b[1A") {
+		t.Errorf("Expected the previous lines to be replaced, got %q", out.String())
+	}
+	if r.lines != 1 {
+		t.Errorf("Expected a single line to be drawn, got %d", r.lines)
+	}
+}
+
+func TestRendererActiveLineOverflow(t *testing.T) {
+	r := NewRenderer(nil, true, newTestMeter(0), "Moving", 10)
+	for _, name := range []string{"first-file.bin", "second-file.bin", "third-file.bin", "fourth-file.bin"} {
+		r.StartFile(name)
+	}
+	line := r.activeLine(60)
+	if line != "  current: first-file.bin, second-file.bin (+2 more)" {
+		t.Errorf("Unexpected line of files in flight: %q", line)
+	}
+}
+
+// TestRendererPlain checks the status lines printed when output is not a terminal
+func TestRendererPlain(t *testing.T) {
+	var out bytes.Buffer
+	r := NewRenderer(nil, false, newTestMeter(2048), "Processing files", 2)
+	r.out = &out
+
+	// Nothing is printed for a transfer that finishes before the first status line
+	r.Finish()
+	if out.Len() != 0 {
+		t.Errorf("Expected no output, got %q", out.String())
+	}
+
+	r = NewRenderer(nil, false, newTestMeter(2048), "Processing files", 2)
+	r.out = &out
+	r.Skip(1024)
+	r.IncrementFile()
+	r.render()
+	r.Skip(4096) // More than estimated, e.g. after a retry
+	r.IncrementFile()
+	r.Finish()
+
+	expected := "Processing files: 1/2 files, 1.0 KiB / 2.0 KiB (50%)\n" +
+		"Processing files: 2/2 files, 5.0 KiB / 2.0 KiB (100%)\n"
+	if out.String() != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
+
+func TestRendererDisabled(t *testing.T) {
+	meter := newTestMeter(10)
+	r := NewRenderer(nil, true, meter, "Downloading", 1)
+	r.Write([]byte("0123456789"))
+	r.IncrementFile()
+	r.Finish()
+	if bytes := meter.Reading().TransferredBytes; bytes != 10 || r.files != 1 {
+		t.Errorf("Expected progress to be counted without output, got %d bytes, %d files", bytes, r.files)
+	}
+}
+
+// TestRendererRateAndETA checks that the bar shows the rate and time left of the meter
+func TestRendererRateAndETA(t *testing.T) {
+	meter := newTestMeter(10 * 1024 * 1024)
+	meter.Add(2 * 1024 * 1024)
+	meter.Skip(3 * 1024 * 1024)
+	meter.reading.Rate = 1024 * 1024
+	r := NewRenderer(nil, true, meter, "Downloading", 4)
+
+	line := r.barLine(120)
+	if !strings.Contains(line, "5.0 MiB / 10.0 MiB, 1.0 MiB/s, ETA 5s") {
+		t.Errorf("Expected the rate and ETA of the meter, got %q", line)
+	}
+	if !strings.Contains(line, " 50% [") {
+		t.Errorf("Expected skipped bytes to count as done, got %q", line)
+	}
+
+	// Without a rate there is nothing to estimate from
+	meter.reading.Rate = 0
+	if line := r.barLine(120); strings.Contains(line, "ETA") || strings.Contains(line, "/s") {
+		t.Errorf("Expected no rate or ETA before anything is transferred, got %q", line)
+	}
+}
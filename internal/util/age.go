@@ -0,0 +1,37 @@
+package util
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseAge parses an age such as "30d", "2w" or "12h". Days ("d") and weeks ("w") are
+// accepted in addition to the units of time.ParseDuration. Negative ages, NaN, infinity
+// and ages too long for a time.Duration are rejected, since they would otherwise convert
+// to a negative duration that disables an age filter.
+func ParseAge(s string) (time.Duration, error) {
+	value := strings.TrimSpace(s)
+	if n := len(value); n > 1 && (value[n-1] == 'd' || value[n-1] == 'w') {
+		number, err := strconv.ParseFloat(value[:n-1], 64)
+		// The negated comparison also rejects NaN
+		if err != nil || !(number >= 0) {
+			return 0, fmt.Errorf("invalid age '%s': must be a non-negative number followed by a unit (e.g. 30d, 2w, 12h)", s)
+		}
+		unit := 24 * time.Hour
+		if value[n-1] == 'w' {
+			unit *= 7
+		}
+		if number*float64(unit) >= math.MaxInt64 {
+			return 0, fmt.Errorf("invalid age '%s': too long", s)
+		}
+		return time.Duration(number * float64(unit)), nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid age '%s': must be a non-negative number followed by a unit (e.g. 30d, 2w, 12h)", s)
+	}
+	return d, nil
+}
@@ -0,0 +1,55 @@
+package util
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseAge(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{input: "30d", want: 30 * 24 * time.Hour},
+		{input: "2w", want: 14 * 24 * time.Hour},
+		{input: "1.5d", want: 36 * time.Hour},
+		{input: "12h", want: 12 * time.Hour},
+		{input: "90m", want: 90 * time.Minute},
+		{input: " 7d ", want: 7 * 24 * time.Hour},
+		{input: "0d", want: 0},
+		{input: "", wantErr: true},
+		{input: "d", wantErr: true},
+		{input: "30", wantErr: true},
+		{input: "-1d", wantErr: true},
+		{input: "-1h", wantErr: true},
+		{input: "soon", wantErr: true},
+		// Values that would convert to a negative duration and disable an age filter
+		{input: "NaNd", wantErr: true},
+		{input: "Infd", wantErr: true},
+		{input: "+Infw", wantErr: true},
+		{input: "1e300d", wantErr: true},
+		{input: "1e400d", wantErr: true},
+		{input: "106752d", wantErr: true},
+		{input: "9999999999999h", wantErr: true},
+		{input: "106751d", want: 106751 * 24 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseAge(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseAge(%q) expected error, got %v", tt.input, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseAge(%q) unexpected error: %v", tt.input, err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseAge(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package util
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ParseDirMode parses an octal directory mode such as "755", "0750" or "2775". The
+// setuid (4000), setgid (2000) and sticky (1000) bits are kept. An empty string gives 0,
+// which MkdirAll takes as 0777 less the umask.
+func ParseDirMode(s string) (os.FileMode, error) {
+	value := strings.TrimSpace(s)
+	if value == "" {
+		return 0, nil
+	}
+	bits, err := strconv.ParseUint(value, 8, 32)
+	if err != nil || bits > 07777 {
+		return 0, fmt.Errorf("invalid directory mode '%s': must be an octal mode such as 755 or 2775", s)
+	}
+	mode := os.FileMode(bits) & os.ModePerm
+	if bits&04000 != 0 {
+		mode |= os.ModeSetuid
+	}
+	if bits&02000 != 0 {
+		mode |= os.ModeSetgid
+	}
+	if bits&01000 != 0 {
+		mode |= os.ModeSticky
+	}
+	return mode, nil
+}
+
+// MkdirAll creates dir and any missing parents like os.MkdirAll. With mode 0 new
+// directories get 0777 less the umask, like mkdir(1); otherwise they are set to exactly
+// mode, whatever the umask. A new directory always keeps the setgid bit of its parent,
+// so files created in a shared group directory stay in its group. Existing directories
+// are left as they are.
+func MkdirAll(dir string, mode os.FileMode) error {
+	if info, err := os.Stat(dir); err == nil && info.IsDir() {
+		return nil
+	}
+	parent := filepath.Dir(dir)
+	if parent != dir {
+		if err := MkdirAll(parent, mode); err != nil {
+			return err
+		}
+	}
+	if err := os.Mkdir(dir, 0777); err != nil {
+		// Parallel downloads create the same directories
+		if info, statErr := os.Stat(dir); statErr == nil && info.IsDir() {
+			return nil
+		}
+		return err
+	}
+	return applyDirMode(dir, parent, mode)
+}
+
+// applyDirMode sets the mode of the new directory dir, adding the setgid bit of its parent
+func applyDirMode(dir, parent string, mode os.FileMode) error {
+	setgid := false
+	if info, err := os.Stat(parent); err == nil {
+		setgid = info.Mode()&os.ModeSetgid != 0
+	}
+	if mode == 0 {
+		if !setgid {
+			return nil
+		}
+		// Most systems already inherit the bit on mkdir
+		info, err := os.Stat(dir)
+		if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSetgid != 0 {
+			return nil
+		}
+		mode = info.Mode() & (os.ModePerm | os.ModeSetuid | os.ModeSticky)
+	}
+	if setgid {
+		mode |= os.ModeSetgid
+	}
+	return os.Chmod(dir, mode)
+}
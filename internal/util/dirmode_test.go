@@ -0,0 +1,36 @@
+package util
+
+import (
+	"os"
+	"testing"
+)
+
+func TestParseDirMode(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    os.FileMode
+		wantErr bool
+	}{
+		{input: "", want: 0},
+		{input: "755", want: 0755},
+		{input: "0750", want: 0750},
+		{input: "2775", want: 0775 | os.ModeSetgid},
+		{input: "1777", want: 0777 | os.ModeSticky},
+		{input: "4755", want: 0755 | os.ModeSetuid},
+		{input: "rwxr-xr-x", wantErr: true},
+		{input: "789", wantErr: true},
+		{input: "17777", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseDirMode(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseDirMode(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseDirMode(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+//go:build linux || darwin || freebsd
+
+package util
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+// withUmask runs fn with the process umask set to mask
+func withUmask(mask int, fn func()) {
+	old := syscall.Umask(mask)
+	defer syscall.Umask(old)
+	fn()
+}
+
+func dirMode(t *testing.T, dir string) os.FileMode {
+	t.Helper()
+	info, err := os.Stat(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info.Mode() & (os.ModePerm | os.ModeSetgid | os.ModeSetuid | os.ModeSticky)
+}
+
+// TestMkdirAllHonorsUmask tests that without a mode new directories get 0777 less the umask
+func TestMkdirAllHonorsUmask(t *testing.T) {
+	root := t.TempDir()
+	withUmask(0002, func() {
+		if err := MkdirAll(filepath.Join(root, "a", "b"), 0); err != nil {
+			t.Fatal(err)
+		}
+	})
+	for _, dir := range []string{"a", filepath.Join("a", "b")} {
+		if mode := dirMode(t, filepath.Join(root, dir)); mode != 0775 {
+			t.Errorf("Expected %s to have mode 0775, got %o", dir, mode)
+		}
+	}
+}
+
+// TestMkdirAllMode tests that an explicit mode is applied whatever the umask, and only to
+// the directories that were created
+func TestMkdirAllMode(t *testing.T) {
+	root := t.TempDir()
+	existing := filepath.Join(root, "existing")
+	if err := os.Mkdir(existing, 0700); err != nil {
+		t.Fatal(err)
+	}
+	withUmask(0077, func() {
+		if err := MkdirAll(filepath.Join(existing, "a", "b"), 0750|os.ModeSetgid); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if mode := dirMode(t, existing); mode != 0700 {
+		t.Errorf("Expected the existing directory to keep mode 0700, got %o", mode)
+	}
+	for _, dir := range []string{"a", filepath.Join("a", "b")} {
+		if mode := dirMode(t, filepath.Join(existing, dir)); mode != 0750|os.ModeSetgid {
+			t.Errorf("Expected %s to have mode 2750, got %v", dir, mode)
+		}
+	}
+}
+
+// TestMkdirAllKeepsParentSetgid tests that new directories keep the setgid bit of a
+// shared parent directory, also when a mode without it is given
+func TestMkdirAllKeepsParentSetgid(t *testing.T) {
+	shared := filepath.Join(t.TempDir(), "shared")
+	if err := os.Mkdir(shared, 0775); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(shared, 0775|os.ModeSetgid); err != nil {
+		t.Fatal(err)
+	}
+	if dirMode(t, shared)&os.ModeSetgid == 0 {
+		t.Skip("filesystem does not support the setgid bit on directories")
+	}
+
+	withUmask(0022, func() {
+		if err := MkdirAll(filepath.Join(shared, "default"), 0); err != nil {
+			t.Fatal(err)
+		}
+		if err := MkdirAll(filepath.Join(shared, "explicit"), 0770); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if mode := dirMode(t, filepath.Join(shared, "default")); mode != 0755|os.ModeSetgid {
+		t.Errorf("Expected mode 2755, got %v", mode)
+	}
+	if mode := dirMode(t, filepath.Join(shared, "explicit")); mode != 0770|os.ModeSetgid {
+		t.Errorf("Expected mode 2770, got %v", mode)
+	}
+}
@@ -47,35 +47,89 @@ func ParseGlobPattern(globPattern string) *GlobPattern {
 // 2. No negative patterns match
 // The path is automatically normalized to use forward slashes for consistent matching.
 func (gp *GlobPattern) Match(path string) (bool, error) {
+	matched, _, err := gp.Explain(path)
+	return matched, err
+}
+
+// Explain works like Match but also returns a human readable reason naming the
+// pattern that included or excluded the path. It is used for --glob-debug output.
+func (gp *GlobPattern) Explain(path string) (bool, string, error) {
 	path = filepath.ToSlash(path)
 
+	reason := "no include patterns"
 	matchesPositive := len(gp.positivePatterns) == 0
 	for _, pattern := range gp.positivePatterns {
 		matched, err := doublestar.Match(pattern, path)
 		if err != nil {
-			return false, fmt.Errorf("invalid glob pattern '%s': %w", pattern, err)
+			return false, "", fmt.Errorf("invalid glob pattern '%s': %w", pattern, err)
 		}
 		if matched {
 			matchesPositive = true
+			reason = fmt.Sprintf("matched '%s'", pattern)
 			break
 		}
 	}
 
 	if !matchesPositive {
-		return false, nil
+		return false, "no include pattern matched", nil
 	}
 
 	for _, pattern := range gp.negativePatterns {
 		matched, err := doublestar.Match(pattern, path)
 		if err != nil {
-			return false, fmt.Errorf("invalid glob pattern '%s': %w", pattern, err)
+			return false, "", fmt.Errorf("invalid glob pattern '%s': %w", pattern, err)
 		}
 		if matched {
-			return false, nil
+			return false, fmt.Sprintf("excluded by '!%s'", pattern), nil
 		}
 	}
 
-	return true, nil
+	return true, reason, nil
+}
+
+// ValidateGlobPattern checks a comma-separated glob pattern string for mistakes that would
+// otherwise silently match nothing or fail while walking files. The returned error names
+// the offending sub-pattern and hints at the supported syntax. An empty string is valid
+// and means no filtering.
+func ValidateGlobPattern(globPattern string) error {
+	if globPattern == "" {
+		return nil
+	}
+
+	subPatterns := strings.Split(globPattern, ",")
+	nonEmpty := 0
+	for _, raw := range subPatterns {
+		pattern := strings.TrimSpace(raw)
+		if pattern == "" {
+			continue
+		}
+		nonEmpty++
+
+		body := strings.TrimPrefix(pattern, "!")
+		if body == "" {
+			return fmt.Errorf("invalid glob pattern '%s': exclusion '!' must be followed by a pattern, e.g. '!**/*_test.go'", pattern)
+		}
+		if !doublestar.ValidatePattern(body) {
+			return fmt.Errorf("invalid glob pattern '%s': %s", pattern, globSyntaxHint(body))
+		}
+	}
+
+	if nonEmpty == 0 {
+		return fmt.Errorf("invalid glob pattern '%s': no patterns given, e.g. '**/*.go'", globPattern)
+	}
+
+	return nil
+}
+
+// globSyntaxHint returns a hint explaining why a sub-pattern is invalid
+func globSyntaxHint(pattern string) string {
+	if strings.Count(pattern, "{") != strings.Count(pattern, "}") {
+		return "brace expansion like '*.{go,md}' is not supported because ',' separates patterns; use '**/*.go,**/*.md' instead"
+	}
+	if strings.Count(pattern, "[") != strings.Count(pattern, "]") {
+		return "unterminated character class; use '[abc]' or '[a-z]', or escape a literal '[' as '\\['"
+	}
+	return "supported syntax is '*', '**', '?', '[abc]' and '!' for exclusion"
 }
 
 // FilterWithGlob filters a slice of items using glob patterns.
@@ -1,6 +1,7 @@
 package util
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -307,3 +308,66 @@ func TestFilterWithGlobInvalidPattern(t *testing.T) {
 		t.Error("FilterWithGlob() expected error for invalid pattern, got nil")
 	}
 }
+
+func TestValidateGlobPattern(t *testing.T) {
+	tests := []struct {
+		name        string
+		globPattern string
+		errContains string
+	}{
+		{name: "empty string means no filter", globPattern: ""},
+		{name: "single pattern", globPattern: "**/*.go"},
+		{name: "include and exclude", globPattern: "**/*.go, !**/*_test.go"},
+		{name: "empty entries are skipped", globPattern: "**/*.go,,**/*.md,"},
+		{name: "only separators", globPattern: " , ,", errContains: "no patterns given"},
+		{name: "lone exclusion", globPattern: "**/*.go,!", errContains: "invalid glob pattern '!': exclusion '!' must be followed by a pattern"},
+		{name: "brace expansion", globPattern: "**/*.{go,md}", errContains: "invalid glob pattern '**/*.{go': brace expansion"},
+		{name: "unterminated class", globPattern: "src/[abc", errContains: "invalid glob pattern 'src/[abc': unterminated character class"},
+		{name: "invalid exclusion", globPattern: "**/*,![x", errContains: "invalid glob pattern '![x'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateGlobPattern(tt.globPattern)
+			if tt.errContains == "" {
+				if err != nil {
+					t.Errorf("ValidateGlobPattern(%q) unexpected error: %v", tt.globPattern, err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("ValidateGlobPattern(%q) expected error, got nil", tt.globPattern)
+			}
+			if !strings.Contains(err.Error(), tt.errContains) {
+				t.Errorf("ValidateGlobPattern(%q) error = %q, want it to contain %q", tt.globPattern, err.Error(), tt.errContains)
+			}
+		})
+	}
+}
+
+func TestGlobPatternExplain(t *testing.T) {
+	gp := ParseGlobPattern("**/*.go,**/*.md,!**/*_test.go")
+
+	tests := []struct {
+		path       string
+		wantMatch  bool
+		wantReason string
+	}{
+		{path: "main.go", wantMatch: true, wantReason: "matched '**/*.go'"},
+		{path: "docs/README.md", wantMatch: true, wantReason: "matched '**/*.md'"},
+		{path: "main_test.go", wantMatch: false, wantReason: "excluded by '!**/*_test.go'"},
+		{path: "image.png", wantMatch: false, wantReason: "no include pattern matched"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			matched, reason, err := gp.Explain(tt.path)
+			if err != nil {
+				t.Fatalf("Explain(%q) unexpected error: %v", tt.path, err)
+			}
+			if matched != tt.wantMatch || reason != tt.wantReason {
+				t.Errorf("Explain(%q) = (%v, %q), want (%v, %q)", tt.path, matched, reason, tt.wantMatch, tt.wantReason)
+			}
+		})
+	}
+}
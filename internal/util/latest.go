@@ -0,0 +1,317 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNoSemver is returned by ResolveLatest if none of the names a {latest} placeholder
+// selecting by semver is resolved from is a semantic version
+var ErrNoSemver = errors.New("no name is a semantic version")
+
+// SelectMode is how a {latest} placeholder picks among the names found at its position
+type SelectMode string
+
+const (
+	SelectSemver  SelectMode = "semver"  // Highest semantic version, by semver precedence
+	SelectLexical SelectMode = "lexical" // Last name in byte order
+	SelectMtime   SelectMode = "mtime"   // Name holding the most recently modified asset
+)
+
+// ParseSelectMode parses the mode of --select or of a {latest:<mode>} placeholder
+func ParseSelectMode(s string) (SelectMode, error) {
+	switch mode := SelectMode(strings.ToLower(s)); mode {
+	case SelectSemver, SelectLexical, SelectMtime:
+		return mode, nil
+	}
+	return "", fmt.Errorf("invalid selection '%s': must be semver, lexical or mtime", s)
+}
+
+// latestPattern matches {latest} and {latest:<mode>}
+var latestPattern = regexp.MustCompile(`\{latest(?::([^}]*))?\}`)
+
+// HasLatest reports whether p contains a {latest} placeholder
+func HasLatest(p string) bool {
+	return latestPattern.MatchString(p)
+}
+
+// ValidateLatest checks that every {latest} placeholder of p names a valid mode and that
+// no path segment has more than one
+func ValidateLatest(p string) error {
+	for _, segment := range strings.Split(p, "/") {
+		placeholders := latestPattern.FindAllStringSubmatch(segment, -1)
+		if len(placeholders) > 1 {
+			return fmt.Errorf("path segment '%s' has more than one {latest} placeholder", segment)
+		}
+		for _, placeholder := range placeholders {
+			if placeholder[1] == "" && placeholder[0] != "{latest}" {
+				return fmt.Errorf("invalid placeholder '%s': must be {latest} or {latest:semver|lexical|mtime}", placeholder[0])
+			}
+			if placeholder[1] != "" {
+				if _, err := ParseSelectMode(placeholder[1]); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// LatestAsset is an asset listed below the parent folder of a {latest} placeholder
+type LatestAsset struct {
+	Path     string // Path of the asset, with or without a leading slash
+	Modified time.Time
+}
+
+// LatestSelection describes how one {latest} placeholder was resolved
+type LatestSelection struct {
+	Parent  string     // Folder whose entries were considered, "" for the repository root
+	Mode    SelectMode // How the value was selected
+	Value   string     // Selected value, the part of the entry name the placeholder stands for
+	Ignored []string   // Entry names that are not semantic versions, for SelectSemver
+}
+
+// ResolveLatest replaces the {latest} placeholders of p from left to right. For each one
+// the assets below the folder before its segment are listed with list, and the names of
+// the entries of that folder that match the rest of the segment are the candidates, e.g.
+// app-1.2.0.zip for app-{latest}.zip. A placeholder without a mode selects with
+// defaultMode. With SelectSemver names that are not semantic versions are ignored; if no
+// name is one, the error lists them.
+func ResolveLatest(p string, defaultMode SelectMode, list func(parent string) ([]LatestAsset, error)) (string, []LatestSelection, error) {
+	if err := ValidateLatest(p); err != nil {
+		return "", nil, err
+	}
+	segments := strings.Split(p, "/")
+	var selections []LatestSelection
+	for i, segment := range segments {
+		loc := latestPattern.FindStringSubmatchIndex(segment)
+		if loc == nil {
+			continue
+		}
+		mode := defaultMode
+		if loc[2] >= 0 {
+			mode, _ = ParseSelectMode(segment[loc[2]:loc[3]])
+		}
+		before, after := segment[:loc[0]], segment[loc[1]:]
+		parent := strings.Trim(strings.Join(segments[:i], "/"), "/")
+
+		assets, err := list(parent)
+		if err != nil {
+			return "", nil, err
+		}
+		candidates := latestCandidates(assets, parent, before, after)
+		under := "the repository root"
+		if parent != "" {
+			under = "'" + parent + "'"
+		}
+		if len(candidates) == 0 {
+			return "", nil, fmt.Errorf("no entries matching '%s' found under %s", segment, under)
+		}
+		value, ignored, err := selectLatest(candidates, mode)
+		if err != nil {
+			return "", nil, fmt.Errorf("cannot resolve '%s' under %s: %w", segment, under, err)
+		}
+		segments[i] = before + value + after
+		selections = append(selections, LatestSelection{Parent: parent, Mode: mode, Value: value, Ignored: ignored})
+	}
+	return strings.Join(segments, "/"), selections, nil
+}
+
+// latestCandidates returns the values the entries of parent give a placeholder between
+// before and after, with the modification time of the newest asset of each entry
+func latestCandidates(assets []LatestAsset, parent, before, after string) map[string]time.Time {
+	candidates := make(map[string]time.Time)
+	for _, asset := range assets {
+		rel := strings.TrimPrefix(asset.Path, "/")
+		if parent != "" {
+			if !strings.HasPrefix(rel, parent+"/") {
+				continue
+			}
+			rel = rel[len(parent)+1:]
+		}
+		name, _, _ := strings.Cut(rel, "/")
+		if len(name) <= len(before)+len(after) || !strings.HasPrefix(name, before) || !strings.HasSuffix(name, after) {
+			continue
+		}
+		value := name[len(before) : len(name)-len(after)]
+		if modified, ok := candidates[value]; !ok || asset.Modified.After(modified) {
+			candidates[value] = asset.Modified
+		}
+	}
+	return candidates
+}
+
+// selectLatest picks the latest of candidates by mode. Ties are broken by byte order, so
+// the result does not depend on the listing order.
+func selectLatest(candidates map[string]time.Time, mode SelectMode) (string, []string, error) {
+	names := make([]string, 0, len(candidates))
+	for name := range candidates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	switch mode {
+	case SelectLexical:
+		return names[len(names)-1], nil, nil
+	case SelectMtime:
+		latest := names[0]
+		for _, name := range names[1:] {
+			if !candidates[name].Before(candidates[latest]) {
+				latest = name
+			}
+		}
+		return latest, nil, nil
+	}
+
+	var latest string
+	var latestVersion Semver
+	var ignored []string
+	for _, name := range names {
+		version, ok := ParseSemver(name)
+		if !ok {
+			ignored = append(ignored, name)
+			continue
+		}
+		if latest == "" || version.Compare(latestVersion) >= 0 {
+			latest, latestVersion = name, version
+		}
+	}
+	if latest == "" {
+		return "", nil, fmt.Errorf("%w: %s", ErrNoSemver, strings.Join(names, ", "))
+	}
+	return latest, ignored, nil
+}
+
+// Semver is a semantic version. Build metadata is dropped, as it does not take part in
+// precedence.
+type Semver struct {
+	Major, Minor, Patch uint64
+	PreRelease          []string // Dot separated identifiers after the '-', nil for a release
+}
+
+// ParseSemver parses a semantic version such as 1.2.3, v1.2.3-rc.1 or 1.2.3+build.5. An
+// optional 'v' prefix is allowed, and a missing patch version counts as 0, so 2.1 is a
+// version too. A major version alone is not, so dates such as 2024-01-15 are not taken
+// for version 2024 with a pre-release.
+func ParseSemver(s string) (Semver, bool) {
+	if strings.HasPrefix(s, "v") || strings.HasPrefix(s, "V") {
+		s = s[1:]
+	}
+	s, build, hasBuild := strings.Cut(s, "+")
+	if hasBuild && !validIdentifiers(build) {
+		return Semver{}, false
+	}
+	core, pre, hasPre := strings.Cut(s, "-")
+	if hasPre && !validIdentifiers(pre) {
+		return Semver{}, false
+	}
+
+	parts := strings.Split(core, ".")
+	if len(parts) < 2 || len(parts) > 3 {
+		return Semver{}, false
+	}
+	var numbers [3]uint64
+	for i, part := range parts {
+		if !isNumeric(part) {
+			return Semver{}, false
+		}
+		n, err := strconv.ParseUint(part, 10, 64)
+		if err != nil {
+			return Semver{}, false
+		}
+		numbers[i] = n
+	}
+
+	version := Semver{Major: numbers[0], Minor: numbers[1], Patch: numbers[2]}
+	if hasPre {
+		version.PreRelease = strings.Split(pre, ".")
+		for _, identifier := range version.PreRelease {
+			// Numeric identifiers have no leading zeros, which keeps dates such as 2024-01 out
+			if isNumeric(identifier) && len(identifier) > 1 && identifier[0] == '0' {
+				return Semver{}, false
+			}
+		}
+	}
+	return version, true
+}
+
+// Compare returns -1, 0 or 1 as v has lower, equal or higher precedence than other. A
+// pre-release has lower precedence than its release, and pre-release identifiers compare
+// numerically if both are numeric, with numeric identifiers below alphanumeric ones.
+func (v Semver) Compare(other Semver) int {
+	for _, c := range [][2]uint64{{v.Major, other.Major}, {v.Minor, other.Minor}, {v.Patch, other.Patch}} {
+		if c[0] != c[1] {
+			return compareOrdered(c[0], c[1])
+		}
+	}
+	switch {
+	case v.PreRelease == nil && other.PreRelease == nil:
+		return 0
+	case v.PreRelease == nil:
+		return 1
+	case other.PreRelease == nil:
+		return -1
+	}
+	for i := 0; i < min(len(v.PreRelease), len(other.PreRelease)); i++ {
+		a, b := v.PreRelease[i], other.PreRelease[i]
+		if a == b {
+			continue
+		}
+		aNumeric, bNumeric := isNumeric(a), isNumeric(b)
+		switch {
+		case aNumeric && bNumeric:
+			an, _ := strconv.ParseUint(a, 10, 64)
+			bn, _ := strconv.ParseUint(b, 10, 64)
+			return compareOrdered(an, bn)
+		case aNumeric:
+			return -1
+		case bNumeric:
+			return 1
+		}
+		return strings.Compare(a, b)
+	}
+	return compareOrdered(len(v.PreRelease), len(other.PreRelease))
+}
+
+func compareOrdered[T uint64 | int](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// validIdentifiers reports whether s is a dot separated list of non-empty identifiers of
+// ASCII letters, digits and hyphens
+func validIdentifiers(s string) bool {
+	for _, identifier := range strings.Split(s, ".") {
+		if identifier == "" {
+			return false
+		}
+		for _, r := range identifier {
+			if !(r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r == '-') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func isNumeric(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
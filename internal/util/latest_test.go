@@ -0,0 +1,107 @@
+package util
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSemverCompare(t *testing.T) {
+	// In ascending precedence, from the examples of the semver specification
+	ordered := []string{
+		"1.0.0-alpha",
+		"1.0.0-alpha.1",
+		"1.0.0-alpha.beta",
+		"1.0.0-beta",
+		"1.0.0-beta.2",
+		"v1.0.0-beta.11",
+		"1.0.0-rc.1",
+		"1.0.0",
+		"1.1",
+		"1.9.0",
+		"V1.10.0+build.5",
+		"2.0",
+	}
+	for i := range ordered {
+		for j := range ordered {
+			a, ok := ParseSemver(ordered[i])
+			if !ok {
+				t.Fatalf("Expected %s to be a semantic version", ordered[i])
+			}
+			b, _ := ParseSemver(ordered[j])
+			if got, want := a.Compare(b), compareOrdered(i, j); got != want {
+				t.Errorf("Compare(%s, %s) = %d, expected %d", ordered[i], ordered[j], got, want)
+			}
+		}
+	}
+
+	for _, invalid := range []string{"", "v", "latest", "1.2.3.4", "1.x", "1.0.0-", "1.0.0-rc..1", "1.0.0+", "2024-01", "2024-01-15", "v2", "1.0.0-rc_1"} {
+		if _, ok := ParseSemver(invalid); ok {
+			t.Errorf("Expected %q not to be a semantic version", invalid)
+		}
+	}
+}
+
+func TestResolveLatest(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	assets := []LatestAsset{
+		{Path: "/libfoo/1.9.0/libfoo.jar", Modified: base.Add(3 * time.Hour)},
+		{Path: "/libfoo/1.10.0/libfoo.jar", Modified: base.Add(1 * time.Hour)},
+		{Path: "/libfoo/1.10.0/libfoo.pom", Modified: base.Add(2 * time.Hour)},
+		{Path: "/libfoo/2.0.0-rc.1/libfoo.jar", Modified: base},
+		{Path: "/libfoo/nightly/libfoo.jar", Modified: base},
+		{Path: "/libfoo/releases/app-1.2.zip", Modified: base},
+		{Path: "/libfoo/releases/app-1.10.zip", Modified: base},
+		{Path: "/libfoo/releases/app-1.10.zip.sha1", Modified: base},
+		{Path: "/libfoobar/9.0.0/libfoobar.jar", Modified: base},
+	}
+	var listed []string
+	list := func(parent string) ([]LatestAsset, error) {
+		listed = append(listed, parent)
+		return assets, nil
+	}
+
+	resolved, selections, err := ResolveLatest("libfoo/{latest}/libfoo.jar", SelectSemver, list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resolved != "libfoo/2.0.0-rc.1/libfoo.jar" {
+		t.Errorf("Expected the highest version, got %s", resolved)
+	}
+	if len(selections) != 1 || selections[0].Parent != "libfoo" || strings.Join(selections[0].Ignored, ",") != "nightly,releases" {
+		t.Errorf("Expected the names that are not versions to be ignored, got %+v", selections)
+	}
+	if len(listed) != 1 || listed[0] != "libfoo" {
+		t.Errorf("Expected the parent folder to be listed, got %v", listed)
+	}
+
+	for _, tc := range []struct {
+		pattern  string
+		expected string
+	}{
+		{"libfoo/{latest:lexical}", "libfoo/releases"},
+		{"libfoo/{latest:mtime}/", "libfoo/1.9.0/"},
+		{"libfoo/releases/app-{latest}.zip", "libfoo/releases/app-1.10.zip"},
+		{"{latest:lexical}/{latest:semver}", "libfoobar/9.0.0"},
+	} {
+		resolved, _, err := ResolveLatest(tc.pattern, SelectSemver, list)
+		if err != nil {
+			t.Errorf("ResolveLatest(%s) failed: %v", tc.pattern, err)
+		} else if resolved != tc.expected {
+			t.Errorf("ResolveLatest(%s) = %s, expected %s", tc.pattern, resolved, tc.expected)
+		}
+	}
+
+	if _, _, err := ResolveLatest("libfoo/releases/{latest}", SelectSemver, list); !errors.Is(err, ErrNoSemver) || !strings.Contains(err.Error(), "app-1.10.zip, app-1.10.zip.sha1, app-1.2.zip") {
+		t.Errorf("Expected an error listing the names, got %v", err)
+	}
+	if _, _, err := ResolveLatest("libfoo/{latest}", SelectLexical, func(string) ([]LatestAsset, error) { return nil, nil }); err == nil || !strings.Contains(err.Error(), "under 'libfoo'") {
+		t.Errorf("Expected an error for an empty folder, got %v", err)
+	}
+	for _, invalid := range []string{"libfoo/{latest:newest}", "libfoo/{latest:}", "libfoo/{latest}-{latest}"} {
+		if _, _, err := ResolveLatest(invalid, SelectSemver, list); err == nil {
+			t.Errorf("Expected an error for %s", invalid)
+		}
+	}
+}
@@ -12,22 +12,28 @@ type Logger interface {
 	VerbosePrintf(format string, v ...interface{})
 	VerbosePrintln(v ...interface{})
 	IsVerbose() bool
+	Verbosity() int // Number of times --verbose was given: 0 normal, 1 verbose (-v), 2 or more very verbose (-vv)
 }
 
 // SimpleLogger writes to the given writer
 type SimpleLogger struct {
-	writer  io.Writer
-	verbose bool
+	writer    io.Writer
+	verbosity int
 }
 
 // NewLogger creates a new logger that writes to the given writer
 func NewLogger(writer io.Writer) Logger {
-	return &SimpleLogger{writer: writer, verbose: false}
+	return &SimpleLogger{writer: writer}
 }
 
 // NewVerboseLogger creates a new logger with verbose mode enabled
 func NewVerboseLogger(writer io.Writer) Logger {
-	return &SimpleLogger{writer: writer, verbose: true}
+	return NewLoggerWithVerbosity(writer, 1)
+}
+
+// NewLoggerWithVerbosity creates a new logger with the given verbosity, e.g. 2 for -vv
+func NewLoggerWithVerbosity(writer io.Writer, verbosity int) Logger {
+	return &SimpleLogger{writer: writer, verbosity: verbosity}
 }
 
 func (l *SimpleLogger) Printf(format string, v ...interface{}) {
@@ -39,17 +45,21 @@ func (l *SimpleLogger) Println(v ...interface{}) {
 }
 
 func (l *SimpleLogger) VerbosePrintf(format string, v ...interface{}) {
-	if l.verbose {
+	if l.IsVerbose() {
 		fmt.Fprintf(l.writer, format, v...)
 	}
 }
 
 func (l *SimpleLogger) VerbosePrintln(v ...interface{}) {
-	if l.verbose {
+	if l.IsVerbose() {
 		fmt.Fprintln(l.writer, v...)
 	}
 }
 
 func (l *SimpleLogger) IsVerbose() bool {
-	return l.verbose
+	return l.verbosity > 0
+}
+
+func (l *SimpleLogger) Verbosity() int {
+	return l.verbosity
 }
@@ -76,3 +76,21 @@ func TestNonVerboseLogger(t *testing.T) {
 		t.Errorf("Expected no output, got '%s'", buf.String())
 	}
 }
+
+func TestLoggerVerbosity(t *testing.T) {
+	var buf bytes.Buffer
+	if v := NewLogger(&buf).Verbosity(); v != 0 {
+		t.Errorf("Expected verbosity 0, got %d", v)
+	}
+	if v := NewVerboseLogger(&buf).Verbosity(); v != 1 {
+		t.Errorf("Expected verbosity 1, got %d", v)
+	}
+	logger := NewLoggerWithVerbosity(&buf, 2)
+	if v := logger.Verbosity(); v != 2 {
+		t.Errorf("Expected verbosity 2, got %d", v)
+	}
+	logger.VerbosePrintln("verbose")
+	if buf.String() != "verbose\n" {
+		t.Errorf("Expected verbose messages at -vv, got %q", buf.String())
+	}
+}
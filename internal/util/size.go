@@ -0,0 +1,67 @@
+package util
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ParseByteSize parses a human-readable byte size such as "512", "10K", "1.5MB" or "2GiB".
+// Units are binary multiples (1K = 1024 bytes) and are case-insensitive.
+func ParseByteSize(s string) (int64, error) {
+	value := strings.TrimSpace(s)
+	if value == "" {
+		return 0, fmt.Errorf("invalid size '%s': value is empty", s)
+	}
+
+	upper := strings.ToUpper(value)
+	// "iB" is only valid after a unit, as in "2GiB"
+	binary := strings.HasSuffix(upper, "IB")
+	if binary {
+		upper = strings.TrimSuffix(upper, "IB")
+	} else {
+		upper = strings.TrimSuffix(upper, "B")
+	}
+
+	multiplier := int64(1)
+	if n := len(upper); n > 0 {
+		switch upper[n-1] {
+		case 'K':
+			multiplier = 1 << 10
+		case 'M':
+			multiplier = 1 << 20
+		case 'G':
+			multiplier = 1 << 30
+		case 'T':
+			multiplier = 1 << 40
+		}
+		if multiplier > 1 {
+			upper = upper[:n-1]
+		}
+	}
+
+	number, err := strconv.ParseFloat(strings.TrimSpace(upper), 64)
+	// The negated comparison also rejects NaN
+	if err != nil || !(number >= 0) || (binary && multiplier == 1) {
+		return 0, fmt.Errorf("invalid size '%s': must be a non-negative number with optional unit (K, M, G, T)", s)
+	}
+	if number*float64(multiplier) >= math.MaxInt64 {
+		return 0, fmt.Errorf("invalid size '%s': too large", s)
+	}
+	return int64(number * float64(multiplier)), nil
+}
+
+// FormatByteSize formats a byte count with binary units, e.g. "1.5 MiB"
+func FormatByteSize(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,77 @@
+package util
+
+import "testing"
+
+func TestParseByteSize(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    int64
+		wantErr bool
+	}{
+		{input: "0", want: 0},
+		{input: "512", want: 512},
+		{input: "512B", want: 512},
+		{input: "10K", want: 10 * 1024},
+		{input: "10k", want: 10 * 1024},
+		{input: "10KB", want: 10 * 1024},
+		{input: "10KiB", want: 10 * 1024},
+		{input: "1.5M", want: 1536 * 1024},
+		{input: "2G", want: 2 * 1024 * 1024 * 1024},
+		{input: "1T", want: 1024 * 1024 * 1024 * 1024},
+		{input: " 3M ", want: 3 * 1024 * 1024},
+		{input: "", wantErr: true},
+		{input: "fast", wantErr: true},
+		{input: "-1M", wantErr: true},
+		{input: "M", wantErr: true},
+		// "iB" needs a unit before it
+		{input: "10iB", wantErr: true},
+		{input: "iB", wantErr: true},
+		{input: "2GIB", want: 2 * 1024 * 1024 * 1024},
+		// Values that would convert to a negative size and disable a size limit
+		{input: "NaN", wantErr: true},
+		{input: "NaNK", wantErr: true},
+		{input: "inf", wantErr: true},
+		{input: "+InfM", wantErr: true},
+		{input: "1e30T", wantErr: true},
+		{input: "1e400", wantErr: true},
+		{input: "8388608T", wantErr: true},
+		{input: "8388607T", want: 8388607 << 40},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseByteSize(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseByteSize(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseByteSize(%q) = %d, want %d", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatByteSize(t *testing.T) {
+	tests := []struct {
+		name     string
+		bytes    int64
+		expected string
+	}{
+		{"zero", 0, "0 B"},
+		{"bytes", 512, "512 B"},
+		{"1 KiB", 1024, "1.0 KiB"},
+		{"1.5 KiB", 1536, "1.5 KiB"},
+		{"1 MiB", 1048576, "1.0 MiB"},
+		{"2.5 MiB", 2621440, "2.5 MiB"},
+		{"1 GiB", 1073741824, "1.0 GiB"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := FormatByteSize(tt.bytes)
+			if result != tt.expected {
+				t.Errorf("FormatByteSize(%d) = %s, want %s", tt.bytes, result, tt.expected)
+			}
+		})
+	}
+}